@@ -26,6 +26,11 @@ var (
 	httpListenAddr    = flag.String("httpListenAddr", ":8428", "TCP address to listen for http connections")
 	minScrapeInterval = flag.Duration("dedup.minScrapeInterval", 0, "Leave only the first sample in every time series per each discrete interval "+
 		"equal to -dedup.minScrapeInterval > 0. See https://docs.victoriametrics.com/#deduplication for details")
+	dedupPolicy = flag.String("dedup.policy", "first", "Which sample to leave per each -dedup.minScrapeInterval. Supported values: first, last, min, max. "+
+		"See https://docs.victoriametrics.com/#deduplication for details")
+	downsamplingPeriods = flagutil.NewArray("downsampling.period", "Comma-separated downsampling periods in the format <offset>:<interval>, for example, 30d:5m,180d:1h . "+
+		"Leaves only the first sample in every time series per each discrete <interval> for samples older than <offset>. "+
+		"See https://docs.victoriametrics.com/#downsampling for details")
 	dryRun = flag.Bool("dryRun", false, "Whether to check only -promscrape.config and then exit. "+
 		"Unknown config entries are allowed in -promscrape.config by default. This can be changed with -promscrape.config.strictParse")
 )
@@ -52,6 +57,12 @@ func main() {
 	logger.Infof("starting VictoriaMetrics at %q...", *httpListenAddr)
 	startTime := time.Now()
 	storage.SetMinScrapeIntervalForDeduplication(*minScrapeInterval)
+	if err := storage.SetDedupPolicy(*dedupPolicy); err != nil {
+		logger.Fatalf("cannot parse -dedup.policy: %s", err)
+	}
+	if err := storage.SetDownsamplingPeriods(*downsamplingPeriods); err != nil {
+		logger.Fatalf("cannot parse -downsampling.period: %s", err)
+	}
 	vmstorage.Init(promql.ResetRollupResultCacheIfNeeded)
 	vmselect.Init()
 	vminsert.Init()
@@ -97,6 +108,7 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 			{"/api/v1/status/tsdb", "tsdb status page"},
 			{"/api/v1/status/top_queries", "top queries"},
 			{"/api/v1/status/active_queries", "active queries"},
+			{"/api/v1/status/series_limits", "metric names with the most new series for -storage.maxHourlySeries and -storage.maxDailySeries limits"},
 		})
 		return true
 	}
@@ -17,6 +17,9 @@ type PushCtx struct {
 
 	// Samples contains flat list of all the samples used in WriteRequest.
 	Samples []prompbmarshal.Sample
+
+	// Exemplars contains flat list of all the exemplars used in WriteRequest.
+	Exemplars []prompbmarshal.Exemplar
 }
 
 // Reset resets ctx.
@@ -26,6 +29,7 @@ func (ctx *PushCtx) Reset() {
 		ts := &tss[i]
 		ts.Labels = nil
 		ts.Samples = nil
+		ts.Exemplars = nil
 	}
 	ctx.WriteRequest.Timeseries = ctx.WriteRequest.Timeseries[:0]
 
@@ -33,6 +37,11 @@ func (ctx *PushCtx) Reset() {
 	ctx.Labels = ctx.Labels[:0]
 
 	ctx.Samples = ctx.Samples[:0]
+
+	for i := range ctx.Exemplars {
+		ctx.Exemplars[i].Labels = nil
+	}
+	ctx.Exemplars = ctx.Exemplars[:0]
 }
 
 // GetPushCtx returns PushCtx from pool.
@@ -21,16 +21,22 @@ var (
 )
 
 // InsertHandler processes csv data from req.
-func InsertHandler(at *auth.Token, req *http.Request) error {
+//
+// It returns the number of skipped malformed csv lines.
+func InsertHandler(at *auth.Token, req *http.Request) (int, error) {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return writeconcurrencylimiter.Do(func() error {
-		return parser.ParseStream(req, func(rows []parser.Row) error {
+	invalidLines := 0
+	err = writeconcurrencylimiter.Do(func() error {
+		var err error
+		invalidLines, err = parser.ParseStream(req, func(rows []parser.Row) error {
 			return insertRows(at, rows, extraLabels)
 		})
+		return err
 	})
+	return invalidLines, err
 }
 
 func insertRows(at *auth.Token, rows []parser.Row, extraLabels []prompbmarshal.Label) error {
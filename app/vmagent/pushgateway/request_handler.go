@@ -0,0 +1,48 @@
+package pushgateway
+
+import (
+	"net/http"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/remotewrite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/pushgateway"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	rowsInserted  = metrics.NewCounter(`vmagent_rows_inserted_total{type="pushgateway"}`)
+	rowsPerInsert = metrics.NewHistogram(`vmagent_rows_per_insert{type="pushgateway"}`)
+)
+
+var registry *pushgateway.Registry
+
+// Init initializes Pushgateway API handler.
+//
+// MustStop must be called when Pushgateway API is no longer needed.
+func Init() {
+	registry = pushgateway.MustNewRegistry(pushSeries)
+}
+
+// MustStop stops Pushgateway API handler.
+func MustStop() {
+	registry.MustStop()
+	registry = nil
+}
+
+// InsertHandler processes Pushgateway API requests at /metrics/job/...
+//
+// See https://github.com/prometheus/pushgateway#api
+func InsertHandler(req *http.Request) error {
+	return writeconcurrencylimiter.Do(func() error {
+		return registry.HandleRequest(req)
+	})
+}
+
+func pushSeries(tss []prompbmarshal.TimeSeries) {
+	remotewrite.Push(&prompbmarshal.WriteRequest{
+		Timeseries: tss,
+	})
+	rowsInserted.Add(len(tss))
+	rowsPerInsert.Update(float64(len(tss)))
+}
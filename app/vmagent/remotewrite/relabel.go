@@ -111,8 +111,9 @@ func (rctx *relabelCtx) applyRelabeling(tss []prompbmarshal.TimeSeries, extraLab
 			continue
 		}
 		tssDst = append(tssDst, prompbmarshal.TimeSeries{
-			Labels:  labels[labelsLen:],
-			Samples: ts.Samples,
+			Labels:    labels[labelsLen:],
+			Samples:   ts.Samples,
+			Exemplars: ts.Exemplars,
 		})
 	}
 	rctx.labels = labels
@@ -239,9 +239,18 @@ func Push(wr *prompbmarshal.WriteRequest) {
 // Note that wr may be modified by Push due to relabeling and rounding.
 func PushWithAuthToken(at *auth.Token, wr *prompbmarshal.WriteRequest) {
 	if at == nil && len(*remoteWriteMultitenantURLs) > 0 {
+		if *tenantFromLabels {
+			// Obtain tenant from vm_account_id and vm_project_id labels.
+			pushWithTenantFromLabels(wr.Timeseries)
+			return
+		}
 		// Write data to default tenant if at isn't set while -remoteWrite.multitenantURL is set.
 		at = defaultAuthToken
 	}
+	pushInternal(at, wr.Timeseries)
+}
+
+func pushInternal(at *auth.Token, tss []prompbmarshal.TimeSeries) {
 	var rwctxs []*remoteWriteCtx
 	if at == nil {
 		rwctxs = rwctxsDefault
@@ -268,7 +277,6 @@ func PushWithAuthToken(at *auth.Token, wr *prompbmarshal.WriteRequest) {
 	if pcsGlobal.Len() > 0 || len(labelsGlobal) > 0 {
 		rctx = getRelabelCtx()
 	}
-	tss := wr.Timeseries
 	for len(tss) > 0 {
 		// Process big tss in smaller blocks in order to reduce the maximum memory usage
 		samplesCount := 0
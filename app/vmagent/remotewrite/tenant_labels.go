@@ -0,0 +1,95 @@
+package remotewrite
+
+import (
+	"flag"
+	"strconv"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/tenantmetrics"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var tenantFromLabels = flag.Bool("remoteWrite.tenantFromLabels", false, "Whether to obtain tenant for the data received via non-multitenant endpoints "+
+	"from vm_account_id and vm_project_id labels. These labels are removed from the data before sending it to remote storage. "+
+	"This flag works only if -remoteWrite.multitenantURL is set. See https://docs.victoriametrics.com/vmagent.html#multitenancy")
+
+const (
+	accountIDLabel = "vm_account_id"
+	projectIDLabel = "vm_project_id"
+)
+
+// pushWithTenantFromLabels splits tss by tenants obtained from vm_account_id and vm_project_id labels
+// and pushes every tenant's series to the corresponding -remoteWrite.multitenantURL.
+func pushWithTenantFromLabels(tss []prompbmarshal.TimeSeries) {
+	m := make(map[tenantmetrics.TenantID][]prompbmarshal.TimeSeries)
+	var order []tenantmetrics.TenantID
+	for i := range tss {
+		ts := &tss[i]
+		tenantID := extractTenantFromLabels(ts)
+		if _, ok := m[tenantID]; !ok {
+			order = append(order, tenantID)
+		}
+		m[tenantID] = append(m[tenantID], *ts)
+	}
+	for _, tenantID := range order {
+		at := &auth.Token{
+			AccountID: tenantID.AccountID,
+			ProjectID: tenantID.ProjectID,
+		}
+		pushInternal(at, m[tenantID])
+	}
+}
+
+// extractTenantFromLabels returns tenant from vm_account_id and vm_project_id labels and removes these labels from ts.
+//
+// The corresponding defaultAuthToken part is returned for missing labels. Series with invalid label values are written to defaultAuthToken.
+func extractTenantFromLabels(ts *prompbmarshal.TimeSeries) tenantmetrics.TenantID {
+	tenantID := tenantmetrics.TenantID{
+		AccountID: defaultAuthToken.AccountID,
+		ProjectID: defaultAuthToken.ProjectID,
+	}
+	isValid := true
+	dst := ts.Labels[:0]
+	for _, label := range ts.Labels {
+		switch label.Name {
+		case accountIDLabel:
+			n, err := strconv.ParseUint(label.Value, 10, 32)
+			if err != nil {
+				isValid = false
+				continue
+			}
+			tenantID.AccountID = uint32(n)
+		case projectIDLabel:
+			n, err := strconv.ParseUint(label.Value, 10, 32)
+			if err != nil {
+				isValid = false
+				continue
+			}
+			tenantID.ProjectID = uint32(n)
+		default:
+			dst = append(dst, label)
+		}
+	}
+	ts.Labels = dst
+	if !isValid {
+		invalidTenantLabels.Inc()
+		select {
+		case <-logInvalidTenantLabelsTicker.C:
+			logger.Warnf("cannot parse %s or %s label for series %s; writing it to %d:%d tenant",
+				accountIDLabel, projectIDLabel, labelsToString(ts.Labels), defaultAuthToken.AccountID, defaultAuthToken.ProjectID)
+		default:
+		}
+		return tenantmetrics.TenantID{
+			AccountID: defaultAuthToken.AccountID,
+			ProjectID: defaultAuthToken.ProjectID,
+		}
+	}
+	return tenantID
+}
+
+var logInvalidTenantLabelsTicker = time.NewTicker(5 * time.Second)
+
+var invalidTenantLabels = metrics.NewCounter(`vmagent_remotewrite_invalid_tenant_labels_total`)
@@ -0,0 +1,57 @@
+package remotewrite
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/tenantmetrics"
+)
+
+func TestExtractTenantFromLabels(t *testing.T) {
+	f := func(labels []prompbmarshal.Label, tenantIDExpected tenantmetrics.TenantID, labelsExpected string) {
+		t.Helper()
+		ts := &prompbmarshal.TimeSeries{
+			Labels: labels,
+		}
+		tenantID := extractTenantFromLabels(ts)
+		if tenantID != tenantIDExpected {
+			t.Fatalf("unexpected tenant; got %d:%d; want %d:%d", tenantID.AccountID, tenantID.ProjectID, tenantIDExpected.AccountID, tenantIDExpected.ProjectID)
+		}
+		if s := labelsToString(ts.Labels); s != labelsExpected {
+			t.Fatalf("unexpected labels; got %s; want %s", s, labelsExpected)
+		}
+	}
+
+	// Missing tenant labels
+	f([]prompbmarshal.Label{
+		{Name: "__name__", Value: "foo"},
+	}, tenantmetrics.TenantID{}, `{__name__="foo"}`)
+
+	// Only account id
+	f([]prompbmarshal.Label{
+		{Name: "__name__", Value: "foo"},
+		{Name: "vm_account_id", Value: "42"},
+		{Name: "job", Value: "bar"},
+	}, tenantmetrics.TenantID{AccountID: 42}, `{__name__="foo",job="bar"}`)
+
+	// Account id and project id
+	f([]prompbmarshal.Label{
+		{Name: "vm_project_id", Value: "7"},
+		{Name: "__name__", Value: "foo"},
+		{Name: "vm_account_id", Value: "42"},
+	}, tenantmetrics.TenantID{AccountID: 42, ProjectID: 7}, `{__name__="foo"}`)
+
+	// Invalid account id
+	f([]prompbmarshal.Label{
+		{Name: "__name__", Value: "foo"},
+		{Name: "vm_account_id", Value: "bar"},
+		{Name: "vm_project_id", Value: "7"},
+	}, tenantmetrics.TenantID{}, `{__name__="foo"}`)
+
+	// Too big project id
+	f([]prompbmarshal.Label{
+		{Name: "__name__", Value: "foo"},
+		{Name: "vm_account_id", Value: "1"},
+		{Name: "vm_project_id", Value: "4294967296"},
+	}, tenantmetrics.TenantID{}, `{__name__="foo"}`)
+}
@@ -0,0 +1,53 @@
+package statsd
+
+import (
+	"io"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/remotewrite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	parser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/statsd"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	rowsInserted  = metrics.NewCounter(`vmagent_rows_inserted_total{type="statsd"}`)
+	rowsPerInsert = metrics.NewHistogram(`vmagent_rows_per_insert{type="statsd"}`)
+)
+
+var aggregator *parser.Aggregator
+
+// Init initializes statsd aggregation.
+//
+// MustStop must be called when statsd data is no longer ingested.
+func Init() {
+	aggregator = parser.MustNewAggregator(pushAggregatedSeries)
+}
+
+// MustStop flushes the aggregated statsd data to remote storage and stops the aggregation.
+func MustStop() {
+	aggregator.MustStop()
+	aggregator = nil
+}
+
+// InsertHandler processes remote write for statsd plaintext protocol.
+//
+// The ingested rows are aggregated before being sent to remote storage.
+//
+// See https://github.com/statsd/statsd/blob/master/docs/metric_types.md
+func InsertHandler(r io.Reader) error {
+	return writeconcurrencylimiter.Do(func() error {
+		return parser.ParseStream(r, func(rows []parser.Row) error {
+			aggregator.Push(rows)
+			return nil
+		})
+	})
+}
+
+func pushAggregatedSeries(tss []prompbmarshal.TimeSeries) {
+	remotewrite.Push(&prompbmarshal.WriteRequest{
+		Timeseries: tss,
+	})
+	rowsInserted.Add(len(tss))
+	rowsPerInsert.Update(float64(len(tss)))
+}
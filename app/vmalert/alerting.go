@@ -19,16 +19,21 @@ import (
 
 // AlertingRule is basic alert entity
 type AlertingRule struct {
-	Type         datasource.Type
-	RuleID       uint64
-	Name         string
-	Expr         string
-	For          time.Duration
-	Labels       map[string]string
-	Annotations  map[string]string
-	GroupID      uint64
-	GroupName    string
-	EvalInterval time.Duration
+	Type          datasource.Type
+	RuleID        uint64
+	Name          string
+	Expr          string
+	For           time.Duration
+	KeepFiringFor time.Duration
+	Labels        map[string]string
+	Annotations   map[string]string
+	GroupID       uint64
+	GroupName     string
+	EvalInterval  time.Duration
+	Debug         bool
+	Limit         int
+	OnError       string
+	OnNoData      string
 
 	q datasource.Querier
 
@@ -45,6 +50,8 @@ type AlertingRule struct {
 	// stores the number of samples returned during
 	// the last evaluation
 	lastExecSamples int
+	// stores the duration of the last evaluation
+	lastExecDuration time.Duration
 
 	metrics *alertingRuleMetrics
 }
@@ -58,20 +65,29 @@ type alertingRuleMetrics struct {
 
 func newAlertingRule(qb datasource.QuerierBuilder, group *Group, cfg config.Rule) *AlertingRule {
 	ar := &AlertingRule{
-		Type:         cfg.Type,
-		RuleID:       cfg.ID,
-		Name:         cfg.Alert,
-		Expr:         cfg.Expr,
-		For:          cfg.For.Duration(),
-		Labels:       cfg.Labels,
-		Annotations:  cfg.Annotations,
-		GroupID:      group.ID(),
-		GroupName:    group.Name,
-		EvalInterval: group.Interval,
+		Type:          cfg.Type,
+		RuleID:        cfg.ID,
+		Name:          cfg.Alert,
+		Expr:          cfg.Expr,
+		For:           cfg.For.Duration(),
+		KeepFiringFor: cfg.KeepFiringFor.Duration(),
+		Labels:        cfg.Labels,
+		Annotations:   cfg.Annotations,
+		GroupID:       group.ID(),
+		GroupName:     group.Name,
+		EvalInterval:  group.Interval,
+		Debug:         cfg.Debug,
+		Limit:         cfg.Limit,
+		OnError:       cfg.OnError,
+		OnNoData:      cfg.OnNoData,
 		q: qb.BuildWithParams(datasource.QuerierParams{
 			DataSourceType:     &cfg.Type,
 			EvaluationInterval: group.Interval,
 			ExtraLabels:        group.ExtraFilterLabels,
+			QueryParams:        group.Params,
+			Headers:            group.Headers,
+			Debug:              cfg.Debug,
+			Tenant:             group.Tenant,
 		}),
 		alerts:  make(map[uint64]*notifier.Alert),
 		metrics: &alertingRuleMetrics{},
@@ -199,24 +215,62 @@ func (ar *AlertingRule) ExecRange(ctx context.Context, start, end time.Time) ([]
 // Exec executes AlertingRule expression via the given Querier.
 // Based on the Querier results AlertingRule maintains notifier.Alerts
 func (ar *AlertingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries, error) {
+	return ar.execAt(ctx, time.Now())
+}
+
+// execAt executes AlertingRule similarly to Exec, while treating ts as the current time.
+func (ar *AlertingRule) execAt(ctx context.Context, ts time.Time) ([]prompbmarshal.TimeSeries, error) {
+	start := time.Now()
 	qMetrics, err := ar.q.Query(ctx, ar.Expr)
 	ar.mu.Lock()
 	defer ar.mu.Unlock()
 
 	ar.lastExecError = err
-	ar.lastExecTime = time.Now()
+	ar.lastExecTime = ts
 	ar.lastExecSamples = len(qMetrics)
+	ar.lastExecDuration = time.Since(start)
+	if err == nil && ar.Limit > 0 && len(qMetrics) > ar.Limit {
+		err = fmt.Errorf("exec exceeded limit of %d with %d series", ar.Limit, len(qMetrics))
+		ar.lastExecError = err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query %q: %w", ar.Expr, err)
+		ar.logDebugf(ts, nil, "query returned error: %s", err)
+		switch ar.OnError {
+		case config.PolicyAlerting:
+			logger.Errorf("rule %q: failed to execute query %q: %s; firing synthetic alert according to on_error=%q", ar, ar.Expr, err, ar.OnError)
+			qMetrics = []datasource.Metric{newReasonMetric(alertReasonError, ts)}
+		case config.PolicyClear:
+			logger.Errorf("rule %q: failed to execute query %q: %s; resolving alerts according to on_error=%q", ar, ar.Expr, err, ar.OnError)
+			qMetrics = nil
+		default:
+			return nil, fmt.Errorf("failed to execute query %q: %w", ar.Expr, err)
+		}
+	} else {
+		ar.logDebugf(ts, nil, "query returned %d samples (elapsed: %s)", len(qMetrics), ar.lastExecDuration)
+		for _, m := range qMetrics {
+			ar.logDebugf(ts, nil, "returned series %s=%v", labelsToString(m.Labels), m.Values)
+		}
 	}
 
 	for h, a := range ar.alerts {
 		// cleanup inactive alerts from previous Exec
 		if a.State == notifier.StateInactive {
+			ar.logDebugf(ts, a, "INACTIVE => DELETED: is absent in current evaluation round")
 			delete(ar.alerts, h)
 		}
 	}
 
+	if err == nil && len(qMetrics) == 0 {
+		switch ar.OnNoData {
+		case config.PolicyAlerting:
+			ar.logDebugf(ts, nil, "firing synthetic alert according to on_no_data=%q", ar.OnNoData)
+			qMetrics = []datasource.Metric{newReasonMetric(alertReasonNoData, ts)}
+		case config.PolicyKeep:
+			ar.logDebugf(ts, nil, "keeping alerts state according to on_no_data=%q", ar.OnNoData)
+			return ar.toTimeSeries(ar.lastExecTime.Unix()), nil
+		}
+	}
+
 	qFn := func(query string) ([]datasource.Metric, error) { return ar.q.Query(ctx, query) }
 	updated := make(map[uint64]struct{})
 	// update list of active alerts
@@ -239,6 +293,8 @@ func (ar *AlertingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries, e
 		}
 		updated[h] = struct{}{}
 		if a, ok := ar.alerts[h]; ok {
+			// reset keep_firing_for state, since the expression returns the alert again
+			a.KeepFiringSince = time.Time{}
 			if a.Value != m.Values[0] {
 				// update Value field with latest value
 				a.Value = m.Values[0]
@@ -259,6 +315,7 @@ func (ar *AlertingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries, e
 		a.ID = h
 		a.State = notifier.StatePending
 		ar.alerts[h] = a
+		ar.logDebugf(ts, a, "created in state PENDING")
 	}
 
 	for h, a := range ar.alerts {
@@ -268,20 +325,47 @@ func (ar *AlertingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries, e
 			if a.State == notifier.StatePending {
 				// alert was in Pending state - it is not
 				// active anymore
+				ar.logDebugf(ts, a, "PENDING => DELETED: is absent in current evaluation round")
 				delete(ar.alerts, h)
 				continue
 			}
+			if ar.KeepFiringFor > 0 {
+				if a.KeepFiringSince.IsZero() {
+					a.KeepFiringSince = ts
+				}
+				if ts.Sub(a.KeepFiringSince) < ar.KeepFiringFor {
+					// keep the alert firing until keep_firing_for expires
+					ar.logDebugf(ts, a, "KEEP_FIRING: is absent in current evaluation round since %s", a.KeepFiringSince.Format(time.RFC3339))
+					continue
+				}
+			}
 			a.State = notifier.StateInactive
+			ar.logDebugf(ts, a, "FIRING => INACTIVE: is absent in current evaluation round")
 			continue
 		}
-		if a.State == notifier.StatePending && time.Since(a.Start) >= ar.For {
+		if a.State == notifier.StatePending && ts.Sub(a.Start) >= ar.For {
 			a.State = notifier.StateFiring
 			alertsFired.Inc()
+			ar.logDebugf(ts, a, "PENDING => FIRING: %s since becoming active at %s", ts.Sub(a.Start), a.Start.Format(time.RFC3339))
 		}
 	}
 	return ar.toTimeSeries(ar.lastExecTime.Unix()), nil
 }
 
+// logDebugf logs the given message if debug mode is enabled for the rule.
+// The message is prefixed with the rule identity, evaluation time
+// and the alert's labels if a isn't nil.
+func (ar *AlertingRule) logDebugf(at time.Time, a *notifier.Alert, format string, args ...interface{}) {
+	if !ar.Debug {
+		return
+	}
+	prefix := fmt.Sprintf("DEBUG rule %q:%q (%d) at %s: ", ar.GroupName, ar.Name, ar.RuleID, at.Format(time.RFC3339))
+	if a != nil {
+		prefix += fmt.Sprintf("alert %d %s ", a.ID, mapToString(a.Labels))
+	}
+	logger.Infof("%s%s", prefix, fmt.Sprintf(format, args...))
+}
+
 func expandLabels(m datasource.Metric, q notifier.QueryFn, ar *AlertingRule) (map[string]string, error) {
 	metricLabels := make(map[string]string)
 	for _, l := range m.Labels {
@@ -317,6 +401,11 @@ func (ar *AlertingRule) UpdateWith(r Rule) error {
 	}
 	ar.Expr = nr.Expr
 	ar.For = nr.For
+	ar.KeepFiringFor = nr.KeepFiringFor
+	ar.Debug = nr.Debug
+	ar.Limit = nr.Limit
+	ar.OnError = nr.OnError
+	ar.OnNoData = nr.OnNoData
 	ar.Labels = nr.Labels
 	ar.Annotations = nr.Annotations
 	ar.EvalInterval = nr.EvalInterval
@@ -383,26 +472,77 @@ func (ar *AlertingRule) AlertAPI(id uint64) *APIAlert {
 // RuleAPI returns Rule representation in form
 // of APIAlertingRule
 func (ar *AlertingRule) RuleAPI() APIAlertingRule {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
 	var lastErr string
 	if ar.lastExecError != nil {
 		lastErr = ar.lastExecError.Error()
 	}
 	return APIAlertingRule{
 		// encode as strings to avoid rounding
-		ID:          fmt.Sprintf("%d", ar.ID()),
-		GroupID:     fmt.Sprintf("%d", ar.GroupID),
-		Type:        ar.Type.String(),
-		Name:        ar.Name,
-		Expression:  ar.Expr,
-		For:         ar.For.String(),
-		LastError:   lastErr,
-		LastSamples: ar.lastExecSamples,
-		LastExec:    ar.lastExecTime,
-		Labels:      ar.Labels,
-		Annotations: ar.Annotations,
+		ID:               fmt.Sprintf("%d", ar.ID()),
+		GroupID:          fmt.Sprintf("%d", ar.GroupID),
+		Type:             ar.Type.String(),
+		Name:             ar.Name,
+		Expression:       ar.Expr,
+		For:              ar.For.String(),
+		KeepFiringFor:    ar.KeepFiringFor.String(),
+		Debug:            ar.Debug,
+		Limit:            ar.Limit,
+		OnError:          ar.onError(),
+		OnNoData:         ar.onNoData(),
+		LastError:        lastErr,
+		LastSamples:      ar.lastExecSamples,
+		LastExec:         ar.lastExecTime,
+		LastExecDuration: ar.lastExecDuration.Seconds(),
+		Labels:           ar.Labels,
+		Annotations:      ar.Annotations,
 	}
 }
 
+// PromRuleAPI returns Rule representation in form
+// of Prometheus-compatible PromAlertingRule
+func (ar *AlertingRule) PromRuleAPI() PromAlertingRule {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+	var lastErr string
+	if ar.lastExecError != nil {
+		lastErr = ar.lastExecError.Error()
+	}
+	r := PromAlertingRule{
+		Name:           ar.Name,
+		Query:          ar.Expr,
+		Duration:       ar.For.Seconds(),
+		KeepFiringFor:  ar.KeepFiringFor.Seconds(),
+		Labels:         ar.Labels,
+		Annotations:    ar.Annotations,
+		Alerts:         []*APIAlert{},
+		Health:         ruleHealth(ar.lastExecTime, ar.lastExecError),
+		LastError:      lastErr,
+		EvaluationTime: ar.lastExecDuration.Seconds(),
+		LastEvaluation: ar.lastExecTime,
+		Type:           "alerting",
+	}
+	state := notifier.StateInactive
+	for _, a := range ar.alerts {
+		// inactive alerts are kept only until the next evaluation
+		// in order to send resolve notifications, so skip them
+		if a.State == notifier.StateInactive {
+			continue
+		}
+		if a.State > state {
+			state = a.State
+		}
+		r.Alerts = append(r.Alerts, ar.newAlertAPI(*a))
+	}
+	// sort list of alerts for deterministic output
+	sort.Slice(r.Alerts, func(i, j int) bool {
+		return r.Alerts[i].ID < r.Alerts[j].ID
+	})
+	r.State = state.String()
+	return r
+}
+
 // AlertsAPI generates list of APIAlert objects from existing alerts
 func (ar *AlertingRule) AlertsAPI() []*APIAlert {
 	var alerts []*APIAlert
@@ -415,6 +555,10 @@ func (ar *AlertingRule) AlertsAPI() []*APIAlert {
 }
 
 func (ar *AlertingRule) newAlertAPI(a notifier.Alert) *APIAlert {
+	var keepFiringSince *time.Time
+	if !a.KeepFiringSince.IsZero() {
+		keepFiringSince = &a.KeepFiringSince
+	}
 	return &APIAlert{
 		// encode as strings to avoid rounding
 		ID:      fmt.Sprintf("%d", a.ID),
@@ -423,14 +567,27 @@ func (ar *AlertingRule) newAlertAPI(a notifier.Alert) *APIAlert {
 
 		Name:        a.Name,
 		Expression:  ar.Expr,
-		Labels:      a.Labels,
+		Labels:      alertLabelsAPI(a),
 		Annotations: a.Annotations,
 		State:       a.State.String(),
 		ActiveAt:    a.Start,
 		Value:       strconv.FormatFloat(a.Value, 'f', -1, 32),
+
+		KeepFiringSince: keepFiringSince,
 	}
 }
 
+// alertLabelsAPI returns a copy of alert labels with alertname label
+// added for compatibility with Prometheus API.
+func alertLabelsAPI(a notifier.Alert) map[string]string {
+	labels := make(map[string]string, len(a.Labels)+1)
+	for k, v := range a.Labels {
+		labels[k] = v
+	}
+	labels[alertNameLabel] = a.Name
+	return labels
+}
+
 const (
 	// alertMetricName is the metric name for synthetic alert timeseries.
 	alertMetricName = "ALERTS"
@@ -444,8 +601,42 @@ const (
 
 	// alertGroupNameLabel defines the label name attached for generated time series.
 	alertGroupNameLabel = "alertgroup"
+
+	// alertReasonLabel is the label name attached to synthetic alerts
+	// fired according to on_error or on_no_data params.
+	alertReasonLabel = "alertreason"
+	// alertReasonError is the alertReasonLabel value for alerts fired on evaluation errors.
+	alertReasonError = "error"
+	// alertReasonNoData is the alertReasonLabel value for alerts fired when the expression returns no data.
+	alertReasonNoData = "no_data"
 )
 
+// onError returns the effective on_error policy.
+func (ar *AlertingRule) onError() string {
+	if ar.OnError == "" {
+		return config.PolicyKeep
+	}
+	return ar.OnError
+}
+
+// onNoData returns the effective on_no_data policy.
+func (ar *AlertingRule) onNoData() string {
+	if ar.OnNoData == "" {
+		return config.PolicyClear
+	}
+	return ar.OnNoData
+}
+
+// newReasonMetric returns a synthetic series used for firing
+// an alert according to on_error or on_no_data params.
+func newReasonMetric(reason string, ts time.Time) datasource.Metric {
+	return datasource.Metric{
+		Labels:     []datasource.Label{{Name: alertReasonLabel, Value: reason}},
+		Values:     []float64{0},
+		Timestamps: []int64{ts.Unix()},
+	}
+}
+
 // alertToTimeSeries converts the given alert with the given timestamp to timeseries
 func (ar *AlertingRule) alertToTimeSeries(a *notifier.Alert, timestamp int64) []prompbmarshal.TimeSeries {
 	var tss []prompbmarshal.TimeSeries
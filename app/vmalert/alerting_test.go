@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
@@ -599,6 +600,210 @@ func TestAlertingRule_Restore(t *testing.T) {
 	}
 }
 
+func TestAlertingRule_KeepFiringFor(t *testing.T) {
+	fq := &fakeQuerier{}
+	ar := newTestAlertingRule("keep firing", 0)
+	ar.KeepFiringFor = 2 * time.Minute
+	// enable debug logging in order to verify it works for all the state transitions
+	ar.Debug = true
+	ar.q = fq
+	h := hash(metricWithLabels(t, "name", "foo"))
+	ts := time.Now()
+
+	f := func(offset time.Duration, active bool, expState notifier.AlertState) {
+		t.Helper()
+		fq.reset()
+		if active {
+			fq.add(metricWithValueAndLabels(t, 1, "name", "foo"))
+		}
+		if _, err := ar.execAt(context.TODO(), ts.Add(offset)); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		a, ok := ar.alerts[h]
+		if !ok {
+			t.Fatalf("expected to have alert at %v", offset)
+		}
+		if a.State != expState {
+			t.Fatalf("unexpected alert state at %v; got %s; want %s", offset, a.State, expState)
+		}
+	}
+	f(0, true, notifier.StateFiring)
+	// the alert keeps firing during keep_firing_for
+	f(time.Minute, false, notifier.StateFiring)
+	if since := ar.alerts[h].KeepFiringSince; !since.Equal(ts.Add(time.Minute)) {
+		t.Fatalf("unexpected KeepFiringSince %v", since)
+	}
+	// the alert returns back, so keep_firing_for state must be reset
+	f(2*time.Minute, true, notifier.StateFiring)
+	if since := ar.alerts[h].KeepFiringSince; !since.IsZero() {
+		t.Fatalf("expected zero KeepFiringSince; got %v", since)
+	}
+	f(3*time.Minute, false, notifier.StateFiring)
+	f(4*time.Minute, false, notifier.StateFiring)
+	// keep_firing_for expired
+	f(5*time.Minute, false, notifier.StateInactive)
+
+	// pending alerts aren't kept
+	ar.For = time.Minute
+	f(6*time.Minute, true, notifier.StatePending)
+	fq.reset()
+	if _, err := ar.execAt(context.TODO(), ts.Add(7*time.Minute)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(ar.alerts) != 0 {
+		t.Fatalf("expected pending alert to be removed; got %v", ar.alerts)
+	}
+}
+
+func TestAlertingRule_Limit(t *testing.T) {
+	fq := &fakeQuerier{}
+	ar := newTestAlertingRule("limit", 0)
+	ar.Limit = 1
+	ar.q = fq
+
+	fq.add(metricWithValueAndLabels(t, 1, "name", "foo"))
+	if _, err := ar.Exec(context.TODO()); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(ar.alerts) != 1 {
+		t.Fatalf("expected to have 1 alert; got %d", len(ar.alerts))
+	}
+
+	// the limit is exceeded, so the state of alerts must be kept by default
+	fq.add(metricWithValueAndLabels(t, 1, "name", "bar"))
+	_, err := ar.Exec(context.TODO())
+	if err == nil || !strings.Contains(err.Error(), "exceeded limit of 1 with 2 series") {
+		t.Fatalf("expected limit error; got %v", err)
+	}
+	if len(ar.alerts) != 1 {
+		t.Fatalf("expected to have 1 alert; got %d", len(ar.alerts))
+	}
+	if ar.lastExecError == nil {
+		t.Fatalf("expected lastExecError to be set")
+	}
+}
+
+func TestAlertingRule_OnErrorOnNoData(t *testing.T) {
+	fq := &fakeQuerier{}
+	newRule := func(onError, onNoData string) *AlertingRule {
+		ar := newTestAlertingRule("policy", 0)
+		ar.OnError = onError
+		ar.OnNoData = onNoData
+		ar.Debug = true
+		ar.q = fq
+		return ar
+	}
+	h := hash(metricWithLabels(t, "name", "foo"))
+	exec := func(ar *AlertingRule, active bool, queryErr error) error {
+		t.Helper()
+		fq.reset()
+		if active {
+			fq.add(metricWithValueAndLabels(t, 1, "name", "foo"))
+		}
+		fq.setErr(queryErr)
+		_, err := ar.Exec(context.TODO())
+		return err
+	}
+	reasonAlert := func(ar *AlertingRule, reason string) *notifier.Alert {
+		t.Helper()
+		for _, a := range ar.alerts {
+			if a.Labels[alertReasonLabel] == reason {
+				return a
+			}
+		}
+		return nil
+	}
+	queryErr := errors.New("datasource is down")
+
+	// on_error=keep (default)
+	ar := newRule("", "")
+	if err := exec(ar, true, nil); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if err := exec(ar, false, queryErr); err == nil {
+		t.Fatalf("expected to get error")
+	}
+	if a, ok := ar.alerts[h]; !ok || a.State != notifier.StateFiring {
+		t.Fatalf("expected alert to keep firing; got %v", ar.alerts)
+	}
+
+	// on_error=clear
+	ar = newRule(config.PolicyClear, "")
+	if err := exec(ar, true, nil); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if err := exec(ar, false, queryErr); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if a, ok := ar.alerts[h]; !ok || a.State != notifier.StateInactive {
+		t.Fatalf("expected alert to be resolved; got %v", ar.alerts)
+	}
+	if ar.lastExecError == nil {
+		t.Fatalf("expected lastExecError to be set")
+	}
+
+	// on_error=alerting
+	ar = newRule(config.PolicyAlerting, "")
+	if err := exec(ar, true, nil); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if err := exec(ar, false, queryErr); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	a := reasonAlert(ar, alertReasonError)
+	if a == nil || a.State != notifier.StateFiring {
+		t.Fatalf("expected synthetic alert to fire; got %v", ar.alerts)
+	}
+	if a, ok := ar.alerts[h]; !ok || a.State != notifier.StateInactive {
+		t.Fatalf("expected alert to be resolved; got %v", ar.alerts)
+	}
+	// synthetic alert is resolved once the datasource recovers
+	if err := exec(ar, true, nil); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if a := reasonAlert(ar, alertReasonError); a == nil || a.State != notifier.StateInactive {
+		t.Fatalf("expected synthetic alert to be resolved; got %v", ar.alerts)
+	}
+
+	// on_no_data=clear (default)
+	ar = newRule("", "")
+	if err := exec(ar, true, nil); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if err := exec(ar, false, nil); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if a, ok := ar.alerts[h]; !ok || a.State != notifier.StateInactive {
+		t.Fatalf("expected alert to be resolved; got %v", ar.alerts)
+	}
+
+	// on_no_data=keep
+	ar = newRule("", config.PolicyKeep)
+	if err := exec(ar, true, nil); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	fq.reset()
+	tss, err := ar.Exec(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if a, ok := ar.alerts[h]; !ok || a.State != notifier.StateFiring {
+		t.Fatalf("expected alert to keep firing; got %v", ar.alerts)
+	}
+	if len(tss) == 0 {
+		t.Fatalf("expected to get time series for firing alert")
+	}
+
+	// on_no_data=alerting
+	ar = newRule("", config.PolicyAlerting)
+	if err := exec(ar, false, nil); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if a := reasonAlert(ar, alertReasonNoData); a == nil || a.State != notifier.StateFiring {
+		t.Fatalf("expected synthetic alert to fire; got %v", ar.alerts)
+	}
+}
+
 func TestAlertingRule_Exec_Negative(t *testing.T) {
 	fq := &fakeQuerier{}
 	ar := newTestAlertingRule("test", 0)
@@ -2,22 +2,33 @@ package config
 
 import (
 	"crypto/md5"
+	"flag"
 	"fmt"
 	"hash/fnv"
-	"io/ioutil"
-	"path/filepath"
+	"net/url"
 	"sort"
 	"strings"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
 	"gopkg.in/yaml.v2"
 )
 
+var (
+	clusterMode = flag.Bool("clusterMode", false, "Whether to evaluate rule groups for multiple tenants of VictoriaMetrics cluster. "+
+		"If enabled, then -datasource.url, -remoteRead.url and -remoteWrite.url must contain only the hostname without tenant id, "+
+		"while the tenant is taken from the tenant param of the group. See https://docs.victoriametrics.com/vmalert.html#multitenancy")
+	defaultTenant = flag.String("defaultTenant", "0", "Default tenant in the form accountID[:projectID] for groups without tenant param. "+
+		"Is applied only if -clusterMode is enabled")
+)
+
 // Group contains list of Rules grouped into
 // entity with one name and evaluation interval
 type Group struct {
@@ -34,6 +45,19 @@ type Group struct {
 	// Labels is a set of label value pairs, that will be added to every rule.
 	// It has priority over the external labels.
 	Labels map[string]string `yaml:"labels"`
+	// Params is a set of HTTP query params added to every rule's
+	// datasource request within a group. Is compatible only with Prometheus datasources.
+	Params url.Values `yaml:"params"`
+	// Headers is a list of HTTP headers in the form `Name: value`
+	// added to every rule's datasource request within a group.
+	Headers []Header `yaml:"headers,omitempty"`
+	// Tenant is the tenant for rules evaluation in the form `accountID[:projectID]`.
+	// It is added to datasource, remote read and remote write urls.
+	// May be set only if -clusterMode is enabled.
+	Tenant string `yaml:"tenant,omitempty"`
+	// RemoteWrite is an optional destination for recording rules results
+	// and alerts state of the group. It overrides -remoteWrite.url.
+	RemoteWrite *RemoteWrite `yaml:"remote_write,omitempty"`
 	// Checksum stores the hash of yaml definition for this group.
 	// May be used to detect any changes like rules re-ordering etc.
 	Checksum string
@@ -71,6 +95,72 @@ func (g *Group) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// RemoteWrite contains settings for writing recording rules results
+// and alerts state of the group to a remote storage.
+type RemoteWrite struct {
+	// URL of VictoriaMetrics or vminsert, e.g. http://127.0.0.1:8428
+	URL string `yaml:"url"`
+	// HTTPClientConfig contains auth and TLS settings for the URL.
+	HTTPClientConfig promauth.HTTPClientConfig `yaml:",inline"`
+
+	// Catches all undefined fields and must be empty after parsing.
+	XXX map[string]interface{} `yaml:",inline"`
+}
+
+// AuthConfig returns auth config for the remote write URL.
+//
+// Relative paths to files in the config are resolved relative to the current working directory.
+func (rw *RemoteWrite) AuthConfig() (*promauth.Config, error) {
+	return rw.HTTPClientConfig.NewConfig(".")
+}
+
+// Validate checks remote write settings for errors.
+func (rw *RemoteWrite) Validate() error {
+	if rw.URL == "" {
+		return fmt.Errorf("`url` must be set")
+	}
+	u, err := url.Parse(rw.URL)
+	if err != nil {
+		return fmt.Errorf("cannot parse `url` %q: %w", rw.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme in `url` %q; supported values: http, https", rw.URL)
+	}
+	if _, err := rw.AuthConfig(); err != nil {
+		return fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	return checkOverflow(rw.XXX, "remote_write")
+}
+
+// Header is an HTTP header, which must be specified in the form `Name: value`
+type Header struct {
+	Key   string
+	Value string
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (h *Header) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	n := strings.IndexByte(s, ':')
+	if n < 0 {
+		return fmt.Errorf("missing ':' in header %q; expecting `Name: value` format", s)
+	}
+	h.Key = strings.TrimSpace(s[:n])
+	h.Value = strings.TrimSpace(s[n+1:])
+	if h.Key == "" {
+		return fmt.Errorf("missing name in header %q; expecting `Name: value` format", s)
+	}
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (h Header) MarshalYAML() (interface{}, error) {
+	return fmt.Sprintf("%s: %s", h.Key, h.Value), nil
+}
+
 // Validate check for internal Group or Rule configuration errors
 func (g *Group) Validate(validateAnnotations, validateExpressions bool) error {
 	if g.Name == "" {
@@ -79,6 +169,20 @@ func (g *Group) Validate(validateAnnotations, validateExpressions bool) error {
 	if len(g.Rules) == 0 {
 		return fmt.Errorf("group %q can't contain no rules", g.Name)
 	}
+	if g.Tenant != "" {
+		if !*clusterMode {
+			return fmt.Errorf("`tenant` may be set only if -clusterMode is enabled")
+		}
+		if _, err := auth.NewToken(g.Tenant); err != nil {
+			return fmt.Errorf("invalid tenant %q: %w", g.Tenant, err)
+		}
+	}
+
+	if g.RemoteWrite != nil {
+		if err := g.RemoteWrite.Validate(); err != nil {
+			return fmt.Errorf("invalid `remote_write` for group %q: %w", g.Name, err)
+		}
+	}
 
 	uniqueRules := map[uint64]struct{}{}
 	for _, r := range g.Rules {
@@ -119,14 +223,19 @@ func (g *Group) Validate(validateAnnotations, validateExpressions bool) error {
 // Rule describes entity that represent either
 // recording rule or alerting rule.
 type Rule struct {
-	ID          uint64
-	Type        datasource.Type    `yaml:"type,omitempty"`
-	Record      string             `yaml:"record,omitempty"`
-	Alert       string             `yaml:"alert,omitempty"`
-	Expr        string             `yaml:"expr"`
-	For         utils.PromDuration `yaml:"for"`
-	Labels      map[string]string  `yaml:"labels,omitempty"`
-	Annotations map[string]string  `yaml:"annotations,omitempty"`
+	ID            uint64
+	Type          datasource.Type    `yaml:"type,omitempty"`
+	Record        string             `yaml:"record,omitempty"`
+	Alert         string             `yaml:"alert,omitempty"`
+	Expr          string             `yaml:"expr"`
+	For           utils.PromDuration `yaml:"for"`
+	KeepFiringFor utils.PromDuration `yaml:"keep_firing_for"`
+	Labels        map[string]string  `yaml:"labels,omitempty"`
+	Annotations   map[string]string  `yaml:"annotations,omitempty"`
+	Debug         bool               `yaml:"debug,omitempty"`
+	Limit         int                `yaml:"limit,omitempty"`
+	OnError       string             `yaml:"on_error,omitempty"`
+	OnNoData      string             `yaml:"on_no_data,omitempty"`
 
 	// Catches all undefined fields and must be empty after parsing.
 	XXX map[string]interface{} `yaml:",inline"`
@@ -180,24 +289,75 @@ func (r *Rule) Validate() error {
 	if r.Expr == "" {
 		return fmt.Errorf("expression can't be empty")
 	}
+	if r.Record != "" && r.KeepFiringFor.Duration() > 0 {
+		return fmt.Errorf("`keep_firing_for` can be set only for alerting rules")
+	}
+	if r.KeepFiringFor.Duration() < 0 {
+		return fmt.Errorf("`keep_firing_for` can't be negative")
+	}
+	if r.Limit < 0 {
+		return fmt.Errorf("`limit` can't be negative")
+	}
+	if r.Record != "" && (r.OnError != "" || r.OnNoData != "") {
+		return fmt.Errorf("`on_error` and `on_no_data` can be set only for alerting rules")
+	}
+	if err := validatePolicy(r.OnError); err != nil {
+		return fmt.Errorf("invalid `on_error`: %w", err)
+	}
+	if err := validatePolicy(r.OnNoData); err != nil {
+		return fmt.Errorf("invalid `on_no_data`: %w", err)
+	}
 	return checkOverflow(r.XXX, "rule")
 }
 
+// Supported values for `on_error` and `on_no_data` params of alerting rules.
+const (
+	// PolicyKeep keeps the alerts state unchanged.
+	PolicyKeep = "keep"
+	// PolicyAlerting fires a synthetic alert instead of the alerts returned by the expression.
+	PolicyAlerting = "alerting"
+	// PolicyClear resolves the alerts as if the expression returned no series.
+	PolicyClear = "clear"
+)
+
+func validatePolicy(p string) error {
+	switch p {
+	case "", PolicyKeep, PolicyAlerting, PolicyClear:
+		return nil
+	default:
+		return fmt.Errorf("unsupported value %q; supported values: %q, %q, %q", p, PolicyKeep, PolicyAlerting, PolicyClear)
+	}
+}
+
 // Parse parses rule configs from given file patterns
 func Parse(pathPatterns []string, validateAnnotations, validateExpressions bool) ([]Group, error) {
+	if *clusterMode {
+		if _, err := auth.NewToken(*defaultTenant); err != nil {
+			return nil, fmt.Errorf("invalid -defaultTenant=%q: %w", *defaultTenant, err)
+		}
+	}
 	var fp []string
+	files := make(map[string][]byte)
 	for _, pattern := range pathPatterns {
-		matches, err := filepath.Glob(pattern)
+		matches, err := fs.Read(pattern)
 		if err != nil {
 			return nil, fmt.Errorf("error reading file pattern %s: %w", pattern, err)
 		}
-		fp = append(fp, matches...)
+		var paths []string
+		for path, data := range matches {
+			if _, ok := files[path]; !ok {
+				paths = append(paths, path)
+			}
+			files[path] = data
+		}
+		sort.Strings(paths)
+		fp = append(fp, paths...)
 	}
 	errGroup := new(utils.ErrGroup)
 	var groups []Group
 	for _, file := range fp {
 		uniqueGroups := map[string]struct{}{}
-		gr, err := parseFile(file)
+		gr, err := parse(files[file])
 		if err != nil {
 			errGroup.Add(fmt.Errorf("failed to parse file %q: %w", file, err))
 			continue
@@ -213,6 +373,9 @@ func Parse(pathPatterns []string, validateAnnotations, validateExpressions bool)
 			}
 			uniqueGroups[g.Name] = struct{}{}
 			g.File = file
+			if *clusterMode && g.Tenant == "" {
+				g.Tenant = *defaultTenant
+			}
 			groups = append(groups, g)
 		}
 	}
@@ -225,19 +388,14 @@ func Parse(pathPatterns []string, validateAnnotations, validateExpressions bool)
 	return groups, nil
 }
 
-func parseFile(path string) ([]Group, error) {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("error reading alert rule file: %w", err)
-	}
+func parse(data []byte) ([]Group, error) {
 	data = envtemplate.Replace(data)
 	g := struct {
 		Groups []Group `yaml:"groups"`
 		// Catches all undefined fields and must be empty after parsing.
 		XXX map[string]interface{} `yaml:",inline"`
 	}{}
-	err = yaml.Unmarshal(data, &g)
-	if err != nil {
+	if err := yaml.Unmarshal(data, &g); err != nil {
 		return nil, err
 	}
 	return g.Groups, checkOverflow(g.XXX, "config")
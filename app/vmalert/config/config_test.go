@@ -1,6 +1,9 @@
 package config
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"strings"
@@ -10,6 +13,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
 	"gopkg.in/yaml.v2"
 )
 
@@ -25,6 +29,31 @@ func TestParseGood(t *testing.T) {
 	}
 }
 
+func TestParseFromURL(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/rules1-good.rules")
+	if err != nil {
+		t.Fatalf("cannot read file: %s", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	u := srv.URL + "/rules1-good.rules"
+	groups, err := Parse([]string{u}, true, true)
+	if err != nil {
+		t.Fatalf("error parsing URL %s", err)
+	}
+	if len(groups) == 0 {
+		t.Fatalf("expecting at least one group")
+	}
+	for _, g := range groups {
+		if g.File != u {
+			t.Fatalf("unexpected file for group %q; got %q; want %q", g.Name, g.File, u)
+		}
+	}
+}
+
 func TestParseBad(t *testing.T) {
 	testCases := []struct {
 		path   []string
@@ -58,6 +87,10 @@ func TestParseBad(t *testing.T) {
 			[]string{"testdata/rules1-bad.rules"},
 			"bad graphite expr",
 		},
+		{
+			[]string{"testdata/dir/rules6-bad.rules"},
+			"missing ':' in header",
+		},
 	}
 	for _, tc := range testCases {
 		_, err := Parse(tc.path, true, true)
@@ -81,6 +114,28 @@ func TestRule_Validate(t *testing.T) {
 	if err := (&Rule{Alert: "alert", Expr: "test>0"}).Validate(); err != nil {
 		t.Errorf("expected valid rule; got %s", err)
 	}
+	keepFiringFor := utils.NewPromDuration(time.Minute)
+	if err := (&Rule{Alert: "alert", Expr: "test>0", KeepFiringFor: keepFiringFor}).Validate(); err != nil {
+		t.Errorf("expected valid rule; got %s", err)
+	}
+	if err := (&Rule{Record: "record", Expr: "test", KeepFiringFor: keepFiringFor}).Validate(); err == nil {
+		t.Errorf("expected keep_firing_for error for recording rule")
+	}
+	if err := (&Rule{Record: "record", Expr: "test", Limit: 10}).Validate(); err != nil {
+		t.Errorf("expected valid rule; got %s", err)
+	}
+	if err := (&Rule{Alert: "alert", Expr: "test>0", Limit: -1}).Validate(); err == nil {
+		t.Errorf("expected negative limit error")
+	}
+	if err := (&Rule{Alert: "alert", Expr: "test>0", OnError: PolicyAlerting, OnNoData: PolicyKeep}).Validate(); err != nil {
+		t.Errorf("expected valid rule; got %s", err)
+	}
+	if err := (&Rule{Alert: "alert", Expr: "test>0", OnNoData: "foo"}).Validate(); err == nil {
+		t.Errorf("expected unsupported on_no_data error")
+	}
+	if err := (&Rule{Record: "record", Expr: "test", OnError: PolicyClear}).Validate(); err == nil {
+		t.Errorf("expected on_error error for recording rule")
+	}
 }
 
 func TestGroup_Validate(t *testing.T) {
@@ -304,6 +359,94 @@ func TestGroup_Validate(t *testing.T) {
 	}
 }
 
+func TestGroup_ValidateTenant(t *testing.T) {
+	defer func() { *clusterMode = false }()
+
+	f := func(tenant string, cluster bool, expErr string) {
+		t.Helper()
+		*clusterMode = cluster
+		g := &Group{
+			Name:   "test",
+			Tenant: tenant,
+			Rules:  []Rule{{Record: "record", Expr: "up"}},
+		}
+		err := g.Validate(false, false)
+		if expErr == "" {
+			if err != nil {
+				t.Fatalf("unexpected error for tenant %q: %s", tenant, err)
+			}
+			return
+		}
+		if err == nil || !strings.Contains(err.Error(), expErr) {
+			t.Fatalf("expecting error containing %q for tenant %q; got %v", expErr, tenant, err)
+		}
+	}
+	f("", false, "")
+	f("", true, "")
+	f("123", true, "")
+	f("123:456", true, "")
+	f("123", false, "-clusterMode")
+	f("foo", true, "invalid tenant")
+	f("1:2:3", true, "invalid tenant")
+}
+
+func TestGroup_ValidateRemoteWrite(t *testing.T) {
+	f := func(rw *RemoteWrite, expErr string) {
+		t.Helper()
+		g := &Group{
+			Name:        "test",
+			RemoteWrite: rw,
+			Rules:       []Rule{{Record: "record", Expr: "up"}},
+		}
+		err := g.Validate(false, false)
+		if expErr == "" {
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			return
+		}
+		if err == nil || !strings.Contains(err.Error(), expErr) {
+			t.Fatalf("expecting error containing %q; got %v", expErr, err)
+		}
+	}
+	f(nil, "")
+	f(&RemoteWrite{URL: "http://localhost:8428"}, "")
+	f(&RemoteWrite{URL: "https://localhost:8428", HTTPClientConfig: promauth.HTTPClientConfig{BearerToken: "foo"}}, "")
+	f(&RemoteWrite{}, "`url` must be set")
+	f(&RemoteWrite{URL: "localhost:8428"}, "unsupported scheme")
+	f(&RemoteWrite{URL: "http://localhost:8428", HTTPClientConfig: promauth.HTTPClientConfig{
+		BearerToken:     "foo",
+		BearerTokenFile: "bar",
+	}}, "cannot parse auth config")
+	f(&RemoteWrite{URL: "http://localhost:8428", XXX: map[string]interface{}{"foo": "bar"}}, "unknown fields")
+}
+
+func TestParseDefaultTenant(t *testing.T) {
+	defer func() { *clusterMode = false }()
+
+	*clusterMode = true
+	groups, err := Parse([]string{"testdata/rules1-good.rules"}, false, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, g := range groups {
+		if g.Tenant != *defaultTenant {
+			t.Fatalf("expecting default tenant %q for group %q; got %q", *defaultTenant, g.Name, g.Tenant)
+		}
+	}
+
+	*clusterMode = false
+	groups, err = Parse([]string{"testdata/rules1-good.rules"}, false, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, g := range groups {
+		if g.Tenant != "" {
+			t.Fatalf("expecting empty tenant for group %q; got %q", g.Name, g.Tenant)
+		}
+	}
+}
+
 func TestHashRule(t *testing.T) {
 	testCases := []struct {
 		a, b  Rule
@@ -475,6 +618,72 @@ rules:
 `, `
 name: TestGroup
 concurrency: 16
+rules:
+  - alert: ExampleAlertWithFor
+    expr: sum by(job) (up == 1)
+`)
+	})
+	t.Run("`params` change", func(t *testing.T) {
+		f(t, `
+name: TestGroup
+params:
+  nocache: ["1"]
+rules:
+  - alert: ExampleAlertWithFor
+    expr: sum by(job) (up == 1)
+`, `
+name: TestGroup
+params:
+  nocache: ["0"]
+rules:
+  - alert: ExampleAlertWithFor
+    expr: sum by(job) (up == 1)
+`)
+	})
+	t.Run("`headers` change", func(t *testing.T) {
+		f(t, `
+name: TestGroup
+headers:
+  - "TenantID: foo"
+rules:
+  - alert: ExampleAlertWithFor
+    expr: sum by(job) (up == 1)
+`, `
+name: TestGroup
+headers:
+  - "TenantID: bar"
+rules:
+  - alert: ExampleAlertWithFor
+    expr: sum by(job) (up == 1)
+`)
+	})
+	t.Run("`remote_write` change", func(t *testing.T) {
+		f(t, `
+name: TestGroup
+remote_write:
+  url: http://localhost:8428
+rules:
+  - record: job:up:sum
+    expr: sum(up) by (job)
+`, `
+name: TestGroup
+remote_write:
+  url: http://localhost:8429
+rules:
+  - record: job:up:sum
+    expr: sum(up) by (job)
+`)
+	})
+	t.Run("`tenant` change", func(t *testing.T) {
+		f(t, `
+name: TestGroup
+tenant: "123"
+rules:
+  - alert: ExampleAlertWithFor
+    expr: sum by(job) (up == 1)
+`, `
+name: TestGroup
+tenant: "123:1"
 rules:
   - alert: ExampleAlertWithFor
     expr: sum by(job) (up == 1)
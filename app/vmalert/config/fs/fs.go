@@ -0,0 +1,133 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FS represents a source of rule files.
+// It may be a local filesystem or a remote storage.
+type FS interface {
+	// Init initializes FS.
+	Init() error
+
+	// String must return human-readable representation of FS.
+	String() string
+
+	// Read returns the contents of all the files matched by FS.
+	// The returned map is keyed by file path.
+	Read() (map[string][]byte, error)
+}
+
+var (
+	fsRegistryMu sync.Mutex
+	fsRegistry   = make(map[string]FS)
+)
+
+// Read returns the contents of all the files matching the given path.
+//
+// The path may be a local file path or glob pattern, or a URL with one of
+// the following schemes: http://, https://, s3://, gs:// or gcs://.
+// FS for the given path is initialized on the first call and is re-used
+// for subsequent calls, so Read may be called periodically in order to re-fetch files.
+func Read(path string) (map[string][]byte, error) {
+	fs, err := getFS(path)
+	if err != nil {
+		return nil, err
+	}
+	files, err := fs.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read files from %s: %w", fs, err)
+	}
+	return files, nil
+}
+
+func getFS(path string) (FS, error) {
+	fsRegistryMu.Lock()
+	defer fsRegistryMu.Unlock()
+
+	if fs, ok := fsRegistry[path]; ok {
+		return fs, nil
+	}
+	fs, err := newFS(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.Init(); err != nil {
+		return nil, fmt.Errorf("cannot initialize %s: %w", fs, err)
+	}
+	fsRegistry[path] = fs
+	return fs, nil
+}
+
+func newFS(path string) (FS, error) {
+	n := strings.Index(path, "://")
+	if n < 0 {
+		return &Local{Pattern: path}, nil
+	}
+	scheme, p := path[:n], path[n+len("://"):]
+	switch scheme {
+	case "file":
+		return &Local{Pattern: p}, nil
+	case "http", "https":
+		return &HTTP{URL: path}, nil
+	case "s3":
+		bucket, pattern, err := splitBucketPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", path, err)
+		}
+		return &S3{
+			CredsFilePath:  *credsFilePath,
+			ConfigFilePath: *configFilePath,
+			CustomEndpoint: *customS3Endpoint,
+			ProfileName:    *configProfile,
+			Bucket:         bucket,
+			Pattern:        pattern,
+		}, nil
+	case "gs", "gcs":
+		bucket, pattern, err := splitBucketPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", path, err)
+		}
+		return &GCS{
+			CredsFilePath: *credsFilePath,
+			Bucket:        bucket,
+			Pattern:       pattern,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q in path %q; supported schemes: file, http, https, s3, gs, gcs", scheme, path)
+	}
+}
+
+// splitBucketPath splits p in the form `bucket/path/to/object` into bucket and object path.
+func splitBucketPath(p string) (string, string, error) {
+	n := strings.Index(p, "/")
+	if n < 0 {
+		return "", "", fmt.Errorf("missing object path; want `bucket/path/to/object`")
+	}
+	bucket, object := p[:n], strings.TrimLeft(p[n+1:], "/")
+	if len(bucket) == 0 {
+		return "", "", fmt.Errorf("bucket name cannot be empty")
+	}
+	if len(object) == 0 {
+		return "", "", fmt.Errorf("object path cannot be empty")
+	}
+	return bucket, object, nil
+}
+
+// hasMeta reports whether pattern contains any of the magic characters
+// recognized by path.Match.
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// patternPrefix returns the longest prefix of pattern without magic characters.
+// It is used for listing objects in remote buckets.
+func patternPrefix(pattern string) string {
+	n := strings.IndexAny(pattern, `*?[\`)
+	if n < 0 {
+		return pattern
+	}
+	return pattern[:n]
+}
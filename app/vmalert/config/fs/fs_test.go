@@ -0,0 +1,121 @@
+package fs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewFS(t *testing.T) {
+	f := func(path, expected string) {
+		t.Helper()
+		fs, err := newFS(path)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", path, err)
+		}
+		if got := fs.String(); got != expected {
+			t.Fatalf("unexpected FS for %q; got %s; want %s", path, got, expected)
+		}
+	}
+	f("rules/*.yaml", `Local{pattern: "rules/*.yaml"}`)
+	f("file:///etc/rules/*.yaml", `Local{pattern: "/etc/rules/*.yaml"}`)
+	f("http://foo.bar/rules.yaml", `HTTP{url: "http://foo.bar/rules.yaml"}`)
+	f("https://foo.bar/rules.yaml", `HTTP{url: "https://foo.bar/rules.yaml"}`)
+	f("s3://bucket/rules/alerts.yaml", `S3{bucket: "bucket", pattern: "rules/alerts.yaml"}`)
+	f("s3://bucket/rules/*.yaml", `S3{bucket: "bucket", pattern: "rules/*.yaml"}`)
+	f("gs://bucket/rules/*.yaml", `GCS{bucket: "bucket", pattern: "rules/*.yaml"}`)
+	f("gcs://bucket//rules.yaml", `GCS{bucket: "bucket", pattern: "rules.yaml"}`)
+}
+
+func TestNewFSFailure(t *testing.T) {
+	f := func(path string) {
+		t.Helper()
+		if _, err := newFS(path); err == nil {
+			t.Fatalf("expecting non-nil error for %q", path)
+		}
+	}
+	f("ftp://foo.bar/rules.yaml")
+	f("s3://bucket")
+	f("s3://bucket/")
+	f("s3:///rules.yaml")
+	f("gs://bucket")
+}
+
+func TestPatternPrefix(t *testing.T) {
+	f := func(pattern, expected string, expectedMeta bool) {
+		t.Helper()
+		if got := patternPrefix(pattern); got != expected {
+			t.Fatalf("unexpected prefix for %q; got %q; want %q", pattern, got, expected)
+		}
+		if got := hasMeta(pattern); got != expectedMeta {
+			t.Fatalf("unexpected hasMeta for %q; got %v; want %v", pattern, got, expectedMeta)
+		}
+	}
+	f("rules/alerts.yaml", "rules/alerts.yaml", false)
+	f("rules/*.yaml", "rules/", true)
+	f("rules/team-?/alerts.yaml", "rules/team-", true)
+	f("*", "", true)
+}
+
+func TestReadLocal(t *testing.T) {
+	files, err := Read("testdata/*.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expecting 2 files; got %d", len(files))
+	}
+	if string(files["testdata/a.yaml"]) != "a\n" {
+		t.Fatalf("unexpected content of testdata/a.yaml: %q", files["testdata/a.yaml"])
+	}
+
+	files, err = Read("testdata/missing/*.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("expecting no files; got %d", len(files))
+	}
+
+	if _, err := Read("testdata/[.yaml"); err == nil {
+		t.Fatalf("expecting error for invalid pattern")
+	}
+}
+
+func TestReadHTTP(t *testing.T) {
+	var content atomic.Value
+	content.Store("groups: []")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rules.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, content.Load())
+	}))
+	defer srv.Close()
+
+	url := srv.URL + "/rules.yaml"
+	files, err := Read(url)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := string(files[url]); got != "groups: []" {
+		t.Fatalf("unexpected content; got %q", got)
+	}
+
+	// subsequent reads must re-fetch the file
+	content.Store("groups: [{name: foo}]")
+	files, err = Read(url)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := string(files[url]); got != "groups: [{name: foo}]" {
+		t.Fatalf("unexpected content after update; got %q", got)
+	}
+
+	if _, err := Read(srv.URL + "/missing.yaml"); err == nil {
+		t.Fatalf("expecting error for missing file")
+	}
+}
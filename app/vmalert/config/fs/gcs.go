@@ -0,0 +1,109 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCS represents files stored in Google Cloud Storage bucket.
+type GCS struct {
+	// Path to GCP credentials file.
+	//
+	// Default credentials are used if empty.
+	CredsFilePath string
+
+	// GCS bucket to read from.
+	Bucket string
+
+	// Pattern is a path or glob pattern for objects in the bucket.
+	Pattern string
+
+	bkt *storage.BucketHandle
+}
+
+// Init initializes GCS client.
+func (fs *GCS) Init() error {
+	var opts []option.ClientOption
+	if len(fs.CredsFilePath) > 0 {
+		opts = append(opts, option.WithCredentialsFile(fs.CredsFilePath))
+	}
+	client, err := storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		return fmt.Errorf("cannot create gcs client: %w", err)
+	}
+	fs.bkt = client.Bucket(fs.Bucket)
+	return nil
+}
+
+// String implements FS interface.
+func (fs *GCS) String() string {
+	return fmt.Sprintf("GCS{bucket: %q, pattern: %q}", fs.Bucket, fs.Pattern)
+}
+
+// Read implements FS interface.
+func (fs *GCS) Read() (map[string][]byte, error) {
+	ctx := context.Background()
+	names := []string{fs.Pattern}
+	if hasMeta(fs.Pattern) {
+		var err error
+		names, err = fs.list(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := make(map[string][]byte, len(names))
+	for _, name := range names {
+		data, err := fs.get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		result[fmt.Sprintf("gs://%s/%s", fs.Bucket, name)] = data
+	}
+	return result, nil
+}
+
+func (fs *GCS) list(ctx context.Context) ([]string, error) {
+	q := &storage.Query{
+		Prefix: patternPrefix(fs.Pattern),
+	}
+	it := fs.bkt.Objects(ctx, q)
+	var names []string
+	for {
+		attr, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot list objects in bucket %q: %w", fs.Bucket, err)
+		}
+		ok, err := path.Match(fs.Pattern, attr.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", fs.Pattern, err)
+		}
+		if ok {
+			names = append(names, attr.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (fs *GCS) get(ctx context.Context, name string) ([]byte, error) {
+	r, err := fs.bkt.Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %q in bucket %q: %w", name, fs.Bucket, err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q from bucket %q: %w", name, fs.Bucket, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,51 @@
+package fs
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var httpTimeout = flag.Duration("rule.httpTimeout", 30*time.Second, "Timeout for fetching rule files via http:// or https:// URLs passed to -rule")
+
+// HTTP represents a single file available via http(s) URL.
+type HTTP struct {
+	// URL is the url of the file to read.
+	URL string
+
+	c *http.Client
+}
+
+// Init verifies the URL and initializes the http client.
+func (h *HTTP) Init() error {
+	if _, err := url.Parse(h.URL); err != nil {
+		return fmt.Errorf("failed to parse url %q: %w", h.URL, err)
+	}
+	h.c = &http.Client{Timeout: *httpTimeout}
+	return nil
+}
+
+// String implements FS interface.
+func (h *HTTP) String() string {
+	return fmt.Sprintf("HTTP{url: %q}", h.URL)
+}
+
+// Read implements FS interface.
+func (h *HTTP) Read() (map[string][]byte, error) {
+	resp, err := h.c.Get(h.URL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch %q: %w", h.URL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response from %q: %w", h.URL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code when fetching %q: %d; response body: %q", h.URL, resp.StatusCode, data)
+	}
+	return map[string][]byte{h.URL: data}, nil
+}
@@ -0,0 +1,43 @@
+package fs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Local represents a local filesystem.
+type Local struct {
+	// Pattern is a path or glob pattern for files to read.
+	Pattern string
+}
+
+// Init verifies the pattern.
+func (l *Local) Init() error {
+	if _, err := filepath.Glob(l.Pattern); err != nil {
+		return fmt.Errorf("invalid file pattern %q: %w", l.Pattern, err)
+	}
+	return nil
+}
+
+// String implements FS interface.
+func (l *Local) String() string {
+	return fmt.Sprintf("Local{pattern: %q}", l.Pattern)
+}
+
+// Read implements FS interface.
+func (l *Local) Read() (map[string][]byte, error) {
+	matches, err := filepath.Glob(l.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file pattern %q: %w", l.Pattern, err)
+	}
+	result := make(map[string][]byte, len(matches))
+	for _, path := range matches {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %q: %w", path, err)
+		}
+		result[path] = data
+	}
+	return result, nil
+}
@@ -0,0 +1,157 @@
+package fs
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+var (
+	credsFilePath = flag.String("rule.credsFilePath", "", "Path to file with GCS or S3 credentials for reading rule files from s3:// or gs:// paths. "+
+		"Credentials are loaded from default locations if not set. See https://cloud.google.com/iam/docs/creating-managing-service-account-keys "+
+		"and https://docs.aws.amazon.com/general/latest/gr/aws-security-credentials.html")
+	configFilePath = flag.String("rule.configFilePath", "", "Path to file with S3 configs for reading rule files from s3:// paths. "+
+		"Configs are loaded from default location if not set. See https://docs.aws.amazon.com/general/latest/gr/aws-security-credentials.html")
+	configProfile    = flag.String("rule.configProfile", "", "Profile name for S3 configs. If no set, the value of the environment variable will be loaded (AWS_PROFILE or AWS_DEFAULT_PROFILE), or if both not set, DefaultSharedConfigProfile is used")
+	customS3Endpoint = flag.String("rule.customS3Endpoint", "", "Custom S3 endpoint for reading rule files from s3:// paths. Useful for S3-compatible storages such as MinIO. Default S3 endpoint is used if not set")
+)
+
+// S3 represents files stored in S3-compatible bucket.
+type S3 struct {
+	// Path to S3 credentials file.
+	CredsFilePath string
+
+	// Path to S3 configs file.
+	ConfigFilePath string
+
+	// Custom S3 endpoint.
+	CustomEndpoint string
+
+	// The name of S3 config profile to use.
+	ProfileName string
+
+	// S3 bucket to read from.
+	Bucket string
+
+	// Pattern is a path or glob pattern for objects in the bucket.
+	Pattern string
+
+	s3 *s3.S3
+}
+
+// Init initializes S3 session.
+func (fs *S3) Init() error {
+	opts := session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Profile:           fs.ProfileName,
+	}
+	if len(fs.CredsFilePath) > 0 {
+		opts.SharedConfigFiles = []string{
+			fs.ConfigFilePath,
+			fs.CredsFilePath,
+		}
+	}
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return fmt.Errorf("cannot create S3 session: %w", err)
+	}
+	if len(fs.CustomEndpoint) > 0 {
+		if sess.Config.Region == nil || *sess.Config.Region == "" {
+			sess.Config.WithRegion("us-east-1")
+		}
+		sess.Config.WithEndpoint(fs.CustomEndpoint)
+		// Disable prefixing endpoint with bucket name
+		sess.Config.WithS3ForcePathStyle(true)
+	} else {
+		region, err := s3manager.GetBucketRegion(context.Background(), sess, fs.Bucket, "us-west-2")
+		if err != nil {
+			return fmt.Errorf("cannot determine region for bucket %q: %w", fs.Bucket, err)
+		}
+		sess.Config.WithRegion(region)
+		logger.Infof("bucket %q is stored at region %q; switching to this region", fs.Bucket, region)
+	}
+	fs.s3 = s3.New(sess)
+	return nil
+}
+
+// String implements FS interface.
+func (fs *S3) String() string {
+	return fmt.Sprintf("S3{bucket: %q, pattern: %q}", fs.Bucket, fs.Pattern)
+}
+
+// Read implements FS interface.
+func (fs *S3) Read() (map[string][]byte, error) {
+	keys := []string{fs.Pattern}
+	if hasMeta(fs.Pattern) {
+		var err error
+		keys, err = fs.list()
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		data, err := fs.get(key)
+		if err != nil {
+			return nil, err
+		}
+		result[fmt.Sprintf("s3://%s/%s", fs.Bucket, key)] = data
+	}
+	return result, nil
+}
+
+func (fs *S3) list() ([]string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(fs.Bucket),
+		Prefix: aws.String(patternPrefix(fs.Pattern)),
+	}
+	var keys []string
+	var errOuter error
+	err := fs.s3.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, o := range page.Contents {
+			ok, err := path.Match(fs.Pattern, *o.Key)
+			if err != nil {
+				errOuter = fmt.Errorf("invalid pattern %q: %w", fs.Pattern, err)
+				return false
+			}
+			if ok {
+				keys = append(keys, *o.Key)
+			}
+		}
+		return !lastPage
+	})
+	if errOuter != nil && err == nil {
+		err = errOuter
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot list objects in bucket %q: %w", fs.Bucket, err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (fs *S3) get(key string) ([]byte, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(fs.Bucket),
+		Key:    aws.String(key),
+	}
+	o, err := fs.s3.GetObject(input)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %q in bucket %q: %w", key, fs.Bucket, err)
+	}
+	defer func() { _ = o.Body.Close() }()
+	data, err := ioutil.ReadAll(o.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q from bucket %q: %w", key, fs.Bucket, err)
+	}
+	return data, nil
+}
@@ -2,6 +2,7 @@ package datasource
 
 import (
 	"context"
+	"net/url"
 	"time"
 )
 
@@ -22,6 +23,16 @@ type QuerierParams struct {
 	EvaluationInterval time.Duration
 	// see https://docs.victoriametrics.com/#prometheus-querying-api-enhancements
 	ExtraLabels map[string]string
+	// QueryParams contains extra HTTP query params added to Prometheus requests
+	QueryParams url.Values
+	// Headers contains extra HTTP headers added to every request
+	Headers map[string]string
+	// Debug enables logging of every request
+	Debug bool
+	// Tenant is the VictoriaMetrics cluster tenant in the form `accountID[:projectID]`.
+	// If set, the `/select/<tenant>` path is added to the datasource url
+	// according to https://docs.victoriametrics.com/Cluster-VictoriaMetrics.html#url-format
+	Tenant string
 }
 
 // Metric is the basic entity which should be return by datasource
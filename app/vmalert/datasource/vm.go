@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
 )
 
@@ -24,6 +25,13 @@ type VMStorage struct {
 	evaluationInterval time.Duration
 	extraLabels        []string
 	extraParams        []Param
+	extraHeaders       []keyValue
+	debug              bool
+}
+
+type keyValue struct {
+	key   string
+	value string
 }
 
 // Clone makes clone of VMStorage, shares http client.
@@ -36,6 +44,9 @@ func (s *VMStorage) Clone() *VMStorage {
 		queryStep:        s.queryStep,
 		appendTypePrefix: s.appendTypePrefix,
 		dataSourceType:   s.dataSourceType,
+		// copy slices, so ApplyParams on the clone doesn't modify the original
+		extraParams:  append([]Param{}, s.extraParams...),
+		extraHeaders: append([]keyValue{}, s.extraHeaders...),
 	}
 }
 
@@ -45,9 +56,23 @@ func (s *VMStorage) ApplyParams(params QuerierParams) *VMStorage {
 		s.dataSourceType = *params.DataSourceType
 	}
 	s.evaluationInterval = params.EvaluationInterval
+	s.debug = params.Debug
 	for k, v := range params.ExtraLabels {
 		s.extraLabels = append(s.extraLabels, fmt.Sprintf("%s=%s", k, v))
 	}
+	for k, vl := range params.QueryParams {
+		for _, v := range vl {
+			s.extraParams = append(s.extraParams, Param{Key: k, Value: v})
+		}
+	}
+	for k, v := range params.Headers {
+		s.extraHeaders = append(s.extraHeaders, keyValue{key: k, value: v})
+	}
+	if params.Tenant != "" {
+		s.datasourceURL = fmt.Sprintf("%s/select/%s", s.datasourceURL, params.Tenant)
+		// vmselect requires the type prefix after the tenant
+		s.appendTypePrefix = true
+	}
 	return s
 }
 
@@ -130,6 +155,9 @@ func (s *VMStorage) QueryRange(ctx context.Context, query string, start, end tim
 }
 
 func (s *VMStorage) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if s.debug {
+		logger.Infof("DEBUG datasource request: executing %s request with params %q", req.Method, req.URL.RawQuery)
+	}
 	resp, err := s.c.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("error getting response from %s: %w", req.URL, err)
@@ -153,5 +181,8 @@ func (s *VMStorage) newRequestPOST() (*http.Request, error) {
 			req.Header.Set("Authorization", auth)
 		}
 	}
+	for _, h := range s.extraHeaders {
+		req.Header.Set(h.key, h.value)
+	}
 	return req, nil
 }
@@ -11,25 +11,39 @@ import (
 type graphiteResponse []graphiteResponseTarget
 
 type graphiteResponseTarget struct {
-	Target     string            `json:"target"`
-	Tags       map[string]string `json:"tags"`
-	DataPoints [][2]float64      `json:"datapoints"`
+	Target string            `json:"target"`
+	Tags   map[string]string `json:"tags"`
+	// DataPoints contains [value, timestamp] pairs.
+	// Value may be null if there is no data for the given timestamp.
+	DataPoints [][2]*float64 `json:"datapoints"`
 }
 
 func (r graphiteResponse) metrics() []Metric {
 	var ms []Metric
 	for _, res := range r {
-		if len(res.DataPoints) < 1 {
+		// add only the last non-null value to the result,
+		// since Graphite usually returns null for the current incomplete interval.
+		var last *[2]*float64
+		for i := len(res.DataPoints) - 1; i >= 0; i-- {
+			if res.DataPoints[i][0] != nil && res.DataPoints[i][1] != nil {
+				last = &res.DataPoints[i]
+				break
+			}
+		}
+		if last == nil {
 			continue
 		}
 		var m Metric
-		// add only last value to the result.
-		last := res.DataPoints[len(res.DataPoints)-1]
-		m.Values = append(m.Values, last[0])
-		m.Timestamps = append(m.Timestamps, int64(last[1]))
+		m.Values = append(m.Values, *last[0])
+		m.Timestamps = append(m.Timestamps, int64(*last[1]))
 		for k, v := range res.Tags {
 			m.AddLabel(k, v)
 		}
+		if _, ok := res.Tags["name"]; !ok {
+			// Graphite versions without tags support don't return the `name` tag,
+			// so use the target in order to distinguish the returned series.
+			m.AddLabel("name", res.Target)
+		}
 		ms = append(ms, m)
 	}
 	return ms
@@ -0,0 +1,45 @@
+package datasource
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGraphiteResponseMetrics(t *testing.T) {
+	f := func(data string, expected []Metric) {
+		t.Helper()
+		var r graphiteResponse
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			t.Fatalf("cannot unmarshal response: %s", err)
+		}
+		got := r.metrics()
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("unexpected metrics;\ngot\n%+v\nwant\n%+v", got, expected)
+		}
+	}
+
+	// the last value is returned
+	f(`[{"target":"foo","tags":{"name":"foo"},"datapoints":[[1,1611758343],[2,1611758373]]}]`, []Metric{{
+		Labels:     []Label{{Name: "name", Value: "foo"}},
+		Timestamps: []int64{1611758373},
+		Values:     []float64{2},
+	}})
+
+	// trailing null values are skipped
+	f(`[{"target":"foo","tags":{"name":"foo"},"datapoints":[[0,1611758313],[1,1611758343],[null,1611758373]]}]`, []Metric{{
+		Labels:     []Label{{Name: "name", Value: "foo"}},
+		Timestamps: []int64{1611758343},
+		Values:     []float64{1},
+	}})
+
+	// targets with null values only are skipped
+	f(`[{"target":"foo","tags":{"name":"foo"},"datapoints":[[null,1611758343],[null,1611758373]]},{"target":"bar","datapoints":[]}]`, nil)
+
+	// target is used as name if tags are missing
+	f(`[{"target":"servers.host1.cpu","datapoints":[[5,1611758343]]}]`, []Metric{{
+		Labels:     []Label{{Name: "name", Value: "servers.host1.cpu"}},
+		Timestamps: []int64{1611758343},
+		Values:     []float64{5},
+	}})
+}
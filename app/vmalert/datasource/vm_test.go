@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
@@ -447,6 +448,46 @@ func TestRequestParams(t *testing.T) {
 				checkEqualString(t, exp, r.URL.RawQuery)
 			},
 		},
+		{
+			"group params and headers",
+			false,
+			(&VMStorage{
+				authCfg:     authCfg,
+				extraParams: []Param{{Key: "nocache", Value: "1"}},
+			}).BuildWithParams(QuerierParams{
+				QueryParams: url.Values{"extra_label": {"env=prod"}, "denyPartialResponse": {"true"}},
+				Headers:     map[string]string{"TenantID": "foo", "Authorization": "Bearer token"},
+			}).(*VMStorage),
+			func(t *testing.T, r *http.Request) {
+				exp := fmt.Sprintf("denyPartialResponse=true&extra_label=env%%3Dprod&nocache=1&query=%s&time=%d", query, timestamp.Unix())
+				checkEqualString(t, exp, r.URL.RawQuery)
+				checkEqualString(t, "foo", r.Header.Get("TenantID"))
+				// group headers have priority over auth settings
+				checkEqualString(t, "Bearer token", r.Header.Get("Authorization"))
+			},
+		},
+		{
+			"prometheus tenant",
+			false,
+			(&VMStorage{
+				datasourceURL:  "http://vmselect:8481",
+				dataSourceType: NewPrometheusType(),
+			}).BuildWithParams(QuerierParams{Tenant: "123:456"}).(*VMStorage),
+			func(t *testing.T, r *http.Request) {
+				checkEqualString(t, "/select/123:456"+prometheusPrefix+prometheusInstantPath, r.URL.Path)
+			},
+		},
+		{
+			"graphite tenant",
+			false,
+			(&VMStorage{
+				datasourceURL:  "http://vmselect:8481",
+				dataSourceType: NewGraphiteType(),
+			}).BuildWithParams(QuerierParams{Tenant: "7"}).(*VMStorage),
+			func(t *testing.T, r *http.Request) {
+				checkEqualString(t, "/select/7"+graphitePrefix+graphitePath, r.URL.Path)
+			},
+		},
 	}
 
 	for _, tc := range testCases {
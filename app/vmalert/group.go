@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"hash/fnv"
+	"net/url"
+	"reflect"
 	"sync"
 	"time"
 
@@ -29,6 +31,21 @@ type Group struct {
 
 	ExtraFilterLabels map[string]string
 	Labels            map[string]string
+	Params            url.Values
+	Headers           map[string]string
+	Tenant            string
+	// RemoteWrite is an optional remote write destination of the group,
+	// which overrides -remoteWrite.url.
+	RemoteWrite *config.RemoteWrite
+
+	// rw is the remote write client for RemoteWrite.
+	// It is created by manager before the group start or update and is closed on group stop.
+	rw *remotewrite.Client
+
+	// lastEvaluation is the time when the last evaluation of the group started
+	lastEvaluation time.Time
+	// evaluationDuration is the duration of the last evaluation of the group
+	evaluationDuration time.Duration
 
 	doneCh     chan struct{}
 	finishedCh chan struct{}
@@ -79,6 +96,9 @@ func newGroup(cfg config.Group, qb datasource.QuerierBuilder, defaultInterval ti
 		Checksum:          cfg.Checksum,
 		ExtraFilterLabels: cfg.ExtraFilterLabels,
 		Labels:            cfg.Labels,
+		Params:            cfg.Params,
+		Tenant:            cfg.Tenant,
+		RemoteWrite:       cfg.RemoteWrite,
 
 		doneCh:     make(chan struct{}),
 		finishedCh: make(chan struct{}),
@@ -91,6 +111,12 @@ func newGroup(cfg config.Group, qb datasource.QuerierBuilder, defaultInterval ti
 	if g.Concurrency < 1 {
 		g.Concurrency = 1
 	}
+	if len(cfg.Headers) > 0 {
+		g.Headers = make(map[string]string, len(cfg.Headers))
+		for _, h := range cfg.Headers {
+			g.Headers[h.Key] = h.Value
+		}
+	}
 	rules := make([]Rule, len(cfg.Rules))
 	for i, r := range cfg.Rules {
 		var extraLabels map[string]string
@@ -144,7 +170,7 @@ func (g *Group) Restore(ctx context.Context, qb datasource.QuerierBuilder, lookb
 		}
 		// ignore g.ExtraFilterLabels on purpose, so it
 		// won't affect the restore procedure.
-		q := qb.BuildWithParams(datasource.QuerierParams{})
+		q := qb.BuildWithParams(datasource.QuerierParams{Tenant: g.Tenant})
 		if err := rr.Restore(ctx, q, lookback, labels); err != nil {
 			return fmt.Errorf("error while restoring rule %q: %w", rule, err)
 		}
@@ -197,6 +223,10 @@ func (g *Group) updateWith(newGroup *Group) error {
 	g.Concurrency = newGroup.Concurrency
 	g.ExtraFilterLabels = newGroup.ExtraFilterLabels
 	g.Labels = newGroup.Labels
+	g.Params = newGroup.Params
+	g.Headers = newGroup.Headers
+	g.Tenant = newGroup.Tenant
+	g.RemoteWrite = newGroup.RemoteWrite
 	g.Checksum = newGroup.Checksum
 	g.Rules = newRules
 	return nil
@@ -218,8 +248,9 @@ func (g *Group) close() {
 
 var skipRandSleepOnGroupStart bool
 
-func (g *Group) start(ctx context.Context, nts []notifier.Notifier, rw *remotewrite.Client) {
+func (g *Group) start(ctx context.Context, nts func() []notifier.Notifier, rw *remotewrite.Client) {
 	defer func() { close(g.finishedCh) }()
+	defer g.closeRemoteWrite()
 
 	// Spread group rules evaluation over time in order to reduce load on VictoriaMetrics.
 	if !skipRandSleepOnGroupStart {
@@ -242,14 +273,9 @@ func (g *Group) start(ctx context.Context, nts []notifier.Notifier, rw *remotewr
 	}
 
 	logger.Infof("group %q started; interval=%v; concurrency=%d", g.Name, g.Interval, g.Concurrency)
-	e := &executor{rw: rw}
-	for _, nt := range nts {
-		ent := eNotifier{
-			Notifier:         nt,
-			alertsSent:       getOrCreateCounter(fmt.Sprintf("vmalert_alerts_sent_total{addr=%q}", nt.Addr())),
-			alertsSendErrors: getOrCreateCounter(fmt.Sprintf("vmalert_alerts_send_errors_total{addr=%q}", nt.Addr())),
-		}
-		e.notifiers = append(e.notifiers, ent)
+	e := &executor{
+		rw:        g.remoteWriter(rw),
+		notifiers: nts,
 	}
 
 	t := time.NewTicker(g.Interval)
@@ -264,10 +290,12 @@ func (g *Group) start(ctx context.Context, nts []notifier.Notifier, rw *remotewr
 			return
 		case ng := <-g.updateCh:
 			g.mu.Lock()
+			rwChanged := !reflect.DeepEqual(g.RemoteWrite, ng.RemoteWrite)
 			err := g.updateWith(ng)
 			if err != nil {
-				logger.Errorf("group %q: failed to update: %s", g.Name, err)
 				g.mu.Unlock()
+				logger.Errorf("group %q: failed to update: %s", g.Name, err)
+				ng.closeRemoteWrite()
 				continue
 			}
 			if g.Interval != ng.Interval {
@@ -275,7 +303,15 @@ func (g *Group) start(ctx context.Context, nts []notifier.Notifier, rw *remotewr
 				t.Stop()
 				t = time.NewTicker(g.Interval)
 			}
+			if rwChanged {
+				// Swap the clients, so the previous client is closed below.
+				g.rw, ng.rw = ng.rw, g.rw
+			}
+			e.rw = g.remoteWriter(rw)
 			g.mu.Unlock()
+			// Close the unused client outside the lock, since it may take
+			// a while to flush pending data.
+			ng.closeRemoteWrite()
 			logger.Infof("group %q re-started; interval=%v; concurrency=%d", g.Name, g.Interval, g.Concurrency)
 		case <-t.C:
 			g.metrics.iterationTotal.Inc()
@@ -289,10 +325,57 @@ func (g *Group) start(ctx context.Context, nts []notifier.Notifier, rw *remotewr
 			}
 
 			g.metrics.iterationDuration.UpdateDuration(iterationStart)
+			g.mu.Lock()
+			g.lastEvaluation = iterationStart
+			g.evaluationDuration = time.Since(iterationStart)
+			g.mu.Unlock()
 		}
 	}
 }
 
+// initRemoteWrite creates remote write client for the group's own remote write destination.
+// It does nothing if the group has no own remote write destination.
+//
+// The client must be closed via closeRemoteWrite when no longer needed.
+func (g *Group) initRemoteWrite(ctx context.Context) error {
+	if g.RemoteWrite == nil {
+		return nil
+	}
+	authCfg, err := g.RemoteWrite.AuthConfig()
+	if err != nil {
+		return fmt.Errorf("cannot init auth config for `remote_write` of group %q: %w", g.Name, err)
+	}
+	rw, err := remotewrite.NewClientForURL(ctx, g.RemoteWrite.URL, authCfg)
+	if err != nil {
+		return fmt.Errorf("cannot init `remote_write` for group %q: %w", g.Name, err)
+	}
+	g.rw = rw
+	return nil
+}
+
+// closeRemoteWrite flushes pending data and closes the client created via initRemoteWrite.
+func (g *Group) closeRemoteWrite() {
+	if g.rw == nil {
+		return
+	}
+	if err := g.rw.Close(); err != nil {
+		logger.Errorf("group %q: cannot close `remote_write` client: %s", g.Name, err)
+	}
+	g.rw = nil
+}
+
+// remoteWriter returns remote write client for the group's tenant.
+// The group's own remote write destination has priority over the given rw.
+func (g *Group) remoteWriter(rw *remotewrite.Client) *remotewrite.Client {
+	if g.rw != nil {
+		rw = g.rw
+	}
+	if rw == nil || g.Tenant == "" {
+		return rw
+	}
+	return rw.ForTenant(g.Tenant)
+}
+
 // resolveDuration for alerts is equal to 3 interval evaluations
 // so in case if vmalert stops sending updates for some reason,
 // notifier could automatically resolve the alert.
@@ -305,16 +388,12 @@ func getResolveDuration(groupInterval time.Duration) time.Duration {
 }
 
 type executor struct {
-	notifiers []eNotifier
+	// notifiers returns the current list of notifiers,
+	// since it may change over time if notifiers are discovered via -notifier.config.
+	notifiers func() []notifier.Notifier
 	rw        *remotewrite.Client
 }
 
-type eNotifier struct {
-	notifier.Notifier
-	alertsSent       *counter
-	alertsSendErrors *counter
-}
-
 func (e *executor) execConcurrently(ctx context.Context, rules []Rule, concurrency int, resolveDuration time.Duration) chan error {
 	res := make(chan error, len(rules))
 	if concurrency == 1 {
@@ -393,10 +472,10 @@ func (e *executor) exec(ctx context.Context, rule Rule, resolveDuration time.Dur
 	}
 
 	errGr := new(utils.ErrGroup)
-	for _, nt := range e.notifiers {
-		nt.alertsSent.Add(len(alerts))
+	for _, nt := range e.notifiers() {
+		getOrCreateCounter(fmt.Sprintf("vmalert_alerts_sent_total{addr=%q}", nt.Addr())).Add(len(alerts))
 		if err := nt.Send(ctx, alerts); err != nil {
-			nt.alertsSendErrors.Inc()
+			getOrCreateCounter(fmt.Sprintf("vmalert_alerts_send_errors_total{addr=%q}", nt.Addr())).Inc()
 			errGr.Add(fmt.Errorf("rule %q: failed to send alerts: %w", rule, err))
 		}
 	}
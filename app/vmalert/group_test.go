@@ -3,14 +3,19 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/remotewrite"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 )
 
 func init() {
@@ -210,7 +215,7 @@ func TestGroupStart(t *testing.T) {
 	fs.add(m1)
 	fs.add(m2)
 	go func() {
-		g.start(context.Background(), []notifier.Notifier{fn}, nil)
+		g.start(context.Background(), func() []notifier.Notifier { return []notifier.Notifier{fn} }, nil)
 		close(finished)
 	}()
 
@@ -260,3 +265,79 @@ func TestResolveDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestGroupRemoteWriter(t *testing.T) {
+	g := &Group{Name: "test"}
+	if rw := g.remoteWriter(nil); rw != nil {
+		t.Fatalf("expecting nil client if remote write isn't configured")
+	}
+	rw, err := remotewrite.NewClient(context.Background(), remotewrite.Config{Addr: "http://localhost:8480"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { _ = rw.Close() }()
+	if got := g.remoteWriter(rw); got != rw {
+		t.Fatalf("expecting the same client for group without tenant")
+	}
+	g.Tenant = "123:456"
+	got := g.remoteWriter(rw)
+	if got == rw {
+		t.Fatalf("expecting a separate client for group with tenant")
+	}
+	if got != rw.ForTenant("123:456") {
+		t.Fatalf("expecting the client to be re-used for the same tenant")
+	}
+}
+
+func TestGroupOwnRemoteWrite(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/insert/1/prometheus/api/v1/write" {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer srv.Close()
+
+	rw, err := remotewrite.NewClient(context.Background(), remotewrite.Config{Addr: "http://localhost:8480"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { _ = rw.Close() }()
+
+	g := &Group{
+		Name:        "test",
+		Tenant:      "1",
+		RemoteWrite: &config.RemoteWrite{URL: srv.URL},
+	}
+	if err := g.initRemoteWrite(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if g.rw == nil {
+		t.Fatalf("expecting non-nil client for group with `remote_write`")
+	}
+	got := g.remoteWriter(rw)
+	if got == rw || got == rw.ForTenant("1") {
+		t.Fatalf("expecting the group's own client to be used instead of the default one")
+	}
+	if got != g.rw.ForTenant("1") {
+		t.Fatalf("expecting the group's tenant to be applied to the group's own client")
+	}
+	if err := got.Push(prompbmarshal.TimeSeries{
+		Labels:  []prompbmarshal.Label{{Name: "__name__", Value: "foo"}},
+		Samples: []prompbmarshal.Sample{{Value: 1, Timestamp: time.Now().UnixNano() / 1e6}},
+	}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// pending data must be flushed on close
+	g.closeRemoteWrite()
+	if g.rw != nil {
+		t.Fatalf("expecting nil client after close")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("expecting 1 remote write request; got %d", n)
+	}
+	if got := g.remoteWriter(rw); got != rw.ForTenant("1") {
+		t.Fatalf("expecting the default client to be used after close")
+	}
+}
@@ -32,8 +32,18 @@ Examples:
  -rule="/path/to/file". Path to a single file with alerting rules
  -rule="dir/*.yaml" -rule="/*.yaml". Relative path to all .yaml files in "dir" folder,
 absolute path to all .yaml files in root.
+ -rule="s3://bucket/rules/*.yaml" -rule="gs://bucket/alerts.yaml" -rule="https://host/rules.yaml".
+Rule files from S3, GCS or http(s) URLs are re-fetched on SIGHUP or every -rule.configCheckInterval.
 Rule files may contain %{ENV_VAR} placeholders, which are substituted by the corresponding env vars.`)
 
+	ruleTemplatesPath = flagutil.NewArray("rule.templates", `Path or glob pattern to files with Go template definitions
+for reusing in annotations via {{ template "name" . }}. Flag can be specified multiple times.
+Examples:
+ -rule.templates="/path/to/file". Path to a single file with templates
+ -rule.templates="dir/*.tpl" -rule.templates="/*.tpl". Relative path to all .tpl files in "dir" folder,
+absolute path to all .tpl files in root.
+Template files are re-read on SIGHUP or every -rule.configCheckInterval.`)
+
 	rulesCheckInterval = flag.Duration("rule.configCheckInterval", 0, "Interval for checking for changes in '-rule' files. "+
 		"By default the checking is disabled. Send SIGHUP signal in order to force config check for changes")
 
@@ -54,14 +64,27 @@ eg. 'explore?orgId=1&left=[\"now-1h\",\"now\",\"VictoriaMetrics\",{\"expr\": \"{
 		" For example, if lookback=1h then range from now() to now()-1h will be scanned.")
 	remoteReadIgnoreRestoreErrors = flag.Bool("remoteRead.ignoreRestoreErrors", true, "Whether to ignore errors from remote storage when restoring alerts state on startup.")
 
+	stateFile = flag.String("stateFile", "", "Optional path to a file for persisting the state of active alerts on graceful shutdown. "+
+		"The state is restored from the file on startup, so alerts with non-zero 'for' param don't reset their pending timers after restart. "+
+		"See also -remoteRead.url")
+
 	disableAlertGroupLabel = flag.Bool("disableAlertgroupLabel", false, "Whether to disable adding group's name as label to generated alerts and time series.")
 
 	dryRun = flag.Bool("dryRun", false, "Whether to check only config files without running vmalert. The rules file are validated. The `-rule` flag must be specified.")
 )
 
+// toolMain is the entry point for vmalert-tool.
+//
+// It is set only when the binary is built with `vmalerttool` build tag. See unittest.go
+var toolMain func()
+
 func main() {
 	// Write flags and help message to stdout, since it is easier to grep or pipe.
 	flag.CommandLine.SetOutput(os.Stdout)
+	if toolMain != nil {
+		toolMain()
+		return
+	}
 	flag.Usage = usage
 	envflag.Parse()
 	buildinfo.Init()
@@ -70,6 +93,9 @@ func main() {
 	if *dryRun {
 		u, _ := url.Parse("https://victoriametrics.com/")
 		notifier.InitTemplateFunc(u)
+		if err := notifier.LoadTemplates(*ruleTemplatesPath); err != nil {
+			logger.Fatalf("failed to load `rule.templates`: %s", err)
+		}
 		groups, err := config.Parse(*rulePath, true, true)
 		if err != nil {
 			logger.Fatalf("failed to parse %q: %s", *rulePath, err)
@@ -84,11 +110,17 @@ func main() {
 		if err != nil {
 			logger.Fatalf("failed to init remoteWrite: %s", err)
 		}
+		if rw == nil {
+			logger.Fatalf("-remoteWrite.url must be set in replay mode in order to persist rules evaluation results")
+		}
 		eu, err := getExternalURL(*externalURL, *httpListenAddr, httpserver.IsTLS())
 		if err != nil {
 			logger.Fatalf("failed to init `external.url`: %s", err)
 		}
 		notifier.InitTemplateFunc(eu)
+		if err := notifier.LoadTemplates(*ruleTemplatesPath); err != nil {
+			logger.Fatalf("failed to load `rule.templates`: %s", err)
+		}
 		groupsCfg, err := config.Parse(*rulePath, *validateTemplates, *validateExpressions)
 		if err != nil {
 			logger.Fatalf("cannot parse configuration file: %s", err)
@@ -134,6 +166,7 @@ func main() {
 	}
 	cancel()
 	manager.close()
+	notifier.Stop()
 }
 
 var (
@@ -153,6 +186,9 @@ func newManager(ctx context.Context) (*manager, error) {
 		return nil, fmt.Errorf("failed to init `external.url`: %w", err)
 	}
 	notifier.InitTemplateFunc(eu)
+	if err := notifier.LoadTemplates(*ruleTemplatesPath); err != nil {
+		return nil, fmt.Errorf("failed to load `rule.templates`: %w", err)
+	}
 	aug, err := getAlertURLGenerator(eu, *externalAlertSource, *validateTemplates)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init `external.alert.source`: %w", err)
@@ -180,6 +216,14 @@ func newManager(ctx context.Context) (*manager, error) {
 	}
 	manager.rr = rr
 
+	if *stateFile != "" {
+		state, err := readAlertsState(*stateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read -stateFile: %w", err)
+		}
+		manager.state = state
+	}
+
 	for _, s := range *externalLabels {
 		if len(s) == 0 {
 			continue
@@ -263,41 +307,92 @@ func configReload(ctx context.Context, m *manager, groupsCfg []config.Group) {
 	configSuccess.Set(1)
 	configTimestamp.Set(fasttime.UnixTimestamp())
 	for {
+		var respCh chan<- error
 		select {
 		case <-ctx.Done():
 			return
 		case <-sighupCh:
 			logger.Infof("SIGHUP received. Going to reload rules %q ...", *rulePath)
 			configReloads.Inc()
+		case respCh = <-reloadRequestCh:
+			logger.Infof("config reload was requested via API. Going to reload rules %q ...", *rulePath)
+			configReloads.Inc()
 		case <-configCheckCh:
 		}
-		newGroupsCfg, err := config.Parse(*rulePath, *validateTemplates, *validateExpressions)
+		newGroupsCfg, err := reloadConfig(ctx, m, groupsCfg)
+		if respCh != nil {
+			respCh <- err
+		}
 		if err != nil {
 			configReloadErrors.Inc()
 			configSuccess.Set(0)
-			logger.Errorf("cannot parse configuration file: %s", err)
+			logger.Errorf("%s; continue using the previous configuration", err)
 			continue
 		}
-		if configsEqual(newGroupsCfg, groupsCfg) {
-			// set success to 1 since previous reload
-			// could have been unsuccessful
-			configSuccess.Set(1)
+		// set success to 1 since previous reload
+		// could have been unsuccessful
+		configSuccess.Set(1)
+		if newGroupsCfg == nil {
 			// config didn't change - skip it
 			continue
 		}
 		groupsCfg = newGroupsCfg
-		if err := m.update(ctx, groupsCfg, false); err != nil {
-			configReloadErrors.Inc()
-			configSuccess.Set(0)
-			logger.Errorf("error while reloading rules: %s", err)
-			continue
-		}
-		configSuccess.Set(1)
 		configTimestamp.Set(fasttime.UnixTimestamp())
 		logger.Infof("Rules reloaded successfully from %q", *rulePath)
 	}
 }
 
+// reloadRequestCh is used for requesting config reload via /-/reload API.
+// The result of the reload is sent to the passed channel.
+var reloadRequestCh = make(chan chan<- error)
+
+// requestConfigReload requests config reload from configReload
+// and waits until the reload is finished.
+func requestConfigReload(ctx context.Context) error {
+	respCh := make(chan error, 1)
+	select {
+	case reloadRequestCh <- respCh:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-respCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reloadConfig re-reads templates, rules and notifiers configuration
+// and applies the new rules to m.
+// The running groups and notifiers are left untouched if templates or rules are invalid.
+// It returns nil groups if the rules didn't change.
+func reloadConfig(ctx context.Context, m *manager, groupsCfg []config.Group) ([]config.Group, error) {
+	restoreTemplates := notifier.SnapshotTemplates()
+	if err := notifier.LoadTemplates(*ruleTemplatesPath); err != nil {
+		return nil, fmt.Errorf("cannot load `rule.templates`: %w", err)
+	}
+	newGroupsCfg, err := config.Parse(*rulePath, *validateTemplates, *validateExpressions)
+	if err != nil {
+		// roll back templates, since the running rules may refer to them
+		restoreTemplates()
+		return nil, fmt.Errorf("cannot parse configuration file: %w", err)
+	}
+	// Reload notifiers only after the rules are successfully parsed,
+	// so invalid rules do not lead to partially applied config.
+	if err := notifier.Reload(); err != nil {
+		restoreTemplates()
+		return nil, fmt.Errorf("cannot reload notifier configuration: %w", err)
+	}
+	if configsEqual(newGroupsCfg, groupsCfg) {
+		return nil, nil
+	}
+	if err := m.update(ctx, newGroupsCfg, false); err != nil {
+		return nil, fmt.Errorf("error while reloading rules: %w", err)
+	}
+	return newGroupsCfg, nil
+}
+
 func configsEqual(a, b []config.Group) bool {
 	if len(a) != len(b) {
 		return false
@@ -143,6 +143,30 @@ groups:
 		t.Fatalf("expected to have exactly 1 group loaded; got %d", groupsLen)
 	}
 
+	// reload via API must return the result of the reload
+	reqCtx, reqCancel := context.WithTimeout(ctx, time.Second)
+	defer reqCancel()
+	if err := requestConfigReload(reqCtx); err == nil {
+		t.Fatalf("expected to get error for corrupted config")
+	}
+	if groupsLen := lenLocked(m); groupsLen != 1 { // should remain unchanged
+		t.Fatalf("expected to have exactly 1 group loaded; got %d", groupsLen)
+	}
+	if n := configSuccess.Get(); n != 0 {
+		t.Fatalf("expected %q to be 0; got %d", "vmalert_config_last_reload_successful", n)
+	}
+
+	writeToFile(t, f.Name(), rules2)
+	if err := requestConfigReload(reqCtx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if groupsLen := lenLocked(m); groupsLen != 2 {
+		t.Fatalf("expected to have exactly 2 groups loaded; got %d", groupsLen)
+	}
+	if n := configSuccess.Get(); n != 1 {
+		t.Fatalf("expected %q to be 1; got %d", "vmalert_config_last_reload_successful", n)
+	}
+
 	cancel()
 	<-syncCh
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sync"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
@@ -15,11 +16,13 @@ import (
 // manager controls group states
 type manager struct {
 	querierBuilder datasource.QuerierBuilder
-	notifiers      []notifier.Notifier
+	notifiers      func() []notifier.Notifier
 
 	rw *remotewrite.Client
 	// remote read builder.
 	rr datasource.QuerierBuilder
+	// alerts state read from -stateFile grouped by group ID.
+	state map[uint64][]alertState
 
 	wg     sync.WaitGroup
 	labels map[string]string
@@ -61,6 +64,14 @@ func (m *manager) close() {
 		}
 	}
 	m.wg.Wait()
+	if *stateFile != "" {
+		m.groupsMu.RLock()
+		err := writeAlertsState(*stateFile, m.groups)
+		m.groupsMu.RUnlock()
+		if err != nil {
+			logger.Errorf("cannot save alerts state to -stateFile=%q: %s", *stateFile, err)
+		}
+	}
 }
 
 func (m *manager) startGroup(ctx context.Context, group *Group, restore bool) error {
@@ -73,6 +84,13 @@ func (m *manager) startGroup(ctx context.Context, group *Group, restore bool) er
 			logger.Errorf("error while restoring state for group %q: %s", group.Name, err)
 		}
 	}
+	if restore && m.state != nil {
+		// The state from -stateFile takes precedence over the state restored from -remoteRead.url,
+		// since it contains the exact state at the moment of the previous shutdown.
+		if err := group.restoreState(ctx, m.state[group.ID()]); err != nil {
+			logger.Errorf("error while restoring state for group %q from -stateFile=%q: %s", group.Name, *stateFile, err)
+		}
+	}
 
 	m.wg.Add(1)
 	id := group.ID()
@@ -96,6 +114,7 @@ func (m *manager) update(ctx context.Context, groupsCfg []config.Group, restore
 		new *Group
 	}
 	var toUpdate []updateItem
+	var toStop []*Group
 
 	m.groupsMu.Lock()
 	for _, og := range m.groups {
@@ -103,9 +122,7 @@ func (m *manager) update(ctx context.Context, groupsCfg []config.Group, restore
 		if !ok {
 			// old group is not present in new list,
 			// so must be stopped and deleted
-			og.close()
-			delete(m.groups, og.ID())
-			og = nil
+			toStop = append(toStop, og)
 			continue
 		}
 		delete(groupsRegistry, ng.ID())
@@ -113,8 +130,51 @@ func (m *manager) update(ctx context.Context, groupsCfg []config.Group, restore
 			toUpdate = append(toUpdate, updateItem{old: og, new: ng})
 		}
 	}
+
+	// Create remote write clients for new and updated groups before applying any changes,
+	// so the running groups are left untouched if any of the clients cannot be created.
+	// Updated groups re-use their current clients if `remote_write` isn't changed,
+	// so no client is created for them.
+	var initialized []*Group
+	// closeRemoteWrites closes clients of the groups, which weren't started.
+	closeRemoteWrites := func() {
+		for _, g := range initialized {
+			if m.groups[g.ID()] != g {
+				g.closeRemoteWrite()
+			}
+		}
+	}
+	for _, ng := range groupsRegistry {
+		if err := ng.initRemoteWrite(ctx); err != nil {
+			closeRemoteWrites()
+			m.groupsMu.Unlock()
+			return err
+		}
+		initialized = append(initialized, ng)
+	}
+	for _, item := range toUpdate {
+		item.old.mu.RLock()
+		rwChanged := !reflect.DeepEqual(item.old.RemoteWrite, item.new.RemoteWrite)
+		item.old.mu.RUnlock()
+		if !rwChanged {
+			continue
+		}
+		if err := item.new.initRemoteWrite(ctx); err != nil {
+			closeRemoteWrites()
+			m.groupsMu.Unlock()
+			return err
+		}
+		initialized = append(initialized, item.new)
+	}
+
+	for _, og := range toStop {
+		og.close()
+		delete(m.groups, og.ID())
+	}
 	for _, ng := range groupsRegistry {
 		if err := m.startGroup(ctx, ng, restore); err != nil {
+			closeRemoteWrites()
+			m.groupsMu.Unlock()
 			return err
 		}
 	}
@@ -149,6 +209,8 @@ func (g *Group) toAPI() APIGroup {
 		Concurrency:       g.Concurrency,
 		ExtraFilterLabels: g.ExtraFilterLabels,
 		Labels:            g.Labels,
+		Params:            g.Params,
+		Tenant:            g.Tenant,
 	}
 	for _, r := range g.Rules {
 		switch v := r.(type) {
@@ -160,3 +222,26 @@ func (g *Group) toAPI() APIGroup {
 	}
 	return ag
 }
+
+func (g *Group) toPromAPI() PromGroup {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	pg := PromGroup{
+		Name:           g.Name,
+		File:           g.File,
+		Rules:          []interface{}{},
+		Interval:       g.Interval.Seconds(),
+		EvaluationTime: g.evaluationDuration.Seconds(),
+		LastEvaluation: g.lastEvaluation,
+	}
+	for _, r := range g.Rules {
+		switch v := r.(type) {
+		case *AlertingRule:
+			pg.Rules = append(pg.Rules, v.PromRuleAPI())
+		case *RecordingRule:
+			pg.Rules = append(pg.Rules, v.PromRuleAPI())
+		}
+	}
+	return pg
+}
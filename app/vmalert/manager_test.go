@@ -12,6 +12,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
 )
 
 func TestMain(m *testing.M) {
@@ -38,7 +39,7 @@ func TestManagerUpdateConcurrent(t *testing.T) {
 	m := &manager{
 		groups:         make(map[uint64]*Group),
 		querierBuilder: &fakeQuerier{},
-		notifiers:      []notifier.Notifier{&fakeNotifier{}},
+		notifiers:      func() []notifier.Notifier { return []notifier.Notifier{&fakeNotifier{}} },
 	}
 	paths := []string{
 		"config/testdata/dir/rules0-good.rules",
@@ -276,6 +277,53 @@ func TestManagerUpdate(t *testing.T) {
 	}
 }
 
+func TestManagerUpdateRemoteWriteError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	m := &manager{groups: make(map[uint64]*Group), querierBuilder: &fakeQuerier{}}
+	defer func() {
+		cancel()
+		m.close()
+	}()
+
+	cfg := loadCfg(t, []string{"config/testdata/rules-remotewrite-good.rules"}, true, true)
+	if err := m.update(ctx, cfg, false); err != nil {
+		t.Fatalf("failed to complete initial rules update: %s", err)
+	}
+	ids := make(map[uint64]*Group)
+	for id, g := range m.groups {
+		ids[id] = g
+	}
+
+	// invalid `remote_write` in the updated group must fail the whole update
+	cfgUpdate := loadCfg(t, []string{"config/testdata/rules-remotewrite-good.rules"}, true, true)
+	cfgUpdate[1].RemoteWrite = &config.RemoteWrite{
+		URL: "http://localhost:8428",
+		HTTPClientConfig: promauth.HTTPClientConfig{
+			BearerToken:     "foo",
+			BearerTokenFile: "bar",
+		},
+	}
+	cfgUpdate[1].Checksum = "updated"
+	cfgUpdate = append(cfgUpdate, config.Group{Name: "new", Rules: cfgUpdate[1].Rules})
+	if err := m.update(ctx, cfgUpdate, false); err == nil {
+		t.Fatalf("expecting error for invalid `remote_write`")
+	}
+	if len(m.groups) != len(ids) {
+		t.Fatalf("unexpected number of groups after failed update; got %d; want %d", len(m.groups), len(ids))
+	}
+	for id, g := range m.groups {
+		if ids[id] != g {
+			t.Fatalf("unexpected group %q after failed update", g.Name)
+		}
+		g.mu.RLock()
+		rw := g.RemoteWrite
+		g.mu.RUnlock()
+		if rw != nil && g.Name != "TestLongTermGroup" {
+			t.Fatalf("the group %q mustn't be updated", g.Name)
+		}
+	}
+}
+
 func loadCfg(t *testing.T, path []string, validateAnnotations, validateExpressions bool) []config.Group {
 	t.Helper()
 	cfg, err := config.Parse(path, validateAnnotations, validateExpressions)
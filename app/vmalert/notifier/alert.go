@@ -30,6 +30,10 @@ type Alert struct {
 	Start time.Time
 	// End defines the moment of time when Alert supposed to expire
 	End time.Time
+	// KeepFiringSince defines the moment of time when the firing Alert's expression
+	// stopped returning results, while the Alert keeps firing according to keep_firing_for.
+	// It is zero if the Alert's expression returns results.
+	KeepFiringSince time.Time
 	// Value stores the value returned from evaluating expression from Expr field
 	Value float64
 	// ID is the unique identifer for the Alert
@@ -115,8 +119,11 @@ func templateAnnotations(annotations map[string]string, data AlertTplData, funcs
 }
 
 func templateAnnotation(dst io.Writer, text string, data AlertTplData, funcs template.FuncMap) error {
-	t := template.New("").Funcs(funcs).Option("missingkey=zero")
-	tpl, err := t.Parse(text)
+	t, err := newTemplate()
+	if err != nil {
+		return err
+	}
+	tpl, err := t.Funcs(funcs).Option("missingkey=zero").Parse(text)
 	if err != nil {
 		return fmt.Errorf("error parsing annotation: %w", err)
 	}
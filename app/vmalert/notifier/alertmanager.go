@@ -7,6 +7,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 )
 
 // AlertManager represents integration provider with Prometheus alert manager
@@ -18,6 +21,11 @@ type AlertManager struct {
 	basicAuthPass string
 	argFunc       AlertURLGenerator
 	client        *http.Client
+	// authCfg is optional auth config for Alertmanager configured via -notifier.config
+	authCfg *promauth.Config
+	// relabelConfigs are optional relabeling rules applied to alerts before sending them to Alertmanager.
+	// They are applied after alertRelabelConfigs.
+	relabelConfigs *promrelabel.ParsedConfigs
 }
 
 // Addr returns address where alerts are sent.
@@ -25,6 +33,14 @@ func (am AlertManager) Addr() string { return am.addr }
 
 // Send an alert or resolve message
 func (am *AlertManager) Send(ctx context.Context, alerts []Alert) error {
+	if len(alerts) > 0 {
+		alerts = relabelAlerts(alerts, alertRelabelConfigs)
+		alerts = relabelAlerts(alerts, am.relabelConfigs)
+		if len(alerts) == 0 {
+			// All the alerts have been dropped during relabeling.
+			return nil
+		}
+	}
 	b := &bytes.Buffer{}
 	writeamRequest(b, alerts, am.argFunc)
 
@@ -37,6 +53,11 @@ func (am *AlertManager) Send(ctx context.Context, alerts []Alert) error {
 	if am.basicAuthPass != "" {
 		req.SetBasicAuth(am.basicAuthUser, am.basicAuthPass)
 	}
+	if am.authCfg != nil {
+		if ah := am.authCfg.GetAuthHeader(); ah != "" {
+			req.Header.Set("Authorization", ah)
+		}
+	}
 	resp, err := am.client.Do(req)
 	if err != nil {
 		return err
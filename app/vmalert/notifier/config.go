@@ -0,0 +1,155 @@
+package notifier
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/consul"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/dns"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
+)
+
+// Config contains list of supported configuration settings
+// for discovering Alertmanager instances via -notifier.config.
+type Config struct {
+	// Scheme defines the HTTP scheme for Alertmanager address.
+	// It can be overridden via `__scheme__` label during relabeling.
+	Scheme string `yaml:"scheme,omitempty"`
+	// PathPrefix is added to URL path before adding alertManagerPath value.
+	// It can be overridden via `__path_prefix__` label during relabeling.
+	PathPrefix string `yaml:"path_prefix,omitempty"`
+
+	// StaticConfigs contains list of static Alertmanager addresses.
+	StaticConfigs []StaticConfig `yaml:"static_configs,omitempty"`
+	// ConsulSDConfigs contains list of settings for service discovery via Consul.
+	ConsulSDConfigs []consul.SDConfig `yaml:"consul_sd_configs,omitempty"`
+	// DNSSDConfigs contains list of settings for service discovery via DNS.
+	DNSSDConfigs []dns.SDConfig `yaml:"dns_sd_configs,omitempty"`
+	// KubernetesSDConfigs contains list of settings for service discovery via Kubernetes.
+	KubernetesSDConfigs []kubernetes.SDConfig `yaml:"kubernetes_sd_configs,omitempty"`
+
+	// HTTPClientConfig contains HTTP configuration for Alertmanager clients.
+	HTTPClientConfig promauth.HTTPClientConfig `yaml:",inline"`
+	// RelabelConfigs contains list of relabeling rules applied to discovered targets.
+	RelabelConfigs []promrelabel.RelabelConfig `yaml:"relabel_configs,omitempty"`
+	// AlertRelabelConfigs contains list of relabeling rules applied to alerts
+	// before sending them to the discovered targets.
+	AlertRelabelConfigs []promrelabel.RelabelConfig `yaml:"alert_relabel_configs,omitempty"`
+
+	// data is the raw config data. It is used for detecting config changes.
+	data []byte
+	// baseDir is the directory of the config file.
+	// It is used for resolving relative paths in the config.
+	baseDir string
+	// authCfg is parsed HTTPClientConfig.
+	authCfg *promauth.Config
+	// parsedRelabelConfigs is parsed RelabelConfigs.
+	parsedRelabelConfigs *promrelabel.ParsedConfigs
+	// parsedAlertRelabelConfigs is parsed AlertRelabelConfigs.
+	parsedAlertRelabelConfigs *promrelabel.ParsedConfigs
+}
+
+// StaticConfig contains list of static targets.
+type StaticConfig struct {
+	Targets []string `yaml:"targets"`
+}
+
+// parseConfig reads and parses -notifier.config file at the given path.
+func parseConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", path, err)
+	}
+	data = envtemplate.Replace(data)
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain abs path for %q: %w", path, err)
+	}
+	cfg.data = data
+	cfg.baseDir = filepath.Dir(absPath)
+	if cfg.Scheme == "" {
+		cfg.Scheme = "http"
+	}
+	if cfg.Scheme != "http" && cfg.Scheme != "https" {
+		return nil, fmt.Errorf("unexpected `scheme` in %q: %q; supported values: http, https", path, cfg.Scheme)
+	}
+	ac, err := cfg.HTTPClientConfig.NewConfig(cfg.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config in %q: %w", path, err)
+	}
+	cfg.authCfg = ac
+	prcs, err := promrelabel.ParseRelabelConfigs(cfg.RelabelConfigs, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `relabel_configs` in %q: %w", path, err)
+	}
+	cfg.parsedRelabelConfigs = prcs
+	arcs, err := promrelabel.ParseRelabelConfigs(cfg.AlertRelabelConfigs, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `alert_relabel_configs` in %q: %w", path, err)
+	}
+	cfg.parsedAlertRelabelConfigs = arcs
+	if len(cfg.StaticConfigs) == 0 && len(cfg.ConsulSDConfigs) == 0 && len(cfg.DNSSDConfigs) == 0 && len(cfg.KubernetesSDConfigs) == 0 {
+		return nil, fmt.Errorf("at least one of `static_configs`, `consul_sd_configs`, `dns_sd_configs` or `kubernetes_sd_configs` must be set in %q", path)
+	}
+	return &cfg, nil
+}
+
+// parseLabels returns Alertmanager URL for the given target labels after applying relabeling.
+//
+// Empty string is returned if the target has been dropped during relabeling.
+func (cfg *Config) parseLabels(target map[string]string) (string, error) {
+	labels := make([]prompbmarshal.Label, 0, len(target)+2)
+	for name, value := range target {
+		labels = append(labels, prompbmarshal.Label{
+			Name:  name,
+			Value: value,
+		})
+	}
+	if _, ok := target["__scheme__"]; !ok {
+		labels = append(labels, prompbmarshal.Label{
+			Name:  "__scheme__",
+			Value: cfg.Scheme,
+		})
+	}
+	if _, ok := target["__path_prefix__"]; !ok {
+		labels = append(labels, prompbmarshal.Label{
+			Name:  "__path_prefix__",
+			Value: cfg.PathPrefix,
+		})
+	}
+	labels = cfg.parsedRelabelConfigs.Apply(labels, 0, false)
+	addr := promrelabel.GetLabelValueByName(labels, "__address__")
+	if addr == "" {
+		// The target has been dropped during relabeling.
+		return "", nil
+	}
+	if strings.Contains(addr, "/") {
+		return "", fmt.Errorf("`__address__` label must contain only host:port; got %q", addr)
+	}
+	scheme := promrelabel.GetLabelValueByName(labels, "__scheme__")
+	if scheme == "" {
+		scheme = cfg.Scheme
+	}
+	pathPrefix := promrelabel.GetLabelValueByName(labels, "__path_prefix__")
+	if pathPrefix != "" && !strings.HasPrefix(pathPrefix, "/") {
+		pathPrefix = "/" + pathPrefix
+	}
+	u := scheme + "://" + addr + strings.TrimSuffix(pathPrefix, "/")
+	if _, err := url.Parse(u); err != nil {
+		return "", fmt.Errorf("cannot parse Alertmanager URL %q: %w", u, err)
+	}
+	return u, nil
+}
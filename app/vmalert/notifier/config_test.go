@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	f := func(path string) {
+		t.Helper()
+		if _, err := parseConfig(path); err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", path, err)
+		}
+	}
+	f("testdata/static.good.yaml")
+	f("testdata/consul.good.yaml")
+	f("testdata/dns.good.yaml")
+}
+
+func TestParseConfigFailure(t *testing.T) {
+	f := func(path, errStr string) {
+		t.Helper()
+		_, err := parseConfig(path)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", path)
+		}
+		if !strings.Contains(err.Error(), errStr) {
+			t.Fatalf("expecting error to contain %q; got %q", errStr, err)
+		}
+	}
+	f("testdata/missing.yaml", "cannot read")
+	f("testdata/unknownFields.bad.yaml", "unknown_field")
+	f("testdata/empty.bad.yaml", "at least one of")
+	f("testdata/scheme.bad.yaml", "unexpected `scheme`")
+	f("testdata/relabeling.bad.yaml", "relabel_configs")
+	f("testdata/alertRelabeling.bad.yaml", "alert_relabel_configs")
+}
+
+func TestConfigParseLabels(t *testing.T) {
+	cfg, err := parseConfig("testdata/static.good.yaml")
+	if err != nil {
+		t.Fatalf("cannot parse config: %s", err)
+	}
+	f := func(target map[string]string, expected string) {
+		t.Helper()
+		u, err := cfg.parseLabels(target)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if u != expected {
+			t.Fatalf("unexpected URL for %v; got %q; want %q", target, u, expected)
+		}
+	}
+	f(map[string]string{"__address__": "localhost:9093"}, "https://localhost:9093/alertmanager")
+	f(map[string]string{"__address__": "localhost:9094"}, "http://localhost:9094/alertmanager")
+	f(map[string]string{"__address__": "localhost:9093", "__path_prefix__": "foo/"}, "https://localhost:9093/foo")
+	// dropped by relabeling
+	f(map[string]string{"__address__": "localhost:9095"}, "")
+	// missing address
+	f(map[string]string{"foo": "bar"}, "")
+
+	if _, err := cfg.parseLabels(map[string]string{"__address__": "localhost:9093/foo"}); err == nil {
+		t.Fatalf("expecting non-nil error for address with path")
+	}
+}
+
+func TestConfigWatcherStatic(t *testing.T) {
+	const baUser, baPass = "foo", "bar"
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prefix"+alertManagerPath {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != baUser || pass != baPass {
+			t.Errorf("wrong creds %q:%q; expected %q:%q", user, pass, baUser, baPass)
+		}
+		requests++
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	f, err := ioutil.TempFile("", "TestConfigWatcherStatic")
+	if err != nil {
+		t.Fatalf("cannot create temporary file: %s", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	data := fmt.Sprintf(`
+path_prefix: /prefix
+static_configs:
+  - targets:
+      - %s
+      - %s
+      - localhost:1
+relabel_configs:
+  - source_labels: [__address__]
+    regex: localhost:1
+    action: drop
+basic_auth:
+  username: %s
+  password: %s
+`, addr, addr, baUser, baPass)
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("cannot write config: %s", err)
+	}
+	_ = f.Close()
+
+	cfg, err := parseConfig(f.Name())
+	if err != nil {
+		t.Fatalf("cannot parse config: %s", err)
+	}
+	cw := newConfigWatcher(cfg, func(alert Alert) string { return "" })
+	defer cw.stop()
+
+	nts := cw.getNotifiers()
+	var addrs []string
+	for _, nt := range nts {
+		addrs = append(addrs, nt.Addr())
+	}
+	// Duplicate targets must be sent alerts only once.
+	expected := []string{srv.URL + "/prefix"}
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Fatalf("unexpected notifiers; got %q; want %q", addrs, expected)
+	}
+	if err := nts[0].Send(context.Background(), []Alert{{Name: "alert"}}); err != nil {
+		t.Fatalf("unexpected error when sending alerts: %s", err)
+	}
+	if requests != 1 {
+		t.Fatalf("unexpected number of requests; got %d; want 1", requests)
+	}
+}
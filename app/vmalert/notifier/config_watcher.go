@@ -0,0 +1,222 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/consul"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/dns"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
+)
+
+// configWatcher maintains the list of Alertmanager instances
+// discovered according to -notifier.config.
+type configWatcher struct {
+	cfg *Config
+	gen AlertURLGenerator
+
+	wg     sync.WaitGroup
+	stopCh chan struct{}
+
+	mu sync.RWMutex
+	// targets contains discovered Alertmanager URLs per discovery type.
+	targets map[string][]string
+	// ams contains AlertManager objects for the discovered URLs.
+	// The objects are re-used between discovery rounds in order to keep established connections.
+	ams map[string]*AlertManager
+	// notifiers is the list of notifiers for the discovered targets.
+	notifiers []Notifier
+}
+
+func newConfigWatcher(cfg *Config, gen AlertURLGenerator) *configWatcher {
+	cw := &configWatcher{
+		cfg:     cfg,
+		gen:     gen,
+		stopCh:  make(chan struct{}),
+		targets: make(map[string][]string),
+		ams:     make(map[string]*AlertManager),
+	}
+	cw.start()
+	return cw
+}
+
+// getNotifiers returns the current list of notifiers.
+func (cw *configWatcher) getNotifiers() []Notifier {
+	cw.mu.RLock()
+	defer cw.mu.RUnlock()
+	return cw.notifiers
+}
+
+func (cw *configWatcher) start() {
+	if len(cw.cfg.StaticConfigs) > 0 {
+		var targets []map[string]string
+		for _, sc := range cw.cfg.StaticConfigs {
+			for _, target := range sc.Targets {
+				targets = append(targets, map[string]string{
+					"__address__": target,
+				})
+			}
+		}
+		cw.setTargets("static", targets)
+	}
+	if len(cw.cfg.ConsulSDConfigs) > 0 {
+		cw.startDiscovery("consul", *consul.SDCheckInterval, func() ([]map[string]string, error) {
+			var targets []map[string]string
+			for i := range cw.cfg.ConsulSDConfigs {
+				labels, err := cw.cfg.ConsulSDConfigs[i].GetLabels(cw.cfg.baseDir)
+				if err != nil {
+					return nil, fmt.Errorf("cannot discover targets for consul_sd_config #%d: %w", i+1, err)
+				}
+				targets = append(targets, labels...)
+			}
+			return targets, nil
+		})
+	}
+	if len(cw.cfg.DNSSDConfigs) > 0 {
+		cw.startDiscovery("dns", *dns.SDCheckInterval, func() ([]map[string]string, error) {
+			var targets []map[string]string
+			for i := range cw.cfg.DNSSDConfigs {
+				labels, err := cw.cfg.DNSSDConfigs[i].GetLabels(cw.cfg.baseDir)
+				if err != nil {
+					return nil, fmt.Errorf("cannot discover targets for dns_sd_config #%d: %w", i+1, err)
+				}
+				targets = append(targets, labels...)
+			}
+			return targets, nil
+		})
+	}
+	if len(cw.cfg.KubernetesSDConfigs) > 0 {
+		for i := range cw.cfg.KubernetesSDConfigs {
+			// Kubernetes SD watches for changes in the background,
+			// so the discovered targets are just collected on every discovery round.
+			cw.cfg.KubernetesSDConfigs[i].MustStart(cw.cfg.baseDir, func(metaLabels map[string]string) interface{} {
+				return metaLabels
+			})
+		}
+		cw.startDiscovery("kubernetes", *kubernetes.SDCheckInterval, func() ([]map[string]string, error) {
+			var targets []map[string]string
+			for i := range cw.cfg.KubernetesSDConfigs {
+				swos, err := cw.cfg.KubernetesSDConfigs[i].GetScrapeWorkObjects()
+				if err != nil {
+					return nil, fmt.Errorf("cannot discover targets for kubernetes_sd_config #%d: %w", i+1, err)
+				}
+				for _, swo := range swos {
+					targets = append(targets, swo.(map[string]string))
+				}
+			}
+			return targets, nil
+		})
+	}
+}
+
+// startDiscovery updates targets for the given discovery type with the given interval
+// until cw is stopped.
+func (cw *configWatcher) startDiscovery(typ string, interval time.Duration, discover func() ([]map[string]string, error)) {
+	update := func() {
+		targets, err := discover()
+		if err != nil {
+			// Keep the previously discovered targets, so alerts are delivered
+			// while the discovery service is temporarily unavailable.
+			logger.Errorf("error when discovering Alertmanager instances via %s: %s", typ, err)
+			return
+		}
+		cw.setTargets(typ, targets)
+	}
+	update()
+	cw.wg.Add(1)
+	go func() {
+		defer cw.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-cw.stopCh:
+				return
+			case <-ticker.C:
+				update()
+			}
+		}
+	}()
+}
+
+// setTargets sets Alertmanager targets for the given discovery type.
+func (cw *configWatcher) setTargets(typ string, targets []map[string]string) {
+	var urls []string
+	for _, target := range targets {
+		u, err := cw.cfg.parseLabels(target)
+		if err != nil {
+			logger.Errorf("skipping Alertmanager target discovered via %s: %s", typ, err)
+			continue
+		}
+		if u == "" {
+			continue
+		}
+		urls = append(urls, u)
+	}
+
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.targets[typ] = urls
+
+	ams := make(map[string]*AlertManager)
+	var notifiers []Notifier
+	for _, typ := range []string{"static", "consul", "dns", "kubernetes"} {
+		for _, u := range cw.targets[typ] {
+			if _, ok := ams[u]; ok {
+				// Skip duplicate targets, so alerts aren't sent twice to the same Alertmanager.
+				continue
+			}
+			am := cw.ams[u]
+			if am == nil {
+				am = cw.newAlertManager(u)
+				logger.Infof("discovered Alertmanager %q via %s", u, typ)
+			}
+			ams[u] = am
+			notifiers = append(notifiers, am)
+		}
+	}
+	for u, am := range cw.ams {
+		if _, ok := ams[u]; !ok {
+			am.client.CloseIdleConnections()
+			logger.Infof("Alertmanager %q is no longer discovered", u)
+		}
+	}
+	sort.Slice(notifiers, func(i, j int) bool {
+		return notifiers[i].Addr() < notifiers[j].Addr()
+	})
+	cw.ams = ams
+	cw.notifiers = notifiers
+}
+
+func (cw *configWatcher) newAlertManager(u string) *AlertManager {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = cw.cfg.authCfg.NewTLSConfig()
+	am := NewAlertManager(u, "", "", cw.gen, &http.Client{Transport: tr})
+	am.authCfg = cw.cfg.authCfg
+	am.relabelConfigs = cw.cfg.parsedAlertRelabelConfigs
+	return am
+}
+
+// stop stops the discovery and closes connections to the discovered Alertmanager instances.
+func (cw *configWatcher) stop() {
+	close(cw.stopCh)
+	cw.wg.Wait()
+	for i := range cw.cfg.ConsulSDConfigs {
+		cw.cfg.ConsulSDConfigs[i].MustStop()
+	}
+	for i := range cw.cfg.DNSSDConfigs {
+		cw.cfg.DNSSDConfigs[i].MustStop()
+	}
+	for i := range cw.cfg.KubernetesSDConfigs {
+		cw.cfg.KubernetesSDConfigs[i].MustStop()
+	}
+	cw.mu.Lock()
+	for _, am := range cw.ams {
+		am.client.CloseIdleConnections()
+	}
+	cw.mu.Unlock()
+}
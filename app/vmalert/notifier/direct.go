@@ -0,0 +1,324 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// payloadAlert is the Alert representation available in payload templates
+// of notifiers, which deliver alerts directly without Alertmanager.
+type payloadAlert struct {
+	Alert
+	// Status is either "firing" or "resolved".
+	Status string
+	// GeneratorURL is a link to the alert generated via AlertURLGenerator.
+	GeneratorURL string
+}
+
+func newPayloadAlert(a Alert, gen AlertURLGenerator) payloadAlert {
+	pa := payloadAlert{
+		Alert:  a,
+		Status: "resolved",
+	}
+	if a.State == StateFiring {
+		pa.Status = "firing"
+	}
+	if gen != nil {
+		pa.GeneratorURL = gen(a)
+	}
+	return pa
+}
+
+// alertKey returns a key for deduplication of notifications for the given alert
+// by the receiving service.
+func alertKey(a Alert) string {
+	return fmt.Sprintf("%s-%x-%x", a.Name, a.GroupID, a.ID)
+}
+
+// payloadTemplate is a text template for payloads sent by direct notifiers.
+//
+// The template may refer to named templates loaded via LoadTemplates,
+// so it is re-parsed after every LoadTemplates call.
+type payloadTemplate struct {
+	name string
+	text string
+
+	mu sync.Mutex
+	// master contains named templates, which tpl has been parsed with.
+	master *template.Template
+	tpl    *template.Template
+}
+
+func newPayloadTemplate(name, text string) (*payloadTemplate, error) {
+	pt := &payloadTemplate{
+		name: name,
+		text: text,
+	}
+	if _, err := pt.get(); err != nil {
+		return nil, err
+	}
+	return pt, nil
+}
+
+// get returns the parsed template for the currently loaded named templates.
+func (pt *payloadTemplate) get() (*template.Template, error) {
+	master := getMasterTemplate()
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if pt.tpl != nil && pt.master == master {
+		return pt.tpl, nil
+	}
+	t, err := newTemplateFrom(master)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := t.Funcs(tmplFunc).Option("missingkey=zero").Parse(pt.text)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", pt.name, err)
+	}
+	pt.master = master
+	pt.tpl = tpl
+	return tpl, nil
+}
+
+func (pt *payloadTemplate) exec(data interface{}) (string, error) {
+	tpl, err := pt.get()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("cannot execute %s: %w", pt.name, err)
+	}
+	return b.String(), nil
+}
+
+// truncate cuts s to maxLen bytes, since services may reject too long fields.
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen]
+}
+
+// sendWithRetry sends POST request with the given body to url via c.
+//
+// Network errors, 429 and 5xx responses are retried up to -notifier.maxRetries times
+// with exponential backoff starting from -notifier.retryMinInterval
+// and limited by -notifier.retryMaxInterval.
+func sendWithRetry(ctx context.Context, c *http.Client, url string, header http.Header, body []byte) error {
+	backoff := *retryMinInterval
+	for attempt := 0; ; attempt++ {
+		retry, err := doRequest(ctx, c, url, header, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= *maxRetries {
+			if attempt > 0 {
+				return fmt.Errorf("%w; giving up after %d retries", err, attempt)
+			}
+			return err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		backoff *= 2
+		if backoff > *retryMaxInterval {
+			backoff = *retryMaxInterval
+		}
+	}
+}
+
+// doRequest performs a single POST request.
+//
+// It returns true together with the error if the request may be retried.
+func doRequest(ctx context.Context, c *http.Client, url string, header http.Header, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("failed to read response from %q: %w", url, err)
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("invalid SC %d from %q; response body: %s", resp.StatusCode, url, string(respBody))
+}
+
+// asyncNotifier sends alerts to the underlying notifier in background via a bounded queue,
+// so slow or unavailable services don't delay rules evaluation.
+//
+// Alerts are queued only if their state changes since the last notification.
+// Firing alerts are additionally re-sent every -notifier.repeatInterval.
+type asyncNotifier struct {
+	nt Notifier
+
+	queue  chan []Alert
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// sent contains the last notification for alerts by alertKey.
+	sent map[string]*sentAlert
+
+	alertsDropped *metrics.Counter
+	sendErrors    *metrics.Counter
+}
+
+// sentAlert is the last notification for the alert.
+type sentAlert struct {
+	firing bool
+	sentAt time.Time
+	// lastSeen is the last time the alert has been passed to asyncNotifier.Send.
+	lastSeen time.Time
+}
+
+func newAsyncNotifier(nt Notifier) *asyncNotifier {
+	an := &asyncNotifier{
+		nt:            nt,
+		queue:         make(chan []Alert, *queueSize),
+		stopCh:        make(chan struct{}),
+		sent:          make(map[string]*sentAlert),
+		alertsDropped: metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_alerts_dropped_total{addr=%q}`, nt.Addr())),
+		sendErrors:    metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_alerts_async_send_errors_total{addr=%q}`, nt.Addr())),
+	}
+	an.wg.Add(1)
+	go func() {
+		defer an.wg.Done()
+		an.run()
+	}()
+	return an
+}
+
+// Addr returns address where alerts are sent.
+func (an *asyncNotifier) Addr() string { return an.nt.Addr() }
+
+// Send queues alerts, which must be sent, and returns immediately.
+//
+// An error is returned if the queue is full.
+func (an *asyncNotifier) Send(_ context.Context, alerts []Alert) error {
+	alerts = an.filterAlerts(alerts, time.Now())
+	if len(alerts) == 0 {
+		return nil
+	}
+	select {
+	case an.queue <- alerts:
+		return nil
+	default:
+		// Forget the dropped alerts, so they are queued again on the next rule evaluation.
+		an.forgetAlerts(alerts)
+		an.alertsDropped.Add(len(alerts))
+		return fmt.Errorf("cannot queue %d alerts for sending to %q, since the queue is full; "+
+			"increase -notifier.queueSize if the service is slow", len(alerts), an.nt.Addr())
+	}
+}
+
+// filterAlerts returns alerts, which changed their state since the last notification
+// or which must be re-sent according to -notifier.repeatInterval.
+func (an *asyncNotifier) filterAlerts(alerts []Alert, now time.Time) []Alert {
+	an.mu.Lock()
+	defer an.mu.Unlock()
+
+	var result []Alert
+	for _, a := range alerts {
+		key := alertKey(a)
+		firing := a.State == StateFiring
+		sa := an.sent[key]
+		if sa == nil {
+			sa = &sentAlert{}
+			an.sent[key] = sa
+		} else if sa.firing == firing && (!firing || now.Sub(sa.sentAt) < *repeatInterval) {
+			sa.lastSeen = now
+			continue
+		}
+		sa.firing = firing
+		sa.sentAt = now
+		sa.lastSeen = now
+		result = append(result, a)
+	}
+	return result
+}
+
+// forgetAlerts removes the last notifications for the given alerts.
+func (an *asyncNotifier) forgetAlerts(alerts []Alert) {
+	an.mu.Lock()
+	for _, a := range alerts {
+		delete(an.sent, alertKey(a))
+	}
+	an.mu.Unlock()
+}
+
+// removeStaleAlerts removes the last notifications for alerts, which weren't seen since the deadline.
+func (an *asyncNotifier) removeStaleAlerts(deadline time.Time) {
+	an.mu.Lock()
+	for key, sa := range an.sent {
+		if sa.lastSeen.Before(deadline) {
+			delete(an.sent, key)
+		}
+	}
+	an.mu.Unlock()
+}
+
+func (an *asyncNotifier) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-an.stopCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-an.stopCh:
+			return
+		case <-ticker.C:
+			an.removeStaleAlerts(time.Now().Add(-*repeatInterval))
+		case alerts := <-an.queue:
+			if err := an.nt.Send(ctx, alerts); err != nil {
+				// Forget the alerts, so they are queued again on the next rule evaluation.
+				an.forgetAlerts(alerts)
+				an.sendErrors.Inc()
+				logger.Errorf("cannot send %d alerts to %q: %s", len(alerts), an.nt.Addr(), err)
+			}
+		}
+	}
+}
+
+// stop stops sending alerts. Queued alerts are dropped.
+func (an *asyncNotifier) stop() {
+	close(an.stopCh)
+	an.wg.Wait()
+}
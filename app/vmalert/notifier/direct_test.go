@@ -0,0 +1,173 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeDirectNotifier struct {
+	mu     sync.Mutex
+	alerts [][]Alert
+	// blockCh blocks Send until it is closed if set.
+	blockCh chan struct{}
+}
+
+func (fn *fakeDirectNotifier) Addr() string { return "fake" }
+
+func (fn *fakeDirectNotifier) Send(_ context.Context, alerts []Alert) error {
+	if fn.blockCh != nil {
+		<-fn.blockCh
+	}
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	fn.alerts = append(fn.alerts, alerts)
+	return nil
+}
+
+func (fn *fakeDirectNotifier) batches() int {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	return len(fn.alerts)
+}
+
+func TestAsyncNotifierFilterAlerts(t *testing.T) {
+	an := &asyncNotifier{
+		sent: make(map[string]*sentAlert),
+	}
+	f := func(alerts []Alert, ts time.Time, expNames ...string) {
+		t.Helper()
+		var names []string
+		for _, a := range an.filterAlerts(alerts, ts) {
+			names = append(names, a.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expNames) {
+			t.Fatalf("unexpected alerts to send; got %v; want %v", names, expNames)
+		}
+	}
+	start := time.Now()
+	firing := []Alert{{Name: "foo", State: StateFiring}, {Name: "bar", State: StateFiring}}
+	f(firing, start, "foo", "bar")
+	// firing alerts aren't re-sent until -notifier.repeatInterval
+	f(firing, start.Add(time.Minute))
+	f(firing, start.Add(*repeatInterval), "foo", "bar")
+
+	// state change must be sent only once
+	resolved := []Alert{{Name: "foo", State: StateInactive}, {Name: "bar", State: StateFiring}}
+	f(resolved, start.Add(*repeatInterval+time.Minute), "foo")
+	f(resolved, start.Add(*repeatInterval+2*time.Minute))
+
+	// forgotten alerts must be sent again
+	an.forgetAlerts(resolved[:1])
+	f(resolved, start.Add(*repeatInterval+3*time.Minute), "foo")
+
+	// stale alerts must be removed
+	an.removeStaleAlerts(start.Add(*repeatInterval + time.Hour))
+	if len(an.sent) != 0 {
+		t.Fatalf("expecting all the alerts to be removed; got %d alerts", len(an.sent))
+	}
+}
+
+func TestAsyncNotifierSend(t *testing.T) {
+	defer func(n int) { *queueSize = n }(*queueSize)
+	*queueSize = 1
+
+	fn := &fakeDirectNotifier{
+		blockCh: make(chan struct{}),
+	}
+	an := newAsyncNotifier(fn)
+	defer an.stop()
+
+	// The first batch is consumed by the blocked Send, the second one fills the queue.
+	if err := an.Send(context.Background(), []Alert{{Name: "foo", State: StateFiring}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(an.queue) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for the queue to be consumed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := an.Send(context.Background(), []Alert{{Name: "bar", State: StateFiring}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := an.Send(context.Background(), []Alert{{Name: "baz", State: StateFiring}}); err == nil {
+		t.Fatalf("expecting error when the queue is full")
+	}
+	// Already queued alerts mustn't be queued again.
+	if err := an.Send(context.Background(), []Alert{{Name: "foo", State: StateFiring}, {Name: "bar", State: StateFiring}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	close(fn.blockCh)
+	for fn.batches() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for alerts to be sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// The dropped alert must be queued on the next attempt.
+	if err := an.Send(context.Background(), []Alert{{Name: "baz", State: StateFiring}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for fn.batches() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for alerts to be sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := fn.batches(); n != 3 {
+		t.Fatalf("unexpected number of sent batches; got %d; want 3", n)
+	}
+}
+
+func TestPayloadTemplateReload(t *testing.T) {
+	defer SnapshotTemplates()()
+
+	dir, err := ioutil.TempDir("", "TestPayloadTemplateReload")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "tpl")
+	load := func(text string) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatalf("cannot write template file: %s", err)
+		}
+		if err := LoadTemplates([]string{path}); err != nil {
+			t.Fatalf("cannot load templates: %s", err)
+		}
+	}
+	f := func(pt *payloadTemplate, exp string) {
+		t.Helper()
+		s, err := pt.exec(nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if s != exp {
+			t.Fatalf("unexpected result; got %q; want %q", s, exp)
+		}
+	}
+
+	load(`{{ define "name" }}foo{{ end }}`)
+	pt, err := newPayloadTemplate("test template", `{{ template "name" }}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f(pt, "foo")
+	tpl := pt.tpl
+	f(pt, "foo")
+	if pt.tpl != tpl {
+		t.Fatalf("the template mustn't be parsed again until templates reload")
+	}
+
+	load(`{{ define "name" }}bar{{ end }}`)
+	f(pt, "bar")
+}
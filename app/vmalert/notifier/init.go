@@ -1,15 +1,24 @@
 package notifier
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 )
 
 var (
-	addrs             = flagutil.NewArray("notifier.url", "Prometheus alertmanager URL. Required parameter. e.g. http://127.0.0.1:9093")
+	configPath = flag.String("notifier.config", "", "Path to configuration file for notifiers. "+
+		"The file may contain static Alertmanager addresses and settings for discovering Alertmanager instances via Consul, DNS or Kubernetes. "+
+		"The file is re-read on SIGHUP signal. See https://docs.victoriametrics.com/vmalert.html#notifier-configuration-file")
+	addrs             = flagutil.NewArray("notifier.url", "Prometheus alertmanager URL, e.g. http://127.0.0.1:9093. Required parameter if neither -notifier.config nor direct notifiers are set. See https://docs.victoriametrics.com/vmalert.html#direct-notifiers")
 	basicAuthUsername = flagutil.NewArray("notifier.basicAuth.username", "Optional basic auth username for -notifier.url")
 	basicAuthPassword = flagutil.NewArray("notifier.basicAuth.password", "Optional basic auth password for -notifier.url")
 
@@ -20,12 +29,104 @@ var (
 		"By default system CA is used")
 	tlsServerName = flagutil.NewArray("notifier.tlsServerName", "Optional TLS server name to use for connections to -notifier.url. "+
 		"By default the server name from -notifier.url is used")
+
+	alertRelabelConfigPath = flag.String("notifier.alertRelabelConfig", "", "Optional path to a file with relabeling rules, which are applied to all the alerts "+
+		"before sending them to notifiers. See https://docs.victoriametrics.com/vmalert.html#alerts-relabeling")
+	urlAlertRelabelConfigPaths = flagutil.NewArray("notifier.urlAlertRelabelConfig", "Optional path to a file with relabeling rules, which are applied to alerts "+
+		"before sending them to the corresponding -notifier.url. The rules are applied after -notifier.alertRelabelConfig. "+
+		"See https://docs.victoriametrics.com/vmalert.html#alerts-relabeling")
+)
+
+var (
+	webhookURLs = flagutil.NewArray("notifier.webhook.url", "Webhook URL for sending alerts directly without Alertmanager, e.g. http://127.0.0.1:8080/alerts. "+
+		"See https://docs.victoriametrics.com/vmalert.html#direct-notifiers")
+	webhookTemplatePaths = flagutil.NewArray("notifier.webhook.template", "Optional path to a file with template for the request body sent to the corresponding -notifier.webhook.url. "+
+		"By default alerts are sent in the same format as to Alertmanager")
+	webhookBearerTokens = flagutil.NewArray("notifier.webhook.bearerToken", "Optional bearer auth token for the corresponding -notifier.webhook.url")
+
+	pagerDutyRoutingKeys = flagutil.NewArray("notifier.pagerduty.routingKey", "Integration key for sending alerts directly to PagerDuty via Events API v2. "+
+		"See https://docs.victoriametrics.com/vmalert.html#direct-notifiers")
+	pagerDutyURL             = flag.String("notifier.pagerduty.url", "https://events.pagerduty.com/v2/enqueue", "PagerDuty Events API v2 URL for sending alerts for -notifier.pagerduty.routingKey")
+	pagerDutySummaryTemplate = flag.String("notifier.pagerduty.summaryTemplate", "{{ .Name }}{{ with .Annotations.summary }}: {{ . }}{{ end }}",
+		"Template for the summary of PagerDuty events")
+
+	opsGenieAPIKeys = flagutil.NewArray("notifier.opsgenie.apiKey", "API key for sending alerts directly to OpsGenie via Alert API. "+
+		"See https://docs.victoriametrics.com/vmalert.html#direct-notifiers")
+	opsGenieURL = flag.String("notifier.opsgenie.url", "https://api.opsgenie.com/v2/alerts", "OpsGenie Alert API URL for sending alerts for -notifier.opsgenie.apiKey. "+
+		"Use https://api.eu.opsgenie.com/v2/alerts for OpsGenie accounts in EU")
+	opsGenieMessageTemplate = flag.String("notifier.opsgenie.messageTemplate", "{{ .Name }}{{ with .Annotations.summary }}: {{ . }}{{ end }}",
+		"Template for the message of OpsGenie alerts")
+	opsGenieDescriptionTemplate = flag.String("notifier.opsgenie.descriptionTemplate", "{{ .Annotations.description }}",
+		"Template for the description of OpsGenie alerts")
+
+	maxRetries = flag.Int("notifier.maxRetries", 3, "The maximum number of retries for failed requests to -notifier.webhook.url, PagerDuty and OpsGenie. "+
+		"Requests are retried on network errors, 429 and 5xx responses. Alerts are sent again on the next rule evaluation anyway")
+	retryMinInterval = flag.Duration("notifier.retryMinInterval", time.Second, "The minimum delay between retries of failed requests to -notifier.webhook.url, PagerDuty and OpsGenie. "+
+		"The delay is doubled after every retry")
+	retryMaxInterval = flag.Duration("notifier.retryMaxInterval", 10*time.Second, "The maximum delay between retries of failed requests to -notifier.webhook.url, PagerDuty and OpsGenie")
+	sendTimeout      = flag.Duration("notifier.timeout", 10*time.Second, "Timeout for a single request to -notifier.webhook.url, PagerDuty and OpsGenie")
+	queueSize        = flag.Int("notifier.queueSize", 100, "The maximum number of pending notifications for every -notifier.webhook.url, PagerDuty and OpsGenie destination. "+
+		"Every rule evaluation results in a single notification. Notifications are dropped if the queue is full")
+	repeatInterval = flag.Duration("notifier.repeatInterval", 4*time.Hour, "How often to re-send firing alerts to -notifier.webhook.url, PagerDuty and OpsGenie. "+
+		"Alerts are sent to these destinations only on state change or every -notifier.repeatInterval")
 )
 
-// Init creates a Notifier object based on provided flags.
-func Init(gen AlertURLGenerator) ([]Notifier, error) {
-	if len(*addrs) == 0 {
-		return nil, fmt.Errorf("at least one `-notifier.url` must be set")
+var (
+	cwMu sync.Mutex
+	// cw is non-nil if notifiers are configured via -notifier.config.
+	cw *configWatcher
+
+	// asyncNotifiers contains direct notifiers, which must be stopped in Stop.
+	asyncNotifiers []*asyncNotifier
+)
+
+// Init creates Notifier objects based on provided flags.
+//
+// It returns a function for obtaining the current list of notifiers,
+// since notifiers configured via -notifier.config may change over time.
+func Init(gen AlertURLGenerator) (func() []Notifier, error) {
+	if *alertRelabelConfigPath != "" {
+		pcs, err := promrelabel.LoadRelabelConfigs(*alertRelabelConfigPath, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load `-notifier.alertRelabelConfig`: %w", err)
+		}
+		alertRelabelConfigs = pcs
+	}
+	direct, err := initDirectNotifiers(gen)
+	if err != nil {
+		return nil, err
+	}
+	for i, nt := range direct {
+		// Direct notifiers retry failed requests, so they send alerts in background
+		// in order to avoid delaying rules evaluation.
+		an := newAsyncNotifier(nt)
+		asyncNotifiers = append(asyncNotifiers, an)
+		direct[i] = an
+	}
+	if *configPath != "" {
+		if len(*addrs) > 0 {
+			return nil, fmt.Errorf("only one of `-notifier.config` or `-notifier.url` flags can be set")
+		}
+		cfg, err := parseConfig(*configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `-notifier.config`: %w", err)
+		}
+		cwMu.Lock()
+		cw = newConfigWatcher(cfg, gen)
+		cwMu.Unlock()
+		if len(direct) == 0 {
+			return getNotifiers, nil
+		}
+		return func() []Notifier {
+			discovered := getNotifiers()
+			notifiers := make([]Notifier, 0, len(discovered)+len(direct))
+			notifiers = append(notifiers, discovered...)
+			return append(notifiers, direct...)
+		}, nil
+	}
+	if len(*addrs) == 0 && len(direct) == 0 {
+		return nil, fmt.Errorf("at least one `-notifier.url`, `-notifier.config`, `-notifier.webhook.url`, " +
+			"`-notifier.pagerduty.routingKey` or `-notifier.opsgenie.apiKey` must be set")
 	}
 
 	var notifiers []Notifier
@@ -38,8 +139,113 @@ func Init(gen AlertURLGenerator) ([]Notifier, error) {
 		}
 		user, pass := basicAuthUsername.GetOptionalArg(i), basicAuthPassword.GetOptionalArg(i)
 		am := NewAlertManager(addr, user, pass, gen, &http.Client{Transport: tr})
+		if path := urlAlertRelabelConfigPaths.GetOptionalArg(i); path != "" {
+			pcs, err := promrelabel.LoadRelabelConfigs(path, false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load `-notifier.urlAlertRelabelConfig` for %q: %w", addr, err)
+			}
+			am.relabelConfigs = pcs
+		}
 		notifiers = append(notifiers, am)
 	}
+	notifiers = append(notifiers, direct...)
 
+	return func() []Notifier { return notifiers }, nil
+}
+
+// initDirectNotifiers creates notifiers, which send alerts directly
+// to webhooks, PagerDuty and OpsGenie without Alertmanager.
+func initDirectNotifiers(gen AlertURLGenerator) ([]Notifier, error) {
+	if *queueSize <= 0 {
+		return nil, fmt.Errorf("`-notifier.queueSize` must be positive; got %d", *queueSize)
+	}
+	if *repeatInterval <= 0 {
+		return nil, fmt.Errorf("`-notifier.repeatInterval` must be positive; got %s", *repeatInterval)
+	}
+	var notifiers []Notifier
+	for i, addr := range *webhookURLs {
+		var tplText string
+		if path := webhookTemplatePaths.GetOptionalArg(i); path != "" {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read `-notifier.webhook.template` for %q: %w", addr, err)
+			}
+			tplText = string(data)
+		}
+		wh, err := NewWebhook(addr, tplText, webhookBearerTokens.GetOptionalArg(i), gen, &http.Client{Timeout: *sendTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("failed to init webhook notifier for %q: %w", addr, err)
+		}
+		notifiers = append(notifiers, wh)
+	}
+	for _, key := range *pagerDutyRoutingKeys {
+		pd, err := NewPagerDuty(*pagerDutyURL, key, *pagerDutySummaryTemplate, gen, &http.Client{Timeout: *sendTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("failed to init PagerDuty notifier: %w", err)
+		}
+		notifiers = append(notifiers, pd)
+	}
+	for _, key := range *opsGenieAPIKeys {
+		og, err := NewOpsGenie(*opsGenieURL, key, *opsGenieMessageTemplate, *opsGenieDescriptionTemplate, gen, &http.Client{Timeout: *sendTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("failed to init OpsGenie notifier: %w", err)
+		}
+		notifiers = append(notifiers, og)
+	}
 	return notifiers, nil
 }
+
+func getNotifiers() []Notifier {
+	cwMu.Lock()
+	w := cw
+	cwMu.Unlock()
+	if w == nil {
+		return nil
+	}
+	return w.getNotifiers()
+}
+
+// Reload re-reads -notifier.config and restarts discovery of Alertmanager instances if the config has been changed.
+//
+// It does nothing if -notifier.config isn't set.
+func Reload() error {
+	if *configPath == "" {
+		return nil
+	}
+	cwMu.Lock()
+	cwOld := cw
+	cwMu.Unlock()
+	if cwOld == nil {
+		return nil
+	}
+	cfg, err := parseConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to parse `-notifier.config`: %w", err)
+	}
+	if bytes.Equal(cfg.data, cwOld.cfg.data) {
+		return nil
+	}
+	// Start the new watcher before stopping the old one,
+	// so alerts are delivered to the previously discovered targets during the reload.
+	cwNew := newConfigWatcher(cfg, cwOld.gen)
+	cwMu.Lock()
+	cw = cwNew
+	cwMu.Unlock()
+	cwOld.stop()
+	return nil
+}
+
+// Stop stops discovery of Alertmanager instances configured via -notifier.config
+// and sending alerts to direct notifiers.
+func Stop() {
+	cwMu.Lock()
+	defer cwMu.Unlock()
+	if cw != nil {
+		cw.stop()
+		cw = nil
+	}
+	for _, an := range asyncNotifiers {
+		an.stop()
+	}
+	asyncNotifiers = nil
+}
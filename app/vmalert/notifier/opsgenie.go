@@ -0,0 +1,133 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+)
+
+// OpsGenie sends alerts directly to OpsGenie via Alert API
+// https://docs.opsgenie.com/docs/alert-api
+type OpsGenie struct {
+	addr           string
+	argFunc        AlertURLGenerator
+	client         *http.Client
+	header         http.Header
+	messageTpl     *payloadTemplate
+	descriptionTpl *payloadTemplate
+}
+
+// opsGenieAlert is a request for creating OpsGenie alert.
+type opsGenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+	Source      string            `json:"source"`
+}
+
+// opsGenieClose is a request for closing OpsGenie alert.
+type opsGenieClose struct {
+	Source string `json:"source"`
+}
+
+// Max lengths of fields accepted by OpsGenie.
+const (
+	opsGenieMessageMaxLen     = 130
+	opsGenieDescriptionMaxLen = 15000
+)
+
+// Addr returns address where alerts are sent.
+func (og *OpsGenie) Addr() string { return og.addr }
+
+// Send creates OpsGenie alerts for firing alerts and closes them for resolved alerts.
+// Every alert is sent in a separate request. Repeated notifications for the same alert
+// are deduplicated by OpsGenie via alert alias.
+func (og *OpsGenie) Send(ctx context.Context, alerts []Alert) error {
+	alerts = relabelAlerts(alerts, alertRelabelConfigs)
+	eg := new(utils.ErrGroup)
+	for _, a := range alerts {
+		reqURL, body, err := og.newRequest(a)
+		if err != nil {
+			eg.Add(fmt.Errorf("cannot create OpsGenie request for alert %q: %w", a.Name, err))
+			continue
+		}
+		if err := sendWithRetry(ctx, og.client, reqURL, og.header, body); err != nil {
+			eg.Add(err)
+		}
+	}
+	return eg.Err()
+}
+
+func (og *OpsGenie) newRequest(a Alert) (string, []byte, error) {
+	pa := newPayloadAlert(a, og.argFunc)
+	alias := alertKey(a)
+	if pa.Status != "firing" {
+		reqURL := fmt.Sprintf("%s/%s/close?identifierType=alias", og.addr, url.PathEscape(alias))
+		body, err := json.Marshal(opsGenieClose{Source: "vmalert"})
+		return reqURL, body, err
+	}
+	message, err := og.messageTpl.exec(pa)
+	if err != nil {
+		return "", nil, err
+	}
+	description, err := og.descriptionTpl.exec(pa)
+	if err != nil {
+		return "", nil, err
+	}
+	details := make(map[string]string, len(a.Labels)+2)
+	for k, v := range a.Labels {
+		details[k] = v
+	}
+	details["alertname"] = a.Name
+	if pa.GeneratorURL != "" {
+		details["generatorURL"] = pa.GeneratorURL
+	}
+	body, err := json.Marshal(opsGenieAlert{
+		Message:     truncate(message, opsGenieMessageMaxLen),
+		Alias:       alias,
+		Description: truncate(description, opsGenieDescriptionMaxLen),
+		Details:     details,
+		Priority:    opsGeniePriority(a.Labels["priority"]),
+		Source:      "vmalert",
+	})
+	return og.addr, body, err
+}
+
+// opsGeniePriority returns OpsGenie priority for the given `priority` label value.
+// Empty string is returned for unsupported values, so OpsGenie applies the default priority.
+func opsGeniePriority(p string) string {
+	switch p = strings.ToUpper(p); p {
+	case "P1", "P2", "P3", "P4", "P5":
+		return p
+	}
+	return ""
+}
+
+// NewOpsGenie is a constructor for OpsGenie.
+func NewOpsGenie(addr, apiKey, messageTpl, descriptionTpl string, fn AlertURLGenerator, c *http.Client) (*OpsGenie, error) {
+	mt, err := newPayloadTemplate("OpsGenie message template", messageTpl)
+	if err != nil {
+		return nil, err
+	}
+	dt, err := newPayloadTemplate("OpsGenie description template", descriptionTpl)
+	if err != nil {
+		return nil, err
+	}
+	og := &OpsGenie{
+		addr:           strings.TrimSuffix(addr, "/"),
+		argFunc:        fn,
+		client:         c,
+		header:         make(http.Header),
+		messageTpl:     mt,
+		descriptionTpl: dt,
+	}
+	og.header.Set("Authorization", "GenieKey "+apiKey)
+	return og, nil
+}
@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpsGenie_Send(t *testing.T) {
+	var created []opsGenieAlert
+	var closed []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/alerts", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "GenieKey key" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		var a opsGenieAlert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("cannot unmarshal request body: %s", err)
+			return
+		}
+		created = append(created, a)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("/v2/alerts/", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("identifierType"); got != "alias" {
+			t.Errorf("unexpected identifierType %q", got)
+		}
+		closed = append(closed, r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	og, err := NewOpsGenie(srv.URL+"/v2/alerts/", "key", "{{ .Name }}: {{ .Annotations.summary }}", "{{ .Annotations.description }}", nil, srv.Client())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	alerts := []Alert{
+		{
+			Name:        "HighLatency",
+			GroupID:     1,
+			ID:          2,
+			State:       StateFiring,
+			Labels:      map[string]string{"job": "api", "priority": "p2"},
+			Annotations: map[string]string{"summary": "latency is high", "description": "details"},
+		},
+		{
+			Name:    "Down",
+			GroupID: 1,
+			ID:      3,
+			State:   StateInactive,
+		},
+	}
+	if err := og.Send(context.Background(), alerts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(created) != 1 {
+		t.Fatalf("expected 1 created alert; got %d", len(created))
+	}
+	a := created[0]
+	if a.Message != "HighLatency: latency is high" || a.Description != "details" || a.Alias != "HighLatency-1-2" {
+		t.Fatalf("unexpected alert: %+v", a)
+	}
+	if a.Priority != "P2" || a.Source != "vmalert" || a.Details["job"] != "api" || a.Details["alertname"] != "HighLatency" {
+		t.Fatalf("unexpected alert: %+v", a)
+	}
+	if len(closed) != 1 || closed[0] != "/v2/alerts/Down-1-3/close" {
+		t.Fatalf("unexpected closed alerts: %v", closed)
+	}
+}
@@ -0,0 +1,130 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+)
+
+// PagerDuty sends alerts directly to PagerDuty via Events API v2
+// https://developer.pagerduty.com/docs/events-api-v2/overview/
+type PagerDuty struct {
+	addr       string
+	routingKey string
+	argFunc    AlertURLGenerator
+	client     *http.Client
+	summaryTpl *payloadTemplate
+}
+
+// pagerDutyEvent is an event for PagerDuty Events API v2.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// pagerDutySummaryMaxLen is the max length of the event summary accepted by PagerDuty.
+const pagerDutySummaryMaxLen = 1024
+
+// Addr returns address where alerts are sent.
+func (pd *PagerDuty) Addr() string { return pd.addr }
+
+// Send sends every alert as a separate event.
+// Firing alerts trigger incidents, while resolved alerts resolve them.
+func (pd *PagerDuty) Send(ctx context.Context, alerts []Alert) error {
+	alerts = relabelAlerts(alerts, alertRelabelConfigs)
+	eg := new(utils.ErrGroup)
+	for _, a := range alerts {
+		body, err := pd.newEvent(a)
+		if err != nil {
+			eg.Add(fmt.Errorf("cannot create PagerDuty event for alert %q: %w", a.Name, err))
+			continue
+		}
+		if err := sendWithRetry(ctx, pd.client, pd.addr, nil, body); err != nil {
+			eg.Add(err)
+		}
+	}
+	return eg.Err()
+}
+
+func (pd *PagerDuty) newEvent(a Alert) ([]byte, error) {
+	pa := newPayloadAlert(a, pd.argFunc)
+	e := pagerDutyEvent{
+		RoutingKey:  pd.routingKey,
+		EventAction: "resolve",
+		DedupKey:    alertKey(a),
+	}
+	if pa.Status == "firing" {
+		summary, err := pd.summaryTpl.exec(pa)
+		if err != nil {
+			return nil, err
+		}
+		source := a.Labels["instance"]
+		if source == "" {
+			source = "vmalert"
+		}
+		details := make(map[string]string, len(a.Labels)+len(a.Annotations))
+		for k, v := range a.Annotations {
+			details[k] = v
+		}
+		for k, v := range a.Labels {
+			details[k] = v
+		}
+		details["alertname"] = a.Name
+		e.EventAction = "trigger"
+		e.Payload = &pagerDutyPayload{
+			Summary:       truncate(summary, pagerDutySummaryMaxLen),
+			Source:        source,
+			Severity:      pagerDutySeverity(a.Labels["severity"]),
+			Timestamp:     a.Start.Format(time.RFC3339Nano),
+			CustomDetails: details,
+		}
+		if pa.GeneratorURL != "" {
+			e.Links = []pagerDutyLink{{Href: pa.GeneratorURL, Text: "vmalert"}}
+		}
+	}
+	return json.Marshal(e)
+}
+
+// pagerDutySeverity returns PagerDuty severity for the given `severity` label value.
+func pagerDutySeverity(s string) string {
+	switch s {
+	case "critical", "error", "warning", "info":
+		return s
+	}
+	return "error"
+}
+
+// NewPagerDuty is a constructor for PagerDuty.
+func NewPagerDuty(addr, routingKey, summaryTpl string, fn AlertURLGenerator, c *http.Client) (*PagerDuty, error) {
+	tpl, err := newPayloadTemplate("PagerDuty summary template", summaryTpl)
+	if err != nil {
+		return nil, err
+	}
+	return &PagerDuty{
+		addr:       addr,
+		routingKey: routingKey,
+		argFunc:    fn,
+		client:     c,
+		summaryTpl: tpl,
+	}, nil
+}
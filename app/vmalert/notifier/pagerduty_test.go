@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPagerDuty_Send(t *testing.T) {
+	var events []pagerDutyEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e pagerDutyEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("cannot unmarshal request body: %s", err)
+			return
+		}
+		events = append(events, e)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	pd, err := NewPagerDuty(srv.URL, "key", "{{ .Name }} on {{ .Labels.instance }}",
+		func(alert Alert) string { return "http://vmalert/" + alert.Name }, srv.Client())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	alerts := []Alert{
+		{
+			Name:        "HighLatency",
+			GroupID:     1,
+			ID:          2,
+			State:       StateFiring,
+			Labels:      map[string]string{"instance": "host:80", "severity": "warning"},
+			Annotations: map[string]string{"summary": "latency is high"},
+		},
+		{
+			Name:    "Down",
+			GroupID: 1,
+			ID:      3,
+			State:   StateInactive,
+		},
+	}
+	if err := pd.Send(context.Background(), alerts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events; got %d", len(events))
+	}
+
+	trigger := events[0]
+	if trigger.RoutingKey != "key" || trigger.EventAction != "trigger" || trigger.DedupKey != "HighLatency-1-2" {
+		t.Fatalf("unexpected trigger event: %+v", trigger)
+	}
+	p := trigger.Payload
+	if p == nil {
+		t.Fatalf("expected non-empty payload for trigger event")
+	}
+	if p.Summary != "HighLatency on host:80" || p.Source != "host:80" || p.Severity != "warning" {
+		t.Fatalf("unexpected trigger payload: %+v", p)
+	}
+	if p.CustomDetails["summary"] != "latency is high" || p.CustomDetails["alertname"] != "HighLatency" {
+		t.Fatalf("unexpected custom details: %v", p.CustomDetails)
+	}
+	if len(trigger.Links) != 1 || trigger.Links[0].Href != "http://vmalert/HighLatency" {
+		t.Fatalf("unexpected links: %v", trigger.Links)
+	}
+
+	resolve := events[1]
+	if resolve.EventAction != "resolve" || resolve.DedupKey != "Down-1-3" || resolve.Payload != nil {
+		t.Fatalf("unexpected resolve event: %+v", resolve)
+	}
+}
+
+func TestPagerDutySeverity(t *testing.T) {
+	f := func(label, exp string) {
+		t.Helper()
+		if got := pagerDutySeverity(label); got != exp {
+			t.Fatalf("unexpected severity for %q; got %q; want %q", label, got, exp)
+		}
+	}
+	f("critical", "critical")
+	f("info", "info")
+	f("page", "error")
+	f("", "error")
+}
@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+// alertRelabelConfigs contains relabeling rules from -notifier.alertRelabelConfig.
+//
+// They are applied to alerts before sending them to all the notifiers.
+var alertRelabelConfigs *promrelabel.ParsedConfigs
+
+// relabelAlerts applies pcs to labels of the given alerts and returns the result.
+//
+// Alert name is available during relabeling via `alertname` label.
+// Alerts with all the labels removed are dropped. The given alerts aren't modified.
+func relabelAlerts(alerts []Alert, pcs *promrelabel.ParsedConfigs) []Alert {
+	if pcs.Len() == 0 {
+		return alerts
+	}
+	result := make([]Alert, 0, len(alerts))
+	var labels []prompbmarshal.Label
+	for _, a := range alerts {
+		labels = labels[:0]
+		for name, value := range a.Labels {
+			labels = append(labels, prompbmarshal.Label{
+				Name:  name,
+				Value: value,
+			})
+		}
+		labels = append(labels, prompbmarshal.Label{
+			Name:  "alertname",
+			Value: a.Name,
+		})
+		labels = pcs.Apply(labels, 0, false)
+		if len(labels) == 0 {
+			continue
+		}
+		a.Name = ""
+		a.Labels = make(map[string]string, len(labels))
+		for _, label := range labels {
+			if label.Name == "alertname" {
+				a.Name = label.Value
+				continue
+			}
+			a.Labels[label.Name] = label.Value
+		}
+		result = append(result, a)
+	}
+	return result
+}
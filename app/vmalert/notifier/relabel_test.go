@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+func TestRelabelAlerts(t *testing.T) {
+	f := func(config string, alerts, expected []Alert) {
+		t.Helper()
+		pcs, err := promrelabel.ParseRelabelConfigsData([]byte(config), false)
+		if err != nil {
+			t.Fatalf("cannot parse relabel configs: %s", err)
+		}
+		result := relabelAlerts(alerts, pcs)
+		if len(result) == 0 && len(expected) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Fatalf("unexpected result;\ngot\n%+v\nwant\n%+v", result, expected)
+		}
+	}
+	alerts := []Alert{
+		{
+			Name:   "HighLatency",
+			Labels: map[string]string{"job": "api", "severity": "warning", "replica": "a"},
+		},
+		{
+			Name:   "Watchdog",
+			Labels: map[string]string{"severity": "none"},
+		},
+	}
+
+	// no relabeling
+	f("", alerts, alerts)
+
+	// drop and rename labels
+	f(`
+- action: labeldrop
+  regex: replica
+- source_labels: [severity]
+  target_label: priority
+- action: labeldrop
+  regex: severity
+`, alerts, []Alert{
+		{
+			Name:   "HighLatency",
+			Labels: map[string]string{"job": "api", "priority": "warning"},
+		},
+		{
+			Name:   "Watchdog",
+			Labels: map[string]string{"priority": "none"},
+		},
+	})
+
+	// drop alerts by name and add routing label
+	f(`
+- source_labels: [alertname]
+  regex: Watchdog
+  action: drop
+- source_labels: [job]
+  regex: api
+  target_label: team
+  replacement: backend
+`, alerts, []Alert{
+		{
+			Name:   "HighLatency",
+			Labels: map[string]string{"job": "api", "severity": "warning", "replica": "a", "team": "backend"},
+		},
+	})
+
+	// rename alert
+	f(`
+- source_labels: [alertname]
+  regex: Watchdog
+  target_label: alertname
+  replacement: DeadMansSwitch
+`, alerts[1:], []Alert{
+		{
+			Name:   "DeadMansSwitch",
+			Labels: map[string]string{"severity": "none"},
+		},
+	})
+
+	// drop all the alerts
+	f(`
+- action: drop
+  source_labels: [alertname]
+  regex: ".+"
+`, alerts, nil)
+
+	// the original alerts must stay unchanged
+	if len(alerts[0].Labels) != 3 || alerts[1].Name != "Watchdog" {
+		t.Fatalf("original alerts have been modified: %+v", alerts)
+	}
+}
@@ -0,0 +1,91 @@
+package notifier
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+var (
+	masterTmplMu sync.RWMutex
+	// masterTmpl contains named templates loaded via LoadTemplates.
+	// These templates may be referred from annotations via {{ template "name" . }}
+	masterTmpl *template.Template
+)
+
+// LoadTemplates loads named templates from files matching the given path patterns.
+// The loaded templates replace the previously loaded ones only if all the files
+// were parsed successfully, so it is safe to call LoadTemplates on config reload.
+//
+// InitTemplateFunc must be called before LoadTemplates.
+func LoadTemplates(pathPatterns []string) error {
+	tmpl := template.New("").Funcs(tmplFunc).Option("missingkey=zero")
+	var files []string
+	for _, pattern := range pathPatterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid template path pattern %q: %w", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("cannot read template file %q: %w", file, err)
+		}
+		if _, err := tmpl.New(file).Parse(string(data)); err != nil {
+			return fmt.Errorf("cannot parse template file %q: %w", file, err)
+		}
+	}
+	masterTmplMu.Lock()
+	masterTmpl = tmpl
+	masterTmplMu.Unlock()
+	return nil
+}
+
+// SnapshotTemplates returns a function, which restores the templates
+// loaded at the moment of SnapshotTemplates call.
+// It may be used for rolling back LoadTemplates if rules,
+// which refer to the loaded templates, turn out to be invalid.
+func SnapshotTemplates() func() {
+	masterTmplMu.RLock()
+	tmpl := masterTmpl
+	masterTmplMu.RUnlock()
+	return func() {
+		masterTmplMu.Lock()
+		masterTmpl = tmpl
+		masterTmplMu.Unlock()
+	}
+}
+
+// getMasterTemplate returns named templates loaded via LoadTemplates.
+//
+// The returned template changes only on LoadTemplates call, so it may be used
+// for detecting whether templates depending on it must be re-parsed.
+func getMasterTemplate() *template.Template {
+	masterTmplMu.RLock()
+	defer masterTmplMu.RUnlock()
+	return masterTmpl
+}
+
+// newTemplate returns a new empty template, which has access
+// to named templates loaded via LoadTemplates.
+func newTemplate() (*template.Template, error) {
+	return newTemplateFrom(getMasterTemplate())
+}
+
+// newTemplateFrom returns a new empty template, which has access to named templates from master.
+func newTemplateFrom(master *template.Template) (*template.Template, error) {
+	if master == nil {
+		return template.New(""), nil
+	}
+	tmpl, err := master.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("cannot clone templates: %w", err)
+	}
+	return tmpl.New(""), nil
+}
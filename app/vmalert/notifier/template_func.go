@@ -14,6 +14,7 @@
 package notifier
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -231,6 +232,16 @@ func InitTemplateFunc(externalURL *url.URL) {
 			return strings.Replace(q, `"`, `\"`, -1)
 		},
 
+		// jsonEscape converts the string to a quoted JSON string.
+		// It is useful for building JSON payloads in webhook templates.
+		"jsonEscape": func(s string) (string, error) {
+			b, err := json.Marshal(s)
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		},
+
 		// query executes the MetricsQL/PromQL query against
 		// configured `datasource.url` address.
 		// For example, {{ query "foo" | first | value }} will
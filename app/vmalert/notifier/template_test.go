@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	defer func() {
+		if err := LoadTemplates(nil); err != nil {
+			t.Fatalf("cannot reset templates: %s", err)
+		}
+	}()
+
+	if err := LoadTemplates([]string{"testdata/templates/*.tpl"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	annotations := map[string]string{
+		"runbook": `{{ template "runbook.url" . }}`,
+		"summary": `Value is {{ template "summary.value" . }}`,
+	}
+	data := AlertTplData{
+		Labels: map[string]string{"job": "api", "alertname": "HighLatency", "instance": "host:80"},
+		Value:  1500,
+	}
+	result, err := ExecTemplate(nil, annotations, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if exp := "https://runbooks.example.com/api/highlatency"; result["runbook"] != exp {
+		t.Fatalf("unexpected runbook annotation; got %q; want %q", result["runbook"], exp)
+	}
+	if exp := "Value is 1.5k on host:80"; result["summary"] != exp {
+		t.Fatalf("unexpected summary annotation; got %q; want %q", result["summary"], exp)
+	}
+	if err := ValidateTemplates(annotations); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+
+	// templates must stay unchanged if loading fails
+	err = LoadTemplates([]string{"testdata/templates/*.tpl", "testdata/templates/*.invalid"})
+	if err == nil || !strings.Contains(err.Error(), "bad.tpl.invalid") {
+		t.Fatalf("expecting error mentioning the bad file; got %v", err)
+	}
+	if err := ValidateTemplates(annotations); err != nil {
+		t.Fatalf("unexpected validation error after failed reload: %s", err)
+	}
+
+	// unknown templates must fail validation
+	restore := SnapshotTemplates()
+	if err := LoadTemplates(nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ValidateTemplates(annotations); err == nil {
+		t.Fatalf("expecting validation error for missing templates")
+	}
+
+	// templates must be available again after rolling back to the snapshot
+	restore()
+	if err := ValidateTemplates(annotations); err != nil {
+		t.Fatalf("unexpected validation error after restoring templates: %s", err)
+	}
+}
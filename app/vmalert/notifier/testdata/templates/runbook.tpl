@@ -0,0 +1 @@
+{{ define "runbook.url" }}https://runbooks.example.com/{{ .Labels.job }}/{{ .Labels.alertname | toLower }}{{ end }}
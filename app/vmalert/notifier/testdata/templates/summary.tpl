@@ -0,0 +1 @@
+{{ define "summary.value" }}{{ .Value | humanize }} on {{ .Labels.instance }}{{ end }}
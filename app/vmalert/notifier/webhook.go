@@ -0,0 +1,75 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+)
+
+// Webhook sends alerts directly to a generic webhook without Alertmanager.
+type Webhook struct {
+	addr    string
+	argFunc AlertURLGenerator
+	client  *http.Client
+	header  http.Header
+	// tpl is an optional template for the request body.
+	// Alerts are sent in Alertmanager-compatible format if tpl is nil.
+	tpl *payloadTemplate
+}
+
+// webhookTplData is passed to the Webhook payload template.
+type webhookTplData struct {
+	Alerts []payloadAlert
+}
+
+// Addr returns address where alerts are sent.
+func (wh *Webhook) Addr() string { return wh.addr }
+
+// Send sends alerts to the webhook in a single request.
+func (wh *Webhook) Send(ctx context.Context, alerts []Alert) error {
+	alerts = relabelAlerts(alerts, alertRelabelConfigs)
+	if len(alerts) == 0 {
+		return nil
+	}
+	b := &bytes.Buffer{}
+	if wh.tpl == nil {
+		writeamRequest(b, alerts, wh.argFunc)
+	} else {
+		data := webhookTplData{
+			Alerts: make([]payloadAlert, 0, len(alerts)),
+		}
+		for _, a := range alerts {
+			data.Alerts = append(data.Alerts, newPayloadAlert(a, wh.argFunc))
+		}
+		s, err := wh.tpl.exec(data)
+		if err != nil {
+			return err
+		}
+		b.WriteString(s)
+	}
+	return sendWithRetry(ctx, wh.client, wh.addr, wh.header, b.Bytes())
+}
+
+// NewWebhook is a constructor for Webhook.
+//
+// tplText is an optional template for the request body. If it is empty,
+// then alerts are sent in the same format as to Alertmanager.
+func NewWebhook(webhookURL, tplText, bearerToken string, fn AlertURLGenerator, c *http.Client) (*Webhook, error) {
+	wh := &Webhook{
+		addr:    webhookURL,
+		argFunc: fn,
+		client:  c,
+		header:  make(http.Header),
+	}
+	if tplText != "" {
+		tpl, err := newPayloadTemplate("webhook template", tplText)
+		if err != nil {
+			return nil, err
+		}
+		wh.tpl = tpl
+	}
+	if bearerToken != "" {
+		wh.header.Set("Authorization", "Bearer "+bearerToken)
+	}
+	return wh, nil
+}
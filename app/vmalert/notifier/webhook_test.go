@@ -0,0 +1,121 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook_Send(t *testing.T) {
+	defer func(min time.Duration) { *retryMinInterval = min }(*retryMinInterval)
+	*retryMinInterval = time.Millisecond
+
+	const token = "secret"
+	c := -1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer "+token {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		c++
+		switch c {
+		case 0:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 1:
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+		case 2:
+			var a []struct {
+				Labels       map[string]string `json:"labels"`
+				GeneratorURL string            `json:"generatorURL"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+				t.Errorf("cannot unmarshal request body: %s", err)
+				return
+			}
+			if len(a) != 1 || a[0].Labels["alertname"] != "alert0" || a[0].GeneratorURL != "alert0" {
+				t.Errorf("unexpected alerts in request: %v", a)
+			}
+		case 3:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	wh, err := NewWebhook(srv.URL, "", token, func(alert Alert) string { return alert.Name }, srv.Client())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	alerts := []Alert{{Name: "alert0", State: StateFiring}}
+	// the first two attempts fail and must be retried
+	if err := wh.Send(context.Background(), alerts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c != 2 {
+		t.Fatalf("expected 3 requests; got %d", c+1)
+	}
+	// 4xx responses must not be retried
+	if err := wh.Send(context.Background(), alerts); err == nil {
+		t.Fatalf("expected error for 400 response")
+	}
+	if c != 3 {
+		t.Fatalf("expected 4 requests; got %d", c+1)
+	}
+}
+
+func TestWebhook_SendRetriesExhausted(t *testing.T) {
+	defer func(min time.Duration, n int) { *retryMinInterval, *maxRetries = min, n }(*retryMinInterval, *maxRetries)
+	*retryMinInterval = time.Millisecond
+	*maxRetries = 2
+
+	c := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		c++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	wh, err := NewWebhook(srv.URL, "", "", func(alert Alert) string { return alert.Name }, srv.Client())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := wh.Send(context.Background(), []Alert{{Name: "alert0"}}); err == nil {
+		t.Fatalf("expected error after exhausting retries")
+	}
+	if c != 3 {
+		t.Fatalf("expected 3 requests; got %d", c)
+	}
+}
+
+func TestWebhook_SendTemplate(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	tpl := `{"text": {{ range .Alerts }}{{ printf "[%s] %s: %s" .Status .Name .Annotations.summary | jsonEscape }}{{ end }}}`
+	wh, err := NewWebhook(srv.URL, tpl, "", nil, srv.Client())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	alerts := []Alert{{
+		Name:        "HighLatency",
+		State:       StateFiring,
+		Annotations: map[string]string{"summary": `latency is "high"`},
+	}}
+	if err := wh.Send(context.Background(), alerts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	exp := `{"text": "[firing] HighLatency: latency is \"high\""}`
+	if string(body) != exp {
+		t.Fatalf("unexpected request body; got %s; want %s", body, exp)
+	}
+
+	if _, err := NewWebhook(srv.URL, "{{ .Alerts ", "", nil, srv.Client()); err == nil {
+		t.Fatalf("expected error for invalid template")
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/metrics"
 )
@@ -18,12 +19,15 @@ import (
 // to evaluate configured Expression and
 // return TimeSeries as result.
 type RecordingRule struct {
-	Type    datasource.Type
-	RuleID  uint64
-	Name    string
-	Expr    string
-	Labels  map[string]string
-	GroupID uint64
+	Type      datasource.Type
+	RuleID    uint64
+	Name      string
+	Expr      string
+	Labels    map[string]string
+	GroupID   uint64
+	GroupName string
+	Debug     bool
+	Limit     int
 
 	q datasource.Querier
 
@@ -38,6 +42,8 @@ type RecordingRule struct {
 	// stores the number of samples returned during
 	// the last evaluation
 	lastExecSamples int
+	// stores the duration of the last evaluation
+	lastExecDuration time.Duration
 
 	metrics *recordingRuleMetrics
 }
@@ -60,17 +66,24 @@ func (rr *RecordingRule) ID() uint64 {
 
 func newRecordingRule(qb datasource.QuerierBuilder, group *Group, cfg config.Rule) *RecordingRule {
 	rr := &RecordingRule{
-		Type:    cfg.Type,
-		RuleID:  cfg.ID,
-		Name:    cfg.Record,
-		Expr:    cfg.Expr,
-		Labels:  cfg.Labels,
-		GroupID: group.ID(),
-		metrics: &recordingRuleMetrics{},
+		Type:      cfg.Type,
+		RuleID:    cfg.ID,
+		Name:      cfg.Record,
+		Expr:      cfg.Expr,
+		Labels:    cfg.Labels,
+		GroupID:   group.ID(),
+		GroupName: group.Name,
+		Debug:     cfg.Debug,
+		Limit:     cfg.Limit,
+		metrics:   &recordingRuleMetrics{},
 		q: qb.BuildWithParams(datasource.QuerierParams{
 			DataSourceType:     &cfg.Type,
 			EvaluationInterval: group.Interval,
 			ExtraLabels:        group.ExtraFilterLabels,
+			QueryParams:        group.Params,
+			Headers:            group.Headers,
+			Debug:              cfg.Debug,
+			Tenant:             group.Tenant,
 		}),
 	}
 
@@ -123,6 +136,7 @@ func (rr *RecordingRule) ExecRange(ctx context.Context, start, end time.Time) ([
 
 // Exec executes RecordingRule expression via the given Querier.
 func (rr *RecordingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries, error) {
+	start := time.Now()
 	qMetrics, err := rr.q.Query(ctx, rr.Expr)
 	rr.mu.Lock()
 	defer rr.mu.Unlock()
@@ -130,9 +144,16 @@ func (rr *RecordingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries,
 	rr.lastExecTime = time.Now()
 	rr.lastExecError = err
 	rr.lastExecSamples = len(qMetrics)
+	rr.lastExecDuration = rr.lastExecTime.Sub(start)
 	if err != nil {
+		rr.logDebugf("query returned error: %s", err)
 		return nil, fmt.Errorf("failed to execute query %q: %w", rr.Expr, err)
 	}
+	rr.logDebugf("query returned %d samples (elapsed: %s)", len(qMetrics), rr.lastExecDuration)
+	if rr.Limit > 0 && len(qMetrics) > rr.Limit {
+		rr.lastExecError = fmt.Errorf("exec exceeded limit of %d with %d series", rr.Limit, len(qMetrics))
+		return nil, rr.lastExecError
+	}
 
 	duplicates := make(map[string]struct{}, len(qMetrics))
 	var tss []prompbmarshal.TimeSeries
@@ -145,10 +166,20 @@ func (rr *RecordingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries,
 		}
 		duplicates[key] = struct{}{}
 		tss = append(tss, ts)
+		rr.logDebugf("returned series %s=%v", labelsToString(r.Labels), r.Values)
 	}
 	return tss, nil
 }
 
+// logDebugf logs the given message if debug mode is enabled for the rule.
+func (rr *RecordingRule) logDebugf(format string, args ...interface{}) {
+	if !rr.Debug {
+		return
+	}
+	prefix := fmt.Sprintf("DEBUG rule %q:%q (%d) at %s: ", rr.GroupName, rr.Name, rr.RuleID, rr.lastExecTime.Format(time.RFC3339))
+	logger.Infof("%s%s", prefix, fmt.Sprintf(format, args...))
+}
+
 func stringifyLabels(ts prompbmarshal.TimeSeries) string {
 	labels := ts.Labels
 	if len(labels) > 1 {
@@ -189,6 +220,8 @@ func (rr *RecordingRule) UpdateWith(r Rule) error {
 	}
 	rr.Expr = nr.Expr
 	rr.Labels = nr.Labels
+	rr.Debug = nr.Debug
+	rr.Limit = nr.Limit
 	rr.q = nr.q
 	return nil
 }
@@ -196,20 +229,46 @@ func (rr *RecordingRule) UpdateWith(r Rule) error {
 // RuleAPI returns Rule representation in form
 // of APIRecordingRule
 func (rr *RecordingRule) RuleAPI() APIRecordingRule {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
 	var lastErr string
 	if rr.lastExecError != nil {
 		lastErr = rr.lastExecError.Error()
 	}
 	return APIRecordingRule{
 		// encode as strings to avoid rounding
-		ID:          fmt.Sprintf("%d", rr.ID()),
-		GroupID:     fmt.Sprintf("%d", rr.GroupID),
-		Name:        rr.Name,
-		Type:        rr.Type.String(),
-		Expression:  rr.Expr,
-		LastError:   lastErr,
-		LastSamples: rr.lastExecSamples,
-		LastExec:    rr.lastExecTime,
-		Labels:      rr.Labels,
+		ID:               fmt.Sprintf("%d", rr.ID()),
+		GroupID:          fmt.Sprintf("%d", rr.GroupID),
+		Name:             rr.Name,
+		Type:             rr.Type.String(),
+		Expression:       rr.Expr,
+		LastError:        lastErr,
+		LastSamples:      rr.lastExecSamples,
+		LastExec:         rr.lastExecTime,
+		LastExecDuration: rr.lastExecDuration.Seconds(),
+		Labels:           rr.Labels,
+		Debug:            rr.Debug,
+		Limit:            rr.Limit,
+	}
+}
+
+// PromRuleAPI returns Rule representation in form
+// of Prometheus-compatible PromRecordingRule
+func (rr *RecordingRule) PromRuleAPI() PromRecordingRule {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	var lastErr string
+	if rr.lastExecError != nil {
+		lastErr = rr.lastExecError.Error()
+	}
+	return PromRecordingRule{
+		Name:           rr.Name,
+		Query:          rr.Expr,
+		Labels:         rr.Labels,
+		Health:         ruleHealth(rr.lastExecTime, rr.lastExecError),
+		LastError:      lastErr,
+		EvaluationTime: rr.lastExecDuration.Seconds(),
+		LastEvaluation: rr.lastExecTime,
+		Type:           "recording",
 	}
 }
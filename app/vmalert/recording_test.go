@@ -201,3 +201,27 @@ func TestRecoridngRule_ExecNegative(t *testing.T) {
 		t.Fatalf("expected to get err %q; got %q insterad", errDuplicate, err)
 	}
 }
+
+func TestRecordingRule_Limit(t *testing.T) {
+	rr := &RecordingRule{Name: "job:foo", Limit: 1}
+	fq := &fakeQuerier{}
+	rr.q = fq
+
+	fq.add(metricWithValueAndLabels(t, 1, "__name__", "foo", "job", "foo"))
+	tss, err := rr.Exec(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(tss) != 1 {
+		t.Fatalf("expected to get 1 series; got %d", len(tss))
+	}
+
+	fq.add(metricWithValueAndLabels(t, 2, "__name__", "foo", "job", "bar"))
+	tss, err = rr.Exec(context.TODO())
+	if err == nil || !strings.Contains(err.Error(), "exceeded limit of 1 with 2 series") {
+		t.Fatalf("expected limit error; got %v", err)
+	}
+	if len(tss) != 0 {
+		t.Fatalf("expected no series on limit error; got %d", len(tss))
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
 )
 
 var (
@@ -62,3 +64,22 @@ func Init(ctx context.Context) (*Client, error) {
 		Transport:         t,
 	})
 }
+
+// NewClientForURL creates Client for writing to the given addr with the given auth config.
+// The rest of settings such as queue size and flush interval are taken from -remoteWrite.* flags.
+func NewClientForURL(ctx context.Context, addr string, authCfg *promauth.Config) (*Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if authCfg != nil {
+		t.TLSClientConfig = authCfg.NewTLSConfig()
+	}
+	return NewClient(ctx, Config{
+		Addr:              addr,
+		AuthCfg:           authCfg,
+		Concurrency:       *concurrency,
+		MaxQueueSize:      *maxQueueSize,
+		MaxBatchSize:      *maxBatchSize,
+		FlushInterval:     *flushInterval,
+		DisablePathAppend: *disablePathAppend,
+		Transport:         t,
+	})
+}
@@ -31,6 +31,12 @@ type Client struct {
 	maxQueueSize      int
 	disablePathAppend bool
 
+	// ctx and cfg are used for creating clients per each tenant in ForTenant
+	ctx       context.Context
+	cfg       Config
+	tenantsMu sync.Mutex
+	tenants   map[string]*Client
+
 	wg     sync.WaitGroup
 	doneCh chan struct{}
 }
@@ -110,6 +116,8 @@ func NewClient(ctx context.Context, cfg Config) (*Client, error) {
 		doneCh:            make(chan struct{}),
 		input:             make(chan prompbmarshal.TimeSeries, cfg.MaxQueueSize),
 		disablePathAppend: cfg.DisablePathAppend,
+		ctx:               ctx,
+		cfg:               cfg,
 	}
 
 	for i := 0; i < cc; i++ {
@@ -133,12 +141,44 @@ func (c *Client) Push(s prompbmarshal.TimeSeries) error {
 	}
 }
 
-// Close stops the client and waits for all goroutines
-// to exit.
+// ForTenant returns Client for writing timeseries to the given tenant
+// of VictoriaMetrics cluster. The `/insert/<tenant>/prometheus` path is added
+// to the client's addr according to https://docs.victoriametrics.com/Cluster-VictoriaMetrics.html#url-format
+//
+// Clients are created on the first call for every tenant and are closed on c.Close.
+func (c *Client) ForTenant(tenant string) *Client {
+	c.tenantsMu.Lock()
+	defer c.tenantsMu.Unlock()
+	if tc, ok := c.tenants[tenant]; ok {
+		return tc
+	}
+	cfg := c.cfg
+	cfg.Addr = fmt.Sprintf("%s/insert/%s/prometheus", c.addr, tenant)
+	tc, err := NewClient(c.ctx, cfg)
+	if err != nil {
+		logger.Panicf("BUG: cannot create remote write client for tenant %q: %s", tenant, err)
+	}
+	if c.tenants == nil {
+		c.tenants = make(map[string]*Client)
+	}
+	c.tenants[tenant] = tc
+	return tc
+}
+
+// Close stops the client and clients created via ForTenant
+// and waits for all goroutines to exit.
 func (c *Client) Close() error {
 	if c.doneCh == nil {
 		return fmt.Errorf("client is already closed")
 	}
+	c.tenantsMu.Lock()
+	for tenant, tc := range c.tenants {
+		if err := tc.Close(); err != nil {
+			logger.Errorf("cannot close remote write client for tenant %q: %s", tenant, err)
+		}
+	}
+	c.tenants = nil
+	c.tenantsMu.Unlock()
 	close(c.input)
 	close(c.doneCh)
 	c.wg.Wait()
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -53,6 +54,47 @@ func TestClient_Push(t *testing.T) {
 	}
 }
 
+func TestClient_ForTenant(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(context.Background(), Config{Addr: srv.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	tc := client.ForTenant("123:456")
+	if tc != client.ForTenant("123:456") {
+		t.Fatalf("expecting the same client for the same tenant")
+	}
+	s := prompbmarshal.TimeSeries{
+		Samples: []prompbmarshal.Sample{{Value: 1, Timestamp: time.Now().Unix()}},
+	}
+	if err := tc.Push(s); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := client.ForTenant("7").Push(s); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// Close must flush data for all the tenants
+	if err := client.Close(); err != nil {
+		t.Fatalf("failed to close client: %s", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/insert/123:456/prometheus/api/v1/write", "/insert/7/prometheus/api/v1/write"} {
+		if paths[path] != 1 {
+			t.Fatalf("expecting 1 request to %q; got requests: %v", path, paths)
+		}
+	}
+}
+
 func newRWServer() *rwServer {
 	rw := &rwServer{}
 	rw.Server = httptest.NewServer(http.HandlerFunc(rw.handler))
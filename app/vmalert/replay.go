@@ -67,7 +67,11 @@ func replay(groupsCfg []config.Group, qb datasource.QuerierBuilder, rw *remotewr
 	var total int
 	for _, cfg := range groupsCfg {
 		ng := newGroup(cfg, qb, *evaluationInterval, labels)
-		total += ng.replay(tFrom, tTo, rw)
+		if err := ng.initRemoteWrite(context.Background()); err != nil {
+			return err
+		}
+		total += ng.replay(tFrom, tTo, ng.remoteWriter(rw))
+		ng.closeRemoteWrite()
 	}
 	logger.Infof("replay finished! Imported %d samples", total)
 	if rw != nil {
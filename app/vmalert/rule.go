@@ -27,3 +27,15 @@ type Rule interface {
 }
 
 var errDuplicate = errors.New("result contains metrics with the same labelset after applying rule labels")
+
+// ruleHealth returns Prometheus-compatible health of the rule
+// according to the result of its last evaluation.
+func ruleHealth(lastExec time.Time, lastErr error) string {
+	if lastErr != nil {
+		return "err"
+	}
+	if lastExec.IsZero() {
+		return "unknown"
+	}
+	return "ok"
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// alertState is the state of active alert persisted to -stateFile.
+type alertState struct {
+	GroupID  uint64            `json:"groupID"`
+	RuleID   uint64            `json:"ruleID"`
+	Labels   map[string]string `json:"labels"`
+	Value    float64           `json:"value"`
+	ActiveAt time.Time         `json:"activeAt"`
+}
+
+// alertsState is the content of -stateFile.
+type alertsState struct {
+	Alerts []alertState `json:"alerts"`
+}
+
+// readAlertsState reads alerts state from the given path.
+//
+// It returns alerts state grouped by group ID. Nil map is returned if the file doesn't exist.
+func readAlertsState(path string) (map[uint64][]alertState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read alerts state: %w", err)
+	}
+	var as alertsState
+	if err := json.Unmarshal(data, &as); err != nil {
+		return nil, fmt.Errorf("cannot parse alerts state from %q: %w", path, err)
+	}
+	m := make(map[uint64][]alertState)
+	for _, s := range as.Alerts {
+		m[s.GroupID] = append(m[s.GroupID], s)
+	}
+	return m, nil
+}
+
+// writeAlertsState writes state of active alerts for the given groups to the given path.
+func writeAlertsState(path string, groups map[uint64]*Group) error {
+	var as alertsState
+	for _, g := range groups {
+		g.mu.RLock()
+		for _, rule := range g.Rules {
+			if ar, ok := rule.(*AlertingRule); ok {
+				as.Alerts = ar.appendState(as.Alerts)
+			}
+		}
+		g.mu.RUnlock()
+	}
+	data, err := json.Marshal(&as)
+	if err != nil {
+		return fmt.Errorf("cannot marshal alerts state: %w", err)
+	}
+	if err := fs.WriteFileAtomically(path, data); err != nil {
+		return fmt.Errorf("cannot write alerts state: %w", err)
+	}
+	logger.Infof("saved state for %d alerts to %q", len(as.Alerts), path)
+	return nil
+}
+
+// restoreState restores alerts state for group rules from the given states.
+func (g *Group) restoreState(ctx context.Context, states []alertState) error {
+	for _, rule := range g.Rules {
+		ar, ok := rule.(*AlertingRule)
+		if !ok {
+			continue
+		}
+		if ar.For < 1 {
+			continue
+		}
+		if err := ar.restoreState(ctx, states); err != nil {
+			return fmt.Errorf("error while restoring rule %q: %w", rule, err)
+		}
+	}
+	return nil
+}
+
+// appendState appends the state of active alerts for ar to dst and returns the result.
+//
+// Only alerts for rules with For > 0 are appended, since the rest of alerts
+// are restored on the next Exec.
+func (ar *AlertingRule) appendState(dst []alertState) []alertState {
+	if ar.For < 1 {
+		return dst
+	}
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+	for _, a := range ar.alerts {
+		if a.State == notifier.StateInactive {
+			continue
+		}
+		dst = append(dst, alertState{
+			GroupID:  ar.GroupID,
+			RuleID:   ar.RuleID,
+			Labels:   a.Labels,
+			Value:    a.Value,
+			ActiveAt: a.Start,
+		})
+	}
+	return dst
+}
+
+// restoreState restores active alerts for ar from the given states.
+//
+// Similarly to Restore, only Start field is restored, while State is always set to Pending
+// and is supposed to be updated on the next Exec.
+func (ar *AlertingRule) restoreState(ctx context.Context, states []alertState) error {
+	qFn := func(query string) ([]datasource.Metric, error) { return ar.q.Query(ctx, query) }
+	for _, s := range states {
+		if s.GroupID != ar.GroupID || s.RuleID != ar.RuleID {
+			continue
+		}
+		m := datasource.Metric{
+			Values: []float64{s.Value},
+		}
+		for k, v := range s.Labels {
+			// drop the label added by newAlert, so hash key will
+			// be identical to time series received in Exec
+			if k == alertGroupNameLabel {
+				continue
+			}
+			m.Labels = append(m.Labels, datasource.Label{Name: k, Value: v})
+		}
+		a, err := ar.newAlert(m, s.ActiveAt, qFn)
+		if err != nil {
+			return fmt.Errorf("failed to create alert: %w", err)
+		}
+		a.ID = hash(m)
+		a.State = notifier.StatePending
+		ar.alerts[a.ID] = a
+		logger.Infof("alert %q (%d) restored from state file to state at %v", a.Name, a.ID, a.Start)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+)
+
+func TestAlertsStateWriteRead(t *testing.T) {
+	const path = "TestAlertsStateWriteRead.json"
+	defer func() {
+		_ = os.Remove(path)
+	}()
+
+	fq := &fakeQuerier{}
+	fq.add(metricWithValueAndLabels(t, 1, "__name__", "foo", "job", "bar"))
+	g := &Group{Name: "TestAlertsStateWriteRead"}
+	ar := newTestRuleWithLabels("rule labels", "source", "vm")
+	ar.For = time.Hour
+	ar.GroupID = g.ID()
+	ar.GroupName = g.Name
+	ar.q = fq
+	g.Rules = []Rule{ar, newTestAlertingRule("instant", 0)}
+	if _, err := ar.Exec(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(ar.alerts) != 1 {
+		t.Fatalf("expected 1 alert; got %d", len(ar.alerts))
+	}
+	var expAlert *notifier.Alert
+	for _, a := range ar.alerts {
+		expAlert = a
+	}
+
+	if err := writeAlertsState(path, map[uint64]*Group{g.ID(): g}); err != nil {
+		t.Fatalf("cannot write alerts state: %s", err)
+	}
+	state, err := readAlertsState(path)
+	if err != nil {
+		t.Fatalf("cannot read alerts state: %s", err)
+	}
+	if len(state[g.ID()]) != 1 {
+		t.Fatalf("expected state for 1 alert; got %+v", state)
+	}
+
+	// The alert must be restored with the same ID and start time.
+	ar.alerts = make(map[uint64]*notifier.Alert)
+	if err := g.restoreState(context.Background(), state[g.ID()]); err != nil {
+		t.Fatalf("cannot restore alerts state: %s", err)
+	}
+	got, ok := ar.alerts[expAlert.ID]
+	if !ok {
+		t.Fatalf("expected to have alert with id %d; got %+v", expAlert.ID, ar.alerts)
+	}
+	if got.State != notifier.StatePending {
+		t.Fatalf("expected state %d; got %d", notifier.StatePending, got.State)
+	}
+	if !got.Start.Equal(expAlert.Start) {
+		t.Fatalf("expected Start %v; got %v", expAlert.Start, got.Start)
+	}
+
+	// Missing state file must be ignored.
+	state, err = readAlertsState(path + ".missing")
+	if err != nil {
+		t.Fatalf("unexpected error for missing state file: %s", err)
+	}
+	if state != nil {
+		t.Fatalf("expected nil state for missing state file; got %+v", state)
+	}
+}
@@ -0,0 +1,741 @@
+//go:build vmalerttool
+// +build vmalerttool
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envflag"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+var unittestFiles = flagutil.NewArray("files", "Path to file with unit tests for alerting and recording rules in promtool-compatible format. "+
+	"Pass multiple -files flags in order to run tests from multiple files. "+
+	"See https://docs.victoriametrics.com/vmalert.html#unit-testing-for-rules")
+
+func init() {
+	toolMain = unitTestMain
+}
+
+// unitTestMain runs rules unit tests from -files and exits with non-zero code if at least a single test fails.
+func unitTestMain() {
+	flag.Usage = unitTestUsage
+	// Unit tests print their results to stdout, so there is no need in info logs by default.
+	if err := flag.Set("loggerLevel", "ERROR"); err != nil {
+		panic(fmt.Errorf("cannot set -loggerLevel: %w", err))
+	}
+	envflag.Parse()
+	buildinfo.Init()
+	logger.Init()
+
+	if len(*unittestFiles) == 0 {
+		logger.Fatalf("missing -files command-line flag with paths to unit test files")
+	}
+	if !runUnitTests(*unittestFiles) {
+		os.Exit(1)
+	}
+}
+
+func unitTestUsage() {
+	const s = `
+vmalert-tool runs unit tests for vmalert rules.
+
+See the docs at https://docs.victoriametrics.com/vmalert.html#unit-testing-for-rules .
+`
+	flagutil.Usage(s)
+}
+
+// unitTestFile is a file with rules unit tests in promtool-compatible format.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/unit_testing_rules/
+type unitTestFile struct {
+	RuleFiles          []string            `yaml:"rule_files"`
+	EvaluationInterval *utils.PromDuration `yaml:"evaluation_interval"`
+	GroupEvalOrder     []string            `yaml:"group_eval_order"`
+	Tests              []testGroup         `yaml:"tests"`
+}
+
+// testGroup is a group of unit tests sharing the same input series.
+type testGroup struct {
+	Name           string              `yaml:"name"`
+	Interval       *utils.PromDuration `yaml:"interval"`
+	InputSeries    []inputSeries       `yaml:"input_series"`
+	AlertRuleTests []alertTestCase     `yaml:"alert_rule_test"`
+	ExternalLabels map[string]string   `yaml:"external_labels"`
+
+	// MetricsqlExprTests contains tests for MetricsQL expressions.
+	MetricsqlExprTests []exprTestCase `yaml:"metricsql_expr_test"`
+	// PromqlExprTests is an alias to MetricsqlExprTests for compatibility with promtool.
+	PromqlExprTests []exprTestCase `yaml:"promql_expr_test"`
+}
+
+type inputSeries struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+}
+
+type alertTestCase struct {
+	EvalTime  utils.PromDuration `yaml:"eval_time"`
+	GroupName string             `yaml:"groupname"`
+	Alertname string             `yaml:"alertname"`
+	ExpAlerts []expAlert         `yaml:"exp_alerts"`
+}
+
+type expAlert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+type exprTestCase struct {
+	Expr       string             `yaml:"expr"`
+	EvalTime   utils.PromDuration `yaml:"eval_time"`
+	ExpSamples []expSample        `yaml:"exp_samples"`
+}
+
+type expSample struct {
+	Labels string  `yaml:"labels"`
+	Value  float64 `yaml:"value"`
+}
+
+// unitTestStartTime is the timestamp for the first sample of input series.
+var unitTestStartTime = time.Unix(0, 0).UTC()
+
+// unitTestFlags contains flag values required for running unit tests with the embedded storage.
+var unitTestFlags = map[string]string{
+	// Input series start from Unix epoch, so they must fit the retention.
+	"retentionPeriod": "100y",
+	// Rules results are written after the queries over the same time range,
+	// so the cached responses may become stale.
+	"search.disableCache":          "true",
+	"search.disableAutoCacheReset": "true",
+}
+
+// runUnitTests runs rules unit tests from the given files.
+//
+// It returns false if at least a single test fails.
+func runUnitTests(files []string) bool {
+	for name, value := range unitTestFlags {
+		if err := flag.Set(name, value); err != nil {
+			fmt.Printf("cannot set -%s: %s\n", name, err)
+			return false
+		}
+	}
+	eu, err := getExternalURL(*externalURL, *httpListenAddr, false)
+	if err != nil {
+		fmt.Printf("cannot init -external.url: %s\n", err)
+		return false
+	}
+	notifier.InitTemplateFunc(eu)
+	if err := notifier.LoadTemplates(*ruleTemplatesPath); err != nil {
+		fmt.Printf("cannot load -rule.templates: %s\n", err)
+		return false
+	}
+
+	passed := true
+	for _, path := range files {
+		fmt.Printf("Unit testing: %s\n", path)
+		errs := runUnitTestFile(path)
+		if len(errs) == 0 {
+			fmt.Printf("  SUCCESS\n\n")
+			continue
+		}
+		passed = false
+		fmt.Printf("  FAILED:\n")
+		for _, err := range errs {
+			fmt.Printf("    %s\n", strings.ReplaceAll(err.Error(), "\n", "\n    "))
+		}
+		fmt.Println()
+	}
+	return passed
+}
+
+func runUnitTestFile(path string) []error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("cannot read unit test file: %w", err)}
+	}
+	var utf unitTestFile
+	if err := yaml.UnmarshalStrict(data, &utf); err != nil {
+		return []error{fmt.Errorf("cannot parse unit test file %q: %w", path, err)}
+	}
+	evalInterval := time.Minute
+	if utf.EvaluationInterval != nil {
+		evalInterval = utf.EvaluationInterval.Duration()
+	}
+	if evalInterval <= 0 {
+		return []error{fmt.Errorf("evaluation_interval must be positive; got %s", evalInterval)}
+	}
+
+	// Rule files are relative to the unit test file.
+	var ruleFiles []string
+	for _, rf := range utf.RuleFiles {
+		if !filepath.IsAbs(rf) {
+			rf = filepath.Join(filepath.Dir(path), rf)
+		}
+		ruleFiles = append(ruleFiles, rf)
+	}
+	groupsCfg, err := config.Parse(ruleFiles, *validateTemplates, *validateExpressions)
+	if err != nil {
+		return []error{fmt.Errorf("cannot parse rule files: %w", err)}
+	}
+	groupsCfg, err = orderGroups(groupsCfg, utf.GroupEvalOrder)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for i := range utf.Tests {
+		tg := &utf.Tests[i]
+		for _, err := range tg.run(groupsCfg, evalInterval) {
+			name := tg.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			errs = append(errs, fmt.Errorf("test %s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// orderGroups orders groupsCfg according to groupEvalOrder.
+//
+// Groups missing in groupEvalOrder are evaluated after the ordered groups in the order they are defined in rule files.
+func orderGroups(groupsCfg []config.Group, groupEvalOrder []string) ([]config.Group, error) {
+	if len(groupEvalOrder) == 0 {
+		return groupsCfg, nil
+	}
+	var result []config.Group
+	ordered := make(map[string]bool)
+	for _, name := range groupEvalOrder {
+		found := false
+		for _, cfg := range groupsCfg {
+			if cfg.Name == name {
+				result = append(result, cfg)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("cannot find group %q from group_eval_order in rule files", name)
+		}
+		ordered[name] = true
+	}
+	for _, cfg := range groupsCfg {
+		if !ordered[cfg.Name] {
+			result = append(result, cfg)
+		}
+	}
+	return result, nil
+}
+
+func (tg *testGroup) run(groupsCfg []config.Group, evalInterval time.Duration) []error {
+	interval := evalInterval
+	if tg.Interval != nil {
+		interval = tg.Interval.Duration()
+	}
+	if interval <= 0 {
+		return []error{fmt.Errorf("interval must be positive; got %s", interval)}
+	}
+
+	tmpDir, err := ioutil.TempDir("", "vmalert-unittest")
+	if err != nil {
+		return []error{fmt.Errorf("cannot create temporary directory for the storage: %w", err)}
+	}
+	defer fs.MustRemoveAll(tmpDir)
+	if err := startUnitTestStorage(tmpDir); err != nil {
+		return []error{err}
+	}
+	defer stopUnitTestStorage()
+
+	var tss []prompbmarshal.TimeSeries
+	for _, is := range tg.InputSeries {
+		ts, err := is.toTimeSeries(interval)
+		if err != nil {
+			return []error{err}
+		}
+		tss = append(tss, ts)
+	}
+	if err := writeUnitTestSeries(tss); err != nil {
+		return []error{err}
+	}
+
+	q := &unitTestQuerier{
+		step: evalInterval,
+	}
+	var groups []*Group
+	for _, cfg := range groupsCfg {
+		groups = append(groups, newGroup(cfg, q, evalInterval, tg.ExternalLabels))
+	}
+	defer func() {
+		for _, g := range groups {
+			for _, rule := range g.Rules {
+				rule.Close()
+			}
+		}
+	}()
+
+	exprTests := append(append([]exprTestCase{}, tg.MetricsqlExprTests...), tg.PromqlExprTests...)
+	var maxEvalTime time.Duration
+	for _, tc := range tg.AlertRuleTests {
+		if d := tc.EvalTime.Duration(); d > maxEvalTime {
+			maxEvalTime = d
+		}
+	}
+	for _, tc := range exprTests {
+		if d := tc.EvalTime.Duration(); d > maxEvalTime {
+			maxEvalTime = d
+		}
+	}
+
+	var errs []error
+	ctx := context.Background()
+	for ts := time.Duration(0); ts <= maxEvalTime; ts += evalInterval {
+		q.ts = unitTestStartTime.Add(ts)
+		for _, g := range groups {
+			if ts%g.Interval != 0 {
+				continue
+			}
+			for _, rule := range g.Rules {
+				var tss []prompbmarshal.TimeSeries
+				var err error
+				if ar, ok := rule.(*AlertingRule); ok {
+					tss, err = ar.execAt(ctx, q.ts)
+				} else {
+					tss, err = rule.Exec(ctx)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("cannot evaluate rule %q from group %q at %s: %w", rule, g.Name, ts, err))
+					continue
+				}
+				// Make the results available to the subsequent rules.
+				if err := writeUnitTestSeries(tss); err != nil {
+					return append(errs, err)
+				}
+			}
+		}
+		for i := range tg.AlertRuleTests {
+			tc := &tg.AlertRuleTests[i]
+			if d := tc.EvalTime.Duration(); d < ts || d >= ts+evalInterval {
+				continue
+			}
+			if err := tc.check(groups); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for i := range exprTests {
+		if err := exprTests[i].check(q); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func startUnitTestStorage(path string) error {
+	if err := flag.Set("storageDataPath", path); err != nil {
+		return fmt.Errorf("cannot set -storageDataPath: %w", err)
+	}
+	// Response cache is disabled in unit tests, so there is no need in resetting it.
+	vmstorage.InitWithoutMetrics(func(mrs []storage.MetricRow) {})
+	netstorage.InitTmpBlocksDir(path + "/tmp")
+	return nil
+}
+
+func stopUnitTestStorage() {
+	vmstorage.Stop()
+}
+
+func writeUnitTestSeries(tss []prompbmarshal.TimeSeries) error {
+	if len(tss) == 0 {
+		return nil
+	}
+	var mrs []storage.MetricRow
+	var labels []prompb.Label
+	for _, ts := range tss {
+		labels = labels[:0]
+		for _, l := range ts.Labels {
+			labels = append(labels, prompb.Label{
+				Name:  []byte(l.Name),
+				Value: []byte(l.Value),
+			})
+		}
+		metricNameRaw := storage.MarshalMetricNameRaw(nil, labels)
+		for _, s := range ts.Samples {
+			mrs = append(mrs, storage.MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     s.Timestamp,
+				Value:         s.Value,
+			})
+		}
+	}
+	if err := vmstorage.AddRows(mrs); err != nil {
+		return fmt.Errorf("cannot write series to the storage: %w", err)
+	}
+	// Make the written series visible to search.
+	vmstorage.Storage.DebugFlush()
+	return nil
+}
+
+func (is *inputSeries) toTimeSeries(interval time.Duration) (prompbmarshal.TimeSeries, error) {
+	var ts prompbmarshal.TimeSeries
+	labels, err := parseSeriesLabels(is.Series)
+	if err != nil {
+		return ts, fmt.Errorf("cannot parse series %q: %w", is.Series, err)
+	}
+	for _, l := range labels {
+		ts.Labels = append(ts.Labels, prompbmarshal.Label{
+			Name:  l.Name,
+			Value: l.Value,
+		})
+	}
+	values, err := parseSeriesValues(is.Values)
+	if err != nil {
+		return ts, fmt.Errorf("cannot parse values %q for series %q: %w", is.Values, is.Series, err)
+	}
+	for i, v := range values {
+		if v.omitted {
+			continue
+		}
+		ts.Samples = append(ts.Samples, prompbmarshal.Sample{
+			Value:     v.value,
+			Timestamp: unitTestStartTime.Add(time.Duration(i)*interval).UnixNano() / 1e6,
+		})
+	}
+	return ts, nil
+}
+
+// parseSeriesLabels parses labels from series selector such as `metric{foo="bar"}`.
+func parseSeriesLabels(s string) ([]datasource.Label, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	expr, err := metricsql.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	me, ok := expr.(*metricsql.MetricExpr)
+	if !ok {
+		return nil, fmt.Errorf("expecting series selector; got %q", expr.AppendString(nil))
+	}
+	var labels []datasource.Label
+	for _, lf := range me.LabelFilters {
+		if lf.IsRegexp || lf.IsNegative {
+			return nil, fmt.Errorf("only `label=\"value\"` filters are supported; got %q", lf.AppendString(nil))
+		}
+		labels = append(labels, datasource.Label{
+			Name:  lf.Label,
+			Value: lf.Value,
+		})
+	}
+	return labels, nil
+}
+
+type seriesValue struct {
+	value   float64
+	omitted bool
+}
+
+// parseSeriesValues parses series values in promtool notation.
+//
+// The following items are supported:
+//
+//   - `a` - the value a
+//   - `_` - omitted value
+//   - `stale` - staleness marker
+//   - `a+bxn` - n+1 values starting from a and incremented by b: `a a+b a+2*b ... a+n*b`
+//   - `a-bxn` - n+1 values starting from a and decremented by b: `a a-b a-2*b ... a-n*b`
+//   - `axn` - n+1 values equal to a
+//   - `_xn` - n omitted values
+func parseSeriesValues(s string) ([]seriesValue, error) {
+	var values []seriesValue
+	for _, item := range strings.Fields(s) {
+		switch item {
+		case "_":
+			values = append(values, seriesValue{omitted: true})
+			continue
+		case "stale":
+			values = append(values, seriesValue{value: decimal.StaleNaN})
+			continue
+		}
+		n := strings.LastIndexByte(item, 'x')
+		if n < 0 {
+			v, err := strconv.ParseFloat(item, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse %q: %w", item, err)
+			}
+			values = append(values, seriesValue{value: v})
+			continue
+		}
+		times, err := strconv.Atoi(item[n+1:])
+		if err != nil || times < 0 {
+			return nil, fmt.Errorf("cannot parse the number of repetitions in %q", item)
+		}
+		expr := item[:n]
+		if expr == "_" {
+			for i := 0; i < times; i++ {
+				values = append(values, seriesValue{omitted: true})
+			}
+			continue
+		}
+		start, delta, sign := expr, "0", 1.0
+		for i := 1; i < len(expr); i++ {
+			if (expr[i] == '+' || expr[i] == '-') && expr[i-1] != 'e' && expr[i-1] != 'E' {
+				start, delta = expr[:i], expr[i+1:]
+				if expr[i] == '-' {
+					sign = -1
+				}
+				break
+			}
+		}
+		a, err := strconv.ParseFloat(start, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse initial value in %q: %w", item, err)
+		}
+		b, err := strconv.ParseFloat(delta, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse increment in %q: %w", item, err)
+		}
+		for i := 0; i <= times; i++ {
+			values = append(values, seriesValue{value: a + sign*b*float64(i)})
+		}
+	}
+	return values, nil
+}
+
+// unitTestQuerier executes queries against the storage with unit test data at the given evaluation time.
+type unitTestQuerier struct {
+	ts   time.Time
+	step time.Duration
+}
+
+// BuildWithParams implements datasource.QuerierBuilder interface.
+func (q *unitTestQuerier) BuildWithParams(_ datasource.QuerierParams) datasource.Querier {
+	return q
+}
+
+// Query implements datasource.Querier interface.
+func (q *unitTestQuerier) Query(_ context.Context, query string) ([]datasource.Metric, error) {
+	return q.query(query, q.ts, q.ts)
+}
+
+// QueryRange implements datasource.Querier interface.
+func (q *unitTestQuerier) QueryRange(_ context.Context, query string, from, to time.Time) ([]datasource.Metric, error) {
+	return q.query(query, from, to)
+}
+
+func (q *unitTestQuerier) query(query string, start, end time.Time) ([]datasource.Metric, error) {
+	ec := &promql.EvalConfig{
+		Start:       start.UnixNano() / 1e6,
+		End:         end.UnixNano() / 1e6,
+		Step:        q.step.Milliseconds(),
+		Deadline:    searchutils.NewDeadline(time.Now(), time.Minute, ""),
+		RoundDigits: 100,
+	}
+	results, err := promql.Exec(nil, ec, query, start.Equal(end))
+	if err != nil {
+		return nil, err
+	}
+	ms := make([]datasource.Metric, 0, len(results))
+	for _, r := range results {
+		var m datasource.Metric
+		if len(r.MetricName.MetricGroup) > 0 {
+			m.Labels = append(m.Labels, datasource.Label{
+				Name:  "__name__",
+				Value: string(r.MetricName.MetricGroup),
+			})
+		}
+		for _, tag := range r.MetricName.Tags {
+			m.Labels = append(m.Labels, datasource.Label{
+				Name:  string(tag.Key),
+				Value: string(tag.Value),
+			})
+		}
+		for i, v := range r.Values {
+			m.Values = append(m.Values, v)
+			m.Timestamps = append(m.Timestamps, r.Timestamps[i]/1e3)
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// testAlert is a firing alert representation for comparing with the expected alerts.
+type testAlert struct {
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+func newTestAlert(alertname string, labels, annotations map[string]string) testAlert {
+	ta := testAlert{
+		Labels:      make(map[string]string),
+		Annotations: make(map[string]string),
+	}
+	for k, v := range labels {
+		ta.Labels[k] = v
+	}
+	ta.Labels[alertNameLabel] = alertname
+	for k, v := range annotations {
+		ta.Annotations[k] = v
+	}
+	return ta
+}
+
+func (ta testAlert) String() string {
+	return fmt.Sprintf("labels: %s, annotations: %s", mapToString(ta.Labels), mapToString(ta.Annotations))
+}
+
+func (tc *alertTestCase) check(groups []*Group) error {
+	var got []testAlert
+	found := false
+	for _, g := range groups {
+		if g.Name != tc.GroupName {
+			continue
+		}
+		for _, rule := range g.Rules {
+			ar, ok := rule.(*AlertingRule)
+			if !ok || ar.Name != tc.Alertname {
+				continue
+			}
+			found = true
+			ar.mu.RLock()
+			for _, a := range ar.alerts {
+				if a.State != notifier.StateFiring {
+					continue
+				}
+				labels := make(map[string]string, len(a.Labels))
+				for k, v := range a.Labels {
+					// The label with group name is added by vmalert, so it is ignored.
+					if k == alertGroupNameLabel && v == ar.GroupName {
+						continue
+					}
+					labels[k] = v
+				}
+				got = append(got, newTestAlert(ar.Name, labels, a.Annotations))
+			}
+			ar.mu.RUnlock()
+		}
+	}
+	if !found {
+		return fmt.Errorf("cannot find alerting rule %q in group %q", tc.Alertname, tc.GroupName)
+	}
+	var exp []testAlert
+	for _, ea := range tc.ExpAlerts {
+		exp = append(exp, newTestAlert(tc.Alertname, ea.ExpLabels, ea.ExpAnnotations))
+	}
+	sortTestAlerts(got)
+	sortTestAlerts(exp)
+	if len(got) == 0 && len(exp) == 0 {
+		return nil
+	}
+	if !reflect.DeepEqual(got, exp) {
+		return fmt.Errorf("alertname: %s, group: %s, time: %s,\n  exp: %v,\n  got: %v",
+			tc.Alertname, tc.GroupName, tc.EvalTime.Duration(), exp, got)
+	}
+	return nil
+}
+
+func sortTestAlerts(tas []testAlert) {
+	sort.Slice(tas, func(i, j int) bool {
+		return mapToString(tas[i].Labels) < mapToString(tas[j].Labels)
+	})
+}
+
+// testSample is a sample representation for comparing with the expected samples.
+type testSample struct {
+	Labels string
+	Value  float64
+}
+
+func (ts testSample) String() string {
+	return fmt.Sprintf("%s %g", ts.Labels, ts.Value)
+}
+
+func (tc *exprTestCase) check(q *unitTestQuerier) error {
+	q.ts = unitTestStartTime.Add(tc.EvalTime.Duration())
+	ms, err := q.Query(context.Background(), tc.Expr)
+	if err != nil {
+		return fmt.Errorf("expr: %q, time: %s, err: %w", tc.Expr, tc.EvalTime.Duration(), err)
+	}
+	var got []testSample
+	for _, m := range ms {
+		got = append(got, testSample{
+			Labels: labelsToString(m.Labels),
+			Value:  m.Values[0],
+		})
+	}
+	var exp []testSample
+	for _, s := range tc.ExpSamples {
+		labels, err := parseSeriesLabels(s.Labels)
+		if err != nil {
+			return fmt.Errorf("expr: %q, time: %s, cannot parse labels %q: %w", tc.Expr, tc.EvalTime.Duration(), s.Labels, err)
+		}
+		exp = append(exp, testSample{
+			Labels: labelsToString(labels),
+			Value:  s.Value,
+		})
+	}
+	sortTestSamples(got)
+	sortTestSamples(exp)
+	if !testSamplesEqual(got, exp) {
+		return fmt.Errorf("expr: %q, time: %s,\n  exp: %v,\n  got: %v", tc.Expr, tc.EvalTime.Duration(), exp, got)
+	}
+	return nil
+}
+
+func sortTestSamples(tss []testSample) {
+	sort.Slice(tss, func(i, j int) bool {
+		return tss[i].Labels < tss[j].Labels
+	})
+}
+
+func testSamplesEqual(a, b []testSample) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Labels != b[i].Labels || !valuesAlmostEqual(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func valuesAlmostEqual(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	if a == b {
+		return true
+	}
+	const epsilon = 1e-9
+	return math.Abs(a-b) <= epsilon*math.Max(math.Abs(a), math.Abs(b))
+}
@@ -0,0 +1,85 @@
+//go:build vmalerttool
+// +build vmalerttool
+
+package main
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+)
+
+func TestParseSeriesValues(t *testing.T) {
+	f := func(s string, expected []seriesValue) {
+		t.Helper()
+		values, err := parseSeriesValues(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if len(values) != len(expected) {
+			t.Fatalf("unexpected number of values for %q; got %d; want %d", s, len(values), len(expected))
+		}
+		for i, v := range values {
+			exp := expected[i]
+			if v.omitted != exp.omitted {
+				t.Fatalf("unexpected omitted value #%d for %q; got %v; want %v", i, s, v.omitted, exp.omitted)
+			}
+			if decimal.IsStaleNaN(exp.value) {
+				if !decimal.IsStaleNaN(v.value) {
+					t.Fatalf("expecting staleness marker #%d for %q; got %v", i, s, v.value)
+				}
+				continue
+			}
+			if v.value != exp.value {
+				t.Fatalf("unexpected value #%d for %q; got %v; want %v", i, s, v.value, exp.value)
+			}
+		}
+	}
+	value := func(vs ...float64) []seriesValue {
+		var result []seriesValue
+		for _, v := range vs {
+			result = append(result, seriesValue{value: v})
+		}
+		return result
+	}
+	omitted := seriesValue{omitted: true}
+
+	f("", nil)
+	f("1 2.5 -3", value(1, 2.5, -3))
+	f("1e3 -1e-2", value(1000, -0.01))
+	f("0+10x3", value(0, 10, 20, 30))
+	f("5-1x2", value(5, 4, 3))
+	f("-1+1x2", value(-1, 0, 1))
+	f("1e2+1e1x1", value(100, 110))
+	f("7x2", value(7, 7, 7))
+	f("1 _ 2", []seriesValue{{value: 1}, omitted, {value: 2}})
+	f("_x3", []seriesValue{omitted, omitted, omitted})
+	f("1 stale", []seriesValue{{value: 1}, {value: decimal.StaleNaN}})
+}
+
+func TestParseSeriesValuesFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if _, err := parseSeriesValues(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+	}
+	f("foo")
+	f("1+x3")
+	f("1+1x")
+	f("1+1x-1")
+	f("1+1xfoo")
+	f("_x")
+}
+
+func TestRunUnitTests(t *testing.T) {
+	if !runUnitTests([]string{"testdata/unittest.yaml"}) {
+		t.Fatalf("expecting unit tests to pass")
+	}
+	if runUnitTests([]string{"testdata/unittest-bad.yaml"}) {
+		t.Fatalf("expecting unit tests to fail")
+	}
+	if runUnitTests([]string{"testdata/missing.yaml"}) {
+		t.Fatalf("expecting unit tests to fail for missing file")
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 )
 
@@ -30,3 +33,35 @@ func newTimeSeries(values []float64, timestamps []int64, labels map[string]strin
 	}
 	return ts
 }
+
+// labelsToString returns string representation for labels in the form `metric{label="value",...}`.
+func labelsToString(labels []datasource.Label) string {
+	var name string
+	m := make(map[string]string, len(labels))
+	for _, l := range labels {
+		if l.Name == "__name__" {
+			name = l.Value
+			continue
+		}
+		m[l.Name] = l.Value
+	}
+	return name + mapToString(m)
+}
+
+func mapToString(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s=%q", k, m[k])
+	}
+	b.WriteString("}")
+	return b.String()
+}
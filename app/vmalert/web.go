@@ -13,7 +13,6 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/tpl"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
-	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
 )
 
 var (
@@ -26,6 +25,7 @@ func initLinks() {
 	pathPrefix := httpserver.GetPathPrefix()
 	apiLinks = [][2]string{
 		{path.Join(pathPrefix, "api/v1/groups"), "list all loaded groups and rules"},
+		{path.Join(pathPrefix, "api/v1/rules"), "list all loaded groups and rules in Prometheus-compatible format"},
 		{path.Join(pathPrefix, "api/v1/alerts"), "list all active alerts"},
 		{path.Join(pathPrefix, "api/v1/groupID/alertID/status"), "get alert status by ID"},
 		{path.Join(pathPrefix, "metrics"), "list of application metrics"},
@@ -70,6 +70,15 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.Write(data)
 		return true
+	case "/api/v1/rules":
+		data, err := rh.listRules(r)
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(data)
+		return true
 	case "/api/v1/alerts":
 		data, err := rh.listAlerts()
 		if err != nil {
@@ -80,8 +89,11 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 		w.Write(data)
 		return true
 	case "/-/reload":
-		logger.Infof("api config reload was called, sending sighup")
-		procutil.SelfSIGHUP()
+		logger.Infof("api config reload was called")
+		if err := requestConfigReload(r.Context()); err != nil {
+			httpserver.Errorf(w, r, "cannot reload config: %s", err)
+			return true
+		}
 		w.WriteHeader(http.StatusOK)
 		return true
 	default:
@@ -148,6 +160,70 @@ func (rh *requestHandler) listGroups() ([]byte, error) {
 	return b, nil
 }
 
+type listRulesResponse struct {
+	Data struct {
+		Groups []PromGroup `json:"groups"`
+	} `json:"data"`
+	Status string `json:"status"`
+}
+
+// listRules returns groups and rules in Prometheus-compatible format.
+// The optional `type` query arg may be set to `alert` or `record`
+// in order to return only alerting or recording rules.
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#rules
+func (rh *requestHandler) listRules(r *http.Request) ([]byte, error) {
+	typ := r.FormValue("type")
+	if typ != "" && typ != "alert" && typ != "record" {
+		return nil, badRequest(fmt.Errorf(`unsupported "type" query arg %q; must be "alert" or "record"`, typ))
+	}
+
+	rh.m.groupsMu.RLock()
+	lr := listRulesResponse{Status: "success"}
+	lr.Data.Groups = []PromGroup{}
+	for _, g := range rh.m.groups {
+		pg := g.toPromAPI()
+		if typ != "" {
+			var rules []interface{}
+			for _, r := range pg.Rules {
+				switch r.(type) {
+				case PromAlertingRule:
+					if typ == "alert" {
+						rules = append(rules, r)
+					}
+				case PromRecordingRule:
+					if typ == "record" {
+						rules = append(rules, r)
+					}
+				}
+			}
+			if len(rules) == 0 {
+				// skip groups without matching rules
+				continue
+			}
+			pg.Rules = rules
+		}
+		lr.Data.Groups = append(lr.Data.Groups, pg)
+	}
+	rh.m.groupsMu.RUnlock()
+
+	// sort list of groups for deterministic output
+	sort.Slice(lr.Data.Groups, func(i, j int) bool {
+		a, b := lr.Data.Groups[i], lr.Data.Groups[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Name < b.Name
+	})
+	b, err := json.Marshal(lr)
+	if err != nil {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf(`error encoding list of rules: %w`, err),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	return b, nil
+}
+
 type listAlertsResponse struct {
 	Data struct {
 		Alerts []*APIAlert `json:"alerts"`
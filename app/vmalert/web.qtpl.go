@@ -180,6 +180,16 @@ func StreamListGroups(qw422016 *qt422016.Writer, groups []APIGroup) {
 				qw422016.N().S(`)`)
 //line app/vmalert/web.qtpl:53
 			}
+//line app/vmalert/web.qtpl:53
+			if g.Tenant != "" {
+//line app/vmalert/web.qtpl:53
+				qw422016.N().S(` (tenant `)
+//line app/vmalert/web.qtpl:53
+				qw422016.E().S(g.Tenant)
+//line app/vmalert/web.qtpl:53
+				qw422016.N().S(`)`)
+//line app/vmalert/web.qtpl:53
+			}
 //line app/vmalert/web.qtpl:53
 			qw422016.N().S(` (every `)
 //line app/vmalert/web.qtpl:53
@@ -240,11 +250,52 @@ func StreamListGroups(qw422016 *qt422016.Writer, groups []APIGroup) {
 			}
 //line app/vmalert/web.qtpl:63
 			qw422016.N().S(`
+                `)
+//line app/vmalert/web.qtpl:64
+			if len(g.Params) > 0 {
+//line app/vmalert/web.qtpl:64
+				qw422016.N().S(`
+                    <div class="fs-6 fw-lighter">Extra params
+                    `)
+//line app/vmalert/web.qtpl:66
+				for k, vl := range g.Params {
+//line app/vmalert/web.qtpl:66
+					qw422016.N().S(`
+                        `)
+//line app/vmalert/web.qtpl:67
+					for _, v := range vl {
+//line app/vmalert/web.qtpl:67
+						qw422016.N().S(`
+                            <span class="float-left badge bg-primary">`)
+//line app/vmalert/web.qtpl:68
+						qw422016.E().S(k)
+//line app/vmalert/web.qtpl:68
+						qw422016.N().S(`=`)
+//line app/vmalert/web.qtpl:68
+						qw422016.E().S(v)
+//line app/vmalert/web.qtpl:68
+						qw422016.N().S(`</span>
+                        `)
+//line app/vmalert/web.qtpl:69
+					}
+//line app/vmalert/web.qtpl:69
+					qw422016.N().S(`
+                    `)
+//line app/vmalert/web.qtpl:70
+				}
+//line app/vmalert/web.qtpl:70
+				qw422016.N().S(`
+                    </div>
+                `)
+//line app/vmalert/web.qtpl:72
+			}
+//line app/vmalert/web.qtpl:72
+			qw422016.N().S(`
             </div>
             <div class="collapse" id="rules-`)
-//line app/vmalert/web.qtpl:65
+//line app/vmalert/web.qtpl:74
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:65
+//line app/vmalert/web.qtpl:74
 			qw422016.N().S(`">
                 <table class="table table-striped table-hover table-sm">
                     <thead>
@@ -252,285 +303,318 @@ func StreamListGroups(qw422016 *qt422016.Writer, groups []APIGroup) {
                             <th scope="col">Rule</th>
                             <th scope="col" title="Shows if rule's execution ended with error">Error</th>
                             <th scope="col" title="How many samples were produced by the rule">Samples</th>
+                            <th scope="col" title="How many seconds the last rule evaluation took">Duration</th>
                             <th scope="col" title="How many seconds ago rule was executed">Updated</th>
                         </tr>
                     </thead>
                     <tbody>
                     `)
-//line app/vmalert/web.qtpl:76
+//line app/vmalert/web.qtpl:86
 			for _, ar := range g.AlertingRules {
-//line app/vmalert/web.qtpl:76
+//line app/vmalert/web.qtpl:86
 				qw422016.N().S(`
                         <tr`)
-//line app/vmalert/web.qtpl:77
+//line app/vmalert/web.qtpl:87
 				if ar.LastError != "" {
-//line app/vmalert/web.qtpl:77
+//line app/vmalert/web.qtpl:87
 					qw422016.N().S(` class="alert-danger"`)
-//line app/vmalert/web.qtpl:77
+//line app/vmalert/web.qtpl:87
 				}
-//line app/vmalert/web.qtpl:77
+//line app/vmalert/web.qtpl:87
 				qw422016.N().S(`>
                             <td>
                                 <b>alert:</b> `)
-//line app/vmalert/web.qtpl:79
+//line app/vmalert/web.qtpl:89
 				qw422016.E().S(ar.Name)
-//line app/vmalert/web.qtpl:79
+//line app/vmalert/web.qtpl:89
 				qw422016.N().S(` (for: `)
-//line app/vmalert/web.qtpl:79
+//line app/vmalert/web.qtpl:89
 				qw422016.E().V(ar.For)
-//line app/vmalert/web.qtpl:79
-				qw422016.N().S(`)<br>
+//line app/vmalert/web.qtpl:89
+				if ar.KeepFiringFor != "0s" {
+//line app/vmalert/web.qtpl:89
+					qw422016.N().S(`, keep_firing_for: `)
+//line app/vmalert/web.qtpl:89
+					qw422016.E().V(ar.KeepFiringFor)
+//line app/vmalert/web.qtpl:89
+				}
+//line app/vmalert/web.qtpl:89
+				qw422016.N().S(`)`)
+//line app/vmalert/web.qtpl:89
+				if ar.Debug {
+//line app/vmalert/web.qtpl:89
+					qw422016.N().S(` <span class="badge bg-warning text-dark" title="Debug mode is enabled for the rule">debug</span>`)
+//line app/vmalert/web.qtpl:89
+				}
+//line app/vmalert/web.qtpl:89
+				qw422016.N().S(`<br>
                                 <code><pre>`)
-//line app/vmalert/web.qtpl:80
+//line app/vmalert/web.qtpl:90
 				qw422016.E().S(ar.Expression)
-//line app/vmalert/web.qtpl:80
+//line app/vmalert/web.qtpl:90
 				qw422016.N().S(`</pre></code><br>
                                 `)
-//line app/vmalert/web.qtpl:81
+//line app/vmalert/web.qtpl:91
 				if len(ar.Labels) > 0 {
-//line app/vmalert/web.qtpl:81
+//line app/vmalert/web.qtpl:91
 					qw422016.N().S(` <b>Labels:</b>`)
-//line app/vmalert/web.qtpl:81
+//line app/vmalert/web.qtpl:91
 				}
-//line app/vmalert/web.qtpl:81
+//line app/vmalert/web.qtpl:91
 				qw422016.N().S(`
                                 `)
-//line app/vmalert/web.qtpl:82
+//line app/vmalert/web.qtpl:92
 				for k, v := range ar.Labels {
-//line app/vmalert/web.qtpl:82
+//line app/vmalert/web.qtpl:92
 					qw422016.N().S(`
                                         <span class="ms-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:83
+//line app/vmalert/web.qtpl:93
 					qw422016.E().S(k)
-//line app/vmalert/web.qtpl:83
+//line app/vmalert/web.qtpl:93
 					qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:83
+//line app/vmalert/web.qtpl:93
 					qw422016.E().S(v)
-//line app/vmalert/web.qtpl:83
+//line app/vmalert/web.qtpl:93
 					qw422016.N().S(`</span>
                                 `)
-//line app/vmalert/web.qtpl:84
+//line app/vmalert/web.qtpl:94
 				}
-//line app/vmalert/web.qtpl:84
+//line app/vmalert/web.qtpl:94
 				qw422016.N().S(`
                             </td>
                             <td><div class="error-cell">`)
-//line app/vmalert/web.qtpl:86
+//line app/vmalert/web.qtpl:96
 				qw422016.E().S(ar.LastError)
-//line app/vmalert/web.qtpl:86
+//line app/vmalert/web.qtpl:96
 				qw422016.N().S(`</div></td>
                             <td>`)
-//line app/vmalert/web.qtpl:87
+//line app/vmalert/web.qtpl:97
 				qw422016.N().D(ar.LastSamples)
-//line app/vmalert/web.qtpl:87
+//line app/vmalert/web.qtpl:97
 				qw422016.N().S(`</td>
                             <td>`)
-//line app/vmalert/web.qtpl:88
+//line app/vmalert/web.qtpl:98
+				qw422016.N().FPrec(ar.LastExecDuration, 3)
+//line app/vmalert/web.qtpl:98
+				qw422016.N().S(`s</td>
+                            <td>`)
+//line app/vmalert/web.qtpl:99
 				qw422016.N().FPrec(time.Since(ar.LastExec).Seconds(), 3)
-//line app/vmalert/web.qtpl:88
+//line app/vmalert/web.qtpl:99
 				qw422016.N().S(`s ago</td>
                         </tr>
                     `)
-//line app/vmalert/web.qtpl:90
+//line app/vmalert/web.qtpl:101
 			}
-//line app/vmalert/web.qtpl:90
+//line app/vmalert/web.qtpl:101
 			qw422016.N().S(`
                     `)
-//line app/vmalert/web.qtpl:91
+//line app/vmalert/web.qtpl:102
 			for _, rr := range g.RecordingRules {
-//line app/vmalert/web.qtpl:91
+//line app/vmalert/web.qtpl:102
 				qw422016.N().S(`
                         <tr>
                             <td>
                                 <b>record:</b> `)
-//line app/vmalert/web.qtpl:94
+//line app/vmalert/web.qtpl:105
 				qw422016.E().S(rr.Name)
-//line app/vmalert/web.qtpl:94
+//line app/vmalert/web.qtpl:105
+				if rr.Debug {
+//line app/vmalert/web.qtpl:105
+					qw422016.N().S(` <span class="badge bg-warning text-dark" title="Debug mode is enabled for the rule">debug</span>`)
+//line app/vmalert/web.qtpl:105
+				}
+//line app/vmalert/web.qtpl:105
 				qw422016.N().S(`<br>
                                 <code><pre>`)
-//line app/vmalert/web.qtpl:95
+//line app/vmalert/web.qtpl:106
 				qw422016.E().S(rr.Expression)
-//line app/vmalert/web.qtpl:95
+//line app/vmalert/web.qtpl:106
 				qw422016.N().S(`</pre></code>
                                 `)
-//line app/vmalert/web.qtpl:96
+//line app/vmalert/web.qtpl:107
 				if len(rr.Labels) > 0 {
-//line app/vmalert/web.qtpl:96
+//line app/vmalert/web.qtpl:107
 					qw422016.N().S(` <b>Labels:</b>`)
-//line app/vmalert/web.qtpl:96
+//line app/vmalert/web.qtpl:107
 				}
-//line app/vmalert/web.qtpl:96
+//line app/vmalert/web.qtpl:107
 				qw422016.N().S(`
                                 `)
-//line app/vmalert/web.qtpl:97
+//line app/vmalert/web.qtpl:108
 				for k, v := range rr.Labels {
-//line app/vmalert/web.qtpl:97
+//line app/vmalert/web.qtpl:108
 					qw422016.N().S(`
                                         <span class="ms-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:98
+//line app/vmalert/web.qtpl:109
 					qw422016.E().S(k)
-//line app/vmalert/web.qtpl:98
+//line app/vmalert/web.qtpl:109
 					qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:98
+//line app/vmalert/web.qtpl:109
 					qw422016.E().S(v)
-//line app/vmalert/web.qtpl:98
+//line app/vmalert/web.qtpl:109
 					qw422016.N().S(`</span>
                                 `)
-//line app/vmalert/web.qtpl:99
+//line app/vmalert/web.qtpl:110
 				}
-//line app/vmalert/web.qtpl:99
+//line app/vmalert/web.qtpl:110
 				qw422016.N().S(`
                             </td>
                             <td><div class="error-cell">`)
-//line app/vmalert/web.qtpl:101
+//line app/vmalert/web.qtpl:112
 				qw422016.E().S(rr.LastError)
-//line app/vmalert/web.qtpl:101
+//line app/vmalert/web.qtpl:112
 				qw422016.N().S(`</div></td>
                             <td>`)
-//line app/vmalert/web.qtpl:102
+//line app/vmalert/web.qtpl:113
 				qw422016.N().D(rr.LastSamples)
-//line app/vmalert/web.qtpl:102
+//line app/vmalert/web.qtpl:113
 				qw422016.N().S(`</td>
                             <td>`)
-//line app/vmalert/web.qtpl:103
+//line app/vmalert/web.qtpl:114
+				qw422016.N().FPrec(rr.LastExecDuration, 3)
+//line app/vmalert/web.qtpl:114
+				qw422016.N().S(`s</td>
+                            <td>`)
+//line app/vmalert/web.qtpl:115
 				qw422016.N().FPrec(time.Since(rr.LastExec).Seconds(), 3)
-//line app/vmalert/web.qtpl:103
+//line app/vmalert/web.qtpl:115
 				qw422016.N().S(`s ago</td>
                         </tr>
                     `)
-//line app/vmalert/web.qtpl:105
+//line app/vmalert/web.qtpl:117
 			}
-//line app/vmalert/web.qtpl:105
+//line app/vmalert/web.qtpl:117
 			qw422016.N().S(`
                  </tbody>
                 </table>
             </div>
         `)
-//line app/vmalert/web.qtpl:109
+//line app/vmalert/web.qtpl:121
 		}
-//line app/vmalert/web.qtpl:109
+//line app/vmalert/web.qtpl:121
 		qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:111
+//line app/vmalert/web.qtpl:123
 	} else {
-//line app/vmalert/web.qtpl:111
+//line app/vmalert/web.qtpl:123
 		qw422016.N().S(`
         <div>
             <p>No items...</p>
         </div>
     `)
-//line app/vmalert/web.qtpl:115
+//line app/vmalert/web.qtpl:127
 	}
-//line app/vmalert/web.qtpl:115
+//line app/vmalert/web.qtpl:127
 	qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:117
+//line app/vmalert/web.qtpl:129
 	tpl.StreamFooter(qw422016)
-//line app/vmalert/web.qtpl:117
+//line app/vmalert/web.qtpl:129
 	qw422016.N().S(`
 
 `)
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 }
 
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 func WriteListGroups(qq422016 qtio422016.Writer, groups []APIGroup) {
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 	StreamListGroups(qw422016, groups)
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 }
 
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 func ListGroups(groups []APIGroup) string {
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 	WriteListGroups(qb422016, groups)
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 	return qs422016
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:131
 }
 
-//line app/vmalert/web.qtpl:122
+//line app/vmalert/web.qtpl:134
 func StreamListAlerts(qw422016 *qt422016.Writer, groupAlerts []GroupAlerts) {
-//line app/vmalert/web.qtpl:122
+//line app/vmalert/web.qtpl:134
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:123
+//line app/vmalert/web.qtpl:135
 	tpl.StreamHeader(qw422016, "Alerts", navItems)
-//line app/vmalert/web.qtpl:123
+//line app/vmalert/web.qtpl:135
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:124
+//line app/vmalert/web.qtpl:136
 	if len(groupAlerts) > 0 {
-//line app/vmalert/web.qtpl:124
+//line app/vmalert/web.qtpl:136
 		qw422016.N().S(`
          <a class="btn btn-primary" role="button" onclick="collapseAll()">Collapse All</a>
          <a class="btn btn-primary" role="button" onclick="expandAll()">Expand All</a>
          `)
-//line app/vmalert/web.qtpl:127
+//line app/vmalert/web.qtpl:139
 		for _, ga := range groupAlerts {
-//line app/vmalert/web.qtpl:127
+//line app/vmalert/web.qtpl:139
 			qw422016.N().S(`
             `)
-//line app/vmalert/web.qtpl:128
+//line app/vmalert/web.qtpl:140
 			g := ga.Group
 
-//line app/vmalert/web.qtpl:128
+//line app/vmalert/web.qtpl:140
 			qw422016.N().S(`
             <div class="group-heading alert-danger" data-bs-target="rules-`)
-//line app/vmalert/web.qtpl:129
+//line app/vmalert/web.qtpl:141
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:129
+//line app/vmalert/web.qtpl:141
 			qw422016.N().S(`">
                 <span class="anchor" id="group-`)
-//line app/vmalert/web.qtpl:130
+//line app/vmalert/web.qtpl:142
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:130
+//line app/vmalert/web.qtpl:142
 			qw422016.N().S(`"></span>
                 <a href="#group-`)
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:143
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:143
 			qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:143
 			qw422016.E().S(g.Name)
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:143
 			if g.Type != "prometheus" {
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:143
 				qw422016.N().S(` (`)
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:143
 				qw422016.E().S(g.Type)
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:143
 				qw422016.N().S(`)`)
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:143
 			}
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:143
 			qw422016.N().S(`</a>
                 <span class="badge bg-danger" title="Number of active alerts">`)
-//line app/vmalert/web.qtpl:132
+//line app/vmalert/web.qtpl:144
 			qw422016.N().D(len(ga.Alerts))
-//line app/vmalert/web.qtpl:132
+//line app/vmalert/web.qtpl:144
 			qw422016.N().S(`</span>
                 <br>
                 <p class="fs-6 fw-lighter">`)
-//line app/vmalert/web.qtpl:134
+//line app/vmalert/web.qtpl:146
 			qw422016.E().S(g.File)
-//line app/vmalert/web.qtpl:134
+//line app/vmalert/web.qtpl:146
 			qw422016.N().S(`</p>
             </div>
             `)
-//line app/vmalert/web.qtpl:137
+//line app/vmalert/web.qtpl:149
 			var keys []string
 			alertsByRule := make(map[string][]*APIAlert)
 			for _, alert := range ga.Alerts {
@@ -541,20 +625,20 @@ func StreamListAlerts(qw422016 *qt422016.Writer, groupAlerts []GroupAlerts) {
 			}
 			sort.Strings(keys)
 
-//line app/vmalert/web.qtpl:146
+//line app/vmalert/web.qtpl:158
 			qw422016.N().S(`
             <div class="collapse" id="rules-`)
-//line app/vmalert/web.qtpl:147
+//line app/vmalert/web.qtpl:159
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:147
+//line app/vmalert/web.qtpl:159
 			qw422016.N().S(`">
                 `)
-//line app/vmalert/web.qtpl:148
+//line app/vmalert/web.qtpl:160
 			for _, ruleID := range keys {
-//line app/vmalert/web.qtpl:148
+//line app/vmalert/web.qtpl:160
 				qw422016.N().S(`
                     `)
-//line app/vmalert/web.qtpl:150
+//line app/vmalert/web.qtpl:162
 				defaultAR := alertsByRule[ruleID][0]
 				var labelKeys []string
 				for k := range defaultAR.Labels {
@@ -562,22 +646,22 @@ func StreamListAlerts(qw422016 *qt422016.Writer, groupAlerts []GroupAlerts) {
 				}
 				sort.Strings(labelKeys)
 
-//line app/vmalert/web.qtpl:156
+//line app/vmalert/web.qtpl:168
 				qw422016.N().S(`
                     <br>
                     <b>alert:</b> `)
-//line app/vmalert/web.qtpl:158
+//line app/vmalert/web.qtpl:170
 				qw422016.E().S(defaultAR.Name)
-//line app/vmalert/web.qtpl:158
+//line app/vmalert/web.qtpl:170
 				qw422016.N().S(` (`)
-//line app/vmalert/web.qtpl:158
+//line app/vmalert/web.qtpl:170
 				qw422016.N().D(len(alertsByRule[ruleID]))
-//line app/vmalert/web.qtpl:158
+//line app/vmalert/web.qtpl:170
 				qw422016.N().S(`)<br>
                     <b>expr:</b><code><pre>`)
-//line app/vmalert/web.qtpl:159
+//line app/vmalert/web.qtpl:171
 				qw422016.E().S(defaultAR.Expression)
-//line app/vmalert/web.qtpl:159
+//line app/vmalert/web.qtpl:171
 				qw422016.N().S(`</pre></code>
                     <table class="table table-striped table-hover table-sm">
                         <thead>
@@ -591,152 +675,152 @@ func StreamListAlerts(qw422016 *qt422016.Writer, groupAlerts []GroupAlerts) {
                         </thead>
                         <tbody>
                         `)
-//line app/vmalert/web.qtpl:171
+//line app/vmalert/web.qtpl:183
 				for _, ar := range alertsByRule[ruleID] {
-//line app/vmalert/web.qtpl:171
+//line app/vmalert/web.qtpl:183
 					qw422016.N().S(`
                             <tr>
                                 <td>
                                     `)
-//line app/vmalert/web.qtpl:174
+//line app/vmalert/web.qtpl:186
 					for _, k := range labelKeys {
-//line app/vmalert/web.qtpl:174
+//line app/vmalert/web.qtpl:186
 						qw422016.N().S(`
                                         <span class="ms-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:175
+//line app/vmalert/web.qtpl:187
 						qw422016.E().S(k)
-//line app/vmalert/web.qtpl:175
+//line app/vmalert/web.qtpl:187
 						qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:175
+//line app/vmalert/web.qtpl:187
 						qw422016.E().S(ar.Labels[k])
-//line app/vmalert/web.qtpl:175
+//line app/vmalert/web.qtpl:187
 						qw422016.N().S(`</span>
                                     `)
-//line app/vmalert/web.qtpl:176
+//line app/vmalert/web.qtpl:188
 					}
-//line app/vmalert/web.qtpl:176
+//line app/vmalert/web.qtpl:188
 					qw422016.N().S(`
                                 </td>
                                 <td><span class="badge `)
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:190
 					if ar.State == "firing" {
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:190
 						qw422016.N().S(`bg-danger`)
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:190
 					} else {
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:190
 						qw422016.N().S(` bg-warning text-dark`)
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:190
 					}
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:190
 					qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:190
 					qw422016.E().S(ar.State)
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:190
 					qw422016.N().S(`</span></td>
                                 <td>`)
-//line app/vmalert/web.qtpl:179
+//line app/vmalert/web.qtpl:191
 					qw422016.E().S(ar.ActiveAt.Format("2006-01-02T15:04:05Z07:00"))
-//line app/vmalert/web.qtpl:179
+//line app/vmalert/web.qtpl:191
 					qw422016.N().S(`</td>
                                 <td>`)
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:192
 					qw422016.E().S(ar.Value)
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:192
 					qw422016.N().S(`</td>
                                 <td>
                                     <a href="/`)
-//line app/vmalert/web.qtpl:182
+//line app/vmalert/web.qtpl:194
 					qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:182
+//line app/vmalert/web.qtpl:194
 					qw422016.N().S(`/`)
-//line app/vmalert/web.qtpl:182
+//line app/vmalert/web.qtpl:194
 					qw422016.E().S(ar.ID)
-//line app/vmalert/web.qtpl:182
+//line app/vmalert/web.qtpl:194
 					qw422016.N().S(`/status">Details</a>
                                 </td>
                             </tr>
                         `)
-//line app/vmalert/web.qtpl:185
+//line app/vmalert/web.qtpl:197
 				}
-//line app/vmalert/web.qtpl:185
+//line app/vmalert/web.qtpl:197
 				qw422016.N().S(`
                      </tbody>
                     </table>
                 `)
-//line app/vmalert/web.qtpl:188
+//line app/vmalert/web.qtpl:200
 			}
-//line app/vmalert/web.qtpl:188
+//line app/vmalert/web.qtpl:200
 			qw422016.N().S(`
             </div>
             <br>
         `)
-//line app/vmalert/web.qtpl:191
+//line app/vmalert/web.qtpl:203
 		}
-//line app/vmalert/web.qtpl:191
+//line app/vmalert/web.qtpl:203
 		qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:193
+//line app/vmalert/web.qtpl:205
 	} else {
-//line app/vmalert/web.qtpl:193
+//line app/vmalert/web.qtpl:205
 		qw422016.N().S(`
         <div>
             <p>No items...</p>
         </div>
     `)
-//line app/vmalert/web.qtpl:197
+//line app/vmalert/web.qtpl:209
 	}
-//line app/vmalert/web.qtpl:197
+//line app/vmalert/web.qtpl:209
 	qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:199
+//line app/vmalert/web.qtpl:211
 	tpl.StreamFooter(qw422016)
-//line app/vmalert/web.qtpl:199
+//line app/vmalert/web.qtpl:211
 	qw422016.N().S(`
 
 `)
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 }
 
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 func WriteListAlerts(qq422016 qtio422016.Writer, groupAlerts []GroupAlerts) {
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 	StreamListAlerts(qw422016, groupAlerts)
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 }
 
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 func ListAlerts(groupAlerts []GroupAlerts) string {
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 	WriteListAlerts(qb422016, groupAlerts)
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 	return qs422016
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:213
 }
 
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:215
 func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:215
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:204
+//line app/vmalert/web.qtpl:216
 	tpl.StreamHeader(qw422016, "", navItems)
-//line app/vmalert/web.qtpl:204
+//line app/vmalert/web.qtpl:216
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:206
+//line app/vmalert/web.qtpl:218
 	var labelKeys []string
 	for k := range alert.Labels {
 		labelKeys = append(labelKeys, k)
@@ -749,28 +833,28 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
 	}
 	sort.Strings(annotationKeys)
 
-//line app/vmalert/web.qtpl:217
+//line app/vmalert/web.qtpl:229
 	qw422016.N().S(`
     <div class="display-6 pb-3 mb-3">`)
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 	qw422016.E().S(alert.Name)
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 	qw422016.N().S(`<span class="ms-2 badge `)
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 	if alert.State == "firing" {
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 		qw422016.N().S(`bg-danger`)
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 	} else {
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 		qw422016.N().S(` bg-warning text-dark`)
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 	}
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 	qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 	qw422016.E().S(alert.State)
-//line app/vmalert/web.qtpl:218
+//line app/vmalert/web.qtpl:230
 	qw422016.N().S(`</span></div>
     <div class="container border-bottom p-2">
       <div class="row">
@@ -779,9 +863,9 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
           `)
-//line app/vmalert/web.qtpl:225
+//line app/vmalert/web.qtpl:237
 	qw422016.E().S(alert.ActiveAt.Format("2006-01-02T15:04:05Z07:00"))
-//line app/vmalert/web.qtpl:225
+//line app/vmalert/web.qtpl:237
 	qw422016.N().S(`
         </div>
       </div>
@@ -793,9 +877,9 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
           <code><pre>`)
-//line app/vmalert/web.qtpl:235
+//line app/vmalert/web.qtpl:247
 	qw422016.E().S(alert.Expression)
-//line app/vmalert/web.qtpl:235
+//line app/vmalert/web.qtpl:247
 	qw422016.N().S(`</pre></code>
         </div>
       </div>
@@ -807,23 +891,23 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
            `)
-//line app/vmalert/web.qtpl:245
+//line app/vmalert/web.qtpl:257
 	for _, k := range labelKeys {
-//line app/vmalert/web.qtpl:245
+//line app/vmalert/web.qtpl:257
 		qw422016.N().S(`
                 <span class="m-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:246
+//line app/vmalert/web.qtpl:258
 		qw422016.E().S(k)
-//line app/vmalert/web.qtpl:246
+//line app/vmalert/web.qtpl:258
 		qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:246
+//line app/vmalert/web.qtpl:258
 		qw422016.E().S(alert.Labels[k])
-//line app/vmalert/web.qtpl:246
+//line app/vmalert/web.qtpl:258
 		qw422016.N().S(`</span>
           `)
-//line app/vmalert/web.qtpl:247
+//line app/vmalert/web.qtpl:259
 	}
-//line app/vmalert/web.qtpl:247
+//line app/vmalert/web.qtpl:259
 	qw422016.N().S(`
         </div>
       </div>
@@ -835,24 +919,24 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
            `)
-//line app/vmalert/web.qtpl:257
+//line app/vmalert/web.qtpl:269
 	for _, k := range annotationKeys {
-//line app/vmalert/web.qtpl:257
+//line app/vmalert/web.qtpl:269
 		qw422016.N().S(`
                 <b>`)
-//line app/vmalert/web.qtpl:258
+//line app/vmalert/web.qtpl:270
 		qw422016.E().S(k)
-//line app/vmalert/web.qtpl:258
+//line app/vmalert/web.qtpl:270
 		qw422016.N().S(`:</b><br>
                 <p>`)
-//line app/vmalert/web.qtpl:259
+//line app/vmalert/web.qtpl:271
 		qw422016.E().S(alert.Annotations[k])
-//line app/vmalert/web.qtpl:259
+//line app/vmalert/web.qtpl:271
 		qw422016.N().S(`</p>
           `)
-//line app/vmalert/web.qtpl:260
+//line app/vmalert/web.qtpl:272
 	}
-//line app/vmalert/web.qtpl:260
+//line app/vmalert/web.qtpl:272
 	qw422016.N().S(`
         </div>
       </div>
@@ -864,49 +948,49 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
            <a target="_blank" href="/groups#group-`)
-//line app/vmalert/web.qtpl:270
+//line app/vmalert/web.qtpl:282
 	qw422016.E().S(alert.GroupID)
-//line app/vmalert/web.qtpl:270
+//line app/vmalert/web.qtpl:282
 	qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:270
+//line app/vmalert/web.qtpl:282
 	qw422016.E().S(alert.GroupID)
-//line app/vmalert/web.qtpl:270
+//line app/vmalert/web.qtpl:282
 	qw422016.N().S(`</a>
         </div>
       </div>
     </div>
     `)
-//line app/vmalert/web.qtpl:274
+//line app/vmalert/web.qtpl:286
 	tpl.StreamFooter(qw422016)
-//line app/vmalert/web.qtpl:274
+//line app/vmalert/web.qtpl:286
 	qw422016.N().S(`
 
 `)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 }
 
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 func WriteAlert(qq422016 qtio422016.Writer, alert *APIAlert) {
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 	StreamAlert(qw422016, alert)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 }
 
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 func Alert(alert *APIAlert) string {
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 	WriteAlert(qb422016, alert)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 	return qs422016
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:288
 }
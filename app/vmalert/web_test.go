@@ -2,10 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 )
@@ -79,3 +81,113 @@ func TestHandler(t *testing.T) {
 		getResp(ts.URL, nil, 200)
 	})
 }
+
+func TestHandlerRules(t *testing.T) {
+	ar := &AlertingRule{
+		Name: "alert",
+		Expr: "up == 0",
+		For:  time.Minute,
+		alerts: map[uint64]*notifier.Alert{
+			1: {ID: 1, Name: "alert", State: notifier.StatePending, Labels: map[string]string{"job": "foo"}},
+			2: {ID: 2, Name: "alert", State: notifier.StateFiring},
+			3: {ID: 3, Name: "alert", State: notifier.StateInactive},
+		},
+		lastExecTime:     time.Now(),
+		lastExecDuration: time.Second,
+	}
+	rr := &RecordingRule{
+		Name:          "record",
+		Expr:          "sum(up)",
+		lastExecTime:  time.Now(),
+		lastExecError: fmt.Errorf("query error"),
+	}
+	m := &manager{groups: make(map[uint64]*Group)}
+	m.groups[0] = &Group{
+		Name:     "group",
+		File:     "rules.yaml",
+		Interval: 30 * time.Second,
+		Rules:    []Rule{ar, rr},
+	}
+	rh := &requestHandler{m: m}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { rh.handler(w, r) }))
+	defer ts.Close()
+
+	getRules := func(query string, code int) []map[string]interface{} {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/v1/rules" + query)
+		if err != nil {
+			t.Fatalf("unexpected err %s", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != code {
+			t.Fatalf("unexpected status code %d want %d", resp.StatusCode, code)
+		}
+		if code != http.StatusOK {
+			return nil
+		}
+		var lr struct {
+			Status string `json:"status"`
+			Data   struct {
+				Groups []struct {
+					Name     string                   `json:"name"`
+					File     string                   `json:"file"`
+					Interval float64                  `json:"interval"`
+					Rules    []map[string]interface{} `json:"rules"`
+				} `json:"groups"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&lr); err != nil {
+			t.Fatalf("unexpected err %s", err)
+		}
+		if lr.Status != "success" {
+			t.Fatalf("unexpected status %q", lr.Status)
+		}
+		if len(lr.Data.Groups) == 0 {
+			return nil
+		}
+		if len(lr.Data.Groups) != 1 {
+			t.Fatalf("expected 1 group; got %d", len(lr.Data.Groups))
+		}
+		g := lr.Data.Groups[0]
+		if g.Name != "group" || g.File != "rules.yaml" || g.Interval != 30 {
+			t.Fatalf("unexpected group %+v", g)
+		}
+		return g.Rules
+	}
+
+	rules := getRules("", http.StatusOK)
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules; got %d", len(rules))
+	}
+	alerting, recording := rules[0], rules[1]
+	if alerting["type"] != "alerting" || alerting["state"] != "firing" || alerting["health"] != "ok" ||
+		alerting["duration"] != float64(60) || alerting["evaluationTime"] != float64(1) {
+		t.Fatalf("unexpected alerting rule %v", alerting)
+	}
+	alerts := alerting["alerts"].([]interface{})
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 active alerts; got %d", len(alerts))
+	}
+	labels := alerts[0].(map[string]interface{})["labels"].(map[string]interface{})
+	if labels["alertname"] != "alert" || labels["job"] != "foo" {
+		t.Fatalf("unexpected alert labels %v", labels)
+	}
+	if recording["type"] != "recording" || recording["health"] != "err" || recording["lastError"] != "query error" {
+		t.Fatalf("unexpected recording rule %v", recording)
+	}
+
+	rules = getRules("?type=alert", http.StatusOK)
+	if len(rules) != 1 || rules[0]["name"] != "alert" {
+		t.Fatalf("unexpected rules for type=alert: %v", rules)
+	}
+	rules = getRules("?type=record", http.StatusOK)
+	if len(rules) != 1 || rules[0]["name"] != "record" {
+		t.Fatalf("unexpected rules for type=record: %v", rules)
+	}
+	getRules("?type=foo", http.StatusBadRequest)
+
+	m.groups[0].Rules = []Rule{rr}
+	if rules := getRules("?type=alert", http.StatusOK); len(rules) != 0 {
+		t.Fatalf("expected no groups for type=alert; got rules %v", rules)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"net/url"
 	"time"
 )
 
@@ -17,6 +18,9 @@ type APIAlert struct {
 	Labels      map[string]string `json:"labels"`
 	Annotations map[string]string `json:"annotations"`
 	ActiveAt    time.Time         `json:"activeAt"`
+	// KeepFiringSince is set only for alerts which keep firing
+	// according to keep_firing_for after the rule's expression stopped returning them
+	KeepFiringSince *time.Time `json:"keepFiringSince,omitempty"`
 }
 
 // APIGroup represents Group for WEB view
@@ -29,36 +33,47 @@ type APIGroup struct {
 	Concurrency       int                `json:"concurrency"`
 	ExtraFilterLabels map[string]string  `json:"extra_filter_labels"`
 	Labels            map[string]string  `json:"labels,omitempty"`
+	Params            url.Values         `json:"params,omitempty"`
+	Tenant            string             `json:"tenant,omitempty"`
 	AlertingRules     []APIAlertingRule  `json:"alerting_rules"`
 	RecordingRules    []APIRecordingRule `json:"recording_rules"`
 }
 
 // APIAlertingRule represents AlertingRule for WEB view
 type APIAlertingRule struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Type        string            `json:"type"`
-	GroupID     string            `json:"group_id"`
-	Expression  string            `json:"expression"`
-	For         string            `json:"for"`
-	LastError   string            `json:"last_error"`
-	LastSamples int               `json:"last_samples"`
-	LastExec    time.Time         `json:"last_exec"`
-	Labels      map[string]string `json:"labels"`
-	Annotations map[string]string `json:"annotations"`
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Type             string            `json:"type"`
+	GroupID          string            `json:"group_id"`
+	Expression       string            `json:"expression"`
+	For              string            `json:"for"`
+	KeepFiringFor    string            `json:"keep_firing_for"`
+	Debug            bool              `json:"debug"`
+	Limit            int               `json:"limit"`
+	OnError          string            `json:"on_error"`
+	OnNoData         string            `json:"on_no_data"`
+	LastError        string            `json:"last_error"`
+	LastSamples      int               `json:"last_samples"`
+	LastExec         time.Time         `json:"last_exec"`
+	LastExecDuration float64           `json:"last_exec_duration"`
+	Labels           map[string]string `json:"labels"`
+	Annotations      map[string]string `json:"annotations"`
 }
 
 // APIRecordingRule represents RecordingRule for WEB view
 type APIRecordingRule struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Type        string            `json:"type"`
-	GroupID     string            `json:"group_id"`
-	Expression  string            `json:"expression"`
-	LastError   string            `json:"last_error"`
-	LastSamples int               `json:"last_samples"`
-	LastExec    time.Time         `json:"last_exec"`
-	Labels      map[string]string `json:"labels"`
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Type             string            `json:"type"`
+	GroupID          string            `json:"group_id"`
+	Expression       string            `json:"expression"`
+	LastError        string            `json:"last_error"`
+	LastSamples      int               `json:"last_samples"`
+	LastExec         time.Time         `json:"last_exec"`
+	LastExecDuration float64           `json:"last_exec_duration"`
+	Labels           map[string]string `json:"labels"`
+	Debug            bool              `json:"debug"`
+	Limit            int               `json:"limit"`
 }
 
 // GroupAlerts represents a group of alerts for WEB view
@@ -66,3 +81,54 @@ type GroupAlerts struct {
 	Group  APIGroup
 	Alerts []*APIAlert
 }
+
+// PromGroup represents Group in Prometheus-compatible format.
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#rules
+type PromGroup struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	// Rules contains PromAlertingRule and PromRecordingRule objects
+	Rules []interface{} `json:"rules"`
+	// Interval is the evaluation interval in seconds
+	Interval float64 `json:"interval"`
+	// EvaluationTime is the duration of the last evaluation in seconds
+	EvaluationTime float64   `json:"evaluationTime"`
+	LastEvaluation time.Time `json:"lastEvaluation"`
+}
+
+// PromAlertingRule represents AlertingRule in Prometheus-compatible format
+type PromAlertingRule struct {
+	// State is the most severe state among rule's alerts:
+	// "firing", "pending" or "inactive"
+	State string `json:"state"`
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// Duration is the rule's `for` param in seconds
+	Duration float64 `json:"duration"`
+	// KeepFiringFor is the rule's `keep_firing_for` param in seconds
+	KeepFiringFor float64           `json:"keepFiringFor"`
+	Labels        map[string]string `json:"labels"`
+	Annotations   map[string]string `json:"annotations"`
+	Alerts        []*APIAlert       `json:"alerts"`
+	// Health is one of "ok", "err" or "unknown"
+	Health         string    `json:"health"`
+	LastError      string    `json:"lastError,omitempty"`
+	EvaluationTime float64   `json:"evaluationTime"`
+	LastEvaluation time.Time `json:"lastEvaluation"`
+	// Type is always "alerting"
+	Type string `json:"type"`
+}
+
+// PromRecordingRule represents RecordingRule in Prometheus-compatible format
+type PromRecordingRule struct {
+	Name   string            `json:"name"`
+	Query  string            `json:"query"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Health is one of "ok", "err" or "unknown"
+	Health         string    `json:"health"`
+	LastError      string    `json:"lastError,omitempty"`
+	EvaluationTime float64   `json:"evaluationTime"`
+	LastEvaluation time.Time `json:"lastEvaluation"`
+	// Type is always "recording"
+	Type string `json:"type"`
+}
@@ -244,6 +244,7 @@ var (
 
 const (
 	promSnapshot         = "prom-snapshot"
+	promBlock            = "prom-block"
 	promConcurrency      = "prom-concurrency"
 	promFilterTimeStart  = "prom-filter-time-start"
 	promFilterTimeEnd    = "prom-filter-time-end"
@@ -254,9 +255,15 @@ const (
 var (
 	promFlags = []cli.Flag{
 		&cli.StringFlag{
-			Name:     promSnapshot,
-			Usage:    "Path to Prometheus snapshot. Pls see for details https://www.robustperception.io/taking-snapshots-of-prometheus-data",
-			Required: true,
+			Name: promSnapshot,
+			Usage: "Path to Prometheus snapshot or to Prometheus data directory. Pls see for details https://www.robustperception.io/taking-snapshots-of-prometheus-data . " +
+				fmt.Sprintf("Either %q or %q flag must be set", promSnapshot, promBlock),
+		},
+		&cli.StringSliceFlag{
+			Name: promBlock,
+			Usage: "Path to Prometheus TSDB block directory containing meta.json, index and chunks. " +
+				"The flag can be set multiple times for importing multiple blocks. " +
+				fmt.Sprintf("Blocks are imported together with blocks from %q if it is set", promSnapshot),
 		},
 		&cli.IntFlag{
 			Name:  promConcurrency,
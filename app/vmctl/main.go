@@ -107,6 +107,7 @@ func main() {
 
 					promCfg := prometheus.Config{
 						Snapshot: c.String(promSnapshot),
+						Blocks:   c.StringSlice(promBlock),
 						Filter: prometheus.Filter{
 							TimeMin:    c.String(promFilterTimeStart),
 							TimeMax:    c.String(promFilterTimeEnd),
@@ -118,6 +119,7 @@ func main() {
 					if err != nil {
 						return fmt.Errorf("failed to create prometheus client: %s", err)
 					}
+					defer func() { _ = cl.Close() }()
 					pp := prometheusProcessor{
 						cl: cl,
 						im: importer,
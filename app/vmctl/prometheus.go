@@ -86,10 +86,11 @@ func (pp *prometheusProcessor) run(silent bool) error {
 }
 
 func (pp *prometheusProcessor) do(b tsdb.BlockReader) error {
-	ss, err := pp.cl.Read(b)
+	q, ss, err := pp.cl.Read(b)
 	if err != nil {
 		return fmt.Errorf("failed to read block: %s", err)
 	}
+	defer func() { _ = q.Close() }()
 	for ss.Next() {
 		var name string
 		var labels []vm.LabelPair
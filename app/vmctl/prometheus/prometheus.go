@@ -2,6 +2,7 @@ package prometheus
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/prometheus/prometheus/pkg/labels"
@@ -15,6 +16,10 @@ type Config struct {
 	// Path to snapshot directory
 	Snapshot string
 
+	// Paths to Prometheus TSDB block directories.
+	// Every directory must contain meta.json, index and chunks.
+	Blocks []string
+
 	Filter Filter
 }
 
@@ -29,7 +34,12 @@ type Filter struct {
 
 // Client is a wrapper over Prometheus tsdb.DBReader
 type Client struct {
-	*tsdb.DBReadOnly
+	// db is nil if Config.Snapshot isn't set
+	db *tsdb.DBReadOnly
+
+	// blocks contains blocks opened from Config.Blocks
+	blocks []*tsdb.Block
+
 	filter filter
 }
 
@@ -53,15 +63,29 @@ func (f filter) inRange(min, max int64) bool {
 // NewClient creates and validates new Client
 // with given Config
 func NewClient(cfg Config) (*Client, error) {
-	db, err := tsdb.OpenDBReadOnly(cfg.Snapshot, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open snapshot %q: %s", cfg.Snapshot, err)
+	if cfg.Snapshot == "" && len(cfg.Blocks) == 0 {
+		return nil, fmt.Errorf("either path to snapshot or paths to blocks must be set")
 	}
-	c := &Client{DBReadOnly: db}
 	min, max, err := parseTime(cfg.Filter.TimeMin, cfg.Filter.TimeMax)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse time in filter: %s", err)
 	}
+	c := &Client{}
+	if cfg.Snapshot != "" {
+		db, err := tsdb.OpenDBReadOnly(cfg.Snapshot, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open snapshot %q: %s", cfg.Snapshot, err)
+		}
+		c.db = db
+	}
+	for _, path := range cfg.Blocks {
+		b, err := tsdb.OpenBlock(nil, path, nil)
+		if err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("failed to open block %q: %s", path, err)
+		}
+		c.blocks = append(c.blocks, b)
+	}
 	c.filter = filter{
 		min:        min,
 		max:        max,
@@ -71,16 +95,49 @@ func NewClient(cfg Config) (*Client, error) {
 	return c, nil
 }
 
+// Close releases resources occupied by c.
+func (c *Client) Close() error {
+	var firstErr error
+	for _, b := range c.blocks {
+		if err := b.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.blocks = nil
+	if c.db != nil {
+		if err := c.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		c.db = nil
+	}
+	return firstErr
+}
+
 // Explore fetches all available blocks from a snapshot
+// and from the given block directories
 // and collects the Meta() data from each block.
 // Explore does initial filtering by time-range
 // for snapshot blocks but does not take into account
 // label filters.
+//
+// Blocks are returned in the order of their min time.
+// Blocks with the same ULID are returned only once.
 func (c *Client) Explore() ([]tsdb.BlockReader, error) {
-	blocks, err := c.Blocks()
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch blocks: %s", err)
+	var blocks []tsdb.BlockReader
+	if c.db != nil {
+		bs, err := c.db.Blocks()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch blocks: %s", err)
+		}
+		blocks = append(blocks, bs...)
 	}
+	for _, b := range c.blocks {
+		blocks = append(blocks, b)
+	}
+	blocks = uniqBlocks(blocks)
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].Meta().MinTime < blocks[j].Meta().MinTime
+	})
 	s := &Stats{
 		Filtered: c.filter.min != 0 || c.filter.max != 0 || c.filter.label != "",
 		Blocks:   len(blocks),
@@ -106,9 +163,25 @@ func (c *Client) Explore() ([]tsdb.BlockReader, error) {
 	return blocksToImport, nil
 }
 
+func uniqBlocks(blocks []tsdb.BlockReader) []tsdb.BlockReader {
+	m := make(map[string]bool, len(blocks))
+	dst := blocks[:0]
+	for _, b := range blocks {
+		id := b.Meta().ULID.String()
+		if m[id] {
+			continue
+		}
+		m[id] = true
+		dst = append(dst, b)
+	}
+	return dst
+}
+
 // Read reads the given BlockReader according to configured
 // time and label filters.
-func (c *Client) Read(block tsdb.BlockReader) (storage.SeriesSet, error) {
+//
+// The returned querier must be closed after reading the returned series set.
+func (c *Client) Read(block tsdb.BlockReader) (storage.Querier, storage.SeriesSet, error) {
 	minTime, maxTime := block.Meta().MinTime, block.Meta().MaxTime
 	if c.filter.min != 0 {
 		minTime = c.filter.min
@@ -118,10 +191,10 @@ func (c *Client) Read(block tsdb.BlockReader) (storage.SeriesSet, error) {
 	}
 	q, err := tsdb.NewBlockQuerier(block, minTime, maxTime)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	ss := q.Select(false, nil, labels.MustNewMatcher(labels.MatchRegexp, c.filter.label, c.filter.labelValue))
-	return ss, nil
+	return q, ss, nil
 }
 
 func parseTime(start, end string) (int64, int64, error) {
@@ -1,7 +1,17 @@
 package prometheus
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
 )
 
 func TestInRange(t *testing.T) {
@@ -32,3 +42,110 @@ func TestInRange(t *testing.T) {
 		}
 	}
 }
+
+func TestClientBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vmctl-prometheus-blocks")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	blockPath1 := writeTestBlock(t, dir, "foo", []int64{1000, 2000, 3000})
+	blockPath2 := writeTestBlock(t, dir, "bar", []int64{100, 200})
+
+	// The same block passed twice must be imported only once.
+	// Blocks from the snapshot must be deduplicated with blocks passed explicitly.
+	c, err := NewClient(Config{
+		Snapshot: dir,
+		Blocks:   []string{blockPath1, blockPath1, blockPath2},
+		Filter: Filter{
+			LabelValue: ".*",
+		},
+	})
+	if err != nil {
+		t.Fatalf("cannot create client: %s", err)
+	}
+	defer func() { _ = c.Close() }()
+	blocks, err := c.Explore()
+	if err != nil {
+		t.Fatalf("cannot explore blocks: %s", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("unexpected number of blocks; got %d; want 2", len(blocks))
+	}
+	var result []string
+	for _, b := range blocks {
+		q, ss, err := c.Read(b)
+		if err != nil {
+			t.Fatalf("cannot read block: %s", err)
+		}
+		for ss.Next() {
+			series := ss.At()
+			it := series.Iterator()
+			for it.Next() {
+				ts, v := it.At()
+				result = append(result, fmt.Sprintf("%s %d %g", series.Labels().Get("__name__"), ts, v))
+			}
+			if err := it.Err(); err != nil {
+				t.Fatalf("cannot iterate over samples: %s", err)
+			}
+		}
+		if err := ss.Err(); err != nil {
+			t.Fatalf("cannot read series: %s", err)
+		}
+		if err := q.Close(); err != nil {
+			t.Fatalf("cannot close querier: %s", err)
+		}
+	}
+	// Blocks must be ordered by min time and samples must have original timestamps.
+	resultExpected := []string{
+		"bar 100 0",
+		"bar 200 1",
+		"foo 1000 0",
+		"foo 2000 1",
+		"foo 3000 2",
+	}
+	if !reflect.DeepEqual(result, resultExpected) {
+		t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+}
+
+func TestNewClientFailure(t *testing.T) {
+	f := func(cfg Config) {
+		t.Helper()
+		c, err := NewClient(cfg)
+		if err == nil {
+			_ = c.Close()
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	// Neither snapshot nor blocks
+	f(Config{})
+	// Missing snapshot
+	f(Config{Snapshot: "/non-existing-path"})
+	// Missing block
+	f(Config{Blocks: []string{"/non-existing-path"}})
+}
+
+func writeTestBlock(t *testing.T, dir, metricName string, timestamps []int64) string {
+	t.Helper()
+	w, err := tsdb.NewBlockWriter(log.NewNopLogger(), dir, tsdb.DefaultBlockDuration)
+	if err != nil {
+		t.Fatalf("cannot create block writer: %s", err)
+	}
+	defer func() { _ = w.Close() }()
+	app := w.Appender(context.Background())
+	for i, ts := range timestamps {
+		if _, err := app.Add(labels.FromStrings("__name__", metricName, "job", "test"), ts, float64(i)); err != nil {
+			t.Fatalf("cannot add sample: %s", err)
+		}
+	}
+	if err := app.Commit(); err != nil {
+		t.Fatalf("cannot commit samples: %s", err)
+	}
+	id, err := w.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("cannot flush block: %s", err)
+	}
+	return filepath.Join(dir, id.String())
+}
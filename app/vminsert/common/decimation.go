@@ -0,0 +1,209 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cespare/xxhash/v2"
+)
+
+var decimationIntervals = flagutil.NewArray("decimation.interval", "Optional interval for leaving only the first sample per each time series on every interval "+
+	"for data ingested via the given protocol. The interval must be set in the format <protocol>:<interval>, for example, influx:10s . "+
+	"Supported protocols: "+strings.Join(ingestionProtocols, ", ")+". "+
+	"See https://docs.victoriametrics.com/#ingestion-decimation")
+
+// ingestionProtocols contains protocol names supported by per-protocol settings such as -decimation.interval.
+//
+// The names match `type` label values for `vm_rows_inserted_total` metric.
+var ingestionProtocols = []string{
+	"arrow",
+	"csvimport",
+	"datadog",
+	"graphite",
+	"influx",
+	"native",
+	"opentsdb",
+	"opentsdbhttp",
+	"prometheus",
+	"promremotewrite",
+	"promscrape",
+	"pushgateway",
+	"statsd",
+	"vmimport",
+}
+
+// InitDecimation initializes per-protocol decimation according to -decimation.interval command-line flag.
+//
+// It must be called after flag.Parse.
+func InitDecimation() {
+	m, err := parseDecimationIntervals(*decimationIntervals)
+	if err != nil {
+		logger.Fatalf("cannot parse -decimation.interval: %s", err)
+	}
+	decimatorsLock.Lock()
+	decimators = m
+	decimatorsLock.Unlock()
+}
+
+func parseDecimationIntervals(a []string) (map[string]*decimator, error) {
+	m := make(map[string]*decimator)
+	for _, s := range a {
+		n := strings.IndexByte(s, ':')
+		if n < 0 {
+			return nil, fmt.Errorf("missing ':' in %q; expecting <protocol>:<interval>", s)
+		}
+		protocol := s[:n]
+		if !isSupportedIngestionProtocol(protocol) {
+			return nil, fmt.Errorf("unsupported protocol %q in %q; supported protocols: %s", protocol, s, strings.Join(ingestionProtocols, ", "))
+		}
+		if m[protocol] != nil {
+			return nil, fmt.Errorf("duplicate interval for protocol %q", protocol)
+		}
+		interval, err := time.ParseDuration(s[n+1:])
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse interval in %q: %w", s, err)
+		}
+		if interval < time.Millisecond {
+			return nil, fmt.Errorf("interval in %q must be at least 1ms", s)
+		}
+		m[protocol] = newDecimator(protocol, interval)
+	}
+	return m, nil
+}
+
+func isSupportedIngestionProtocol(protocol string) bool {
+	for _, p := range ingestionProtocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	decimators     map[string]*decimator
+	decimatorsLock sync.Mutex
+)
+
+// getDecimator returns decimator for the given protocol.
+//
+// nil is returned if decimation isn't configured for the given protocol.
+func getDecimator(protocol string) *decimator {
+	decimatorsLock.Lock()
+	d := decimators[protocol]
+	decimatorsLock.Unlock()
+	return d
+}
+
+const decimatorShardsCount = 16
+
+// decimator leaves only the first sample per each time series on every interval.
+type decimator struct {
+	intervalMs int64
+
+	// idleTimeout is the duration in seconds after which the series state is removed if no new samples are received for it.
+	idleTimeout uint64
+
+	shards [decimatorShardsCount]decimatorShard
+
+	rowsDropped *metrics.Counter
+}
+
+type decimatorShard struct {
+	mu sync.Mutex
+	m  map[uint64]*decimatorEntry
+
+	lastCleanupTime uint64
+}
+
+type decimatorEntry struct {
+	// bucket is the interval number for the last kept sample.
+	bucket int64
+
+	// lastSeen is the unix timestamp in seconds when the last sample for the series has been seen.
+	lastSeen uint64
+}
+
+func newDecimator(protocol string, interval time.Duration) *decimator {
+	idleTimeout := uint64(2 * interval / time.Second)
+	if idleTimeout < 60 {
+		idleTimeout = 60
+	}
+	d := &decimator{
+		intervalMs:  interval.Milliseconds(),
+		idleTimeout: idleTimeout,
+		rowsDropped: metrics.GetOrCreateCounter(fmt.Sprintf(`vm_decimation_rows_dropped_total{type=%q}`, protocol)),
+	}
+	for i := range d.shards {
+		d.shards[i].m = make(map[uint64]*decimatorEntry)
+	}
+	return d
+}
+
+// filter removes samples from mrs, which must be dropped according to d, and returns the remaining samples.
+func (d *decimator) filter(mrs []storage.MetricRow) []storage.MetricRow {
+	currentTime := fasttime.UnixTimestamp()
+	dst := mrs[:0]
+	for i := range mrs {
+		mr := &mrs[i]
+		if d.keep(mr, currentTime) {
+			dst = append(dst, *mr)
+		}
+	}
+	if n := len(mrs) - len(dst); n > 0 {
+		d.rowsDropped.Add(n)
+	}
+	return dst
+}
+
+func (d *decimator) keep(mr *storage.MetricRow, currentTime uint64) bool {
+	if decimal.IsStaleNaN(mr.Value) {
+		// Always keep staleness markers, since they must be propagated to the storage.
+		return true
+	}
+	bucket := mr.Timestamp / d.intervalMs
+	if mr.Timestamp < 0 && mr.Timestamp%d.intervalMs != 0 {
+		bucket--
+	}
+	h := xxhash.Sum64(mr.MetricNameRaw)
+	shard := &d.shards[h%decimatorShardsCount]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if currentTime-shard.lastCleanupTime > d.idleTimeout {
+		shard.cleanupLocked(currentTime, d.idleTimeout)
+	}
+	e := shard.m[h]
+	if e == nil {
+		shard.m[h] = &decimatorEntry{
+			bucket:   bucket,
+			lastSeen: currentTime,
+		}
+		return true
+	}
+	e.lastSeen = currentTime
+	if bucket == e.bucket {
+		return false
+	}
+	if bucket > e.bucket {
+		e.bucket = bucket
+	}
+	// Keep out of order samples from older intervals, since they may belong to backfilled data.
+	return true
+}
+
+func (shard *decimatorShard) cleanupLocked(currentTime, idleTimeout uint64) {
+	for h, e := range shard.m {
+		if currentTime-e.lastSeen > idleTimeout {
+			delete(shard.m, h)
+		}
+	}
+	shard.lastCleanupTime = currentTime
+}
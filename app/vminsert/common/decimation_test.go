@@ -0,0 +1,103 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestParseDecimationIntervalsSuccess(t *testing.T) {
+	f := func(a []string, intervalsExpected map[string]int64) {
+		t.Helper()
+		m, err := parseDecimationIntervals(a)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		intervals := make(map[string]int64, len(m))
+		for protocol, d := range m {
+			intervals[protocol] = d.intervalMs
+		}
+		if !reflect.DeepEqual(intervals, intervalsExpected) {
+			t.Fatalf("unexpected intervals; got %v; want %v", intervals, intervalsExpected)
+		}
+	}
+	f(nil, map[string]int64{})
+	f([]string{"influx:10s"}, map[string]int64{
+		"influx": 10000,
+	})
+	f([]string{"influx:1m", "graphite:500ms"}, map[string]int64{
+		"influx":   60000,
+		"graphite": 500,
+	})
+}
+
+func TestParseDecimationIntervalsFailure(t *testing.T) {
+	f := func(a []string) {
+		t.Helper()
+		if _, err := parseDecimationIntervals(a); err == nil {
+			t.Fatalf("expecting non-nil error for %q", a)
+		}
+	}
+	// Missing interval
+	f([]string{"influx"})
+	// Unknown protocol
+	f([]string{"foobar:10s"})
+	// Invalid interval
+	f([]string{"influx:foo"})
+	f([]string{"influx:0s"})
+	f([]string{"influx:-1s"})
+	// Duplicate protocol
+	f([]string{"influx:10s", "influx:20s"})
+}
+
+func TestDecimatorFilter(t *testing.T) {
+	d := newDecimator("test", 10*time.Second)
+	f := func(mrs []storage.MetricRow, timestampsExpected []int64) {
+		t.Helper()
+		var timestamps []int64
+		for _, mr := range d.filter(mrs) {
+			timestamps = append(timestamps, mr.Timestamp)
+		}
+		if !reflect.DeepEqual(timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps; got %v; want %v", timestamps, timestampsExpected)
+		}
+	}
+	row := func(metricName string, timestamp int64, value float64) storage.MetricRow {
+		return storage.MetricRow{
+			MetricNameRaw: []byte(metricName),
+			Timestamp:     timestamp,
+			Value:         value,
+		}
+	}
+
+	// The first sample per each interval is kept for every series
+	f([]storage.MetricRow{
+		row("foo", 10000, 1),
+		row("foo", 11000, 2),
+		row("bar", 12000, 3),
+		row("foo", 19999, 4),
+		row("foo", 20000, 5),
+		row("bar", 25000, 6),
+	}, []int64{10000, 12000, 20000, 25000})
+
+	// The state is preserved between calls
+	f([]storage.MetricRow{
+		row("foo", 21000, 1),
+		row("bar", 29000, 2),
+		row("foo", 30000, 3),
+	}, []int64{30000})
+
+	// Staleness markers are always kept
+	f([]storage.MetricRow{
+		row("foo", 31000, decimal.StaleNaN),
+	}, []int64{31000})
+
+	// Out of order samples from older intervals are kept
+	f([]storage.MetricRow{
+		row("foo", 5000, 1),
+		row("foo", 32000, 2),
+	}, []int64{5000})
+}
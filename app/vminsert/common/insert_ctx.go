@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -18,8 +19,16 @@ type InsertCtx struct {
 
 	mrs            []storage.MetricRow
 	metricNamesBuf []byte
+	histogramsBuf  []byte
+
+	ers          []storage.ExemplarRow
+	exemplarsBuf []byte
+	exemplars    []storage.Exemplar
 
 	relabelCtx relabel.Ctx
+
+	decimator       *decimator
+	timestampWindow *timestampWindow
 }
 
 // Reset resets ctx for future fill with rowsLen rows.
@@ -34,6 +43,7 @@ func (ctx *InsertCtx) Reset(rowsLen int) {
 	for i := range ctx.mrs {
 		mr := &ctx.mrs[i]
 		mr.MetricNameRaw = nil
+		mr.Histogram = nil
 	}
 	ctx.mrs = ctx.mrs[:0]
 	if n := rowsLen - cap(ctx.mrs); n > 0 {
@@ -41,6 +51,23 @@ func (ctx *InsertCtx) Reset(rowsLen int) {
 	}
 	ctx.mrs = ctx.mrs[:0]
 	ctx.metricNamesBuf = ctx.metricNamesBuf[:0]
+	ctx.histogramsBuf = ctx.histogramsBuf[:0]
+
+	for i := range ctx.ers {
+		er := &ctx.ers[i]
+		er.MetricNameRaw = nil
+		er.Exemplars = nil
+	}
+	ctx.ers = ctx.ers[:0]
+	ctx.exemplarsBuf = ctx.exemplarsBuf[:0]
+	for i := range ctx.exemplars {
+		labels := ctx.exemplars[i].Labels
+		for j := range labels {
+			labels[j].Key = nil
+			labels[j].Value = nil
+		}
+	}
+	ctx.exemplars = ctx.exemplars[:0]
 	ctx.relabelCtx.Reset()
 }
 
@@ -55,7 +82,31 @@ func (ctx *InsertCtx) marshalMetricNameRaw(prefix []byte, labels []prompb.Label)
 // WriteDataPoint writes (timestamp, value) with the given prefix and labels into ctx buffer.
 func (ctx *InsertCtx) WriteDataPoint(prefix []byte, labels []prompb.Label, timestamp int64, value float64) error {
 	metricNameRaw := ctx.marshalMetricNameRaw(prefix, labels)
-	return ctx.addRow(metricNameRaw, timestamp, value)
+	return ctx.addRow(metricNameRaw, timestamp, 0, value, nil)
+}
+
+// WriteDataPointWithNanos writes (timestamp, value) with the given prefix and labels into ctx buffer.
+//
+// nanos is the sub-millisecond part of the timestamp in nanoseconds.
+// It is stored only for metrics matching -storage.nanosecondPrecisionMetricPrefix.
+func (ctx *InsertCtx) WriteDataPointWithNanos(prefix []byte, labels []prompb.Label, timestamp int64, nanos int32, value float64) error {
+	metricNameRaw := ctx.marshalMetricNameRaw(prefix, labels)
+	return ctx.addRow(metricNameRaw, timestamp, nanos, value, nil)
+}
+
+// WriteDataPointWithHistogram writes (timestamp, value) with the given native histogram, prefix and labels into ctx buffer.
+//
+// nanos is the sub-millisecond part of the timestamp like in WriteDataPointWithNanos.
+// histogram must be marshaled with prompb.Histogram.MarshalCompact. It may be empty for ordinary samples.
+// value must contain the number of observations in the histogram if histogram isn't empty.
+func (ctx *InsertCtx) WriteDataPointWithHistogram(prefix []byte, labels []prompb.Label, timestamp int64, nanos int32, value float64, histogram []byte) error {
+	metricNameRaw := ctx.marshalMetricNameRaw(prefix, labels)
+	if len(histogram) > 0 {
+		start := len(ctx.histogramsBuf)
+		ctx.histogramsBuf = append(ctx.histogramsBuf, histogram...)
+		histogram = ctx.histogramsBuf[start:len(ctx.histogramsBuf):len(ctx.histogramsBuf)]
+	}
+	return ctx.addRow(metricNameRaw, timestamp, nanos, value, histogram)
 }
 
 // WriteDataPointExt writes (timestamp, value) with the given metricNameRaw and labels into ctx buffer.
@@ -65,11 +116,70 @@ func (ctx *InsertCtx) WriteDataPointExt(metricNameRaw []byte, labels []prompb.La
 	if len(metricNameRaw) == 0 {
 		metricNameRaw = ctx.marshalMetricNameRaw(nil, labels)
 	}
-	err := ctx.addRow(metricNameRaw, timestamp, value)
+	err := ctx.addRow(metricNameRaw, timestamp, 0, value, nil)
+	return metricNameRaw, err
+}
+
+// WriteHistogramExt writes native histogram h with the given metricNameRaw and labels into ctx buffer.
+//
+// It returns metricNameRaw for the given labels if len(metricNameRaw) == 0.
+func (ctx *InsertCtx) WriteHistogramExt(metricNameRaw []byte, labels []prompb.Label, h *prompb.Histogram) ([]byte, error) {
+	if len(metricNameRaw) == 0 {
+		metricNameRaw = ctx.marshalMetricNameRaw(nil, labels)
+	}
+	start := len(ctx.histogramsBuf)
+	ctx.histogramsBuf = h.MarshalCompact(ctx.histogramsBuf)
+	histogram := ctx.histogramsBuf[start:len(ctx.histogramsBuf):len(ctx.histogramsBuf)]
+	err := ctx.addRow(metricNameRaw, h.Timestamp, 0, h.Count, histogram)
 	return metricNameRaw, err
 }
 
-func (ctx *InsertCtx) addRow(metricNameRaw []byte, timestamp int64, value float64) error {
+// WriteExemplarsExt writes exemplars for the time series with the given metricNameRaw and labels into ctx buffer.
+//
+// It returns metricNameRaw for the given labels if len(metricNameRaw) == 0.
+// Exemplars are stored only for time series with already stored samples.
+func (ctx *InsertCtx) WriteExemplarsExt(metricNameRaw []byte, labels []prompb.Label, exemplars []prompb.Exemplar) ([]byte, error) {
+	if len(exemplars) == 0 {
+		return metricNameRaw, nil
+	}
+	if len(metricNameRaw) == 0 {
+		metricNameRaw = ctx.marshalMetricNameRaw(nil, labels)
+	}
+	es := ctx.exemplars[:0]
+	for i := range exemplars {
+		e := &exemplars[i]
+		if cap(es) > len(es) {
+			es = es[:len(es)+1]
+		} else {
+			es = append(es, storage.Exemplar{})
+		}
+		dst := &es[len(es)-1]
+		dst.Labels = dst.Labels[:0]
+		for _, label := range e.Labels {
+			dst.Labels = append(dst.Labels, storage.Tag{
+				Key:   label.Name,
+				Value: label.Value,
+			})
+		}
+		dst.Value = e.Value
+		dst.Timestamp = e.Timestamp
+	}
+	ctx.exemplars = es
+	start := len(ctx.exemplarsBuf)
+	ctx.exemplarsBuf = storage.MarshalExemplars(ctx.exemplarsBuf, es)
+	ctx.ers = append(ctx.ers, storage.ExemplarRow{
+		MetricNameRaw: metricNameRaw,
+		Exemplars:     ctx.exemplarsBuf[start:len(ctx.exemplarsBuf):len(ctx.exemplarsBuf)],
+	})
+	if len(ctx.metricNamesBuf)+len(ctx.histogramsBuf)+len(ctx.exemplarsBuf) > 16*1024*1024 {
+		if err := ctx.FlushBufs(); err != nil {
+			return metricNameRaw, err
+		}
+	}
+	return metricNameRaw, nil
+}
+
+func (ctx *InsertCtx) addRow(metricNameRaw []byte, timestamp int64, nanos int32, value float64, histogram []byte) error {
 	mrs := ctx.mrs
 	if cap(mrs) > len(mrs) {
 		mrs = mrs[:len(mrs)+1]
@@ -80,8 +190,10 @@ func (ctx *InsertCtx) addRow(metricNameRaw []byte, timestamp int64, value float6
 	ctx.mrs = mrs
 	mr.MetricNameRaw = metricNameRaw
 	mr.Timestamp = timestamp
+	mr.Nanos = nanos
 	mr.Value = value
-	if len(ctx.metricNamesBuf) > 16*1024*1024 {
+	mr.Histogram = histogram
+	if len(ctx.metricNamesBuf)+len(ctx.histogramsBuf)+len(ctx.exemplarsBuf) > 16*1024*1024 {
 		if err := ctx.FlushBufs(); err != nil {
 			return err
 		}
@@ -125,6 +237,15 @@ func (ctx *InsertCtx) AddLabel(name, value string) {
 	})
 }
 
+// SetProtocol sets the protocol name for the rows written to ctx.
+//
+// The name must match `type` label value for `vm_rows_inserted_total` metric.
+// It is used for applying per-protocol settings such as -decimation.interval and -ingestion.maxSampleAge.
+func (ctx *InsertCtx) SetProtocol(protocol string) {
+	ctx.decimator = getDecimator(protocol)
+	ctx.timestampWindow = getTimestampWindow(protocol)
+}
+
 // ApplyRelabeling applies relabeling to ic.Labels.
 func (ctx *InsertCtx) ApplyRelabeling() {
 	ctx.Labels = ctx.relabelCtx.ApplyRelabeling(ctx.Labels)
@@ -132,11 +253,27 @@ func (ctx *InsertCtx) ApplyRelabeling() {
 
 // FlushBufs flushes buffered rows to the underlying storage.
 func (ctx *InsertCtx) FlushBufs() error {
+	if ctx.timestampWindow != nil {
+		ctx.mrs = ctx.timestampWindow.filter(ctx.mrs)
+	}
+	if ctx.decimator != nil {
+		ctx.mrs = ctx.decimator.filter(ctx.mrs)
+	}
 	err := vmstorage.AddRows(ctx.mrs)
+	if err == nil {
+		// Exemplars must be added after the corresponding samples, so their time series are already registered in the storage.
+		err = vmstorage.AddExemplars(ctx.ers)
+	}
 	ctx.Reset(0)
 	if err == nil {
 		return nil
 	}
+	if errors.Is(err, storage.ErrReadOnly) {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("cannot store metrics: %w", err),
+			StatusCode: http.StatusTooManyRequests,
+		}
+	}
 	return &httpserver.ErrorWithStatusCode{
 		Err:        fmt.Errorf("cannot store metrics: %w", err),
 		StatusCode: http.StatusServiceUnavailable,
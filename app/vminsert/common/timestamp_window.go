@@ -0,0 +1,193 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+var (
+	maxSampleAge = flagutil.NewArray("ingestion.maxSampleAge", "Optional maximum age for ingested samples relative to the current time. "+
+		"Older samples are rejected. The age can be set in the format <protocol>:<age>, for example, influx:30d, in order to apply it only to the given protocol, "+
+		"or in the format <age>, for example, 1y, in order to apply it to all the protocols without explicitly set age. "+
+		"Supported protocols: "+strings.Join(ingestionProtocols, ", ")+". See https://docs.victoriametrics.com/#ingestion-timestamp-window")
+	maxFutureOffset = flagutil.NewArray("ingestion.maxFutureOffset", "Optional maximum offset in the future for timestamps of ingested samples relative to the current time. "+
+		"Samples with bigger timestamps are rejected. The offset can be set in the format <protocol>:<offset>, for example, influx:1h, "+
+		"in order to apply it only to the given protocol, or in the format <offset>, for example, 1d, in order to apply it to all the protocols without explicitly set offset. "+
+		"Supported protocols: "+strings.Join(ingestionProtocols, ", ")+". See https://docs.victoriametrics.com/#ingestion-timestamp-window")
+)
+
+// InitTimestampWindows initializes per-protocol timestamp windows according to -ingestion.maxSampleAge and -ingestion.maxFutureOffset command-line flags.
+//
+// It must be called after flag.Parse.
+func InitTimestampWindows() {
+	m, err := parseTimestampWindows(*maxSampleAge, *maxFutureOffset)
+	if err != nil {
+		logger.Fatalf("cannot initialize timestamp windows: %s", err)
+	}
+	timestampWindowsLock.Lock()
+	timestampWindows = m
+	timestampWindowsLock.Unlock()
+}
+
+func parseTimestampWindows(maxAges, maxFutureOffsets []string) (map[string]*timestampWindow, error) {
+	ages, err := parsePerProtocolDurations(maxAges)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse -ingestion.maxSampleAge: %w", err)
+	}
+	futureOffsets, err := parsePerProtocolDurations(maxFutureOffsets)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse -ingestion.maxFutureOffset: %w", err)
+	}
+	m := make(map[string]*timestampWindow)
+	for _, protocol := range ingestionProtocols {
+		age, ok := ages[protocol]
+		if !ok {
+			age = ages[""]
+		}
+		futureOffset, ok := futureOffsets[protocol]
+		if !ok {
+			futureOffset = futureOffsets[""]
+		}
+		if age == 0 && futureOffset == 0 {
+			continue
+		}
+		m[protocol] = newTimestampWindow(protocol, age, futureOffset)
+	}
+	return m, nil
+}
+
+// parsePerProtocolDurations parses durations in the format [<protocol>:]<duration>.
+//
+// The duration without protocol is stored under empty key.
+func parsePerProtocolDurations(a []string) (map[string]int64, error) {
+	m := make(map[string]int64)
+	for _, s := range a {
+		protocol := ""
+		durationStr := s
+		if n := strings.IndexByte(s, ':'); n >= 0 {
+			protocol = s[:n]
+			durationStr = s[n+1:]
+			if !isSupportedIngestionProtocol(protocol) {
+				return nil, fmt.Errorf("unsupported protocol %q in %q; supported protocols: %s", protocol, s, strings.Join(ingestionProtocols, ", "))
+			}
+		}
+		if _, ok := m[protocol]; ok {
+			if protocol == "" {
+				return nil, fmt.Errorf("duplicate value without protocol: %q", s)
+			}
+			return nil, fmt.Errorf("duplicate value for protocol %q", protocol)
+		}
+		d, err := metricsql.PositiveDurationValue(durationStr, 0)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse duration in %q: %w", s, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("duration in %q must be positive", s)
+		}
+		m[protocol] = d
+	}
+	return m, nil
+}
+
+var (
+	timestampWindows     map[string]*timestampWindow
+	timestampWindowsLock sync.Mutex
+)
+
+// getTimestampWindow returns timestamp window for the given protocol.
+//
+// nil is returned if timestamp window isn't configured for the given protocol.
+func getTimestampWindow(protocol string) *timestampWindow {
+	timestampWindowsLock.Lock()
+	tw := timestampWindows[protocol]
+	timestampWindowsLock.Unlock()
+	return tw
+}
+
+// timestampWindow rejects samples with timestamps outside the allowed window relative to the current time.
+type timestampWindow struct {
+	protocol string
+
+	// maxAgeMsecs is the maximum age for samples. It is disabled if set to 0.
+	maxAgeMsecs int64
+
+	// maxFutureOffsetMsecs is the maximum offset in the future for samples. It is disabled if set to 0.
+	maxFutureOffsetMsecs int64
+
+	tooOldRows    *metrics.Counter
+	tooFutureRows *metrics.Counter
+}
+
+func newTimestampWindow(protocol string, maxAgeMsecs, maxFutureOffsetMsecs int64) *timestampWindow {
+	return &timestampWindow{
+		protocol:             protocol,
+		maxAgeMsecs:          maxAgeMsecs,
+		maxFutureOffsetMsecs: maxFutureOffsetMsecs,
+		tooOldRows:           metrics.GetOrCreateCounter(fmt.Sprintf(`vm_rows_rejected_total{reason="too_old_timestamp",type=%q}`, protocol)),
+		tooFutureRows:        metrics.GetOrCreateCounter(fmt.Sprintf(`vm_rows_rejected_total{reason="too_future_timestamp",type=%q}`, protocol)),
+	}
+}
+
+// filter removes samples outside tw from mrs and returns the remaining samples.
+func (tw *timestampWindow) filter(mrs []storage.MetricRow) []storage.MetricRow {
+	now := int64(fasttime.UnixTimestamp()) * 1000
+	minTimestamp := int64(-1 << 63)
+	if tw.maxAgeMsecs > 0 {
+		minTimestamp = now - tw.maxAgeMsecs
+	}
+	maxTimestamp := int64(1<<63 - 1)
+	if tw.maxFutureOffsetMsecs > 0 {
+		maxTimestamp = now + tw.maxFutureOffsetMsecs
+	}
+	var firstWarn error
+	tooOldRows := 0
+	tooFutureRows := 0
+	dst := mrs[:0]
+	for i := range mrs {
+		mr := &mrs[i]
+		if mr.Timestamp < minTimestamp {
+			if firstWarn == nil {
+				firstWarn = fmt.Errorf("rejecting sample with too old timestamp %d ingested via %s; minimum allowed timestamp is %d according to -ingestion.maxSampleAge; metricName: %s",
+					mr.Timestamp, tw.protocol, minTimestamp, getUserReadableMetricName(mr.MetricNameRaw))
+			}
+			tooOldRows++
+			continue
+		}
+		if mr.Timestamp > maxTimestamp {
+			if firstWarn == nil {
+				firstWarn = fmt.Errorf("rejecting sample with too big timestamp %d ingested via %s; maximum allowed timestamp is %d according to -ingestion.maxFutureOffset; metricName: %s",
+					mr.Timestamp, tw.protocol, maxTimestamp, getUserReadableMetricName(mr.MetricNameRaw))
+			}
+			tooFutureRows++
+			continue
+		}
+		dst = append(dst, *mr)
+	}
+	if firstWarn != nil {
+		logger.Warnf("%s", firstWarn)
+	}
+	if tooOldRows > 0 {
+		tw.tooOldRows.Add(tooOldRows)
+	}
+	if tooFutureRows > 0 {
+		tw.tooFutureRows.Add(tooFutureRows)
+	}
+	return dst
+}
+
+func getUserReadableMetricName(metricNameRaw []byte) string {
+	mn := storage.GetMetricName()
+	defer storage.PutMetricName(mn)
+	if err := mn.UnmarshalRaw(metricNameRaw); err != nil {
+		return fmt.Sprintf("cannot unmarshal metricNameRaw %q: %s", metricNameRaw, err)
+	}
+	return mn.String()
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestParseTimestampWindowsSuccess(t *testing.T) {
+	f := func(maxAges, maxFutureOffsets []string, windowsExpected map[string][2]int64) {
+		t.Helper()
+		m, err := parseTimestampWindows(maxAges, maxFutureOffsets)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		windows := make(map[string][2]int64, len(m))
+		for protocol, tw := range m {
+			windows[protocol] = [2]int64{tw.maxAgeMsecs, tw.maxFutureOffsetMsecs}
+		}
+		if !reflect.DeepEqual(windows, windowsExpected) {
+			t.Fatalf("unexpected windows; got %v; want %v", windows, windowsExpected)
+		}
+	}
+	f(nil, nil, map[string][2]int64{})
+	f([]string{"influx:1d"}, []string{"graphite:1h"}, map[string][2]int64{
+		"influx":   {24 * 3600 * 1000, 0},
+		"graphite": {0, 3600 * 1000},
+	})
+
+	// Value without protocol applies to all the protocols without explicitly set value
+	m := make(map[string][2]int64)
+	for _, protocol := range ingestionProtocols {
+		m[protocol] = [2]int64{7 * 24 * 3600 * 1000, 0}
+	}
+	m["influx"] = [2]int64{3600 * 1000, 0}
+	f([]string{"1w", "influx:1h"}, nil, m)
+}
+
+func TestParseTimestampWindowsFailure(t *testing.T) {
+	f := func(maxAges, maxFutureOffsets []string) {
+		t.Helper()
+		if _, err := parseTimestampWindows(maxAges, maxFutureOffsets); err == nil {
+			t.Fatalf("expecting non-nil error for maxAges=%q, maxFutureOffsets=%q", maxAges, maxFutureOffsets)
+		}
+	}
+	// Unknown protocol
+	f([]string{"foobar:1d"}, nil)
+	f(nil, []string{"foobar:1d"})
+	// Invalid duration
+	f([]string{"influx:foo"}, nil)
+	f([]string{"influx:0s"}, nil)
+	f(nil, []string{"-1h"})
+	// Duplicate values
+	f([]string{"influx:1d", "influx:2d"}, nil)
+	f(nil, []string{"1d", "2d"})
+}
+
+func TestTimestampWindowFilter(t *testing.T) {
+	now := time.Now().UnixNano() / 1e6
+	f := func(maxAgeMsecs, maxFutureOffsetMsecs int64, timestamps, timestampsExpected []int64) {
+		t.Helper()
+		tw := newTimestampWindow("test", maxAgeMsecs, maxFutureOffsetMsecs)
+		var mrs []storage.MetricRow
+		for _, timestamp := range timestamps {
+			mrs = append(mrs, storage.MetricRow{
+				MetricNameRaw: []byte("foo"),
+				Timestamp:     timestamp,
+			})
+		}
+		var result []int64
+		for _, mr := range tw.filter(mrs) {
+			result = append(result, mr.Timestamp)
+		}
+		if !reflect.DeepEqual(result, timestampsExpected) {
+			t.Fatalf("unexpected timestamps; got %v; want %v", result, timestampsExpected)
+		}
+	}
+	const hour = 3600 * 1000
+	timestamps := []int64{now - 10*hour, now - hour/2, now, now + hour/2, now + 10*hour}
+	f(hour, 0, timestamps, []int64{now - hour/2, now, now + hour/2, now + 10*hour})
+	f(0, hour, timestamps, []int64{now - 10*hour, now - hour/2, now, now + hour/2})
+	f(hour, hour, timestamps, []int64{now - hour/2, now, now + hour/2})
+	f(hour, hour, []int64{1230768000000}, nil)
+}
@@ -18,16 +18,22 @@ var (
 )
 
 // InsertHandler processes /api/v1/import/csv requests.
-func InsertHandler(req *http.Request) error {
+//
+// It returns the number of skipped malformed csv lines.
+func InsertHandler(req *http.Request) (int, error) {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return writeconcurrencylimiter.Do(func() error {
-		return parser.ParseStream(req, func(rows []parser.Row) error {
+	invalidLines := 0
+	err = writeconcurrencylimiter.Do(func() error {
+		var err error
+		invalidLines, err = parser.ParseStream(req, func(rows []parser.Row) error {
 			return insertRows(rows, extraLabels)
 		})
+		return err
 	})
+	return invalidLines, err
 }
 
 func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
@@ -35,6 +41,7 @@ func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
 	defer common.PutInsertCtx(ctx)
 
 	ctx.Reset(len(rows))
+	ctx.SetProtocol("csvimport")
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
 		r := &rows[i]
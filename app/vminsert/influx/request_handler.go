@@ -71,6 +71,7 @@ func insertRows(db string, rows []parser.Row, extraLabels []prompbmarshal.Label)
 	}
 	ic := &ctx.Common
 	ic.Reset(rowsLen)
+	ic.SetProtocol("influx")
 	rowsTotal := 0
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
@@ -118,7 +119,7 @@ func insertRows(db string, rows []parser.Row, extraLabels []prompbmarshal.Label)
 					continue
 				}
 				ic.SortLabelsIfNeeded()
-				if err := ic.WriteDataPoint(nil, ic.Labels, r.Timestamp, f.Value); err != nil {
+				if err := ic.WriteDataPointWithNanos(nil, ic.Labels, r.Timestamp, r.Nanos, f.Value); err != nil {
 					return err
 				}
 			}
@@ -138,7 +139,7 @@ func insertRows(db string, rows []parser.Row, extraLabels []prompbmarshal.Label)
 					// Skip metric without labels.
 					continue
 				}
-				if err := ic.WriteDataPoint(ctx.metricNameBuf, ic.Labels[len(ic.Labels)-1:], r.Timestamp, f.Value); err != nil {
+				if err := ic.WriteDataPointWithNanos(ctx.metricNameBuf, ic.Labels[len(ic.Labels)-1:], r.Timestamp, r.Nanos, f.Value); err != nil {
 					return err
 				}
 			}
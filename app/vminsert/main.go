@@ -4,11 +4,15 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/arrow"
+	vminsertCommon "github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/csvimport"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/datadog"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/graphite"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/influx"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/native"
@@ -17,17 +21,24 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/prometheusimport"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/prompush"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/promremotewrite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/pushgateway"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/statsd"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/vmimport"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/influxutils"
 	graphiteserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/graphite"
+	grpcserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/grpc"
 	influxserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/influx"
 	opentsdbserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/opentsdb"
 	opentsdbhttpserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/opentsdbhttp"
+	statsdserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/statsd"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	promremotewriteparser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/promremotewrite"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
 	"github.com/VictoriaMetrics/metrics"
@@ -41,7 +52,16 @@ var (
 		"Telnet put messages and HTTP /api/put messages are simultaneously served on TCP port. "+
 		"Usually :4242 must be set. Doesn't work if empty")
 	opentsdbHTTPListenAddr = flag.String("opentsdbHTTPListenAddr", "", "TCP address to listen for OpentTSDB HTTP put requests. Usually :4242 must be set. Doesn't work if empty")
+	statsdListenAddr       = flag.String("statsdListenAddr", "", "TCP and UDP address to listen for statsd plaintext data. Usually :8125 must be set. Doesn't work if empty. "+
+		"The ingested data is aggregated over -statsd.flushInterval before being written")
+	grpcListenAddr = flag.String("grpcListenAddr", "", "TCP address to listen for Prometheus remote write data sent via gRPC streams. "+
+		"Doesn't work if empty. See https://docs.victoriametrics.com/#how-to-send-data-via-grpc")
+	grpcAuthKey = flag.String("grpcAuthKey", "", "Optional auth key for gRPC streams accepted at -grpcListenAddr. "+
+		"Every stream must contain `authorization: Bearer <grpcAuthKey>` metadata if the key is set")
 	maxLabelsPerTimeseries = flag.Int("maxLabelsPerTimeseries", 30, "The maximum number of labels accepted per time series. Superfluous labels are dropped")
+	storeNativeHistograms  = flag.Bool("promremotewrite.storeNativeHistograms", false, "Whether to store Prometheus native histograms received via remote write protocol as single series. "+
+		"By default native histograms are converted to <name>_count, <name>_sum and <name>_bucket series with vmrange label. "+
+		"See https://docs.victoriametrics.com/#native-histograms")
 )
 
 var (
@@ -49,12 +69,17 @@ var (
 	influxServer       *influxserver.Server
 	opentsdbServer     *opentsdbserver.Server
 	opentsdbhttpServer *opentsdbhttpserver.Server
+	statsdServer       *statsdserver.Server
+	grpcServer         *grpcserver.Server
 )
 
 // Init initializes vminsert.
 func Init() {
 	relabel.Init()
 	storage.SetMaxLabelsPerTimeseries(*maxLabelsPerTimeseries)
+	promremotewriteparser.SetKeepNativeHistograms(*storeNativeHistograms)
+	vminsertCommon.InitDecimation()
+	vminsertCommon.InitTimestampWindows()
 	common.StartUnmarshalWorkers()
 	writeconcurrencylimiter.Init()
 	if len(*graphiteListenAddr) > 0 {
@@ -69,12 +94,22 @@ func Init() {
 	if len(*opentsdbHTTPListenAddr) > 0 {
 		opentsdbhttpServer = opentsdbhttpserver.MustStart(*opentsdbHTTPListenAddr, opentsdbhttp.InsertHandler)
 	}
+	if len(*statsdListenAddr) > 0 {
+		statsd.Init()
+		statsdServer = statsdserver.MustStart(*statsdListenAddr, statsd.InsertHandler)
+	}
+	if len(*grpcListenAddr) > 0 {
+		grpcServer = grpcserver.MustStart(*grpcListenAddr, *grpcAuthKey, promremotewriteparser.MaxRequestSize(), promremotewrite.InsertHandlerForGRPC)
+	}
+	pushgateway.Init()
+	prommetadata.Init(vmstorage.AddMetricsMetadata)
 	promscrape.Init(prompush.Push)
 }
 
 // Stop stops vminsert.
 func Stop() {
 	promscrape.Stop()
+	pushgateway.MustStop()
 	if len(*graphiteListenAddr) > 0 {
 		graphiteServer.MustStop()
 	}
@@ -87,6 +122,13 @@ func Stop() {
 	if len(*opentsdbHTTPListenAddr) > 0 {
 		opentsdbhttpServer.MustStop()
 	}
+	if len(*statsdListenAddr) > 0 {
+		statsdServer.MustStop()
+		statsd.MustStop()
+	}
+	if len(*grpcListenAddr) > 0 {
+		grpcServer.MustStop()
+	}
 	common.StopUnmarshalWorkers()
 }
 
@@ -96,6 +138,22 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 	defer requestDuration.UpdateDuration(startTime)
 
 	path := strings.Replace(r.URL.Path, "//", "/", -1)
+	if strings.HasPrefix(path, "/metrics/job/") {
+		pushgatewayRequests.Inc()
+		if err := pushgateway.InsertHandler(r); err != nil {
+			pushgatewayErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		// Pushgateway clients expect 200 or 202 status codes.
+		// See https://github.com/prometheus/pushgateway#api
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusAccepted)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		return true
+	}
 	switch path {
 	case "/prometheus/api/v1/write", "/api/v1/write":
 		prometheusWriteRequests.Inc()
@@ -117,11 +175,15 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		return true
 	case "/prometheus/api/v1/import/csv", "/api/v1/import/csv":
 		csvimportRequests.Inc()
-		if err := csvimport.InsertHandler(r); err != nil {
+		invalidLines, err := csvimport.InsertHandler(r)
+		if err != nil {
 			csvimportErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
 		}
+		if invalidLines > 0 {
+			w.Header().Set("X-Invalid-Lines", strconv.Itoa(invalidLines))
+		}
 		w.WriteHeader(http.StatusNoContent)
 		return true
 	case "/prometheus/api/v1/import/prometheus", "/api/v1/import/prometheus":
@@ -142,6 +204,15 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		}
 		w.WriteHeader(http.StatusNoContent)
 		return true
+	case "/prometheus/api/v1/import/arrow", "/api/v1/import/arrow":
+		arrowimportRequests.Inc()
+		if err := arrow.InsertHandler(r); err != nil {
+			arrowimportErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
 	case "/influx/write", "/influx/api/v2/write", "/write", "/api/v2/write":
 		influxWriteRequests.Inc()
 		if err := influx.InsertHandlerForHTTP(r); err != nil {
@@ -151,6 +222,62 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		}
 		w.WriteHeader(http.StatusNoContent)
 		return true
+	case "/datadog/api/v1/series":
+		datadogWriteRequests.Inc()
+		if err := datadog.InsertHandlerForHTTP(r); err != nil {
+			datadogWriteErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		// See https://docs.datadoghq.com/api/latest/metrics/#submit-metrics
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(202)
+		fmt.Fprintf(w, `{"status":"ok"}`)
+		return true
+	case "/datadog/api/v2/series":
+		datadogWriteV2Requests.Inc()
+		if err := datadog.InsertV2HandlerForHTTP(r); err != nil {
+			datadogWriteV2Errors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		// See https://docs.datadoghq.com/api/latest/metrics/#submit-metrics
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(202)
+		fmt.Fprintf(w, `{"status":"ok"}`)
+		return true
+	case "/datadog/api/beta/sketches":
+		datadogSketchesRequests.Inc()
+		if err := datadog.InsertSketchesHandlerForHTTP(r); err != nil {
+			datadogSketchesErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		// See https://docs.datadoghq.com/api/latest/metrics/#submit-metrics
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(202)
+		fmt.Fprintf(w, `{"status":"ok"}`)
+		return true
+	case "/datadog/api/v1/validate":
+		datadogValidateRequests.Inc()
+		// See https://docs.datadoghq.com/api/latest/authentication/#validate-api-key
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, `{"valid":true}`)
+		return true
+	case "/datadog/api/v1/check_run":
+		datadogCheckRunRequests.Inc()
+		// Service checks aren't stored, but DataDog agent expects a successful response for them.
+		// See https://docs.datadoghq.com/api/latest/service-checks/#submit-a-service-check
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(202)
+		fmt.Fprintf(w, `{"status":"ok"}`)
+		return true
+	case "/datadog/intake/":
+		datadogIntakeRequests.Inc()
+		// Host metadata isn't stored, but DataDog agent expects a successful response for it.
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, `{}`)
+		return true
 	case "/influx/query", "/query":
 		influxQueryRequests.Inc()
 		influxutils.WriteDatabaseNames(w)
@@ -192,6 +319,9 @@ var (
 	prometheusWriteRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/write", protocol="promremotewrite"}`)
 	prometheusWriteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/write", protocol="promremotewrite"}`)
 
+	pushgatewayRequests = metrics.NewCounter(`vm_http_requests_total{path="/metrics/job/*", protocol="pushgateway"}`)
+	pushgatewayErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/metrics/job/*", protocol="pushgateway"}`)
+
 	vmimportRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/import", protocol="vmimport"}`)
 	vmimportErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/import", protocol="vmimport"}`)
 
@@ -204,9 +334,25 @@ var (
 	nativeimportRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/import/native", protocol="nativeimport"}`)
 	nativeimportErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/import/native", protocol="nativeimport"}`)
 
+	arrowimportRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/import/arrow", protocol="arrowimport"}`)
+	arrowimportErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/import/arrow", protocol="arrowimport"}`)
+
 	influxWriteRequests = metrics.NewCounter(`vm_http_requests_total{path="/write", protocol="influx"}`)
 	influxWriteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/write", protocol="influx"}`)
 
+	datadogWriteRequests = metrics.NewCounter(`vm_http_requests_total{path="/datadog/api/v1/series", protocol="datadog"}`)
+	datadogWriteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/datadog/api/v1/series", protocol="datadog"}`)
+
+	datadogWriteV2Requests = metrics.NewCounter(`vm_http_requests_total{path="/datadog/api/v2/series", protocol="datadog"}`)
+	datadogWriteV2Errors   = metrics.NewCounter(`vm_http_request_errors_total{path="/datadog/api/v2/series", protocol="datadog"}`)
+
+	datadogSketchesRequests = metrics.NewCounter(`vm_http_requests_total{path="/datadog/api/beta/sketches", protocol="datadog"}`)
+	datadogSketchesErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/datadog/api/beta/sketches", protocol="datadog"}`)
+
+	datadogValidateRequests = metrics.NewCounter(`vm_http_requests_total{path="/datadog/api/v1/validate", protocol="datadog"}`)
+	datadogCheckRunRequests = metrics.NewCounter(`vm_http_requests_total{path="/datadog/api/v1/check_run", protocol="datadog"}`)
+	datadogIntakeRequests   = metrics.NewCounter(`vm_http_requests_total{path="/datadog/intake/", protocol="datadog"}`)
+
 	influxQueryRequests = metrics.NewCounter(`vm_http_requests_total{path="/query", protocol="influx"}`)
 
 	promscrapeTargetsRequests      = metrics.NewCounter(`vm_http_requests_total{path="/targets"}`)
@@ -46,6 +46,7 @@ func insertRows(block *parser.Block, extraLabels []prompbmarshal.Label) error {
 
 	ic := &ctx.Common
 	ic.Reset(rowsLen)
+	ic.SetProtocol("native")
 	hasRelabeling := relabel.HasRelabeling()
 	mn := &block.MetricName
 	ic.Labels = ic.Labels[:0]
@@ -74,7 +75,15 @@ func insertRows(block *parser.Block, extraLabels []prompbmarshal.Label) error {
 	}
 	for j, value := range values {
 		timestamp := timestamps[j]
-		if err := ic.WriteDataPoint(ctx.metricNameBuf, nil, timestamp, value); err != nil {
+		var nanos int32
+		if len(block.Nanos) > 0 {
+			nanos = block.Nanos[j]
+		}
+		var histogram []byte
+		if len(block.Histograms) > 0 {
+			histogram = block.Histograms[j]
+		}
+		if err := ic.WriteDataPointWithHistogram(ctx.metricNameBuf, nil, timestamp, nanos, value, histogram); err != nil {
 			return err
 		}
 	}
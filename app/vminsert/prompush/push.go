@@ -48,6 +48,7 @@ func push(ctx *common.InsertCtx, tss []prompbmarshal.TimeSeries) {
 		rowsLen += len(tss[i].Samples)
 	}
 	ctx.Reset(rowsLen)
+	ctx.SetProtocol("promscrape")
 	rowsTotal := 0
 	for i := range tss {
 		ts := &tss[i]
@@ -5,6 +5,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	parserCommon "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
@@ -25,26 +26,37 @@ func InsertHandler(req *http.Request) error {
 		return err
 	}
 	return writeconcurrencylimiter.Do(func() error {
-		return parser.ParseStream(req.Body, func(tss []prompb.TimeSeries) error {
+		contentEncoding := req.Header.Get("Content-Encoding")
+		return parser.ParseStream(req.Body, contentEncoding, func(tss []prompb.TimeSeries) error {
 			return insertRows(tss, extraLabels)
 		})
 	})
 }
 
+// InsertHandlerForGRPC processes uncompressed protobuf-encoded Prometheus WriteRequest received via gRPC.
+func InsertHandlerForGRPC(data []byte) error {
+	return writeconcurrencylimiter.Do(func() error {
+		return parser.ParseWriteRequest(data, func(tss []prompb.TimeSeries) error {
+			return insertRows(tss, nil)
+		})
+	})
+}
+
 func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Label) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
 	rowsLen := 0
 	for i := range timeseries {
-		rowsLen += len(timeseries[i].Samples)
+		rowsLen += len(timeseries[i].Samples) + len(timeseries[i].Histograms)
 	}
 	ctx.Reset(rowsLen)
+	ctx.SetProtocol("promremotewrite")
 	rowsTotal := 0
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range timeseries {
 		ts := &timeseries[i]
-		rowsTotal += len(ts.Samples)
+		rowsTotal += len(ts.Samples) + len(ts.Histograms)
 		ctx.Labels = ctx.Labels[:0]
 		srcLabels := ts.Labels
 		for _, srcLabel := range srcLabels {
@@ -72,6 +84,22 @@ func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Labe
 				return err
 			}
 		}
+		histograms := ts.Histograms
+		for i := range histograms {
+			h := &histograms[i]
+			if decimal.IsStaleNaN(h.Sum) {
+				// Store staleness marker as an ordinary sample.
+				metricNameRaw, err = ctx.WriteDataPointExt(metricNameRaw, ctx.Labels, h.Timestamp, decimal.StaleNaN)
+			} else {
+				metricNameRaw, err = ctx.WriteHistogramExt(metricNameRaw, ctx.Labels, h)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := ctx.WriteExemplarsExt(metricNameRaw, ctx.Labels, ts.Exemplars); err != nil {
+			return err
+		}
 	}
 	rowsInserted.Add(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
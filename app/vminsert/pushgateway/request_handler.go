@@ -0,0 +1,88 @@
+package pushgateway
+
+import (
+	"net/http"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/pushgateway"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	rowsInserted  = metrics.NewCounter(`vm_rows_inserted_total{type="pushgateway"}`)
+	rowsPerInsert = metrics.NewHistogram(`vm_rows_per_insert{type="pushgateway"}`)
+)
+
+var registry *pushgateway.Registry
+
+// Init initializes Pushgateway API handler.
+//
+// MustStop must be called when Pushgateway API is no longer needed.
+func Init() {
+	registry = pushgateway.MustNewRegistry(pushSeries)
+}
+
+// MustStop stops Pushgateway API handler.
+func MustStop() {
+	registry.MustStop()
+	registry = nil
+}
+
+// InsertHandler processes Pushgateway API requests at /metrics/job/...
+//
+// See https://github.com/prometheus/pushgateway#api
+func InsertHandler(req *http.Request) error {
+	return writeconcurrencylimiter.Do(func() error {
+		return registry.HandleRequest(req)
+	})
+}
+
+func pushSeries(tss []prompbmarshal.TimeSeries) {
+	if err := insertRows(tss); err != nil {
+		logger.Errorf("cannot write data pushed via Pushgateway API to the storage: %s", err)
+	}
+}
+
+func insertRows(tss []prompbmarshal.TimeSeries) error {
+	ctx := common.GetInsertCtx()
+	defer common.PutInsertCtx(ctx)
+
+	ctx.Reset(len(tss))
+	ctx.SetProtocol("pushgateway")
+	hasRelabeling := relabel.HasRelabeling()
+	for i := range tss {
+		ts := &tss[i]
+		ctx.Labels = ctx.Labels[:0]
+		for j := range ts.Labels {
+			label := &ts.Labels[j]
+			name := label.Name
+			if name == "__name__" {
+				name = ""
+			}
+			ctx.AddLabel(name, label.Value)
+		}
+		if hasRelabeling {
+			ctx.ApplyRelabeling()
+		}
+		if len(ctx.Labels) == 0 {
+			// Skip metric without labels.
+			continue
+		}
+		ctx.SortLabelsIfNeeded()
+		var metricNameRaw []byte
+		var err error
+		for _, sample := range ts.Samples {
+			metricNameRaw, err = ctx.WriteDataPointExt(metricNameRaw, ctx.Labels, sample.Timestamp, sample.Value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	rowsInserted.Add(len(tss))
+	rowsPerInsert.Update(float64(len(tss)))
+	return ctx.FlushBufs()
+}
@@ -4,8 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
@@ -19,6 +21,8 @@ var (
 		"See https://docs.victoriametrics.com/#relabeling for details")
 	relabelDebug = flag.Bool("relabelDebug", false, "Whether to log metrics before and after relabeling with -relabelConfig. If the -relabelDebug is enabled, "+
 		"then the metrics aren't sent to storage. This is useful for debugging the relabeling configs")
+	relabelConfigCheckInterval = flag.Duration("relabelConfigCheckInterval", 0, "Interval for checking for changes in -relabelConfig file. "+
+		"By default the checking is disabled. Send SIGHUP signal in order to force config check for changes")
 )
 
 // Init must be called after flag.Parse and before using the relabel package.
@@ -36,20 +40,52 @@ func Init() {
 	if len(*relabelConfig) == 0 {
 		return
 	}
+	configSuccess.Set(1)
+	configTimestamp.Set(fasttime.UnixTimestamp())
+	var tickerCh <-chan time.Time
+	if *relabelConfigCheckInterval > 0 {
+		ticker := time.NewTicker(*relabelConfigCheckInterval)
+		tickerCh = ticker.C
+	}
 	go func() {
-		for range sighupCh {
-			logger.Infof("received SIGHUP; reloading -relabelConfig=%q...", *relabelConfig)
-			pcs, err := loadRelabelConfig()
-			if err != nil {
-				logger.Errorf("cannot load the updated relabelConfig: %s; preserving the previous config", err)
-				continue
+		for {
+			select {
+			case <-sighupCh:
+				logger.Infof("received SIGHUP; reloading -relabelConfig=%q...", *relabelConfig)
+			case <-tickerCh:
 			}
-			pcsGlobal.Store(pcs)
-			logger.Infof("successfully reloaded -relabelConfig=%q", *relabelConfig)
+			reloadRelabelConfig()
 		}
 	}()
 }
 
+func reloadRelabelConfig() {
+	configReloads.Inc()
+	pcs, err := loadRelabelConfig()
+	if err != nil {
+		configReloadErrors.Inc()
+		configSuccess.Set(0)
+		logger.Errorf("cannot load the updated relabelConfig: %s; preserving the previous config", err)
+		return
+	}
+	configSuccess.Set(1)
+	configTimestamp.Set(fasttime.UnixTimestamp())
+	pcsPrev := pcsGlobal.Load().(*promrelabel.ParsedConfigs)
+	if pcs.String() == pcsPrev.String() {
+		// Nothing changed since the previous load.
+		return
+	}
+	pcsGlobal.Store(pcs)
+	logger.Infof("successfully reloaded -relabelConfig=%q", *relabelConfig)
+}
+
+var (
+	configReloads      = metrics.NewCounter(`vm_relabel_config_reloads_total`)
+	configReloadErrors = metrics.NewCounter(`vm_relabel_config_reloads_errors_total`)
+	configSuccess      = metrics.NewCounter(`vm_relabel_config_last_reload_successful`)
+	configTimestamp    = metrics.NewCounter(`vm_relabel_config_last_reload_success_timestamp_seconds`)
+)
+
 var pcsGlobal atomic.Value
 
 func loadRelabelConfig() (*promrelabel.ParsedConfigs, error) {
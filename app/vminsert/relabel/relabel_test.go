@@ -0,0 +1,150 @@
+package relabel
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+func TestApplyRelabeling(t *testing.T) {
+	defer pcsGlobal.Store((*promrelabel.ParsedConfigs)(nil))
+
+	f := func(config string, labels []prompb.Label, resultExpected string) {
+		t.Helper()
+		pcs, err := promrelabel.ParseRelabelConfigsData([]byte(config), false)
+		if err != nil {
+			t.Fatalf("cannot parse relabel config: %s", err)
+		}
+		pcsGlobal.Store(pcs)
+		var ctx Ctx
+		result := labelsString(ctx.ApplyRelabeling(labels))
+		if result != resultExpected {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+		ctx.Reset()
+	}
+
+	// Missing relabeling
+	f("", newLabels("", "foo", "job", "bar"), `{__name__="foo",job="bar"}`)
+
+	// Add label
+	f(`
+- target_label: cluster
+  replacement: dev
+`, newLabels("", "foo", "job", "bar"), `{__name__="foo",cluster="dev",job="bar"}`)
+
+	// Rename metric
+	f(`
+- source_labels: [__name__]
+  regex: "foo_(.+)"
+  target_label: __name__
+  replacement: "bar_$1"
+`, newLabels("", "foo_total", "job", "x"), `{__name__="bar_total",job="x"}`)
+
+	// Drop metric
+	f(`
+- action: drop
+  source_labels: [job]
+  regex: "bar"
+`, newLabels("", "foo", "job", "bar"), `{}`)
+}
+
+func TestReloadRelabelConfig(t *testing.T) {
+	defer pcsGlobal.Store((*promrelabel.ParsedConfigs)(nil))
+
+	fp, err := ioutil.TempFile("", "relabel_config")
+	if err != nil {
+		t.Fatalf("cannot create temporary file: %s", err)
+	}
+	path := fp.Name()
+	_ = fp.Close()
+	defer func() {
+		_ = os.Remove(path)
+	}()
+	writeConfig := func(config string) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatalf("cannot write config: %s", err)
+		}
+	}
+
+	origRelabelConfig := *relabelConfig
+	*relabelConfig = path
+	defer func() {
+		*relabelConfig = origRelabelConfig
+	}()
+
+	writeConfig(`
+- target_label: cluster
+  replacement: dev
+`)
+	pcs, err := loadRelabelConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	pcsGlobal.Store(pcs)
+	if !HasRelabeling() {
+		t.Fatalf("expecting non-empty relabeling")
+	}
+
+	// Invalid config must preserve the previous config.
+	writeConfig(`
+- action: foobar
+`)
+	reloadRelabelConfig()
+	if pcsGlobal.Load().(*promrelabel.ParsedConfigs) != pcs {
+		t.Fatalf("the previous config must be preserved on invalid config")
+	}
+
+	// Unchanged config mustn't be replaced.
+	writeConfig(`
+- target_label: cluster
+  replacement: dev
+`)
+	reloadRelabelConfig()
+	if pcsGlobal.Load().(*promrelabel.ParsedConfigs) != pcs {
+		t.Fatalf("the config mustn't be replaced if it isn't changed")
+	}
+
+	// Updated config must be applied.
+	writeConfig(`
+- target_label: cluster
+  replacement: prod
+`)
+	reloadRelabelConfig()
+	var ctx Ctx
+	result := labelsString(ctx.ApplyRelabeling(newLabels("", "foo")))
+	resultExpected := `{__name__="foo",cluster="prod"}`
+	if result != resultExpected {
+		t.Fatalf("unexpected result after config reload;\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+}
+
+func newLabels(nameValues ...string) []prompb.Label {
+	var labels []prompb.Label
+	for i := 0; i < len(nameValues); i += 2 {
+		labels = append(labels, prompb.Label{
+			Name:  []byte(nameValues[i]),
+			Value: []byte(nameValues[i+1]),
+		})
+	}
+	return labels
+}
+
+func labelsString(labels []prompb.Label) string {
+	s := "{"
+	for i, label := range labels {
+		name := string(label.Name)
+		if name == "" {
+			name = "__name__"
+		}
+		if i > 0 {
+			s += ","
+		}
+		s += name + "=" + `"` + string(label.Value) + `"`
+	}
+	return s + "}"
+}
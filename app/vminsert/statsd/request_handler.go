@@ -0,0 +1,93 @@
+package statsd
+
+import (
+	"io"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	parser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/statsd"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	rowsInserted  = metrics.NewCounter(`vm_rows_inserted_total{type="statsd"}`)
+	rowsPerInsert = metrics.NewHistogram(`vm_rows_per_insert{type="statsd"}`)
+)
+
+var aggregator *parser.Aggregator
+
+// Init initializes statsd aggregation.
+//
+// MustStop must be called when statsd data is no longer ingested.
+func Init() {
+	aggregator = parser.MustNewAggregator(pushAggregatedSeries)
+}
+
+// MustStop flushes the aggregated statsd data to the storage and stops the aggregation.
+func MustStop() {
+	aggregator.MustStop()
+	aggregator = nil
+}
+
+// InsertHandler processes remote write for statsd plaintext protocol.
+//
+// The ingested rows are aggregated before being written to the storage.
+//
+// See https://github.com/statsd/statsd/blob/master/docs/metric_types.md
+func InsertHandler(r io.Reader) error {
+	return writeconcurrencylimiter.Do(func() error {
+		return parser.ParseStream(r, func(rows []parser.Row) error {
+			aggregator.Push(rows)
+			return nil
+		})
+	})
+}
+
+func pushAggregatedSeries(tss []prompbmarshal.TimeSeries) {
+	if err := insertRows(tss); err != nil {
+		logger.Errorf("cannot write aggregated statsd data to the storage: %s", err)
+	}
+}
+
+func insertRows(tss []prompbmarshal.TimeSeries) error {
+	ctx := common.GetInsertCtx()
+	defer common.PutInsertCtx(ctx)
+
+	ctx.Reset(len(tss))
+	ctx.SetProtocol("statsd")
+	hasRelabeling := relabel.HasRelabeling()
+	for i := range tss {
+		ts := &tss[i]
+		ctx.Labels = ctx.Labels[:0]
+		for j := range ts.Labels {
+			label := &ts.Labels[j]
+			name := label.Name
+			if name == "__name__" {
+				name = ""
+			}
+			ctx.AddLabel(name, label.Value)
+		}
+		if hasRelabeling {
+			ctx.ApplyRelabeling()
+		}
+		if len(ctx.Labels) == 0 {
+			// Skip metric without labels.
+			continue
+		}
+		ctx.SortLabelsIfNeeded()
+		var metricNameRaw []byte
+		var err error
+		for _, sample := range ts.Samples {
+			metricNameRaw, err = ctx.WriteDataPointExt(metricNameRaw, ctx.Labels, sample.Timestamp, sample.Value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	rowsInserted.Add(len(tss))
+	rowsPerInsert.Update(float64(len(tss)))
+	return ctx.FlushBufs()
+}
@@ -47,6 +47,7 @@ func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
 	}
 	ic := &ctx.Common
 	ic.Reset(rowsLen)
+	ic.SetProtocol("vmimport")
 	rowsTotal := 0
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
@@ -0,0 +1,142 @@
+package graphite
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphiteql"
+)
+
+// getArg returns an arg with the given name or at the given position idx.
+//
+// nil is returned if the arg is missing.
+func getArg(args []*graphiteql.ArgExpr, name string, idx int) *graphiteql.ArgExpr {
+	if name != "" {
+		for _, arg := range args {
+			if arg.Name == name {
+				return arg
+			}
+		}
+	}
+	if idx < len(args) && args[idx].Name == "" {
+		return args[idx]
+	}
+	return nil
+}
+
+func getArgExpr(arg *graphiteql.ArgExpr) graphiteql.Expr {
+	if arg == nil {
+		return nil
+	}
+	return arg.Expr
+}
+
+func getArgDescription(name string, idx int) string {
+	if name == "" {
+		return fmt.Sprintf("arg #%d", idx)
+	}
+	return fmt.Sprintf("%q arg", name)
+}
+
+func getSeriesArg(ec *evalConfig, args []*graphiteql.ArgExpr, name string, idx int) ([]*series, error) {
+	arg := getArg(args, name, idx)
+	if arg == nil {
+		return nil, fmt.Errorf("missing %s", getArgDescription(name, idx))
+	}
+	return evalExpr(ec, arg.Expr)
+}
+
+// getSeriesListArgs returns series for all the positional args.
+func getSeriesListArgs(ec *evalConfig, args []*graphiteql.ArgExpr) ([]*series, error) {
+	var ss []*series
+	for i, arg := range args {
+		if arg.Name != "" {
+			continue
+		}
+		ssLocal, err := evalExpr(ec, arg.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot evaluate arg #%d: %w", i, err)
+		}
+		ss = append(ss, ssLocal...)
+	}
+	return ss, nil
+}
+
+func getNumberArg(args []*graphiteql.ArgExpr, name string, idx int) (float64, error) {
+	arg := getArg(args, name, idx)
+	if arg == nil {
+		return 0, fmt.Errorf("missing %s", getArgDescription(name, idx))
+	}
+	ne, ok := arg.Expr.(*graphiteql.NumberExpr)
+	if !ok {
+		return 0, fmt.Errorf("%s must be a number; got %s", getArgDescription(name, idx), arg.Expr.AppendString(nil))
+	}
+	return ne.N, nil
+}
+
+func getOptionalNumberArg(args []*graphiteql.ArgExpr, name string, idx int, defaultValue float64) (float64, error) {
+	arg := getArg(args, name, idx)
+	if arg == nil {
+		return defaultValue, nil
+	}
+	if _, ok := arg.Expr.(*graphiteql.NoneExpr); ok {
+		return defaultValue, nil
+	}
+	return getNumberArg(args, name, idx)
+}
+
+func getStringArg(args []*graphiteql.ArgExpr, name string, idx int) (string, error) {
+	arg := getArg(args, name, idx)
+	if arg == nil {
+		return "", fmt.Errorf("missing %s", getArgDescription(name, idx))
+	}
+	se, ok := arg.Expr.(*graphiteql.StringExpr)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string; got %s", getArgDescription(name, idx), arg.Expr.AppendString(nil))
+	}
+	return se.S, nil
+}
+
+func getOptionalStringArg(args []*graphiteql.ArgExpr, name string, idx int, defaultValue string) (string, error) {
+	arg := getArg(args, name, idx)
+	if arg == nil {
+		return defaultValue, nil
+	}
+	if _, ok := arg.Expr.(*graphiteql.NoneExpr); ok {
+		return defaultValue, nil
+	}
+	return getStringArg(args, name, idx)
+}
+
+func getOptionalBoolArg(args []*graphiteql.ArgExpr, name string, idx int, defaultValue bool) (bool, error) {
+	arg := getArg(args, name, idx)
+	if arg == nil {
+		return defaultValue, nil
+	}
+	switch t := arg.Expr.(type) {
+	case *graphiteql.NoneExpr:
+		return defaultValue, nil
+	case *graphiteql.BoolExpr:
+		return t.B, nil
+	case *graphiteql.NumberExpr:
+		return t.N != 0, nil
+	default:
+		return false, fmt.Errorf("%s must be a bool; got %s", getArgDescription(name, idx), arg.Expr.AppendString(nil))
+	}
+}
+
+// getNodesArgs returns node args starting from startIdx.
+//
+// Every node must be either a number or a string.
+func getNodesArgs(args []*graphiteql.ArgExpr, startIdx int) ([]graphiteql.Expr, error) {
+	var nodes []graphiteql.Expr
+	for i := startIdx; i < len(args); i++ {
+		expr := args[i].Expr
+		switch expr.(type) {
+		case *graphiteql.NumberExpr, *graphiteql.StringExpr:
+			nodes = append(nodes, expr)
+		default:
+			return nil, fmt.Errorf("node arg #%d must be either a number or a string; got %s", i, expr.AppendString(nil))
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,250 @@
+package graphite
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphiteql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// evalConfig is the configuration for evaluating Graphite Render API targets.
+type evalConfig struct {
+	// startTime is the start time in milliseconds. It is aligned to storageStep.
+	startTime int64
+
+	// endTime is the end time in milliseconds.
+	endTime int64
+
+	// storageStep is the interval in milliseconds between datapoints stored in the database.
+	storageStep int64
+
+	deadline searchutils.Deadline
+
+	// enforcedTagFilters are added to every series selector.
+	enforcedTagFilters []storage.TagFilter
+}
+
+// withTimeShift returns a copy of ec with the time range shifted by the given offset in milliseconds.
+func (ec *evalConfig) withTimeShift(offset int64) *evalConfig {
+	ecCopy := *ec
+	ecCopy.startTime += offset
+	ecCopy.startTime -= ecCopy.startTime % ecCopy.storageStep
+	ecCopy.endTime += offset
+	return &ecCopy
+}
+
+// withStartOffset returns a copy of ec with startTime moved back by the given duration in milliseconds.
+func (ec *evalConfig) withStartOffset(d int64) *evalConfig {
+	ecCopy := *ec
+	ecCopy.startTime -= d
+	ecCopy.startTime -= ecCopy.startTime % ecCopy.storageStep
+	return &ecCopy
+}
+
+// getTimestamps returns timestamps for datapoints on the [startTime ... endTime] time range.
+func (ec *evalConfig) getTimestamps() []int64 {
+	n := (ec.endTime-ec.startTime)/ec.storageStep + 1
+	timestamps := make([]int64, n)
+	for i := range timestamps {
+		timestamps[i] = ec.startTime + int64(i)*ec.storageStep
+	}
+	return timestamps
+}
+
+// series is a single time series returned from Graphite Render API.
+type series struct {
+	// Name is the series name returned in `target` field.
+	Name string
+
+	// Tags are series tags. The `name` tag contains the original series name.
+	Tags map[string]string
+
+	Timestamps []int64
+	Values     []float64
+
+	// pathExpression is the expression, which was used for obtaining the series.
+	// It is used for generating names for aggregate functions.
+	pathExpression string
+
+	// consolidateFunc is used for reducing the number of datapoints to maxDataPoints.
+	// It can be set via consolidateBy() function.
+	consolidateFunc aggrFunc
+}
+
+// consolidate reduces the number of datapoints in s to maxDataPoints.
+//
+// See https://graphite.readthedocs.io/en/stable/render_api.html#maxdatapoints
+func (s *series) consolidate(maxDataPoints int) {
+	if len(s.Values) <= maxDataPoints {
+		return
+	}
+	pointsPerBucket := (len(s.Values) + maxDataPoints - 1) / maxDataPoints
+	f := s.consolidateFunc
+	if f == nil {
+		f = aggrAvg
+	}
+	// Allocate new slices, since s.Timestamps may be shared among multiple series.
+	dstValues := make([]float64, 0, maxDataPoints)
+	dstTimestamps := make([]int64, 0, maxDataPoints)
+	for i := 0; i < len(s.Values); i += pointsPerBucket {
+		j := i + pointsPerBucket
+		if j > len(s.Values) {
+			j = len(s.Values)
+		}
+		v := f(s.Values[i:j])
+		ts := s.Timestamps[i]
+		dstValues = append(dstValues, v)
+		dstTimestamps = append(dstTimestamps, ts)
+	}
+	s.Values = dstValues
+	s.Timestamps = dstTimestamps
+}
+
+func (s *series) copyTags() map[string]string {
+	tags := make(map[string]string, len(s.Tags))
+	for k, v := range s.Tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+func evalExpr(ec *evalConfig, expr graphiteql.Expr) ([]*series, error) {
+	switch t := expr.(type) {
+	case *graphiteql.MetricExpr:
+		return evalMetricExpr(ec, t)
+	case *graphiteql.FuncExpr:
+		tf := transformFuncs[t.FuncName]
+		if tf == nil {
+			return nil, fmt.Errorf("unsupported function %q", t.FuncName)
+		}
+		ss, err := tf(ec, t)
+		if err != nil {
+			return nil, fmt.Errorf("cannot evaluate %s: %w", t.AppendString(nil), err)
+		}
+		return ss, nil
+	default:
+		return nil, fmt.Errorf("unexpected expression %s; want series selector or function call", expr.AppendString(nil))
+	}
+}
+
+func evalMetricExpr(ec *evalConfig, me *graphiteql.MetricExpr) ([]*series, error) {
+	tf := getTagFilterForPath(me.Query)
+	tfs := append([]storage.TagFilter{tf}, ec.enforcedTagFilters...)
+	return fetchSeries(ec, tfs, me.Query)
+}
+
+func evalSeriesByTag(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	if len(fe.Args) == 0 {
+		return nil, fmt.Errorf("expecting at least a single tag expression")
+	}
+	exprs := make([]string, 0, len(fe.Args))
+	for i := range fe.Args {
+		expr, err := getStringArg(fe.Args, "", i)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	tfs, err := exprsToTagFilters(exprs)
+	if err != nil {
+		return nil, err
+	}
+	tfs = append(tfs, ec.enforcedTagFilters...)
+	return fetchSeries(ec, tfs, string(fe.AppendString(nil)))
+}
+
+// getTagFilterForPath returns a filter on metric name for the given Graphite path such as `foo.*.bar`.
+func getTagFilterForPath(path string) storage.TagFilter {
+	if !strings.ContainsAny(path, "*{[") {
+		return storage.TagFilter{
+			Value: []byte(path),
+		}
+	}
+	re, _ := getRegexpStringForQuery(path, '.', false)
+	re = strings.TrimPrefix(re, "^")
+	re = strings.TrimSuffix(re, "$")
+	re = strings.TrimSuffix(re, regexp.QuoteMeta(".")+"?")
+	return storage.TagFilter{
+		Value:    []byte(re),
+		IsRegexp: true,
+	}
+}
+
+func fetchSeries(ec *evalConfig, tfs []storage.TagFilter, pathExpression string) ([]*series, error) {
+	sq := storage.NewSearchQuery(ec.startTime, ec.endTime, [][]storage.TagFilter{tfs})
+	rss, err := netstorage.ProcessSearchQuery(nil, true, sq, true, nil, ec.deadline)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch data for %q: %w", sq, err)
+	}
+	if rss.Len() > *maxGraphiteSeries {
+		rss.Cancel()
+		return nil, fmt.Errorf("the number of matching series for %q exceeds %d; either narrow down the query "+
+			"or increase -search.maxGraphiteSeries command-line flag value", pathExpression, *maxGraphiteSeries)
+	}
+	timestamps := ec.getTimestamps()
+	var ss []*series
+	var ssLock sync.Mutex
+	err = rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
+		s := &series{
+			Name:           getCanonicalPath(&rs.MetricName),
+			Tags:           getTagsForMetricName(&rs.MetricName),
+			Timestamps:     timestamps,
+			Values:         alignValues(ec, rs.Values, rs.Timestamps, len(timestamps)),
+			pathExpression: pathExpression,
+		}
+		ssLock.Lock()
+		ss = append(ss, s)
+		ssLock.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error when fetching data for %q: %w", sq, err)
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		return ss[i].Name < ss[j].Name
+	})
+	return ss, nil
+}
+
+// alignValues averages raw samples into buckets with storageStep interval starting from ec.startTime.
+func alignValues(ec *evalConfig, values []float64, timestamps []int64, pointsLen int) []float64 {
+	sums := make([]float64, pointsLen)
+	counts := make([]int, pointsLen)
+	for i, ts := range timestamps {
+		if ts < ec.startTime {
+			continue
+		}
+		idx := int((ts - ec.startTime) / ec.storageStep)
+		if idx >= pointsLen {
+			break
+		}
+		sums[idx] += values[i]
+		counts[idx]++
+	}
+	for i, n := range counts {
+		if n == 0 {
+			sums[i] = nan
+		} else {
+			sums[i] /= float64(n)
+		}
+	}
+	return sums
+}
+
+func getTagsForMetricName(mn *storage.MetricName) map[string]string {
+	tags := make(map[string]string, len(mn.Tags)+1)
+	tags["name"] = string(mn.MetricGroup)
+	for _, tag := range mn.Tags {
+		tags[string(tag.Key)] = string(tag.Value)
+	}
+	return tags
+}
+
+var nan = math.NaN()
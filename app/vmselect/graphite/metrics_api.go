@@ -76,7 +76,16 @@ func MetricsFindHandler(startTime time.Time, w http.ResponseWriter, r *http.Requ
 		MinTimestamp: from,
 		MaxTimestamp: until,
 	}
-	paths, err := metricsFind(tr, label, "", query, delimiter[0], false, deadline)
+	etfs, err := searchutils.GetEnforcedTagFiltersFromRequest(r)
+	if err != nil {
+		return fmt.Errorf("cannot setup tag filters: %w", err)
+	}
+	var paths []string
+	if len(etfs) == 0 {
+		paths, err = metricsFind(tr, label, "", query, delimiter[0], false, deadline)
+	} else {
+		paths, err = metricsFindWithFilters(tr, label, query, delimiter[0], etfs, deadline)
+	}
 	if err != nil {
 		return err
 	}
@@ -153,9 +162,19 @@ func MetricsExpandHandler(startTime time.Time, w http.ResponseWriter, r *http.Re
 		MinTimestamp: from,
 		MaxTimestamp: until,
 	}
+	etfs, err := searchutils.GetEnforcedTagFiltersFromRequest(r)
+	if err != nil {
+		return fmt.Errorf("cannot setup tag filters: %w", err)
+	}
 	m := make(map[string][]string, len(queries))
 	for _, query := range queries {
-		paths, err := metricsFind(tr, label, "", query, delimiter[0], true, deadline)
+		var paths []string
+		var err error
+		if len(etfs) == 0 {
+			paths, err = metricsFind(tr, label, "", query, delimiter[0], true, deadline)
+		} else {
+			paths, err = metricsFindWithFilters(tr, label, query, delimiter[0], etfs, deadline)
+		}
 		if err != nil {
 			return err
 		}
@@ -300,6 +319,74 @@ func metricsFind(tr storage.TimeRange, label, qHead, qTail string, delimiter byt
 	return results, nil
 }
 
+// metricsFindWithFilters searches for label values matching the given query among series matching etfs.
+//
+// It is slower than metricsFind, since it needs to fetch all the matching series names.
+// It is used when additional filters must be applied, e.g. via `extra_label` query arg.
+func metricsFindWithFilters(tr storage.TimeRange, label, query string, delimiter byte, etfs []storage.TagFilter, deadline searchutils.Deadline) ([]string, error) {
+	prefix := query
+	if n := strings.IndexAny(query, "*{["); n >= 0 {
+		prefix = query[:n]
+	}
+	tfs := make([]storage.TagFilter, 0, len(etfs)+1)
+	tfs = append(tfs, storage.TagFilter{
+		Key:      []byte(label),
+		Value:    []byte(regexp.QuoteMeta(prefix) + ".*"),
+		IsRegexp: true,
+	})
+	tfs = append(tfs, etfs...)
+	sq := storage.NewSearchQuery(tr.MinTimestamp, tr.MaxTimestamp, [][]storage.TagFilter{tfs})
+	mns, err := netstorage.SearchMetricNames(sq, deadline)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch metric names for %q: %w", sq, err)
+	}
+	if label == "" {
+		label = "__name__"
+	}
+	values := make([]string, 0, len(mns))
+	for i := range mns {
+		values = append(values, string(mns[i].GetTagValue(label)))
+	}
+	return getPathsForQuery(values, query, delimiter)
+}
+
+// getPathsForQuery returns paths matching the given query from values.
+//
+// A path ending with delimiter is returned if the query matches a node in the value hierarchy.
+func getPathsForQuery(values []string, query string, delimiter byte) ([]string, error) {
+	re, err := getRegexpForQuery(query, delimiter)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert query %q to regexp: %w", query, err)
+	}
+	depth := strings.Count(query, string(delimiter))
+	if strings.HasSuffix(query, string(delimiter)) {
+		depth--
+	}
+	m := make(map[string]struct{})
+	for _, value := range values {
+		// Cut the value after the delimiter at the query depth in order to obtain node path.
+		n := 0
+		for i := 0; i <= depth; i++ {
+			k := strings.IndexByte(value[n:], delimiter)
+			if k < 0 {
+				n = len(value)
+				break
+			}
+			n += k + 1
+		}
+		path := value[:n]
+		if re.MatchString(path) {
+			m[path] = struct{}{}
+		}
+	}
+	paths := make([]string, 0, len(m))
+	for path := range m {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
 var (
 	metricsFindDuration   = metrics.NewSummary(`vm_request_duration_seconds{path="/metrics/find"}`)
 	metricsExpandDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/metrics/expand"}`)
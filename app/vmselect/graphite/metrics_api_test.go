@@ -82,3 +82,26 @@ func TestAddAutomaticVariants(t *testing.T) {
 	f("foo.bar,baz,aa.bb,cc", ".", "foo.{bar,baz,aa}.{bb,cc}")
 	f("foo.b*r,b[a-xz]z,aa.bb,cc", ".", "foo.{b*r,b[a-xz]z,aa}.{bb,cc}")
 }
+
+func TestGetPathsForQuery(t *testing.T) {
+	f := func(values []string, query string, delimiter byte, pathsExpected []string) {
+		t.Helper()
+		paths, err := getPathsForQuery(values, query, delimiter)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(paths, pathsExpected) {
+			t.Fatalf("unexpected paths for query=%q; got\n%q\nwant\n%q", query, paths, pathsExpected)
+		}
+	}
+	values := []string{"foo.bar.baz", "foo.bar.x", "foo.qwe", "foo", "abc.def"}
+	f(values, "*", '.', []string{"abc.", "foo", "foo."})
+	f(values, "foo.*", '.', []string{"foo.bar.", "foo.qwe"})
+	f(values, "foo.", '.', []string{"foo."})
+	f(values, "foo.bar.*", '.', []string{"foo.bar.baz", "foo.bar.x"})
+	f(values, "foo.{bar,qwe}", '.', []string{"foo.bar.", "foo.qwe"})
+	f(values, "*.def", '.', []string{"abc.def"})
+	f(values, "foo.bar.b*", '.', []string{"foo.bar.baz"})
+	f(values, "x.*", '.', []string{})
+	f([]string{"foo_bar_baz", "foo_x"}, "foo_*", '_', []string{"foo_bar_", "foo_x"})
+}
@@ -0,0 +1,219 @@
+package graphite
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/bufferedwriter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphiteql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	storageStep = flag.Duration("search.graphiteStorageStep", 10*time.Second, "The interval between datapoints stored in the database. "+
+		"It is used at Graphite Render API handler for normalizing the interval between datapoints in case it isn't normalized. "+
+		"It can be overridden by sending 'storage_step' query arg to /render API or "+
+		"by sending the desired interval via 'Storage-Step' http header during querying /render API")
+	maxGraphiteSeries = flag.Int("search.maxGraphiteSeries", 300e3, "The maximum number of time series, which can be scanned during queries to Graphite Render API. "+
+		"See https://docs.victoriametrics.com/#graphite-render-api-usage")
+)
+
+// RenderHandler implements /render endpoint from Graphite Render API.
+//
+// See https://graphite.readthedocs.io/en/stable/render_api.html
+func RenderHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("cannot parse form values: %w", err)
+	}
+	format := r.FormValue("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" {
+		return fmt.Errorf(`unsupported format=%q; supported values: "json"`, format)
+	}
+	targets := r.Form["target"]
+	jsonp := r.FormValue("jsonp")
+	maxDataPoints, err := getInt(r, "maxDataPoints")
+	if err != nil {
+		return err
+	}
+	step, err := getStorageStep(r)
+	if err != nil {
+		return err
+	}
+	ct := startTime.UnixNano() / 1e6
+	from, err := getGraphiteTime(r, "from", ct-24*3600*1000, ct)
+	if err != nil {
+		return err
+	}
+	until, err := getGraphiteTime(r, "until", ct, ct)
+	if err != nil {
+		return err
+	}
+	if from > until {
+		return fmt.Errorf("from=%d cannot exceed until=%d", from, until)
+	}
+	etfs, err := searchutils.GetEnforcedTagFiltersFromRequest(r)
+	if err != nil {
+		return fmt.Errorf("cannot setup tag filters: %w", err)
+	}
+	ec := &evalConfig{
+		startTime:          from - from%step,
+		endTime:            until,
+		storageStep:        step,
+		deadline:           deadline,
+		enforcedTagFilters: etfs,
+	}
+	var ss []*series
+	for _, target := range targets {
+		expr, err := graphiteql.Parse(target)
+		if err != nil {
+			return fmt.Errorf("cannot parse target=%q: %w", target, err)
+		}
+		ssLocal, err := evalExpr(ec, expr)
+		if err != nil {
+			return fmt.Errorf("cannot evaluate target=%q: %w", target, err)
+		}
+		ss = append(ss, ssLocal...)
+	}
+	if maxDataPoints > 0 {
+		for _, s := range ss {
+			s.consolidate(maxDataPoints)
+		}
+	}
+
+	contentType := getContentType(jsonp)
+	w.Header().Set("Content-Type", contentType)
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteRenderJSONResponse(bw, ss, jsonp)
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	renderDuration.UpdateDuration(startTime)
+	return nil
+}
+
+var renderDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/render"}`)
+
+func getStorageStep(r *http.Request) (int64, error) {
+	s := r.FormValue("storage_step")
+	if len(s) == 0 {
+		s = r.Header.Get("Storage-Step")
+	}
+	if len(s) == 0 {
+		step := int64(storageStep.Seconds() * 1000)
+		if step <= 0 {
+			return 0, fmt.Errorf("-search.graphiteStorageStep=%s must be positive", storageStep)
+		}
+		return step, nil
+	}
+	step, err := parseInterval(s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse storage step %q: %w", s, err)
+	}
+	if step <= 0 {
+		return 0, fmt.Errorf("storage step must be positive; got %q", s)
+	}
+	return step, nil
+}
+
+// getGraphiteTime returns time in milliseconds from the given argKey query arg.
+//
+// defaultMs is returned if argKey is missing in r. ct is the current time in milliseconds.
+//
+// See https://graphite.readthedocs.io/en/stable/render_api.html#from-until
+func getGraphiteTime(r *http.Request, argKey string, defaultMs, ct int64) (int64, error) {
+	s := r.FormValue(argKey)
+	if len(s) == 0 {
+		return defaultMs, nil
+	}
+	t, err := parseGraphiteTime(s, ct)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %s=%q: %w", argKey, s, err)
+	}
+	return t, nil
+}
+
+func parseGraphiteTime(s string, ct int64) (int64, error) {
+	if s == "now" {
+		return ct, nil
+	}
+	if strings.HasPrefix(s, "now") {
+		s = s[len("now"):]
+	}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		d, err := parseInterval(s)
+		if err != nil {
+			return 0, err
+		}
+		return ct + d, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && len(s) != len("YYYYMMDD") {
+		// Unix timestamp in seconds
+		return n * 1000, nil
+	}
+	for _, layout := range []string{"15:04_20060102", "20060102", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UnixNano() / 1e6, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported time format; supported formats: now, -<interval>, now-<interval>, unix timestamp, HH:MM_YYYYMMDD, YYYYMMDD, RFC3339")
+}
+
+// parseInterval parses Graphite interval such as `5min`, `-1h` or `2weeks` and returns its value in milliseconds.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.summarize
+func parseInterval(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	sign := int64(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign = -1
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	n := 0
+	for n < len(s) && (s[n] >= '0' && s[n] <= '9' || s[n] == '.') {
+		n++
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("missing number in interval %q", s)
+	}
+	f, err := strconv.ParseFloat(s[:n], 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse number in interval %q: %w", s, err)
+	}
+	unit := strings.ToLower(s[n:])
+	var unitMs float64
+	switch {
+	case unit == "ms":
+		unitMs = 1
+	case strings.HasPrefix(unit, "s"):
+		unitMs = 1000
+	case strings.HasPrefix(unit, "min"):
+		unitMs = 60 * 1000
+	case strings.HasPrefix(unit, "h"):
+		unitMs = 3600 * 1000
+	case strings.HasPrefix(unit, "d"):
+		unitMs = 24 * 3600 * 1000
+	case strings.HasPrefix(unit, "w"):
+		unitMs = 7 * 24 * 3600 * 1000
+	case strings.HasPrefix(unit, "mon"):
+		unitMs = 30 * 24 * 3600 * 1000
+	case strings.HasPrefix(unit, "y"):
+		unitMs = 365 * 24 * 3600 * 1000
+	default:
+		return 0, fmt.Errorf("unsupported unit %q in interval %q; supported units: s, min, h, d, w, mon, y", unit, s)
+	}
+	return sign * int64(math.Round(f*unitMs)), nil
+}
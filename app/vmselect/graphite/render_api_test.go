@@ -0,0 +1,83 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseIntervalSuccess(t *testing.T) {
+	f := func(s string, resultExpected int64) {
+		t.Helper()
+		result, err := parseInterval(s)
+		if err != nil {
+			t.Fatalf("unexpected error in parseInterval(%q): %s", s, err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result for parseInterval(%q); got %d; want %d", s, result, resultExpected)
+		}
+	}
+	f("1s", 1000)
+	f("10sec", 10*1000)
+	f("5min", 5*60*1000)
+	f("-5minutes", -5*60*1000)
+	f("+1h", 3600*1000)
+	f("2hours", 2*3600*1000)
+	f("1d", 24*3600*1000)
+	f("1w", 7*24*3600*1000)
+	f("1mon", 30*24*3600*1000)
+	f("1y", 365*24*3600*1000)
+	f("1.5s", 1500)
+	f("100ms", 100)
+}
+
+func TestParseIntervalFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		result, err := parseInterval(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error for parseInterval(%q); got %d", s, result)
+		}
+	}
+	f("")
+	f("foo")
+	f("1")
+	f("1m")
+	f("1foo")
+	f("-")
+}
+
+func TestParseGraphiteTimeSuccess(t *testing.T) {
+	ct := time.Date(2022, 3, 4, 10, 20, 30, 0, time.UTC).UnixNano() / 1e6
+	f := func(s string, resultExpected int64) {
+		t.Helper()
+		result, err := parseGraphiteTime(s, ct)
+		if err != nil {
+			t.Fatalf("unexpected error in parseGraphiteTime(%q): %s", s, err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result for parseGraphiteTime(%q); got %d; want %d", s, result, resultExpected)
+		}
+	}
+	f("now", ct)
+	f("-1h", ct-3600*1000)
+	f("now-5min", ct-5*60*1000)
+	f("now+1d", ct+24*3600*1000)
+	f("1646389230", 1646389230*1000)
+	f("20220304", time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC).UnixNano()/1e6)
+	f("10:20_20220304", time.Date(2022, 3, 4, 10, 20, 0, 0, time.UTC).UnixNano()/1e6)
+	f("2022-03-04T10:20:30Z", ct)
+}
+
+func TestParseGraphiteTimeFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		result, err := parseGraphiteTime(s, 0)
+		if err == nil {
+			t.Fatalf("expecting non-nil error for parseGraphiteTime(%q); got %d", s, result)
+		}
+	}
+	f("")
+	f("foo")
+	f("-1foo")
+	f("now-")
+}
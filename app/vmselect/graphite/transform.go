@@ -0,0 +1,1241 @@
+package graphite
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphiteql"
+)
+
+// transformFunc evaluates the given function call.
+type transformFunc func(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error)
+
+// transformFuncs contains the supported Graphite functions.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html
+//
+// It is initialized in init() in order to avoid initialization loop, since some functions call evalExpr.
+var transformFuncs map[string]transformFunc
+
+func init() {
+	transformFuncs = map[string]transformFunc{
+		"absolute":              transformAbsolute,
+		"aggregate":             transformAggregate,
+		"alias":                 transformAlias,
+		"aliasByMetric":         transformAliasByMetric,
+		"aliasByNode":           transformAliasByNode,
+		"aliasByTags":           transformAliasByNode,
+		"aliasSub":              transformAliasSub,
+		"alpha":                 transformPassThrough,
+		"asPercent":             transformAsPercent,
+		"averageSeries":         newTransformAggregateSeries("average"),
+		"avg":                   newTransformAggregateSeries("average"),
+		"color":                 transformPassThrough,
+		"consolidateBy":         transformConsolidateBy,
+		"constantLine":          transformConstantLine,
+		"countSeries":           newTransformAggregateSeries("count"),
+		"derivative":            transformDerivative,
+		"diffSeries":            newTransformAggregateSeries("diff"),
+		"divideSeries":          transformDivideSeries,
+		"exclude":               newTransformFilterByName(false),
+		"grep":                  newTransformFilterByName(true),
+		"group":                 transformGroup,
+		"groupByNode":           transformGroupByNode,
+		"groupByNodes":          transformGroupByNodes,
+		"groupByTags":           transformGroupByTags,
+		"highest":               newTransformHighestLowest(true, ""),
+		"highestAverage":        newTransformHighestLowest(true, "average"),
+		"highestCurrent":        newTransformHighestLowest(true, "current"),
+		"highestMax":            newTransformHighestLowest(true, "max"),
+		"integral":              transformIntegral,
+		"invert":                transformInvert,
+		"keepLastValue":         transformKeepLastValue,
+		"limit":                 transformLimit,
+		"lineWidth":             transformPassThrough,
+		"lowest":                newTransformHighestLowest(false, ""),
+		"lowestAverage":         newTransformHighestLowest(false, "average"),
+		"lowestCurrent":         newTransformHighestLowest(false, "current"),
+		"max":                   newTransformAggregateSeries("max"),
+		"maxSeries":             newTransformAggregateSeries("max"),
+		"min":                   newTransformAggregateSeries("min"),
+		"minSeries":             newTransformAggregateSeries("min"),
+		"movingAverage":         newTransformMovingWindow("movingAverage", aggrAvg),
+		"movingMax":             newTransformMovingWindow("movingMax", aggrMax),
+		"movingMedian":          newTransformMovingWindow("movingMedian", aggrMedian),
+		"movingMin":             newTransformMovingWindow("movingMin", aggrMin),
+		"movingSum":             newTransformMovingWindow("movingSum", aggrSum),
+		"multiplySeries":        newTransformAggregateSeries("multiply"),
+		"nonNegativeDerivative": newTransformNonNegativeDerivative("nonNegativeDerivative", false),
+		"offset":                transformOffset,
+		"perSecond":             newTransformNonNegativeDerivative("perSecond", true),
+		"rangeOfSeries":         newTransformAggregateSeries("rangeOf"),
+		"removeAboveValue":      newTransformRemoveValue("removeAboveValue", true),
+		"removeBelowValue":      newTransformRemoveValue("removeBelowValue", false),
+		"scale":                 transformScale,
+		"seriesByTag":           evalSeriesByTag,
+		"sortByMaxima":          newTransformSortBy(aggrMax, true),
+		"sortByMinima":          newTransformSortBy(aggrMin, false),
+		"sortByName":            transformSortByName,
+		"sortByTotal":           newTransformSortBy(aggrSum, true),
+		"stddevSeries":          newTransformAggregateSeries("stddev"),
+		"sum":                   newTransformAggregateSeries("sum"),
+		"sumSeries":             newTransformAggregateSeries("sum"),
+		"summarize":             transformSummarize,
+		"timeShift":             transformTimeShift,
+		"transformNull":         transformTransformNull,
+	}
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.absolute
+func transformAbsolute(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		for i, v := range s.Values {
+			s.Values[i] = math.Abs(v)
+		}
+		s.setName(fmt.Sprintf("absolute(%s)", s.Name))
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.aggregate
+func transformAggregate(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	funcName, err := getStringArg(fe.Args, "func", 1)
+	if err != nil {
+		return nil, err
+	}
+	funcName = strings.TrimSuffix(funcName, "Series")
+	return aggregateSeriesList(ss, funcName)
+}
+
+// newTransformAggregateSeries returns transformFunc for sumSeries-like functions.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.sumSeries
+func newTransformAggregateSeries(funcName string) transformFunc {
+	return func(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+		ss, err := getSeriesListArgs(ec, fe.Args)
+		if err != nil {
+			return nil, err
+		}
+		return aggregateSeriesList(ss, funcName)
+	}
+}
+
+func aggregateSeriesList(ss []*series, funcName string) ([]*series, error) {
+	f, err := getAggrFunc(funcName)
+	if err != nil {
+		return nil, err
+	}
+	if len(ss) == 0 {
+		return nil, nil
+	}
+	name := fmt.Sprintf("%sSeries(%s)", funcName, formatPathExpressions(ss))
+	s := aggregateSeries(ss, funcName, f)
+	s.setName(name)
+	if _, ok := s.Tags["name"]; !ok {
+		s.Tags["name"] = name
+	}
+	return []*series{s}, nil
+}
+
+// aggregateSeries calculates f over datapoints with the same index in ss.
+//
+// The returned series contains tags common to all the ss plus `aggregatedBy` tag.
+func aggregateSeries(ss []*series, funcName string, f aggrFunc) *series {
+	timestamps := ss[0].Timestamps
+	values := make([]float64, len(timestamps))
+	tmp := make([]float64, 0, len(ss))
+	for i := range values {
+		tmp = tmp[:0]
+		for _, s := range ss {
+			if i < len(s.Values) {
+				tmp = append(tmp, s.Values[i])
+			}
+		}
+		values[i] = f(tmp)
+	}
+	tags := ss[0].copyTags()
+	for _, s := range ss[1:] {
+		for k, v := range tags {
+			if s.Tags[k] != v {
+				delete(tags, k)
+			}
+		}
+	}
+	tags["aggregatedBy"] = funcName
+	return &series{
+		Tags:       tags,
+		Timestamps: timestamps,
+		Values:     values,
+	}
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.alias
+func transformAlias(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	newName, err := getStringArg(fe.Args, "newName", 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		s.Name = newName
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.aliasByMetric
+func transformAliasByMetric(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		path := getPathFromName(s.Name)
+		if n := strings.LastIndexByte(path, '.'); n >= 0 {
+			path = path[n+1:]
+		}
+		s.Name = path
+	}
+	return ss, nil
+}
+
+// transformAliasByNode implements aliasByNode and aliasByTags functions.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.aliasByNode
+// and https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.aliasByTags
+func transformAliasByNode(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := getNodesArgs(fe.Args, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		s.Name = getNameFromNodes(s, nodes)
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.aliasSub
+func transformAliasSub(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	search, err := getStringArg(fe.Args, "search", 1)
+	if err != nil {
+		return nil, err
+	}
+	replace, err := getStringArg(fe.Args, "replace", 2)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(search)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile search=%q: %w", search, err)
+	}
+	// Convert Python-style backreferences such as `\1` to Go-style backreferences such as `${1}`.
+	replace = backreferenceRegexp.ReplaceAllString(replace, "$${$1}")
+	for _, s := range ss {
+		s.Name = re.ReplaceAllString(s.Name, replace)
+	}
+	return ss, nil
+}
+
+var backreferenceRegexp = regexp.MustCompile(`\\(\d+)`)
+
+// transformPassThrough returns the seriesList arg as is.
+//
+// It is used for functions, which change only the visual representation of series, such as color or lineWidth.
+func transformPassThrough(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	return getSeriesArg(ec, fe.Args, "seriesList", 0)
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.asPercent
+func transformAsPercent(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(fe.Args) > 2 {
+		return nil, fmt.Errorf("nodes arg isn't supported")
+	}
+	totalArg := getArg(fe.Args, "total", 1)
+	if len(ss) == 0 {
+		return nil, nil
+	}
+	var totals []*series
+	var totalNames []string
+	switch t := getArgExpr(totalArg).(type) {
+	case nil, *graphiteql.NoneExpr:
+		total := aggregateSeries(ss, "sum", aggrSum)
+		totals = []*series{total}
+		totalNames = []string{fmt.Sprintf("sumSeries(%s)", formatPathExpressions(ss))}
+	case *graphiteql.NumberExpr:
+		values := make([]float64, len(ss[0].Timestamps))
+		for i := range values {
+			values[i] = t.N
+		}
+		totals = []*series{{
+			Timestamps: ss[0].Timestamps,
+			Values:     values,
+		}}
+		totalNames = []string{formatNumber(t.N)}
+	default:
+		totals, err = evalExpr(ec, t)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(totals) == 1:
+		case len(totals) == len(ss):
+			// Match series by names as Graphite does.
+			sortSeriesByName(ss)
+			sortSeriesByName(totals)
+		default:
+			return nil, fmt.Errorf("the number of total series must be 1 or match the number of series in seriesList (%d); got %d", len(ss), len(totals))
+		}
+		for _, total := range totals {
+			totalNames = append(totalNames, total.Name)
+		}
+	}
+	for i, s := range ss {
+		total := totals[0]
+		totalName := totalNames[0]
+		if len(totals) > 1 {
+			total = totals[i]
+			totalName = totalNames[i]
+		}
+		for j, v := range s.Values {
+			tv := nan
+			if j < len(total.Values) {
+				tv = total.Values[j]
+			}
+			if tv == 0 || math.IsNaN(tv) {
+				s.Values[j] = nan
+			} else {
+				s.Values[j] = v / tv * 100
+			}
+		}
+		s.setName(fmt.Sprintf("asPercent(%s,%s)", s.Name, totalName))
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.consolidateBy
+func transformConsolidateBy(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	funcName, err := getStringArg(fe.Args, "consolidationFunc", 1)
+	if err != nil {
+		return nil, err
+	}
+	f, err := getAggrFunc(funcName)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		s.consolidateFunc = f
+		s.setName(fmt.Sprintf("consolidateBy(%s,%q)", s.Name, funcName))
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.constantLine
+func transformConstantLine(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	value, err := getNumberArg(fe.Args, "value", 0)
+	if err != nil {
+		return nil, err
+	}
+	timestamps := ec.getTimestamps()
+	values := make([]float64, len(timestamps))
+	for i := range values {
+		values[i] = value
+	}
+	name := formatNumber(value)
+	s := &series{
+		Name: name,
+		Tags: map[string]string{
+			"name": name,
+		},
+		Timestamps:     timestamps,
+		Values:         values,
+		pathExpression: name,
+	}
+	return []*series{s}, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.derivative
+func transformDerivative(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		prev := nan
+		for i, v := range s.Values {
+			s.Values[i] = v - prev
+			prev = v
+		}
+		s.setName(fmt.Sprintf("derivative(%s)", s.Name))
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.divideSeries
+func transformDivideSeries(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "dividendSeriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	divisors, err := getSeriesArg(ec, fe.Args, "divisorSeries", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(divisors) != 1 {
+		return nil, fmt.Errorf("divisorSeries must contain exactly one series; got %d series", len(divisors))
+	}
+	divisor := divisors[0]
+	for _, s := range ss {
+		for i, v := range s.Values {
+			d := nan
+			if i < len(divisor.Values) {
+				d = divisor.Values[i]
+			}
+			if d == 0 {
+				s.Values[i] = nan
+			} else {
+				s.Values[i] = v / d
+			}
+		}
+		s.setName(fmt.Sprintf("divideSeries(%s,%s)", s.Name, divisor.Name))
+	}
+	return ss, nil
+}
+
+// newTransformFilterByName returns transformFunc for grep and exclude functions.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.grep
+// and https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.exclude
+func newTransformFilterByName(keepMatching bool) transformFunc {
+	return func(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+		ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := getStringArg(fe.Args, "pattern", 1)
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile pattern=%q: %w", pattern, err)
+		}
+		dst := ss[:0]
+		for _, s := range ss {
+			if re.MatchString(s.Name) == keepMatching {
+				dst = append(dst, s)
+			}
+		}
+		return dst, nil
+	}
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.group
+func transformGroup(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	return getSeriesListArgs(ec, fe.Args)
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.groupByNode
+func transformGroupByNode(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	nodeArg := getArg(fe.Args, "nodeNum", 1)
+	if nodeArg == nil {
+		return nil, fmt.Errorf("missing nodeNum arg")
+	}
+	callback, err := getOptionalStringArg(fe.Args, "callback", 2, "average")
+	if err != nil {
+		return nil, err
+	}
+	return groupSeriesByNodes(ss, callback, []graphiteql.Expr{nodeArg.Expr})
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.groupByNodes
+func transformGroupByNodes(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	callback, err := getStringArg(fe.Args, "callback", 1)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := getNodesArgs(fe.Args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return groupSeriesByNodes(ss, callback, nodes)
+}
+
+func groupSeriesByNodes(ss []*series, callback string, nodes []graphiteql.Expr) ([]*series, error) {
+	f, err := getAggrFunc(callback)
+	if err != nil {
+		return nil, err
+	}
+	keys, groups := groupSeries(ss, func(s *series) string {
+		return getNameFromNodes(s, nodes)
+	})
+	ssDst := make([]*series, 0, len(keys))
+	for _, key := range keys {
+		s := aggregateSeries(groups[key], callback, f)
+		s.setName(key)
+		s.Tags["name"] = key
+		ssDst = append(ssDst, s)
+	}
+	return ssDst, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.groupByTags
+func transformGroupByTags(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	callback, err := getStringArg(fe.Args, "callback", 1)
+	if err != nil {
+		return nil, err
+	}
+	f, err := getAggrFunc(callback)
+	if err != nil {
+		return nil, err
+	}
+	var tagKeys []string
+	for i := 2; i < len(fe.Args); i++ {
+		tagKey, err := getStringArg(fe.Args, "", i)
+		if err != nil {
+			return nil, err
+		}
+		tagKeys = append(tagKeys, tagKey)
+	}
+	if len(tagKeys) == 0 {
+		return nil, fmt.Errorf("expecting at least a single tag")
+	}
+	keys, groups := groupSeries(ss, func(s *series) string {
+		name := callback + "Series"
+		if hasString(tagKeys, "name") {
+			name = s.Tags["name"]
+		}
+		for _, tagKey := range tagKeys {
+			if tagKey != "name" {
+				name += ";" + tagKey + "=" + s.Tags[tagKey]
+			}
+		}
+		return name
+	})
+	ssDst := make([]*series, 0, len(keys))
+	for _, key := range keys {
+		s := aggregateSeries(groups[key], callback, f)
+		s.setName(key)
+		s.Tags = getTagsFromCanonicalPath(key)
+		ssDst = append(ssDst, s)
+	}
+	return ssDst, nil
+}
+
+// groupSeries groups ss by keys returned from keyFunc.
+//
+// Keys are returned in the order of their first appearance in ss.
+func groupSeries(ss []*series, keyFunc func(s *series) string) ([]string, map[string][]*series) {
+	var keys []string
+	groups := make(map[string][]*series)
+	for _, s := range ss {
+		key := keyFunc(s)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], s)
+	}
+	return keys, groups
+}
+
+// newTransformHighestLowest returns transformFunc for highest* and lowest* functions.
+//
+// If funcName is empty, then the aggregate function name is read from the `func` arg.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.highest
+// and https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.lowest
+func newTransformHighestLowest(isHighest bool, funcName string) transformFunc {
+	return func(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+		ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+		if err != nil {
+			return nil, err
+		}
+		n, err := getOptionalNumberArg(fe.Args, "n", 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		name := funcName
+		if name == "" {
+			name, err = getOptionalStringArg(fe.Args, "func", 2, "average")
+			if err != nil {
+				return nil, err
+			}
+		}
+		f, err := getAggrFunc(name)
+		if err != nil {
+			return nil, err
+		}
+		sortSeriesByAggr(ss, f, isHighest)
+		if int(n) < len(ss) {
+			ss = ss[:int(n)]
+		}
+		return ss, nil
+	}
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.integral
+func transformIntegral(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		sum := float64(0)
+		for i, v := range s.Values {
+			if math.IsNaN(v) {
+				continue
+			}
+			sum += v
+			s.Values[i] = sum
+		}
+		s.setName(fmt.Sprintf("integral(%s)", s.Name))
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.invert
+func transformInvert(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		for i, v := range s.Values {
+			if v == 0 {
+				s.Values[i] = nan
+			} else {
+				s.Values[i] = 1 / v
+			}
+		}
+		s.setName(fmt.Sprintf("invert(%s)", s.Name))
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.keepLastValue
+func transformKeepLastValue(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := getOptionalNumberArg(fe.Args, "limit", 1, math.Inf(1))
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		values := s.Values
+		lastValue := nan
+		gapStart := -1
+		for i, v := range values {
+			if math.IsNaN(v) {
+				if gapStart < 0 {
+					gapStart = i
+				}
+				continue
+			}
+			if gapStart >= 0 && float64(i-gapStart) <= limit {
+				fillValues(values[gapStart:i], lastValue)
+			}
+			gapStart = -1
+			lastValue = v
+		}
+		if gapStart >= 0 && float64(len(values)-gapStart) <= limit {
+			fillValues(values[gapStart:], lastValue)
+		}
+		if math.IsInf(limit, 1) {
+			s.setName(fmt.Sprintf("keepLastValue(%s)", s.Name))
+		} else {
+			s.setName(fmt.Sprintf("keepLastValue(%s,%s)", s.Name, formatNumber(limit)))
+		}
+	}
+	return ss, nil
+}
+
+func fillValues(values []float64, v float64) {
+	for i := range values {
+		values[i] = v
+	}
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.limit
+func transformLimit(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	n, err := getNumberArg(fe.Args, "n", 1)
+	if err != nil {
+		return nil, err
+	}
+	if int(n) < len(ss) {
+		ss = ss[:int(n)]
+	}
+	return ss, nil
+}
+
+// newTransformMovingWindow returns transformFunc for moving* functions.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.movingWindow
+func newTransformMovingWindow(funcName string, f aggrFunc) transformFunc {
+	return func(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+		windowArg := getArg(fe.Args, "windowSize", 1)
+		if windowArg == nil {
+			return nil, fmt.Errorf("missing windowSize arg")
+		}
+		var windowPoints int
+		var window int64
+		var windowStr string
+		switch t := windowArg.Expr.(type) {
+		case *graphiteql.NumberExpr:
+			windowPoints = int(t.N)
+			if windowPoints <= 0 {
+				return nil, fmt.Errorf("windowSize must be positive; got %s", formatNumber(t.N))
+			}
+			window = int64(windowPoints) * ec.storageStep
+			windowStr = formatNumber(t.N)
+		case *graphiteql.StringExpr:
+			d, err := parseInterval(t.S)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse windowSize: %w", err)
+			}
+			if d < 0 {
+				d = -d
+			}
+			if d == 0 {
+				return nil, fmt.Errorf("windowSize must be positive; got %q", t.S)
+			}
+			window = d
+			windowStr = strconv.Quote(t.S)
+		default:
+			return nil, fmt.Errorf("windowSize must be either number or string; got %s", windowArg.Expr.AppendString(nil))
+		}
+		// Fetch additional data before the start time in order to fill the window for the first datapoints.
+		ss, err := getSeriesArg(ec.withStartOffset(window), fe.Args, "seriesList", 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			points := windowPoints
+			if points == 0 {
+				points = int(window / s.step(ec))
+				if points < 1 {
+					points = 1
+				}
+			}
+			start := 0
+			for start < len(s.Timestamps) && s.Timestamps[start] < ec.startTime {
+				start++
+			}
+			values := make([]float64, 0, len(s.Values)-start)
+			for i := start; i < len(s.Values); i++ {
+				j := i - points
+				if j < 0 {
+					j = 0
+				}
+				values = append(values, f(s.Values[j:i]))
+			}
+			s.Timestamps = s.Timestamps[start:]
+			s.Values = values
+			s.setName(fmt.Sprintf("%s(%s,%s)", funcName, s.Name, windowStr))
+		}
+		return ss, nil
+	}
+}
+
+// newTransformNonNegativeDerivative returns transformFunc for nonNegativeDerivative and perSecond functions.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.nonNegativeDerivative
+// and https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.perSecond
+func newTransformNonNegativeDerivative(funcName string, isPerSecond bool) transformFunc {
+	return func(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+		ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+		if err != nil {
+			return nil, err
+		}
+		maxValue, err := getOptionalNumberArg(fe.Args, "maxValue", 1, nan)
+		if err != nil {
+			return nil, err
+		}
+		minValue, err := getOptionalNumberArg(fe.Args, "minValue", 2, nan)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			prevValue := nan
+			prevTimestamp := int64(0)
+			for i, v := range s.Values {
+				ts := s.Timestamps[i]
+				if math.IsNaN(v) {
+					continue
+				}
+				d := nonNegativeDelta(v, prevValue, maxValue, minValue)
+				if isPerSecond && !math.IsNaN(d) {
+					d /= float64(ts-prevTimestamp) / 1e3
+				}
+				s.Values[i] = d
+				prevValue = v
+				prevTimestamp = ts
+			}
+			s.setName(fmt.Sprintf("%s(%s)", funcName, s.Name))
+		}
+		return ss, nil
+	}
+}
+
+// nonNegativeDelta returns non-negative delta between v and prev.
+//
+// maxValue and minValue are used for detecting counter wraps. They are ignored if set to NaN.
+func nonNegativeDelta(v, prev, maxValue, minValue float64) float64 {
+	if math.IsNaN(prev) {
+		return nan
+	}
+	if !math.IsNaN(maxValue) && v > maxValue || !math.IsNaN(minValue) && v < minValue {
+		return nan
+	}
+	if v >= prev {
+		return v - prev
+	}
+	switch {
+	case !math.IsNaN(maxValue) && !math.IsNaN(minValue):
+		return maxValue - prev + v - minValue + 1
+	case !math.IsNaN(maxValue):
+		return maxValue - prev + v + 1
+	case !math.IsNaN(minValue):
+		return v - minValue
+	default:
+		return nan
+	}
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.offset
+func transformOffset(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := getNumberArg(fe.Args, "factor", 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		for i, v := range s.Values {
+			s.Values[i] = v + factor
+		}
+		s.setName(fmt.Sprintf("offset(%s,%s)", s.Name, formatNumber(factor)))
+	}
+	return ss, nil
+}
+
+// newTransformRemoveValue returns transformFunc for removeAboveValue and removeBelowValue functions.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.removeAboveValue
+// and https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.removeBelowValue
+func newTransformRemoveValue(funcName string, isAbove bool) transformFunc {
+	return func(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+		ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+		if err != nil {
+			return nil, err
+		}
+		n, err := getNumberArg(fe.Args, "n", 1)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			for i, v := range s.Values {
+				if isAbove && v > n || !isAbove && v < n {
+					s.Values[i] = nan
+				}
+			}
+			s.setName(fmt.Sprintf("%s(%s, %s)", funcName, s.Name, formatNumber(n)))
+		}
+		return ss, nil
+	}
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.scale
+func transformScale(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := getNumberArg(fe.Args, "factor", 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		for i, v := range s.Values {
+			s.Values[i] = v * factor
+		}
+		s.setName(fmt.Sprintf("scale(%s,%s)", s.Name, formatNumber(factor)))
+	}
+	return ss, nil
+}
+
+// newTransformSortBy returns transformFunc for sortByMaxima, sortByMinima and sortByTotal functions.
+//
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.sortByMaxima
+func newTransformSortBy(f aggrFunc, isDesc bool) transformFunc {
+	return func(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+		ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+		if err != nil {
+			return nil, err
+		}
+		sortSeriesByAggr(ss, f, isDesc)
+		return ss, nil
+	}
+}
+
+// sortSeriesByAggr sorts ss by values of f calculated over every series.
+//
+// Series with NaN values are put at the end.
+func sortSeriesByAggr(ss []*series, f aggrFunc, isDesc bool) {
+	keys := make(map[*series]float64, len(ss))
+	for _, s := range ss {
+		keys[s] = f(s.Values)
+	}
+	sort.SliceStable(ss, func(i, j int) bool {
+		a, b := keys[ss[i]], keys[ss[j]]
+		if math.IsNaN(a) {
+			return false
+		}
+		if math.IsNaN(b) {
+			return true
+		}
+		if isDesc {
+			return a > b
+		}
+		return a < b
+	})
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.sortByName
+func transformSortByName(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	natural, err := getOptionalBoolArg(fe.Args, "natural", 1, false)
+	if err != nil {
+		return nil, err
+	}
+	reverse, err := getOptionalBoolArg(fe.Args, "reverse", 2, false)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ss, func(i, j int) bool {
+		a, b := ss[i].Name, ss[j].Name
+		if reverse {
+			a, b = b, a
+		}
+		if natural {
+			return naturalLess(a, b)
+		}
+		return a < b
+	})
+	return ss, nil
+}
+
+func sortSeriesByName(ss []*series) {
+	sort.SliceStable(ss, func(i, j int) bool {
+		return ss[i].Name < ss[j].Name
+	})
+}
+
+// naturalLess returns true if a is less than b, while comparing numbers in a and b by their values.
+func naturalLess(a, b string) bool {
+	for len(a) > 0 && len(b) > 0 {
+		na := getDigitsPrefixLen(a)
+		nb := getDigitsPrefixLen(b)
+		if na > 0 && nb > 0 {
+			da := strings.TrimLeft(a[:na], "0")
+			db := strings.TrimLeft(b[:nb], "0")
+			if len(da) != len(db) {
+				return len(da) < len(db)
+			}
+			if da != db {
+				return da < db
+			}
+			a = a[na:]
+			b = b[nb:]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a = a[1:]
+		b = b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func getDigitsPrefixLen(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.summarize
+func transformSummarize(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	intervalString, err := getStringArg(fe.Args, "intervalString", 1)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := parseInterval(intervalString)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse intervalString: %w", err)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("intervalString must be positive; got %q", intervalString)
+	}
+	funcName, err := getOptionalStringArg(fe.Args, "func", 2, "sum")
+	if err != nil {
+		return nil, err
+	}
+	f, err := getAggrFunc(funcName)
+	if err != nil {
+		return nil, err
+	}
+	alignToFrom, err := getOptionalBoolArg(fe.Args, "alignToFrom", 3, false)
+	if err != nil {
+		return nil, err
+	}
+	start := ec.startTime
+	if !alignToFrom {
+		start -= start % interval
+	}
+	pointsLen := int((ec.endTime-start)/interval) + 1
+	timestamps := make([]int64, pointsLen)
+	for i := range timestamps {
+		timestamps[i] = start + int64(i)*interval
+	}
+	for _, s := range ss {
+		values := make([]float64, pointsLen)
+		var buf []float64
+		idxPrev := 0
+		for i, ts := range s.Timestamps {
+			if ts < start {
+				continue
+			}
+			idx := int((ts - start) / interval)
+			if idx >= pointsLen {
+				break
+			}
+			for idxPrev < idx {
+				values[idxPrev] = f(buf)
+				buf = buf[:0]
+				idxPrev++
+			}
+			buf = append(buf, s.Values[i])
+		}
+		for idxPrev < pointsLen {
+			values[idxPrev] = f(buf)
+			buf = buf[:0]
+			idxPrev++
+		}
+		s.Timestamps = timestamps
+		s.Values = values
+		if alignToFrom {
+			s.setName(fmt.Sprintf("summarize(%s, %q, %q, true)", s.Name, intervalString, funcName))
+		} else {
+			s.setName(fmt.Sprintf("summarize(%s, %q, %q)", s.Name, intervalString, funcName))
+		}
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.timeShift
+func transformTimeShift(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	timeShift, err := getStringArg(fe.Args, "timeShift", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(timeShift) > 0 && timeShift[0] >= '0' && timeShift[0] <= '9' {
+		// Shift to the past by default as Graphite does.
+		timeShift = "-" + timeShift
+	}
+	offset, err := parseInterval(timeShift)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse timeShift: %w", err)
+	}
+	ss, err := getSeriesArg(ec.withTimeShift(offset), fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		timestamps := make([]int64, 0, len(s.Timestamps))
+		values := make([]float64, 0, len(s.Values))
+		for i, ts := range s.Timestamps {
+			ts -= offset
+			if ts < ec.startTime || ts > ec.endTime {
+				continue
+			}
+			timestamps = append(timestamps, ts)
+			values = append(values, s.Values[i])
+		}
+		s.Timestamps = timestamps
+		s.Values = values
+		s.setName(fmt.Sprintf("timeShift(%s, %q)", s.Name, timeShift))
+	}
+	return ss, nil
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.transformNull
+func transformTransformNull(ec *evalConfig, fe *graphiteql.FuncExpr) ([]*series, error) {
+	ss, err := getSeriesArg(ec, fe.Args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	defaultValue, err := getOptionalNumberArg(fe.Args, "default", 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		for i, v := range s.Values {
+			if math.IsNaN(v) {
+				s.Values[i] = defaultValue
+			}
+		}
+		s.setName(fmt.Sprintf("transformNull(%s,%s)", s.Name, formatNumber(defaultValue)))
+	}
+	return ss, nil
+}
+
+// setName sets s name and path expression to name.
+func (s *series) setName(name string) {
+	s.Name = name
+	s.pathExpression = name
+}
+
+// step returns the interval between s datapoints.
+func (s *series) step(ec *evalConfig) int64 {
+	if len(s.Timestamps) < 2 {
+		return ec.storageStep
+	}
+	return s.Timestamps[1] - s.Timestamps[0]
+}
+
+// getPathFromName returns metric path from series name such as `scale(foo.bar;baz=x,10)`.
+func getPathFromName(name string) string {
+	if n := strings.LastIndexByte(name, '('); n >= 0 {
+		name = name[n+1:]
+	}
+	if n := strings.IndexAny(name, ",);"); n >= 0 {
+		name = name[:n]
+	}
+	return name
+}
+
+// getNameFromNodes returns series name constructed from the given nodes.
+//
+// Numeric nodes refer to path parts delimited by dots. Negative numbers refer to parts from the end of path.
+// String nodes refer to tag values.
+func getNameFromNodes(s *series, nodes []graphiteql.Expr) string {
+	path := getPathFromName(s.Name)
+	parts := strings.Split(path, ".")
+	dst := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		switch t := node.(type) {
+		case *graphiteql.NumberExpr:
+			n := int(t.N)
+			if n < 0 {
+				n += len(parts)
+			}
+			if n >= 0 && n < len(parts) {
+				dst = append(dst, parts[n])
+			}
+		case *graphiteql.StringExpr:
+			dst = append(dst, s.Tags[t.S])
+		}
+	}
+	return strings.Join(dst, ".")
+}
+
+// getTagsFromCanonicalPath returns tags from path in the form `name;tag1=value1;...;tagN=valueN`.
+func getTagsFromCanonicalPath(path string) map[string]string {
+	parts := strings.Split(path, ";")
+	tags := make(map[string]string, len(parts))
+	tags["name"] = parts[0]
+	for _, part := range parts[1:] {
+		n := strings.IndexByte(part, '=')
+		if n < 0 {
+			continue
+		}
+		tags[part[:n]] = part[n+1:]
+	}
+	return tags
+}
+
+// formatPathExpressions returns sorted unique path expressions from ss joined with comma.
+func formatPathExpressions(ss []*series) string {
+	m := make(map[string]struct{}, len(ss))
+	a := make([]string, 0, len(ss))
+	for _, s := range ss {
+		if _, ok := m[s.pathExpression]; ok {
+			continue
+		}
+		m[s.pathExpression] = struct{}{}
+		a = append(a, s.pathExpression)
+	}
+	sort.Strings(a)
+	return strings.Join(a, ",")
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func hasString(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package graphite
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphiteql"
+)
+
+func TestExecExprSuccess(t *testing.T) {
+	ec := &evalConfig{
+		startTime:   120e3,
+		endTime:     300e3,
+		storageStep: 60e3,
+	}
+	f := func(query, resultExpected string) {
+		t.Helper()
+		expr, err := graphiteql.Parse(query)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", query, err)
+		}
+		ss, err := evalExpr(ec, expr)
+		if err != nil {
+			t.Fatalf("unexpected error when evaluating %q: %s", query, err)
+		}
+		result := RenderJSONResponse(ss, "")
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q;\ngot\n%s\nwant\n%s", query, result, resultExpected)
+		}
+	}
+	f(`constantLine(1.5)`, `[{"target":"1.5","tags":{"name":"1.5"},"datapoints":[[1.5,120],[1.5,180],[1.5,240],[1.5,300]]}]`)
+	f(`scale(constantLine(2),3)`, `[{"target":"scale(2,3)","tags":{"name":"2"},"datapoints":[[6,120],[6,180],[6,240],[6,300]]}]`)
+	f(`constantLine(2)|offset(-1)|alias('foo')`, `[{"target":"foo","tags":{"name":"2"},"datapoints":[[1,120],[1,180],[1,240],[1,300]]}]`)
+	f(`sumSeries(constantLine(1),constantLine(2))`, `[{"target":"sumSeries(1,2)","tags":{"aggregatedBy":"sum","name":"sumSeries(1,2)"},`+
+		`"datapoints":[[3,120],[3,180],[3,240],[3,300]]}]`)
+	f(`averageSeries(constantLine(1),constantLine(2))`, `[{"target":"averageSeries(1,2)","tags":{"aggregatedBy":"average","name":"averageSeries(1,2)"},`+
+		`"datapoints":[[1.5,120],[1.5,180],[1.5,240],[1.5,300]]}]`)
+	f(`diffSeries(constantLine(5),constantLine(2))`, `[{"target":"diffSeries(2,5)","tags":{"aggregatedBy":"diff","name":"diffSeries(2,5)"},`+
+		`"datapoints":[[3,120],[3,180],[3,240],[3,300]]}]`)
+	f(`asPercent(constantLine(1),4)`, `[{"target":"asPercent(1,4)","tags":{"name":"1"},"datapoints":[[25,120],[25,180],[25,240],[25,300]]}]`)
+	f(`asPercent(constantLine(1),constantLine(5))`, `[{"target":"asPercent(1,5)","tags":{"name":"1"},"datapoints":[[20,120],[20,180],[20,240],[20,300]]}]`)
+	f(`divideSeries(constantLine(3),constantLine(2))`, `[{"target":"divideSeries(3,2)","tags":{"name":"3"},"datapoints":[[1.5,120],[1.5,180],[1.5,240],[1.5,300]]}]`)
+	f(`integral(constantLine(2))`, `[{"target":"integral(2)","tags":{"name":"2"},"datapoints":[[2,120],[4,180],[6,240],[8,300]]}]`)
+	f(`derivative(integral(constantLine(2)))`, `[{"target":"derivative(integral(2))","tags":{"name":"2"},"datapoints":[[null,120],[2,180],[2,240],[2,300]]}]`)
+	f(`perSecond(integral(constantLine(60)))`, `[{"target":"perSecond(integral(60))","tags":{"name":"60"},"datapoints":[[null,120],[1,180],[1,240],[1,300]]}]`)
+	f(`movingAverage(integral(constantLine(1)),2)`, `[{"target":"movingAverage(integral(1),2)","tags":{"name":"1"},"datapoints":[[1.5,120],[2.5,180],[3.5,240],[4.5,300]]}]`)
+	f(`movingSum(constantLine(1),'3min')`, `[{"target":"movingSum(1,\"3min\")","tags":{"name":"1"},"datapoints":[[3,120],[3,180],[3,240],[3,300]]}]`)
+	f(`summarize(constantLine(1),'2min')`, `[{"target":"summarize(1, \"2min\", \"sum\")","tags":{"name":"1"},"datapoints":[[2,120],[2,240]]}]`)
+	f(`summarize(constantLine(1),'4min','max')`, `[{"target":"summarize(1, \"4min\", \"max\")","tags":{"name":"1"},"datapoints":[[1,0],[1,240]]}]`)
+	f(`timeShift(integral(constantLine(1)),'1min')`, `[{"target":"timeShift(integral(1), \"-1min\")","tags":{"name":"1"},"datapoints":[[1,120],[2,180],[3,240],[4,300]]}]`)
+	f(`removeAboveValue(integral(constantLine(1)),2)`, `[{"target":"removeAboveValue(integral(1), 2)","tags":{"name":"1"},"datapoints":[[1,120],[2,180],[null,240],[null,300]]}]`)
+	f(`keepLastValue(removeAboveValue(integral(constantLine(1)),2))`, `[{"target":"keepLastValue(removeAboveValue(integral(1), 2))","tags":{"name":"1"},`+
+		`"datapoints":[[1,120],[2,180],[2,240],[2,300]]}]`)
+	f(`transformNull(removeBelowValue(integral(constantLine(1)),3),-1)`, `[{"target":"transformNull(removeBelowValue(integral(1), 3),-1)","tags":{"name":"1"},`+
+		`"datapoints":[[-1,120],[-1,180],[3,240],[4,300]]}]`)
+	f(`highestMax(group(constantLine(1),constantLine(3),constantLine(2)),2)`, `[{"target":"3","tags":{"name":"3"},"datapoints":[[3,120],[3,180],[3,240],[3,300]]},`+
+		`{"target":"2","tags":{"name":"2"},"datapoints":[[2,120],[2,180],[2,240],[2,300]]}]`)
+	f(`limit(sortByName(group(constantLine(3),constantLine(1)),reverse=True),1)`, `[{"target":"3","tags":{"name":"3"},"datapoints":[[3,120],[3,180],[3,240],[3,300]]}]`)
+	f(`exclude(group(constantLine(3),constantLine(1)),'3')`, `[{"target":"1","tags":{"name":"1"},"datapoints":[[1,120],[1,180],[1,240],[1,300]]}]`)
+	f(`consolidateBy(constantLine(1),'max')`, `[{"target":"consolidateBy(1,\"max\")","tags":{"name":"1"},"datapoints":[[1,120],[1,180],[1,240],[1,300]]}]`)
+}
+
+func TestExecExprFailure(t *testing.T) {
+	ec := &evalConfig{
+		startTime:   120e3,
+		endTime:     300e3,
+		storageStep: 60e3,
+	}
+	f := func(query string) {
+		t.Helper()
+		expr, err := graphiteql.Parse(query)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", query, err)
+		}
+		ss, err := evalExpr(ec, expr)
+		if err == nil {
+			t.Fatalf("expecting non-nil error for %q; got %d series", query, len(ss))
+		}
+	}
+	f(`123`)
+	f(`'foo'`)
+	f(`unknownFunc(constantLine(1))`)
+	f(`constantLine('foo')`)
+	f(`scale(constantLine(1))`)
+	f(`scale(constantLine(1),'foo')`)
+	f(`summarize(constantLine(1),'foo')`)
+	f(`summarize(constantLine(1),'1min','unknownFunc')`)
+	f(`movingAverage(constantLine(1),0)`)
+	f(`divideSeries(constantLine(1),group(constantLine(1),constantLine(2)))`)
+	f(`aliasSub(constantLine(1),'(','x')`)
+}
+
+func TestGetNameFromNodes(t *testing.T) {
+	f := func(name string, nodes []graphiteql.Expr, resultExpected string) {
+		t.Helper()
+		s := &series{
+			Name: name,
+			Tags: map[string]string{
+				"name": name,
+				"foo":  "bar",
+			},
+		}
+		result := getNameFromNodes(s, nodes)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for getNameFromNodes(%q); got %q; want %q", name, result, resultExpected)
+		}
+	}
+	n := func(n float64) graphiteql.Expr {
+		return &graphiteql.NumberExpr{N: n}
+	}
+	s := func(s string) graphiteql.Expr {
+		return &graphiteql.StringExpr{S: s}
+	}
+	f("a.b.c", []graphiteql.Expr{n(1)}, "b")
+	f("a.b.c", []graphiteql.Expr{n(0), n(2)}, "a.c")
+	f("a.b.c", []graphiteql.Expr{n(-1)}, "c")
+	f("a.b.c", []graphiteql.Expr{n(10)}, "")
+	f("scale(a.b.c,10)", []graphiteql.Expr{n(1)}, "b")
+	f("a.b.c;foo=bar", []graphiteql.Expr{n(2), s("foo")}, "c.bar")
+}
+
+func TestNonNegativeDelta(t *testing.T) {
+	f := func(v, prev, maxValue, minValue, resultExpected float64) {
+		t.Helper()
+		result := nonNegativeDelta(v, prev, maxValue, minValue)
+		if math.IsNaN(resultExpected) {
+			if !math.IsNaN(result) {
+				t.Fatalf("unexpected result; got %v; want NaN", result)
+			}
+			return
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %v; want %v", result, resultExpected)
+		}
+	}
+	f(10, nan, nan, nan, nan)
+	f(10, 5, nan, nan, 5)
+	f(5, 10, nan, nan, nan)
+	f(5, 10, 20, nan, 16)
+	f(5, 10, nan, 0, 5)
+	f(30, 10, 20, nan, nan)
+}
+
+func TestNaturalLess(t *testing.T) {
+	a := []string{"foo10", "foo9", "foo1", "bar", "foo09a", "foo"}
+	sort.SliceStable(a, func(i, j int) bool {
+		return naturalLess(a[i], a[j])
+	})
+	resultExpected := []string{"bar", "foo", "foo1", "foo9", "foo09a", "foo10"}
+	if !reflect.DeepEqual(a, resultExpected) {
+		t.Fatalf("unexpected result; got %q; want %q", a, resultExpected)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,11 +31,32 @@ var (
 	maxQueueDuration = flag.Duration("search.maxQueueDuration", 10*time.Second, "The maximum time the request waits for execution when -search.maxConcurrentRequests "+
 		"limit is reached; see also -search.maxQueryDuration")
 	resetCacheAuthKey    = flag.String("search.resetCacheAuthKey", "", "Optional authKey for resetting rollup cache via /internal/resetRollupResultCache call")
+	cancelQueryAuthKey   = flag.String("search.cancelQueryAuthKey", "", "Optional authKey for canceling active queries via /api/v1/status/active_queries/cancel call")
 	logSlowQueryDuration = flag.Duration("search.logSlowQueryDuration", 5*time.Second, "Log queries with execution time exceeding this value. Zero disables slow query logging")
 )
 
 var slowQueries = metrics.NewCounter(`vm_slow_queries_total`)
 
+// logSlowQuery logs the slow query r, which took d, in key=value form, so it can be parsed by log processing tools.
+func logSlowQuery(r *http.Request, d time.Duration, qs *promql.QueryStats) {
+	var b []byte
+	b = append(b, "remoteAddr="...)
+	b = append(b, httpserver.GetQuotedRemoteAddr(r)...)
+	b = append(b, " path="...)
+	b = strconv.AppendQuote(b, r.URL.Path)
+	for _, argKey := range []string{"query", "match[]", "time", "start", "end", "step"} {
+		if argValue := r.FormValue(argKey); argValue != "" {
+			b = append(b, ' ')
+			b = append(b, argKey...)
+			b = append(b, '=')
+			b = strconv.AppendQuote(b, argValue)
+		}
+	}
+	b = append(b, fmt.Sprintf(" duration=%.3fs seriesFetched=%d samplesFetched=%d requestURI=", d.Seconds(), qs.SeriesFetched, qs.SamplesFetched)...)
+	b = strconv.AppendQuote(b, httpserver.GetRequestURI(r))
+	logger.Warnf("slow query according to -search.logSlowQueryDuration=%s: %s", *logSlowQueryDuration, b)
+}
+
 func getDefaultMaxConcurrentRequests() int {
 	n := cgroup.AvailableCPUs()
 	if n <= 4 {
@@ -119,15 +141,13 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		}
 	}
 
+	var qs promql.QueryStats
 	if *logSlowQueryDuration > 0 {
 		actualStartTime := time.Now()
 		defer func() {
 			d := time.Since(actualStartTime)
 			if d >= *logSlowQueryDuration {
-				remoteAddr := httpserver.GetQuotedRemoteAddr(r)
-				requestURI := httpserver.GetRequestURI(r)
-				logger.Warnf("slow query according to -search.logSlowQueryDuration=%s: remoteAddr=%s, duration=%.3f seconds; requestURI: %q",
-					*logSlowQueryDuration, remoteAddr, d.Seconds(), requestURI)
+				logSlowQuery(r, d, &qs)
 				slowQueries.Inc()
 			}
 		}()
@@ -139,7 +159,13 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			sendPrometheusError(w, r, fmt.Errorf("invalid authKey=%q for %q", r.FormValue("authKey"), path))
 			return true
 		}
-		promql.ResetRollupResultCache()
+		if r.FormValue("start") == "" && r.FormValue("end") == "" {
+			promql.ResetRollupResultCache()
+			return true
+		}
+		if err := resetRollupResultCacheForTimeRange(r); err != nil {
+			sendPrometheusError(w, r, err)
+		}
 		return true
 	}
 
@@ -208,7 +234,7 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 	case "/api/v1/query":
 		queryRequests.Inc()
 		httpserver.EnableCORS(w, r)
-		if err := prometheus.QueryHandler(startTime, w, r); err != nil {
+		if err := prometheus.QueryHandler(&qs, startTime, w, r); err != nil {
 			queryErrors.Inc()
 			sendPrometheusError(w, r, err)
 			return true
@@ -217,7 +243,7 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 	case "/api/v1/query_range":
 		queryRangeRequests.Inc()
 		httpserver.EnableCORS(w, r)
-		if err := prometheus.QueryRangeHandler(startTime, w, r); err != nil {
+		if err := prometheus.QueryRangeHandler(&qs, startTime, w, r); err != nil {
 			queryRangeErrors.Inc()
 			sendPrometheusError(w, r, err)
 			return true
@@ -259,6 +285,15 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/api/v1/autocomplete":
+		autocompleteRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.AutocompleteHandler(startTime, w, r); err != nil {
+			autocompleteErrors.Inc()
+			sendPrometheusError(w, r, err)
+			return true
+		}
+		return true
 	case "/api/v1/status/tsdb":
 		statusTSDBRequests.Inc()
 		if err := prometheus.TSDBStatusHandler(startTime, w, r); err != nil {
@@ -269,8 +304,50 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		return true
 	case "/api/v1/status/active_queries":
 		statusActiveQueriesRequests.Inc()
+		if r.FormValue("format") == "json" {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			promql.WriteActiveQueriesJSON(w)
+			return true
+		}
 		promql.WriteActiveQueries(w)
 		return true
+	case "/api/v1/status/active_queries/cancel":
+		statusActiveQueriesCancelRequests.Inc()
+		if len(*cancelQueryAuthKey) > 0 && r.FormValue("authKey") != *cancelQueryAuthKey {
+			statusActiveQueriesCancelErrors.Inc()
+			sendPrometheusError(w, r, fmt.Errorf("invalid authKey=%q for %q; it must match the value from -search.cancelQueryAuthKey command-line flag", r.FormValue("authKey"), path))
+			return true
+		}
+		idStr := r.FormValue("id")
+		qid, err := strconv.ParseUint(idStr, 16, 64)
+		if err != nil {
+			statusActiveQueriesCancelErrors.Inc()
+			sendPrometheusError(w, r, fmt.Errorf("cannot parse `id` arg %q: %w", idStr, err))
+			return true
+		}
+		if !promql.CancelActiveQuery(qid) {
+			statusActiveQueriesCancelErrors.Inc()
+			sendPrometheusError(w, r, fmt.Errorf("cannot find active query with id=%q", idStr))
+			return true
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, `{"status":"success"}`)
+		return true
+	case "/api/v1/status/buildinfo":
+		buildInfoRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		prometheus.BuildInfoHandler(w, r)
+		return true
+	case "/api/v1/status/flags":
+		flagsRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		prometheus.FlagsHandler(w, r)
+		return true
+	case "/api/v1/status/runtimeinfo":
+		runtimeInfoRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		prometheus.RuntimeInfoHandler(w, r)
+		return true
 	case "/api/v1/status/top_queries":
 		topQueriesRequests.Inc()
 		if err := prometheus.QueryStatsHandler(startTime, w, r); err != nil {
@@ -279,6 +356,14 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/expand-with-exprs":
+		expandWithExprsRequests.Inc()
+		if err := prometheus.ExpandWithExprsHandler(w, r); err != nil {
+			expandWithExprsErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
 	case "/api/v1/export":
 		exportRequests.Inc()
 		if err := prometheus.ExportHandler(startTime, w, r); err != nil {
@@ -311,6 +396,23 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/api/v1/read":
+		remoteReadRequests.Inc()
+		if err := prometheus.RemoteReadHandler(startTime, w, r); err != nil {
+			remoteReadErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
+	case "/render":
+		graphiteRenderRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := graphite.RenderHandler(startTime, w, r); err != nil {
+			graphiteRenderErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
 	case "/metrics/find", "/metrics/find/":
 		graphiteMetricsFindRequests.Inc()
 		httpserver.EnableCORS(w, r)
@@ -414,16 +516,31 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		fmt.Fprintf(w, "%s", `{"status":"success","data":{"alerts":[]}}`)
 		return true
 	case "/api/v1/metadata":
-		// Return dumb placeholder for https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
 		metadataRequests.Inc()
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		fmt.Fprintf(w, "%s", `{"status":"success","data":{}}`)
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.MetadataHandler(startTime, w, r); err != nil {
+			metadataErrors.Inc()
+			sendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	case "/api/v1/targets/metadata":
+		targetsMetadataRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.TargetsMetadataHandler(startTime, w, r); err != nil {
+			targetsMetadataErrors.Inc()
+			sendPrometheusError(w, r, err)
+			return true
+		}
 		return true
 	case "/api/v1/query_exemplars":
-		// Return dumb placeholder for https://prometheus.io/docs/prometheus/latest/querying/api/#querying-exemplars
 		queryExemplarsRequests.Inc()
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		fmt.Fprintf(w, "%s", `{"status":"success","data":null}`)
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.QueryExemplarsHandler(startTime, w, r); err != nil {
+			queryExemplarsErrors.Inc()
+			sendPrometheusError(w, r, err)
+			return true
+		}
 		return true
 	case "/api/v1/admin/tsdb/delete_series":
 		deleteRequests.Inc()
@@ -444,6 +561,27 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 	}
 }
 
+// resetRollupResultCacheForTimeRange resets rollup result cache entries on the time range
+// specified via `start` and `end` query args.
+//
+// Missing `start` defaults to the beginning of time, while missing `end` defaults to the current time.
+func resetRollupResultCacheForTimeRange(r *http.Request) error {
+	ct := time.Now().UnixNano() / 1e6
+	start, err := searchutils.GetTime(r, "start", 0)
+	if err != nil {
+		return err
+	}
+	end, err := searchutils.GetTime(r, "end", ct)
+	if err != nil {
+		return err
+	}
+	if start > end {
+		return fmt.Errorf("start=%d cannot exceed end=%d", start, end)
+	}
+	promql.ResetRollupResultCacheForTimeRange(start, end)
+	return nil
+}
+
 func isGraphiteTagsPath(path string) bool {
 	switch path {
 	// See https://graphite.readthedocs.io/en/stable/tags.html for a list of Graphite Tags API paths.
@@ -493,14 +631,27 @@ var (
 	labelsCountRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/labels/count"}`)
 	labelsCountErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/labels/count"}`)
 
+	autocompleteRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/autocomplete"}`)
+	autocompleteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/autocomplete"}`)
+
 	statusTSDBRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/tsdb"}`)
 	statusTSDBErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/tsdb"}`)
 
 	statusActiveQueriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/active_queries"}`)
 
+	statusActiveQueriesCancelRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/active_queries/cancel"}`)
+	statusActiveQueriesCancelErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/active_queries/cancel"}`)
+
+	buildInfoRequests   = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/buildinfo"}`)
+	flagsRequests       = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/flags"}`)
+	runtimeInfoRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/runtimeinfo"}`)
+
 	topQueriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/top_queries"}`)
 	topQueriesErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/top_queries"}`)
 
+	expandWithExprsRequests = metrics.NewCounter(`vm_http_requests_total{path="/expand-with-exprs"}`)
+	expandWithExprsErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/expand-with-exprs"}`)
+
 	deleteRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/admin/tsdb/delete_series"}`)
 	deleteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/admin/tsdb/delete_series"}`)
 
@@ -516,6 +667,12 @@ var (
 	federateRequests = metrics.NewCounter(`vm_http_requests_total{path="/federate"}`)
 	federateErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/federate"}`)
 
+	remoteReadRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/read"}`)
+	remoteReadErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/read"}`)
+
+	graphiteRenderRequests = metrics.NewCounter(`vm_http_requests_total{path="/render"}`)
+	graphiteRenderErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/render"}`)
+
 	graphiteMetricsFindRequests = metrics.NewCounter(`vm_http_requests_total{path="/metrics/find"}`)
 	graphiteMetricsFindErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/metrics/find"}`)
 
@@ -551,8 +708,14 @@ var (
 
 	graphiteFunctionsRequests = metrics.NewCounter(`vm_http_request_total{path="/functions"}`)
 
+	metadataRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/metadata"}`)
+	metadataErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/metadata"}`)
+
+	targetsMetadataRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/targets/metadata"}`)
+	targetsMetadataErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/targets/metadata"}`)
+
 	rulesRequests          = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/rules"}`)
 	alertsRequests         = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/alerts"}`)
-	metadataRequests       = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/metadata"}`)
 	queryExemplarsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/query_exemplars"}`)
+	queryExemplarsErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/query_exemplars"}`)
 )
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,6 +18,8 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/valyala/fastrand"
@@ -41,12 +44,19 @@ type Result struct {
 	// Values are sorted by Timestamps.
 	Values     []float64
 	Timestamps []int64
+
+	// Histograms contains native histograms marshaled with prompb.Histogram.MarshalCompact for Values.
+	//
+	// It is nil if the time series doesn't contain native histograms.
+	// Empty histograms correspond to ordinary samples.
+	Histograms [][]byte
 }
 
 func (r *Result) reset() {
 	r.MetricName.Reset()
 	r.Values = r.Values[:0]
 	r.Timestamps = r.Timestamps[:0]
+	r.Histograms = nil
 }
 
 // Results holds results returned from ProcessSearchQuery.
@@ -54,6 +64,7 @@ type Results struct {
 	tr        storage.TimeRange
 	fetchData bool
 	deadline  searchutils.Deadline
+	isPartial bool
 
 	packedTimeseries []packedTimeseries
 	sr               *storage.Search
@@ -65,6 +76,11 @@ func (rss *Results) Len() int {
 	return len(rss.packedTimeseries)
 }
 
+// IsPartial returns true if rss doesn't contain data from some of -search.remoteSource because they were unavailable.
+func (rss *Results) IsPartial() bool {
+	return rss.isPartial
+}
+
 // Cancel cancels rss work.
 func (rss *Results) Cancel() {
 	rss.mustClose()
@@ -193,7 +209,8 @@ var resultPool sync.Pool
 // Data processing is immediately stopped if f returns non-nil error.
 //
 // rss becomes unusable after the call to RunParallel.
-func (rss *Results) RunParallel(f func(rs *Result, workerID uint) error) error {
+func (rss *Results) RunParallel(qt *querytracer.Tracer, f func(rs *Result, workerID uint) error) error {
+	qt = qt.NewChild("parallel process of fetched data")
 	defer rss.mustClose()
 
 	// Spin up local workers.
@@ -249,6 +266,7 @@ func (rss *Results) RunParallel(f func(rs *Result, workerID uint) error) error {
 
 	perQueryRowsProcessed.Update(float64(rowsProcessedTotal))
 	perQuerySeriesProcessed.Update(float64(seriesProcessedTotal))
+	qt.Donef("series=%d, samples=%d", seriesProcessedTotal, rowsProcessedTotal)
 
 	// Shut down local workers
 	for _, workCh := range workChs {
@@ -267,6 +285,9 @@ var gomaxprocs = cgroup.AvailableCPUs()
 type packedTimeseries struct {
 	metricName string
 	brs        []blockRef
+
+	// remoteRows contains rows fetched from -search.remoteSource urls.
+	remoteRows []remoteRows
 }
 
 type unpackWorkItem struct {
@@ -468,7 +489,29 @@ func (pts *packedTimeseries) Unpack(dst *Result, tbf *tmpBlocksFile, tr storage.
 	if firstErr != nil {
 		return firstErr
 	}
+	if len(pts.remoteRows) == 0 {
+		mergeSortBlocks(dst, sbs)
+		return nil
+	}
+	for _, rr := range pts.remoteRows {
+		sb := getSortBlock()
+		for i, ts := range rr.timestamps {
+			if ts >= tr.MinTimestamp && ts <= tr.MaxTimestamp {
+				sb.Timestamps = append(sb.Timestamps, ts)
+				sb.Values = append(sb.Values, rr.values[i])
+			}
+		}
+		sbs = append(sbs, sb)
+	}
+	pts.remoteRows = nil
 	mergeSortBlocks(dst, sbs)
+	// Remove samples with identical timestamps, which may be returned
+	// from multiple installations containing the same data.
+	if dst.Histograms != nil {
+		dst.Timestamps, dst.Values, dst.Histograms = removeDuplicateHistogramTimestamps(dst.Timestamps, dst.Values, dst.Histograms)
+		return nil
+	}
+	dst.Timestamps, dst.Values = removeDuplicateTimestamps(dst.Timestamps, dst.Values)
 	return nil
 }
 
@@ -503,6 +546,13 @@ func mergeSortBlocks(dst *Result, sbh sortBlocksHeap) {
 	if len(sbh) == 0 {
 		return
 	}
+	hasHistograms := false
+	for _, sb := range sbh {
+		if len(sb.Histograms) > 0 {
+			hasHistograms = true
+			break
+		}
+	}
 	heap.Init(&sbh)
 	for {
 		top := sbh[0]
@@ -510,6 +560,9 @@ func mergeSortBlocks(dst *Result, sbh sortBlocksHeap) {
 		if len(sbh) == 0 {
 			dst.Timestamps = append(dst.Timestamps, top.Timestamps[top.NextIdx:]...)
 			dst.Values = append(dst.Values, top.Values[top.NextIdx:]...)
+			if hasHistograms {
+				dst.Histograms = top.appendHistograms(dst.Histograms, top.NextIdx, len(top.Timestamps))
+			}
 			putSortBlock(top)
 			break
 		}
@@ -524,6 +577,9 @@ func mergeSortBlocks(dst *Result, sbh sortBlocksHeap) {
 		}
 		dst.Timestamps = append(dst.Timestamps, top.Timestamps[top.NextIdx:idxNext]...)
 		dst.Values = append(dst.Values, top.Values[top.NextIdx:idxNext]...)
+		if hasHistograms {
+			dst.Histograms = top.appendHistograms(dst.Histograms, top.NextIdx, idxNext)
+		}
 		if idxNext < len(top.Timestamps) {
 			top.NextIdx = idxNext
 			heap.Push(&sbh, top)
@@ -533,6 +589,10 @@ func mergeSortBlocks(dst *Result, sbh sortBlocksHeap) {
 		}
 	}
 
+	if hasHistograms {
+		// Native histograms cannot be deduplicated, since they must stay aligned with values.
+		return
+	}
 	timestamps, values := storage.DeduplicateSamples(dst.Timestamps, dst.Values)
 	dedups := len(dst.Timestamps) - len(timestamps)
 	dedupsDuringSelect.Add(dedups)
@@ -546,12 +606,32 @@ type sortBlock struct {
 	Timestamps []int64
 	Values     []float64
 	NextIdx    int
+
+	// Histograms contains native histograms for Values. It is empty if the block has no native histograms.
+	Histograms [][]byte
 }
 
 func (sb *sortBlock) reset() {
 	sb.Timestamps = sb.Timestamps[:0]
 	sb.Values = sb.Values[:0]
 	sb.NextIdx = 0
+	for i := range sb.Histograms {
+		sb.Histograms[i] = nil
+	}
+	sb.Histograms = sb.Histograms[:0]
+}
+
+// appendHistograms appends histograms for samples in the range [start:end) to dst and returns the result.
+//
+// Empty histograms are appended if sb has no native histograms.
+func (sb *sortBlock) appendHistograms(dst [][]byte, start, end int) [][]byte {
+	if len(sb.Histograms) == 0 {
+		for i := start; i < end; i++ {
+			dst = append(dst, nil)
+		}
+		return dst
+	}
+	return append(dst, sb.Histograms[start:end]...)
 }
 
 func (sb *sortBlock) unpackFrom(tmpBlock *storage.Block, tbf *tmpBlocksFile, br blockRef, tr storage.TimeRange) error {
@@ -562,6 +642,9 @@ func (sb *sortBlock) unpackFrom(tmpBlock *storage.Block, tbf *tmpBlocksFile, br
 		return fmt.Errorf("cannot unmarshal block: %w", err)
 	}
 	sb.Timestamps, sb.Values = tmpBlock.AppendRowsWithTimeRangeFilter(sb.Timestamps[:0], sb.Values[:0], tr)
+	if tmpBlock.HasHistograms() {
+		sb.Histograms = tmpBlock.AppendHistogramsWithTimeRangeFilter(sb.Histograms[:0], tr)
+	}
 	skippedRows := tmpBlock.RowsCount() - len(sb.Timestamps)
 	metricRowsSkipped.Add(skippedRows)
 	return nil
@@ -607,6 +690,19 @@ func DeleteSeries(sq *storage.SearchQuery, deadline searchutils.Deadline) (int,
 	return vmstorage.DeleteMetrics(tfss)
 }
 
+// DeleteSeriesOnTimeRange deletes samples on the time range from sq for time series matching sq.TagFilterss.
+func DeleteSeriesOnTimeRange(sq *storage.SearchQuery, deadline searchutils.Deadline) (int, error) {
+	tr := storage.TimeRange{
+		MinTimestamp: sq.MinTimestamp,
+		MaxTimestamp: sq.MaxTimestamp,
+	}
+	tfss, err := setupTfss(tr, sq.TagFilterss, deadline)
+	if err != nil {
+		return 0, err
+	}
+	return vmstorage.DeleteMetricsOnTimeRange(tfss, tr)
+}
+
 // GetLabelsOnTimeRange returns labels for the given tr until the given deadline.
 func GetLabelsOnTimeRange(tr storage.TimeRange, deadline searchutils.Deadline) ([]string, error) {
 	if deadline.Exceeded() {
@@ -754,6 +850,32 @@ func GetLabelValues(labelName string, deadline searchutils.Deadline) ([]string,
 	return labelValues, nil
 }
 
+// GetLabelValuesWithPrefix returns up to limit label values for the given labelName starting with the given prefix on the given tr.
+//
+// If tagFilterss is non-empty, then only label values for time series matching tagFilterss are returned.
+// The returned label values are sorted.
+func GetLabelValuesWithPrefix(labelName, prefix string, tagFilterss [][]storage.TagFilter, tr storage.TimeRange, limit int, deadline searchutils.Deadline) ([]string, error) {
+	if deadline.Exceeded() {
+		return nil, fmt.Errorf("timeout exceeded before starting the query processing: %s", deadline.String())
+	}
+	if labelName == "__name__" {
+		labelName = ""
+	}
+	tfss, err := setupTfss(tr, tagFilterss, deadline)
+	if err != nil {
+		return nil, err
+	}
+	labelValues, err := vmstorage.SearchTagValuesWithPrefix(tr, []byte(labelName), []byte(prefix), tfss, limit, *maxMetricsPerSearch, deadline.Deadline())
+	if err != nil {
+		return nil, fmt.Errorf("error during search for label values with prefix %q for labelName=%q on time range %s: %w", prefix, labelName, tr.String(), err)
+	}
+	sort.Strings(labelValues)
+	if len(labelValues) > limit {
+		labelValues = labelValues[:limit]
+	}
+	return labelValues, nil
+}
+
 // GetTagValueSuffixes returns tag value suffixes for the given tagKey and the given tagValuePrefix.
 //
 // It can be used for implementing https://graphite-api.readthedocs.io/en/latest/api.html#metrics-find
@@ -805,11 +927,11 @@ func GetLabelEntries(deadline searchutils.Deadline) ([]storage.TagEntry, error)
 }
 
 // GetTSDBStatusForDate returns tsdb status according to https://prometheus.io/docs/prometheus/latest/querying/api/#tsdb-stats
-func GetTSDBStatusForDate(deadline searchutils.Deadline, date uint64, topN int) (*storage.TSDBStatus, error) {
+func GetTSDBStatusForDate(deadline searchutils.Deadline, date uint64, focusLabel string, topN int, diskUsage bool) (*storage.TSDBStatus, error) {
 	if deadline.Exceeded() {
 		return nil, fmt.Errorf("timeout exceeded before starting the query processing: %s", deadline.String())
 	}
-	status, err := vmstorage.GetTSDBStatusForDate(date, topN, deadline.Deadline())
+	status, err := vmstorage.GetTSDBStatusForDate(date, focusLabel, topN, diskUsage, deadline.Deadline())
 	if err != nil {
 		return nil, fmt.Errorf("error during tsdb status request: %w", err)
 	}
@@ -819,7 +941,7 @@ func GetTSDBStatusForDate(deadline searchutils.Deadline, date uint64, topN int)
 // GetTSDBStatusWithFilters returns tsdb status according to https://prometheus.io/docs/prometheus/latest/querying/api/#tsdb-stats
 //
 // It accepts aribtrary filters on time series in sq.
-func GetTSDBStatusWithFilters(deadline searchutils.Deadline, sq *storage.SearchQuery, topN int) (*storage.TSDBStatus, error) {
+func GetTSDBStatusWithFilters(deadline searchutils.Deadline, sq *storage.SearchQuery, focusLabel string, topN int, diskUsage bool) (*storage.TSDBStatus, error) {
 	if deadline.Exceeded() {
 		return nil, fmt.Errorf("timeout exceeded before starting the query processing: %s", deadline.String())
 	}
@@ -832,7 +954,7 @@ func GetTSDBStatusWithFilters(deadline searchutils.Deadline, sq *storage.SearchQ
 		return nil, err
 	}
 	date := uint64(tr.MinTimestamp) / (3600 * 24 * 1000)
-	status, err := vmstorage.GetTSDBStatusWithFiltersForDate(tfss, date, topN, deadline.Deadline())
+	status, err := vmstorage.GetTSDBStatusWithFiltersForDate(tfss, date, focusLabel, topN, diskUsage, deadline.Deadline())
 	if err != nil {
 		return nil, fmt.Errorf("error during tsdb status with filters request: %w", err)
 	}
@@ -851,6 +973,17 @@ func GetSeriesCount(deadline searchutils.Deadline) (uint64, error) {
 	return n, nil
 }
 
+// SearchMetricsMetadata returns metric metadata rows sorted by metric name.
+//
+// If metric is non-empty, then only rows for the given metric are returned.
+// Non-positive limit and limitPerMetric mean no limit.
+func SearchMetricsMetadata(metric string, limit, limitPerMetric int, deadline searchutils.Deadline) ([]prommetadata.Row, error) {
+	if deadline.Exceeded() {
+		return nil, fmt.Errorf("timeout exceeded before starting to search metrics metadata: %s", deadline.String())
+	}
+	return vmstorage.SearchMetricsMetadata(metric, limit, limitPerMetric), nil
+}
+
 func getStorageSearch() *storage.Search {
 	v := ssPool.Get()
 	if v == nil {
@@ -1003,10 +1136,46 @@ func SearchMetricNames(sq *storage.SearchQuery, deadline searchutils.Deadline) (
 	return mns, nil
 }
 
+// SearchExemplars returns exemplars for time series matching the given sq until the given deadline.
+func SearchExemplars(sq *storage.SearchQuery, deadline searchutils.Deadline) ([]storage.MetricExemplars, error) {
+	if deadline.Exceeded() {
+		return nil, fmt.Errorf("timeout exceeded before starting to search exemplars: %s", deadline.String())
+	}
+
+	// Setup search.
+	tr := storage.TimeRange{
+		MinTimestamp: sq.MinTimestamp,
+		MaxTimestamp: sq.MaxTimestamp,
+	}
+	if err := vmstorage.CheckTimeRange(tr); err != nil {
+		return nil, err
+	}
+	tfss, err := setupTfss(tr, sq.TagFilterss, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	mes, err := vmstorage.SearchExemplars(tfss, tr, *maxMetricsPerSearch, deadline.Deadline())
+	if err != nil {
+		return nil, fmt.Errorf("cannot find exemplars: %w", err)
+	}
+	return mes, nil
+}
+
 // ProcessSearchQuery performs sq until the given deadline.
 //
+// ql may contain per-query limits overriding -search.maxUniqueTimeseries and -search.maxSamplesPerQuery. It may be nil.
+//
+// If denyPartialResponse is false, then the results are returned even if some of -search.remoteSource are unavailable.
+// Results.IsPartial returns true in this case.
+//
 // Results.RunParallel or Results.Cancel must be called on the returned Results.
-func ProcessSearchQuery(sq *storage.SearchQuery, fetchData bool, deadline searchutils.Deadline) (*Results, error) {
+func ProcessSearchQuery(qt *querytracer.Tracer, denyPartialResponse bool, sq *storage.SearchQuery, fetchData bool, ql *searchutils.QueryLimits, deadline searchutils.Deadline) (*Results, error) {
+	if qt.Enabled() {
+		qt = qt.NewChild("fetch matching series: filters=%s, timeRange=[%s..%s], fetchData=%v", tagFilterssToString(sq.TagFilterss),
+			storage.TimestampToHumanReadableFormat(sq.MinTimestamp), storage.TimestampToHumanReadableFormat(sq.MaxTimestamp), fetchData)
+		defer qt.Done()
+	}
 	if deadline.Exceeded() {
 		return nil, fmt.Errorf("timeout exceeded before starting the query processing: %s", deadline.String())
 	}
@@ -1029,8 +1198,11 @@ func ProcessSearchQuery(sq *storage.SearchQuery, fetchData bool, deadline search
 
 	sr := getStorageSearch()
 	startTime := time.Now()
-	maxSeriesCount := sr.Init(vmstorage.Storage, tfss, tr, *maxMetricsPerSearch, deadline.Deadline())
+	maxMetrics := ql.GetMaxUniqueTimeseries(*maxMetricsPerSearch)
+	maxSamples := ql.GetMaxSamplesPerQuery(*maxSamplesPerQuery)
+	maxSeriesCount := sr.Init(vmstorage.Storage, tfss, tr, maxMetrics, deadline.Deadline())
 	indexSearchDuration.UpdateDuration(startTime)
+	qt.Printf("search for matching series in the index: found up to %d series", maxSeriesCount)
 	m := make(map[string][]blockRef, maxSeriesCount)
 	orderedMetricNames := make([]string, 0, maxSeriesCount)
 	blocksRead := 0
@@ -1046,10 +1218,10 @@ func ProcessSearchQuery(sq *storage.SearchQuery, fetchData bool, deadline search
 		}
 		br := sr.MetricBlockRef.BlockRef
 		samples += br.RowsCount()
-		if *maxSamplesPerQuery > 0 && samples > *maxSamplesPerQuery {
+		if maxSamples > 0 && samples > maxSamples {
 			putTmpBlocksFile(tbf)
 			putStorageSearch(sr)
-			return nil, fmt.Errorf("cannot select more than -search.maxSamplesPerQuery=%d samples; possible solutions: to increase the -search.maxSamplesPerQuery; to reduce time range for the query; to use more specific label filters in order to select lower number of series", *maxSamplesPerQuery)
+			return nil, fmt.Errorf("cannot select more than -search.maxSamplesPerQuery=%d samples; possible solutions: to increase the -search.maxSamplesPerQuery; to reduce time range for the query; to use more specific label filters in order to select lower number of series", maxSamples)
 		}
 		buf = br.Marshal(buf[:0])
 		addr, err := tbf.WriteBlockRefData(buf)
@@ -1086,16 +1258,49 @@ func ProcessSearchQuery(sq *storage.SearchQuery, fetchData bool, deadline search
 		putStorageSearch(sr)
 		return nil, fmt.Errorf("cannot finalize temporary file: %w", err)
 	}
+	qt.Printf("fetch unique series=%d, blocks=%d, samples=%d, blockRefsBytes=%d", len(m), blocksRead, samples, tbf.offset)
+
+	var remoteSeries map[string][]remoteRows
+	isPartial := false
+	if fetchData && len(*remoteSources) > 0 {
+		remoteSeries, isPartial, err = fetchRemoteSeries(qt, denyPartialResponse, sq, deadline)
+		if err != nil {
+			putTmpBlocksFile(tbf)
+			putStorageSearch(sr)
+			return nil, err
+		}
+		for metricName, rrs := range remoteSeries {
+			for _, rr := range rrs {
+				samples += len(rr.timestamps)
+			}
+			if _, ok := m[metricName]; !ok {
+				m[metricName] = nil
+				orderedMetricNames = append(orderedMetricNames, metricName)
+			}
+		}
+		if maxSamples > 0 && samples > maxSamples {
+			putTmpBlocksFile(tbf)
+			putStorageSearch(sr)
+			return nil, fmt.Errorf("cannot select more than -search.maxSamplesPerQuery=%d samples including samples from -search.remoteSource; possible solutions: to increase the -search.maxSamplesPerQuery; to reduce time range for the query; to use more specific label filters in order to select lower number of series", maxSamples)
+		}
+		if maxMetrics > 0 && len(orderedMetricNames) > maxMetrics {
+			putTmpBlocksFile(tbf)
+			putStorageSearch(sr)
+			return nil, fmt.Errorf("the number of matching unique timeseries including series from -search.remoteSource exceeds %d; either narrow down the search or increase -search.maxUniqueTimeseries", maxMetrics)
+		}
+	}
 
 	var rss Results
 	rss.tr = tr
 	rss.fetchData = fetchData
 	rss.deadline = deadline
+	rss.isPartial = isPartial
 	pts := make([]packedTimeseries, len(orderedMetricNames))
 	for i, metricName := range orderedMetricNames {
 		pts[i] = packedTimeseries{
 			metricName: metricName,
 			brs:        m[metricName],
+			remoteRows: remoteSeries[metricName],
 		}
 	}
 	rss.packedTimeseries = pts
@@ -1106,6 +1311,38 @@ func ProcessSearchQuery(sq *storage.SearchQuery, fetchData bool, deadline search
 
 var indexSearchDuration = metrics.NewHistogram(`vm_index_search_duration_seconds`)
 
+// tagFilterssToString returns human-readable representation of tagFilterss for query tracing.
+func tagFilterssToString(tagFilterss [][]storage.TagFilter) string {
+	a := make([]string, 0, len(tagFilterss))
+	for _, tfs := range tagFilterss {
+		a = append(a, tagFiltersToString(tfs))
+	}
+	return strings.Join(a, " or ")
+}
+
+// tagFiltersToString returns series selector for tfs.
+func tagFiltersToString(tfs []storage.TagFilter) string {
+	filters := make([]string, 0, len(tfs))
+	for i := range tfs {
+		tf := &tfs[i]
+		key := string(tf.Key)
+		if key == "" {
+			key = "__name__"
+		}
+		op := "="
+		switch {
+		case tf.IsNegative && tf.IsRegexp:
+			op = "!~"
+		case tf.IsNegative:
+			op = "!="
+		case tf.IsRegexp:
+			op = "=~"
+		}
+		filters = append(filters, fmt.Sprintf("%s%s%q", key, op, tf.Value))
+	}
+	return "{" + strings.Join(filters, ",") + "}"
+}
+
 type blockRef struct {
 	partRef storage.PartRef
 	addr    tmpBlockAddr
@@ -0,0 +1,196 @@
+package netstorage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/vmimport"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var remoteSources = flagutil.NewArray("search.remoteSource", "Optional URL of another VictoriaMetrics installation to fan out queries to. "+
+	"For example, http://victoria-metrics-eu:8428 or http://vmselect-eu:8481/select/0/prometheus . "+
+	"Data from remote sources is merged with the local data and samples with identical timestamps are deduplicated, "+
+	"so queries return a global view over all the installations. "+
+	"See https://docs.victoriametrics.com/#global-query-view")
+
+var remoteSourcesClient = &http.Client{}
+
+// remoteRows contains samples for a single time series fetched from a remote source.
+type remoteRows struct {
+	timestamps []int64
+	values     []float64
+}
+
+// fetchRemoteSeries fetches series matching sq from all the -search.remoteSource urls.
+//
+// It returns a map from marshaled metric name to the fetched rows.
+// If denyPartialResponse is false, then unavailable sources are skipped and true is returned as the second value.
+func fetchRemoteSeries(qt *querytracer.Tracer, denyPartialResponse bool, sq *storage.SearchQuery, deadline searchutils.Deadline) (map[string][]remoteRows, bool, error) {
+	qt = qt.NewChild("fetch series from %d remote sources", len(*remoteSources))
+	defer qt.Done()
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Unix(int64(deadline.Deadline()), 0))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	results := make([]map[string][]remoteRows, len(*remoteSources))
+	errs := make([]error, len(*remoteSources))
+	for i, sourceURL := range *remoteSources {
+		wg.Add(1)
+		go func(i int, sourceURL string) {
+			defer wg.Done()
+			results[i], errs[i] = fetchRemoteSeriesFromSource(ctx, sourceURL, sq)
+		}(i, sourceURL)
+	}
+	wg.Wait()
+
+	m := make(map[string][]remoteRows)
+	samples := 0
+	isPartial := false
+	for i, result := range results {
+		if errs[i] != nil {
+			remoteSourceErrors.Inc()
+			err := fmt.Errorf("cannot fetch data from -search.remoteSource=%q: %w", (*remoteSources)[i], errs[i])
+			if denyPartialResponse {
+				return nil, false, err
+			}
+			partialRemoteSourceResults.Inc()
+			logger.Warnf("returning partial response, since %s", err)
+			qt.Printf("skip unavailable remote source: %s", err)
+			isPartial = true
+			continue
+		}
+		for metricName, rrs := range result {
+			for _, rr := range rrs {
+				samples += len(rr.timestamps)
+			}
+			m[metricName] = append(m[metricName], rrs...)
+		}
+	}
+	qt.Printf("fetched %d series with %d samples", len(m), samples)
+	return m, isPartial, nil
+}
+
+func fetchRemoteSeriesFromSource(ctx context.Context, sourceURL string, sq *storage.SearchQuery) (map[string][]remoteRows, error) {
+	args := url.Values{}
+	for _, tfs := range sq.TagFilterss {
+		args.Add("match[]", tagFiltersToString(tfs))
+	}
+	args.Set("start", formatTimestamp(sq.MinTimestamp))
+	args.Set("end", formatTimestamp(sq.MaxTimestamp))
+	// reduce_mem_usage=1 makes the remote source to export only its local data,
+	// so it doesn't fan out the request to its own remote sources.
+	// This prevents from request loops when installations refer to each other.
+	args.Set("reduce_mem_usage", "1")
+	requestURL := strings.TrimSuffix(sourceURL, "/") + "/api/v1/export?" + args.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request to %q: %w", requestURL, err)
+	}
+	resp, err := remoteSourcesClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch %q: %w", requestURL, err)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response from %q: %w", requestURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code returned from %q: %d; expecting %d; response body: %q", requestURL, resp.StatusCode, http.StatusOK, data)
+	}
+	remoteSourceRequests.Inc()
+	return unmarshalRemoteSeries(bytesutil.ToUnsafeString(data)), nil
+}
+
+// unmarshalRemoteSeries unmarshals series in JSON line format returned from /api/v1/export.
+func unmarshalRemoteSeries(s string) map[string][]remoteRows {
+	var rows vmimport.Rows
+	rows.Unmarshal(s)
+	m := make(map[string][]remoteRows, len(rows.Rows))
+	var mn storage.MetricName
+	var metricNameBuf []byte
+	for i := range rows.Rows {
+		r := &rows.Rows[i]
+		mn.Reset()
+		for j := range r.Tags {
+			tag := &r.Tags[j]
+			if string(tag.Key) == "__name__" {
+				mn.MetricGroup = append(mn.MetricGroup[:0], tag.Value...)
+				continue
+			}
+			mn.AddTagBytes(tag.Key, tag.Value)
+		}
+		mn.SortTags()
+		metricNameBuf = mn.Marshal(metricNameBuf[:0])
+		// Copy timestamps and values, since they are re-used by rows.
+		rr := remoteRows{
+			timestamps: append([]int64{}, r.Timestamps...),
+			values:     append([]float64{}, r.Values...),
+		}
+		m[string(metricNameBuf)] = append(m[string(metricNameBuf)], rr)
+	}
+	return m
+}
+
+func formatTimestamp(timestamp int64) string {
+	return fmt.Sprintf("%.3f", float64(timestamp)/1e3)
+}
+
+// removeDuplicateTimestamps removes samples with duplicate timestamps from the sorted timestamps and values.
+//
+// The first sample is left for each timestamp.
+func removeDuplicateTimestamps(timestamps []int64, values []float64) ([]int64, []float64) {
+	if len(timestamps) < 2 {
+		return timestamps, values
+	}
+	dstTimestamps := timestamps[:1]
+	dstValues := values[:1]
+	for i := 1; i < len(timestamps); i++ {
+		if timestamps[i] == dstTimestamps[len(dstTimestamps)-1] {
+			continue
+		}
+		dstTimestamps = append(dstTimestamps, timestamps[i])
+		dstValues = append(dstValues, values[i])
+	}
+	return dstTimestamps, dstValues
+}
+
+// removeDuplicateHistogramTimestamps is like removeDuplicateTimestamps, but also removes histograms for the removed samples.
+func removeDuplicateHistogramTimestamps(timestamps []int64, values []float64, histograms [][]byte) ([]int64, []float64, [][]byte) {
+	if len(timestamps) < 2 {
+		return timestamps, values, histograms
+	}
+	dstTimestamps := timestamps[:1]
+	dstValues := values[:1]
+	dstHistograms := histograms[:1]
+	for i := 1; i < len(timestamps); i++ {
+		if timestamps[i] == dstTimestamps[len(dstTimestamps)-1] {
+			continue
+		}
+		dstTimestamps = append(dstTimestamps, timestamps[i])
+		dstValues = append(dstValues, values[i])
+		dstHistograms = append(dstHistograms, histograms[i])
+	}
+	return dstTimestamps, dstValues, dstHistograms
+}
+
+var (
+	remoteSourceRequests = metrics.NewCounter(`vm_remote_source_requests_total`)
+	remoteSourceErrors   = metrics.NewCounter(`vm_remote_source_errors_total`)
+
+	partialRemoteSourceResults = metrics.NewCounter(`vm_partial_results_total{name="remote_source"}`)
+)
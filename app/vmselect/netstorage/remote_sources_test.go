@@ -0,0 +1,121 @@
+package netstorage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestRemoveDuplicateTimestamps(t *testing.T) {
+	f := func(timestamps []int64, values []float64, timestampsExpected []int64, valuesExpected []float64) {
+		t.Helper()
+		timestamps, values = removeDuplicateTimestamps(timestamps, values)
+		if !reflect.DeepEqual(timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps; got %v; want %v", timestamps, timestampsExpected)
+		}
+		if !reflect.DeepEqual(values, valuesExpected) {
+			t.Fatalf("unexpected values; got %v; want %v", values, valuesExpected)
+		}
+	}
+	f(nil, nil, nil, nil)
+	f([]int64{1}, []float64{2}, []int64{1}, []float64{2})
+	f([]int64{1, 2, 3}, []float64{4, 5, 6}, []int64{1, 2, 3}, []float64{4, 5, 6})
+	f([]int64{1, 1, 2, 3, 3, 3}, []float64{4, 5, 6, 7, 8, 9}, []int64{1, 2, 3}, []float64{4, 6, 7})
+}
+
+func TestRemoveDuplicateHistogramTimestamps(t *testing.T) {
+	timestamps := []int64{1, 1, 2, 3, 3}
+	values := []float64{4, 5, 6, 7, 8}
+	histograms := [][]byte{[]byte("a"), nil, nil, []byte("b"), []byte("c")}
+	timestamps, values, histograms = removeDuplicateHistogramTimestamps(timestamps, values, histograms)
+	if !reflect.DeepEqual(timestamps, []int64{1, 2, 3}) {
+		t.Fatalf("unexpected timestamps; got %v", timestamps)
+	}
+	if !reflect.DeepEqual(values, []float64{4, 6, 7}) {
+		t.Fatalf("unexpected values; got %v", values)
+	}
+	if !reflect.DeepEqual(histograms, [][]byte{[]byte("a"), nil, []byte("b")}) {
+		t.Fatalf("unexpected histograms; got %q", histograms)
+	}
+}
+
+func TestFetchRemoteSeriesFromSource(t *testing.T) {
+	var requestURI string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+		_, _ = w.Write([]byte(`{"metric":{"__name__":"foo","job":"a","instance":"b"},"values":[1,2],"timestamps":[1000,2000]}
+{"metric":{"instance":"b","__name__":"foo","job":"a"},"values":[3],"timestamps":[3000]}
+{"metric":{"__name__":"bar"},"values":[4],"timestamps":[1000]}
+`))
+	}))
+	defer ts.Close()
+
+	sq := storage.NewSearchQuery(1000, 3500, [][]storage.TagFilter{
+		{
+			{
+				Value: []byte("foo"),
+			},
+			{
+				Key:        []byte("job"),
+				Value:      []byte("a|b"),
+				IsNegative: true,
+				IsRegexp:   true,
+			},
+		},
+	})
+	m, err := fetchRemoteSeriesFromSource(context.Background(), ts.URL+"/", sq)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	uriExpected := "/api/v1/export?end=3.500&match%5B%5D=%7B__name__%3D%22foo%22%2Cjob%21~%22a%7Cb%22%7D&reduce_mem_usage=1&start=1.000"
+	if requestURI != uriExpected {
+		t.Fatalf("unexpected request uri;\ngot\n%s\nwant\n%s", requestURI, uriExpected)
+	}
+
+	metricNameMarshaled := func(metricGroup string, tags ...string) string {
+		var mn storage.MetricName
+		mn.MetricGroup = []byte(metricGroup)
+		for i := 0; i < len(tags); i += 2 {
+			mn.AddTag(tags[i], tags[i+1])
+		}
+		mn.SortTags()
+		return string(mn.Marshal(nil))
+	}
+	mExpected := map[string][]remoteRows{
+		metricNameMarshaled("foo", "job", "a", "instance", "b"): {
+			{
+				timestamps: []int64{1000, 2000},
+				values:     []float64{1, 2},
+			},
+			{
+				timestamps: []int64{3000},
+				values:     []float64{3},
+			},
+		},
+		metricNameMarshaled("bar"): {
+			{
+				timestamps: []int64{1000},
+				values:     []float64{4},
+			},
+		},
+	}
+	if !reflect.DeepEqual(m, mExpected) {
+		t.Fatalf("unexpected series;\ngot\n%v\nwant\n%v", m, mExpected)
+	}
+}
+
+func TestFetchRemoteSeriesFromSourceError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	sq := storage.NewSearchQuery(1000, 2000, [][]storage.TagFilter{{{Value: []byte("foo")}}})
+	if _, err := fetchRemoteSeriesFromSource(context.Background(), ts.URL, sq); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
@@ -0,0 +1,49 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExpandWithExprsHandler(t *testing.T) {
+	f := func(args url.Values, statusCodeExpected int, responseExpected string) {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/expand-with-exprs?"+args.Encode(), nil)
+		w := httptest.NewRecorder()
+		if err := ExpandWithExprsHandler(w, r); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if w.Code != statusCodeExpected {
+			t.Fatalf("unexpected status code; got %d; want %d", w.Code, statusCodeExpected)
+		}
+		response := w.Body.String()
+		if !strings.Contains(response, responseExpected) {
+			t.Fatalf("response must contain %q; got\n%s", responseExpected, response)
+		}
+	}
+
+	// JSON format
+	f(url.Values{
+		"query":  {`WITH (f(x) = rate(x[5m]), commonFilters = {job="api"}) sum(f(http_requests_total{commonFilters}))`},
+		"format": {"json"},
+	}, http.StatusOK, `{"status":"success","expr":"sum(rate(http_requests_total{job=\"api\"}[5m]))"}`)
+	f(url.Values{
+		"query":  {`WITH (x = ) x`},
+		"format": {"json"},
+	}, http.StatusBadRequest, `{"status":"error","error":"`)
+	f(url.Values{
+		"format": {"json"},
+	}, http.StatusBadRequest, `{"status":"error","error":"missing`)
+
+	// HTML format
+	f(url.Values{
+		"query": {`WITH (x = {a="<b>"}) x`},
+	}, http.StatusOK, `readonly>{a=&quot;&lt;b&gt;&quot;}</textarea>`)
+	f(url.Values{
+		"query": {`WITH (x = ) x`},
+	}, http.StatusOK, `Cannot expand WITH expressions: `)
+	f(nil, http.StatusOK, `<textarea name="query" style="height: 15em; width: 90%"></textarea>`)
+}
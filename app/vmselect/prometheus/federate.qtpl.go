@@ -7,69 +7,79 @@ package prometheus
 //line app/vmselect/prometheus/federate.qtpl:1
 import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 )
 
 // Federate writes rs in /federate format.// See https://prometheus.io/docs/prometheus/latest/federation/
 
-//line app/vmselect/prometheus/federate.qtpl:9
+//line app/vmselect/prometheus/federate.qtpl:10
 import (
 	qtio422016 "io"
 
 	qt422016 "github.com/valyala/quicktemplate"
 )
 
-//line app/vmselect/prometheus/federate.qtpl:9
+//line app/vmselect/prometheus/federate.qtpl:10
 var (
 	_ = qtio422016.Copy
 	_ = qt422016.AcquireByteBuffer
 )
 
-//line app/vmselect/prometheus/federate.qtpl:9
-func StreamFederate(qw422016 *qt422016.Writer, rs *netstorage.Result) {
 //line app/vmselect/prometheus/federate.qtpl:10
+func StreamFederate(qw422016 *qt422016.Writer, rs *netstorage.Result) {
+//line app/vmselect/prometheus/federate.qtpl:11
 	if len(rs.Timestamps) == 0 || len(rs.Values) == 0 {
-//line app/vmselect/prometheus/federate.qtpl:10
+//line app/vmselect/prometheus/federate.qtpl:11
 		return
-//line app/vmselect/prometheus/federate.qtpl:10
-	}
 //line app/vmselect/prometheus/federate.qtpl:11
+	}
+//line app/vmselect/prometheus/federate.qtpl:12
+	lastValue := rs.Values[len(rs.Values)-1]
+
+//line app/vmselect/prometheus/federate.qtpl:13
+	if decimal.IsStaleNaN(lastValue) {
+//line app/vmselect/prometheus/federate.qtpl:17
+		return
+//line app/vmselect/prometheus/federate.qtpl:18
+	}
+//line app/vmselect/prometheus/federate.qtpl:19
 	streamprometheusMetricName(qw422016, &rs.MetricName)
-//line app/vmselect/prometheus/federate.qtpl:11
+//line app/vmselect/prometheus/federate.qtpl:19
 	qw422016.N().S(` `)
-//line app/vmselect/prometheus/federate.qtpl:12
-	qw422016.N().F(rs.Values[len(rs.Values)-1])
-//line app/vmselect/prometheus/federate.qtpl:12
+//line app/vmselect/prometheus/federate.qtpl:20
+	qw422016.N().F(lastValue)
+//line app/vmselect/prometheus/federate.qtpl:20
 	qw422016.N().S(` `)
-//line app/vmselect/prometheus/federate.qtpl:13
+//line app/vmselect/prometheus/federate.qtpl:21
 	qw422016.N().DL(rs.Timestamps[len(rs.Timestamps)-1])
-//line app/vmselect/prometheus/federate.qtpl:13
+//line app/vmselect/prometheus/federate.qtpl:21
 	qw422016.N().S(`
 `)
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 }
 
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 func WriteFederate(qq422016 qtio422016.Writer, rs *netstorage.Result) {
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 	StreamFederate(qw422016, rs)
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 }
 
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 func Federate(rs *netstorage.Result) string {
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 	WriteFederate(qb422016, rs)
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 	return qs422016
-//line app/vmselect/prometheus/federate.qtpl:14
+//line app/vmselect/prometheus/federate.qtpl:22
 }
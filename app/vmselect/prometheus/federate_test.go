@@ -0,0 +1,52 @@
+package prometheus
+
+import (
+	"math"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestFederate(t *testing.T) {
+	f := func(rs *netstorage.Result, expectedResult string) {
+		t.Helper()
+		result := Federate(rs)
+		if result != expectedResult {
+			t.Fatalf("unexpected result; got\n%s\nwant\n%s", result, expectedResult)
+		}
+	}
+
+	f(&netstorage.Result{}, ``)
+
+	f(&netstorage.Result{
+		MetricName: storage.MetricName{
+			MetricGroup: []byte("foo"),
+			Tags: []storage.Tag{{
+				Key:   []byte("a"),
+				Value: []byte("b"),
+			}},
+		},
+		Values:     []float64{1.23, 4.56},
+		Timestamps: []int64{1000, 2000},
+	}, `foo{a="b"} 4.56 2000`+"\n")
+
+	// The last value is NaN
+	f(&netstorage.Result{
+		MetricName: storage.MetricName{
+			MetricGroup: []byte("foo"),
+		},
+		Values:     []float64{1, math.NaN()},
+		Timestamps: []int64{1000, 2000},
+	}, `foo NaN 2000`+"\n")
+
+	// The last value is a staleness marker
+	f(&netstorage.Result{
+		MetricName: storage.MetricName{
+			MetricGroup: []byte("foo"),
+		},
+		Values:     []float64{1, decimal.StaleNaN},
+		Timestamps: []int64{1000, 2000},
+	}, ``)
+}
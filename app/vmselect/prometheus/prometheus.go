@@ -23,8 +23,10 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/metrics"
+	"github.com/VictoriaMetrics/metricsql"
 	"github.com/valyala/fastjson/fastfloat"
 	"github.com/valyala/quicktemplate"
 )
@@ -34,7 +36,7 @@ var (
 		"Too small value can result in incomplete last points for query results")
 	maxQueryLen = flagutil.NewBytes("search.maxQueryLen", 16*1024, "The maximum search query length in bytes")
 	maxLookback = flag.Duration("search.maxLookback", 0, "Synonym to -search.lookback-delta from Prometheus. "+
-		"The value is dynamically detected from interval between time series datapoints if not set. It can be overridden on per-query basis via max_lookback arg. "+
+		"The value is dynamically detected from interval between time series datapoints if not set. It can be overridden on per-query basis via max_lookback or lookback_delta arg. "+
 		"See also '-search.maxStalenessInterval' flag, which has the same meaining due to historical reasons")
 	maxStalenessInterval = flag.Duration("search.maxStalenessInterval", 0, "The maximum interval for staleness calculations. "+
 		"By default it is automatically calculated from the median interval between samples. This flag could be useful for tuning "+
@@ -79,7 +81,7 @@ func FederateHandler(startTime time.Time, w http.ResponseWriter, r *http.Request
 		return err
 	}
 	sq := storage.NewSearchQuery(start, end, tagFilterss)
-	rss, err := netstorage.ProcessSearchQuery(sq, true, deadline)
+	rss, err := netstorage.ProcessSearchQuery(nil, true, sq, true, nil, deadline)
 	if err != nil {
 		return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 	}
@@ -87,7 +89,7 @@ func FederateHandler(startTime time.Time, w http.ResponseWriter, r *http.Request
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	bw := bufferedwriter.Get(w)
 	defer bufferedwriter.Put(bw)
-	err = rss.RunParallel(func(rs *netstorage.Result, workerID uint) error {
+	err = rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
 		if err := bw.Error(); err != nil {
 			return err
 		}
@@ -120,7 +122,10 @@ func ExportCSVHandler(startTime time.Time, w http.ResponseWriter, r *http.Reques
 	if len(format) == 0 {
 		return fmt.Errorf("missing `format` arg; see https://docs.victoriametrics.com/#how-to-export-csv-data")
 	}
-	fieldNames := strings.Split(format, ",")
+	fieldNames, err := parseCSVFieldNames(format)
+	if err != nil {
+		return err
+	}
 	start, err := searchutils.GetTime(r, "start", 0)
 	if err != nil {
 		return err
@@ -180,6 +185,38 @@ func ExportCSVHandler(startTime time.Time, w http.ResponseWriter, r *http.Reques
 	return nil
 }
 
+// parseCSVFieldNames parses the `format` query arg for /api/v1/export/csv.
+//
+// `label:<labelName>` fields are converted to `<labelName>`.
+// See https://docs.victoriametrics.com/#how-to-export-csv-data
+func parseCSVFieldNames(format string) ([]string, error) {
+	fieldNames := strings.Split(format, ",")
+	for i, fieldName := range fieldNames {
+		switch {
+		case fieldName == "__value__", fieldName == "__timestamp__", fieldName == "__name__":
+		case strings.HasPrefix(fieldName, "__timestamp__:"):
+			timeFormat := fieldName[len("__timestamp__:"):]
+			switch timeFormat {
+			case "unix_s", "unix_ms", "unix_ns", "rfc3339":
+			default:
+				if !strings.HasPrefix(timeFormat, "custom:") {
+					return nil, fmt.Errorf("unsupported time format %q in `format` arg; supported values: unix_s, unix_ms, unix_ns, rfc3339, custom:<layout>", timeFormat)
+				}
+			}
+		case strings.HasPrefix(fieldName, "label:"):
+			labelName := fieldName[len("label:"):]
+			if labelName == "__value__" || strings.HasPrefix(labelName, "__timestamp__") {
+				return nil, fmt.Errorf("unsupported label name %q in `format` arg", labelName)
+			}
+			fieldNames[i] = labelName
+		}
+		if len(fieldNames[i]) == 0 {
+			return nil, fmt.Errorf("`format` arg cannot contain empty field names; got %q", format)
+		}
+	}
+	return fieldNames, nil
+}
+
 var exportCSVDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/export/csv"}`)
 
 // ExportNativeHandler exports data in native format from /api/v1/export/native.
@@ -279,6 +316,10 @@ func ExportHandler(startTime time.Time, w http.ResponseWriter, r *http.Request)
 	format := r.FormValue("format")
 	maxRowsPerLine := int(fastfloat.ParseInt64BestEffort(r.FormValue("max_rows_per_line")))
 	reduceMemUsage := searchutils.GetBool(r, "reduce_mem_usage")
+	nanosPrecision, err := getExportNanosPrecision(r, format)
+	if err != nil {
+		return err
+	}
 	deadline := searchutils.GetDeadlineForExport(r, startTime)
 	if start >= end {
 		end = start + defaultStep
@@ -287,7 +328,11 @@ func ExportHandler(startTime time.Time, w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return err
 	}
-	if err := exportHandler(w, matches, etf, start, end, format, maxRowsPerLine, reduceMemUsage, deadline); err != nil {
+	ql, err := searchutils.GetQueryLimits(r)
+	if err != nil {
+		return err
+	}
+	if err := exportHandler(w, matches, etf, ql, start, end, format, maxRowsPerLine, reduceMemUsage, nanosPrecision, deadline); err != nil {
 		return fmt.Errorf("error when exporting data for queries=%q on the time range (start=%d, end=%d): %w", matches, start, end, err)
 	}
 	return nil
@@ -295,7 +340,28 @@ func ExportHandler(startTime time.Time, w http.ResponseWriter, r *http.Request)
 
 var exportDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/export"}`)
 
-func exportHandler(w http.ResponseWriter, matches []string, etf []storage.TagFilter, start, end int64, format string, maxRowsPerLine int, reduceMemUsage bool, deadline searchutils.Deadline) error {
+// getExportNanosPrecision returns true if `precision=ns` query arg is passed to /api/v1/export.
+//
+// Nanosecond timestamps are supported only for JSON line format.
+func getExportNanosPrecision(r *http.Request, format string) (bool, error) {
+	precision := r.FormValue("precision")
+	switch precision {
+	case "", "ms":
+		return false, nil
+	case "ns":
+		if format == "prometheus" || format == "promapi" {
+			return false, fmt.Errorf("`precision=ns` isn't supported for `format=%s`", format)
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported `precision` query arg: %q; supported values: ms, ns", precision)
+	}
+}
+
+// exportHandler exports the data for the given matches on the [start...end] time range.
+//
+// Timestamps are exported in nanoseconds if nanosPrecision is set. Data is exported block by block then as with reduceMemUsage.
+func exportHandler(w http.ResponseWriter, matches []string, etf []storage.TagFilter, ql *searchutils.QueryLimits, start, end int64, format string, maxRowsPerLine int, reduceMemUsage, nanosPrecision bool, deadline searchutils.Deadline) error {
 	writeResponseFunc := WriteExportStdResponse
 	writeLineFunc := func(xb *exportBlock, resultsCh chan<- *quicktemplate.ByteBuffer) {
 		bb := quicktemplate.AcquireByteBuffer()
@@ -361,13 +427,13 @@ func exportHandler(w http.ResponseWriter, matches []string, etf []storage.TagFil
 
 	resultsCh := make(chan *quicktemplate.ByteBuffer, cgroup.AvailableCPUs())
 	doneCh := make(chan error)
-	if !reduceMemUsage {
-		rss, err := netstorage.ProcessSearchQuery(sq, true, deadline)
+	if !reduceMemUsage && !nanosPrecision {
+		rss, err := netstorage.ProcessSearchQuery(nil, true, sq, true, ql, deadline)
 		if err != nil {
 			return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 		}
 		go func() {
-			err := rss.RunParallel(func(rs *netstorage.Result, workerID uint) error {
+			err := rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
 				if err := bw.Error(); err != nil {
 					return err
 				}
@@ -394,7 +460,11 @@ func exportHandler(w http.ResponseWriter, matches []string, etf []storage.TagFil
 				}
 				xb := exportBlockPool.Get().(*exportBlock)
 				xb.mn = mn
-				xb.timestamps, xb.values = b.AppendRowsWithTimeRangeFilter(xb.timestamps[:0], xb.values[:0], tr)
+				if nanosPrecision {
+					xb.timestamps, xb.values = b.AppendRowsWithTimeRangeFilterNanos(xb.timestamps[:0], xb.values[:0], tr)
+				} else {
+					xb.timestamps, xb.values = b.AppendRowsWithTimeRangeFilter(xb.timestamps[:0], xb.values[:0], tr)
+				}
 				if len(xb.timestamps) > 0 {
 					writeLineFunc(xb, resultsCh)
 				}
@@ -447,18 +517,36 @@ func DeleteHandler(startTime time.Time, r *http.Request) error {
 	if err := r.ParseForm(); err != nil {
 		return fmt.Errorf("cannot parse request form values: %w", err)
 	}
-	if r.FormValue("start") != "" || r.FormValue("end") != "" {
-		return fmt.Errorf("start and end aren't supported. Remove these args from the query in order to delete all the matching metrics")
-	}
 	tagFilterss, err := getTagFilterssFromRequest(r)
 	if err != nil {
 		return err
 	}
 	ct := startTime.UnixNano() / 1e6
-	sq := storage.NewSearchQuery(0, ct, tagFilterss)
-	deletedCount, err := netstorage.DeleteSeries(sq, deadline)
-	if err != nil {
-		return fmt.Errorf("cannot delete time series: %w", err)
+	var deletedCount int
+	if r.FormValue("start") != "" || r.FormValue("end") != "" {
+		// Delete only samples on the given time range.
+		start, err := searchutils.GetTime(r, "start", 0)
+		if err != nil {
+			return err
+		}
+		end, err := searchutils.GetTime(r, "end", ct)
+		if err != nil {
+			return err
+		}
+		if start > end {
+			return fmt.Errorf("start=%d cannot exceed end=%d", start, end)
+		}
+		sq := storage.NewSearchQuery(start, end, tagFilterss)
+		deletedCount, err = netstorage.DeleteSeriesOnTimeRange(sq, deadline)
+		if err != nil {
+			return fmt.Errorf("cannot delete samples on the time range [%d..%d]: %w", start, end, err)
+		}
+	} else {
+		sq := storage.NewSearchQuery(0, ct, tagFilterss)
+		deletedCount, err = netstorage.DeleteSeries(sq, deadline)
+		if err != nil {
+			return fmt.Errorf("cannot delete time series: %w", err)
+		}
 	}
 	if deletedCount > 0 {
 		promql.ResetRollupResultCache()
@@ -584,12 +672,12 @@ func labelValuesWithMatches(labelName string, matches []string, etf []storage.Ta
 			m[string(labelValue)] = struct{}{}
 		}
 	} else {
-		rss, err := netstorage.ProcessSearchQuery(sq, false, deadline)
+		rss, err := netstorage.ProcessSearchQuery(nil, true, sq, false, nil, deadline)
 		if err != nil {
 			return nil, fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 		}
 		var mLock sync.Mutex
-		err = rss.RunParallel(func(rs *netstorage.Result, workerID uint) error {
+		err = rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
 			labelValue := rs.MetricName.GetTagValue(labelName)
 			if len(labelValue) == 0 {
 				return nil
@@ -613,6 +701,79 @@ func labelValuesWithMatches(labelName string, matches []string, etf []storage.Ta
 
 var labelValuesDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/label/{}/values"}`)
 
+// AutocompleteHandler processes /api/v1/autocomplete request.
+//
+// It returns up to `limit` values for the `label` starting with the given `prefix`.
+// Values are searched in the inverted index, so the request is fast even on databases with big number of time series.
+// Optional `match[]` args narrow down the returned values to time series matching the given selectors.
+func AutocompleteHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer autocompleteDuration.UpdateDuration(startTime)
+
+	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("cannot parse form values: %w", err)
+	}
+	labelName := r.FormValue("label")
+	if len(labelName) == 0 {
+		labelName = "__name__"
+	}
+	prefix := r.FormValue("prefix")
+	limit := 100
+	limitStr := r.FormValue("limit")
+	if len(limitStr) > 0 {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return fmt.Errorf("cannot parse `limit` arg %q: %w", limitStr, err)
+		}
+		if n <= 0 {
+			n = 1
+		}
+		if n > 1000 {
+			n = 1000
+		}
+		limit = n
+	}
+	ct := startTime.UnixNano() / 1e6
+	end, err := searchutils.GetTime(r, "end", ct)
+	if err != nil {
+		return err
+	}
+	start, err := searchutils.GetTime(r, "start", end-defaultStep)
+	if err != nil {
+		return err
+	}
+	tr := storage.TimeRange{
+		MinTimestamp: start,
+		MaxTimestamp: end,
+	}
+	etf, err := searchutils.GetEnforcedTagFiltersFromRequest(r)
+	if err != nil {
+		return err
+	}
+	tagFilterss, err := getTagFilterssFromMatches(getMatchesFromRequest(r))
+	if err != nil {
+		return err
+	}
+	if len(etf) > 0 {
+		tagFilterss = addEnforcedFiltersToTagFilterss(tagFilterss, etf)
+	}
+	labelValues, err := netstorage.GetLabelValuesWithPrefix(labelName, prefix, tagFilterss, tr, limit, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot obtain autocomplete values for label=%q, prefix=%q: %w", labelName, prefix, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteLabelValuesResponse(bw, labelValues)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot flush autocomplete values to remote client: %w", err)
+	}
+	return nil
+}
+
+var autocompleteDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/autocomplete"}`)
+
 // LabelsCountHandler processes /api/v1/labels/count request.
 func LabelsCountHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
 	defer labelsCountDuration.UpdateDuration(startTime)
@@ -678,16 +839,18 @@ func TSDBStatusHandler(startTime time.Time, w http.ResponseWriter, r *http.Reque
 		}
 		topN = n
 	}
+	focusLabel := r.FormValue("focusLabel")
+	diskUsage := searchutils.GetBool(r, "diskUsage")
 	var status *storage.TSDBStatus
 	if len(matches) == 0 && len(etf) == 0 {
-		status, err = netstorage.GetTSDBStatusForDate(deadline, date, topN)
+		status, err = netstorage.GetTSDBStatusForDate(deadline, date, focusLabel, topN, diskUsage)
 		if err != nil {
-			return fmt.Errorf(`cannot obtain tsdb status for date=%d, topN=%d: %w`, date, topN, err)
+			return fmt.Errorf(`cannot obtain tsdb status for date=%d, focusLabel=%q, topN=%d: %w`, date, focusLabel, topN, err)
 		}
 	} else {
-		status, err = tsdbStatusWithMatches(matches, etf, date, topN, deadline)
+		status, err = tsdbStatusWithMatches(matches, etf, date, focusLabel, topN, diskUsage, deadline)
 		if err != nil {
-			return fmt.Errorf("cannot obtain tsdb status with matches for date=%d, topN=%d: %w", date, topN, err)
+			return fmt.Errorf("cannot obtain tsdb status with matches for date=%d, focusLabel=%q, topN=%d: %w", date, focusLabel, topN, err)
 		}
 	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -700,7 +863,7 @@ func TSDBStatusHandler(startTime time.Time, w http.ResponseWriter, r *http.Reque
 	return nil
 }
 
-func tsdbStatusWithMatches(matches []string, etf []storage.TagFilter, date uint64, topN int, deadline searchutils.Deadline) (*storage.TSDBStatus, error) {
+func tsdbStatusWithMatches(matches []string, etf []storage.TagFilter, date uint64, focusLabel string, topN int, diskUsage bool, deadline searchutils.Deadline) (*storage.TSDBStatus, error) {
 	tagFilterss, err := getTagFilterssFromMatches(matches)
 	if err != nil {
 		return nil, err
@@ -712,7 +875,7 @@ func tsdbStatusWithMatches(matches []string, etf []storage.TagFilter, date uint6
 	start := int64(date*secsPerDay) * 1000
 	end := int64(date*secsPerDay+secsPerDay) * 1000
 	sq := storage.NewSearchQuery(start, end, tagFilterss)
-	status, err := netstorage.GetTSDBStatusWithFilters(deadline, sq, topN)
+	status, err := netstorage.GetTSDBStatusWithFilters(deadline, sq, focusLabel, topN, diskUsage)
 	if err != nil {
 		return nil, err
 	}
@@ -823,12 +986,12 @@ func labelsWithMatches(matches []string, etf []storage.TagFilter, start, end int
 			m["__name__"] = struct{}{}
 		}
 	} else {
-		rss, err := netstorage.ProcessSearchQuery(sq, false, deadline)
+		rss, err := netstorage.ProcessSearchQuery(nil, true, sq, false, nil, deadline)
 		if err != nil {
 			return nil, fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 		}
 		var mLock sync.Mutex
-		err = rss.RunParallel(func(rs *netstorage.Result, workerID uint) error {
+		err = rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
 			mLock.Lock()
 			for _, tag := range rs.MetricName.Tags {
 				m[string(tag.Key)] = struct{}{}
@@ -872,6 +1035,81 @@ func SeriesCountHandler(startTime time.Time, w http.ResponseWriter, r *http.Requ
 
 var seriesCountDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/series/count"}`)
 
+// MetadataHandler processes /api/v1/metadata request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
+func MetadataHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer metadataDuration.UpdateDuration(startTime)
+	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+
+	limit, err := getIntArg(r, "limit")
+	if err != nil {
+		return err
+	}
+	limitPerMetric, err := getIntArg(r, "limit_per_metric")
+	if err != nil {
+		return err
+	}
+	rows, err := netstorage.SearchMetricsMetadata(r.FormValue("metric"), limit, limitPerMetric, deadline)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteMetadataResponse(bw, rows)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send metadata response to remote client: %w", err)
+	}
+	return nil
+}
+
+var metadataDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/metadata"}`)
+
+// TargetsMetadataHandler processes /api/v1/targets/metadata request.
+//
+// VictoriaMetrics doesn't track the targets metadata originates from, so the returned entries have empty `target`.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-target-metadata
+func TargetsMetadataHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer targetsMetadataDuration.UpdateDuration(startTime)
+	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+
+	limit, err := getIntArg(r, "limit")
+	if err != nil {
+		return err
+	}
+	rows, err := netstorage.SearchMetricsMetadata(r.FormValue("metric"), 0, 0, deadline)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteTargetsMetadataResponse(bw, rows)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send targets metadata response to remote client: %w", err)
+	}
+	return nil
+}
+
+var targetsMetadataDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/targets/metadata"}`)
+
+func getIntArg(r *http.Request, argKey string) (int, error) {
+	s := r.FormValue(argKey)
+	if len(s) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse `%s` arg %q: %w", argKey, s, err)
+	}
+	return n, nil
+}
+
 // SeriesHandler processes /api/v1/series request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#finding-series-by-label-matchers
@@ -931,7 +1169,7 @@ func SeriesHandler(startTime time.Time, w http.ResponseWriter, r *http.Request)
 		seriesDuration.UpdateDuration(startTime)
 		return nil
 	}
-	rss, err := netstorage.ProcessSearchQuery(sq, false, deadline)
+	rss, err := netstorage.ProcessSearchQuery(nil, true, sq, false, nil, deadline)
 	if err != nil {
 		return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 	}
@@ -942,7 +1180,7 @@ func SeriesHandler(startTime time.Time, w http.ResponseWriter, r *http.Request)
 	resultsCh := make(chan *quicktemplate.ByteBuffer)
 	doneCh := make(chan error)
 	go func() {
-		err := rss.RunParallel(func(rs *netstorage.Result, workerID uint) error {
+		err := rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
 			if err := bw.Error(); err != nil {
 				return err
 			}
@@ -968,10 +1206,63 @@ func SeriesHandler(startTime time.Time, w http.ResponseWriter, r *http.Request)
 
 var seriesDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/series"}`)
 
+// QueryExemplarsHandler processes /api/v1/query_exemplars request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-exemplars
+func QueryExemplarsHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer queryExemplarsDuration.UpdateDuration(startTime)
+
+	ct := startTime.UnixNano() / 1e6
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("cannot parse form values: %w", err)
+	}
+	query := r.FormValue("query")
+	if len(query) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	end, err := searchutils.GetTime(r, "end", ct)
+	if err != nil {
+		return err
+	}
+	start, err := searchutils.GetTime(r, "start", end-defaultStep)
+	if err != nil {
+		return err
+	}
+	if start > end {
+		return fmt.Errorf("`start` cannot exceed `end`; got start=%d, end=%d", start, end)
+	}
+	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+	etf, err := searchutils.GetEnforcedTagFiltersFromRequest(r)
+	if err != nil {
+		return err
+	}
+	tagFilterss, err := promql.ParseMetricSelectors(query)
+	if err != nil {
+		return fmt.Errorf("cannot parse query %q: %w", query, err)
+	}
+	tagFilterss = addEnforcedFiltersToTagFilterss(tagFilterss, etf)
+
+	sq := storage.NewSearchQuery(start, end, tagFilterss)
+	mes, err := netstorage.SearchExemplars(sq, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot fetch exemplars for %q: %w", sq, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteExemplarsResponse(bw, mes)
+	return bw.Flush()
+}
+
+var queryExemplarsDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/query_exemplars"}`)
+
 // QueryHandler processes /api/v1/query request.
 //
+// qs is updated with query execution stats. It may be nil.
+//
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#instant-queries
-func QueryHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+func QueryHandler(qs *promql.QueryStats, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
 	defer queryDuration.UpdateDuration(startTime)
 
 	ct := startTime.UnixNano() / 1e6
@@ -995,6 +1286,7 @@ func QueryHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) e
 		step = defaultStep
 	}
 	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+	qt := querytracer.New(searchutils.GetBool(r, "trace"), "/api/v1/query: query=%s, time=%d, step=%d", query, start, step)
 
 	if len(query) > maxQueryLen.N {
 		return fmt.Errorf("too long query; got %d bytes; mustn't exceed `-search.maxQueryLen=%d` bytes", len(query), maxQueryLen.N)
@@ -1003,6 +1295,10 @@ func QueryHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) e
 	if err != nil {
 		return err
 	}
+	ql, err := searchutils.GetQueryLimits(r)
+	if err != nil {
+		return err
+	}
 	if childQuery, windowExpr, offsetExpr := promql.IsMetricSelectorWithRollup(query); childQuery != "" {
 		window := windowExpr.Duration(step)
 		offset := offsetExpr.Duration(step)
@@ -1014,7 +1310,7 @@ func QueryHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) e
 		if end < start {
 			end = start
 		}
-		if err := exportHandler(w, []string{childQuery}, etf, start, end, "promapi", 0, false, deadline); err != nil {
+		if err := exportHandler(w, []string{childQuery}, etf, ql, start, end, "promapi", 0, false, false, deadline); err != nil {
 			return fmt.Errorf("error when exporting data for query=%q on the time range (start=%d, end=%d): %w", childQuery, start, end, err)
 		}
 		queryDuration.UpdateDuration(startTime)
@@ -1030,7 +1326,7 @@ func QueryHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) e
 		start -= offset
 		end := start
 		start = end - window
-		if err := queryRangeHandler(startTime, w, childQuery, start, end, step, r, ct, etf); err != nil {
+		if err := queryRangeHandler(qt, qs, startTime, w, childQuery, start, end, step, r, ct, etf); err != nil {
 			return fmt.Errorf("error when executing query=%q on the time range (start=%d, end=%d, step=%d): %w", childQuery, start, end, step, err)
 		}
 		queryDuration.UpdateDuration(startTime)
@@ -1056,8 +1352,12 @@ func QueryHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) e
 		LookbackDelta:      lookbackDelta,
 		RoundDigits:        getRoundDigits(r),
 		EnforcedTagFilters: etf,
+		QueryLimits:        ql,
+		QueryStats:         qs,
+
+		DenyPartialResponse: searchutils.GetDenyPartialResponse(r),
 	}
-	result, err := promql.Exec(&ec, query, true)
+	result, err := promql.Exec(qt, &ec, query, true)
 	if err != nil {
 		return fmt.Errorf("error when executing query=%q for (time=%d, step=%d): %w", query, start, step, err)
 	}
@@ -1073,7 +1373,7 @@ func QueryHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) e
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	bw := bufferedwriter.Get(w)
 	defer bufferedwriter.Put(bw)
-	WriteQueryResponse(bw, result)
+	WriteQueryResponse(bw, ec.IsPartialResponse(), result, qt)
 	if err := bw.Flush(); err != nil {
 		return fmt.Errorf("cannot flush query response to remote client: %w", err)
 	}
@@ -1084,8 +1384,10 @@ var queryDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v
 
 // QueryRangeHandler processes /api/v1/query_range request.
 //
+// qs is updated with query execution stats. It may be nil.
+//
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#range-queries
-func QueryRangeHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+func QueryRangeHandler(qs *promql.QueryStats, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
 	defer queryRangeDuration.UpdateDuration(startTime)
 
 	ct := startTime.UnixNano() / 1e6
@@ -1109,19 +1411,24 @@ func QueryRangeHandler(startTime time.Time, w http.ResponseWriter, r *http.Reque
 	if err != nil {
 		return err
 	}
-	if err := queryRangeHandler(startTime, w, query, start, end, step, r, ct, etf); err != nil {
+	qt := querytracer.New(searchutils.GetBool(r, "trace"), "/api/v1/query_range: query=%s, start=%d, end=%d, step=%d", query, start, end, step)
+	if err := queryRangeHandler(qt, qs, startTime, w, query, start, end, step, r, ct, etf); err != nil {
 		return fmt.Errorf("error when executing query=%q on the time range (start=%d, end=%d, step=%d): %w", query, start, end, step, err)
 	}
 	return nil
 }
 
-func queryRangeHandler(startTime time.Time, w http.ResponseWriter, query string, start, end, step int64, r *http.Request, ct int64, etf []storage.TagFilter) error {
+func queryRangeHandler(qt *querytracer.Tracer, qs *promql.QueryStats, startTime time.Time, w http.ResponseWriter, query string, start, end, step int64, r *http.Request, ct int64, etf []storage.TagFilter) error {
 	deadline := searchutils.GetDeadlineForQuery(r, startTime)
 	mayCache := !searchutils.GetBool(r, "nocache")
 	lookbackDelta, err := getMaxLookback(r)
 	if err != nil {
 		return err
 	}
+	ql, err := searchutils.GetQueryLimits(r)
+	if err != nil {
+		return err
+	}
 
 	// Validate input args.
 	if len(query) > maxQueryLen.N {
@@ -1147,8 +1454,12 @@ func queryRangeHandler(startTime time.Time, w http.ResponseWriter, query string,
 		LookbackDelta:      lookbackDelta,
 		RoundDigits:        getRoundDigits(r),
 		EnforcedTagFilters: etf,
+		QueryLimits:        ql,
+		QueryStats:         qs,
+
+		DenyPartialResponse: searchutils.GetDenyPartialResponse(r),
 	}
-	result, err := promql.Exec(&ec, query, false)
+	result, err := promql.Exec(qt, &ec, query, false)
 	if err != nil {
 		return fmt.Errorf("cannot execute query: %w", err)
 	}
@@ -1162,11 +1473,12 @@ func queryRangeHandler(startTime time.Time, w http.ResponseWriter, query string,
 	// Remove NaN values as Prometheus does.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/153
 	result = removeEmptyValuesAndTimeseries(result)
+	qt.Printf("remove empty series and NaN values")
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	bw := bufferedwriter.Get(w)
 	defer bufferedwriter.Put(bw)
-	WriteQueryRangeResponse(bw, result)
+	WriteQueryRangeResponse(bw, ec.IsPartialResponse(), result, qt)
 	if err := bw.Flush(); err != nil {
 		return fmt.Errorf("cannot send query range response to remote client: %w", err)
 	}
@@ -1251,6 +1563,12 @@ func getMaxLookback(r *http.Request) (int64, error) {
 	if d == 0 {
 		d = maxStalenessInterval.Milliseconds()
 	}
+	// Prometheus accepts `lookback_delta` query arg for overriding -query.lookback-delta on per-query basis.
+	// Support it for compatibility. The `max_lookback` arg has priority over `lookback_delta`.
+	d, err := searchutils.GetDuration(r, "lookback_delta", d)
+	if err != nil {
+		return 0, err
+	}
 	return searchutils.GetDuration(r, "max_lookback", d)
 }
 
@@ -1354,3 +1672,37 @@ func QueryStatsHandler(startTime time.Time, w http.ResponseWriter, r *http.Reque
 }
 
 var queryStatsDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/status/top_queries"}`)
+
+// ExpandWithExprsHandler processes /expand-with-exprs request.
+//
+// It expands WITH templates in the `query` arg and returns either HTML page or JSON response if `format=json` arg is set.
+func ExpandWithExprsHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("cannot parse form values: %w", err)
+	}
+	query := r.FormValue("query")
+	var expanded string
+	var err error
+	if len(query) > 0 {
+		expanded, err = metricsql.ExpandWithExprs(query)
+	}
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	if r.FormValue("format") == "json" {
+		if len(query) == 0 {
+			err = fmt.Errorf("missing `query` arg")
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		WriteExpandWithExprsJSONResponse(bw, expanded, err)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		WriteExpandWithExprsResponse(bw, query, expanded, err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send expanded query to client: %w", err)
+	}
+	return nil
+}
@@ -1,11 +1,17 @@
 package prometheus
 
 import (
+	"encoding/json"
 	"math"
+	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
 
@@ -231,3 +237,190 @@ func Test_addEnforcedFiltersToTagFilterss(t *testing.T) {
 			{tfFromKV("l2", "v2"), tfFromKV("ext-l1", "v2")},
 		})
 }
+
+func TestGetMaxLookback(t *testing.T) {
+	f := func(form url.Values, dExpected int64) {
+		t.Helper()
+		r := &http.Request{
+			Form: form,
+		}
+		d, err := getMaxLookback(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if d != dExpected {
+			t.Fatalf("unexpected max lookback; got %d; want %d", d, dExpected)
+		}
+	}
+	f(url.Values{}, 0)
+	f(url.Values{"max_lookback": {"1h"}}, 3600*1000)
+	f(url.Values{"lookback_delta": {"30s"}}, 30*1000)
+	f(url.Values{"lookback_delta": {"10"}}, 10*1000)
+
+	// max_lookback has priority over lookback_delta
+	f(url.Values{"lookback_delta": {"30s"}, "max_lookback": {"2m"}}, 2*60*1000)
+
+	// invalid value
+	r := &http.Request{
+		Form: url.Values{"lookback_delta": {"foobar"}},
+	}
+	if _, err := getMaxLookback(r); err == nil {
+		t.Fatalf("expecting non-nil error for invalid lookback_delta")
+	}
+}
+
+func TestQueryResponseWithTrace(t *testing.T) {
+	rs := []netstorage.Result{
+		{
+			MetricName: storage.MetricName{
+				MetricGroup: []byte("foo"),
+			},
+			Values:     []float64{1, 2},
+			Timestamps: []int64{1000, 2000},
+		},
+	}
+	f := func(traceEnabled bool, generate func(qt *querytracer.Tracer) string) {
+		t.Helper()
+		qt := querytracer.New(traceEnabled, "test")
+		response := generate(qt)
+		var v struct {
+			Status string `json:"status"`
+			Trace  *struct {
+				Message  string `json:"message"`
+				Children []struct {
+					Message string `json:"message"`
+				} `json:"children"`
+			} `json:"trace"`
+		}
+		if err := json.Unmarshal([]byte(response), &v); err != nil {
+			t.Fatalf("cannot unmarshal response %s: %s", response, err)
+		}
+		if v.Status != "success" {
+			t.Fatalf("unexpected status in response %s", response)
+		}
+		if !traceEnabled {
+			if v.Trace != nil {
+				t.Fatalf("unexpected trace in response %s", response)
+			}
+			return
+		}
+		if v.Trace == nil || v.Trace.Message != "test" || len(v.Trace.Children) != 1 {
+			t.Fatalf("unexpected trace in response %s", response)
+		}
+		if !strings.Contains(v.Trace.Children[0].Message, "response for series=1") {
+			t.Fatalf("unexpected trace message in response %s", response)
+		}
+	}
+	for _, traceEnabled := range []bool{false, true} {
+		f(traceEnabled, func(qt *querytracer.Tracer) string {
+			return QueryResponse(false, rs, qt)
+		})
+		f(traceEnabled, func(qt *querytracer.Tracer) string {
+			return QueryRangeResponse(false, rs, qt)
+		})
+	}
+}
+
+func TestQueryResponseIsPartial(t *testing.T) {
+	f := func(response string, isPartialExpected bool) {
+		t.Helper()
+		var v struct {
+			Status    string `json:"status"`
+			IsPartial *bool  `json:"isPartial"`
+		}
+		if err := json.Unmarshal([]byte(response), &v); err != nil {
+			t.Fatalf("cannot unmarshal response %s: %s", response, err)
+		}
+		if v.Status != "success" {
+			t.Fatalf("unexpected status in response %s", response)
+		}
+		if v.IsPartial == nil || *v.IsPartial != isPartialExpected {
+			t.Fatalf("unexpected isPartial in response %s; want %v", response, isPartialExpected)
+		}
+	}
+	for _, isPartial := range []bool{false, true} {
+		f(QueryResponse(isPartial, nil, nil), isPartial)
+		f(QueryRangeResponse(isPartial, nil, nil), isPartial)
+	}
+}
+
+func TestParseCSVFieldNamesSuccess(t *testing.T) {
+	f := func(format string, fieldNamesExpected []string) {
+		t.Helper()
+		fieldNames, err := parseCSVFieldNames(format)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", format, err)
+		}
+		if !reflect.DeepEqual(fieldNames, fieldNamesExpected) {
+			t.Fatalf("unexpected field names for %q; got %q; want %q", format, fieldNames, fieldNamesExpected)
+		}
+	}
+	f("__name__", []string{"__name__"})
+	f("__name__,label:instance,__value__,__timestamp__:rfc3339", []string{"__name__", "instance", "__value__", "__timestamp__:rfc3339"})
+	f("job,__timestamp__:unix_s,__timestamp__:custom:2006-01-02", []string{"job", "__timestamp__:unix_s", "__timestamp__:custom:2006-01-02"})
+	f("label:foo:bar,__timestamp__", []string{"foo:bar", "__timestamp__"})
+}
+
+func TestParseCSVFieldNamesFailure(t *testing.T) {
+	f := func(format string) {
+		t.Helper()
+		fieldNames, err := parseCSVFieldNames(format)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q; got %q", format, fieldNames)
+		}
+	}
+	f("__name__,")
+	f("label:")
+	f("label:__value__")
+	f("__timestamp__:foobar")
+	f("__value__,,__name__")
+}
+
+func TestMetadataResponse(t *testing.T) {
+	f := func(rows []prommetadata.Row, resultExpected string) {
+		t.Helper()
+		result := MetadataResponse(rows)
+		if result != resultExpected {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+	f(nil, `{"status":"success","data":{}}`)
+	f([]prommetadata.Row{
+		{
+			Metric: "bar",
+			Type:   "gauge",
+		},
+		{
+			Metric: "foo",
+			Type:   "counter",
+			Help:   "a \"quoted\" help",
+		},
+		{
+			Metric: "foo",
+			Type:   "counter",
+			Help:   "other help",
+			Unit:   "seconds",
+		},
+	}, `{"status":"success","data":{"bar":[{"type":"gauge","help":"","unit":""}],"foo":[{"type":"counter","help":"a \"quoted\" help","unit":""},{"type":"counter","help":"other help","unit":"seconds"}]}}`)
+}
+
+func TestTargetsMetadataResponse(t *testing.T) {
+	f := func(rows []prommetadata.Row, resultExpected string) {
+		t.Helper()
+		result := TargetsMetadataResponse(rows)
+		if result != resultExpected {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+	f(nil, `{"status":"success","data":[]}`)
+	f([]prommetadata.Row{
+		{
+			Metric: "bar",
+			Type:   "gauge",
+		},
+		{
+			Metric: "foo",
+			Help:   "foo help",
+		},
+	}, `{"status":"success","data":[{"target":{},"metric":"bar","type":"gauge","help":"","unit":""},{"target":{},"metric":"foo","type":"","help":"foo help","unit":""}]}`)
+}
@@ -7,112 +7,136 @@ package prometheus
 //line app/vmselect/prometheus/query_range_response.qtpl:1
 import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 )
 
 // QueryRangeResponse generates response for /api/v1/query_range.See https://prometheus.io/docs/prometheus/latest/querying/api/#range-queries
 
-//line app/vmselect/prometheus/query_range_response.qtpl:8
+//line app/vmselect/prometheus/query_range_response.qtpl:9
 import (
 	qtio422016 "io"
 
 	qt422016 "github.com/valyala/quicktemplate"
 )
 
-//line app/vmselect/prometheus/query_range_response.qtpl:8
+//line app/vmselect/prometheus/query_range_response.qtpl:9
 var (
 	_ = qtio422016.Copy
 	_ = qt422016.AcquireByteBuffer
 )
 
-//line app/vmselect/prometheus/query_range_response.qtpl:8
-func StreamQueryRangeResponse(qw422016 *qt422016.Writer, rs []netstorage.Result) {
-//line app/vmselect/prometheus/query_range_response.qtpl:8
-	qw422016.N().S(`{"status":"success","data":{"resultType":"matrix","result":[`)
-//line app/vmselect/prometheus/query_range_response.qtpl:14
+//line app/vmselect/prometheus/query_range_response.qtpl:9
+func StreamQueryRangeResponse(qw422016 *qt422016.Writer, isPartial bool, rs []netstorage.Result, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/query_range_response.qtpl:10
+	seriesCount := len(rs)
+
+//line app/vmselect/prometheus/query_range_response.qtpl:10
+	qw422016.N().S(`{"status":"success","isPartial":`)
+//line app/vmselect/prometheus/query_range_response.qtpl:13
+	if isPartial {
+//line app/vmselect/prometheus/query_range_response.qtpl:13
+		qw422016.N().S(`true`)
+//line app/vmselect/prometheus/query_range_response.qtpl:13
+	} else {
+//line app/vmselect/prometheus/query_range_response.qtpl:13
+		qw422016.N().S(`false`)
+//line app/vmselect/prometheus/query_range_response.qtpl:13
+	}
+//line app/vmselect/prometheus/query_range_response.qtpl:13
+	qw422016.N().S(`,"data":{"resultType":"matrix","result":[`)
+//line app/vmselect/prometheus/query_range_response.qtpl:17
 	if len(rs) > 0 {
-//line app/vmselect/prometheus/query_range_response.qtpl:15
+//line app/vmselect/prometheus/query_range_response.qtpl:18
 		streamqueryRangeLine(qw422016, &rs[0])
-//line app/vmselect/prometheus/query_range_response.qtpl:16
+//line app/vmselect/prometheus/query_range_response.qtpl:19
 		rs = rs[1:]
 
-//line app/vmselect/prometheus/query_range_response.qtpl:17
+//line app/vmselect/prometheus/query_range_response.qtpl:20
 		for i := range rs {
-//line app/vmselect/prometheus/query_range_response.qtpl:17
+//line app/vmselect/prometheus/query_range_response.qtpl:20
 			qw422016.N().S(`,`)
-//line app/vmselect/prometheus/query_range_response.qtpl:18
+//line app/vmselect/prometheus/query_range_response.qtpl:21
 			streamqueryRangeLine(qw422016, &rs[i])
-//line app/vmselect/prometheus/query_range_response.qtpl:19
+//line app/vmselect/prometheus/query_range_response.qtpl:22
 		}
-//line app/vmselect/prometheus/query_range_response.qtpl:20
+//line app/vmselect/prometheus/query_range_response.qtpl:23
 	}
-//line app/vmselect/prometheus/query_range_response.qtpl:20
-	qw422016.N().S(`]}}`)
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:23
+	qw422016.N().S(`]}`)
+//line app/vmselect/prometheus/query_range_response.qtpl:27
+	qt.Printf("generate /api/v1/query_range response for series=%d", seriesCount)
+	qt.Done()
+
+//line app/vmselect/prometheus/query_range_response.qtpl:30
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/query_range_response.qtpl:30
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_range_response.qtpl:32
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:24
-func WriteQueryRangeResponse(qq422016 qtio422016.Writer, rs []netstorage.Result) {
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:32
+func WriteQueryRangeResponse(qq422016 qtio422016.Writer, isPartial bool, rs []netstorage.Result, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/query_range_response.qtpl:32
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:24
-	StreamQueryRangeResponse(qw422016, rs)
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:32
+	StreamQueryRangeResponse(qw422016, isPartial, rs, qt)
+//line app/vmselect/prometheus/query_range_response.qtpl:32
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:32
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:24
-func QueryRangeResponse(rs []netstorage.Result) string {
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:32
+func QueryRangeResponse(isPartial bool, rs []netstorage.Result, qt *querytracer.Tracer) string {
+//line app/vmselect/prometheus/query_range_response.qtpl:32
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/query_range_response.qtpl:24
-	WriteQueryRangeResponse(qb422016, rs)
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:32
+	WriteQueryRangeResponse(qb422016, isPartial, rs, qt)
+//line app/vmselect/prometheus/query_range_response.qtpl:32
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:32
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:32
 	return qs422016
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:32
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:26
+//line app/vmselect/prometheus/query_range_response.qtpl:34
 func streamqueryRangeLine(qw422016 *qt422016.Writer, r *netstorage.Result) {
-//line app/vmselect/prometheus/query_range_response.qtpl:26
+//line app/vmselect/prometheus/query_range_response.qtpl:34
 	qw422016.N().S(`{"metric":`)
-//line app/vmselect/prometheus/query_range_response.qtpl:28
+//line app/vmselect/prometheus/query_range_response.qtpl:36
 	streammetricNameObject(qw422016, &r.MetricName)
-//line app/vmselect/prometheus/query_range_response.qtpl:28
+//line app/vmselect/prometheus/query_range_response.qtpl:36
 	qw422016.N().S(`,"values":`)
-//line app/vmselect/prometheus/query_range_response.qtpl:29
+//line app/vmselect/prometheus/query_range_response.qtpl:37
 	streamvaluesWithTimestamps(qw422016, r.Values, r.Timestamps)
-//line app/vmselect/prometheus/query_range_response.qtpl:29
+//line app/vmselect/prometheus/query_range_response.qtpl:37
 	qw422016.N().S(`}`)
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 func writequeryRangeLine(qq422016 qtio422016.Writer, r *netstorage.Result) {
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 	streamqueryRangeLine(qw422016, r)
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 func queryRangeLine(r *netstorage.Result) string {
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 	writequeryRangeLine(qb422016, r)
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 	return qs422016
-//line app/vmselect/prometheus/query_range_response.qtpl:31
+//line app/vmselect/prometheus/query_range_response.qtpl:39
 }
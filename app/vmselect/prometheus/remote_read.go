@@ -0,0 +1,349 @@
+package prometheus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/bufferedwriter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/valyala/quicktemplate"
+)
+
+// maxRemoteReadRequestSize is the maximum size of uncompressed remote read request.
+//
+// Remote read requests contain only label matchers, so they are usually small.
+const maxRemoteReadRequestSize = 32 * 1024 * 1024
+
+// RemoteReadHandler processes /api/v1/read request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/remote_read_api/
+func RemoteReadHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer remoteReadDuration.UpdateDuration(startTime)
+
+	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+	etf, err := searchutils.GetEnforcedTagFiltersFromRequest(r)
+	if err != nil {
+		return err
+	}
+	rr, err := readRemoteReadRequest(r)
+	if err != nil {
+		return err
+	}
+	responseType, ok := getRemoteReadResponseType(rr.AcceptedResponseTypes)
+	if !ok {
+		return fmt.Errorf("unsupported accepted_response_types=%v; supported response types: SAMPLES, STREAMED_XOR_CHUNKS", rr.AcceptedResponseTypes)
+	}
+	if responseType == prompb.ReadRequestStreamedXORChunks {
+		return writeStreamedRemoteReadResponse(w, rr, etf, deadline)
+	}
+	var resp prompbmarshal.ReadResponse
+	resp.Results = make([]prompbmarshal.QueryResult, len(rr.Queries))
+	for i := range rr.Queries {
+		tss, err := remoteReadQuery(&rr.Queries[i], etf, deadline)
+		if err != nil {
+			return err
+		}
+		resp.Results[i].Timeseries = tss
+	}
+	data := prompbmarshal.MarshalReadResponse(nil, &resp)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+	if _, err := w.Write(snappy.Encode(nil, data)); err != nil {
+		return fmt.Errorf("cannot send remote read response to remote client: %w", err)
+	}
+	return nil
+}
+
+var remoteReadDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/read"}`)
+
+func readRemoteReadRequest(r *http.Request) (*prompb.ReadRequest, error) {
+	compressed, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRemoteReadRequestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read remote read request: %w", err)
+	}
+	if len(compressed) > maxRemoteReadRequestSize {
+		return nil, fmt.Errorf("too big compressed remote read request; mustn't exceed %d bytes", maxRemoteReadRequestSize)
+	}
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode snappy-compressed remote read request: %w", err)
+	}
+	if n > maxRemoteReadRequestSize {
+		return nil, fmt.Errorf("too big remote read request; mustn't exceed %d bytes; got %d bytes", maxRemoteReadRequestSize, n)
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode snappy-compressed remote read request: %w", err)
+	}
+	var rr prompb.ReadRequest
+	if err := rr.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal remote read request: %w", err)
+	}
+	return &rr, nil
+}
+
+// getRemoteReadResponseType returns the first supported response type from types.
+//
+// types must be sorted in the order of preference according to Prometheus remote read protocol.
+func getRemoteReadResponseType(types []prompb.ReadRequestResponseType) (prompb.ReadRequestResponseType, bool) {
+	if len(types) == 0 {
+		// Prometheus treats an empty list as SAMPLES.
+		return prompb.ReadRequestSamples, true
+	}
+	for _, t := range types {
+		if t == prompb.ReadRequestSamples || t == prompb.ReadRequestStreamedXORChunks {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+func remoteReadQuery(q *prompb.Query, etf []storage.TagFilter, deadline searchutils.Deadline) ([]prompbmarshal.TimeSeries, error) {
+	sq, err := getRemoteReadSearchQuery(q, etf)
+	if err != nil {
+		return nil, err
+	}
+	rss, err := netstorage.ProcessSearchQuery(nil, true, sq, true, nil, deadline)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch data for %q: %w", sq, err)
+	}
+	var tssLock sync.Mutex
+	var tss []prompbmarshal.TimeSeries
+	err = rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
+		ts := resultToTimeSeries(rs)
+		tssLock.Lock()
+		tss = append(tss, ts)
+		tssLock.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error during remote read for %q: %w", sq, err)
+	}
+	// Return series in a deterministic order.
+	sort.Slice(tss, func(i, j int) bool {
+		return labelsLess(tss[i].Labels, tss[j].Labels)
+	})
+	return tss, nil
+}
+
+// writeStreamedRemoteReadResponse writes STREAMED_XOR_CHUNKS response for rr to w.
+//
+// Series are written to w as soon as they are read from the storage, so the whole response isn't buffered in memory.
+// Series are returned in arbitrary order.
+func writeStreamedRemoteReadResponse(w http.ResponseWriter, rr *prompb.ReadRequest, etf []storage.TagFilter, deadline searchutils.Deadline) error {
+	w.Header().Set("Content-Type", "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	for i := range rr.Queries {
+		sq, err := getRemoteReadSearchQuery(&rr.Queries[i], etf)
+		if err != nil {
+			return err
+		}
+		rss, err := netstorage.ProcessSearchQuery(nil, true, sq, true, nil, deadline)
+		if err != nil {
+			return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
+		}
+		queryIndex := int64(i)
+		err = rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
+			if err := bw.Error(); err != nil {
+				return err
+			}
+			bb := quicktemplate.AcquireByteBuffer()
+			bb.B = appendChunkedReadResponseFrames(bb.B[:0], rs, queryIndex)
+			_, err := bw.Write(bb.B)
+			quicktemplate.ReleaseByteBuffer(bb)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error during streamed remote read for %q: %w", sq, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send streamed remote read response to remote client: %w", err)
+	}
+	return nil
+}
+
+const (
+	// maxSamplesPerChunk is the maximum number of samples per XOR chunk.
+	//
+	// This is the same value as Prometheus uses.
+	maxSamplesPerChunk = 120
+
+	// maxBytesInFrame is the maximum size of a single ChunkedReadResponse message.
+	//
+	// This is the same value as the default for -storage.remote.read-max-bytes-in-frame in Prometheus.
+	maxBytesInFrame = 1024 * 1024
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// appendChunkedReadResponseFrames appends ChunkedReadResponse frames for rs to dst and returns the result.
+//
+// Samples are encoded into XOR chunks. A series is split into multiple frames if its chunks exceed maxBytesInFrame.
+func appendChunkedReadResponseFrames(dst []byte, rs *netstorage.Result, queryIndex int64) []byte {
+	crr := prompbmarshal.ChunkedReadResponse{
+		ChunkedSeries: []prompbmarshal.ChunkedSeries{{
+			Labels: metricNameToLabels(&rs.MetricName),
+		}},
+		QueryIndex: queryIndex,
+	}
+	cs := &crr.ChunkedSeries[0]
+	frameSize := cs.Size()
+	var buf []byte
+	timestamps := rs.Timestamps
+	values := rs.Values
+	for len(timestamps) > 0 {
+		n := maxSamplesPerChunk
+		if n > len(timestamps) {
+			n = len(timestamps)
+		}
+		c := chunkenc.NewXORChunk()
+		app, err := c.Appender()
+		if err != nil {
+			logger.Panicf("BUG: cannot create appender for XOR chunk: %s", err)
+		}
+		for i := 0; i < n; i++ {
+			app.Append(timestamps[i], values[i])
+		}
+		chunk := prompbmarshal.Chunk{
+			MinTimeMs: timestamps[0],
+			MaxTimeMs: timestamps[n-1],
+			Type:      prompbmarshal.ChunkEncodingXOR,
+			Data:      c.Bytes(),
+		}
+		timestamps = timestamps[n:]
+		values = values[n:]
+		cs.Chunks = append(cs.Chunks, chunk)
+		chunkSize := chunk.Size()
+		frameSize += 1 + chunkSize + sovVarint(uint64(chunkSize))
+		if frameSize >= maxBytesInFrame {
+			buf = prompbmarshal.MarshalChunkedReadResponse(buf[:0], &crr)
+			dst = appendFrame(dst, buf)
+			cs.Chunks = cs.Chunks[:0]
+			frameSize = cs.Size()
+		}
+	}
+	if len(cs.Chunks) > 0 {
+		buf = prompbmarshal.MarshalChunkedReadResponse(buf[:0], &crr)
+		dst = appendFrame(dst, buf)
+	}
+	return dst
+}
+
+// appendFrame appends a frame with the given data to dst according to Prometheus streamed remote read protocol.
+//
+// The frame consists of uvarint-encoded data size, big-endian CRC32 Castagnoli checksum for data and data itself.
+func appendFrame(dst, data []byte) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], uint64(len(data)))
+	dst = append(dst, b[:n]...)
+	dst = encoding.MarshalUint32(dst, crc32.Checksum(data, castagnoliTable))
+	return append(dst, data...)
+}
+
+func sovVarint(x uint64) int {
+	var b [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(b[:], x)
+}
+
+func getRemoteReadSearchQuery(q *prompb.Query, etf []storage.TagFilter) (*storage.SearchQuery, error) {
+	tfs, err := labelMatchersToTagFilters(q.Matchers)
+	if err != nil {
+		return nil, err
+	}
+	tagFilterss := addEnforcedFiltersToTagFilterss([][]storage.TagFilter{tfs}, etf)
+	return storage.NewSearchQuery(q.StartTimestampMs, q.EndTimestampMs, tagFilterss), nil
+}
+
+func labelMatchersToTagFilters(lms []prompb.LabelMatcher) ([]storage.TagFilter, error) {
+	tfs := make([]storage.TagFilter, 0, len(lms))
+	for i := range lms {
+		lm := &lms[i]
+		var tf storage.TagFilter
+		if lm.Name != "__name__" {
+			tf.Key = []byte(lm.Name)
+		}
+		tf.Value = []byte(lm.Value)
+		switch lm.Type {
+		case prompb.LabelMatcherEQ:
+		case prompb.LabelMatcherNEQ:
+			tf.IsNegative = true
+		case prompb.LabelMatcherRE:
+			tf.IsRegexp = true
+		case prompb.LabelMatcherNRE:
+			tf.IsNegative = true
+			tf.IsRegexp = true
+		default:
+			return nil, fmt.Errorf("unknown label matcher type=%d for label %q", lm.Type, lm.Name)
+		}
+		tfs = append(tfs, tf)
+	}
+	return tfs, nil
+}
+
+// resultToTimeSeries converts rs to TimeSeries with sorted labels.
+//
+// The returned TimeSeries doesn't refer to rs, so rs may be re-used after the call.
+func resultToTimeSeries(rs *netstorage.Result) prompbmarshal.TimeSeries {
+	samples := make([]prompbmarshal.Sample, len(rs.Timestamps))
+	for i, ts := range rs.Timestamps {
+		samples[i] = prompbmarshal.Sample{
+			Value:     rs.Values[i],
+			Timestamp: ts,
+		}
+	}
+	return prompbmarshal.TimeSeries{
+		Labels:  metricNameToLabels(&rs.MetricName),
+		Samples: samples,
+	}
+}
+
+// metricNameToLabels returns labels sorted by name for mn.
+func metricNameToLabels(mn *storage.MetricName) []prompbmarshal.Label {
+	labels := make([]prompbmarshal.Label, 0, len(mn.Tags)+1)
+	if len(mn.MetricGroup) > 0 {
+		labels = append(labels, prompbmarshal.Label{
+			Name:  "__name__",
+			Value: string(mn.MetricGroup),
+		})
+	}
+	for _, tag := range mn.Tags {
+		labels = append(labels, prompbmarshal.Label{
+			Name:  string(tag.Key),
+			Value: string(tag.Value),
+		})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels
+}
+
+func labelsLess(a, b []prompbmarshal.Label) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].Name != b[i].Name {
+			return a[i].Name < b[i].Name
+		}
+		if a[i].Value != b[i].Value {
+			return a[i].Value < b[i].Value
+		}
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,258 @@
+package prometheus
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+)
+
+func TestGetRemoteReadResponseType(t *testing.T) {
+	f := func(types []prompb.ReadRequestResponseType, rtExpected prompb.ReadRequestResponseType, okExpected bool) {
+		t.Helper()
+		rt, ok := getRemoteReadResponseType(types)
+		if ok != okExpected {
+			t.Fatalf("unexpected ok for getRemoteReadResponseType(%v); got %v; want %v", types, ok, okExpected)
+		}
+		if rt != rtExpected {
+			t.Fatalf("unexpected response type for getRemoteReadResponseType(%v); got %d; want %d", types, rt, rtExpected)
+		}
+	}
+	f(nil, prompb.ReadRequestSamples, true)
+	f([]prompb.ReadRequestResponseType{prompb.ReadRequestSamples}, prompb.ReadRequestSamples, true)
+	f([]prompb.ReadRequestResponseType{prompb.ReadRequestStreamedXORChunks}, prompb.ReadRequestStreamedXORChunks, true)
+	f([]prompb.ReadRequestResponseType{prompb.ReadRequestStreamedXORChunks, prompb.ReadRequestSamples}, prompb.ReadRequestStreamedXORChunks, true)
+	f([]prompb.ReadRequestResponseType{5, prompb.ReadRequestSamples}, prompb.ReadRequestSamples, true)
+	f([]prompb.ReadRequestResponseType{5}, 0, false)
+}
+
+func TestLabelMatchersToTagFilters(t *testing.T) {
+	tfs, err := labelMatchersToTagFilters([]prompb.LabelMatcher{
+		{
+			Type:  prompb.LabelMatcherEQ,
+			Name:  "__name__",
+			Value: "up",
+		},
+		{
+			Type:  prompb.LabelMatcherNEQ,
+			Name:  "a",
+			Value: "b",
+		},
+		{
+			Type:  prompb.LabelMatcherRE,
+			Name:  "c",
+			Value: "d.*",
+		},
+		{
+			Type:  prompb.LabelMatcherNRE,
+			Name:  "e",
+			Value: "f|g",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tfsExpected := []storage.TagFilter{
+		{
+			Value: []byte("up"),
+		},
+		{
+			Key:        []byte("a"),
+			Value:      []byte("b"),
+			IsNegative: true,
+		},
+		{
+			Key:      []byte("c"),
+			Value:    []byte("d.*"),
+			IsRegexp: true,
+		},
+		{
+			Key:        []byte("e"),
+			Value:      []byte("f|g"),
+			IsNegative: true,
+			IsRegexp:   true,
+		},
+	}
+	if !reflect.DeepEqual(tfs, tfsExpected) {
+		t.Fatalf("unexpected tag filters;\ngot\n%v\nwant\n%v", tfs, tfsExpected)
+	}
+
+	// Unknown matcher type
+	if _, err := labelMatchersToTagFilters([]prompb.LabelMatcher{{Type: 4, Name: "a"}}); err == nil {
+		t.Fatalf("expecting non-nil error for unknown matcher type")
+	}
+}
+
+func TestResultToTimeSeries(t *testing.T) {
+	var rs netstorage.Result
+	rs.MetricName.MetricGroup = []byte("up")
+	rs.MetricName.AddTag("job", "foo")
+	rs.MetricName.AddTag("instance", "bar")
+	rs.Timestamps = []int64{1000, 2000}
+	rs.Values = []float64{1, 0}
+	ts := resultToTimeSeries(&rs)
+	tsExpected := prompbmarshal.TimeSeries{
+		Labels: []prompbmarshal.Label{
+			{
+				Name:  "__name__",
+				Value: "up",
+			},
+			{
+				Name:  "instance",
+				Value: "bar",
+			},
+			{
+				Name:  "job",
+				Value: "foo",
+			},
+		},
+		Samples: []prompbmarshal.Sample{
+			{
+				Value:     1,
+				Timestamp: 1000,
+			},
+			{
+				Value:     0,
+				Timestamp: 2000,
+			},
+		},
+	}
+	if !reflect.DeepEqual(ts, tsExpected) {
+		t.Fatalf("unexpected time series;\ngot\n%v\nwant\n%v", ts, tsExpected)
+	}
+}
+
+func TestAppendChunkedReadResponseFrames(t *testing.T) {
+	var rs netstorage.Result
+	rs.MetricName.MetricGroup = []byte("foo")
+	rs.MetricName.AddTag("job", "bar")
+	for i := 0; i < 2*maxSamplesPerChunk+10; i++ {
+		rs.Timestamps = append(rs.Timestamps, int64(i)*1000)
+		rs.Values = append(rs.Values, float64(i))
+	}
+	data := appendChunkedReadResponseFrames(nil, &rs, 3)
+
+	// Read the frame.
+	size, n := binary.Uvarint(data)
+	if n <= 0 {
+		t.Fatalf("cannot read frame size")
+	}
+	data = data[n:]
+	if len(data) < 4 {
+		t.Fatalf("missing frame checksum")
+	}
+	checksum := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	if uint64(len(data)) != size {
+		t.Fatalf("unexpected frame size; got %d; want %d", len(data), size)
+	}
+	if checksumExpected := crc32.Checksum(data, castagnoliTable); checksum != checksumExpected {
+		t.Fatalf("unexpected checksum; got %d; want %d", checksum, checksumExpected)
+	}
+
+	// Read ChunkedReadResponse
+	var seriesData []byte
+	var queryIndex uint64
+	for len(data) > 0 {
+		fieldNum, v, tail := readProtoField(t, data)
+		data = tail
+		switch fieldNum {
+		case 1:
+			seriesData = v
+		case 2:
+			queryIndex, _ = binary.Uvarint(v)
+		}
+	}
+	if queryIndex != 3 {
+		t.Fatalf("unexpected query_index; got %d; want 3", queryIndex)
+	}
+
+	// Read ChunkedSeries
+	var labels []string
+	var timestamps []int64
+	var values []float64
+	chunks := 0
+	for len(seriesData) > 0 {
+		fieldNum, v, tail := readProtoField(t, seriesData)
+		seriesData = tail
+		switch fieldNum {
+		case 1:
+			for len(v) > 0 {
+				_, s, tail := readProtoField(t, v)
+				v = tail
+				labels = append(labels, string(s))
+			}
+		case 2:
+			chunks++
+			for len(v) > 0 {
+				fieldNum, chunkData, tail := readProtoField(t, v)
+				v = tail
+				if fieldNum != 4 {
+					continue
+				}
+				c, err := chunkenc.FromData(chunkenc.EncXOR, chunkData)
+				if err != nil {
+					t.Fatalf("cannot read XOR chunk: %s", err)
+				}
+				it := c.Iterator(nil)
+				for it.Next() {
+					ts, v := it.At()
+					timestamps = append(timestamps, ts)
+					values = append(values, v)
+				}
+				if err := it.Err(); err != nil {
+					t.Fatalf("cannot iterate XOR chunk: %s", err)
+				}
+			}
+		}
+	}
+	labelsExpected := []string{"__name__", "foo", "job", "bar"}
+	if !reflect.DeepEqual(labels, labelsExpected) {
+		t.Fatalf("unexpected labels; got %q; want %q", labels, labelsExpected)
+	}
+	if chunks != 3 {
+		t.Fatalf("unexpected number of chunks; got %d; want 3", chunks)
+	}
+	if !reflect.DeepEqual(timestamps, rs.Timestamps) {
+		t.Fatalf("unexpected timestamps;\ngot\n%v\nwant\n%v", timestamps, rs.Timestamps)
+	}
+	if !reflect.DeepEqual(values, rs.Values) {
+		t.Fatalf("unexpected values;\ngot\n%v\nwant\n%v", values, rs.Values)
+	}
+}
+
+// readProtoField reads protobuf field from data.
+//
+// The value for varint fields is returned in uvarint-encoded form.
+func readProtoField(t *testing.T, data []byte) (uint64, []byte, []byte) {
+	t.Helper()
+	tag, n := binary.Uvarint(data)
+	if n <= 0 {
+		t.Fatalf("cannot read field tag")
+	}
+	data = data[n:]
+	switch tag & 7 {
+	case 0:
+		_, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("cannot read varint")
+		}
+		return tag >> 3, data[:n], data[n:]
+	case 2:
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data[n:])) < size {
+			t.Fatalf("cannot read field with length %d", size)
+		}
+		data = data[n:]
+		return tag >> 3, data[:size], data[size:]
+	default:
+		t.Fatalf("unexpected wire type %d", tag&7)
+	}
+	return 0, nil, nil
+}
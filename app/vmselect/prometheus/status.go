@@ -0,0 +1,94 @@
+package prometheus
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+)
+
+// prometheusCompatibleVersion is reported at /api/v1/status/buildinfo.
+//
+// Clients such as Grafana detect the supported querying API features by Prometheus version,
+// so the version of Prometheus with the querying API compatible with VictoriaMetrics is reported.
+const prometheusCompatibleVersion = "2.24.0"
+
+var processStartTime = time.Now()
+
+// BuildInfoHandler processes /api/v1/status/buildinfo request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#build-information
+func BuildInfoHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	WriteBuildInfoResponse(w, prometheusCompatibleVersion, buildinfo.Version, runtime.Version())
+}
+
+// FlagsHandler processes /api/v1/status/flags request.
+//
+// Values for flags with secrets are hidden.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#flags
+func FlagsHandler(w http.ResponseWriter, r *http.Request) {
+	names, values := getFlags()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	WriteFlagsResponse(w, names, values)
+}
+
+func getFlags() ([]string, []string) {
+	var names, values []string
+	// flag.VisitAll visits flags in lexicographical order.
+	flag.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if flagutil.IsSecretFlag(strings.ToLower(f.Name)) {
+			value = "secret"
+		}
+		names = append(names, f.Name)
+		values = append(values, value)
+	})
+	return names, values
+}
+
+// runtimeInfo contains data for /api/v1/status/runtimeinfo response.
+type runtimeInfo struct {
+	startTime        string
+	cwd              string
+	goroutineCount   int
+	gomaxprocs       int
+	gogc             string
+	godebug          string
+	storageRetention string
+}
+
+// RuntimeInfoHandler processes /api/v1/status/runtimeinfo request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#runtime-information
+func RuntimeInfoHandler(w http.ResponseWriter, r *http.Request) {
+	ri := getRuntimeInfo()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	WriteRuntimeInfoResponse(w, ri)
+}
+
+func getRuntimeInfo() *runtimeInfo {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "<error retrieving current working directory>"
+	}
+	storageRetention := ""
+	if f := flag.Lookup("retentionPeriod"); f != nil {
+		storageRetention = f.Value.String()
+	}
+	return &runtimeInfo{
+		startTime:        processStartTime.Format(time.RFC3339Nano),
+		cwd:              cwd,
+		goroutineCount:   runtime.NumGoroutine(),
+		gomaxprocs:       runtime.GOMAXPROCS(0),
+		gogc:             os.Getenv("GOGC"),
+		godebug:          os.Getenv("GODEBUG"),
+		storageRetention: storageRetention,
+	}
+}
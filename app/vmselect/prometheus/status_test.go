@@ -0,0 +1,70 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"flag"
+	"testing"
+)
+
+func TestBuildInfoResponse(t *testing.T) {
+	response := BuildInfoResponse(prometheusCompatibleVersion, "victoria-metrics-test", "go1.17")
+	var v struct {
+		Status string            `json:"status"`
+		Data   map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(response), &v); err != nil {
+		t.Fatalf("cannot unmarshal response %s: %s", response, err)
+	}
+	if v.Status != "success" {
+		t.Fatalf("unexpected status in response %s", response)
+	}
+	if v.Data["version"] != prometheusCompatibleVersion || v.Data["revision"] != "victoria-metrics-test" || v.Data["goVersion"] != "go1.17" {
+		t.Fatalf("unexpected data in response %s", response)
+	}
+}
+
+func TestFlagsResponse(t *testing.T) {
+	_ = flag.String("testFlagsResponse.authKey", "foobar", "test flag with secret value")
+	names, values := getFlags()
+	response := FlagsResponse(names, values)
+	var v struct {
+		Status string            `json:"status"`
+		Data   map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(response), &v); err != nil {
+		t.Fatalf("cannot unmarshal response %s: %s", response, err)
+	}
+	if v.Status != "success" {
+		t.Fatalf("unexpected status in response %s", response)
+	}
+	if len(v.Data) != len(names) {
+		t.Fatalf("unexpected number of flags in response; got %d; want %d", len(v.Data), len(names))
+	}
+	if value := v.Data["search.latencyOffset"]; value != "30s" {
+		t.Fatalf("unexpected value for -search.latencyOffset; got %q; want %q", value, "30s")
+	}
+	if value := v.Data["testFlagsResponse.authKey"]; value != "secret" {
+		t.Fatalf("the value for -testFlagsResponse.authKey must be hidden; got %q", value)
+	}
+}
+
+func TestRuntimeInfoResponse(t *testing.T) {
+	response := RuntimeInfoResponse(getRuntimeInfo())
+	var v struct {
+		Status string `json:"status"`
+		Data   struct {
+			StartTime      string `json:"startTime"`
+			GoroutineCount int    `json:"goroutineCount"`
+			GOMAXPROCS     int    `json:"GOMAXPROCS"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(response), &v); err != nil {
+		t.Fatalf("cannot unmarshal response %s: %s", response, err)
+	}
+	if v.Status != "success" {
+		t.Fatalf("unexpected status in response %s", response)
+	}
+	if v.Data.StartTime == "" || v.Data.GoroutineCount <= 0 || v.Data.GOMAXPROCS <= 0 {
+		t.Fatalf("unexpected data in response %s", response)
+	}
+}
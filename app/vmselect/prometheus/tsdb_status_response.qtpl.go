@@ -25,99 +25,123 @@ var (
 //line app/vmselect/prometheus/tsdb_status_response.qtpl:5
 func StreamTSDBStatusResponse(qw422016 *qt422016.Writer, status *storage.TSDBStatus) {
 //line app/vmselect/prometheus/tsdb_status_response.qtpl:5
-	qw422016.N().S(`{"status":"success","data":{"seriesCountByMetricName":`)
+	qw422016.N().S(`{"status":"success","data":{"totalSeries":`)
 //line app/vmselect/prometheus/tsdb_status_response.qtpl:9
-	streamtsdbStatusEntries(qw422016, status.SeriesCountByMetricName)
+	qw422016.N().DUL(status.TotalSeries)
 //line app/vmselect/prometheus/tsdb_status_response.qtpl:9
-	qw422016.N().S(`,"labelValueCountByLabelName":`)
+	qw422016.N().S(`,"totalLabelValuePairs":`)
 //line app/vmselect/prometheus/tsdb_status_response.qtpl:10
-	streamtsdbStatusEntries(qw422016, status.LabelValueCountByLabelName)
+	qw422016.N().DUL(status.TotalLabelValuePairs)
 //line app/vmselect/prometheus/tsdb_status_response.qtpl:10
-	qw422016.N().S(`,"seriesCountByLabelValuePair":`)
+	qw422016.N().S(`,"seriesCountByMetricName":`)
 //line app/vmselect/prometheus/tsdb_status_response.qtpl:11
-	streamtsdbStatusEntries(qw422016, status.SeriesCountByLabelValuePair)
+	streamtsdbStatusEntries(qw422016, status.SeriesCountByMetricName)
 //line app/vmselect/prometheus/tsdb_status_response.qtpl:11
-	qw422016.N().S(`}}`)
+	qw422016.N().S(`,"labelValueCountByLabelName":`)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:12
+	streamtsdbStatusEntries(qw422016, status.LabelValueCountByLabelName)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:12
+	qw422016.N().S(`,"seriesCountByLabelValuePair":`)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:13
+	streamtsdbStatusEntries(qw422016, status.SeriesCountByLabelValuePair)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:13
+	qw422016.N().S(`,"seriesCountByFocusLabelValue":`)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+	streamtsdbStatusEntries(qw422016, status.SeriesCountByFocusLabelValue)
 //line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+	qw422016.N().S(`,"totalBytes":`)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:15
+	qw422016.N().DUL(status.TotalBytes)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:15
+	qw422016.N().S(`,"bytesByMetricName":`)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:16
+	streamtsdbStatusEntries(qw422016, status.BytesByMetricName)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:16
+	qw422016.N().S(`,"bytesByLabelValuePair":`)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:17
+	streamtsdbStatusEntries(qw422016, status.BytesByLabelValuePair)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:17
+	qw422016.N().S(`}}`)
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 }
 
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 func WriteTSDBStatusResponse(qq422016 qtio422016.Writer, status *storage.TSDBStatus) {
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 	StreamTSDBStatusResponse(qw422016, status)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 }
 
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 func TSDBStatusResponse(status *storage.TSDBStatus) string {
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 	WriteTSDBStatusResponse(qb422016, status)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 	return qs422016
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:14
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
 }
 
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:16
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:22
 func streamtsdbStatusEntries(qw422016 *qt422016.Writer, a []storage.TopHeapEntry) {
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:16
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:22
 	qw422016.N().S(`[`)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:18
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:24
 	for i, e := range a {
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:18
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:24
 		qw422016.N().S(`{"name":`)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
 		qw422016.N().Q(e.Name)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:20
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
 		qw422016.N().S(`,"value":`)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:21
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:27
 		qw422016.N().D(int(e.Count))
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:21
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:27
 		qw422016.N().S(`}`)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:23
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:29
 		if i+1 < len(a) {
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:23
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:29
 			qw422016.N().S(`,`)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:23
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:29
 		}
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:24
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:30
 	}
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:24
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:30
 	qw422016.N().S(`]`)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 }
 
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 func writetsdbStatusEntries(qq422016 qtio422016.Writer, a []storage.TopHeapEntry) {
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 	streamtsdbStatusEntries(qw422016, a)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 }
 
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 func tsdbStatusEntries(a []storage.TopHeapEntry) string {
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 	writetsdbStatusEntries(qb422016, a)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 	return qs422016
-//line app/vmselect/prometheus/tsdb_status_response.qtpl:26
+//line app/vmselect/prometheus/tsdb_status_response.qtpl:32
 }
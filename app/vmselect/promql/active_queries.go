@@ -7,6 +7,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
 )
 
 // WriteActiveQueries writes active queries to w.
@@ -25,6 +27,35 @@ func WriteActiveQueries(w io.Writer) {
 	}
 }
 
+// WriteActiveQueriesJSON writes active queries to w in JSON format.
+//
+// The written active queries are sorted in descending order of their exeuction duration.
+func WriteActiveQueriesJSON(w io.Writer) {
+	aqes := activeQueriesV.GetAll()
+	sort.Slice(aqes, func(i, j int) bool {
+		return aqes[i].startTime.Sub(aqes[j].startTime) < 0
+	})
+	now := time.Now()
+	fmt.Fprintf(w, `{"status":"ok","data":[`)
+	for i, aqe := range aqes {
+		d := now.Sub(aqe.startTime)
+		fmt.Fprintf(w, `{"duration":"%.3fs","id":"%016X","remote_addr":%s,"query":%q,"start":%d,"end":%d,"step":%d,"start_time":%q}`,
+			d.Seconds(), aqe.qid, aqe.quotedRemoteAddr, aqe.q, aqe.start, aqe.end, aqe.step, aqe.startTime.UTC().Format(time.RFC3339Nano))
+		if i+1 < len(aqes) {
+			fmt.Fprintf(w, `,`)
+		}
+	}
+	fmt.Fprintf(w, `]}`)
+}
+
+// CancelActiveQuery cancels the active query with the given qid.
+//
+// The qid must be obtained from WriteActiveQueries or WriteActiveQueriesJSON output.
+// It returns false if there is no active query with the given qid.
+func CancelActiveQuery(qid uint64) bool {
+	return activeQueriesV.Cancel(qid)
+}
+
 var activeQueriesV = newActiveQueries()
 
 type activeQueries struct {
@@ -40,6 +71,7 @@ type activeQueryEntry struct {
 	quotedRemoteAddr string
 	q                string
 	startTime        time.Time
+	deadline         searchutils.Deadline
 }
 
 func newActiveQueries() *activeQueries {
@@ -57,6 +89,7 @@ func (aq *activeQueries) Add(ec *EvalConfig, q string) uint64 {
 	aqe.quotedRemoteAddr = ec.QuotedRemoteAddr
 	aqe.q = q
 	aqe.startTime = time.Now()
+	aqe.deadline = ec.Deadline
 
 	aq.mu.Lock()
 	aq.m[aqe.qid] = aqe
@@ -70,6 +103,17 @@ func (aq *activeQueries) Remove(qid uint64) {
 	aq.mu.Unlock()
 }
 
+func (aq *activeQueries) Cancel(qid uint64) bool {
+	aq.mu.Lock()
+	aqe, ok := aq.m[qid]
+	aq.mu.Unlock()
+	if !ok {
+		return false
+	}
+	aqe.deadline.Cancel()
+	return true
+}
+
 func (aq *activeQueries) GetAll() []activeQueryEntry {
 	aq.mu.Lock()
 	aqes := make([]activeQueryEntry, 0, len(aq.m))
@@ -0,0 +1,65 @@
+package promql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+)
+
+func TestActiveQueriesCancel(t *testing.T) {
+	ec := &EvalConfig{
+		Start:            1000,
+		End:              2000,
+		Step:             100,
+		QuotedRemoteAddr: `"1.2.3.4:5678"`,
+		Deadline:         searchutils.NewDeadline(time.Now(), time.Minute, ""),
+	}
+	qid := activeQueriesV.Add(ec, "sum(rate(foo[5m]))")
+	defer activeQueriesV.Remove(qid)
+
+	var bb bytes.Buffer
+	WriteActiveQueriesJSON(&bb)
+	var resp struct {
+		Status string
+		Data   []struct {
+			ID         string
+			RemoteAddr string `json:"remote_addr"`
+			Query      string
+			Step       int64
+		}
+	}
+	if err := json.Unmarshal(bb.Bytes(), &resp); err != nil {
+		t.Fatalf("cannot parse active queries response %q: %s", bb.String(), err)
+	}
+	idExpected := fmt.Sprintf("%016X", qid)
+	found := false
+	for _, aq := range resp.Data {
+		if aq.ID != idExpected {
+			continue
+		}
+		found = true
+		if aq.Query != "sum(rate(foo[5m]))" || aq.RemoteAddr != "1.2.3.4:5678" || aq.Step != 100 {
+			t.Fatalf("unexpected active query entry: %+v", aq)
+		}
+	}
+	if !found {
+		t.Fatalf("cannot find active query with id=%s in the response %s", idExpected, bb.String())
+	}
+
+	if ec.Deadline.Exceeded() {
+		t.Fatalf("deadline mustn't be exceeded before canceling the query")
+	}
+	if !CancelActiveQuery(qid) {
+		t.Fatalf("cannot cancel active query with id=%s", idExpected)
+	}
+	if !ec.Deadline.Exceeded() {
+		t.Fatalf("deadline must be exceeded after canceling the query")
+	}
+	if CancelActiveQuery(qid + 1) {
+		t.Fatalf("unexpected cancellation of non-existing query")
+	}
+}
@@ -40,10 +40,12 @@ var aggrFuncs = map[string]aggrFunc{
 	"topk_max":       newAggrFuncRangeTopK(maxValue, false),
 	"topk_avg":       newAggrFuncRangeTopK(avgValue, false),
 	"topk_median":    newAggrFuncRangeTopK(medianValue, false),
+	"topk_last":      newAggrFuncRangeTopK(lastValue, false),
 	"bottomk_min":    newAggrFuncRangeTopK(minValue, true),
 	"bottomk_max":    newAggrFuncRangeTopK(maxValue, true),
 	"bottomk_avg":    newAggrFuncRangeTopK(avgValue, true),
 	"bottomk_median": newAggrFuncRangeTopK(medianValue, true),
+	"bottomk_last":   newAggrFuncRangeTopK(lastValue, true),
 	"any":            aggrFuncAny,
 	"mad":            newAggrFunc(aggrFuncMAD),
 	"outliers_mad":   aggrFuncOutliersMAD,
@@ -812,6 +814,16 @@ func medianValue(values []float64) float64 {
 	return value
 }
 
+func lastValue(values []float64) float64 {
+	for i := len(values) - 1; i >= 0; i-- {
+		v := values[i]
+		if !math.IsNaN(v) {
+			return v
+		}
+	}
+	return nan
+}
+
 func aggrFuncMAD(tss []*timeseries) []*timeseries {
 	// Calculate medians for each point across tss.
 	medians := getPerPointMedians(tss)
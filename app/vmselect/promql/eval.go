@@ -6,14 +6,17 @@ import (
 	"math"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/memory"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/VictoriaMetrics/metricsql"
@@ -24,6 +27,9 @@ var (
 	maxPointsPerTimeseries = flag.Int("search.maxPointsPerTimeseries", 30e3, "The maximum points per a single timeseries returned from /api/v1/query_range. "+
 		"This option doesn't limit the number of scanned raw samples in the database. The main purpose of this option is to limit the number of per-series points "+
 		"returned to graphing UI such as Grafana. There is no sense in setting this limit to values bigger than the horizontal resolution of the graph")
+	maxMemoryPerQuery = flagutil.NewBytes("search.maxMemoryPerQuery", 0, "The maximum amount of memory a single query may consume for processing of the selected series. "+
+		"Queries requiring more memory are rejected. The total memory limit for concurrently executed queries can be estimated as -search.maxMemoryPerQuery "+
+		"multiplied by -search.maxConcurrentRequests. Zero value means there is no per-query limit. See also -search.maxSamplesPerQuery and -search.maxUniqueTimeseries")
 	noStaleMarkers = flag.Bool("search.noStaleMarkers", false, "Set this flag to true if the database doesn't contain Prometheus stale markers, so there is no need in spending additional CPU time on its handling. Staleness markers may exist only in data obtained from Prometheus scrape targets")
 )
 
@@ -107,6 +113,24 @@ type EvalConfig struct {
 	// EnforcedTagFilters used for apply additional label filters to query.
 	EnforcedTagFilters []storage.TagFilter
 
+	// QueryLimits may contain per-query limits overriding the corresponding command-line flags.
+	QueryLimits *searchutils.QueryLimits
+
+	// QueryStats is updated with stats collected during query execution. It may be nil.
+	QueryStats *QueryStats
+
+	// DenyPartialResponse is set to true if the query must fail when some of -search.remoteSource are unavailable.
+	DenyPartialResponse bool
+
+	// nativeHistogramComponent is the component of native histograms to select for evaluation.
+	//
+	// See nativeHistogramBuckets, nativeHistogramCount and nativeHistogramSum.
+	nativeHistogramComponent string
+
+	// isPartialResponse is shared among EvalConfig copies made during query execution.
+	// It is set to non-zero if the response doesn't contain data from some of -search.remoteSource.
+	isPartialResponse *uint32
+
 	timestamps     []int64
 	timestampsOnce sync.Once
 }
@@ -122,11 +146,29 @@ func newEvalConfig(src *EvalConfig) *EvalConfig {
 	ec.LookbackDelta = src.LookbackDelta
 	ec.RoundDigits = src.RoundDigits
 	ec.EnforcedTagFilters = src.EnforcedTagFilters
+	ec.QueryLimits = src.QueryLimits
+	ec.QueryStats = src.QueryStats
+	ec.DenyPartialResponse = src.DenyPartialResponse
+	ec.isPartialResponse = src.isPartialResponse
+	ec.nativeHistogramComponent = src.nativeHistogramComponent
 
 	// do not copy src.timestamps - they must be generated again.
 	return &ec
 }
 
+// IsPartialResponse returns true if the query results don't contain data from some of -search.remoteSource.
+//
+// It must be called after Exec returns.
+func (ec *EvalConfig) IsPartialResponse() bool {
+	return ec.isPartialResponse != nil && atomic.LoadUint32(ec.isPartialResponse) != 0
+}
+
+func (ec *EvalConfig) updateIsPartialResponse(isPartial bool) {
+	if isPartial && ec.isPartialResponse != nil {
+		atomic.StoreUint32(ec.isPartialResponse, 1)
+	}
+}
+
 func (ec *EvalConfig) validate() {
 	if ec.Start > ec.End {
 		logger.Panicf("BUG: start cannot exceed end; got %d vs %d", ec.Start, ec.End)
@@ -152,6 +194,12 @@ func (ec *EvalConfig) mayCache() bool {
 	return true
 }
 
+func (ec *EvalConfig) timeRangeString() string {
+	start := storage.TimestampToHumanReadableFormat(ec.Start)
+	end := storage.TimestampToHumanReadableFormat(ec.End)
+	return fmt.Sprintf("[%s..%s]", start, end)
+}
+
 func (ec *EvalConfig) getSharedTimestamps() []int64 {
 	ec.timestampsOnce.Do(ec.timestampsInit)
 	return ec.timestamps
@@ -183,19 +231,41 @@ func getTimestamps(start, end, step int64) []int64 {
 	return timestamps
 }
 
-func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
+func evalExpr(qt *querytracer.Tracer, ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
+	if qt.Enabled() {
+		query := e.AppendString(nil)
+		mayCache := ec.mayCache()
+		qt = qt.NewChild("eval: query=%s, timeRange=%s, step=%d, mayCache=%v", query, ec.timeRangeString(), ec.Step, mayCache)
+	}
+	rv, err := evalExprInternal(qt, ec, e)
+	if err != nil {
+		return nil, err
+	}
+	if qt.Enabled() {
+		seriesCount := len(rv)
+		pointsPerSeries := 0
+		if len(rv) > 0 {
+			pointsPerSeries = len(rv[0].Timestamps)
+		}
+		pointsCount := seriesCount * pointsPerSeries
+		qt.Donef("series=%d, points=%d, pointsPerSeries=%d", seriesCount, pointsCount, pointsPerSeries)
+	}
+	return rv, nil
+}
+
+func evalExprInternal(qt *querytracer.Tracer, ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 	if me, ok := e.(*metricsql.MetricExpr); ok {
 		re := &metricsql.RollupExpr{
 			Expr: me,
 		}
-		rv, err := evalRollupFunc(ec, "default_rollup", rollupDefault, e, re, nil)
+		rv, err := evalRollupFunc(qt, ec, "default_rollup", rollupDefault, e, re, nil)
 		if err != nil {
 			return nil, fmt.Errorf(`cannot evaluate %q: %w`, me.AppendString(nil), err)
 		}
 		return rv, nil
 	}
 	if re, ok := e.(*metricsql.RollupExpr); ok {
-		rv, err := evalRollupFunc(ec, "default_rollup", rollupDefault, e, re, nil)
+		rv, err := evalRollupFunc(qt, ec, "default_rollup", rollupDefault, e, re, nil)
 		if err != nil {
 			return nil, fmt.Errorf(`cannot evaluate %q: %w`, re.AppendString(nil), err)
 		}
@@ -204,7 +274,13 @@ func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 	if fe, ok := e.(*metricsql.FuncExpr); ok {
 		nrf := getRollupFunc(fe.Name)
 		if nrf == nil {
-			args, err := evalExprs(ec, fe.Args)
+			ecArgs := ec
+			if component, ok := getNativeHistogramComponent(fe.Name); ok && component != ec.nativeHistogramComponent {
+				// Select the needed component of native histograms for the function args.
+				ecArgs = newEvalConfig(ec)
+				ecArgs.nativeHistogramComponent = component
+			}
+			args, err := evalExprs(qt, ecArgs, fe.Args)
 			if err != nil {
 				return nil, err
 			}
@@ -217,13 +293,15 @@ func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 				fe:   fe,
 				args: args,
 			}
+			qtChild := qt.NewChild("transform %s()", fe.Name)
 			rv, err := tf(tfa)
+			qtChild.Donef("series=%d", len(rv))
 			if err != nil {
 				return nil, fmt.Errorf(`cannot evaluate %q: %w`, fe.AppendString(nil), err)
 			}
 			return rv, nil
 		}
-		args, re, err := evalRollupFuncArgs(ec, fe)
+		args, re, err := evalRollupFuncArgs(qt, ec, fe)
 		if err != nil {
 			return nil, err
 		}
@@ -231,7 +309,7 @@ func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 		if err != nil {
 			return nil, err
 		}
-		rv, err := evalRollupFunc(ec, fe.Name, rf, e, re, nil)
+		rv, err := evalRollupFunc(qt, ec, fe.Name, rf, e, re, nil)
 		if err != nil {
 			return nil, fmt.Errorf(`cannot evaluate %q: %w`, fe.AppendString(nil), err)
 		}
@@ -243,7 +321,7 @@ func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 			if fe != nil {
 				// There is an optimized path for calculating metricsql.AggrFuncExpr over rollupFunc over metricsql.MetricExpr.
 				// The optimized path saves RAM for aggregates over big number of time series.
-				args, re, err := evalRollupFuncArgs(ec, fe)
+				args, re, err := evalRollupFuncArgs(qt, ec, fe)
 				if err != nil {
 					return nil, err
 				}
@@ -252,10 +330,10 @@ func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 					return nil, err
 				}
 				iafc := newIncrementalAggrFuncContext(ae, callbacks)
-				return evalRollupFunc(ec, fe.Name, rf, e, re, iafc)
+				return evalRollupFunc(qt, ec, fe.Name, rf, e, re, iafc)
 			}
 		}
-		args, err := evalExprs(ec, ae.Args)
+		args, err := evalExprs(qt, ec, ae.Args)
 		if err != nil {
 			return nil, err
 		}
@@ -268,7 +346,9 @@ func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 			args: args,
 			ec:   ec,
 		}
+		qtChild := qt.NewChild("aggregate %s()", ae.Name)
 		rv, err := af(afa)
+		qtChild.Donef("series=%d", len(rv))
 		if err != nil {
 			return nil, fmt.Errorf(`cannot evaluate %q: %w`, ae.AppendString(nil), err)
 		}
@@ -283,11 +363,15 @@ func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 		var mu sync.Mutex
 		var wg sync.WaitGroup
 		var errGlobal error
+		// Child tracers must be created in the current goroutine, since NewChild cannot be called concurrently.
+		qtLeft := qt.NewChild("left side of %q", be.Op)
+		qtRight := qt.NewChild("right side of %q", be.Op)
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			ecCopy := newEvalConfig(ec)
-			tss, err := evalExpr(ecCopy, be.Left)
+			tss, err := evalExpr(qtLeft, ecCopy, be.Left)
+			qtLeft.Done()
 			mu.Lock()
 			if err != nil {
 				if errGlobal == nil {
@@ -301,7 +385,8 @@ func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 		go func() {
 			defer wg.Done()
 			ecCopy := newEvalConfig(ec)
-			tss, err := evalExpr(ecCopy, be.Right)
+			tss, err := evalExpr(qtRight, ecCopy, be.Right)
+			qtRight.Done()
 			mu.Lock()
 			if err != nil {
 				if errGlobal == nil {
@@ -325,7 +410,9 @@ func evalExpr(ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
 			left:  left,
 			right: right,
 		}
+		qtChild := qt.NewChild("binary op %q: left series=%d, right series=%d", be.Op, len(left), len(right))
 		rv, err := bf(bfa)
+		qtChild.Donef("series=%d", len(rv))
 		if err != nil {
 			return nil, fmt.Errorf(`cannot evaluate %q: %w`, be.AppendString(nil), err)
 		}
@@ -417,10 +504,10 @@ func tryGetArgRollupFuncWithMetricExpr(ae *metricsql.AggrFuncExpr) (*metricsql.F
 	return nil, nil
 }
 
-func evalExprs(ec *EvalConfig, es []metricsql.Expr) ([][]*timeseries, error) {
+func evalExprs(qt *querytracer.Tracer, ec *EvalConfig, es []metricsql.Expr) ([][]*timeseries, error) {
 	var rvs [][]*timeseries
 	for _, e := range es {
-		rv, err := evalExpr(ec, e)
+		rv, err := evalExpr(qt, ec, e)
 		if err != nil {
 			return nil, err
 		}
@@ -429,7 +516,7 @@ func evalExprs(ec *EvalConfig, es []metricsql.Expr) ([][]*timeseries, error) {
 	return rvs, nil
 }
 
-func evalRollupFuncArgs(ec *EvalConfig, fe *metricsql.FuncExpr) ([]interface{}, *metricsql.RollupExpr, error) {
+func evalRollupFuncArgs(qt *querytracer.Tracer, ec *EvalConfig, fe *metricsql.FuncExpr) ([]interface{}, *metricsql.RollupExpr, error) {
 	var re *metricsql.RollupExpr
 	rollupArgIdx := getRollupArgIdx(fe)
 	if len(fe.Args) <= rollupArgIdx {
@@ -442,7 +529,7 @@ func evalRollupFuncArgs(ec *EvalConfig, fe *metricsql.FuncExpr) ([]interface{},
 			args[i] = re
 			continue
 		}
-		ts, err := evalExpr(ec, arg)
+		ts, err := evalExpr(qt, ec, arg)
 		if err != nil {
 			return nil, nil, fmt.Errorf("cannot evaluate arg #%d for %q: %w", i+1, fe.AppendString(nil), err)
 		}
@@ -479,7 +566,7 @@ func getRollupExprArg(arg metricsql.Expr) *metricsql.RollupExpr {
 	return &reNew
 }
 
-func evalRollupFunc(ec *EvalConfig, funcName string, rf rollupFunc, expr metricsql.Expr, re *metricsql.RollupExpr, iafc *incrementalAggrFuncContext) ([]*timeseries, error) {
+func evalRollupFunc(qt *querytracer.Tracer, ec *EvalConfig, funcName string, rf rollupFunc, expr metricsql.Expr, re *metricsql.RollupExpr, iafc *incrementalAggrFuncContext) ([]*timeseries, error) {
 	funcName = strings.ToLower(funcName)
 	ecNew := ec
 	var offset int64
@@ -506,12 +593,12 @@ func evalRollupFunc(ec *EvalConfig, funcName string, rf rollupFunc, expr metrics
 	var rvs []*timeseries
 	var err error
 	if me, ok := re.Expr.(*metricsql.MetricExpr); ok {
-		rvs, err = evalRollupFuncWithMetricExpr(ecNew, funcName, rf, expr, me, iafc, re.Window)
+		rvs, err = evalRollupFuncWithMetricExpr(qt, ecNew, funcName, rf, expr, me, iafc, re.Window)
 	} else {
 		if iafc != nil {
 			logger.Panicf("BUG: iafc must be nil for rollup %q over subquery %q", funcName, re.AppendString(nil))
 		}
-		rvs, err = evalRollupFuncWithSubquery(ecNew, funcName, rf, expr, re)
+		rvs, err = evalRollupFuncWithSubquery(qt, ecNew, funcName, rf, expr, re)
 	}
 	if err != nil {
 		return nil, err
@@ -530,16 +617,38 @@ func evalRollupFunc(ec *EvalConfig, funcName string, rf rollupFunc, expr metrics
 	return rvs, nil
 }
 
-func evalRollupFuncWithSubquery(ec *EvalConfig, funcName string, rf rollupFunc, expr metricsql.Expr, re *metricsql.RollupExpr) ([]*timeseries, error) {
-	// TODO: determine whether to use rollupResultCacheV here.
+func evalRollupFuncWithSubquery(qt *querytracer.Tracer, ec *EvalConfig, funcName string, rf rollupFunc, expr metricsql.Expr, re *metricsql.RollupExpr) ([]*timeseries, error) {
+	qt = qt.NewChild("subquery")
+	defer qt.Done()
 	step := re.Step.Duration(ec.Step)
 	if step == 0 {
 		step = ec.Step
 	}
 	window := re.Window.Duration(ec.Step)
 
+	// Search for partial results in cache.
+	// Subquery results can be cached only if they do not depend on the subquery time range.
+	mayCache := funcName != "absent_over_time" && isSubqueryCacheable(re.Expr)
+	var tssCached []*timeseries
+	start := ec.Start
+	if mayCache {
+		tssCached, start = rollupResultCacheV.Get(qt, ec, expr, window)
+		if start > ec.End {
+			// The result is fully cached.
+			rollupResultCacheFullHits.Inc()
+			return tssCached, nil
+		}
+		if start > ec.Start {
+			rollupResultCachePartialHits.Inc()
+		} else {
+			rollupResultCacheMiss.Inc()
+		}
+	} else {
+		qt.Printf("do not use rollup cache, since the subquery results depend on the subquery time range")
+	}
+
 	ecSQ := newEvalConfig(ec)
-	ecSQ.Start -= window + maxSilenceInterval + step
+	ecSQ.Start = start - window - maxSilenceInterval - step
 	ecSQ.End += step
 	ecSQ.Step = step
 	if err := ValidateMaxPointsPerTimeseries(ecSQ.Start, ecSQ.End, ecSQ.Step); err != nil {
@@ -547,7 +656,7 @@ func evalRollupFuncWithSubquery(ec *EvalConfig, funcName string, rf rollupFunc,
 	}
 	// unconditionally align start and end args to step for subquery as Prometheus does.
 	ecSQ.Start, ecSQ.End = alignStartEnd(ecSQ.Start, ecSQ.End, ecSQ.Step)
-	tssSQ, err := evalExpr(ecSQ, re.Expr)
+	tssSQ, err := evalExpr(qt, ecSQ, re.Expr)
 	if err != nil {
 		return nil, err
 	}
@@ -556,10 +665,16 @@ func evalRollupFuncWithSubquery(ec *EvalConfig, funcName string, rf rollupFunc,
 			tss := evalNumber(ec, 1)
 			return tss, nil
 		}
-		return nil, nil
+		if len(tssCached) == 0 {
+			return nil, nil
+		}
+		// Add missing points until ec.End.
+		// Do not cache the result, since missing points
+		// may be backfilled in the future.
+		return mergeTimeseries(tssCached, nil, start, ec), nil
 	}
-	sharedTimestamps := getTimestamps(ec.Start, ec.End, ec.Step)
-	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, ec.Start, ec.End, ec.Step, window, ec.LookbackDelta, sharedTimestamps)
+	sharedTimestamps := getTimestamps(start, ec.End, ec.Step)
+	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, start, ec.End, ec.Step, window, ec.LookbackDelta, sharedTimestamps)
 	if err != nil {
 		return nil, err
 	}
@@ -569,7 +684,7 @@ func evalRollupFuncWithSubquery(ec *EvalConfig, funcName string, rf rollupFunc,
 		values, timestamps = removeNanValues(values[:0], timestamps[:0], tsSQ.Values, tsSQ.Timestamps)
 		preFunc(values, timestamps)
 		for _, rc := range rcs {
-			if tsm := newTimeseriesMap(funcName, sharedTimestamps, &tsSQ.MetricName); tsm != nil {
+			if tsm := newTimeseriesMap(funcName, rc.KeepMetricNames, sharedTimestamps, &tsSQ.MetricName); tsm != nil {
 				rc.DoTimeseriesMap(tsm, values, timestamps)
 				tssLock.Lock()
 				tss = tsm.AppendTimeseriesTo(tss)
@@ -584,9 +699,72 @@ func evalRollupFuncWithSubquery(ec *EvalConfig, funcName string, rf rollupFunc,
 		}
 		return values, timestamps
 	})
+	qt.Printf("rollup %s() over %d series returned by subquery: series=%d", funcName, len(tssSQ), len(tss))
+	if !mayCache {
+		return tss, nil
+	}
+	tss = mergeTimeseries(tssCached, tss, start, ec)
+	if !ec.IsPartialResponse() {
+		// Do not cache partial results, since the missing data may become available later.
+		rollupResultCacheV.Put(qt, ec, expr, window, tss)
+	}
 	return tss, nil
 }
 
+// isSubqueryCacheable returns true if results for the subquery e at the given timestamp
+// do not depend on the subquery time range.
+//
+// Such results may be cached in rollupResultCacheV and then merged with results
+// for the remaining time range.
+func isSubqueryCacheable(e metricsql.Expr) bool {
+	ok := true
+	metricsql.VisitAll(e, func(expr metricsql.Expr) {
+		switch t := expr.(type) {
+		case *metricsql.FuncExpr:
+			if timeRangeDependentFuncs[strings.ToLower(t.Name)] {
+				ok = false
+			}
+		case *metricsql.AggrFuncExpr:
+			name := strings.ToLower(t.Name)
+			if timeRangeDependentFuncs[name] || strings.HasPrefix(name, "topk_") || strings.HasPrefix(name, "bottomk_") {
+				ok = false
+			}
+		}
+	})
+	return ok
+}
+
+// timeRangeDependentFuncs contains functions, which results at the given timestamp depend on the time range for the query.
+var timeRangeDependentFuncs = map[string]bool{
+	// transform functions
+	"keep_last_value":    true,
+	"keep_next_value":    true,
+	"interpolate":        true,
+	"running_sum":        true,
+	"running_max":        true,
+	"running_min":        true,
+	"running_avg":        true,
+	"range_sum":          true,
+	"range_max":          true,
+	"range_min":          true,
+	"range_avg":          true,
+	"range_first":        true,
+	"range_last":         true,
+	"range_quantile":     true,
+	"smooth_exponential": true,
+	"remove_resets":      true,
+	"start":              true,
+	"end":                true,
+	"rand":               true,
+	"rand_normal":        true,
+	"rand_exponential":   true,
+
+	// aggregate functions
+	"any":       true,
+	"limitk":    true,
+	"outliersk": true,
+}
+
 func doParallel(tss []*timeseries, f func(ts *timeseries, values []float64, timestamps []int64) ([]float64, []int64)) {
 	concurrency := cgroup.AvailableCPUs()
 	if concurrency > len(tss) {
@@ -643,15 +821,22 @@ var (
 	rollupResultCacheMiss        = metrics.NewCounter(`vm_rollup_result_cache_miss_total`)
 )
 
-func evalRollupFuncWithMetricExpr(ec *EvalConfig, funcName string, rf rollupFunc,
+func evalRollupFuncWithMetricExpr(qt *querytracer.Tracer, ec *EvalConfig, funcName string, rf rollupFunc,
 	expr metricsql.Expr, me *metricsql.MetricExpr, iafc *incrementalAggrFuncContext, windowExpr *metricsql.DurationExpr) ([]*timeseries, error) {
+	var rollupMemorySize int64
+	window := windowExpr.Duration(ec.Step)
+	if qt.Enabled() {
+		qt = qt.NewChild("rollup %s(): timeRange=%s, step=%d, window=%d", funcName, ec.timeRangeString(), ec.Step, window)
+		defer func() {
+			qt.Donef("neededMemoryBytes=%d", rollupMemorySize)
+		}()
+	}
 	if me.IsEmpty() {
 		return evalNumber(ec, nan), nil
 	}
-	window := windowExpr.Duration(ec.Step)
 
 	// Search for partial results in cache.
-	tssCached, start := rollupResultCacheV.Get(ec, expr, window)
+	tssCached, start := rollupResultCacheV.Get(qt, ec, expr, window)
 	if start > ec.End {
 		// The result is fully cached.
 		rollupResultCacheFullHits.Inc()
@@ -682,11 +867,14 @@ func evalRollupFuncWithMetricExpr(ec *EvalConfig, funcName string, rf rollupFunc
 		minTimestamp -= ec.Step
 	}
 	sq := storage.NewSearchQuery(minTimestamp, ec.End, [][]storage.TagFilter{tfs})
-	rss, err := netstorage.ProcessSearchQuery(sq, true, ec.Deadline)
+	rss, err := netstorage.ProcessSearchQuery(qt, ec.DenyPartialResponse, sq, true, ec.QueryLimits, ec.Deadline)
 	if err != nil {
 		return nil, err
 	}
+	isPartial := rss.IsPartial()
+	ec.updateIsPartialResponse(isPartial)
 	rssLen := rss.Len()
+	ec.QueryStats.addSeriesFetched(rssLen)
 	if rssLen == 0 {
 		rss.Cancel()
 		var tss []*timeseries
@@ -723,7 +911,14 @@ func evalRollupFuncWithMetricExpr(ec *EvalConfig, funcName string, rf rollupFunc
 		}
 	}
 	rollupPoints := mulNoOverflow(pointsPerTimeseries, int64(timeseriesLen*len(rcs)))
-	rollupMemorySize := mulNoOverflow(rollupPoints, 16)
+	rollupMemorySize = mulNoOverflow(rollupPoints, 16)
+	if maxMemory := int64(ec.QueryLimits.GetMaxMemoryPerQuery(maxMemoryPerQuery.N)); maxMemory > 0 && rollupMemorySize > maxMemory {
+		rss.Cancel()
+		return nil, fmt.Errorf("not enough memory for processing %d data points across %d time series with %d points in each time series "+
+			"according to -search.maxMemoryPerQuery=%d; requested memory: %d bytes; "+
+			"possible solutions are: reducing the number of matching time series; increasing `step` query arg (%gs); increasing -search.maxMemoryPerQuery",
+			rollupPoints, timeseriesLen*len(rcs), pointsPerTimeseries, maxMemory, rollupMemorySize, float64(ec.Step)/1e3)
+	}
 	rml := getRollupMemoryLimiter()
 	if !rml.Get(uint64(rollupMemorySize)) {
 		rss.Cancel()
@@ -738,15 +933,18 @@ func evalRollupFuncWithMetricExpr(ec *EvalConfig, funcName string, rf rollupFunc
 	// Evaluate rollup
 	var tss []*timeseries
 	if iafc != nil {
-		tss, err = evalRollupWithIncrementalAggregate(funcName, iafc, rss, rcs, preFunc, sharedTimestamps)
+		tss, err = evalRollupWithIncrementalAggregate(qt, ec.QueryStats, funcName, ec.nativeHistogramComponent, iafc, rss, rcs, preFunc, sharedTimestamps)
 	} else {
-		tss, err = evalRollupNoIncrementalAggregate(funcName, rss, rcs, preFunc, sharedTimestamps)
+		tss, err = evalRollupNoIncrementalAggregate(qt, ec.QueryStats, funcName, ec.nativeHistogramComponent, rss, rcs, preFunc, sharedTimestamps)
 	}
 	if err != nil {
 		return nil, err
 	}
 	tss = mergeTimeseries(tssCached, tss, start, ec)
-	rollupResultCacheV.Put(ec, expr, window, tss)
+	if !isPartial {
+		// Do not cache partial results, since the missing data may become available later.
+		rollupResultCacheV.Put(qt, ec, expr, window, tss)
+	}
 	return tss, nil
 }
 
@@ -762,30 +960,32 @@ func getRollupMemoryLimiter() *memoryLimiter {
 	return &rollupMemoryLimiter
 }
 
-func evalRollupWithIncrementalAggregate(funcName string, iafc *incrementalAggrFuncContext, rss *netstorage.Results, rcs []*rollupConfig,
-	preFunc func(values []float64, timestamps []int64), sharedTimestamps []int64) ([]*timeseries, error) {
-	err := rss.RunParallel(func(rs *netstorage.Result, workerID uint) error {
-		rs.Values, rs.Timestamps = dropStaleNaNs(funcName, rs.Values, rs.Timestamps)
-		preFunc(rs.Values, rs.Timestamps)
+func evalRollupWithIncrementalAggregate(qt *querytracer.Tracer, qs *QueryStats, funcName, histogramComponent string, iafc *incrementalAggrFuncContext,
+	rss *netstorage.Results, rcs []*rollupConfig, preFunc func(values []float64, timestamps []int64), sharedTimestamps []int64) ([]*timeseries, error) {
+	err := rss.RunParallel(qt, func(rs *netstorage.Result, workerID uint) error {
+		qs.addSamplesFetched(len(rs.Values))
 		ts := getTimeseries()
 		defer putTimeseries(ts)
-		for _, rc := range rcs {
-			if tsm := newTimeseriesMap(funcName, sharedTimestamps, &rs.MetricName); tsm != nil {
-				rc.DoTimeseriesMap(tsm, rs.Values, rs.Timestamps)
-				for _, ts := range tsm.m {
-					iafc.updateTimeseries(ts, workerID)
+		return expandNativeHistograms(rs, histogramComponent, func(mn *storage.MetricName, values []float64, timestamps []int64) {
+			values, timestamps = dropStaleNaNs(funcName, values, timestamps)
+			preFunc(values, timestamps)
+			for _, rc := range rcs {
+				if tsm := newTimeseriesMap(funcName, rc.KeepMetricNames, sharedTimestamps, mn); tsm != nil {
+					rc.DoTimeseriesMap(tsm, values, timestamps)
+					for _, ts := range tsm.m {
+						iafc.updateTimeseries(ts, workerID)
+					}
+					continue
 				}
-				continue
-			}
-			ts.Reset()
-			doRollupForTimeseries(funcName, rc, ts, &rs.MetricName, rs.Values, rs.Timestamps, sharedTimestamps)
-			iafc.updateTimeseries(ts, workerID)
+				ts.Reset()
+				doRollupForTimeseries(funcName, rc, ts, mn, values, timestamps, sharedTimestamps)
+				iafc.updateTimeseries(ts, workerID)
 
-			// ts.Timestamps points to sharedTimestamps. Zero it, so it can be re-used.
-			ts.Timestamps = nil
-			ts.denyReuse = false
-		}
-		return nil
+				// ts.Timestamps points to sharedTimestamps. Zero it, so it can be re-used.
+				ts.Timestamps = nil
+				ts.denyReuse = false
+			}
+		})
 	})
 	if err != nil {
 		return nil, err
@@ -794,28 +994,30 @@ func evalRollupWithIncrementalAggregate(funcName string, iafc *incrementalAggrFu
 	return tss, nil
 }
 
-func evalRollupNoIncrementalAggregate(funcName string, rss *netstorage.Results, rcs []*rollupConfig,
+func evalRollupNoIncrementalAggregate(qt *querytracer.Tracer, qs *QueryStats, funcName, histogramComponent string, rss *netstorage.Results, rcs []*rollupConfig,
 	preFunc func(values []float64, timestamps []int64), sharedTimestamps []int64) ([]*timeseries, error) {
 	tss := make([]*timeseries, 0, rss.Len()*len(rcs))
 	var tssLock sync.Mutex
-	err := rss.RunParallel(func(rs *netstorage.Result, workerID uint) error {
-		rs.Values, rs.Timestamps = dropStaleNaNs(funcName, rs.Values, rs.Timestamps)
-		preFunc(rs.Values, rs.Timestamps)
-		for _, rc := range rcs {
-			if tsm := newTimeseriesMap(funcName, sharedTimestamps, &rs.MetricName); tsm != nil {
-				rc.DoTimeseriesMap(tsm, rs.Values, rs.Timestamps)
+	err := rss.RunParallel(qt, func(rs *netstorage.Result, workerID uint) error {
+		qs.addSamplesFetched(len(rs.Values))
+		return expandNativeHistograms(rs, histogramComponent, func(mn *storage.MetricName, values []float64, timestamps []int64) {
+			values, timestamps = dropStaleNaNs(funcName, values, timestamps)
+			preFunc(values, timestamps)
+			for _, rc := range rcs {
+				if tsm := newTimeseriesMap(funcName, rc.KeepMetricNames, sharedTimestamps, mn); tsm != nil {
+					rc.DoTimeseriesMap(tsm, values, timestamps)
+					tssLock.Lock()
+					tss = tsm.AppendTimeseriesTo(tss)
+					tssLock.Unlock()
+					continue
+				}
+				var ts timeseries
+				doRollupForTimeseries(funcName, rc, &ts, mn, values, timestamps, sharedTimestamps)
 				tssLock.Lock()
-				tss = tsm.AppendTimeseriesTo(tss)
+				tss = append(tss, &ts)
 				tssLock.Unlock()
-				continue
 			}
-			var ts timeseries
-			doRollupForTimeseries(funcName, rc, &ts, &rs.MetricName, rs.Values, rs.Timestamps, sharedTimestamps)
-			tssLock.Lock()
-			tss = append(tss, &ts)
-			tssLock.Unlock()
-		}
-		return nil
+		})
 	})
 	if err != nil {
 		return nil, err
@@ -829,7 +1031,7 @@ func doRollupForTimeseries(funcName string, rc *rollupConfig, tsDst *timeseries,
 	if len(rc.TagValue) > 0 {
 		tsDst.MetricName.AddTag("rollup", rc.TagValue)
 	}
-	if !rollupFuncsKeepMetricGroup[funcName] {
+	if !rc.KeepMetricNames && !rollupFuncsKeepMetricGroup[funcName] {
 		tsDst.MetricName.ResetMetricGroup()
 	}
 	tsDst.Values = rc.Do(tsDst.Values[:0], valuesSrc, timestampsSrc)
@@ -897,10 +1099,20 @@ func toTagFilter(dst *storage.TagFilter, src *metricsql.LabelFilter) {
 }
 
 func dropStaleNaNs(funcName string, values []float64, timestamps []int64) ([]float64, []int64) {
-	if *noStaleMarkers || funcName == "default_rollup" {
-		// Do not drop Prometheus staleness marks (aka stale NaNs) for default_rollup() function,
-		// since it uses them for Prometheus-style staleness detection.
+	if *noStaleMarkers {
+		return values, timestamps
+	}
+	switch storage.GetStaleMarkersMode() {
+	case storage.StaleMarkersSeriesEnd:
+		// Do not drop Prometheus staleness marks, since they are used as series end markers by all the rollup functions.
+		// See rollupConfig.Do.
 		return values, timestamps
+	case storage.StaleMarkersKeep:
+		if funcName == "default_rollup" {
+			// Do not drop Prometheus staleness marks (aka stale NaNs) for default_rollup() function,
+			// since it uses them for Prometheus-style staleness detection.
+			return values, timestamps
+		}
 	}
 	// Remove Prometheus staleness marks, so non-default rollup functions don't hit NaN values.
 	hasStaleSamples := false
@@ -13,6 +13,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/querystats"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/VictoriaMetrics/metricsql"
@@ -26,21 +27,26 @@ var (
 )
 
 // Exec executes q for the given ec.
-func Exec(ec *EvalConfig, q string, isFirstPointOnly bool) ([]netstorage.Result, error) {
+//
+// Execution stages are registered in qt if it is enabled.
+func Exec(qt *querytracer.Tracer, ec *EvalConfig, q string, isFirstPointOnly bool) ([]netstorage.Result, error) {
 	if querystats.Enabled() {
 		startTime := time.Now()
 		defer querystats.RegisterQuery(q, ec.End-ec.Start, startTime)
 	}
 
 	ec.validate()
+	if ec.isPartialResponse == nil {
+		ec.isPartialResponse = new(uint32)
+	}
 
-	e, err := parsePromQLWithCache(q)
+	e, err := parsePromQLWithCache(qt, q)
 	if err != nil {
 		return nil, err
 	}
 
 	qid := activeQueriesV.Add(ec, q)
-	rv, err := evalExpr(ec, e)
+	rv, err := evalExpr(qt, ec, e)
 	activeQueriesV.Remove(qid)
 	if err != nil {
 		return nil, err
@@ -59,6 +65,11 @@ func Exec(ec *EvalConfig, q string, isFirstPointOnly bool) ([]netstorage.Result,
 	if err != nil {
 		return nil, err
 	}
+	if maySort {
+		qt.Printf("sort series by metric name and labels")
+	} else {
+		qt.Printf("do not sort series by metric name and labels")
+	}
 	if n := ec.RoundDigits; n < 100 {
 		for i := range result {
 			values := result[i].Values
@@ -66,6 +77,7 @@ func Exec(ec *EvalConfig, q string, isFirstPointOnly bool) ([]netstorage.Result,
 				values[j] = decimal.RoundToDecimalDigits(v, n)
 			}
 		}
+		qt.Printf("round series values to %d decimal digits after the point", n)
 	}
 	return result, err
 }
@@ -75,14 +87,15 @@ func maySortResults(e metricsql.Expr, tss []*timeseries) bool {
 	case *metricsql.FuncExpr:
 		switch strings.ToLower(v.Name) {
 		case "sort", "sort_desc",
-			"sort_by_label", "sort_by_label_desc":
+			"sort_by_label", "sort_by_label_desc",
+			"limit_offset":
 			return false
 		}
 	case *metricsql.AggrFuncExpr:
 		switch strings.ToLower(v.Name) {
 		case "topk", "bottomk", "outliersk",
-			"topk_max", "topk_min", "topk_avg", "topk_median",
-			"bottomk_max", "bottomk_min", "bottomk_avg", "bottomk_median":
+			"topk_max", "topk_min", "topk_avg", "topk_median", "topk_last",
+			"bottomk_max", "bottomk_min", "bottomk_avg", "bottomk_median", "bottomk_last":
 			return false
 		}
 	}
@@ -219,9 +232,12 @@ func getReverseCmpOp(op string) string {
 	}
 }
 
-func parsePromQLWithCache(q string) (metricsql.Expr, error) {
+func parsePromQLWithCache(qt *querytracer.Tracer, q string) (metricsql.Expr, error) {
 	pcv := parseCacheV.Get(q)
-	if pcv == nil {
+	if pcv != nil {
+		qt.Printf("obtain the parsed query from cache")
+	} else {
+		qt = qt.NewChild("parse query")
 		e, err := metricsql.Parse(q)
 		if err == nil {
 			e = metricsql.Optimize(e)
@@ -235,6 +251,7 @@ func parsePromQLWithCache(q string) (metricsql.Expr, error) {
 			err: err,
 		}
 		parseCacheV.Put(q, pcv)
+		qt.Done()
 	}
 	if pcv.err != nil {
 		return nil, pcv.err
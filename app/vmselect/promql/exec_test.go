@@ -1,11 +1,13 @@
 package promql
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/metricsql"
 )
@@ -64,7 +66,7 @@ func TestExecSuccess(t *testing.T) {
 			RoundDigits: 100,
 		}
 		for i := 0; i < 5; i++ {
-			result, err := Exec(ec, q, false)
+			result, err := Exec(nil, ec, q, false)
 			if err != nil {
 				t.Fatalf(`unexpected error when executing %q: %s`, q, err)
 			}
@@ -212,14 +214,9 @@ func TestExecSuccess(t *testing.T) {
 	t.Run("timezone_offset(America/New_York)", func(t *testing.T) {
 		t.Parallel()
 		q := `timezone_offset("America/New_York")`
-		offset, err := getTimezoneOffset("America/New_York")
-		if err != nil {
-			t.Fatalf("cannot obtain timezone: %s", err)
-		}
-		off := float64(offset)
 		r := netstorage.Result{
 			MetricName: metricNameExpected,
-			Values:     []float64{off, off, off, off, off, off},
+			Values:     []float64{-18000, -18000, -18000, -18000, -18000, -18000},
 			Timestamps: timestampsExpected,
 		}
 		resultExpected := []netstorage.Result{r}
@@ -228,14 +225,13 @@ func TestExecSuccess(t *testing.T) {
 	t.Run("timezone_offset(Local)", func(t *testing.T) {
 		t.Parallel()
 		q := `timezone_offset("Local")`
-		offset, err := getTimezoneOffset("Local")
-		if err != nil {
-			t.Fatalf("cannot obtain timezone: %s", err)
+		var values []float64
+		for _, timestamp := range timestampsExpected {
+			values = append(values, float64(getTimezoneOffset(time.Local, timestamp)))
 		}
-		off := float64(offset)
 		r := netstorage.Result{
 			MetricName: metricNameExpected,
-			Values:     []float64{off, off, off, off, off, off},
+			Values:     values,
 			Timestamps: timestampsExpected,
 		}
 		resultExpected := []netstorage.Result{r}
@@ -2086,6 +2082,50 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r1, r2}
 		f(q, resultExpected)
 	})
+	t.Run(`limit_offset()`, func(t *testing.T) {
+		t.Parallel()
+		q := `limit_offset(1, 1, (
+			alias(1, "foo"),
+			alias(2, "bar"),
+			alias(3, "baz"),
+		))`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{3, 3, 3, 3, 3, 3},
+			Timestamps: timestampsExpected,
+		}
+		r.MetricName.MetricGroup = []byte("baz")
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`limit_offset(sort_by_label_desc())`, func(t *testing.T) {
+		t.Parallel()
+		q := `limit_offset(5, 1, sort_by_label_desc((
+			alias(1, "foo"),
+			alias(2, "bar"),
+			alias(3, "baz"),
+		), "__name__"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{3, 3, 3, 3, 3, 3},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.MetricGroup = []byte("baz")
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{2, 2, 2, 2, 2, 2},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.MetricGroup = []byte("bar")
+		resultExpected := []netstorage.Result{r1, r2}
+		f(q, resultExpected)
+	})
+	t.Run(`limit_offset(offset_exceeds_series)`, func(t *testing.T) {
+		t.Parallel()
+		q := `limit_offset(1, 2, (alias(1, "foo"), alias(2, "bar")))`
+		resultExpected := []netstorage.Result{}
+		f(q, resultExpected)
+	})
 	t.Run(`sort_by_label(multiple_labels)`, func(t *testing.T) {
 		t.Parallel()
 		q := `sort_by_label((
@@ -3468,6 +3508,42 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r1, r2}
 		f(q, resultExpected)
 	})
+	t.Run(`histogram_quantiles(multiple-groups)`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort_by_label(histogram_quantiles("phi", 0.2, 0.5, (
+			label_set(0, "foo", "bar", "le", "10"),
+			label_set(100, "foo", "bar", "le", "30"),
+			label_set(300, "foo", "bar", "le", "+Inf"),
+			label_set(50, "foo", "baz", "le", "10"),
+			label_set(100, "foo", "baz", "le", "20"),
+			label_set(100, "foo", "baz", "le", "+Inf"),
+		)), "phi", "foo")`
+		newResult := func(v float64, foo, phi string) netstorage.Result {
+			r := netstorage.Result{
+				MetricName: metricNameExpected,
+				Values:     []float64{v, v, v, v, v, v},
+				Timestamps: timestampsExpected,
+			}
+			r.MetricName.Tags = []storage.Tag{
+				{
+					Key:   []byte("foo"),
+					Value: []byte(foo),
+				},
+				{
+					Key:   []byte("phi"),
+					Value: []byte(phi),
+				},
+			}
+			return r
+		}
+		resultExpected := []netstorage.Result{
+			newResult(22, "bar", "0.2"),
+			newResult(4, "baz", "0.2"),
+			newResult(30, "bar", "0.5"),
+			newResult(10, "baz", "0.5"),
+		}
+		f(q, resultExpected)
+	})
 	t.Run(`histogram_share(normal-bucket-count)`, func(t *testing.T) {
 		t.Parallel()
 		q := `histogram_share(35,
@@ -3845,6 +3921,54 @@ func TestExecSuccess(t *testing.T) {
 		resultsExpected := []netstorage.Result{r1}
 		f(q, resultsExpected)
 	})
+	t.Run(`prometheus_buckets(zero-inf-vmrange-value)`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort(prometheus_buckets(label_set(0, "vmrange", "1...+Inf")))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{0, 0, 0, 0, 0, 0},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("le"),
+				Value: []byte("+Inf"),
+			},
+		}
+		resultsExpected := []netstorage.Result{r1}
+		f(q, resultsExpected)
+	})
+	t.Run(`prometheus_buckets(zero-last-vmrange-value)`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort(prometheus_buckets((
+			label_set(5, "vmrange", "0...1"),
+			label_set(0, "vmrange", "1...+Inf"),
+		)))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{5, 5, 5, 5, 5, 5},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("le"),
+				Value: []byte("1"),
+			},
+		}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{5, 5, 5, 5, 5, 5},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("le"),
+				Value: []byte("+Inf"),
+			},
+		}
+		resultsExpected := []netstorage.Result{r1, r2}
+		f(q, resultsExpected)
+	})
 	t.Run(`prometheus_buckets(valid)`, func(t *testing.T) {
 		t.Parallel()
 		q := `sort(prometheus_buckets((
@@ -4633,6 +4757,30 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r}
 		f(q, resultExpected)
 	})
+	t.Run(`quantiles_over_time(single-sample)`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort_by_label(quantiles_over_time("phi", 0.5, 0.9, time()[100s:100s]), "phi")`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1000, 1200, 1400, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("phi"),
+			Value: []byte("0.5"),
+		}}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1000, 1200, 1400, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("phi"),
+			Value: []byte("0.9"),
+		}}
+		resultExpected := []netstorage.Result{r1, r2}
+		f(q, resultExpected)
+	})
 	t.Run(`quantiles_over_time`, func(t *testing.T) {
 		t.Parallel()
 		q := `sort_by_label(
@@ -5291,6 +5439,45 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r1}
 		f(q, resultExpected)
 	})
+	t.Run(`topk_last(1, remaining_sum)`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort_desc(topk_last(1, label_set(10, "foo", "bar") or label_set(time()/150, "baz", "sss"), "foo=other"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{nan, nan, nan, 10.666666666666666, 12, 13.333333333333334},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("baz"),
+			Value: []byte("sss"),
+		}}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{10, 10, 10, 10, 10, 10},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("foo"),
+			Value: []byte("other"),
+		}}
+		resultExpected := []netstorage.Result{r1, r2}
+		f(q, resultExpected)
+	})
+	t.Run(`bottomk_last(1)`, func(t *testing.T) {
+		t.Parallel()
+		q := `bottomk_last(1, label_set(10, "foo", "bar") or label_set(time()/150, "baz", "sss"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{10, 10, 10, 10, 10, 10},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("foo"),
+			Value: []byte("bar"),
+		}}
+		resultExpected := []netstorage.Result{r1}
+		f(q, resultExpected)
+	})
 	t.Run(`topk(1, nan_timeseries)`, func(t *testing.T) {
 		t.Parallel()
 		q := `topk(1, label_set(NaN, "foo", "bar") or label_set(time()/150, "baz", "sss")) default 0`
@@ -5918,6 +6105,42 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r}
 		f(q, resultExpected)
 	})
+	t.Run(`rate(alias(time(),"foo")) keep_metric_names`, func(t *testing.T) {
+		t.Parallel()
+		q := `rate(alias(time(), "foo")) keep_metric_names`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1, 1, 1, 1, 1, 1},
+			Timestamps: timestampsExpected,
+		}
+		r.MetricName.MetricGroup = []byte("foo")
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`ln(alias(time(),"foo")) keep_metric_names`, func(t *testing.T) {
+		t.Parallel()
+		q := `round(ln(alias(time(), "foo")) keep_metric_names, 0.01)`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{6.91, 7.09, 7.24, 7.38, 7.5, 7.6},
+			Timestamps: timestampsExpected,
+		}
+		r.MetricName.MetricGroup = []byte("foo")
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`running_sum(alias(time(),"foo")) keep_metric_names`, func(t *testing.T) {
+		t.Parallel()
+		q := `running_sum(alias(time()/1e3, "foo")) keep_metric_names`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1, 2.2, 3.6, 5.2, 7, 9},
+			Timestamps: timestampsExpected,
+		}
+		r.MetricName.MetricGroup = []byte("foo")
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
 	t.Run(`rate(2000-time())`, func(t *testing.T) {
 		t.Parallel()
 		q := `rate(2000-time())`
@@ -6428,6 +6651,18 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r1, r2, r3, r4}
 		f(q, resultExpected)
 	})
+	t.Run(`rollup_candlestick(open)`, func(t *testing.T) {
+		t.Parallel()
+		q := `rollup_candlestick(alias(round(rand(0),0.01),"foobar")[:10s], "open")`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{0.9, 0.32, 0.82, 0.13, 0.28, 0.86},
+			Timestamps: timestampsExpected,
+		}
+		r.MetricName.MetricGroup = []byte("foobar")
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
 	t.Run(`rollup_increase()`, func(t *testing.T) {
 		t.Parallel()
 		q := `sort(rollup_increase(time()))`
@@ -6494,6 +6729,17 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r1, r2, r3}
 		f(q, resultExpected)
 	})
+	t.Run(`rollup(max)`, func(t *testing.T) {
+		t.Parallel()
+		q := `rollup(time()[:50s], "max")`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1000, 1200, 1400, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
 	t.Run(`rollup_deriv()`, func(t *testing.T) {
 		t.Parallel()
 		q := `sort(rollup_deriv(time()[100s:50s]))`
@@ -7060,14 +7306,14 @@ func TestExecError(t *testing.T) {
 			RoundDigits: 100,
 		}
 		for i := 0; i < 4; i++ {
-			rv, err := Exec(ec, q, false)
+			rv, err := Exec(nil, ec, q, false)
 			if err == nil {
 				t.Fatalf(`expecting non-nil error on %q`, q)
 			}
 			if rv != nil {
 				t.Fatalf(`expecting nil rv`)
 			}
-			rv, err = Exec(ec, q, true)
+			rv, err = Exec(nil, ec, q, true)
 			if err == nil {
 				t.Fatalf(`expecting non-nil error on %q`, q)
 			}
@@ -7137,6 +7383,8 @@ func TestExecError(t *testing.T) {
 	f(`bottomk_max()`)
 	f(`bottomk_avg()`)
 	f(`bottomk_median()`)
+	f(`topk_last()`)
+	f(`bottomk_last()`)
 	f(`time(123)`)
 	f(`start(1)`)
 	f(`end(1)`)
@@ -7195,6 +7443,9 @@ func TestExecError(t *testing.T) {
 	f(`prometheus_buckets()`)
 	f(`buckets_limit()`)
 	f(`buckets_limit(1)`)
+	f(`limit_offset()`)
+	f(`limit_offset(1)`)
+	f(`limit_offset(1, 2)`)
 	f(`share_le_over_time()`)
 	f(`share_gt_over_time()`)
 	f(`count_le_over_time()`)
@@ -7247,6 +7498,10 @@ func TestExecError(t *testing.T) {
 	f(`alias(1, 2)`)
 	f(`aggr_over_time(1, 2)`)
 	f(`aggr_over_time(("foo", "bar"), 3)`)
+	f(`rollup(time(), "invalid")`)
+	f(`rollup(time(), 123)`)
+	f(`rollup_candlestick(time(), "max")`)
+	f(`rollup(time(), "min", "max")`)
 	f(`outliersk((label_set(1, "foo", "bar"), label_set(2, "x", "y")), 123)`)
 
 	// Duplicate timeseries
@@ -7337,3 +7592,109 @@ func testAddLabels(t *testing.T, mn *storage.MetricName, labels ...string) {
 		})
 	}
 }
+
+func TestExecWithQueryTracing(t *testing.T) {
+	ec := &EvalConfig{
+		Start:       1000,
+		End:         2000,
+		Step:        200,
+		Deadline:    searchutils.NewDeadline(time.Now(), time.Minute, ""),
+		RoundDigits: 3,
+	}
+	q := `sum(label_set(time(), "foo", "bar")) / abs(-2)`
+	qt := querytracer.New(true, "test query")
+	result, err := Exec(qt, ec, q, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	qt.Done()
+	if len(result) != 1 {
+		t.Fatalf("unexpected number of series; got %d; want 1", len(result))
+	}
+	trace := qt.String()
+	for _, s := range []string{
+		"test query",
+		`eval: query=sum(label_set(time(), "foo", "bar")) / abs(-2), timeRange=[1970-01-01T00:00:01Z..1970-01-01T00:00:02Z], step=200`,
+		`left side of "/"`,
+		`right side of "/"`,
+		`aggregate sum(): series=1`,
+		`transform label_set(): series=1`,
+		`binary op "/": left series=1, right series=1`,
+		"series=1, points=6, pointsPerSeries=6",
+		"round series values to 3 decimal digits after the point",
+	} {
+		if !strings.Contains(trace, s) {
+			t.Fatalf("missing %q in the trace:\n%s", s, trace)
+		}
+	}
+}
+
+func TestExecSubqueryWithCache(t *testing.T) {
+	ResetRollupResultCache()
+	f := func(q string, mayCache bool) {
+		t.Helper()
+		newEC := func(start, end int64, mayCache bool) *EvalConfig {
+			return &EvalConfig{
+				Start:       start,
+				End:         end,
+				Step:        200e3,
+				Deadline:    searchutils.NewDeadline(time.Now(), time.Minute, ""),
+				MayCache:    mayCache,
+				RoundDigits: 100,
+			}
+		}
+		resultExpected, err := Exec(nil, newEC(1000e3, 3000e3, false), q, false)
+		if err != nil {
+			t.Fatalf("unexpected error when executing %q without cache: %s", q, err)
+		}
+
+		// Populate the cache with results for the first part of the time range.
+		if _, err := Exec(nil, newEC(1000e3, 2000e3, true), q, false); err != nil {
+			t.Fatalf("unexpected error when executing %q on the first part of the time range: %s", q, err)
+		}
+
+		// Verify that the results for the full time range are properly merged with the cached results.
+		partialHits := rollupResultCachePartialHits.Get()
+		result, err := Exec(nil, newEC(1000e3, 3000e3, true), q, false)
+		if err != nil {
+			t.Fatalf("unexpected error when executing %q with cache: %s", q, err)
+		}
+		testResultsEqual(t, result, resultExpected)
+		hasPartialHits := rollupResultCachePartialHits.Get() > partialHits
+		if hasPartialHits != mayCache {
+			t.Fatalf("unexpected partial cache hits for %q; got %v; want %v", q, hasPartialHits, mayCache)
+		}
+	}
+	f(`max_over_time((time()*2)[600s:100s])`, true)
+	f(`sum_over_time(sum(label_set(time(), "foo", "bar"))[1000s:300s])`, true)
+	f(`max_over_time(rate((time()/10)[300s:100s])[1000s:200s])`, true)
+	f(`max_over_time(running_sum(time())[600s:100s])`, false)
+	f(`min_over_time(topk_max(1, time())[600s:100s])`, false)
+}
+
+func TestIsSubqueryCacheable(t *testing.T) {
+	f := func(q string, resultExpected bool) {
+		t.Helper()
+		e, err := metricsql.Parse(q)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", q, err)
+		}
+		result := isSubqueryCacheable(e)
+		if result != resultExpected {
+			t.Fatalf("unexpected isSubqueryCacheable(%q); got %v; want %v", q, result, resultExpected)
+		}
+	}
+	f(`foo`, true)
+	f(`rate(http_errors_total[5m])`, true)
+	f(`sum(rate(foo[5m])) by (job) / 2`, true)
+	f(`max_over_time(rate(foo[5m])[1h:1m])`, true)
+	f(`label_set(abs(foo), "a", "b")`, true)
+	f(`running_sum(foo)`, false)
+	f(`RANGE_AVG(foo)`, false)
+	f(`sum(keep_last_value(foo))`, false)
+	f(`max_over_time(remove_resets(foo)[1h:1m])`, false)
+	f(`foo - start()`, false)
+	f(`topk_avg(3, foo)`, false)
+	f(`bottomk_max(3, foo)`, false)
+	f(`limitk(3, foo)`, false)
+}
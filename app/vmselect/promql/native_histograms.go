@@ -0,0 +1,152 @@
+package promql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// Components of native histograms, which may be selected for evaluation via EvalConfig.nativeHistogramComponent.
+const (
+	// nativeHistogramBuckets selects `vmrange` buckets from native histograms.
+	//
+	// This is the default component, so native histograms may be used in the same way as VictoriaMetrics histograms.
+	nativeHistogramBuckets = ""
+
+	// nativeHistogramCount selects the number of observations from native histograms.
+	nativeHistogramCount = "count"
+
+	// nativeHistogramSum selects the sum of observations from native histograms.
+	nativeHistogramSum = "sum"
+)
+
+// getNativeHistogramComponent returns native histogram component for args of the given transform function.
+//
+// false is returned if the function args must be evaluated with the component from the parent expression.
+func getNativeHistogramComponent(funcName string) (string, bool) {
+	switch strings.ToLower(funcName) {
+	case "histogram_count":
+		return nativeHistogramCount, true
+	case "histogram_sum":
+		return nativeHistogramSum, true
+	default:
+		return "", false
+	}
+}
+
+// expandNativeHistograms calls f for every time series obtained from rs for the given native histogram component.
+//
+// f is called for rs contents if rs doesn't contain native histograms and the component is nativeHistogramBuckets.
+// Time series without native histograms are skipped for other components.
+//
+// f may modify values, but it mustn't hold references to mn, values and timestamps after returning.
+func expandNativeHistograms(rs *netstorage.Result, component string, f func(mn *storage.MetricName, values []float64, timestamps []int64)) error {
+	if rs.Histograms == nil {
+		if component == nativeHistogramBuckets {
+			f(&rs.MetricName, rs.Values, rs.Timestamps)
+		}
+		return nil
+	}
+	switch component {
+	case nativeHistogramCount, nativeHistogramSum:
+		return expandNativeHistogramsValue(rs, component, f)
+	case nativeHistogramBuckets:
+		return expandNativeHistogramsBuckets(rs, f)
+	default:
+		return fmt.Errorf("BUG: unexpected native histogram component: %q", component)
+	}
+}
+
+func expandNativeHistogramsValue(rs *netstorage.Result, component string, f func(mn *storage.MetricName, values []float64, timestamps []int64)) error {
+	var h prompb.Histogram
+	values := make([]float64, 0, len(rs.Values))
+	timestamps := make([]int64, 0, len(rs.Timestamps))
+	for i, data := range rs.Histograms {
+		v := rs.Values[i]
+		if len(data) == 0 {
+			if !decimal.IsStaleNaN(v) {
+				// Skip ordinary samples.
+				continue
+			}
+		} else if component == nativeHistogramSum {
+			if err := h.UnmarshalCompact(data); err != nil {
+				return fmt.Errorf("cannot unmarshal native histogram for %s: %w", &rs.MetricName, err)
+			}
+			v = h.Sum
+		}
+		// The number of observations is stored in rs.Values.
+		values = append(values, v)
+		timestamps = append(timestamps, rs.Timestamps[i])
+	}
+	if len(values) > 0 {
+		f(&rs.MetricName, values, timestamps)
+	}
+	return nil
+}
+
+func expandNativeHistogramsBuckets(rs *netstorage.Result, f func(mn *storage.MetricName, values []float64, timestamps []int64)) error {
+	var h prompb.Histogram
+	var buckets []prompb.VMRangeBucket
+	var timestamps []int64
+	var rowBucketsEnds []int
+	var isStale []bool
+	for i, data := range rs.Histograms {
+		if len(data) == 0 {
+			if !decimal.IsStaleNaN(rs.Values[i]) {
+				// Skip ordinary samples.
+				continue
+			}
+			// Staleness marker is propagated to all the buckets.
+			isStale = append(isStale, true)
+		} else {
+			if err := h.UnmarshalCompact(data); err != nil {
+				return fmt.Errorf("cannot unmarshal native histogram for %s: %w", &rs.MetricName, err)
+			}
+			var err error
+			buckets, err = h.AppendVMRangeBuckets(buckets)
+			if err != nil {
+				return fmt.Errorf("cannot obtain buckets for native histogram %s: %w", &rs.MetricName, err)
+			}
+			isStale = append(isStale, false)
+		}
+		timestamps = append(timestamps, rs.Timestamps[i])
+		rowBucketsEnds = append(rowBucketsEnds, len(buckets))
+	}
+
+	// Collect buckets across all the histograms, since they may have distinct bucket layouts.
+	// Missing buckets have zero counts.
+	bucketValues := make(map[string][]float64)
+	var vmranges []string
+	start := 0
+	for row, end := range rowBucketsEnds {
+		for _, b := range buckets[start:end] {
+			vmrange := b.VMRange()
+			values := bucketValues[vmrange]
+			if values == nil {
+				values = make([]float64, len(timestamps))
+				for i := range values {
+					if isStale[i] {
+						values[i] = decimal.StaleNaN
+					}
+				}
+				bucketValues[vmrange] = values
+				vmranges = append(vmranges, vmrange)
+			}
+			values[row] = b.Count
+		}
+		start = end
+	}
+
+	var mn storage.MetricName
+	for _, vmrange := range vmranges {
+		mn.CopyFrom(&rs.MetricName)
+		mn.AddTag("vmrange", vmrange)
+		// Pass a copy of timestamps, since f may modify them.
+		f(&mn, bucketValues[vmrange], append([]int64{}, timestamps...))
+	}
+	return nil
+}
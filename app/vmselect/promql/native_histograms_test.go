@@ -0,0 +1,78 @@
+package promql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestExpandNativeHistograms(t *testing.T) {
+	h1 := &prompb.Histogram{
+		Count:          3,
+		Sum:            10,
+		PositiveSpans:  []prompb.BucketSpan{{Offset: 1, Length: 1}},
+		PositiveDeltas: []int64{3},
+	}
+	h2 := &prompb.Histogram{
+		Count:          5,
+		Sum:            12,
+		PositiveSpans:  []prompb.BucketSpan{{Offset: 1, Length: 2}},
+		PositiveDeltas: []int64{4, -3},
+	}
+	var rs netstorage.Result
+	rs.MetricName.MetricGroup = []byte("foo")
+	rs.Timestamps = []int64{1000, 2000, 3000, 4000}
+	rs.Values = []float64{3, 42, decimal.StaleNaN, 5}
+	rs.Histograms = [][]byte{h1.MarshalCompact(nil), nil, nil, h2.MarshalCompact(nil)}
+
+	f := func(rs *netstorage.Result, component string, resultExpected []string) {
+		t.Helper()
+		var result []string
+		err := expandNativeHistograms(rs, component, func(mn *storage.MetricName, values []float64, timestamps []int64) {
+			var a []string
+			for i, v := range values {
+				if decimal.IsStaleNaN(v) {
+					a = append(a, fmt.Sprintf("stale@%d", timestamps[i]))
+					continue
+				}
+				a = append(a, fmt.Sprintf("%g@%d", v, timestamps[i]))
+			}
+			result = append(result, mn.String()+" "+strings.Join(a, " "))
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", strings.Join(result, "\n"), strings.Join(resultExpected, "\n"))
+		}
+	}
+
+	// Ordinary samples are skipped, while staleness markers are propagated to all the buckets.
+	f(&rs, nativeHistogramBuckets, []string{
+		`foo{vmrange="1...2"} 3@1000 stale@3000 4@4000`,
+		`foo{vmrange="2...4"} 0@1000 stale@3000 1@4000`,
+	})
+	f(&rs, nativeHistogramCount, []string{
+		`foo{} 3@1000 stale@3000 5@4000`,
+	})
+	f(&rs, nativeHistogramSum, []string{
+		`foo{} 10@1000 stale@3000 12@4000`,
+	})
+
+	// Time series without native histograms are returned as is only for buckets.
+	var rsFloat netstorage.Result
+	rsFloat.MetricName.MetricGroup = []byte("bar")
+	rsFloat.Timestamps = []int64{1000}
+	rsFloat.Values = []float64{1}
+	f(&rsFloat, nativeHistogramBuckets, []string{
+		`bar{} 1@1000`,
+	})
+	f(&rsFloat, nativeHistogramCount, nil)
+	f(&rsFloat, nativeHistogramSum, nil)
+}
@@ -11,7 +11,7 @@ import (
 //
 // It returns the wrapped query with the corresponding window, step and offset.
 func IsRollup(s string) (childQuery string, window, step, offset *metricsql.DurationExpr) {
-	expr, err := parsePromQLWithCache(s)
+	expr, err := parsePromQLWithCache(nil, s)
 	if err != nil {
 		return
 	}
@@ -28,7 +28,7 @@ func IsRollup(s string) (childQuery string, window, step, offset *metricsql.Dura
 //
 // It returns the wrapped query with the corresponding window with offset.
 func IsMetricSelectorWithRollup(s string) (childQuery string, window, offset *metricsql.DurationExpr) {
-	expr, err := parsePromQLWithCache(s)
+	expr, err := parsePromQLWithCache(nil, s)
 	if err != nil {
 		return
 	}
@@ -47,7 +47,7 @@ func IsMetricSelectorWithRollup(s string) (childQuery string, window, offset *me
 // ParseMetricSelector parses s containing PromQL metric selector
 // and returns the corresponding LabelFilters.
 func ParseMetricSelector(s string) ([]storage.TagFilter, error) {
-	expr, err := parsePromQLWithCache(s)
+	expr, err := parsePromQLWithCache(nil, s)
 	if err != nil {
 		return nil, err
 	}
@@ -61,3 +61,23 @@ func ParseMetricSelector(s string) ([]storage.TagFilter, error) {
 	tfs := toTagFilters(me.LabelFilters)
 	return tfs, nil
 }
+
+// ParseMetricSelectors parses PromQL query s and returns LabelFilters for all the metric selectors in it.
+func ParseMetricSelectors(s string) ([][]storage.TagFilter, error) {
+	expr, err := parsePromQLWithCache(nil, s)
+	if err != nil {
+		return nil, err
+	}
+	var tfss [][]storage.TagFilter
+	metricsql.VisitAll(expr, func(expr metricsql.Expr) {
+		me, ok := expr.(*metricsql.MetricExpr)
+		if !ok || len(me.LabelFilters) == 0 {
+			return
+		}
+		tfss = append(tfss, toTagFilters(me.LabelFilters))
+	})
+	if len(tfss) == 0 {
+		return nil, fmt.Errorf("expecting at least a single metric selector in %q", s)
+	}
+	return tfss, nil
+}
@@ -48,3 +48,29 @@ func TestParseMetricSelectorError(t *testing.T) {
 	f(`foo[5m]`)
 	f(`foo offset 5m`)
 }
+
+func TestParseMetricSelectors(t *testing.T) {
+	f := func(s string, selectorsExpected int) {
+		t.Helper()
+		tfss, err := ParseMetricSelectors(s)
+		if selectorsExpected == 0 {
+			if err == nil {
+				t.Fatalf("expecting non-nil error when parsing %q", s)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if len(tfss) != selectorsExpected {
+			t.Fatalf("unexpected number of selectors for %q; got %d; want %d", s, len(tfss), selectorsExpected)
+		}
+	}
+	f(`foo`, 1)
+	f(`rate(foo{bar="baz"}[5m])`, 1)
+	f(`histogram_quantile(0.9, sum(rate(foo_bucket[5m])) by (le)) / bar`, 2)
+	f(`foo + bar{x="y"} or baz`, 3)
+	f(``, 0)
+	f(`foo{`, 0)
+	f(`1 + 2`, 0)
+}
@@ -0,0 +1,30 @@
+package promql
+
+import (
+	"sync/atomic"
+)
+
+// QueryStats contains stats collected during query execution.
+//
+// It may be passed to Exec via EvalConfig.QueryStats and then read after Exec returns.
+type QueryStats struct {
+	// SeriesFetched is the number of series fetched from the storage.
+	SeriesFetched int64
+
+	// SamplesFetched is the number of raw samples fetched from the storage.
+	SamplesFetched int64
+}
+
+func (qs *QueryStats) addSeriesFetched(n int) {
+	if qs == nil {
+		return
+	}
+	atomic.AddInt64(&qs.SeriesFetched, int64(n))
+}
+
+func (qs *QueryStats) addSamplesFetched(n int) {
+	if qs == nil {
+		return
+	}
+	atomic.AddInt64(&qs.SamplesFetched, int64(n))
+}
@@ -71,12 +71,12 @@ var rollupFuncs = map[string]newRollupFunc{
 	"count_eq_over_time":    newRollupCountEQ,
 	"count_ne_over_time":    newRollupCountNE,
 	"histogram_over_time":   newRollupFuncOneArg(rollupHistogram),
-	"rollup":                newRollupFuncOneArg(rollupFake),
-	"rollup_rate":           newRollupFuncOneArg(rollupFake), // + rollupFuncsRemoveCounterResets
-	"rollup_deriv":          newRollupFuncOneArg(rollupFake),
-	"rollup_delta":          newRollupFuncOneArg(rollupFake),
-	"rollup_increase":       newRollupFuncOneArg(rollupFake), // + rollupFuncsRemoveCounterResets
-	"rollup_candlestick":    newRollupFuncOneArg(rollupFake),
+	"rollup":                newRollupFuncOneOrTwoArgs(rollupFake),
+	"rollup_rate":           newRollupFuncOneOrTwoArgs(rollupFake), // + rollupFuncsRemoveCounterResets
+	"rollup_deriv":          newRollupFuncOneOrTwoArgs(rollupFake),
+	"rollup_delta":          newRollupFuncOneOrTwoArgs(rollupFake),
+	"rollup_increase":       newRollupFuncOneOrTwoArgs(rollupFake), // + rollupFuncsRemoveCounterResets
+	"rollup_candlestick":    newRollupFuncOneOrTwoArgs(rollupFake),
 	"aggr_over_time":        newRollupFuncTwoArgs(rollupFake),
 	"hoeffding_bound_upper": newRollupHoeffdingBoundUpper,
 	"hoeffding_bound_lower": newRollupHoeffdingBoundLower,
@@ -84,6 +84,7 @@ var rollupFuncs = map[string]newRollupFunc{
 	"descent_over_time":     newRollupFuncOneArg(rollupDescentOverTime),
 	"zscore_over_time":      newRollupFuncOneArg(rollupZScoreOverTime),
 	"quantiles_over_time":   newRollupQuantiles,
+	"mad_over_time":         newRollupFuncOneArg(rollupMAD),
 
 	// `timestamp` function must return timestamp for the last datapoint on the current window
 	// in order to properly handle offset and timestamps unaligned to the current step.
@@ -140,6 +141,7 @@ var rollupAggrFuncs = map[string]rollupFunc{
 	"ascent_over_time":    rollupAscentOverTime,
 	"descent_over_time":   rollupDescentOverTime,
 	"zscore_over_time":    rollupZScoreOverTime,
+	"mad_over_time":       rollupMAD,
 	"timestamp":           rollupTlast,
 	"mode_over_time":      rollupModeOverTime,
 	"rate_over_sum":       rollupRateOverSum,
@@ -172,6 +174,7 @@ var rollupFuncsCannotAdjustWindow = map[string]bool{
 	"ascent_over_time":    true,
 	"descent_over_time":   true,
 	"zscore_over_time":    true,
+	"mad_over_time":       true,
 	"first_over_time":     true,
 	"last_over_time":      true,
 }
@@ -253,6 +256,36 @@ func getRollupAggrFuncNames(expr metricsql.Expr) ([]string, error) {
 	return aggrFuncNames, nil
 }
 
+// getRollupTag returns the optional second arg for rollup*() functions such as `rollup_candlestick(m[d], "open")`.
+//
+// An empty string is returned if the arg is missing.
+func getRollupTag(expr metricsql.Expr) (string, error) {
+	afe, ok := expr.(*metricsql.AggrFuncExpr)
+	if ok {
+		// This is for incremental aggregate function case:
+		//
+		//     sum(rollup(...))
+		//
+		// See aggr_incremental.go for details.
+		expr = afe.Args[0]
+	}
+	fe, ok := expr.(*metricsql.FuncExpr)
+	if !ok {
+		logger.Panicf("BUG: unexpected expression; want metricsql.FuncExpr; got %T; value: %s", expr, expr.AppendString(nil))
+	}
+	if len(fe.Args) < 2 {
+		return "", nil
+	}
+	if len(fe.Args) != 2 {
+		return "", fmt.Errorf("unexpected number of args for %s(); got %d; want 1 or 2", fe.Name, len(fe.Args))
+	}
+	se, ok := fe.Args[1].(*metricsql.StringExpr)
+	if !ok {
+		return "", fmt.Errorf("unexpected second arg for %s(); got %s; want quoted string", fe.Name, fe.Args[1].AppendString(nil))
+	}
+	return se.S, nil
+}
+
 func getRollupArgIdx(fe *metricsql.FuncExpr) int {
 	funcName := strings.ToLower(fe.Name)
 	if rollupFuncs[funcName] == nil {
@@ -288,38 +321,83 @@ func getRollupConfigs(name string, rf rollupFunc, expr metricsql.Expr, start, en
 			MayAdjustWindow: !rollupFuncsCannotAdjustWindow[name],
 			LookbackDelta:   lookbackDelta,
 			Timestamps:      sharedTimestamps,
+			KeepMetricNames: getKeepMetricNames(expr),
 			isDefaultRollup: name == "default_rollup",
 		}
 	}
-	appendRollupConfigs := func(dst []*rollupConfig) []*rollupConfig {
-		dst = append(dst, newRollupConfig(rollupMin, "min"))
-		dst = append(dst, newRollupConfig(rollupMax, "max"))
-		dst = append(dst, newRollupConfig(rollupAvg, "avg"))
-		return dst
+	appendRollupConfigs := func(dst []*rollupConfig, expr metricsql.Expr) ([]*rollupConfig, error) {
+		tag, err := getRollupTag(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid args to %s: %w", expr.AppendString(nil), err)
+		}
+		switch tag {
+		case "min":
+			dst = append(dst, newRollupConfig(rollupMin, ""))
+		case "max":
+			dst = append(dst, newRollupConfig(rollupMax, ""))
+		case "avg":
+			dst = append(dst, newRollupConfig(rollupAvg, ""))
+		case "":
+			dst = append(dst, newRollupConfig(rollupMin, "min"))
+			dst = append(dst, newRollupConfig(rollupMax, "max"))
+			dst = append(dst, newRollupConfig(rollupAvg, "avg"))
+		default:
+			return nil, fmt.Errorf("unexpected rollup tag value %q; wanted min, max or avg", tag)
+		}
+		return dst, nil
 	}
 	var rcs []*rollupConfig
 	switch name {
 	case "rollup":
-		rcs = appendRollupConfigs(rcs)
+		var err error
+		rcs, err = appendRollupConfigs(rcs, expr)
+		if err != nil {
+			return nil, nil, err
+		}
 	case "rollup_rate", "rollup_deriv":
 		preFuncPrev := preFunc
 		preFunc = func(values []float64, timestamps []int64) {
 			preFuncPrev(values, timestamps)
 			derivValues(values, timestamps)
 		}
-		rcs = appendRollupConfigs(rcs)
+		var err error
+		rcs, err = appendRollupConfigs(rcs, expr)
+		if err != nil {
+			return nil, nil, err
+		}
 	case "rollup_increase", "rollup_delta":
 		preFuncPrev := preFunc
 		preFunc = func(values []float64, timestamps []int64) {
 			preFuncPrev(values, timestamps)
 			deltaValues(values)
 		}
-		rcs = appendRollupConfigs(rcs)
+		var err error
+		rcs, err = appendRollupConfigs(rcs, expr)
+		if err != nil {
+			return nil, nil, err
+		}
 	case "rollup_candlestick":
-		rcs = append(rcs, newRollupConfig(rollupOpen, "open"))
-		rcs = append(rcs, newRollupConfig(rollupClose, "close"))
-		rcs = append(rcs, newRollupConfig(rollupLow, "low"))
-		rcs = append(rcs, newRollupConfig(rollupHigh, "high"))
+		tag, err := getRollupTag(expr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid args to %s: %w", expr.AppendString(nil), err)
+		}
+		switch tag {
+		case "open":
+			rcs = append(rcs, newRollupConfig(rollupOpen, ""))
+		case "close":
+			rcs = append(rcs, newRollupConfig(rollupClose, ""))
+		case "low":
+			rcs = append(rcs, newRollupConfig(rollupLow, ""))
+		case "high":
+			rcs = append(rcs, newRollupConfig(rollupHigh, ""))
+		case "":
+			rcs = append(rcs, newRollupConfig(rollupOpen, "open"))
+			rcs = append(rcs, newRollupConfig(rollupClose, "close"))
+			rcs = append(rcs, newRollupConfig(rollupLow, "low"))
+			rcs = append(rcs, newRollupConfig(rollupHigh, "high"))
+		default:
+			return nil, nil, fmt.Errorf("unexpected rollup tag value %q; wanted open, close, low or high", tag)
+		}
 	case "aggr_over_time":
 		aggrFuncNames, err := getRollupAggrFuncNames(expr)
 		if err != nil {
@@ -393,6 +471,23 @@ func (rfa *rollupFuncArg) reset() {
 // prevValue may be nan, values and timestamps may be empty.
 type rollupFunc func(rfa *rollupFuncArg) float64
 
+// getKeepMetricNames returns true if `keep_metric_names` modifier is set for the rollup function in expr.
+func getKeepMetricNames(expr metricsql.Expr) bool {
+	if ae, ok := expr.(*metricsql.AggrFuncExpr); ok {
+		// Extract rollupFunc(...) from aggrFunc(rollupFunc(...)).
+		// This case is possible when optimized aggrFunc calculations are used
+		// such as `sum(rate(...))`
+		if len(ae.Args) != 1 {
+			return false
+		}
+		expr = ae.Args[0]
+	}
+	if fe, ok := expr.(*metricsql.FuncExpr); ok {
+		return fe.KeepMetricNames
+	}
+	return false
+}
+
 type rollupConfig struct {
 	// This tag value must be added to "rollup" tag if non-empty.
 	TagValue string
@@ -415,6 +510,9 @@ type rollupConfig struct {
 	// LoookbackDelta is the analog to `-query.lookback-delta` from Prometheus world.
 	LookbackDelta int64
 
+	// Whether to keep metric names in the results. It is set via `keep_metric_names` modifier.
+	KeepMetricNames bool
+
 	// Whether default_rollup is used.
 	isDefaultRollup bool
 }
@@ -433,7 +531,7 @@ type timeseriesMap struct {
 	m      map[string]*timeseries
 }
 
-func newTimeseriesMap(funcName string, sharedTimestamps []int64, mnSrc *storage.MetricName) *timeseriesMap {
+func newTimeseriesMap(funcName string, keepMetricNames bool, sharedTimestamps []int64, mnSrc *storage.MetricName) *timeseriesMap {
 	funcName = strings.ToLower(funcName)
 	switch funcName {
 	case "histogram_over_time", "quantiles_over_time":
@@ -447,7 +545,7 @@ func newTimeseriesMap(funcName string, sharedTimestamps []int64, mnSrc *storage.
 	}
 	var origin timeseries
 	origin.MetricName.CopyFrom(mnSrc)
-	if !rollupFuncsKeepMetricGroup[funcName] {
+	if !keepMetricNames && !rollupFuncsKeepMetricGroup[funcName] {
 		origin.MetricName.ResetMetricGroup()
 	}
 	origin.Timestamps = sharedTimestamps
@@ -541,6 +639,7 @@ func (rc *rollupConfig) doInternal(dstValues []float64, tsm *timeseriesMap, valu
 	rfa.idx = 0
 	rfa.window = window
 	rfa.tsm = tsm
+	seriesEnd := storage.GetStaleMarkersMode() == storage.StaleMarkersSeriesEnd && hasStaleNaNs(values)
 
 	i := 0
 	j := 0
@@ -576,7 +675,10 @@ func (rc *rollupConfig) doInternal(dstValues []float64, tsm *timeseriesMap, valu
 			rfa.realNextValue = nan
 		}
 		rfa.currTimestamp = tEnd
-		value := f(rfa)
+		value := nan
+		if !seriesEnd || trimSeriesEnd(rfa) {
+			value = f(rfa)
+		}
 		rfa.idx++
 		dstValues = append(dstValues, value)
 	}
@@ -585,6 +687,45 @@ func (rc *rollupConfig) doInternal(dstValues []float64, tsm *timeseriesMap, valu
 	return dstValues
 }
 
+func hasStaleNaNs(values []float64) bool {
+	for _, v := range values {
+		if decimal.IsStaleNaN(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimSeriesEnd removes samples before the last series end marker from rfa according to -storage.staleMarkers=seriesEnd.
+//
+// It returns false if the series ends at the last sample on the window, so rfa has no value.
+func trimSeriesEnd(rfa *rollupFuncArg) bool {
+	values := rfa.values
+	n := len(values)
+	for n > 0 && !decimal.IsStaleNaN(values[n-1]) {
+		n--
+	}
+	if n > 0 {
+		if n == len(values) {
+			return false
+		}
+		// The series has been re-started after the series end marker on the window.
+		rfa.values = values[n:]
+		rfa.timestamps = rfa.timestamps[n:]
+		rfa.prevValue = nan
+		rfa.realPrevValue = nan
+		return true
+	}
+	if decimal.IsStaleNaN(rfa.realPrevValue) {
+		rfa.prevValue = nan
+		rfa.realPrevValue = nan
+	}
+	if decimal.IsStaleNaN(rfa.realNextValue) {
+		rfa.realNextValue = nan
+	}
+	return true
+}
+
 func seekFirstTimestampIdxAfter(timestamps []int64, seekTimestamp int64, nHint int) int {
 	if len(timestamps) == 0 || timestamps[0] > seekTimestamp {
 		return 0
@@ -759,6 +900,15 @@ func newRollupFuncOneArg(rf rollupFunc) newRollupFunc {
 	}
 }
 
+func newRollupFuncOneOrTwoArgs(rf rollupFunc) newRollupFunc {
+	return func(args []interface{}) (rollupFunc, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("unexpected number of args; got %d; want 1...2", len(args))
+		}
+		return rf, nil
+	}
+}
+
 func newRollupFuncTwoArgs(rf rollupFunc) newRollupFunc {
 	return func(args []interface{}) (rollupFunc, error) {
 		if err := expectRollupArgsNum(args, 2); err != nil {
@@ -1059,12 +1209,20 @@ func newRollupQuantiles(args []interface{}) (rollupFunc, error) {
 		// There is no need in handling NaNs here, since they must be cleaned up
 		// before calling rollup funcs.
 		values := rfa.values
-		if len(values) == 0 {
-			return rfa.prevValue
-		}
-		if len(values) == 1 {
-			// Fast path - only a single value.
-			return values[0]
+		idx := rfa.idx
+		tsm := rfa.tsm
+		if len(values) <= 1 {
+			// Fast path - all the quantiles are equal to prevValue or to the only value on the window.
+			// The results must be put into tsm, since the returned value is ignored.
+			v := rfa.prevValue
+			if len(values) == 1 {
+				v = values[0]
+			}
+			for _, phiStr := range phiStrs {
+				ts := tsm.GetOrCreateTimeseries(phiLabel, phiStr)
+				ts.Values[idx] = v
+			}
+			return nan
 		}
 		hf := histogram.GetFast()
 		for _, v := range values {
@@ -1072,8 +1230,6 @@ func newRollupQuantiles(args []interface{}) (rollupFunc, error) {
 		}
 		qs := hf.Quantiles(nil, phis)
 		histogram.PutFast(hf)
-		idx := rfa.idx
-		tsm := rfa.tsm
 		for i, phiStr := range phiStrs {
 			ts := tsm.GetOrCreateTimeseries(phiLabel, phiStr)
 			ts.Values[idx] = qs[i]
@@ -1859,6 +2015,34 @@ func rollupZScoreOverTime(rfa *rollupFuncArg) float64 {
 	return d / rollupStddev(rfa)
 }
 
+func rollupMAD(rfa *rollupFuncArg) float64 {
+	// There is no need in handling NaNs here, since they must be cleaned up
+	// before calling rollup funcs.
+	values := rfa.values
+	if len(values) == 0 {
+		return nan
+	}
+	return mad(values)
+}
+
+// mad returns the median absolute deviation for values.
+//
+// See https://en.wikipedia.org/wiki/Median_absolute_deviation
+func mad(values []float64) float64 {
+	hf := histogram.GetFast()
+	for _, v := range values {
+		hf.Update(v)
+	}
+	median := hf.Quantile(0.5)
+	hf.Reset()
+	for _, v := range values {
+		hf.Update(math.Abs(v - median))
+	}
+	v := hf.Quantile(0.5)
+	histogram.PutFast(hf)
+	return v
+}
+
 func rollupFirst(rfa *rollupFuncArg) float64 {
 	// There is no need in handling NaNs here, since they must be cleaned up
 	// before calling rollup funcs.
@@ -13,6 +13,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/memory"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/workingsetcache"
 	"github.com/VictoriaMetrics/fastcache"
@@ -182,11 +183,85 @@ var rollupResultCacheResets = metrics.NewCounter(`vm_cache_resets_total{type="pr
 func ResetRollupResultCache() {
 	rollupResultCacheResets.Inc()
 	atomic.AddUint64(&rollupResultCacheKeyPrefix, 1)
+	rollupResultCacheResetRangesLock.Lock()
+	rollupResultCacheResetRanges.Store([]rollupResultCacheResetRange(nil))
+	rollupResultCacheResetRangesLock.Unlock()
 	logger.Infof("rollupResult cache has been cleared")
 }
 
-func (rrc *rollupResultCache) Get(ec *EvalConfig, expr metricsql.Expr, window int64) (tss []*timeseries, newStart int64) {
+// ResetRollupResultCacheForTimeRange resets rollup result cache entries, which may depend on samples
+// on the given [start ... end] time range in milliseconds.
+//
+// This is useful after backfilling historical data, since it preserves cached results for other time ranges.
+func ResetRollupResultCacheForTimeRange(start, end int64) {
+	if start > end {
+		logger.Panicf("BUG: start cannot exceed end; got %d vs %d", start, end)
+	}
+	rollupResultCacheResetRangesLock.Lock()
+	rrs := rollupResultCacheResetRanges.Load().([]rollupResultCacheResetRange)
+	if len(rrs) >= maxRollupResultCacheResetRanges {
+		rollupResultCacheResetRangesLock.Unlock()
+		// Too many partial resets slow down cache lookups. Reset the whole cache instead.
+		ResetRollupResultCache()
+		return
+	}
+	rrsNew := make([]rollupResultCacheResetRange, len(rrs), len(rrs)+1)
+	copy(rrsNew, rrs)
+	rrsNew = append(rrsNew, rollupResultCacheResetRange{
+		start:        start,
+		end:          end,
+		maxKeySuffix: atomic.LoadUint64(&rollupResultCacheKeySuffix),
+	})
+	rollupResultCacheResetRanges.Store(rrsNew)
+	rollupResultCacheResetRangesLock.Unlock()
+	rollupResultCachePartialResets.Inc()
+	logger.Infof("rollupResult cache has been cleared on the time range [%d..%d]", start, end)
+}
+
+// maxRollupResultCacheResetRanges is the maximum number of partial resets to track
+// before falling back to the full reset of rollup result cache.
+const maxRollupResultCacheResetRanges = 100
+
+// rollupResultCacheResetRange contains a time range passed to ResetRollupResultCacheForTimeRange.
+type rollupResultCacheResetRange struct {
+	start int64
+	end   int64
+
+	// maxKeySuffix is the maximum rollupResultCacheKey.suffix for cache entries, which were stored before the reset.
+	maxKeySuffix uint64
+}
+
+var (
+	rollupResultCacheResetRanges = func() *atomic.Value {
+		var v atomic.Value
+		v.Store([]rollupResultCacheResetRange(nil))
+		return &v
+	}()
+	rollupResultCacheResetRangesLock sync.Mutex
+)
+
+var rollupResultCachePartialResets = metrics.NewCounter(`vm_cache_partial_resets_total{type="promql/rollupResult"}`)
+
+// isStale returns true if the entry may contain results calculated from samples on time ranges passed to ResetRollupResultCacheForTimeRange.
+//
+// lookbehind is the maximum duration in milliseconds before the entry start, which could be used for calculating the entry values.
+func (mie *rollupResultCacheMetainfoEntry) isStale(rrs []rollupResultCacheResetRange, lookbehind int64) bool {
+	for _, rr := range rrs {
+		if mie.key.suffix <= rr.maxKeySuffix && mie.end >= rr.start && mie.start-lookbehind <= rr.end {
+			return true
+		}
+	}
+	return false
+}
+
+func (rrc *rollupResultCache) Get(qt *querytracer.Tracer, ec *EvalConfig, expr metricsql.Expr, window int64) (tss []*timeseries, newStart int64) {
+	if qt.Enabled() {
+		query := expr.AppendString(nil)
+		qt = qt.NewChild("rollup cache get: query=%s, timeRange=%s, step=%d, window=%d", query, ec.timeRangeString(), ec.Step, window)
+		defer qt.Done()
+	}
 	if !ec.mayCache() {
+		qt.Printf("do not fetch series from cache, since it is disabled in the current context")
 		return nil, ec.Start
 	}
 
@@ -194,17 +269,19 @@ func (rrc *rollupResultCache) Get(ec *EvalConfig, expr metricsql.Expr, window in
 	bb := bbPool.Get()
 	defer bbPool.Put(bb)
 
-	bb.B = marshalRollupResultCacheKey(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilters)
+	bb.B = marshalRollupResultCacheKey(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilters, ec.nativeHistogramComponent)
 	metainfoBuf := rrc.c.Get(nil, bb.B)
 	if len(metainfoBuf) == 0 {
+		qt.Printf("nothing found")
 		return nil, ec.Start
 	}
 	var mi rollupResultCacheMetainfo
 	if err := mi.Unmarshal(metainfoBuf); err != nil {
 		logger.Panicf("BUG: cannot unmarshal rollupResultCacheMetainfo: %s; it looks like it was improperly saved", err)
 	}
-	key := mi.GetBestKey(ec.Start, ec.End)
+	key := mi.GetBestKey(ec.Start, ec.End, getRollupLookbehind(ec, window))
 	if key.prefix == 0 && key.suffix == 0 {
+		qt.Printf("nothing found on the timeRange")
 		return nil, ec.Start
 	}
 	bb.B = key.Marshal(bb.B[:0])
@@ -214,20 +291,24 @@ func (rrc *rollupResultCache) Get(ec *EvalConfig, expr metricsql.Expr, window in
 	if len(compressedResultBuf.B) == 0 {
 		mi.RemoveKey(key)
 		metainfoBuf = mi.Marshal(metainfoBuf[:0])
-		bb.B = marshalRollupResultCacheKey(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilters)
+		bb.B = marshalRollupResultCacheKey(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilters, ec.nativeHistogramComponent)
 		rrc.c.Set(bb.B, metainfoBuf)
+		qt.Printf("missing cache entry")
 		return nil, ec.Start
 	}
+	qt.Printf("load compressed entry from cache with size %d bytes", len(compressedResultBuf.B))
 	// Decompress into newly allocated byte slice, since tss returned from unmarshalTimeseriesFast
 	// refers to the byte slice, so it cannot be returned to the resultBufPool.
 	resultBuf, err := encoding.DecompressZSTD(nil, compressedResultBuf.B)
 	if err != nil {
 		logger.Panicf("BUG: cannot decompress resultBuf from rollupResultCache: %s; it looks like it was improperly saved", err)
 	}
+	qt.Printf("unpack the entry into %d bytes", len(resultBuf))
 	tss, err = unmarshalTimeseriesFast(resultBuf)
 	if err != nil {
 		logger.Panicf("BUG: cannot unmarshal timeseries from rollupResultCache: %s; it looks like it was improperly saved", err)
 	}
+	qt.Printf("unmarshal %d series", len(tss))
 
 	// Extract values for the matching timestamps
 	timestamps := tss[0].Timestamps
@@ -236,11 +317,11 @@ func (rrc *rollupResultCache) Get(ec *EvalConfig, expr metricsql.Expr, window in
 		i++
 	}
 	if i == len(timestamps) {
-		// no matches.
+		qt.Printf("no datapoints found in the cached series on the given timeRange")
 		return nil, ec.Start
 	}
 	if timestamps[i] != ec.Start {
-		// The cached range doesn't cover the requested range.
+		qt.Printf("cached series don't cover the given timeRange")
 		return nil, ec.Start
 	}
 
@@ -250,7 +331,7 @@ func (rrc *rollupResultCache) Get(ec *EvalConfig, expr metricsql.Expr, window in
 	}
 	j++
 	if j <= i {
-		// no matches.
+		qt.Printf("no datapoints found in the cached series on the given timeRange")
 		return nil, ec.Start
 	}
 
@@ -261,13 +342,34 @@ func (rrc *rollupResultCache) Get(ec *EvalConfig, expr metricsql.Expr, window in
 
 	timestamps = tss[0].Timestamps
 	newStart = timestamps[len(timestamps)-1] + ec.Step
+	qt.Printf("return %d series on a timeRange=[%d..%d]", len(tss), ec.Start, newStart-ec.Step)
 	return tss, newStart
 }
 
 var resultBufPool bytesutil.ByteBufferPool
 
-func (rrc *rollupResultCache) Put(ec *EvalConfig, expr metricsql.Expr, window int64, tss []*timeseries) {
+// getRollupLookbehind returns the maximum duration in milliseconds before the first point,
+// which may be used for calculating rollup results with the given window.
+//
+// See evalRollupFuncWithMetricExpr for details.
+func getRollupLookbehind(ec *EvalConfig, window int64) int64 {
+	lookbehind := int64(maxSilenceInterval)
+	if window > ec.Step {
+		lookbehind += window
+	} else {
+		lookbehind += ec.Step
+	}
+	return lookbehind
+}
+
+func (rrc *rollupResultCache) Put(qt *querytracer.Tracer, ec *EvalConfig, expr metricsql.Expr, window int64, tss []*timeseries) {
+	if qt.Enabled() {
+		query := expr.AppendString(nil)
+		qt = qt.NewChild("rollup cache put: query=%s, timeRange=%s, step=%d, window=%d, series=%d", query, ec.timeRangeString(), ec.Step, window, len(tss))
+		defer qt.Done()
+	}
 	if len(tss) == 0 || !ec.mayCache() {
+		qt.Printf("do not store series to cache, since it is disabled in the current context")
 		return
 	}
 
@@ -281,7 +383,7 @@ func (rrc *rollupResultCache) Put(ec *EvalConfig, expr metricsql.Expr, window in
 	}
 	i++
 	if i == 0 {
-		// Nothing to store in the cache.
+		qt.Printf("nothing to store in the cache")
 		return
 	}
 	if i < len(timestamps) {
@@ -302,11 +404,14 @@ func (rrc *rollupResultCache) Put(ec *EvalConfig, expr metricsql.Expr, window in
 	resultBuf.B = marshalTimeseriesFast(resultBuf.B[:0], tss, maxMarshaledSize, ec.Step)
 	if len(resultBuf.B) == 0 {
 		tooBigRollupResults.Inc()
+		qt.Printf("cannot store series in the cache, since they would occupy more than %d bytes", maxMarshaledSize)
 		return
 	}
+	qt.Printf("marshal %d series on a timeRange=[%d..%d] into %d bytes", len(tss), timestamps[0], timestamps[len(timestamps)-1], len(resultBuf.B))
 	compressedResultBuf := resultBufPool.Get()
 	defer resultBufPool.Put(compressedResultBuf)
 	compressedResultBuf.B = encoding.CompressZSTDLevel(compressedResultBuf.B[:0], resultBuf.B, 1)
+	qt.Printf("compress %d bytes into %d bytes", len(resultBuf.B), len(compressedResultBuf.B))
 
 	bb := bbPool.Get()
 	defer bbPool.Put(bb)
@@ -316,8 +421,9 @@ func (rrc *rollupResultCache) Put(ec *EvalConfig, expr metricsql.Expr, window in
 	key.suffix = atomic.AddUint64(&rollupResultCacheKeySuffix, 1)
 	bb.B = key.Marshal(bb.B[:0])
 	rrc.c.SetBig(bb.B, compressedResultBuf.B)
+	qt.Printf("store %d bytes in the cache", len(compressedResultBuf.B))
 
-	bb.B = marshalRollupResultCacheKey(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilters)
+	bb.B = marshalRollupResultCacheKey(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilters, ec.nativeHistogramComponent)
 	metainfoBuf := rrc.c.Get(nil, bb.B)
 	var mi rollupResultCacheMetainfo
 	if len(metainfoBuf) > 0 {
@@ -345,9 +451,9 @@ var (
 var tooBigRollupResults = metrics.NewCounter("vm_too_big_rollup_results_total")
 
 // Increment this value every time the format of the cache changes.
-const rollupResultCacheVersion = 8
+const rollupResultCacheVersion = 9
 
-func marshalRollupResultCacheKey(dst []byte, expr metricsql.Expr, window, step int64, filters []storage.TagFilter) []byte {
+func marshalRollupResultCacheKey(dst []byte, expr metricsql.Expr, window, step int64, filters []storage.TagFilter, histogramComponent string) []byte {
 	dst = append(dst, rollupResultCacheVersion)
 	dst = encoding.MarshalUint64(dst, rollupResultCacheKeyPrefix)
 	dst = encoding.MarshalInt64(dst, window)
@@ -356,6 +462,7 @@ func marshalRollupResultCacheKey(dst []byte, expr metricsql.Expr, window, step i
 	for _, f := range filters {
 		dst = f.Marshal(dst)
 	}
+	dst = encoding.MarshalBytes(dst, []byte(histogramComponent))
 	return dst
 }
 
@@ -477,10 +584,15 @@ func (mi *rollupResultCacheMetainfo) Unmarshal(src []byte) error {
 	return nil
 }
 
-func (mi *rollupResultCacheMetainfo) GetBestKey(start, end int64) rollupResultCacheKey {
+// GetBestKey returns the key for the entry, which covers the biggest part of [start ... end] time range.
+//
+// Entries invalidated via ResetRollupResultCacheForTimeRange are skipped.
+// lookbehind is the maximum duration in milliseconds before the entry start, which could be used for calculating the entry values.
+func (mi *rollupResultCacheMetainfo) GetBestKey(start, end, lookbehind int64) rollupResultCacheKey {
 	if start > end {
 		logger.Panicf("BUG: start cannot exceed end; got %d vs %d", start, end)
 	}
+	rrs := rollupResultCacheResetRanges.Load().([]rollupResultCacheResetRange)
 	var bestKey rollupResultCacheKey
 	bestD := int64(1<<63 - 1)
 	for i := range mi.entries {
@@ -488,6 +600,9 @@ func (mi *rollupResultCacheMetainfo) GetBestKey(start, end int64) rollupResultCa
 		if start < e.start || end <= e.start {
 			continue
 		}
+		if e.isStale(rrs, lookbehind) {
+			continue
+		}
 		d := start - e.start
 		if d < bestD {
 			bestD = d
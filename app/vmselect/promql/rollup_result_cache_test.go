@@ -34,7 +34,7 @@ func TestRollupResultCache(t *testing.T) {
 
 	// Try obtaining an empty value.
 	t.Run("empty", func(t *testing.T) {
-		tss, newStart := rollupResultCacheV.Get(ec, fe, window)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != ec.Start {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, ec.Start)
 		}
@@ -52,8 +52,8 @@ func TestRollupResultCache(t *testing.T) {
 				Values:     []float64{0, 1, 2},
 			},
 		}
-		rollupResultCacheV.Put(ec, fe, window, tss)
-		tss, newStart := rollupResultCacheV.Get(ec, fe, window)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != 1400 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1400)
 		}
@@ -73,8 +73,8 @@ func TestRollupResultCache(t *testing.T) {
 				Values:     []float64{0, 1, 2},
 			},
 		}
-		rollupResultCacheV.Put(ec, ae, window, tss)
-		tss, newStart := rollupResultCacheV.Get(ec, ae, window)
+		rollupResultCacheV.Put(nil, ec, ae, window, tss)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, ae, window)
 		if newStart != 1400 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1400)
 		}
@@ -87,6 +87,62 @@ func TestRollupResultCache(t *testing.T) {
 		testTimeseriesEqual(t, tss, tssExpected)
 	})
 
+	// Reset the cache on time ranges
+	t.Run("reset-time-range", func(t *testing.T) {
+		ResetRollupResultCache()
+		tss := []*timeseries{
+			{
+				Timestamps: []int64{1000, 1200},
+				Values:     []float64{1, 2},
+			},
+		}
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+
+		// The reset on the time range after the cached entry mustn't affect it.
+		ResetRollupResultCacheForTimeRange(400e3, 500e3)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
+		if newStart != 1400 {
+			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1400)
+		}
+		tssExpected := []*timeseries{
+			{
+				Timestamps: []int64{1000, 1200},
+				Values:     []float64{1, 2},
+			},
+		}
+		testTimeseriesEqual(t, tss, tssExpected)
+
+		// The reset on the time range before the cached entry must invalidate it, since the entry may depend on samples from this range.
+		ResetRollupResultCacheForTimeRange(0, 100)
+		tss, newStart = rollupResultCacheV.Get(nil, ec, fe, window)
+		if newStart != ec.Start {
+			t.Fatalf("unexpected newStart; got %d; want %d", newStart, ec.Start)
+		}
+		if len(tss) != 0 {
+			t.Fatalf("got %d timeseries, while expecting zero", len(tss))
+		}
+
+		// Entries stored after the reset must be returned.
+		tss = []*timeseries{
+			{
+				Timestamps: []int64{1000, 1200},
+				Values:     []float64{3, 4},
+			},
+		}
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+		tss, newStart = rollupResultCacheV.Get(nil, ec, fe, window)
+		if newStart != 1400 {
+			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1400)
+		}
+		tssExpected = []*timeseries{
+			{
+				Timestamps: []int64{1000, 1200},
+				Values:     []float64{3, 4},
+			},
+		}
+		testTimeseriesEqual(t, tss, tssExpected)
+	})
+
 	// Store timeseries overlapping with end
 	t.Run("end-overlap", func(t *testing.T) {
 		ResetRollupResultCache()
@@ -96,8 +152,8 @@ func TestRollupResultCache(t *testing.T) {
 				Values:     []float64{333, 0, 1, 2},
 			},
 		}
-		rollupResultCacheV.Put(ec, fe, window, tss)
-		tss, newStart := rollupResultCacheV.Get(ec, fe, window)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != 1000 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1000)
 		}
@@ -115,8 +171,8 @@ func TestRollupResultCache(t *testing.T) {
 				Values:     []float64{0, 1, 2},
 			},
 		}
-		rollupResultCacheV.Put(ec, fe, window, tss)
-		tss, newStart := rollupResultCacheV.Get(ec, fe, window)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != 1000 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1000)
 		}
@@ -134,8 +190,8 @@ func TestRollupResultCache(t *testing.T) {
 				Values:     []float64{0, 1, 2},
 			},
 		}
-		rollupResultCacheV.Put(ec, fe, window, tss)
-		tss, newStart := rollupResultCacheV.Get(ec, fe, window)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != 1000 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1000)
 		}
@@ -153,8 +209,8 @@ func TestRollupResultCache(t *testing.T) {
 				Values:     []float64{0, 1, 2},
 			},
 		}
-		rollupResultCacheV.Put(ec, fe, window, tss)
-		tss, newStart := rollupResultCacheV.Get(ec, fe, window)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != 1000 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1000)
 		}
@@ -172,8 +228,8 @@ func TestRollupResultCache(t *testing.T) {
 				Values:     []float64{0, 1, 2, 3, 4, 5, 6, 7},
 			},
 		}
-		rollupResultCacheV.Put(ec, fe, window, tss)
-		tss, newStart := rollupResultCacheV.Get(ec, fe, window)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != 2200 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 2200)
 		}
@@ -195,8 +251,8 @@ func TestRollupResultCache(t *testing.T) {
 				Values:     []float64{1, 2, 3, 4, 5, 6},
 			},
 		}
-		rollupResultCacheV.Put(ec, fe, window, tss)
-		tss, newStart := rollupResultCacheV.Get(ec, fe, window)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != 2200 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 2200)
 		}
@@ -220,8 +276,8 @@ func TestRollupResultCache(t *testing.T) {
 			}
 			tss = append(tss, ts)
 		}
-		rollupResultCacheV.Put(ec, fe, window, tss)
-		tssResult, newStart := rollupResultCacheV.Get(ec, fe, window)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss)
+		tssResult, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != 2200 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 2200)
 		}
@@ -249,10 +305,10 @@ func TestRollupResultCache(t *testing.T) {
 				Values:     []float64{0, 1, 2},
 			},
 		}
-		rollupResultCacheV.Put(ec, fe, window, tss1)
-		rollupResultCacheV.Put(ec, fe, window, tss2)
-		rollupResultCacheV.Put(ec, fe, window, tss3)
-		tss, newStart := rollupResultCacheV.Get(ec, fe, window)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss1)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss2)
+		rollupResultCacheV.Put(nil, ec, fe, window, tss3)
+		tss, newStart := rollupResultCacheV.Get(nil, ec, fe, window)
 		if newStart != 1400 {
 			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1400)
 		}
@@ -4,6 +4,8 @@ import (
 	"math"
 	"testing"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/metricsql"
 )
 
@@ -480,6 +482,7 @@ func TestRollupNewRollupFuncSuccess(t *testing.T) {
 	f("ascent_over_time", 142)
 	f("descent_over_time", 231)
 	f("zscore_over_time", -0.4254336383156416)
+	f("mad_over_time", 10)
 	f("timestamp", 0.13)
 	f("mode_over_time", 34)
 	f("rate_over_sum", 4520)
@@ -722,6 +725,29 @@ func TestRollupFuncsLookbackDelta(t *testing.T) {
 	})
 }
 
+func TestRollupSeriesEnd(t *testing.T) {
+	if err := storage.SetStaleMarkersMode("seriesEnd"); err != nil {
+		t.Fatalf("cannot set stale markers mode: %s", err)
+	}
+	defer func() {
+		if err := storage.SetStaleMarkersMode("keep"); err != nil {
+			t.Fatalf("cannot reset stale markers mode: %s", err)
+		}
+	}()
+	rc := rollupConfig{
+		Func:   rollupCount,
+		Start:  20,
+		End:    70,
+		Step:   10,
+		Window: 25,
+	}
+	rc.Timestamps = getTimestamps(rc.Start, rc.End, rc.Step)
+	values := rc.Do(nil, []float64{1, 2, 3, decimal.StaleNaN, 10, 11}, []int64{10, 20, 30, 40, 50, 60})
+	valuesExpected := []float64{2, 3, nan, 1, 2, 2}
+	timestampsExpected := []int64{20, 30, 40, 50, 60, 70}
+	testRowsEqual(t, values, rc.Timestamps, valuesExpected, timestampsExpected)
+}
+
 func TestRollupFuncsNoWindow(t *testing.T) {
 	t.Run("first", func(t *testing.T) {
 		rc := rollupConfig{
@@ -1087,6 +1113,20 @@ func TestRollupFuncsNoWindow(t *testing.T) {
 		timestampsExpected := []int64{0, 40, 80, 120, 160}
 		testRowsEqual(t, values, rc.Timestamps, valuesExpected, timestampsExpected)
 	})
+	t.Run("mad_over_time", func(t *testing.T) {
+		rc := rollupConfig{
+			Func:   rollupMAD,
+			Start:  0,
+			End:    160,
+			Step:   40,
+			Window: 80,
+		}
+		rc.Timestamps = getTimestamps(rc.Start, rc.End, rc.Step)
+		values := rc.Do(nil, testValues, testTimestamps)
+		valuesExpected := []float64{nan, 23, 23, 10, 2}
+		timestampsExpected := []int64{0, 40, 80, 120, 160}
+		testRowsEqual(t, values, rc.Timestamps, valuesExpected, timestampsExpected)
+	})
 }
 
 func TestRollupBigNumberOfValues(t *testing.T) {
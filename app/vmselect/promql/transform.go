@@ -128,6 +128,9 @@ var transformFuncs = map[string]transformFunc{
 	"bitmap_or":           newTransformBitmap(bitmapOr),
 	"bitmap_xor":          newTransformBitmap(bitmapXor),
 	"histogram_quantiles": transformHistogramQuantiles,
+	"limit_offset":        transformLimitOffset,
+	"histogram_count":     transformHistogramComponent,
+	"histogram_sum":       transformHistogramComponent,
 }
 
 func getTransformFunc(s string) transformFunc {
@@ -160,7 +163,7 @@ func newTransformFuncOneArg(tf func(v float64) float64) transformFunc {
 
 func doTransformValues(arg []*timeseries, tf func(values []float64), fe *metricsql.FuncExpr) ([]*timeseries, error) {
 	name := strings.ToLower(fe.Name)
-	keepMetricGroup := transformFuncsKeepMetricGroup[name]
+	keepMetricGroup := transformFuncsKeepMetricGroup[name] || fe.KeepMetricNames
 	for _, ts := range arg {
 		if !keepMetricGroup {
 			ts.MetricName.ResetMetricGroup()
@@ -545,7 +548,9 @@ func vmrangeBucketsToLE(tss []*timeseries) []*timeseries {
 			}
 			xsPrev = xs
 		}
-		if !math.IsInf(xsPrev.end, 1) {
+		if len(xssNew) == 0 || !math.IsInf(xssNew[len(xssNew)-1].end, 1) {
+			// Add the missing `le="+Inf"` bucket. It may be missing if the last bucket ends with a finite value
+			// or if the last bucket with `vmrange="...+Inf"` contains only zeros.
 			xssNew = append(xssNew, x{
 				endStr: "+Inf",
 				end:    math.Inf(1),
@@ -690,6 +695,17 @@ func transformHistogramAvg(tfa *transformFuncArg) ([]*timeseries, error) {
 	return rvs, nil
 }
 
+// transformHistogramComponent returns the component of native histograms selected for the arg evaluation.
+//
+// See getNativeHistogramComponent.
+func transformHistogramComponent(tfa *transformFuncArg) ([]*timeseries, error) {
+	args := tfa.args
+	if err := expectTransformArgsNum(args, 1); err != nil {
+		return nil, err
+	}
+	return doTransformValues(args[0], func(values []float64) {}, tfa.fe)
+}
+
 func transformHistogramStddev(tfa *transformFuncArg) ([]*timeseries, error) {
 	args := tfa.args
 	if err := expectTransformArgsNum(args, 1); err != nil {
@@ -800,29 +816,27 @@ func transformHistogramQuantiles(tfa *transformFuncArg) ([]*timeseries, error) {
 		return nil, fmt.Errorf("cannot obtain dstLabel: %w", err)
 	}
 	phiArgs := args[1 : len(args)-1]
-	tssOrig := args[len(args)-1]
-	// Calculate quantile individually per each phi.
-	var rvs []*timeseries
-	for _, phiArg := range phiArgs {
-		phiStr := fmt.Sprintf("%g", phiArg[0].Values[0])
-		tss := copyTimeseries(tssOrig)
-		tfaTmp := &transformFuncArg{
-			ec: tfa.ec,
-			fe: tfa.fe,
-			args: [][]*timeseries{
-				phiArg,
-				tss,
-			},
-		}
-		tssTmp, err := transformHistogramQuantile(tfaTmp)
+	phiss := make([][]float64, len(phiArgs))
+	phiStrs := make([]string, len(phiArgs))
+	for i, phiArg := range phiArgs {
+		phis, err := getScalar(phiArg, i+1)
 		if err != nil {
-			return nil, fmt.Errorf("cannot calculate quantile %s: %w", phiStr, err)
+			return nil, fmt.Errorf("cannot parse phi from arg #%d: %w", i+2, err)
 		}
-		for _, ts := range tssTmp {
+		phiss[i] = phis
+		phiStrs[i] = fmt.Sprintf("%g", phis[0])
+	}
+
+	// Calculate all the quantiles in a single pass over the buckets.
+	tss := vmrangeBucketsToLE(args[len(args)-1])
+	rvss := histogramQuantiles(tss, phiss, "")
+	var rvs []*timeseries
+	for i, tssPhi := range rvss {
+		for _, ts := range tssPhi {
 			ts.MetricName.RemoveTag(dstLabel)
-			ts.MetricName.AddTag(dstLabel, phiStr)
+			ts.MetricName.AddTag(dstLabel, phiStrs[i])
 		}
-		rvs = append(rvs, tssTmp...)
+		rvs = append(rvs, tssPhi...)
 	}
 	return rvs, nil
 }
@@ -850,6 +864,16 @@ func transformHistogramQuantile(tfa *transformFuncArg) ([]*timeseries, error) {
 		boundsLabel = s
 	}
 
+	rvss := histogramQuantiles(tss, [][]float64{phis}, boundsLabel)
+	return rvss[0], nil
+}
+
+// histogramQuantiles calculates quantiles for every phis from phiss over the given buckets with `le` labels.
+//
+// Buckets are grouped and sorted only once for all the phiss.
+// The returned slice contains results per each item in phiss.
+// Lower and upper bounds for the estimated quantiles are returned with the boundsLabel label if it isn't empty.
+func histogramQuantiles(tss []*timeseries, phiss [][]float64, boundsLabel string) [][]*timeseries {
 	// Group metrics by all tags excluding "le"
 	m := groupLeTimeseries(tss)
 
@@ -911,38 +935,68 @@ func transformHistogramQuantile(tfa *transformFuncArg) ([]*timeseries, error) {
 		vv := lastNonInf(i, xss)
 		return vv, vv, inf
 	}
-	rvs := make([]*timeseries, 0, len(m))
+	rvss := make([][]*timeseries, len(phiss))
+	for k := range rvss {
+		rvss[k] = make([]*timeseries, 0, len(m))
+	}
+	type quantileResult struct {
+		q, lower, upper float64
+	}
+	results := make([]quantileResult, len(phiss))
 	for _, xss := range m {
 		sort.Slice(xss, func(i, j int) bool {
 			return xss[i].le < xss[j].le
 		})
-		dst := xss[0].ts
-		var tsLower, tsUpper *timeseries
+		// The first bucket is re-used for the last phi, since its values at index i
+		// aren't read after all the quantiles are calculated for index i.
+		dsts := make([]*timeseries, len(phiss))
+		for k := range dsts {
+			if k == len(dsts)-1 {
+				dsts[k] = xss[0].ts
+				continue
+			}
+			dsts[k] = &timeseries{}
+			dsts[k].CopyFromShallowTimestamps(xss[0].ts)
+		}
+		var tsLowers, tsUppers []*timeseries
 		if len(boundsLabel) > 0 {
-			tsLower = &timeseries{}
-			tsLower.CopyFromShallowTimestamps(dst)
-			tsLower.MetricName.RemoveTag(boundsLabel)
-			tsLower.MetricName.AddTag(boundsLabel, "lower")
-			tsUpper = &timeseries{}
-			tsUpper.CopyFromShallowTimestamps(dst)
-			tsUpper.MetricName.RemoveTag(boundsLabel)
-			tsUpper.MetricName.AddTag(boundsLabel, "upper")
+			tsLowers = make([]*timeseries, len(phiss))
+			tsUppers = make([]*timeseries, len(phiss))
+			for k := range phiss {
+				tsLower := &timeseries{}
+				tsLower.CopyFromShallowTimestamps(xss[0].ts)
+				tsLower.MetricName.RemoveTag(boundsLabel)
+				tsLower.MetricName.AddTag(boundsLabel, "lower")
+				tsLowers[k] = tsLower
+				tsUpper := &timeseries{}
+				tsUpper.CopyFromShallowTimestamps(xss[0].ts)
+				tsUpper.MetricName.RemoveTag(boundsLabel)
+				tsUpper.MetricName.AddTag(boundsLabel, "upper")
+				tsUppers[k] = tsUpper
+			}
 		}
-		for i := range dst.Values {
-			v, lower, upper := quantile(i, phis, xss)
-			dst.Values[i] = v
-			if len(boundsLabel) > 0 {
-				tsLower.Values[i] = lower
-				tsUpper.Values[i] = upper
+		for i := range xss[0].ts.Values {
+			for k, phis := range phiss {
+				r := &results[k]
+				r.q, r.lower, r.upper = quantile(i, phis, xss)
+			}
+			for k := range phiss {
+				r := &results[k]
+				dsts[k].Values[i] = r.q
+				if len(boundsLabel) > 0 {
+					tsLowers[k].Values[i] = r.lower
+					tsUppers[k].Values[i] = r.upper
+				}
 			}
 		}
-		rvs = append(rvs, dst)
-		if len(boundsLabel) > 0 {
-			rvs = append(rvs, tsLower)
-			rvs = append(rvs, tsUpper)
+		for k := range phiss {
+			rvss[k] = append(rvss[k], dsts[k])
+			if len(boundsLabel) > 0 {
+				rvss[k] = append(rvss[k], tsLowers[k], tsUppers[k])
+			}
 		}
 	}
-	return rvs, nil
+	return rvss
 }
 
 type leTimeseries struct {
@@ -1134,7 +1188,9 @@ func newTransformFuncRunning(rf func(a, b float64, idx int) float64) transformFu
 
 		rvs := args[0]
 		for _, ts := range rvs {
-			ts.MetricName.ResetMetricGroup()
+			if !tfa.fe.KeepMetricNames {
+				ts.MetricName.ResetMetricGroup()
+			}
 			values := skipLeadingNaNs(ts.Values)
 			if len(values) == 0 {
 				continue
@@ -1649,7 +1705,9 @@ func transformLabelValue(tfa *transformFuncArg) ([]*timeseries, error) {
 	}
 	rvs := args[0]
 	for _, ts := range rvs {
-		ts.MetricName.ResetMetricGroup()
+		if !tfa.fe.KeepMetricNames {
+			ts.MetricName.ResetMetricGroup()
+		}
 		labelValue := ts.MetricName.GetTagValue(labelName)
 		v, err := strconv.ParseFloat(string(labelValue), 64)
 		if err != nil {
@@ -1819,6 +1877,46 @@ func transformScalar(tfa *transformFuncArg) ([]*timeseries, error) {
 	return arg, nil
 }
 
+func transformLimitOffset(tfa *transformFuncArg) ([]*timeseries, error) {
+	args := tfa.args
+	if err := expectTransformArgsNum(args, 3); err != nil {
+		return nil, err
+	}
+	limits, err := getScalar(args[0], 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain limit arg: %w", err)
+	}
+	offsets, err := getScalar(args[1], 1)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain offset arg: %w", err)
+	}
+	limit := 0
+	if len(limits) > 0 && !math.IsNaN(limits[0]) && limits[0] > 0 {
+		limit = int(limits[0])
+	}
+	offset := 0
+	if len(offsets) > 0 && !math.IsNaN(offsets[0]) && offsets[0] > 0 {
+		offset = int(offsets[0])
+	}
+	// Remove empty series, since they are dropped from the response, so they mustn't be counted.
+	rvs := removeNaNs(args[2])
+	if maySortResults(tfa.fe.Args[2], rvs) {
+		// The order of series must be stable between requests, so sort them
+		// in the same way as they are sorted in the response.
+		sort.Slice(rvs, func(i, j int) bool {
+			return metricNameLess(&rvs[i].MetricName, &rvs[j].MetricName)
+		})
+	}
+	if offset >= len(rvs) {
+		return nil, nil
+	}
+	rvs = rvs[offset:]
+	if limit < len(rvs) {
+		rvs = rvs[:limit]
+	}
+	return rvs, nil
+}
+
 func newTransformFuncSortByLabel(isDesc bool) transformFunc {
 	return func(tfa *transformFuncArg) ([]*timeseries, error) {
 		args := tfa.args
@@ -1995,21 +2093,26 @@ func transformTimezoneOffset(tfa *transformFuncArg) ([]*timeseries, error) {
 	if err != nil {
 		return nil, fmt.Errorf("cannot get timezone name: %w", err)
 	}
-	tzOffset, err := getTimezoneOffset(tzString)
+	loc, err := time.LoadLocation(tzString)
 	if err != nil {
-		return nil, fmt.Errorf("cannot get timezone offset for %q: %w", tzString, err)
+		return nil, fmt.Errorf("cannot load timezone %q: %w", tzString, err)
 	}
-	rv := evalNumber(tfa.ec, float64(tzOffset))
-	return rv, nil
-}
 
-func getTimezoneOffset(tzString string) (int, error) {
-	loc, err := time.LoadLocation(tzString)
-	if err != nil {
-		return 0, fmt.Errorf("cannot load timezone %q: %w", tzString, err)
+	// The offset is calculated individually per each point, since it may change over time
+	// because of daylight saving time. This allows using expressions such as
+	// `hour(time() + timezone_offset("Europe/Berlin"))` over time ranges containing DST changes.
+	tss := evalNumber(tfa.ec, nan)
+	ts := tss[0]
+	for i, timestamp := range ts.Timestamps {
+		ts.Values[i] = float64(getTimezoneOffset(loc, timestamp))
 	}
-	_, tzOffset := time.Now().In(loc).Zone()
-	return tzOffset, nil
+	return tss, nil
+}
+
+// getTimezoneOffset returns offset in seconds relative to UTC for the given loc at the given timestamp in milliseconds.
+func getTimezoneOffset(loc *time.Location, timestamp int64) int {
+	_, tzOffset := time.Unix(timestamp/1e3, 0).In(loc).Zone()
+	return tzOffset
 }
 
 func transformTime(tfa *transformFuncArg) ([]*timeseries, error) {
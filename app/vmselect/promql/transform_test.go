@@ -0,0 +1,33 @@
+package promql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetTimezoneOffset(t *testing.T) {
+	f := func(tzString string, timestamp int64, offsetExpected int) {
+		t.Helper()
+		loc, err := time.LoadLocation(tzString)
+		if err != nil {
+			t.Fatalf("cannot load timezone %q: %s", tzString, err)
+		}
+		offset := getTimezoneOffset(loc, timestamp)
+		if offset != offsetExpected {
+			t.Fatalf("unexpected offset for %q at %d; got %d; want %d", tzString, timestamp, offset, offsetExpected)
+		}
+	}
+	f("UTC", 1640995200000, 0)
+
+	// 2022-01-01T00:00:00Z - winter time
+	f("Europe/Berlin", 1640995200000, 3600)
+	f("America/New_York", 1640995200000, -5*3600)
+
+	// 2022-07-01T00:00:00Z - summer time
+	f("Europe/Berlin", 1656633600000, 2*3600)
+	f("America/New_York", 1656633600000, -4*3600)
+
+	// Around DST change at 2022-03-27T01:00:00Z in Europe/Berlin
+	f("Europe/Berlin", 1648342799000, 3600)
+	f("Europe/Berlin", 1648342800000, 2*3600)
+}
@@ -101,6 +101,14 @@ func (qst *queryStatsTracker) writeJSONQueryStats(w io.Writer, topN int, maxLife
 			fmt.Fprintf(w, `,`)
 		}
 	}
+	fmt.Fprintf(w, `],"topByMaxDuration":[`)
+	topByMaxDuration := qst.getTopByMaxDuration(topN, maxLifetime)
+	for i, r := range topByMaxDuration {
+		fmt.Fprintf(w, `{"query":%q,"timeRangeSeconds":%d,"maxDurationSeconds":%.3f,"count":%d}`, r.query, r.timeRangeSecs, r.duration.Seconds(), r.count)
+		if i+1 < len(topByMaxDuration) {
+			fmt.Fprintf(w, `,`)
+		}
+	}
 	fmt.Fprintf(w, `]}`)
 }
 
@@ -256,3 +264,42 @@ func (qst *queryStatsTracker) getTopBySumDuration(topN int, maxLifetime time.Dur
 	}
 	return a
 }
+
+func (qst *queryStatsTracker) getTopByMaxDuration(topN int, maxLifetime time.Duration) []queryStatByDuration {
+	currentTime := time.Now()
+	qst.mu.Lock()
+	type countMax struct {
+		count int
+		max   time.Duration
+	}
+	m := make(map[queryStatKey]countMax)
+	for _, r := range qst.a {
+		if r.matches(currentTime, maxLifetime) {
+			k := r.key()
+			km := m[k]
+			km.count++
+			if r.duration > km.max {
+				km.max = r.duration
+			}
+			m[k] = km
+		}
+	}
+	qst.mu.Unlock()
+
+	var a []queryStatByDuration
+	for k, km := range m {
+		a = append(a, queryStatByDuration{
+			query:         k.query,
+			timeRangeSecs: k.timeRangeSecs,
+			duration:      km.max,
+			count:         km.count,
+		})
+	}
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].duration > a[j].duration
+	})
+	if len(a) > topN {
+		a = a[:topN]
+	}
+	return a
+}
@@ -0,0 +1,81 @@
+package querystats
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func newTestQueryStatsTracker(recordsCount int) *queryStatsTracker {
+	return &queryStatsTracker{
+		a: make([]queryStatRecord, recordsCount),
+	}
+}
+
+func TestQueryStatsTrackerTopByDuration(t *testing.T) {
+	qst := newTestQueryStatsTracker(10)
+	now := time.Now()
+	qst.registerQuery("foo", 3600*1000, now.Add(-time.Second))
+	qst.registerQuery("foo", 3600*1000, now.Add(-3*time.Second))
+	qst.registerQuery("bar", 3600*1000, now.Add(-2*time.Second))
+	qst.registerQuery("bar", 3600*1000, now.Add(-2*time.Second))
+	qst.registerQuery("bar", 3600*1000, now.Add(-2*time.Second))
+
+	topByCount := qst.getTopByCount(1, time.Hour)
+	if len(topByCount) != 1 || topByCount[0].query != "bar" || topByCount[0].count != 3 {
+		t.Fatalf("unexpected topByCount: %+v", topByCount)
+	}
+	topByMaxDuration := qst.getTopByMaxDuration(10, time.Hour)
+	if len(topByMaxDuration) != 2 {
+		t.Fatalf("unexpected number of entries in topByMaxDuration; got %d; want 2", len(topByMaxDuration))
+	}
+	if r := topByMaxDuration[0]; r.query != "foo" || r.count != 2 || r.duration < 3*time.Second || r.duration > 4*time.Second {
+		t.Fatalf("unexpected first entry in topByMaxDuration: %+v", r)
+	}
+	if r := topByMaxDuration[1]; r.query != "bar" || r.count != 3 {
+		t.Fatalf("unexpected second entry in topByMaxDuration: %+v", r)
+	}
+	topByAvgDuration := qst.getTopByAvgDuration(10, time.Hour)
+	if len(topByAvgDuration) != 2 || topByAvgDuration[0].query != "bar" {
+		t.Fatalf("unexpected topByAvgDuration: %+v", topByAvgDuration)
+	}
+
+	// Too small maxLifetime must filter out all the queries
+	if a := qst.getTopByMaxDuration(10, -time.Second); len(a) != 0 {
+		t.Fatalf("expecting empty topByMaxDuration for negative maxLifetime; got %+v", a)
+	}
+}
+
+func TestQueryStatsTrackerWriteJSON(t *testing.T) {
+	qst := newTestQueryStatsTracker(10)
+	qst.registerQuery("up", 300*1000, time.Now().Add(-time.Second))
+
+	var bb bytes.Buffer
+	qst.writeJSONQueryStats(&bb, 5, time.Minute)
+	var resp struct {
+		TopByCount       []map[string]interface{}
+		TopByAvgDuration []map[string]interface{}
+		TopBySumDuration []map[string]interface{}
+		TopByMaxDuration []map[string]interface{}
+	}
+	if err := json.Unmarshal(bb.Bytes(), &resp); err != nil {
+		t.Fatalf("cannot parse query stats response %q: %s", bb.String(), err)
+	}
+	for name, a := range map[string][]map[string]interface{}{
+		"topByCount":       resp.TopByCount,
+		"topByAvgDuration": resp.TopByAvgDuration,
+		"topBySumDuration": resp.TopBySumDuration,
+		"topByMaxDuration": resp.TopByMaxDuration,
+	} {
+		if len(a) != 1 {
+			t.Fatalf("unexpected number of entries in %s; got %d; want 1; response: %s", name, len(a), bb.String())
+		}
+		if a[0]["query"] != "up" || a[0]["timeRangeSeconds"] != float64(300) {
+			t.Fatalf("unexpected entry in %s: %v", name, a[0])
+		}
+	}
+	if _, ok := resp.TopByMaxDuration[0]["maxDurationSeconds"]; !ok {
+		t.Fatalf("missing maxDurationSeconds in topByMaxDuration: %v", resp.TopByMaxDuration[0])
+	}
+}
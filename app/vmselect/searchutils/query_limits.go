@@ -0,0 +1,102 @@
+package searchutils
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+)
+
+var limitsOverrideAuthKey = flag.String("search.limitsOverrideAuthKey", "", "Optional authKey for overriding per-query limits via X-Max-Samples-Per-Query, X-Max-Unique-Timeseries "+
+	"and X-Max-Memory-Per-Query request headers. The authKey must be passed in X-Limits-Auth-Key request header. Per-query limits cannot be overridden if this flag isn't set. "+
+	"See https://docs.victoriametrics.com/#query-resource-limits")
+
+// QueryLimits contains per-query limits, which override the corresponding command-line flags.
+//
+// Zero values mean that the limit from the corresponding command-line flag must be used.
+type QueryLimits struct {
+	// MaxSamplesPerQuery overrides -search.maxSamplesPerQuery.
+	MaxSamplesPerQuery int
+
+	// MaxUniqueTimeseries overrides -search.maxUniqueTimeseries.
+	MaxUniqueTimeseries int
+
+	// MaxMemoryPerQuery overrides -search.maxMemoryPerQuery.
+	MaxMemoryPerQuery int
+}
+
+// GetMaxSamplesPerQuery returns the maximum number of samples per query from ql.
+//
+// defaultValue is returned if ql is nil or if it doesn't override the limit.
+func (ql *QueryLimits) GetMaxSamplesPerQuery(defaultValue int) int {
+	if ql == nil || ql.MaxSamplesPerQuery <= 0 {
+		return defaultValue
+	}
+	return ql.MaxSamplesPerQuery
+}
+
+// GetMaxUniqueTimeseries returns the maximum number of unique time series per query from ql.
+//
+// defaultValue is returned if ql is nil or if it doesn't override the limit.
+func (ql *QueryLimits) GetMaxUniqueTimeseries(defaultValue int) int {
+	if ql == nil || ql.MaxUniqueTimeseries <= 0 {
+		return defaultValue
+	}
+	return ql.MaxUniqueTimeseries
+}
+
+// GetMaxMemoryPerQuery returns the maximum memory in bytes per query from ql.
+//
+// defaultValue is returned if ql is nil or if it doesn't override the limit.
+func (ql *QueryLimits) GetMaxMemoryPerQuery(defaultValue int) int {
+	if ql == nil || ql.MaxMemoryPerQuery <= 0 {
+		return defaultValue
+	}
+	return ql.MaxMemoryPerQuery
+}
+
+// GetQueryLimits returns per-query limits from X-Max-* request headers.
+//
+// nil is returned if r doesn't contain limit overrides.
+// An error is returned if r contains limit overrides, but X-Limits-Auth-Key header
+// doesn't match -search.limitsOverrideAuthKey.
+func GetQueryLimits(r *http.Request) (*QueryLimits, error) {
+	maxSamplesStr := r.Header.Get("X-Max-Samples-Per-Query")
+	maxSeriesStr := r.Header.Get("X-Max-Unique-Timeseries")
+	maxMemoryStr := r.Header.Get("X-Max-Memory-Per-Query")
+	if len(maxSamplesStr) == 0 && len(maxSeriesStr) == 0 && len(maxMemoryStr) == 0 {
+		// Fast path - there are no limit overrides.
+		return nil, nil
+	}
+	if len(*limitsOverrideAuthKey) == 0 {
+		return nil, fmt.Errorf("per-query limits cannot be overridden via X-Max-* request headers, since -search.limitsOverrideAuthKey command-line flag isn't set")
+	}
+	if authKey := r.Header.Get("X-Limits-Auth-Key"); authKey != *limitsOverrideAuthKey {
+		return nil, fmt.Errorf("invalid X-Limits-Auth-Key request header value %q; it must match -search.limitsOverrideAuthKey command-line flag", authKey)
+	}
+	var ql QueryLimits
+	if len(maxSamplesStr) > 0 {
+		n, err := strconv.Atoi(maxSamplesStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse X-Max-Samples-Per-Query request header %q: %w", maxSamplesStr, err)
+		}
+		ql.MaxSamplesPerQuery = n
+	}
+	if len(maxSeriesStr) > 0 {
+		n, err := strconv.Atoi(maxSeriesStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse X-Max-Unique-Timeseries request header %q: %w", maxSeriesStr, err)
+		}
+		ql.MaxUniqueTimeseries = n
+	}
+	if len(maxMemoryStr) > 0 {
+		var b flagutil.Bytes
+		if err := b.Set(maxMemoryStr); err != nil {
+			return nil, fmt.Errorf("cannot parse X-Max-Memory-Per-Query request header %q: %w", maxMemoryStr, err)
+		}
+		ql.MaxMemoryPerQuery = b.N
+	}
+	return &ql, nil
+}
@@ -0,0 +1,111 @@
+package searchutils
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGetQueryLimits(t *testing.T) {
+	origAuthKey := *limitsOverrideAuthKey
+	defer func() {
+		*limitsOverrideAuthKey = origAuthKey
+	}()
+
+	newRequest := func(headers map[string]string) *http.Request {
+		r, err := http.NewRequest("GET", "http://localhost:8428/api/v1/query", nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+	f := func(authKey string, headers map[string]string, qlExpected *QueryLimits) {
+		t.Helper()
+		*limitsOverrideAuthKey = authKey
+		ql, err := GetQueryLimits(newRequest(headers))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(ql, qlExpected) {
+			t.Fatalf("unexpected query limits; got %+v; want %+v", ql, qlExpected)
+		}
+	}
+	fError := func(authKey string, headers map[string]string) {
+		t.Helper()
+		*limitsOverrideAuthKey = authKey
+		ql, err := GetQueryLimits(newRequest(headers))
+		if err == nil {
+			t.Fatalf("expecting non-nil error; got query limits %+v", ql)
+		}
+	}
+
+	// No overrides
+	f("", nil, nil)
+	f("secret", nil, nil)
+
+	// Valid overrides
+	f("secret", map[string]string{
+		"X-Limits-Auth-Key":       "secret",
+		"X-Max-Samples-Per-Query": "1000",
+	}, &QueryLimits{
+		MaxSamplesPerQuery: 1000,
+	})
+	f("secret", map[string]string{
+		"X-Limits-Auth-Key":       "secret",
+		"X-Max-Unique-Timeseries": "10",
+		"X-Max-Memory-Per-Query":  "2MiB",
+	}, &QueryLimits{
+		MaxUniqueTimeseries: 10,
+		MaxMemoryPerQuery:   2 * 1024 * 1024,
+	})
+
+	// Overrides are disabled
+	fError("", map[string]string{
+		"X-Max-Samples-Per-Query": "1000",
+	})
+
+	// Invalid auth key
+	fError("secret", map[string]string{
+		"X-Max-Samples-Per-Query": "1000",
+	})
+	fError("secret", map[string]string{
+		"X-Limits-Auth-Key":       "foobar",
+		"X-Max-Samples-Per-Query": "1000",
+	})
+
+	// Invalid values
+	fError("secret", map[string]string{
+		"X-Limits-Auth-Key":       "secret",
+		"X-Max-Samples-Per-Query": "foo",
+	})
+	fError("secret", map[string]string{
+		"X-Limits-Auth-Key":       "secret",
+		"X-Max-Unique-Timeseries": "1.5",
+	})
+	fError("secret", map[string]string{
+		"X-Limits-Auth-Key":      "secret",
+		"X-Max-Memory-Per-Query": "10XB",
+	})
+}
+
+func TestQueryLimitsGetDefaults(t *testing.T) {
+	var ql *QueryLimits
+	if n := ql.GetMaxSamplesPerQuery(123); n != 123 {
+		t.Fatalf("unexpected MaxSamplesPerQuery for nil limits; got %d; want 123", n)
+	}
+	ql = &QueryLimits{
+		MaxSamplesPerQuery: 10,
+	}
+	if n := ql.GetMaxSamplesPerQuery(123); n != 10 {
+		t.Fatalf("unexpected MaxSamplesPerQuery; got %d; want 10", n)
+	}
+	if n := ql.GetMaxUniqueTimeseries(456); n != 456 {
+		t.Fatalf("unexpected MaxUniqueTimeseries; got %d; want 456", n)
+	}
+	if n := ql.GetMaxMemoryPerQuery(0); n != 0 {
+		t.Fatalf("unexpected MaxMemoryPerQuery; got %d; want 0", n)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
@@ -19,6 +20,9 @@ var (
 	maxExportDuration        = flag.Duration("search.maxExportDuration", time.Hour*24*30, "The maximum duration for /api/v1/export call")
 	maxQueryDuration         = flag.Duration("search.maxQueryDuration", time.Second*30, "The maximum duration for query execution")
 	maxStatusRequestDuration = flag.Duration("search.maxStatusRequestDuration", time.Minute*5, "The maximum duration for /api/v1/status/* requests")
+	denyPartialResponse      = flag.Bool("search.denyPartialResponse", false, "Whether to deny partial responses if some of -search.remoteSource are unavailable. "+
+		"By default partial responses are returned, since this improves availability at the cost of consistency. The flag can be overridden on a per-query basis via deny_partial_response query arg. "+
+		"See https://docs.victoriametrics.com/#partial-responses")
 )
 
 func roundToSeconds(ms int64) int64 {
@@ -161,12 +165,27 @@ func GetBool(r *http.Request, argKey string) bool {
 	}
 }
 
+// GetDenyPartialResponse returns whether partial responses are denied for r.
+//
+// The -search.denyPartialResponse value is returned if r doesn't contain deny_partial_response query arg.
+func GetDenyPartialResponse(r *http.Request) bool {
+	if r.FormValue("deny_partial_response") == "" {
+		return *denyPartialResponse
+	}
+	return GetBool(r, "deny_partial_response")
+}
+
 // Deadline contains deadline with the corresponding timeout for pretty error messages.
 type Deadline struct {
 	deadline uint64
 
 	timeout  time.Duration
 	flagHint string
+
+	// canceled is set to non-zero by Cancel call.
+	//
+	// It is shared among all the copies of the Deadline.
+	canceled *uint32
 }
 
 // NewDeadline returns deadline for the given timeout.
@@ -178,12 +197,25 @@ func NewDeadline(startTime time.Time, timeout time.Duration, flagHint string) De
 		deadline: uint64(startTime.Add(timeout).Unix()),
 		timeout:  timeout,
 		flagHint: flagHint,
+		canceled: new(uint32),
 	}
 }
 
-// Exceeded returns true if deadline is exceeded.
+// Exceeded returns true if deadline is exceeded or if d has been canceled via Cancel call.
 func (d *Deadline) Exceeded() bool {
-	return fasttime.UnixTimestamp() > d.deadline
+	return d.Canceled() || fasttime.UnixTimestamp() > d.deadline
+}
+
+// Cancel cancels d, so Exceeded returns true for d and all its copies.
+func (d *Deadline) Cancel() {
+	if d.canceled != nil {
+		atomic.StoreUint32(d.canceled, 1)
+	}
+}
+
+// Canceled returns true if d has been canceled via Cancel call.
+func (d *Deadline) Canceled() bool {
+	return d.canceled != nil && atomic.LoadUint32(d.canceled) != 0
 }
 
 // Deadline returns deadline in unix timestamp seconds.
@@ -193,6 +225,9 @@ func (d *Deadline) Deadline() uint64 {
 
 // String returns human-readable string representation for d.
 func (d *Deadline) String() string {
+	if d.Canceled() {
+		return "the query has been canceled via /api/v1/status/active_queries/cancel"
+	}
 	startTime := time.Unix(int64(d.deadline), 0).Add(-d.timeout)
 	elapsed := time.Since(startTime)
 	return fmt.Sprintf("%.3f seconds (elapsed %.3f seconds); the timeout can be adjusted with `%s` command-line flag", d.timeout.Seconds(), elapsed.Seconds(), d.flagHint)
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
@@ -124,3 +126,28 @@ func TestGetEnforcedTagFiltersFromRequest(t *testing.T) {
 	f(t, &http.Request{},
 		nil, false)
 }
+
+func TestDeadlineCancel(t *testing.T) {
+	d := NewDeadline(time.Now(), time.Hour, "-search.maxQueryDuration")
+	if d.Exceeded() {
+		t.Fatalf("deadline mustn't be exceeded")
+	}
+	dCopy := d
+	d.Cancel()
+	if !d.Canceled() || !d.Exceeded() {
+		t.Fatalf("deadline must be exceeded after Cancel call")
+	}
+	if !dCopy.Canceled() || !dCopy.Exceeded() {
+		t.Fatalf("deadline copy must be exceeded after Cancel call on the original deadline")
+	}
+	if s := d.String(); !strings.Contains(s, "canceled") {
+		t.Fatalf("unexpected string representation for canceled deadline: %q", s)
+	}
+
+	// Zero Deadline must be safe to cancel.
+	var dZero Deadline
+	dZero.Cancel()
+	if dZero.Canceled() {
+		t.Fatalf("zero deadline cannot be canceled")
+	}
+}
@@ -3,17 +3,22 @@ package vmstorage
 import (
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/mergeset"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/syncwg"
 	"github.com/VictoriaMetrics/metrics"
@@ -22,13 +27,22 @@ import (
 var (
 	retentionPeriod   = flagutil.NewDuration("retentionPeriod", 1, "Data with timestamps outside the retentionPeriod is automatically deleted")
 	snapshotAuthKey   = flag.String("snapshotAuthKey", "", "authKey, which must be passed in query string to /snapshot* pages")
-	forceMergeAuthKey = flag.String("forceMergeAuthKey", "", "authKey, which must be passed in query string to /internal/force_merge pages")
+	snapshotsInterval = flag.Duration("snapshotsInterval", 0, "Interval for automatic creation of snapshots. Automatic snapshots are disabled if set to 0. "+
+		"See also -snapshotsMaxAge and https://docs.victoriametrics.com/#how-to-work-with-snapshots")
+	snapshotsMaxAge = flag.Duration("snapshotsMaxAge", 0, "Automatically delete snapshots older than -snapshotsMaxAge if it is set to non-zero duration. "+
+		"Make sure that backup process has enough time to finish the backup before the corresponding snapshot is automatically deleted")
+	forceMergeAuthKey = flag.String("forceMergeAuthKey", "", "authKey, which must be passed in query string to /internal/force_merge and /internal/indexdb/compact pages")
 	forceFlushAuthKey = flag.String("forceFlushAuthKey", "", "authKey, which must be passed in query string to /internal/force_flush pages")
+	partitionAuthKey  = flag.String("partitionAuthKey", "", "authKey, which must be passed in query string to /internal/partition/* pages")
 
 	precisionBits = flag.Int("precisionBits", 64, "The number of precision bits to store per each value. Lower precision bits improves data compression at the cost of precision loss")
 
 	// DataPath is a path to storage data.
-	DataPath = flag.String("storageDataPath", "victoria-metrics-data", "Path to storage data")
+	//
+	// It contains only the first path after InitWithoutMetrics call if multiple comma-separated paths are passed to -storageDataPath.
+	DataPath = flag.String("storageDataPath", "victoria-metrics-data", "Path to storage data. Multiple comma-separated paths may be passed in order to spread partitions "+
+		"among multiple disks. The first path is used for indexdb, caches and snapshots, while new partitions are created at the path with the most free disk space. "+
+		"See https://docs.victoriametrics.com/#multiple-data-paths")
 
 	finalMergeDelay = flag.Duration("finalMergeDelay", 0, "The delay before starting final merge for per-month partition after no new data is ingested into it. "+
 		"Final merge may require additional disk IO and CPU resources. Final merge may increase query speed and reduce disk space usage in some cases. "+
@@ -36,6 +50,17 @@ var (
 	bigMergeConcurrency   = flag.Int("bigMergeConcurrency", 0, "The maximum number of CPU cores to use for big merges. Default value is used if set to 0")
 	smallMergeConcurrency = flag.Int("smallMergeConcurrency", 0, "The maximum number of CPU cores to use for small merges. Default value is used if set to 0")
 
+	inmemoryDataFlushInterval = flag.Duration("inmemoryDataFlushInterval", 5*time.Second, "The interval for guaranteed saving of in-memory data to disk. "+
+		"The saved data survives unclean shutdown such as OOM crash, hardware reset, SIGKILL, etc. Bigger intervals reduce disk write amplification "+
+		"at the cost of bigger data loss on unclean shutdown. Minimum supported value is 1s. See https://docs.victoriametrics.com/#in-memory-data")
+	inmemoryDataMaxSize = flagutil.NewBytes("inmemoryDataMaxSize", 0, "The maximum size of in-memory data per each per-month partition. "+
+		"In-memory data exceeding this size is saved to disk without waiting for -inmemoryDataFlushInterval. "+
+		"By default it is set to 1/16 of memory allowed via -memory.allowedPercent or -memory.allowedBytes. See https://docs.victoriametrics.com/#in-memory-data")
+	bigMergeMaxBytesPerSecond = flagutil.NewBytes("bigMergeMaxBytesPerSecond", 0, "The maximum write bandwidth shared by all the merges into big parts. "+
+		"This may be useful for reducing the impact of background merges on query performance when disk IO is limited. The bandwidth isn't limited if set to 0")
+	bigMergeWindow = flag.String("bigMergeWindow", "", "Daily time window in UTC for merging big parts in the format HH:MM-HH:MM, for example, 22:00-06:00. "+
+		"Big parts are merged at any time if the window isn't set. See https://docs.victoriametrics.com/#merge-throttling")
+
 	logNewSeries = flag.Bool("logNewSeries", false, "Whether to log new series. This option is for debug purposes only. It can lead to performance issues "+
 		"when big number of new series are ingested into VictoriaMetrics")
 	denyQueriesOutsideRetention = flag.Bool("denyQueriesOutsideRetention", false, "Whether to deny queries outside of the configured -retentionPeriod. "+
@@ -45,6 +70,45 @@ var (
 		"Excess series are logged and dropped. This can be useful for limiting series cardinality. See also -storage.maxDailySeries")
 	maxDailySeries = flag.Int("storage.maxDailySeries", 0, "The maximum number of unique series can be added to the storage during the last 24 hours. "+
 		"Excess series are logged and dropped. This can be useful for limiting series churn rate. See also -storage.maxHourlySeries")
+	maxDaysForPerDaySearch = flag.Int("storage.maxDaysForPerDayIndexSearch", 40, "The maximum number of days in the query time range for searching time series via per-day index. "+
+		"Queries over longer time ranges search time series via the global index, which may be slow under high churn rate. "+
+		"See https://docs.victoriametrics.com/#per-day-index")
+	minFreeDiskSpaceBytes = flagutil.NewBytes("storage.minFreeDiskSpaceBytes", 10e6, "The minimum free disk space at -storageDataPath after which the storage stops accepting new data "+
+		"and continues serving queries. See https://docs.victoriametrics.com/#readonly-mode")
+	tsidCachePercent = flag.Float64("storage.tsidCachePercent", 35, "The size of MetricName->TSID cache as a percent of memory allowed via -memory.allowedPercent or -memory.allowedBytes. "+
+		"See https://docs.victoriametrics.com/#cache-tuning")
+	metricNameCachePercent = flag.Float64("storage.metricNameCachePercent", 10, "The size of MetricID->MetricName cache as a percent of memory allowed via -memory.allowedPercent or -memory.allowedBytes. "+
+		"See https://docs.victoriametrics.com/#cache-tuning")
+	indexBlocksCachePercent = flag.Float64("storage.indexBlocksCachePercent", 25, "The size of per-part caches for index blocks as a percent of memory allowed via -memory.allowedPercent or -memory.allowedBytes. "+
+		"See https://docs.victoriametrics.com/#cache-tuning")
+	dataBlocksCachePercent = flag.Float64("storage.dataBlocksCachePercent", 25, "The size of per-part caches for indexdb data blocks as a percent of memory allowed via -memory.allowedPercent or -memory.allowedBytes. "+
+		"See https://docs.victoriametrics.com/#cache-tuning")
+	staleMarkers = flag.String("storage.staleMarkers", "keep", "How to handle Prometheus staleness markers. Supported values: keep, drop, seriesEnd. "+
+		"See https://docs.victoriametrics.com/#staleness-markers")
+	encryptionKeyFile = flag.String("storage.encryptionKeyFile", "", "Path to file with keys for encrypting data and index parts at rest. Every line must contain a key in the format <key_id>:<base64-encoded 256-bit key>. "+
+		"The last key is used for encrypting new parts. See https://docs.victoriametrics.com/#encryption-at-rest")
+	encryptionKeyCommand = flag.String("storage.encryptionKeyCommand", "", "Shell command, which must print keys for encrypting data and index parts at rest to stdout in the same format as -storage.encryptionKeyFile. "+
+		"This allows obtaining keys from external key management systems. See https://docs.victoriametrics.com/#encryption-at-rest")
+	retentionFilters = flagutil.NewArray("retentionFilter", "Retention filter in the format <series_selector>:<retention>, for example, '{env=\"dev\"}:7d'. "+
+		"Time series matching the series selector are deleted after the given retention, which must be smaller than -retentionPeriod. "+
+		"The first matching filter is used if a time series matches multiple filters. See https://docs.victoriametrics.com/#retention-filters for details")
+	indexDBCompactionInterval = flag.Duration("storage.indexDBCompactionInterval", time.Hour, "The interval for checking whether indexdb must be compacted in background "+
+		"in order to remove entries for deleted series and for dates outside -retentionPeriod. Background compaction is disabled if set to 0. "+
+		"See https://docs.victoriametrics.com/#indexdb-compaction")
+	nanosecondPrecisionMetricPrefixes = flagutil.NewArray("storage.nanosecondPrecisionMetricPrefix", "Metric name prefix for time series, which must be stored with nanosecond timestamps. "+
+		"Timestamps for the remaining time series are stored with millisecond precision. See https://docs.victoriametrics.com/#nanosecond-timestamps")
+	maxExemplarsMemory = flagutil.NewBytes("storage.maxExemplarsMemory", 32*1024*1024, "The maximum memory, which can be occupied by exemplars across all the time series. "+
+		"Exemplars for the least recently updated time series are dropped when the limit is exceeded. Exemplars aren't stored if it is set to 0. "+
+		"See https://docs.victoriametrics.com/#exemplars")
+	maxExemplarsPerSeries = flag.Int("storage.maxExemplarsPerSeries", 10, "The maximum number of the most recent exemplars to store per each time series. "+
+		"See https://docs.victoriametrics.com/#exemplars")
+	maxMetricsMetadataMemory = flagutil.NewBytes("storage.maxMetricsMetadataMemory", 16*1024*1024, "The maximum memory, which can be occupied by metric metadata "+
+		"collected from HELP, TYPE and UNIT lines. The least recently seen metadata entries are dropped when the limit is exceeded. "+
+		"See https://docs.victoriametrics.com/#metric-metadata")
+	checkDataIntegrity = flag.Bool("checkDataIntegrity", false, "Whether to verify all the data parts at -storageDataPath on startup before opening the storage. "+
+		"Parts with corrupted data are logged. See also -checkDataIntegrity.quarantine and https://docs.victoriametrics.com/#data-integrity-check")
+	checkDataIntegrityQuarantine = flag.Bool("checkDataIntegrity.quarantine", false, "Whether to move parts with corrupted data detected by -checkDataIntegrity "+
+		"to <-storageDataPath>/quarantine directory. The data from quarantined parts becomes unavailable for querying. See https://docs.victoriametrics.com/#data-integrity-check")
 )
 
 // CheckTimeRange returns true if the given tr is denied for querying.
@@ -78,9 +142,45 @@ func InitWithoutMetrics(resetCacheIfNeeded func(mrs []storage.MetricRow)) {
 
 	resetResponseCacheIfNeeded = resetCacheIfNeeded
 	storage.SetLogNewSeries(*logNewSeries)
+	storage.SetMaxDaysForPerDaySearch(*maxDaysForPerDaySearch)
+	storage.SetFreeDiskSpaceLimit(int64(minFreeDiskSpaceBytes.N))
+	initDataPaths()
+	storage.SetTSIDCachePercent(mustGetCachePercent("storage.tsidCachePercent", *tsidCachePercent))
+	storage.SetMetricNameCachePercent(mustGetCachePercent("storage.metricNameCachePercent", *metricNameCachePercent))
+	storage.SetIndexBlocksCachePercent(mustGetCachePercent("storage.indexBlocksCachePercent", *indexBlocksCachePercent))
+	mergeset.SetIndexBlocksCachePercent(*indexBlocksCachePercent)
+	mergeset.SetDataBlocksCachePercent(mustGetCachePercent("storage.dataBlocksCachePercent", *dataBlocksCachePercent))
+	if err := storage.SetStaleMarkersMode(*staleMarkers); err != nil {
+		logger.Fatalf("cannot parse -storage.staleMarkers: %s", err)
+	}
+	if err := encryption.Init(*encryptionKeyFile, *encryptionKeyCommand); err != nil {
+		logger.Fatalf("cannot initialize encryption keys from -storage.encryptionKeyFile or -storage.encryptionKeyCommand: %s", err)
+	}
+	if encryption.IsEnabled() {
+		logger.Infof("new data and index parts are encrypted with the key %q", encryption.CurrentKeyID())
+	}
 	storage.SetFinalMergeDelay(*finalMergeDelay)
+	storage.SetInmemoryDataFlushInterval(*inmemoryDataFlushInterval)
+	storage.SetInmemoryDataMaxSize(int64(inmemoryDataMaxSize.N))
 	storage.SetBigMergeWorkersCount(*bigMergeConcurrency)
 	storage.SetSmallMergeWorkersCount(*smallMergeConcurrency)
+	storage.SetBigMergeMaxBytesPerSecond(int64(bigMergeMaxBytesPerSecond.N))
+	storage.SetIndexDBCompactionInterval(*indexDBCompactionInterval)
+	storage.SetExemplarsLimits(maxExemplarsMemory.N, *maxExemplarsPerSeries)
+	storage.SetMetricsMetadataMaxSizeBytes(maxMetricsMetadataMemory.N)
+	if err := storage.SetBigMergeWindow(*bigMergeWindow); err != nil {
+		logger.Fatalf("cannot parse -bigMergeWindow: %s", err)
+	}
+	if err := storage.SetRetentionFilters(*retentionFilters); err != nil {
+		logger.Fatalf("cannot parse -retentionFilter: %s", err)
+	}
+	if err := storage.SetNanosecondPrecisionMetricPrefixes(*nanosecondPrecisionMetricPrefixes); err != nil {
+		logger.Fatalf("cannot parse -storage.nanosecondPrecisionMetricPrefix: %s", err)
+	}
+	initPartitionTier()
+	if *checkDataIntegrity {
+		mustCheckDataIntegrity()
+	}
 
 	logger.Infof("opening storage at %q with -retentionPeriod=%s", *DataPath, retentionPeriod)
 	startTime := time.Now()
@@ -100,6 +200,35 @@ func InitWithoutMetrics(resetCacheIfNeeded func(mrs []storage.MetricRow)) {
 	sizeBytes := tm.SmallSizeBytes + tm.BigSizeBytes
 	logger.Infof("successfully opened storage %q in %.3f seconds; partsCount: %d; blocksCount: %d; rowsCount: %d; sizeBytes: %d",
 		*DataPath, time.Since(startTime).Seconds(), partsCount, blocksCount, rowsCount, sizeBytes)
+
+	startSnapshotsScheduler()
+}
+
+// extraDataPaths contains data paths passed to -storageDataPath after the first path.
+var extraDataPaths []string
+
+func initDataPaths() {
+	paths := strings.Split(*DataPath, ",")
+	for i, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			logger.Fatalf("-storageDataPath cannot contain empty paths; got %q", *DataPath)
+		}
+		paths[i] = path
+	}
+	*DataPath = paths[0]
+	extraDataPaths = paths[1:]
+	storage.SetExtraDataPaths(extraDataPaths)
+	if len(extraDataPaths) > 0 {
+		logger.Infof("spreading new partitions among data paths %q", paths)
+	}
+}
+
+func mustGetCachePercent(flagName string, percent float64) float64 {
+	if percent <= 0 || percent > 100 {
+		logger.Fatalf("-%s must be in the range (0...100]; got %g", flagName, percent)
+	}
+	return percent
 }
 
 // Storage is a storage.
@@ -125,6 +254,46 @@ func AddRows(mrs []storage.MetricRow) error {
 	return err
 }
 
+func mustCheckDataIntegrity() {
+	mustCheckDataIntegrityAt(*DataPath)
+	for _, path := range extraDataPaths {
+		mustCheckDataIntegrityAt(path)
+	}
+}
+
+func mustCheckDataIntegrityAt(path string) {
+	logger.Infof("checking data integrity at %q", path)
+	startTime := time.Now()
+	result, err := storage.CheckDataIntegrity(path, *checkDataIntegrityQuarantine)
+	if err != nil {
+		logger.Fatalf("cannot check data integrity at %q: %s", path, err)
+	}
+	for _, cp := range result.CorruptedParts {
+		if cp.QuarantinePath != "" {
+			logger.Errorf("found %d corrupted blocks in part %q; the part has been moved to %q; error: %s", cp.CorruptedBlocks, cp.Path, cp.QuarantinePath, cp.Err)
+		} else {
+			logger.Errorf("found %d corrupted blocks in part %q; error: %s", cp.CorruptedBlocks, cp.Path, cp.Err)
+		}
+	}
+	logger.Infof("checked data integrity at %q in %.3f seconds; partsChecked: %d, partsWithoutChecksums: %d, blocksChecked: %d, corruptedParts: %d",
+		path, time.Since(startTime).Seconds(), result.PartsChecked, result.PartsWithoutChecksums, result.BlocksChecked, len(result.CorruptedParts))
+}
+
+// AddExemplars adds exemplars from ers to the storage.
+func AddExemplars(ers []storage.ExemplarRow) error {
+	WG.Add(1)
+	err := Storage.AddExemplars(ers)
+	WG.Done()
+	return err
+}
+
+// AddMetricsMetadata adds metric metadata rows to the storage.
+func AddMetricsMetadata(rows []prommetadata.Row) {
+	WG.Add(1)
+	Storage.AddMetricsMetadata(rows)
+	WG.Done()
+}
+
 // RegisterMetricNames registers all the metrics from mrs in the storage.
 func RegisterMetricNames(mrs []storage.MetricRow) error {
 	WG.Add(1)
@@ -143,6 +312,16 @@ func DeleteMetrics(tfss []*storage.TagFilters) (int, error) {
 	return n, err
 }
 
+// DeleteMetricsOnTimeRange deletes samples on the given tr for metrics matching tfss.
+//
+// Returns the number of metrics with deleted samples.
+func DeleteMetricsOnTimeRange(tfss []*storage.TagFilters, tr storage.TimeRange) (int, error) {
+	WG.Add(1)
+	n, err := Storage.DeleteMetricsOnTimeRange(tfss, tr)
+	WG.Done()
+	return n, err
+}
+
 // SearchMetricNames returns metric names for the given tfss on the given tr.
 func SearchMetricNames(tfss []*storage.TagFilters, tr storage.TimeRange, maxMetrics int, deadline uint64) ([]storage.MetricName, error) {
 	WG.Add(1)
@@ -151,6 +330,22 @@ func SearchMetricNames(tfss []*storage.TagFilters, tr storage.TimeRange, maxMetr
 	return mns, err
 }
 
+// SearchExemplars returns exemplars on the given tr for time series matching the given tfss.
+func SearchExemplars(tfss []*storage.TagFilters, tr storage.TimeRange, maxMetrics int, deadline uint64) ([]storage.MetricExemplars, error) {
+	WG.Add(1)
+	mes, err := Storage.SearchExemplars(tfss, tr, maxMetrics, deadline)
+	WG.Done()
+	return mes, err
+}
+
+// SearchMetricsMetadata returns metric metadata rows sorted by metric name.
+func SearchMetricsMetadata(metric string, limit, limitPerMetric int) []prommetadata.Row {
+	WG.Add(1)
+	rows := Storage.SearchMetricsMetadata(metric, limit, limitPerMetric)
+	WG.Done()
+	return rows
+}
+
 // SearchTagKeysOnTimeRange searches for tag keys on tr.
 func SearchTagKeysOnTimeRange(tr storage.TimeRange, maxTagKeys int, deadline uint64) ([]string, error) {
 	WG.Add(1)
@@ -183,6 +378,16 @@ func SearchTagValues(tagKey []byte, maxTagValues int, deadline uint64) ([]string
 	return values, err
 }
 
+// SearchTagValuesWithPrefix returns up to maxTagValues tag values for the given tagKey starting with the given tagValuePrefix on the given tr.
+//
+// If tfss is non-empty, then only tag values for time series matching tfss are returned.
+func SearchTagValuesWithPrefix(tr storage.TimeRange, tagKey, tagValuePrefix []byte, tfss []*storage.TagFilters, maxTagValues, maxMetrics int, deadline uint64) ([]string, error) {
+	WG.Add(1)
+	values, err := Storage.SearchTagValuesWithPrefix(tr, tagKey, tagValuePrefix, tfss, maxTagValues, maxMetrics, deadline)
+	WG.Done()
+	return values, err
+}
+
 // SearchTagValueSuffixes returns all the tag value suffixes for the given tagKey and tagValuePrefix on the given tr.
 //
 // This allows implementing https://graphite-api.readthedocs.io/en/latest/api.html#metrics-find or similar APIs.
@@ -210,17 +415,17 @@ func SearchTagEntries(maxTagKeys, maxTagValues int, deadline uint64) ([]storage.
 }
 
 // GetTSDBStatusForDate returns TSDB status for the given date.
-func GetTSDBStatusForDate(date uint64, topN int, deadline uint64) (*storage.TSDBStatus, error) {
+func GetTSDBStatusForDate(date uint64, focusLabel string, topN int, diskUsage bool, deadline uint64) (*storage.TSDBStatus, error) {
 	WG.Add(1)
-	status, err := Storage.GetTSDBStatusWithFiltersForDate(nil, date, topN, deadline)
+	status, err := Storage.GetTSDBStatusWithFiltersForDate(nil, date, focusLabel, topN, diskUsage, deadline)
 	WG.Done()
 	return status, err
 }
 
 // GetTSDBStatusWithFiltersForDate returns TSDB status for given filters on the given date.
-func GetTSDBStatusWithFiltersForDate(tfss []*storage.TagFilters, date uint64, topN int, deadline uint64) (*storage.TSDBStatus, error) {
+func GetTSDBStatusWithFiltersForDate(tfss []*storage.TagFilters, date uint64, focusLabel string, topN int, diskUsage bool, deadline uint64) (*storage.TSDBStatus, error) {
 	WG.Add(1)
-	status, err := Storage.GetTSDBStatusWithFiltersForDate(tfss, date, topN, deadline)
+	status, err := Storage.GetTSDBStatusWithFiltersForDate(tfss, date, focusLabel, topN, diskUsage, deadline)
 	WG.Done()
 	return status, err
 }
@@ -237,6 +442,7 @@ func GetSeriesCount(deadline uint64) (uint64, error) {
 func Stop() {
 	logger.Infof("gracefully closing the storage at %s", *DataPath)
 	startTime := time.Now()
+	stopSnapshotsScheduler()
 	WG.WaitAndBlock()
 	Storage.MustClose()
 	logger.Infof("successfully closed the storage in %.3f seconds", time.Since(startTime).Seconds())
@@ -267,6 +473,20 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		}()
 		return true
 	}
+	if path == "/internal/indexdb/compact" {
+		authKey := r.FormValue("authKey")
+		if authKey != *forceMergeAuthKey {
+			httpserver.Errorf(w, r, "invalid authKey %q. It must match the value from -forceMergeAuthKey command line flag", authKey)
+			return true
+		}
+		// Run indexdb compaction in background
+		go func() {
+			if err := Storage.CompactIndexDB(); err != nil {
+				logger.Errorf("error in indexdb compaction: %s", err)
+			}
+		}()
+		return true
+	}
 	if path == "/internal/force_flush" {
 		authKey := r.FormValue("authKey")
 		if authKey != *forceFlushAuthKey {
@@ -274,7 +494,25 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		logger.Infof("flushing storage to make pending data available for reading")
+		startTime := time.Now()
 		Storage.DebugFlush()
+		logger.Infof("storage has been flushed in %.3f seconds", time.Since(startTime).Seconds())
+		return true
+	}
+	if strings.HasPrefix(path, "/internal/partition/") {
+		authKey := r.FormValue("authKey")
+		if authKey != *partitionAuthKey {
+			httpserver.Errorf(w, r, "invalid authKey %q. It must match the value from -partitionAuthKey command line flag", authKey)
+			return true
+		}
+		return partitionHandler(w, r, path[len("/internal/partition"):])
+	}
+	if path == "/api/v1/status/series_limits" {
+		seriesLimitsStatusRequests.Inc()
+		if err := seriesLimitsStatusHandler(w, r); err != nil {
+			seriesLimitsStatusErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+		}
 		return true
 	}
 	prometheusCompatibleResponse := false
@@ -359,6 +597,152 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 
 var activeForceMerges = metrics.NewCounter("vm_active_force_merges")
 
+func partitionHandler(w http.ResponseWriter, r *http.Request, path string) bool {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch path {
+	case "/detach", "/export":
+		partitionName := r.FormValue("partition")
+		var dir string
+		var err error
+		if path == "/detach" {
+			dir, err = Storage.DetachPartition(partitionName)
+		} else {
+			dir, err = Storage.ExportPartition(partitionName)
+		}
+		if err != nil {
+			err = fmt.Errorf("cannot %s partition %q: %w", path[1:], partitionName, err)
+			jsonResponseError(w, err)
+			return true
+		}
+		fmt.Fprintf(w, `{"status":"ok","path":%q}`, dir)
+		return true
+	case "/attach":
+		dir := r.FormValue("path")
+		if err := Storage.AttachPartition(dir); err != nil {
+			err = fmt.Errorf("cannot attach partition from %q: %w", dir, err)
+			jsonResponseError(w, err)
+			return true
+		}
+		fmt.Fprintf(w, `{"status":"ok"}`)
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	snapshotsSchedulerStopCh chan struct{}
+	snapshotsSchedulerWG     sync.WaitGroup
+)
+
+// startSnapshotsScheduler starts periodic creation of snapshots according to -snapshotsInterval
+// and periodic deletion of snapshots older than -snapshotsMaxAge.
+func startSnapshotsScheduler() {
+	if *snapshotsInterval <= 0 && *snapshotsMaxAge <= 0 {
+		return
+	}
+	snapshotsSchedulerStopCh = make(chan struct{})
+	snapshotsSchedulerWG.Add(1)
+	go func() {
+		defer snapshotsSchedulerWG.Done()
+		snapshotsScheduler(snapshotsSchedulerStopCh)
+	}()
+}
+
+func stopSnapshotsScheduler() {
+	if snapshotsSchedulerStopCh == nil {
+		return
+	}
+	close(snapshotsSchedulerStopCh)
+	snapshotsSchedulerWG.Wait()
+	snapshotsSchedulerStopCh = nil
+}
+
+func snapshotsScheduler(stopCh <-chan struct{}) {
+	interval := *snapshotsInterval
+	if interval <= 0 {
+		// Only delete stale snapshots.
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		if *snapshotsInterval > 0 {
+			snapshotName, err := Storage.CreateSnapshot()
+			if err != nil {
+				snapshotsSchedulerErrors.Inc()
+				logger.Errorf("cannot create snapshot according to -snapshotsInterval=%s: %s", *snapshotsInterval, err)
+			} else {
+				snapshotsCreated.Inc()
+				logger.Infof("created snapshot %q according to -snapshotsInterval=%s", snapshotName, *snapshotsInterval)
+			}
+		}
+		if *snapshotsMaxAge > 0 {
+			if err := Storage.DeleteStaleSnapshots(*snapshotsMaxAge); err != nil {
+				snapshotsSchedulerErrors.Inc()
+				logger.Errorf("cannot delete snapshots older than -snapshotsMaxAge=%s: %s", *snapshotsMaxAge, err)
+			}
+		}
+	}
+}
+
+var (
+	snapshotsCreated         = metrics.NewCounter(`vm_snapshots_created_total{type="scheduled"}`)
+	snapshotsSchedulerErrors = metrics.NewCounter(`vm_snapshots_scheduler_errors_total`)
+)
+
+var (
+	seriesLimitsStatusRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/series_limits"}`)
+	seriesLimitsStatusErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/series_limits"}`)
+)
+
+// seriesLimitsStatusHandler returns the status for -storage.maxHourlySeries and -storage.maxDailySeries limits
+// together with metric names, which contribute the most new series during the current interval.
+func seriesLimitsStatusHandler(w http.ResponseWriter, r *http.Request) error {
+	topN := 10
+	if s := r.FormValue("topN"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("cannot parse `topN` query arg %q: %w", s, err)
+		}
+		if n <= 0 {
+			n = 1
+		}
+		if n > 1000 {
+			n = 1000
+		}
+		topN = n
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, `{"status":"success","data":{"hourly":`)
+	writeSeriesLimitStatus(w, Storage.GetHourlySeriesLimitStatus(topN))
+	fmt.Fprintf(w, `,"daily":`)
+	writeSeriesLimitStatus(w, Storage.GetDailySeriesLimitStatus(topN))
+	fmt.Fprintf(w, `}}`)
+	return nil
+}
+
+func writeSeriesLimitStatus(w io.Writer, sls *storage.SeriesLimitStatus) {
+	if sls == nil {
+		fmt.Fprintf(w, `null`)
+		return
+	}
+	fmt.Fprintf(w, `{"maxSeries":%d,"currentSeries":%d,"rowsDropped":%d,"windowStartTime":%d,"windowEndTime":%d,"untrackedNewSeries":%d,"topMetricNamesByNewSeries":[`,
+		sls.MaxSeries, sls.CurrentSeries, sls.RowsDropped, sls.WindowStart, sls.WindowStart+uint64(sls.Interval.Seconds()), sls.SkippedNewSeries)
+	for i, e := range sls.TopMetricNames {
+		if i > 0 {
+			fmt.Fprintf(w, `,`)
+		}
+		fmt.Fprintf(w, `{"name":%q,"newSeries":%d,"rowsDropped":%d}`, e.Name, e.NewSeries, e.RowsDropped)
+	}
+	fmt.Fprintf(w, `]}`)
+}
+
 func registerStorageMetrics() {
 	mCache := &storage.Metrics{}
 	var mCacheLock sync.Mutex
@@ -388,6 +772,12 @@ func registerStorageMetrics() {
 	metrics.NewGauge(fmt.Sprintf(`vm_free_disk_space_bytes{path=%q}`, *DataPath), func() float64 {
 		return float64(fs.MustGetFreeSpace(*DataPath))
 	})
+	for _, path := range extraDataPaths {
+		path := path
+		metrics.NewGauge(fmt.Sprintf(`vm_free_disk_space_bytes{path=%q}`, path), func() float64 {
+			return float64(fs.MustGetFreeSpace(path))
+		})
+	}
 
 	metrics.NewGauge(`vm_active_merges{type="storage/big"}`, func() float64 {
 		return float64(tm().ActiveBigMerges)
@@ -405,6 +795,9 @@ func registerStorageMetrics() {
 	metrics.NewGauge(`vm_merges_total{type="storage/small"}`, func() float64 {
 		return float64(tm().SmallMergesCount)
 	})
+	metrics.NewGauge(`vm_merges_total{type="storage/inmemory"}`, func() float64 {
+		return float64(tm().InmemoryMergesCount)
+	})
 	metrics.NewGauge(`vm_merges_total{type="indexdb"}`, func() float64 {
 		return float64(idbm().MergesCount)
 	})
@@ -415,6 +808,9 @@ func registerStorageMetrics() {
 	metrics.NewGauge(`vm_rows_merged_total{type="storage/small"}`, func() float64 {
 		return float64(tm().SmallRowsMerged)
 	})
+	metrics.NewGauge(`vm_rows_merged_total{type="storage/inmemory"}`, func() float64 {
+		return float64(tm().InmemoryRowsMerged)
+	})
 	metrics.NewGauge(`vm_rows_merged_total{type="indexdb"}`, func() float64 {
 		return float64(idbm().ItemsMerged)
 	})
@@ -425,6 +821,9 @@ func registerStorageMetrics() {
 	metrics.NewGauge(`vm_rows_deleted_total{type="storage/small"}`, func() float64 {
 		return float64(tm().SmallRowsDeleted)
 	})
+	metrics.NewGauge(`vm_rows_deleted_total{type="storage/inmemory"}`, func() float64 {
+		return float64(tm().InmemoryRowsDeleted)
+	})
 
 	metrics.NewGauge(`vm_references{type="storage/big", name="parts"}`, func() float64 {
 		return float64(tm().BigPartsRefCount)
@@ -442,6 +841,25 @@ func registerStorageMetrics() {
 		return float64(idbm().PartsRefCount)
 	})
 
+	metrics.NewGauge(`vm_tiered_partitions`, func() float64 {
+		return float64(tm().TieredPartitions)
+	})
+	metrics.NewGauge(`vm_tiering_cache_partitions`, func() float64 {
+		return float64(tm().TieringCachedPartitions)
+	})
+	metrics.NewGauge(`vm_tiering_cache_size_bytes`, func() float64 {
+		return float64(tm().TieringCacheSizeBytes)
+	})
+	metrics.NewGauge(`vm_tiering_uploads_total`, func() float64 {
+		return float64(tm().TieringUploads)
+	})
+	metrics.NewGauge(`vm_tiering_downloads_total`, func() float64 {
+		return float64(tm().TieringDownloads)
+	})
+	metrics.NewGauge(`vm_tiering_refused_downloads_total`, func() float64 {
+		return float64(tm().TieringRefusedDownloads)
+	})
+
 	metrics.NewGauge(`vm_new_timeseries_created_total`, func() float64 {
 		return float64(idbm().NewTimeseriesCreated)
 	})
@@ -454,6 +872,9 @@ func registerStorageMetrics() {
 	metrics.NewGauge(`vm_index_blocks_with_metric_ids_incorrect_order_total`, func() float64 {
 		return float64(idbm().IndexBlocksWithMetricIDsIncorrectOrder)
 	})
+	metrics.NewGauge(`vm_indexdb_compaction_items_removed_total`, func() float64 {
+		return float64(idbm().CompactionItemsRemoved)
+	})
 	metrics.NewGauge(`vm_composite_index_min_timestamp`, func() float64 {
 		return float64(idbm().MinTimestampForCompositeIndex) / 1e3
 	})
@@ -529,6 +950,56 @@ func registerStorageMetrics() {
 	metrics.NewGauge(`vm_rows_ignored_total{reason="small_timestamp"}`, func() float64 {
 		return float64(m().TooSmallTimestampRows)
 	})
+	metrics.NewGauge(`vm_rows_ignored_total{reason="tiered_partition"}`, func() float64 {
+		return float64(m().TieredTimestampRows + tm().TieringIgnoredRows)
+	})
+	metrics.NewGauge(`vm_rows_ignored_total{reason="read_only"}`, func() float64 {
+		return float64(m().ReadOnlyRowsRejected)
+	})
+	metrics.NewGauge(`vm_rows_ignored_total{reason="stale_marker"}`, func() float64 {
+		return float64(m().StaleMarkersDropped)
+	})
+
+	metrics.NewGauge(`vm_exemplars`, func() float64 {
+		return float64(m().ExemplarsCount)
+	})
+	metrics.NewGauge(`vm_exemplars_series`, func() float64 {
+		return float64(m().ExemplarsSeries)
+	})
+	metrics.NewGauge(`vm_exemplars_size_bytes`, func() float64 {
+		return float64(m().ExemplarsSizeBytes)
+	})
+	metrics.NewGauge(`vm_exemplars_max_size_bytes`, func() float64 {
+		return float64(m().ExemplarsMaxSizeBytes)
+	})
+	metrics.NewGauge(`vm_exemplars_added_total`, func() float64 {
+		return float64(m().ExemplarsAdded)
+	})
+	metrics.NewGauge(`vm_exemplars_ignored_total{reason="out_of_order"}`, func() float64 {
+		return float64(m().ExemplarsOutOfOrder)
+	})
+	metrics.NewGauge(`vm_exemplars_ignored_total{reason="invalid"}`, func() float64 {
+		return float64(m().ExemplarsInvalid)
+	})
+	metrics.NewGauge(`vm_exemplars_ignored_total{reason="unknown_series"}`, func() float64 {
+		return float64(m().ExemplarsUnknownSeries)
+	})
+	metrics.NewGauge(`vm_exemplars_evicted_series_total`, func() float64 {
+		return float64(m().ExemplarsEvictedSeries)
+	})
+
+	metrics.NewGauge(`vm_metrics_metadata_entries`, func() float64 {
+		return float64(m().MetricsMetadataEntries)
+	})
+	metrics.NewGauge(`vm_metrics_metadata_size_bytes`, func() float64 {
+		return float64(m().MetricsMetadataSizeBytes)
+	})
+	metrics.NewGauge(`vm_metrics_metadata_max_size_bytes`, func() float64 {
+		return float64(m().MetricsMetadataMaxSizeBytes)
+	})
+	metrics.NewGauge(`vm_metrics_metadata_evicted_entries_total`, func() float64 {
+		return float64(m().MetricsMetadataEvictedEntries)
+	})
 
 	metrics.NewGauge(`vm_concurrent_addrows_limit_reached_total`, func() float64 {
 		return float64(m().AddRowsConcurrencyLimitReached)
@@ -572,6 +1043,16 @@ func registerStorageMetrics() {
 	metrics.NewGauge(`vm_slow_metric_name_loads_total`, func() float64 {
 		return float64(m().SlowMetricNameLoads)
 	})
+	metrics.NewGauge(`vm_active_indexdb_compactions`, func() float64 {
+		return float64(m().ActiveIndexDBCompactions)
+	})
+
+	metrics.NewGauge(`vm_storage_is_read_only`, func() float64 {
+		if m().IsReadOnly {
+			return 1
+		}
+		return 0
+	})
 
 	metrics.NewGauge(`vm_hourly_series_limit_rows_dropped_total`, func() float64 {
 		return float64(m().HourlySeriesLimitRowsDropped)
@@ -579,6 +1060,18 @@ func registerStorageMetrics() {
 	metrics.NewGauge(`vm_daily_series_limit_rows_dropped_total`, func() float64 {
 		return float64(m().DailySeriesLimitRowsDropped)
 	})
+	metrics.NewGauge(`vm_hourly_series_limit_max_series`, func() float64 {
+		return float64(m().HourlySeriesLimitMaxSeries)
+	})
+	metrics.NewGauge(`vm_hourly_series_limit_current_series`, func() float64 {
+		return float64(m().HourlySeriesLimitCurrentSeries)
+	})
+	metrics.NewGauge(`vm_daily_series_limit_max_series`, func() float64 {
+		return float64(m().DailySeriesLimitMaxSeries)
+	})
+	metrics.NewGauge(`vm_daily_series_limit_current_series`, func() float64 {
+		return float64(m().DailySeriesLimitCurrentSeries)
+	})
 
 	metrics.NewGauge(`vm_timestamps_blocks_merged_total`, func() float64 {
 		return float64(m().TimestampsBlocksMerged)
@@ -597,6 +1090,17 @@ func registerStorageMetrics() {
 		return float64(idbm().ItemsCount)
 	})
 
+	// In-memory parts are included in storage/small parts, so export them under distinct names.
+	metrics.NewGauge(`vm_inmemory_parts`, func() float64 {
+		return float64(tm().InmemoryPartsCount)
+	})
+	metrics.NewGauge(`vm_inmemory_rows`, func() float64 {
+		return float64(tm().InmemoryRowsCount)
+	})
+	metrics.NewGauge(`vm_inmemory_data_size_bytes`, func() float64 {
+		return float64(tm().InmemorySizeBytes)
+	})
+
 	metrics.NewGauge(`vm_date_range_search_calls_total`, func() float64 {
 		return float64(idbm().DateRangeSearchCalls)
 	})
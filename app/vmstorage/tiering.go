@@ -0,0 +1,178 @@
+package vmstorage
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/actions"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fscommon"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fslocal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+var (
+	tieringDst = flag.String("storage.tieringDst", "", "Where to offload per-month partitions older than -storage.tieringAge. "+
+		"For example, s3://bucket/path/to/dir, gcs://bucket/path/to/dir or fs:///path/to/dir. Azure Blob Storage isn't supported yet. Tiering is disabled if empty. "+
+		"Every storage must use a distinct dir. See https://docs.victoriametrics.com/#tiering")
+	tieringAge = flagutil.NewDuration("storage.tieringAge", 3, "Per-month partitions with all the data older than -storage.tieringAge "+
+		"are offloaded to -storage.tieringDst. Data older than -storage.tieringAge cannot be ingested when tiering is enabled")
+	tieringCacheSize = flagutil.NewBytes("storage.tieringCacheSize", 0, "The maximum disk space for the local cache of partitions downloaded from -storage.tieringDst. "+
+		"Partitions, which weren't queried during the last minute, are evicted from the cache in order to free space for new downloads. "+
+		"Queries, which need partitions exceeding the remaining cache size, fail. This flag must be set when -storage.tieringDst is set")
+	tieringConcurrency = flag.Int("storage.tieringConcurrency", 10, "The number of concurrent workers for uploading and downloading partitions from -storage.tieringDst")
+)
+
+func initPartitionTier() {
+	if *tieringDst == "" {
+		return
+	}
+	if _, err := actions.NewRemoteFS(*tieringDst); err != nil {
+		logger.Fatalf("cannot parse -storage.tieringDst=%q: %s", *tieringDst, err)
+	}
+	if tieringCacheSize.N <= 0 {
+		logger.Fatalf("-storage.tieringCacheSize must be set to a positive value when -storage.tieringDst is set")
+	}
+	tier := &remotePartitionTier{
+		dst: strings.TrimSuffix(*tieringDst, "/"),
+	}
+	storage.SetPartitionTier(tier, tieringAge.Msecs, uint64(tieringCacheSize.N))
+}
+
+// remotePartitionTier stores partitions at the remote storage supported by vmbackup.
+//
+// Every partition is stored as a separate backup in a subdirectory named by the partition id.
+type remotePartitionTier struct {
+	dst string
+}
+
+func (rpt *remotePartitionTier) newRemoteFS(id string) (common.RemoteFS, error) {
+	path := rpt.dst
+	if id != "" {
+		path += "/" + id
+	}
+	return actions.NewRemoteFS(path)
+}
+
+// UploadPartition implements storage.PartitionTier interface.
+func (rpt *remotePartitionTier) UploadPartition(id, localDir string) error {
+	src := &fslocal.FS{
+		Dir: localDir,
+	}
+	if err := src.Init(); err != nil {
+		return fmt.Errorf("cannot initialize local fs for %q: %w", localDir, err)
+	}
+	defer src.MustStop()
+	dst, err := rpt.newRemoteFS(id)
+	if err != nil {
+		return err
+	}
+	defer dst.MustStop()
+	b := &actions.Backup{
+		Concurrency: *tieringConcurrency,
+		Src:         src,
+		Dst:         dst,
+	}
+	return b.Run()
+}
+
+// DownloadPartition implements storage.PartitionTier interface.
+func (rpt *remotePartitionTier) DownloadPartition(id, localDir string) error {
+	src, err := rpt.newRemoteFS(id)
+	if err != nil {
+		return err
+	}
+	defer src.MustStop()
+	dst := &fslocal.FS{
+		Dir: localDir,
+	}
+	if err := dst.Init(); err != nil {
+		return fmt.Errorf("cannot initialize local fs for %q: %w", localDir, err)
+	}
+	defer dst.MustStop()
+	r := &actions.Restore{
+		Concurrency: *tieringConcurrency,
+		Src:         src,
+		Dst:         dst,
+	}
+	return r.Run()
+}
+
+// PartitionSize implements storage.PartitionTier interface.
+func (rpt *remotePartitionTier) PartitionSize(id string) (uint64, error) {
+	fs, err := rpt.newRemoteFS(id)
+	if err != nil {
+		return 0, err
+	}
+	defer fs.MustStop()
+	parts, err := fs.ListParts()
+	if err != nil {
+		return 0, fmt.Errorf("cannot list parts at %s: %w", fs, err)
+	}
+	n := uint64(0)
+	for _, p := range parts {
+		n += p.Size
+	}
+	return n, nil
+}
+
+// ListPartitions implements storage.PartitionTier interface.
+func (rpt *remotePartitionTier) ListPartitions() ([]string, error) {
+	fs, err := rpt.newRemoteFS("")
+	if err != nil {
+		return nil, err
+	}
+	defer fs.MustStop()
+	parts, err := fs.ListParts()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list parts at %s: %w", fs, err)
+	}
+	m := make(map[string]bool)
+	for _, p := range parts {
+		n := strings.IndexByte(p.Path, '/')
+		if n <= 0 {
+			continue
+		}
+		m[p.Path[:n]] = true
+	}
+	var ids []string
+	for id := range m {
+		// Skip partitions with incomplete upload.
+		ok, err := fs.HasFile(id + "/" + fscommon.BackupCompleteFilename)
+		if err != nil {
+			return nil, fmt.Errorf("cannot check whether the partition %q is completely uploaded to %s: %w", id, fs, err)
+		}
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// DeletePartition implements storage.PartitionTier interface.
+func (rpt *remotePartitionTier) DeletePartition(id string) error {
+	fs, err := rpt.newRemoteFS(id)
+	if err != nil {
+		return err
+	}
+	defer fs.MustStop()
+	// Delete `backup complete` file at first, so the partition becomes invisible to ListPartitions.
+	if err := fs.DeleteFile(fscommon.BackupCompleteFilename); err != nil {
+		return fmt.Errorf("cannot delete `backup complete` file at %s: %w", fs, err)
+	}
+	parts, err := fs.ListParts()
+	if err != nil {
+		return fmt.Errorf("cannot list parts at %s: %w", fs, err)
+	}
+	for _, p := range parts {
+		if err := fs.DeletePart(p); err != nil {
+			return fmt.Errorf("cannot delete part %s at %s: %w", &p, fs, err)
+		}
+	}
+	return fs.RemoveEmptyDirs()
+}
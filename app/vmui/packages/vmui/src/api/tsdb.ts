@@ -0,0 +1,21 @@
+export interface TSDBStatusParams {
+  date: string; // YYYY-MM-DD
+  topN: number;
+  match: string; // series selector
+  focusLabel: string;
+  diskUsage: boolean; // whether to return the approximate disk usage per metric name and label=value pair
+}
+
+export const getTSDBStatusUrl = (server: string, params: TSDBStatusParams): string => {
+  const args = [`topN=${params.topN}`, `date=${params.date}`];
+  if (params.match) {
+    args.push(`match[]=${encodeURIComponent(params.match)}`);
+  }
+  if (params.focusLabel) {
+    args.push(`focusLabel=${encodeURIComponent(params.focusLabel)}`);
+  }
+  if (params.diskUsage) {
+    args.push("diskUsage=1");
+  }
+  return `${server}/api/v1/status/tsdb?${args.join("&")}`;
+};
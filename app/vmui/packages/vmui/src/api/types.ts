@@ -13,10 +13,37 @@ export interface InstantMetricResult extends MetricBase {
   value: [number, string]
 }
 
+export interface TracingData {
+  duration_msec: number;
+  message: string;
+  children?: TracingData[];
+}
+
 export interface QueryRangeResponse {
   status: string;
   data: {
     result: MetricResult[];
     resultType: "matrix";
   }
-}
\ No newline at end of file
+}
+export interface TSDBStatusEntry {
+  name: string;
+  value: number;
+}
+
+export interface TSDBStatus {
+  totalSeries: number;
+  totalLabelValuePairs: number;
+  seriesCountByMetricName: TSDBStatusEntry[];
+  labelValueCountByLabelName: TSDBStatusEntry[];
+  seriesCountByLabelValuePair: TSDBStatusEntry[];
+  seriesCountByFocusLabelValue: TSDBStatusEntry[];
+  totalBytes: number;
+  bytesByMetricName: TSDBStatusEntry[];
+  bytesByLabelValuePair: TSDBStatusEntry[];
+}
+
+export interface TSDBStatusResponse {
+  status: string;
+  data: TSDBStatus;
+}
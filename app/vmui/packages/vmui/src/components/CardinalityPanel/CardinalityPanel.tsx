@@ -0,0 +1,165 @@
+import React, {FC, useState} from "react";
+import {
+  Box,
+  Button,
+  Checkbox,
+  CircularProgress,
+  FormControlLabel,
+  Grid,
+  Paper,
+  TextField,
+  Typography
+} from "@material-ui/core";
+import {Alert} from "@material-ui/lab";
+import {TSDBStatusParams} from "../../api/tsdb";
+import {useFetchTSDBStatus} from "./useFetchTSDBStatus";
+import CardinalityTable from "./CardinalityTable";
+import {addLabelFilter, formatBytes, getCardinalityEntries, splitLabelValuePair} from "../../utils/cardinality";
+
+// The tsdb status API collects the stats per UTC day
+const getCurrentDate = (): string => new Date().toISOString().slice(0, 10);
+
+const defaultParams: TSDBStatusParams = {
+  date: getCurrentDate(),
+  topN: 10,
+  match: "",
+  focusLabel: "",
+  diskUsage: false
+};
+
+const CardinalityPanel: FC = () => {
+
+  const [params, setParams] = useState<TSDBStatusParams>(defaultParams);
+  const [compareDate, setCompareDate] = useState("");
+  const [input, setInput] = useState<TSDBStatusParams>(defaultParams);
+  const [compareDateInput, setCompareDateInput] = useState("");
+
+  const {isLoading, status, prevStatus, error} = useFetchTSDBStatus(params, compareDate);
+
+  const applyParams = (p: TSDBStatusParams, cd = compareDateInput) => {
+    setInput(p);
+    setParams(p);
+    setCompareDateInput(cd);
+    setCompareDate(cd);
+  };
+
+  const onSelectMetricName = (name: string) => applyParams({
+    ...params,
+    match: addLabelFilter(params.match, "__name__", name),
+    focusLabel: ""
+  });
+  const onSelectLabelName = (name: string) => applyParams({...params, focusLabel: name});
+  const onSelectLabelValuePair = (pair: string) => {
+    const [label, value] = splitLabelValuePair(pair);
+    applyParams({...params, match: addLabelFilter(params.match, label, value)});
+  };
+  const onSelectFocusLabelValue = (value: string) => applyParams({
+    ...params,
+    match: addLabelFilter(params.match, params.focusLabel, value),
+    focusLabel: ""
+  });
+
+  const totalSeries = status?.totalSeries || 0;
+  const prevTotalSeries = prevStatus?.totalSeries;
+  const totalBytes = status?.totalBytes || 0;
+
+  return (
+    <Box p={2}>
+      <Paper>
+        <Box p={2}>
+          <Typography variant="h6" component="h2">Cardinality Explorer</Typography>
+          <Grid container spacing={2} alignItems="center">
+            <Grid item xs={12} md={4}>
+              <TextField variant="outlined" size="small" fullWidth label="Series selector" value={input.match}
+                placeholder={"{job=\"node\"}"} inputProps={{style: {fontFamily: "Monospace"}}}
+                onChange={e => setInput({...input, match: e.target.value})}/>
+            </Grid>
+            <Grid item xs={6} md={2}>
+              <TextField variant="outlined" size="small" fullWidth label="Focus label" value={input.focusLabel}
+                onChange={e => setInput({...input, focusLabel: e.target.value})}/>
+            </Grid>
+            <Grid item xs={6} md={1}>
+              <TextField variant="outlined" size="small" fullWidth label="Top N" type="number" value={input.topN}
+                onChange={e => setInput({...input, topN: Number(e.target.value) || defaultParams.topN})}/>
+            </Grid>
+            <Grid item xs={6} md={2}>
+              <TextField variant="outlined" size="small" fullWidth label="Date" type="date" value={input.date}
+                InputLabelProps={{shrink: true}}
+                onChange={e => setInput({...input, date: e.target.value || getCurrentDate()})}/>
+            </Grid>
+            <Grid item xs={6} md={2}>
+              <TextField variant="outlined" size="small" fullWidth label="Compare with date" type="date"
+                value={compareDateInput} InputLabelProps={{shrink: true}}
+                onChange={e => setCompareDateInput(e.target.value)}/>
+            </Grid>
+            <Grid item xs={12} md={1}>
+              <Box display="flex">
+                <Button variant="contained" color="primary" onClick={() => applyParams(input)}>Apply</Button>
+                <Box ml={1}>
+                  <Button variant="outlined" onClick={() => applyParams(defaultParams, "")}>Reset</Button>
+                </Box>
+              </Box>
+            </Grid>
+            <Grid item xs={12}>
+              <FormControlLabel label="Show disk usage (may be slow on large databases)"
+                control={<Checkbox size="small" checked={input.diskUsage}
+                  onChange={e => setInput({...input, diskUsage: e.target.checked})}/>}/>
+            </Grid>
+          </Grid>
+          {status && <Box pt={2}>
+            <Typography variant="body1">
+              Total series: <b>{totalSeries}</b>
+              {prevTotalSeries !== undefined && ` (${totalSeries - prevTotalSeries >= 0 ? "+" : ""}${totalSeries - prevTotalSeries} since ${compareDate})`}
+              ; total label=value pairs: <b>{status.totalLabelValuePairs}</b>
+              {params.diskUsage && <>; approximate disk usage: <b>{formatBytes(totalBytes)}</b></>}
+            </Typography>
+          </Box>}
+        </Box>
+      </Paper>
+      {isLoading && <Box display="flex" justifyContent="center" m={2}><CircularProgress/></Box>}
+      {error && <Box pt={2}><Alert color="error" style={{fontSize: "14px"}}>{error}</Alert></Box>}
+      {status && <Box pt={2}>
+        <Grid container spacing={2}>
+          {params.focusLabel && <Grid item xs={12}>
+            <CardinalityTable title={`Values for "${params.focusLabel}" label with the highest number of series`}
+              nameHeader="Label value" valueHeader="Number of series"
+              entries={getCardinalityEntries(status.seriesCountByFocusLabelValue, prevStatus?.seriesCountByFocusLabelValue, totalSeries)}
+              onSelect={onSelectFocusLabelValue}/>
+          </Grid>}
+          <Grid item xs={12} md={6}>
+            <CardinalityTable title="Metric names with the highest number of series"
+              nameHeader="Metric name" valueHeader="Number of series"
+              entries={getCardinalityEntries(status.seriesCountByMetricName, prevStatus?.seriesCountByMetricName, totalSeries)}
+              onSelect={onSelectMetricName}/>
+          </Grid>
+          <Grid item xs={12} md={6}>
+            <CardinalityTable title="Labels with the highest number of unique values"
+              nameHeader="Label name" valueHeader="Number of unique values"
+              entries={getCardinalityEntries(status.labelValueCountByLabelName, prevStatus?.labelValueCountByLabelName)}
+              onSelect={onSelectLabelName}/>
+          </Grid>
+          <Grid item xs={12}>
+            <CardinalityTable title="Label=value pairs with the highest number of series"
+              nameHeader="Label=value pair" valueHeader="Number of series"
+              entries={getCardinalityEntries(status.seriesCountByLabelValuePair, prevStatus?.seriesCountByLabelValuePair, totalSeries)}
+              onSelect={onSelectLabelValuePair}/>
+          </Grid>
+          {params.diskUsage && <Grid item xs={12} md={6}>
+            <CardinalityTable title="Metric names with the highest disk usage"
+              nameHeader="Metric name" valueHeader="Disk usage"
+              entries={getCardinalityEntries(status.bytesByMetricName || [], undefined, totalBytes)}
+              onSelect={onSelectMetricName} formatValue={formatBytes}/>
+          </Grid>}
+          {params.diskUsage && <Grid item xs={12} md={6}>
+            <CardinalityTable title="Label=value pairs with the highest disk usage"
+              nameHeader="Label=value pair" valueHeader="Disk usage"
+              entries={getCardinalityEntries(status.bytesByLabelValuePair || [], undefined, totalBytes)}
+              onSelect={onSelectLabelValuePair} formatValue={formatBytes}/>
+          </Grid>}
+        </Grid>
+      </Box>}
+    </Box>
+  );
+};
+
+export default CardinalityPanel;
@@ -0,0 +1,56 @@
+import React, {FC} from "react";
+import {Link, Paper, Table, TableBody, TableCell, TableContainer, TableHead, TableRow, Typography} from "@material-ui/core";
+import {CardinalityEntry} from "../../utils/cardinality";
+
+export interface CardinalityTableProps {
+  title: string;
+  nameHeader: string;
+  valueHeader: string;
+  entries: CardinalityEntry[];
+  onSelect?: (name: string) => void;
+  formatValue?: (value: number) => string;
+}
+
+const formatDiff = (diff: number): string => diff > 0 ? `+${diff}` : `${diff}`;
+
+const CardinalityTable: FC<CardinalityTableProps> = ({title, nameHeader, valueHeader, entries, onSelect, formatValue}) => {
+
+  const hasDiff = entries.some(e => e.diff !== undefined);
+  const hasShare = entries.some(e => e.share !== undefined);
+
+  return (
+    <TableContainer component={Paper}>
+      <Typography variant="h6" component="h3" style={{padding: "8px 16px"}}>{title}</Typography>
+      {(entries.length > 0)
+        ? <Table size="small" aria-label={title}>
+          <TableHead>
+            <TableRow>
+              <TableCell>{nameHeader}</TableCell>
+              <TableCell align="right">{valueHeader}</TableCell>
+              {hasDiff && <TableCell align="right">Diff</TableCell>}
+              {hasShare && <TableCell align="right">Share, %</TableCell>}
+            </TableRow>
+          </TableHead>
+          <TableBody>
+            {entries.map(e => (
+              <TableRow key={e.name} hover>
+                <TableCell style={{fontFamily: "Monospace", wordBreak: "break-all"}}>
+                  {onSelect
+                    ? <Link component="button" variant="body2" onClick={() => onSelect(e.name)}>{e.name}</Link>
+                    : e.name}
+                </TableCell>
+                <TableCell align="right">{formatValue ? formatValue(e.value) : e.value}</TableCell>
+                {hasDiff && <TableCell align="right" style={{color: e.diff && e.diff > 0 ? "#c62828" : "#2e7d32"}}>
+                  {e.diff !== undefined && formatDiff(e.diff)}
+                </TableCell>}
+                {hasShare && <TableCell align="right">{e.share !== undefined && e.share.toFixed(2)}</TableCell>}
+              </TableRow>
+            ))}
+          </TableBody>
+        </Table>
+        : <div style={{textAlign: "center", padding: "8px"}}>No data to show</div>}
+    </TableContainer>
+  );
+};
+
+export default CardinalityTable;
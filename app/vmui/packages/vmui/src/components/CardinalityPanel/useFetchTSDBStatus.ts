@@ -0,0 +1,71 @@
+import {useEffect, useState} from "react";
+import {getTSDBStatusUrl, TSDBStatusParams} from "../../api/tsdb";
+import {TSDBStatus} from "../../api/types";
+import {useAppState} from "../../state/common/StateContext";
+import {useAuthState} from "../../state/auth/AuthStateContext";
+import {isValidHttpUrl} from "../../utils/url";
+
+export const useFetchTSDBStatus = (params: TSDBStatusParams, compareDate: string): {
+  fetchUrl?: string,
+  isLoading: boolean,
+  status?: TSDBStatus,
+  prevStatus?: TSDBStatus,
+  error?: string
+} => {
+  const {serverUrl} = useAppState();
+  const {basicData, bearerData, authMethod} = useAuthState();
+
+  const [isLoading, setIsLoading] = useState(false);
+  const [status, setStatus] = useState<TSDBStatus>();
+  const [prevStatus, setPrevStatus] = useState<TSDBStatus>();
+  const [error, setError] = useState<string>();
+
+  const fetchUrl = isValidHttpUrl(serverUrl) ? getTSDBStatusUrl(serverUrl, params) : undefined;
+  const prevFetchUrl = isValidHttpUrl(serverUrl) && compareDate
+    ? getTSDBStatusUrl(serverUrl, {...params, date: compareDate})
+    : undefined;
+
+  const fetchStatus = async (url: string, headers: Headers): Promise<TSDBStatus> => {
+    const response = await fetch(url, {headers});
+    const resp = await response.json();
+    if (!response.ok) {
+      throw new Error(resp?.error || `unexpected response status: ${response.status}`);
+    }
+    return resp.data;
+  };
+
+  useEffect(() => {
+    if (!fetchUrl) {
+      setError("Please provide a valid URL");
+      return;
+    }
+    const headers = new Headers();
+    if (authMethod === "BASIC_AUTH") {
+      headers.set("Authorization", "Basic " + btoa(`${basicData?.login || ""}:${basicData?.password || ""}`));
+    }
+    if (authMethod === "BEARER_AUTH") {
+      headers.set("Authorization", bearerData?.token || "");
+    }
+    (async () => {
+      setIsLoading(true);
+      try {
+        setStatus(await fetchStatus(fetchUrl, headers));
+        setPrevStatus(prevFetchUrl ? await fetchStatus(prevFetchUrl, headers) : undefined);
+        setError(undefined);
+      } catch (e) {
+        setStatus(undefined);
+        setPrevStatus(undefined);
+        setError(e instanceof Error ? e.message : String(e));
+      }
+      setIsLoading(false);
+    })();
+  }, [fetchUrl, prevFetchUrl]);
+
+  return {
+    fetchUrl,
+    isLoading,
+    status,
+    prevStatus,
+    error
+  };
+};
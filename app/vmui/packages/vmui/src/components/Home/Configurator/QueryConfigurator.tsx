@@ -29,12 +29,14 @@ const QueryConfigurator: FC = () => {
   const {serverUrl, query, time: {duration}} = useAppState();
   const dispatch = useAppDispatch();
 
-  const {queryControls: {autocomplete}} = useAppState();
+  const {queryControls: {autocomplete, isTracingEnabled}} = useAppState();
   const onChangeAutocomplete = () => {
     dispatch({type: "TOGGLE_AUTOCOMPLETE"});
     saveToStorage("AUTOCOMPLETE", !autocomplete);
   };
 
+  const onChangeQueryTracing = () => dispatch({type: "TOGGLE_QUERY_TRACING"});
+
   const [dialogOpen, setDialogOpen] = useState(false);
   const [expanded, setExpanded] = useState(true);
   const [popoverOpen, setPopoverOpen] = useState(false);
@@ -113,6 +115,12 @@ const QueryConfigurator: FC = () => {
                           label="Autocomplete"
                         />}
                       </Box>
+                      <Box px={2} pb={2}>
+                        {<FormControlLabel
+                          control={<Switch size="small" checked={isTracingEnabled} onChange={onChangeQueryTracing}/>}
+                          label="Trace query"
+                        />}
+                      </Box>
                     </Popover>
                   </div>
                 </Box>
@@ -1,7 +1,7 @@
 import {useEffect, useMemo, useState} from "react";
 import {getQueryRangeUrl, getQueryUrl} from "../../../api/query-range";
 import {useAppState} from "../../../state/common/StateContext";
-import {InstantMetricResult, MetricResult} from "../../../api/types";
+import {InstantMetricResult, MetricResult, TracingData} from "../../../api/types";
 import {saveToStorage} from "../../../utils/storage";
 import {isValidHttpUrl} from "../../../utils/url";
 import {useAuthState} from "../../../state/auth/AuthStateContext";
@@ -11,21 +11,24 @@ export const useFetchQuery = (): {
   isLoading: boolean,
   graphData?: MetricResult[],
   liveData?: InstantMetricResult[],
+  trace?: TracingData,
   error?: string
 } => {
-  const {query, displayType, serverUrl, time: {period}} = useAppState();
+  const {query, displayType, serverUrl, time: {period}, queryControls: {isTracingEnabled}} = useAppState();
 
   const {basicData, bearerData, authMethod} = useAuthState();
 
   const [isLoading, setIsLoading] = useState(false);
   const [graphData, setGraphData] = useState<MetricResult[]>();
   const [liveData, setLiveData] = useState<InstantMetricResult[]>();
+  const [trace, setTrace] = useState<TracingData>();
   const [error, setError] = useState<string>();
 
   useEffect(() => {
     if (error) {
       setGraphData(undefined);
       setLiveData(undefined);
+      setTrace(undefined);
     }
   }, [error]);
 
@@ -41,14 +44,14 @@ export const useFetchQuery = (): {
       }
       if (isValidHttpUrl(serverUrl)) {
         return displayType === "chart"
-          ? getQueryRangeUrl(serverUrl, query, period)
-          : getQueryUrl(serverUrl, query, period);
+          ? getQueryRangeUrl(serverUrl, query, period, isTracingEnabled)
+          : getQueryUrl(serverUrl, query, period, isTracingEnabled);
       } else {
         setError("Please provide a valid URL");
       }
     }
   },
-  [serverUrl, period, displayType]);
+  [serverUrl, period, displayType, isTracingEnabled]);
 
   // TODO: this should depend on query as well, but need to decide when to do the request.
   //       Doing it on each query change - looks to be a bad idea. Probably can be done on blur
@@ -71,6 +74,7 @@ export const useFetchQuery = (): {
           const resp = await response.json();
           setError(undefined);
           displayType === "chart" ? setGraphData(resp.data.result) : setLiveData(resp.data.result);
+          setTrace(resp.trace);
         } else {
           setError((await response.json())?.error);
         }
@@ -84,6 +88,7 @@ export const useFetchQuery = (): {
     isLoading,
     graphData,
     liveData,
+    trace,
     error
   };
 };
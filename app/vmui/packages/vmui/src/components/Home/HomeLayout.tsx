@@ -1,5 +1,5 @@
-import React, {FC} from "react";
-import {AppBar, Box, CircularProgress, Fade, Link, Toolbar, Typography} from "@material-ui/core";
+import React, {FC, useEffect, useState} from "react";
+import {AppBar, Box, CircularProgress, Fade, Link, Tab, Tabs, Toolbar, Typography} from "@material-ui/core";
 import {ExecutionControls} from "./Configurator/ExecutionControls";
 import {DisplayTypeSwitch} from "./Configurator/DisplayTypeSwitch";
 import GraphView from "./Views/GraphView";
@@ -10,12 +10,30 @@ import {useFetchQuery} from "./Configurator/useFetchQuery";
 import JsonView from "./Views/JsonView";
 import {UrlCopy} from "./UrlCopy";
 import {Alert} from "@material-ui/lab";
+import CardinalityPanel from "../CardinalityPanel/CardinalityPanel";
+import TracingsView from "../TraceQuery/TracingsView";
+import {Trace} from "../../utils/trace";
+
+type PanelType = "query" | "cardinality";
 
 const HomeLayout: FC = () => {
 
-  const {displayType, time: {period}} = useAppState();
+  const {displayType, query, time: {period}, queryControls: {isTracingEnabled}} = useAppState();
+
+  const {fetchUrl, isLoading, liveData, graphData, trace, error} = useFetchQuery();
+
+  const [panel, setPanel] = useState<PanelType>("query");
+  const [traces, setTraces] = useState<Trace[]>([]);
+  const [traceError, setTraceError] = useState<string>();
 
-  const {fetchUrl, isLoading, liveData, graphData, error} = useFetchQuery();
+  useEffect(() => {
+    if (trace) {
+      setTraces(prev => [{id: Date.now(), query, data: trace}, ...prev]);
+    }
+  }, [trace]);
+
+  const onImportTrace = (t: Trace) => setTraces(prev => [t, ...prev]);
+  const onDeleteTrace = (t: Trace) => setTraces(prev => prev.filter(p => p.id !== t.id));
 
   return (
     <>
@@ -43,14 +61,23 @@ const HomeLayout: FC = () => {
               Create an issue
             </Link>
           </div>
+          <Box ml={4}>
+            <Tabs value={panel} onChange={(e, val) => setPanel(val)}>
+              <Tab value="query" label="Query"/>
+              <Tab value="cardinality" label="Cardinality"/>
+            </Tabs>
+          </Box>
           <Box ml={4} flexGrow={1}>
-            <ExecutionControls/>
+            {panel === "query" && <ExecutionControls/>}
           </Box>
-          <DisplayTypeSwitch/>
-          <UrlCopy url={fetchUrl}/>
+          {panel === "query" && <>
+            <DisplayTypeSwitch/>
+            <UrlCopy url={fetchUrl}/>
+          </>}
         </Toolbar>
       </AppBar>
-      <Box display="flex" flexDirection="column" style={{minHeight: "calc(100vh - 64px)"}}>
+      {panel === "cardinality" && <CardinalityPanel/>}
+      {panel === "query" && <Box display="flex" flexDirection="column" style={{minHeight: "calc(100vh - 64px)"}}>
         <Box m={2}>
           <QueryConfigurator/>
         </Box>
@@ -79,8 +106,12 @@ const HomeLayout: FC = () => {
             {liveData && (displayType === "code") && <JsonView data={liveData}/>}
             {liveData && (displayType === "table") && <TableView data={liveData}/>}
           </Box>}
+          {(isTracingEnabled || traces.length > 0) && <Box p={2}>
+            <TracingsView traces={traces} onImport={onImportTrace} onDelete={onDeleteTrace}
+              error={traceError} setError={setTraceError}/>
+          </Box>}
         </Box>
-      </Box>
+      </Box>}
     </>
   );
 };
@@ -0,0 +1,45 @@
+import React, {FC, useState} from "react";
+import {Box, Collapse, IconButton, Typography} from "@material-ui/core";
+import ExpandLessIcon from "@material-ui/icons/ExpandLess";
+import ExpandMoreIcon from "@material-ui/icons/ExpandMore";
+import {TracingData} from "../../api/types";
+import {getDurationShare} from "../../utils/trace";
+
+interface NestedNavProps {
+  data: TracingData;
+  totalMsec: number;
+  level?: number;
+}
+
+const NestedNav: FC<NestedNavProps> = ({data, totalMsec, level = 0}) => {
+  const [open, setOpen] = useState(level === 0);
+  const children = data.children || [];
+  const share = getDurationShare(data.duration_msec, totalMsec);
+
+  return (
+    <Box pl={level > 0 ? 2 : 0}>
+      <Box display="flex" alignItems="center" style={{borderBottom: "1px solid #eee"}}>
+        <Box width="32px" flexShrink={0}>
+          {children.length > 0 && <IconButton size="small" onClick={() => setOpen(prev => !prev)}>
+            {open ? <ExpandLessIcon/> : <ExpandMoreIcon/>}
+          </IconButton>}
+        </Box>
+        <Box flexGrow={1} py={0.5} overflow="hidden">
+          <Typography variant="body2" style={{fontFamily: "Monospace", wordBreak: "break-all"}}>
+            {data.message}
+          </Typography>
+          {/* flame-style bar: its width is proportional to the share of the node in the total duration */}
+          <Box mt={0.5} height="6px" borderRadius="3px" style={{width: `${share}%`, background: "#3f51b5", opacity: .7}}/>
+        </Box>
+        <Box ml={2} flexShrink={0} width="140px" textAlign="right">
+          <Typography variant="body2">{data.duration_msec.toFixed(3)}ms ({share.toFixed(1)}%)</Typography>
+        </Box>
+      </Box>
+      {children.length > 0 && <Collapse in={open} timeout="auto" unmountOnExit>
+        {children.map((child, i) => <NestedNav key={i} data={child} totalMsec={totalMsec} level={level + 1}/>)}
+      </Collapse>}
+    </Box>
+  );
+};
+
+export default NestedNav;
@@ -0,0 +1,88 @@
+import React, {ChangeEvent, FC, useRef} from "react";
+import {Box, Button, IconButton, Paper, Tooltip, Typography} from "@material-ui/core";
+import DeleteIcon from "@material-ui/icons/Delete";
+import GetAppIcon from "@material-ui/icons/GetApp";
+import {Alert} from "@material-ui/lab";
+import NestedNav from "./NestedNav";
+import {formatTracingData, parseTracingData, Trace} from "../../utils/trace";
+
+interface TracingsViewProps {
+  traces: Trace[];
+  onImport: (trace: Trace) => void;
+  onDelete: (trace: Trace) => void;
+  error?: string;
+  setError: (error?: string) => void;
+}
+
+const downloadTrace = (trace: Trace) => {
+  const blob = new Blob([formatTracingData(trace.data)], {type: "application/json"});
+  const href = URL.createObjectURL(blob);
+  const link = document.createElement("a");
+  link.href = href;
+  link.download = `vmui_trace_${trace.id}.json`;
+  document.body.appendChild(link);
+  link.click();
+  document.body.removeChild(link);
+  URL.revokeObjectURL(href);
+};
+
+const TracingsView: FC<TracingsViewProps> = ({traces, onImport, onDelete, error, setError}) => {
+  const inputRef = useRef<HTMLInputElement>(null);
+
+  const handleImport = (e: ChangeEvent<HTMLInputElement>) => {
+    const files = Array.from(e.target.files || []);
+    files.forEach(file => {
+      const reader = new FileReader();
+      reader.onload = () => {
+        try {
+          const data = parseTracingData(String(reader.result));
+          setError(undefined);
+          onImport({id: Date.now(), query: file.name, data});
+        } catch (err) {
+          setError(`${file.name}: ${err instanceof Error ? err.message : err}`);
+        }
+      };
+      reader.readAsText(file);
+    });
+    // allow importing the same file again
+    e.target.value = "";
+  };
+
+  return (
+    <Paper>
+      <Box p={2}>
+        <Box display="flex" alignItems="center" mb={1}>
+          <Box flexGrow={1}>
+            <Typography variant="h6" component="h3">Query traces</Typography>
+          </Box>
+          <input ref={inputRef} type="file" accept="application/json" multiple hidden onChange={handleImport}/>
+          <Button variant="outlined" onClick={() => inputRef.current?.click()}>Import JSON</Button>
+        </Box>
+        {error && <Alert color="error" style={{fontSize: "14px"}}>{error}</Alert>}
+        {!traces.length && <Typography variant="body2">
+          Enable "Trace query" in the query editor settings and execute the query, or import a previously exported trace.
+        </Typography>}
+        {traces.map(trace => (
+          <Box key={trace.id} mt={2}>
+            <Box display="flex" alignItems="center">
+              <Box flexGrow={1}>
+                <Typography variant="subtitle2" style={{fontFamily: "Monospace", wordBreak: "break-all"}}>
+                  {trace.query}
+                </Typography>
+              </Box>
+              <Tooltip title="Export JSON">
+                <IconButton size="small" onClick={() => downloadTrace(trace)}><GetAppIcon/></IconButton>
+              </Tooltip>
+              <Tooltip title="Remove trace">
+                <IconButton size="small" onClick={() => onDelete(trace)}><DeleteIcon/></IconButton>
+              </Tooltip>
+            </Box>
+            <NestedNav data={trace.data} totalMsec={trace.data.duration_msec}/>
+          </Box>
+        ))}
+      </Box>
+    </Paper>
+  );
+};
+
+export default TracingsView;
@@ -17,7 +17,8 @@ export interface AppState {
   time: TimeState;
   queryControls: {
     autoRefresh: boolean;
-    autocomplete: boolean
+    autocomplete: boolean;
+    isTracingEnabled: boolean;
   }
 }
 
@@ -32,6 +33,7 @@ export type Action =
     | { type: "RUN_QUERY_TO_NOW"}
     | { type: "TOGGLE_AUTOREFRESH"}
     | { type: "TOGGLE_AUTOCOMPLETE"}
+    | { type: "TOGGLE_QUERY_TRACING"}
 
 const duration = getQueryStringValue("g0.range_input", "1h");
 const endInput = getQueryStringValue("g0.end_input", undefined);
@@ -46,7 +48,8 @@ export const initialState: AppState = {
   },
   queryControls: {
     autoRefresh: false,
-    autocomplete: getFromStorage("AUTOCOMPLETE") as boolean || false
+    autocomplete: getFromStorage("AUTOCOMPLETE") as boolean || false,
+    isTracingEnabled: false
   }
 };
 
@@ -115,6 +118,14 @@ export function reducer(state: AppState, action: Action): AppState {
           autocomplete: !state.queryControls.autocomplete
         }
       };
+    case "TOGGLE_QUERY_TRACING":
+      return {
+        ...state,
+        queryControls: {
+          ...state.queryControls,
+          isTracingEnabled: !state.queryControls.isTracingEnabled
+        }
+      };
     case "RUN_QUERY":
       return {
         ...state,
@@ -0,0 +1,34 @@
+import {addLabelFilter, formatBytes, getCardinalityEntries, splitLabelValuePair} from "./cardinality";
+
+test("addLabelFilter", () => {
+  expect(addLabelFilter("", "__name__", "foo")).toBe("{__name__=\"foo\"}");
+  expect(addLabelFilter("foo", "job", "bar")).toBe("foo{job=\"bar\"}");
+  expect(addLabelFilter("foo{}", "job", "bar")).toBe("foo{job=\"bar\"}");
+  expect(addLabelFilter("{__name__=\"foo\"}", "job", "a\"b")).toBe("{__name__=\"foo\", job=\"a\\\"b\"}");
+});
+
+test("splitLabelValuePair", () => {
+  expect(splitLabelValuePair("job=node")).toEqual(["job", "node"]);
+  expect(splitLabelValuePair("url=/?a=b")).toEqual(["url", "/?a=b"]);
+  expect(splitLabelValuePair("foo")).toEqual(["foo", ""]);
+});
+
+test("getCardinalityEntries", () => {
+  const entries = [{name: "foo", value: 30}, {name: "bar", value: 10}];
+  const prevEntries = [{name: "foo", value: 20}, {name: "baz", value: 5}];
+  expect(getCardinalityEntries(entries, prevEntries, 40)).toEqual([
+    {name: "foo", value: 30, diff: 10, share: 75},
+    {name: "bar", value: 10, diff: 10, share: 25},
+  ]);
+  expect(getCardinalityEntries(entries)).toEqual([
+    {name: "foo", value: 30, diff: undefined, share: undefined},
+    {name: "bar", value: 10, diff: undefined, share: undefined},
+  ]);
+});
+
+test("formatBytes", () => {
+  expect(formatBytes(0)).toBe("0 B");
+  expect(formatBytes(1023)).toBe("1023 B");
+  expect(formatBytes(1536)).toBe("1.50 KiB");
+  expect(formatBytes(5 * 1024 * 1024 * 1024)).toBe("5.00 GiB");
+});
@@ -0,0 +1,57 @@
+import {TSDBStatusEntry} from "../api/types";
+
+export interface CardinalityEntry extends TSDBStatusEntry {
+  diff?: number; // the difference with the value for the compared date
+  share?: number; // the share of the value in the total (e.g. the total number of series), in percents
+}
+
+export const getCardinalityEntries = (
+  entries: TSDBStatusEntry[],
+  prevEntries?: TSDBStatusEntry[],
+  total?: number
+): CardinalityEntry[] => {
+  const prevValues = new Map((prevEntries || []).map(e => [e.name, e.value]));
+  return entries.map(e => ({
+    ...e,
+    diff: prevEntries ? e.value - (prevValues.get(e.name) || 0) : undefined,
+    share: total ? e.value / total * 100 : undefined
+  }));
+};
+
+const byteUnits = ["B", "KiB", "MiB", "GiB", "TiB", "PiB"];
+
+// formatBytes returns human-readable representation for the given number of bytes
+export const formatBytes = (n: number): string => {
+  let i = 0;
+  while (n >= 1024 && i < byteUnits.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return i === 0 ? `${n} ${byteUnits[i]}` : `${n.toFixed(2)} ${byteUnits[i]}`;
+};
+
+const escapeLabelValue = (value: string): string => value.replace(/\\/g, "\\\\").replace(/"/g, "\\\"");
+
+// addLabelFilter adds `label="value"` filter to the given series selector
+export const addLabelFilter = (match: string, label: string, value: string): string => {
+  const filter = `${label}="${escapeLabelValue(value)}"`;
+  const selector = match.trim();
+  if (!selector) {
+    return `{${filter}}`;
+  }
+  if (!selector.endsWith("}")) {
+    return `${selector}{${filter}}`;
+  }
+  const body = selector.slice(selector.indexOf("{") + 1, -1).trim();
+  const prefix = selector.slice(0, selector.indexOf("{"));
+  return body ? `${prefix}{${body}, ${filter}}` : `${prefix}{${filter}}`;
+};
+
+// splitLabelValuePair splits `label=value` entries returned in seriesCountByLabelValuePair
+export const splitLabelValuePair = (pair: string): [string, string] => {
+  const n = pair.indexOf("=");
+  if (n < 0) {
+    return [pair, ""];
+  }
+  return [pair.slice(0, n), pair.slice(n + 1)];
+};
@@ -0,0 +1,30 @@
+import {getDurationShare, isTracingData, parseTracingData} from "./trace";
+
+const trace = {
+  duration_msec: 10.5,
+  message: "/api/v1/query: query=up",
+  children: [
+    {duration_msec: 2, message: "eval: query=up"},
+  ],
+};
+
+test("isTracingData", () => {
+  expect(isTracingData(trace)).toBe(true);
+  expect(isTracingData({duration_msec: 1, message: "foo"})).toBe(true);
+  expect(isTracingData({message: "foo"})).toBe(false);
+  expect(isTracingData({duration_msec: 1, message: "foo", children: [{message: "bar"}]})).toBe(false);
+  expect(isTracingData(null)).toBe(false);
+});
+
+test("parseTracingData", () => {
+  expect(parseTracingData(JSON.stringify(trace))).toEqual(trace);
+  expect(parseTracingData(JSON.stringify({status: "success", trace}))).toEqual(trace);
+  expect(() => parseTracingData("foo")).toThrow("invalid JSON");
+  expect(() => parseTracingData("{\"status\":\"success\"}")).toThrow("missing");
+});
+
+test("getDurationShare", () => {
+  expect(getDurationShare(5, 10)).toBe(50);
+  expect(getDurationShare(20, 10)).toBe(100);
+  expect(getDurationShare(5, 0)).toBe(100);
+});
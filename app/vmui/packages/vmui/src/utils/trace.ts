@@ -0,0 +1,38 @@
+import {TracingData} from "../api/types";
+
+export interface Trace {
+  id: number;
+  query: string;
+  data: TracingData;
+}
+
+export const isTracingData = (value: unknown): value is TracingData => {
+  if (!value || typeof value !== "object") return false;
+  const v = value as Record<string, unknown>;
+  if (typeof v.duration_msec !== "number" || typeof v.message !== "string") return false;
+  if (v.children === undefined) return true;
+  return Array.isArray(v.children) && v.children.every(isTracingData);
+};
+
+// parseTracingData accepts either the trace itself or the full query response with the `trace` field,
+// so both exported traces and raw responses for queries with `trace=1` can be imported.
+export const parseTracingData = (text: string): TracingData => {
+  let value: unknown;
+  try {
+    value = JSON.parse(text);
+  } catch (e) {
+    throw new Error("cannot parse trace: invalid JSON");
+  }
+  if (isTracingData(value)) return value;
+  const trace = value && typeof value === "object" ? (value as Record<string, unknown>).trace : undefined;
+  if (isTracingData(trace)) return trace;
+  throw new Error("cannot parse trace: missing `duration_msec` or `message` fields");
+};
+
+export const formatTracingData = (data: TracingData): string => JSON.stringify(data, null, 2);
+
+// getDurationShare returns the share of the given duration in the total duration, in percents.
+export const getDurationShare = (durationMsec: number, totalMsec: number): number => {
+  if (totalMsec <= 0) return 100;
+  return Math.min(100, Math.max(0, durationMsec / totalMsec * 100));
+};
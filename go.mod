@@ -40,10 +40,16 @@ require (
 	golang.org/x/sys v0.0.0-20210923061019-b8560ed6a9b7
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/api v0.57.0
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.27.1
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 // This is needed until https://github.com/googleapis/google-cloud-go/issues/4783 is resolved
 replace cloud.google.com/go v0.94.1 => cloud.google.com/go v0.93.3
 
+// The fork contains MetricsQL functions and modifiers, which aren't available in the upstream metricsql yet.
+// See third_party/metricsql/FORK.md
+replace github.com/VictoriaMetrics/metricsql => ./third_party/metricsql
+
 go 1.16
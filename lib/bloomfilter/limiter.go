@@ -67,6 +67,17 @@ func (l *Limiter) CurrentItems() int {
 // True is returned if h is added or already exists in l.
 // False is returned if h cannot be added to l, since it already has maxItems unique items.
 func (l *Limiter) Add(h uint64) bool {
+	ok, _ := l.AddExt(h)
+	return ok
+}
+
+// AddExt adds h to the limiter.
+//
+// It is safe calling AddExt from concurrent goroutines.
+//
+// ok is set to false if h cannot be added to l, since it already has maxItems unique items.
+// isNew is set to true if h has been added to l during the current refresh interval by this call.
+func (l *Limiter) AddExt(h uint64) (ok, isNew bool) {
 	lm := l.v.Load().(*limiter)
 	return lm.Add(h)
 }
@@ -82,13 +93,14 @@ func newLimiter(maxItems int) *limiter {
 	}
 }
 
-func (l *limiter) Add(h uint64) bool {
+func (l *limiter) Add(h uint64) (bool, bool) {
 	currentItems := atomic.LoadUint64(&l.currentItems)
 	if currentItems >= uint64(l.f.maxItems) {
-		return l.f.Has(h)
+		return l.f.Has(h), false
 	}
 	if l.f.Add(h) {
 		atomic.AddUint64(&l.currentItems, 1)
+		return true, true
 	}
-	return true
+	return true, false
 }
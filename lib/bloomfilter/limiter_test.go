@@ -94,3 +94,24 @@ func TestLimiterConcurrent(t *testing.T) {
 		}
 	}
 }
+
+func TestLimiterAddExt(t *testing.T) {
+	l := NewLimiter(2, time.Hour)
+	defer l.MustStop()
+	f := func(h uint64, okExpected, isNewExpected bool) {
+		t.Helper()
+		ok, isNew := l.AddExt(h)
+		if ok != okExpected {
+			t.Fatalf("unexpected ok for item %d; got %v; want %v", h, ok, okExpected)
+		}
+		if isNew != isNewExpected {
+			t.Fatalf("unexpected isNew for item %d; got %v; want %v", h, isNew, isNewExpected)
+		}
+	}
+	f(1, true, true)
+	f(1, true, false)
+	f(2, true, true)
+	f(2, true, false)
+	f(3, false, false)
+	f(1, true, false)
+}
@@ -0,0 +1,146 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// key is an encryption key.
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var (
+	// keys contains all the known keys by their ids. The keys are used for decrypting files.
+	keys map[string]*key
+
+	// currentKey is used for encrypting new files. It is nil if encryption is disabled.
+	currentKey *key
+)
+
+// Init initializes encryption keys from the given keyFile or from the output of the given keyCommand.
+//
+// Every non-empty line in the keyFile or in the keyCommand output must contain a key in the format `<key_id>:<base64-encoded 256-bit key>`.
+// Lines starting with `#` are ignored. The last key is used for encrypting new files,
+// while the remaining keys are used for decrypting files encrypted with them.
+//
+// Encryption is disabled if both keyFile and keyCommand are empty.
+//
+// This function must be called before initializing the storage.
+func Init(keyFile, keyCommand string) error {
+	keys = nil
+	currentKey = nil
+	var data []byte
+	switch {
+	case keyFile != "" && keyCommand != "":
+		return fmt.Errorf("keyFile and keyCommand cannot be set simultaneously")
+	case keyFile != "":
+		b, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("cannot read encryption keys: %w", err)
+		}
+		data = b
+	case keyCommand != "":
+		cmd := exec.Command("/bin/sh", "-c", keyCommand)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		b, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("cannot obtain encryption keys from %q: %w; stderr: %s", keyCommand, err, stderr.String())
+		}
+		data = b
+	default:
+		return nil
+	}
+	m, k, err := parseKeys(data)
+	if err != nil {
+		return err
+	}
+	keys = m
+	currentKey = k
+	return nil
+}
+
+// IsEnabled returns true if new files are encrypted.
+func IsEnabled() bool {
+	return currentKey != nil
+}
+
+// CurrentKeyID returns the id of the key used for encrypting new files.
+//
+// Empty string is returned if encryption is disabled.
+func CurrentKeyID() string {
+	if currentKey == nil {
+		return ""
+	}
+	return currentKey.id
+}
+
+var keyIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+func parseKeys(data []byte) (map[string]*key, *key, error) {
+	m := make(map[string]*key)
+	var lastKey *key
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; sc.Scan(); lineNum++ {
+		line := strings.TrimSpace(sc.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		n := strings.IndexByte(line, ':')
+		if n < 0 {
+			return nil, nil, fmt.Errorf("missing ':' delimiter between key id and key at line %d", lineNum)
+		}
+		id := line[:n]
+		if !keyIDRegexp.MatchString(id) {
+			return nil, nil, fmt.Errorf("invalid key id %q at line %d; it must match %s", id, lineNum, keyIDRegexp)
+		}
+		if m[id] != nil {
+			return nil, nil, fmt.Errorf("duplicate key id %q at line %d", id, lineNum)
+		}
+		keyData, err := base64.StdEncoding.DecodeString(line[n+1:])
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot decode base64-encoded key %q at line %d: %w", id, lineNum, err)
+		}
+		if len(keyData) != 32 {
+			return nil, nil, fmt.Errorf("unexpected key size for key %q at line %d; got %d bytes; want 32 bytes", id, lineNum, len(keyData))
+		}
+		k, err := newKey(id, keyData)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot initialize key %q at line %d: %w", id, lineNum, err)
+		}
+		m[id] = k
+		lastKey = k
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, fmt.Errorf("cannot read encryption keys: %w", err)
+	}
+	if lastKey == nil {
+		return nil, nil, fmt.Errorf("missing encryption keys")
+	}
+	return m, lastKey, nil
+}
+
+func newKey(id string, keyData []byte) (*key, error) {
+	c, err := aes.NewCipher(keyData)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(c)
+	if err != nil {
+		return nil, err
+	}
+	return &key{
+		id:   id,
+		aead: aead,
+	}, nil
+}
@@ -0,0 +1,258 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func testKeyLine(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestParseKeysSuccess(t *testing.T) {
+	f := func(data string, keysCountExpected int, currentKeyIDExpected string) {
+		t.Helper()
+		m, k, err := parseKeys([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(m) != keysCountExpected {
+			t.Fatalf("unexpected number of keys; got %d; want %d", len(m), keysCountExpected)
+		}
+		if k.id != currentKeyIDExpected {
+			t.Fatalf("unexpected current key id; got %q; want %q", k.id, currentKeyIDExpected)
+		}
+	}
+	f(testKeyLine("foo", 1), 1, "foo")
+	f(testKeyLine("foo", 1)+"\n"+testKeyLine("bar", 2)+"\n", 2, "bar")
+	f("# comment\n\n  "+testKeyLine("2021-01.k_1", 1)+"  \n# "+testKeyLine("bar", 2), 1, "2021-01.k_1")
+}
+
+func TestParseKeysFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, _, err := parseKeys([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error for %q", data)
+		}
+	}
+	f("")
+	f("# comment")
+	f("foo")
+	f(":" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	f("foo bar:" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	f("foo:bar")
+	f("foo:" + base64.StdEncoding.EncodeToString(make([]byte, 16)))
+	f(testKeyLine("foo", 1) + "\n" + testKeyLine("foo", 2))
+}
+
+func TestInit(t *testing.T) {
+	defer func() {
+		_ = Init("", "")
+	}()
+	if err := Init("", ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if IsEnabled() {
+		t.Fatalf("encryption must be disabled")
+	}
+	if err := Init("foo", "bar"); err == nil {
+		t.Fatalf("expecting non-nil error when both keyFile and keyCommand are set")
+	}
+	if err := Init("", "echo '"+testKeyLine("foo", 1)+"'"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if id := CurrentKeyID(); id != "foo" {
+		t.Fatalf("unexpected current key id; got %q; want %q", id, "foo")
+	}
+	if err := Init("", "exit 1"); err == nil {
+		t.Fatalf("expecting non-nil error for failed command")
+	}
+	if IsEnabled() {
+		t.Fatalf("encryption must be disabled after the failed Init")
+	}
+}
+
+func TestFileReadWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestFileReadWrite")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+		_ = Init("", "")
+	}()
+	keyFile := dir + "/keys"
+	if err := ioutil.WriteFile(keyFile, []byte(testKeyLine("k1", 1)), 0600); err != nil {
+		t.Fatalf("cannot write key file: %s", err)
+	}
+	if err := Init(keyFile, ""); err != nil {
+		t.Fatalf("cannot init keys: %s", err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize, 5*chunkSize + 123} {
+		data := make([]byte, size)
+		r.Read(data)
+		path := fmt.Sprintf("%s/data_%d", dir, size)
+		testWriteFile(t, path, data)
+		fileData, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("cannot read %q: %s", path, err)
+		}
+		if !bytes.HasPrefix(fileData, fileMagic) {
+			t.Fatalf("missing encrypted file header in %q", path)
+		}
+		if size > 16 && bytes.Contains(fileData, data) {
+			t.Fatalf("encrypted file %q contains plaintext data", path)
+		}
+		testReadFile(t, path, data)
+	}
+
+	// Files encrypted with the old key must be readable after key rotation.
+	if err := ioutil.WriteFile(keyFile, []byte(testKeyLine("k1", 1)+"\n"+testKeyLine("k2", 2)), 0600); err != nil {
+		t.Fatalf("cannot write key file: %s", err)
+	}
+	if err := Init(keyFile, ""); err != nil {
+		t.Fatalf("cannot init keys: %s", err)
+	}
+	data := []byte("foobar")
+	testReadFile(t, dir+"/data_1", testReadAll(t, dir+"/data_1"))
+	testWriteFile(t, dir+"/data_k2", data)
+	testReadFile(t, dir+"/data_k2", data)
+
+	// Files encrypted with unknown key mustn't be readable.
+	if err := ioutil.WriteFile(keyFile, []byte(testKeyLine("k1", 1)), 0600); err != nil {
+		t.Fatalf("cannot write key file: %s", err)
+	}
+	if err := Init(keyFile, ""); err != nil {
+		t.Fatalf("cannot init keys: %s", err)
+	}
+	if _, err := Open(dir+"/data_k2", true); err == nil {
+		t.Fatalf("expecting non-nil error when opening file encrypted with unknown key")
+	}
+
+	// Plaintext files must be readable when encryption is enabled.
+	plainPath := dir + "/plain"
+	if err := ioutil.WriteFile(plainPath, data, 0600); err != nil {
+		t.Fatalf("cannot write %q: %s", plainPath, err)
+	}
+	testReadFile(t, plainPath, data)
+
+	// Plaintext files must be created when encryption is disabled.
+	if err := Init("", ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testWriteFile(t, plainPath, data)
+	if fileData := testReadAll(t, plainPath); !bytes.Equal(fileData, data) {
+		t.Fatalf("unexpected plaintext file contents; got %q; want %q", fileData, data)
+	}
+}
+
+func TestFileTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestFileTruncated")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+		_ = Init("", "")
+	}()
+	if err := Init("", "echo '"+testKeyLine("k1", 1)+"'"); err != nil {
+		t.Fatalf("cannot init keys: %s", err)
+	}
+	path := dir + "/data"
+	testWriteFile(t, path, make([]byte, 3*chunkSize))
+	fileData, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read %q: %s", path, err)
+	}
+
+	// Drop the last chunk, so the file is truncated at chunk boundary.
+	if err := ioutil.WriteFile(path, fileData[:len(fileData)-tagSize], 0600); err != nil {
+		t.Fatalf("cannot write %q: %s", path, err)
+	}
+	r, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("cannot open %q: %s", path, err)
+	}
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Fatalf("expecting non-nil error when reading truncated file")
+	}
+	r.MustClose()
+
+	// Corrupt the data.
+	fileData[len(fileData)-tagSize-1] ^= 1
+	if err := ioutil.WriteFile(path, fileData, 0600); err != nil {
+		t.Fatalf("cannot write %q: %s", path, err)
+	}
+	r, err = Open(path, true)
+	if err != nil {
+		t.Fatalf("cannot open %q: %s", path, err)
+	}
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Fatalf("expecting non-nil error when reading corrupted file")
+	}
+	r.MustClose()
+}
+
+func testWriteFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	w, err := Create(path, true)
+	if err != nil {
+		t.Fatalf("cannot create %q: %s", path, err)
+	}
+	// Write data in small pieces in order to verify chunks handling.
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatalf("cannot write data to %q: %s", path, err)
+		}
+		data = data[n:]
+	}
+	w.MustClose()
+}
+
+func testReadAll(t *testing.T, path string) []byte {
+	t.Helper()
+	r, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("cannot open %q: %s", path, err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("cannot read %q: %s", path, err)
+	}
+	r.MustClose()
+	return data
+}
+
+func testReadFile(t *testing.T, path string, dataExpected []byte) {
+	t.Helper()
+	if data := testReadAll(t, path); !bytes.Equal(data, dataExpected) {
+		t.Fatalf("unexpected data read from %q; got %d bytes; want %d bytes", path, len(data), len(dataExpected))
+	}
+
+	ra := MustOpenReaderAt(path)
+	defer ra.MustClose()
+	ra.MustReadAt(nil, 0)
+	for _, off := range []int{0, 1, chunkSize - 10, chunkSize, 2*chunkSize + 5} {
+		for _, n := range []int{1, 20, chunkSize + 100, 3 * chunkSize} {
+			if off+n > len(dataExpected) {
+				continue
+			}
+			buf := make([]byte, n)
+			ra.MustReadAt(buf, int64(off))
+			if !bytes.Equal(buf, dataExpected[off:off+n]) {
+				t.Fatalf("unexpected data read from %q at off=%d, n=%d", path, off, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/filestream"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// Encrypted file format:
+//
+//   magic | keyIDLen (1 byte) | keyID | wrapNonce (12 bytes) | wrappedFileKey (48 bytes) | chunk_0 | ... | chunk_N
+//
+// Every file is encrypted with its own random 256-bit file key, which is wrapped with the key identified by keyID.
+// The data is split into chunks with up to chunkSize bytes. Every chunk is sealed with AES-GCM using the chunk index as a nonce.
+// The last chunk is authenticated with distinct additional data, so truncated files are detected.
+// All the chunks except of the last one contain exactly chunkSize bytes. The last chunk may be empty.
+
+var fileMagic = []byte("\x00VMENC01")
+
+const (
+	chunkSize = 4 * 1024
+
+	fileKeySize = 32
+	nonceSize   = 12
+	tagSize     = 16
+
+	sealedChunkSize = chunkSize + tagSize
+)
+
+var (
+	lastChunkAD  = []byte{1}
+	otherChunkAD = []byte{0}
+)
+
+// fileHeader contains the parsed header of the encrypted file.
+type fileHeader struct {
+	keyID string
+	fk    *key
+
+	// size is the header size in bytes.
+	size int64
+}
+
+func newFileHeader() ([]byte, *fileHeader, error) {
+	k := currentKey
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, nil, fmt.Errorf("cannot generate file key: %w", err)
+	}
+	wrapNonce := make([]byte, nonceSize)
+	if _, err := rand.Read(wrapNonce); err != nil {
+		return nil, nil, fmt.Errorf("cannot generate nonce: %w", err)
+	}
+	dst := append([]byte{}, fileMagic...)
+	dst = append(dst, byte(len(k.id)))
+	dst = append(dst, k.id...)
+	dst = append(dst, wrapNonce...)
+	dst = k.aead.Seal(dst, wrapNonce, fileKey, []byte(k.id))
+	fk, err := newKey(k.id, fileKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot initialize file key: %w", err)
+	}
+	fh := &fileHeader{
+		keyID: k.id,
+		fk:    fk,
+		size:  int64(len(dst)),
+	}
+	return dst, fh, nil
+}
+
+// readFileHeader reads the header from the file at the given path.
+//
+// It returns nil header if the file isn't encrypted.
+func readFileHeader(path string) (*fileHeader, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot open %q: %w", path, err)
+	}
+	defer fs.MustClose(f)
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot stat %q: %w", path, err)
+	}
+	fileSize := fi.Size()
+	buf := make([]byte, len(fileMagic)+1+255+nonceSize+fileKeySize+tagSize)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return nil, 0, fmt.Errorf("cannot read header from %q: %w", path, err)
+	}
+	buf = buf[:n]
+	if !bytes.HasPrefix(buf, fileMagic) {
+		return nil, fileSize, nil
+	}
+	src := buf[len(fileMagic):]
+	if len(src) < 1 || len(src) < 1+int(src[0])+nonceSize+fileKeySize+tagSize {
+		return nil, 0, fmt.Errorf("too short header in the encrypted file %q", path)
+	}
+	keyID := string(src[1 : 1+src[0]])
+	src = src[1+len(keyID):]
+	k := keys[keyID]
+	if k == nil {
+		return nil, 0, fmt.Errorf("missing encryption key %q for decrypting %q; the key must be passed via -storage.encryptionKeyFile or -storage.encryptionKeyCommand", keyID, path)
+	}
+	wrapNonce := src[:nonceSize]
+	fileKey, err := k.aead.Open(nil, wrapNonce, src[nonceSize:nonceSize+fileKeySize+tagSize], []byte(keyID))
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot decrypt file key for %q with the key %q: %w", path, keyID, err)
+	}
+	fk, err := newKey(keyID, fileKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot initialize file key for %q: %w", path, err)
+	}
+	fh := &fileHeader{
+		keyID: keyID,
+		fk:    fk,
+		size:  int64(len(fileMagic) + 1 + len(keyID) + nonceSize + fileKeySize + tagSize),
+	}
+	return fh, fileSize, nil
+}
+
+func (fh *fileHeader) getChunksCount(path string, fileSize int64) (uint64, int, error) {
+	n := fileSize - fh.size
+	if n < tagSize {
+		return 0, 0, fmt.Errorf("missing the last chunk in the encrypted file %q", path)
+	}
+	chunksCount := uint64((n + sealedChunkSize - 1) / sealedChunkSize)
+	lastChunkSize := int(n - int64(chunksCount-1)*sealedChunkSize)
+	if lastChunkSize < tagSize {
+		return 0, 0, fmt.Errorf("too short last chunk in the encrypted file %q; got %d bytes; want at least %d bytes", path, lastChunkSize, tagSize)
+	}
+	return chunksCount, lastChunkSize, nil
+}
+
+func (fh *fileHeader) openChunk(dst, src []byte, chunkIdx uint64, isLast bool) ([]byte, error) {
+	var nonce [nonceSize]byte
+	binary.BigEndian.PutUint64(nonce[nonceSize-8:], chunkIdx)
+	ad := otherChunkAD
+	if isLast {
+		ad = lastChunkAD
+	}
+	return fh.fk.aead.Open(dst, nonce[:], src, ad)
+}
+
+func (fh *fileHeader) sealChunk(dst, src []byte, chunkIdx uint64, isLast bool) []byte {
+	var nonce [nonceSize]byte
+	binary.BigEndian.PutUint64(nonce[nonceSize-8:], chunkIdx)
+	ad := otherChunkAD
+	if isLast {
+		ad = lastChunkAD
+	}
+	return fh.fk.aead.Seal(dst, nonce[:], src, ad)
+}
+
+// Create creates the file at the given path in nocache mode.
+//
+// The file is encrypted with the current key if encryption is enabled via Init.
+func Create(path string, nocache bool) (filestream.WriteCloser, error) {
+	w, err := filestream.Create(path, nocache)
+	if err != nil {
+		return nil, err
+	}
+	if !IsEnabled() {
+		return w, nil
+	}
+	header, fh, err := newFileHeader()
+	if err != nil {
+		w.MustClose()
+		return nil, fmt.Errorf("cannot create header for encrypted file %q: %w", path, err)
+	}
+	if _, err := w.Write(header); err != nil {
+		w.MustClose()
+		return nil, fmt.Errorf("cannot write header to encrypted file %q: %w", path, err)
+	}
+	return &writer{
+		w:    w,
+		path: path,
+		fh:   fh,
+	}, nil
+}
+
+type writer struct {
+	w    *filestream.Writer
+	path string
+	fh   *fileHeader
+
+	buf      []byte
+	sealBuf  []byte
+	chunkIdx uint64
+}
+
+// Write writes p to w.
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == chunkSize {
+			// Do not seal the full chunk until the next data arrives, since the last chunk must be sealed in a special way.
+			if err := w.flushChunk(false); err != nil {
+				return written, err
+			}
+		}
+		n := chunkSize - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *writer) flushChunk(isLast bool) error {
+	w.sealBuf = w.fh.sealChunk(w.sealBuf[:0], w.buf, w.chunkIdx, isLast)
+	w.chunkIdx++
+	w.buf = w.buf[:0]
+	if _, err := w.w.Write(w.sealBuf); err != nil {
+		return fmt.Errorf("cannot write encrypted chunk to %q: %w", w.path, err)
+	}
+	return nil
+}
+
+// MustClose seals the last chunk and closes w.
+func (w *writer) MustClose() {
+	if err := w.flushChunk(true); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+	w.w.MustClose()
+}
+
+// Open opens the file at the given path for sequential reading in nocache mode.
+//
+// The file is transparently decrypted if it is encrypted.
+func Open(path string, nocache bool) (filestream.ReadCloser, error) {
+	fh, fileSize, err := readFileHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	if fh == nil {
+		return filestream.Open(path, nocache)
+	}
+	chunksCount, lastChunkSize, err := fh.getChunksCount(path, fileSize)
+	if err != nil {
+		return nil, err
+	}
+	r, err := filestream.OpenReaderAt(path, fh.size, nocache)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{
+		r:             r,
+		path:          path,
+		fh:            fh,
+		chunksCount:   chunksCount,
+		lastChunkSize: lastChunkSize,
+	}, nil
+}
+
+type reader struct {
+	r    *filestream.Reader
+	path string
+	fh   *fileHeader
+
+	chunksCount   uint64
+	lastChunkSize int
+
+	chunkIdx   uint64
+	sealedBuf  []byte
+	buf        []byte
+	bufOffset  int
+	readFailed bool
+}
+
+// Read reads decrypted data to p.
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.bufOffset == len(r.buf) {
+		if r.readFailed {
+			return 0, fmt.Errorf("cannot read from %q after the previous error", r.path)
+		}
+		if r.chunkIdx == r.chunksCount {
+			return 0, io.EOF
+		}
+		if err := r.readChunk(); err != nil {
+			r.readFailed = true
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf[r.bufOffset:])
+	r.bufOffset += n
+	return n, nil
+}
+
+func (r *reader) readChunk() error {
+	isLast := r.chunkIdx == r.chunksCount-1
+	n := sealedChunkSize
+	if isLast {
+		n = r.lastChunkSize
+	}
+	if cap(r.sealedBuf) < n {
+		r.sealedBuf = make([]byte, sealedChunkSize)
+	}
+	r.sealedBuf = r.sealedBuf[:n]
+	if _, err := io.ReadFull(r.r, r.sealedBuf); err != nil {
+		return fmt.Errorf("cannot read encrypted chunk #%d from %q: %w", r.chunkIdx, r.path, err)
+	}
+	buf, err := r.fh.openChunk(r.buf[:0], r.sealedBuf, r.chunkIdx, isLast)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt chunk #%d from %q: %w", r.chunkIdx, r.path, err)
+	}
+	r.buf = buf
+	r.bufOffset = 0
+	r.chunkIdx++
+	return nil
+}
+
+// MustClose closes r.
+func (r *reader) MustClose() {
+	r.r.MustClose()
+}
+
+// MustOpenReaderAt opens the file at the given path for random access reading.
+//
+// The file is transparently decrypted if it is encrypted.
+//
+// MustClose must be called on the returned reader when it is no longer needed.
+func MustOpenReaderAt(path string) fs.MustReadAtCloser {
+	fh, fileSize, err := readFileHeader(path)
+	if err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+	if fh == nil {
+		return fs.MustOpenReaderAt(path)
+	}
+	chunksCount, lastChunkSize, err := fh.getChunksCount(path, fileSize)
+	if err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+	ra := &readerAt{
+		r:             fs.MustOpenReaderAt(path),
+		path:          path,
+		fh:            fh,
+		chunksCount:   chunksCount,
+		lastChunkSize: lastChunkSize,
+		filesCount:    metrics.GetOrCreateCounter(fmt.Sprintf(`vm_encrypted_files_opened{key_id=%q}`, fh.keyID)),
+	}
+	ra.filesCount.Inc()
+	return ra
+}
+
+type readerAt struct {
+	r    *fs.ReaderAt
+	path string
+	fh   *fileHeader
+
+	chunksCount   uint64
+	lastChunkSize int
+
+	filesCount *metrics.Counter
+}
+
+// MustReadAt reads len(p) decrypted bytes at off to p.
+func (ra *readerAt) MustReadAt(p []byte, off int64) {
+	if len(p) == 0 {
+		return
+	}
+	if off < 0 {
+		logger.Panicf("BUG: off=%d cannot be negative", off)
+	}
+	cb := getChunkBuf()
+	defer putChunkBuf(cb)
+	for len(p) > 0 {
+		chunkIdx := uint64(off / chunkSize)
+		if chunkIdx >= ra.chunksCount {
+			logger.Panicf("FATAL: off=%d is out of range for %q", off, ra.path)
+		}
+		isLast := chunkIdx == ra.chunksCount-1
+		n := sealedChunkSize
+		if isLast {
+			n = ra.lastChunkSize
+		}
+		cb.sealed = cb.sealed[:n]
+		ra.r.MustReadAt(cb.sealed, ra.fh.size+int64(chunkIdx)*sealedChunkSize)
+		buf, err := ra.fh.openChunk(cb.buf[:0], cb.sealed, chunkIdx, isLast)
+		if err != nil {
+			logger.Panicf("FATAL: cannot decrypt chunk #%d from %q: %s", chunkIdx, ra.path, err)
+		}
+		cb.buf = buf
+		chunkOffset := int(off % chunkSize)
+		if chunkOffset+len(p) > len(buf) && isLast {
+			logger.Panicf("FATAL: cannot read %d bytes at off=%d from %q, since it is out of range", len(p), off, ra.path)
+		}
+		n = copy(p, buf[chunkOffset:])
+		p = p[n:]
+		off += int64(n)
+	}
+}
+
+// MustClose closes ra.
+func (ra *readerAt) MustClose() {
+	ra.r.MustClose()
+	ra.filesCount.Dec()
+}
+
+type chunkBuf struct {
+	sealed []byte
+	buf    []byte
+}
+
+func getChunkBuf() *chunkBuf {
+	v := chunkBufPool.Get()
+	if v == nil {
+		return &chunkBuf{
+			sealed: make([]byte, sealedChunkSize),
+			buf:    make([]byte, 0, chunkSize),
+		}
+	}
+	return v.(*chunkBuf)
+}
+
+func putChunkBuf(cb *chunkBuf) {
+	chunkBufPool.Put(cb)
+}
+
+var chunkBufPool sync.Pool
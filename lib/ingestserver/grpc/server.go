@@ -0,0 +1,202 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
+	"github.com/VictoriaMetrics/metrics"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	writeRequests = metrics.NewCounter(`vm_ingestserver_requests_total{type="grpc", name="write", net="tcp"}`)
+	writeErrors   = metrics.NewCounter(`vm_ingestserver_request_errors_total{type="grpc", name="write", net="tcp"}`)
+	writeStreams  = metrics.NewCounter(`vm_ingestserver_streams_total{type="grpc", name="write", net="tcp"}`)
+	authErrors    = metrics.NewCounter(`vm_ingestserver_auth_errors_total{type="grpc", name="write", net="tcp"}`)
+)
+
+// Server accepts Prometheus remote write data via gRPC.
+//
+// See remotewrite.proto for the service definition.
+type Server struct {
+	s  *gogrpc.Server
+	ln net.Listener
+	wg sync.WaitGroup
+}
+
+// MustStart starts gRPC server on the given addr.
+//
+// Every stream must contain `authorization: Bearer <authKey>` metadata if authKey isn't empty.
+// Messages exceeding maxMsgSize bytes are rejected.
+// insertHandler is called for every uncompressed protobuf-encoded Prometheus WriteRequest received from streams.
+//
+// MustStop must be called on the returned server when it is no longer needed.
+func MustStart(addr, authKey string, maxMsgSize int, insertHandler func(data []byte) error) *Server {
+	logger.Infof("starting gRPC server at %q", addr)
+	ln, err := netutil.NewTCPListener("grpc", addr)
+	if err != nil {
+		logger.Fatalf("cannot start gRPC server at %q: %s", addr, err)
+	}
+	return MustServe(ln, authKey, maxMsgSize, insertHandler)
+}
+
+// MustServe serves gRPC requests from ln.
+//
+// MustStop must be called on the returned server when it is no longer needed.
+func MustServe(ln net.Listener, authKey string, maxMsgSize int, insertHandler func(data []byte) error) *Server {
+	gs := gogrpc.NewServer(
+		gogrpc.ForceServerCodec(rawCodec{}),
+		gogrpc.MaxRecvMsgSize(maxMsgSize),
+	)
+	gs.RegisterService(&writeServiceDesc, &writeHandler{
+		authKey:       authKey,
+		insertHandler: insertHandler,
+	})
+	s := &Server{
+		s:  gs,
+		ln: ln,
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.s.Serve(s.ln); err != nil {
+			logger.Fatalf("error serving gRPC at %q: %s", s.ln.Addr(), err)
+		}
+	}()
+	return s
+}
+
+// MustStop stops gRPC server.
+//
+// It waits until all the active streams are finished.
+func (s *Server) MustStop() {
+	logger.Infof("stopping gRPC server at %q...", s.ln.Addr())
+	s.s.GracefulStop()
+	s.wg.Wait()
+	logger.Infof("gRPC server at %q has been stopped", s.ln.Addr())
+}
+
+type writeServer interface {
+	write(stream gogrpc.ServerStream) error
+}
+
+var writeServiceDesc = gogrpc.ServiceDesc{
+	ServiceName: "victoriametrics.RemoteWrite",
+	HandlerType: (*writeServer)(nil),
+	Streams: []gogrpc.StreamDesc{
+		{
+			StreamName: "Write",
+			Handler: func(srv interface{}, stream gogrpc.ServerStream) error {
+				return srv.(writeServer).write(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "remotewrite.proto",
+}
+
+type writeHandler struct {
+	authKey       string
+	insertHandler func(data []byte) error
+}
+
+// write processes WriteRequest messages from stream one by one and sends an empty WriteResponse message after every processed WriteRequest.
+//
+// Messages are processed synchronously, so gRPC flow control applies backpressure to clients sending data faster than it can be ingested.
+func (wh *writeHandler) write(stream gogrpc.ServerStream) error {
+	writeStreams.Inc()
+	if err := wh.checkAuth(stream.Context()); err != nil {
+		authErrors.Inc()
+		return err
+	}
+	var req, resp rawMessage
+	for {
+		if err := stream.RecvMsg(&req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		writeRequests.Inc()
+		if err := wh.insertHandler(req.data); err != nil {
+			writeErrors.Inc()
+			return status.Error(getStatusCode(err), err.Error())
+		}
+		if err := stream.SendMsg(&resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (wh *writeHandler) checkAuth(ctx context.Context) error {
+	if wh.authKey == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if strings.TrimPrefix(v, "Bearer ") == wh.authKey {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid `authorization` metadata; it must contain `Bearer <-grpcAuthKey>`")
+}
+
+// getStatusCode returns gRPC status code for the error returned from insertHandler.
+func getStatusCode(err error) codes.Code {
+	var esc *httpserver.ErrorWithStatusCode
+	if !errors.As(err, &esc) {
+		return codes.InvalidArgument
+	}
+	switch esc.StatusCode {
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.InvalidArgument
+	}
+}
+
+// rawMessage holds protobuf-encoded message as is.
+type rawMessage struct {
+	data []byte
+}
+
+// rawCodec passes protobuf-encoded messages as is, so they are unmarshaled by the handler without intermediate copies.
+//
+// It is registered under `proto` name, so standard gRPC clients can send messages generated from remotewrite.proto.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(*rawMessage)
+	if !ok {
+		return nil, fmt.Errorf("BUG: unexpected message type %T", v)
+	}
+	return m.data, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(*rawMessage)
+	if !ok {
+		return fmt.Errorf("BUG: unexpected message type %T", v)
+	}
+	m.data = append(m.data[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	insertHandler := func(data []byte) error {
+		switch string(data) {
+		case "busy":
+			return &httpserver.ErrorWithStatusCode{
+				Err:        fmt.Errorf("too many requests"),
+				StatusCode: http.StatusTooManyRequests,
+			}
+		case "invalid":
+			return fmt.Errorf("cannot parse request")
+		}
+		mu.Lock()
+		received = append(received, string(data))
+		mu.Unlock()
+		return nil
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create listener: %s", err)
+	}
+	s := MustServe(ln, "secret", 1024, insertHandler)
+	defer s.MustStop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := gogrpc.DialContext(ctx, ln.Addr().String(), gogrpc.WithInsecure(), gogrpc.WithBlock(),
+		gogrpc.WithDefaultCallOptions(gogrpc.ForceCodec(rawCodec{})))
+	if err != nil {
+		t.Fatalf("cannot connect to gRPC server: %s", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	f := func(authKey string, msgs []string, codeExpected codes.Code) {
+		t.Helper()
+		streamCtx := ctx
+		if authKey != "" {
+			streamCtx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+authKey)
+		}
+		stream, err := conn.NewStream(streamCtx, &writeServiceDesc.Streams[0], "/victoriametrics.RemoteWrite/Write")
+		if err != nil {
+			t.Fatalf("cannot create stream: %s", err)
+		}
+		for _, msg := range msgs {
+			if err := stream.SendMsg(&rawMessage{data: []byte(msg)}); err != nil {
+				break
+			}
+			var resp rawMessage
+			if err := stream.RecvMsg(&resp); err != nil {
+				break
+			}
+		}
+		_ = stream.CloseSend()
+		var resp rawMessage
+		err = stream.RecvMsg(&resp)
+		if err == io.EOF {
+			// The stream has been successfully finished.
+			err = nil
+		}
+		if code := status.Code(err); code != codeExpected {
+			t.Fatalf("unexpected status code; got %s; want %s; err: %v", code, codeExpected, err)
+		}
+	}
+
+	// Successful stream
+	f("secret", []string{"foo", "bar"}, codes.OK)
+
+	// Missing or invalid auth key
+	f("", []string{"baz"}, codes.Unauthenticated)
+	f("invalid", []string{"baz"}, codes.Unauthenticated)
+
+	// Errors from insertHandler
+	f("secret", []string{"qwe", "busy", "rty"}, codes.ResourceExhausted)
+	f("secret", []string{"invalid"}, codes.InvalidArgument)
+
+	// Too big message
+	f("secret", []string{string(make([]byte, 2048))}, codes.ResourceExhausted)
+
+	mu.Lock()
+	defer mu.Unlock()
+	receivedExpected := []string{"foo", "bar", "qwe"}
+	if !reflect.DeepEqual(received, receivedExpected) {
+		t.Fatalf("unexpected received messages; got %q; want %q", received, receivedExpected)
+	}
+}
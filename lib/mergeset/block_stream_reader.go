@@ -8,6 +8,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/filestream"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -135,7 +136,7 @@ func (bsr *blockStreamReader) InitFromFilePart(path string) error {
 	}
 
 	metaindexPath := path + "/metaindex.bin"
-	metaindexFile, err := filestream.Open(metaindexPath, true)
+	metaindexFile, err := encryption.Open(metaindexPath, true)
 	if err != nil {
 		return fmt.Errorf("cannot open metaindex file in stream mode: %w", err)
 	}
@@ -146,20 +147,20 @@ func (bsr *blockStreamReader) InitFromFilePart(path string) error {
 	}
 
 	indexPath := path + "/index.bin"
-	indexFile, err := filestream.Open(indexPath, true)
+	indexFile, err := encryption.Open(indexPath, true)
 	if err != nil {
 		return fmt.Errorf("cannot open index file in stream mode: %w", err)
 	}
 
 	itemsPath := path + "/items.bin"
-	itemsFile, err := filestream.Open(itemsPath, true)
+	itemsFile, err := encryption.Open(itemsPath, true)
 	if err != nil {
 		indexFile.MustClose()
 		return fmt.Errorf("cannot open items file in stream mode: %w", err)
 	}
 
 	lensPath := path + "/lens.bin"
-	lensFile, err := filestream.Open(lensPath, true)
+	lensFile, err := encryption.Open(lensPath, true)
 	if err != nil {
 		indexFile.MustClose()
 		itemsFile.MustClose()
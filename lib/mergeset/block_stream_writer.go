@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/filestream"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 )
@@ -92,14 +93,14 @@ func (bsw *blockStreamWriter) InitFromFilePart(path string, nocache bool, compre
 	// Always cache metaindex file in OS page cache, since it is immediately
 	// read after the merge.
 	metaindexPath := path + "/metaindex.bin"
-	metaindexFile, err := filestream.Create(metaindexPath, false)
+	metaindexFile, err := encryption.Create(metaindexPath, false)
 	if err != nil {
 		fs.MustRemoveAll(path)
 		return fmt.Errorf("cannot create metaindex file: %w", err)
 	}
 
 	indexPath := path + "/index.bin"
-	indexFile, err := filestream.Create(indexPath, nocache)
+	indexFile, err := encryption.Create(indexPath, nocache)
 	if err != nil {
 		metaindexFile.MustClose()
 		fs.MustRemoveAll(path)
@@ -107,7 +108,7 @@ func (bsw *blockStreamWriter) InitFromFilePart(path string, nocache bool, compre
 	}
 
 	itemsPath := path + "/items.bin"
-	itemsFile, err := filestream.Create(itemsPath, nocache)
+	itemsFile, err := encryption.Create(itemsPath, nocache)
 	if err != nil {
 		metaindexFile.MustClose()
 		indexFile.MustClose()
@@ -116,7 +117,7 @@ func (bsw *blockStreamWriter) InitFromFilePart(path string, nocache bool, compre
 	}
 
 	lensPath := path + "/lens.bin"
-	lensFile, err := filestream.Create(lensPath, nocache)
+	lensFile, err := encryption.Create(lensPath, nocache)
 	if err != nil {
 		metaindexFile.MustClose()
 		indexFile.MustClose()
@@ -8,19 +8,45 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/filestream"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/memory"
 )
 
+var (
+	indexBlocksCachePercent = 25.0
+	dataBlocksCachePercent  = 25.0
+)
+
+// SetIndexBlocksCachePercent sets the limit on the number of cached index blocks per part as a percent of allowed memory.
+//
+// This function must be called before opening tables.
+func SetIndexBlocksCachePercent(percent float64) {
+	indexBlocksCachePercent = percent
+}
+
+// SetDataBlocksCachePercent sets the limit on the number of cached data blocks per part as a percent of allowed memory.
+//
+// This function must be called before opening tables.
+func SetDataBlocksCachePercent(percent float64) {
+	dataBlocksCachePercent = percent
+}
+
+// getMaxCachedBlocksPerPart returns the number of blocks per part, which may be cached for the given percent of allowed memory.
+func getMaxCachedBlocksPerPart(percent float64) int {
+	// Allow caching up to a block per MiB of the given memory share per part.
+	n := int(float64(memory.Allowed())*percent/100) / 1024 / 1024
+	if n == 0 {
+		n = 10
+	}
+	return n
+}
+
 func getMaxCachedIndexBlocksPerPart() int {
 	maxCachedIndexBlocksPerPartOnce.Do(func() {
-		n := memory.Allowed() / 1024 / 1024 / 4
-		if n == 0 {
-			n = 10
-		}
-		maxCachedIndexBlocksPerPart = n
+		maxCachedIndexBlocksPerPart = getMaxCachedBlocksPerPart(indexBlocksCachePercent)
 	})
 	return maxCachedIndexBlocksPerPart
 }
@@ -32,11 +58,7 @@ var (
 
 func getMaxCachedInmemoryBlocksPerPart() int {
 	maxCachedInmemoryBlocksPerPartOnce.Do(func() {
-		n := memory.Allowed() / 1024 / 1024 / 4
-		if n == 0 {
-			n = 10
-		}
-		maxCachedInmemoryBlocksPerPart = n
+		maxCachedInmemoryBlocksPerPart = getMaxCachedBlocksPerPart(dataBlocksCachePercent)
 	})
 	return maxCachedInmemoryBlocksPerPart
 }
@@ -72,22 +94,22 @@ func openFilePart(path string) (*part, error) {
 	}
 
 	metaindexPath := path + "/metaindex.bin"
-	metaindexFile, err := filestream.Open(metaindexPath, true)
+	metaindexFile, err := encryption.Open(metaindexPath, true)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open %q: %w", metaindexPath, err)
 	}
 	metaindexSize := fs.MustFileSize(metaindexPath)
 
 	indexPath := path + "/index.bin"
-	indexFile := fs.MustOpenReaderAt(indexPath)
+	indexFile := encryption.MustOpenReaderAt(indexPath)
 	indexSize := fs.MustFileSize(indexPath)
 
 	itemsPath := path + "/items.bin"
-	itemsFile := fs.MustOpenReaderAt(itemsPath)
+	itemsFile := encryption.MustOpenReaderAt(itemsPath)
 	itemsSize := fs.MustFileSize(itemsPath)
 
 	lensPath := path + "/lens.bin"
-	lensFile := fs.MustOpenReaderAt(lensPath)
+	lensFile := encryption.MustOpenReaderAt(lensPath)
 	lensSize := fs.MustFileSize(lensPath)
 
 	size := metaindexSize + indexSize + itemsSize + lensSize
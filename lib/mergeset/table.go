@@ -590,6 +590,87 @@ func (tb *Table) mergePartsOptimal(pws []*partWrapper, stopCh <-chan struct{}) e
 	return nil
 }
 
+// ForceMergeAllParts merges all the file parts in tb.
+//
+// All the items from the merged parts are passed to PrepareBlockCallback, so it may drop unneeded items.
+// The function waits until the active merges are finished before starting the merge.
+// It returns immediately when stopCh is closed or when tb is closed.
+func (tb *Table) ForceMergeAllParts(stopCh <-chan struct{}) error {
+	tb.partMergersWG.Add(1)
+	defer tb.partMergersWG.Done()
+
+	mergeStopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	go func() {
+		select {
+		case <-stopCh:
+		case <-tb.stopCh:
+		case <-doneCh:
+			return
+		}
+		close(mergeStopCh)
+	}()
+
+	pws := tb.getAllPartsForForceMerge(mergeStopCh)
+	if len(pws) == 0 {
+		// Nothing to merge or the merge is stopped.
+		return nil
+	}
+	if err := tb.mergePartsOptimal(pws, mergeStopCh); err != nil {
+		if errors.Is(err, errForciblyStopped) {
+			return nil
+		}
+		return fmt.Errorf("cannot force merge %d parts in %q: %w", len(pws), tb.path, err)
+	}
+	return nil
+}
+
+// getAllPartsForForceMerge returns all the file parts from tb after waiting for active merges.
+//
+// The returned parts are marked with isInMerge flag.
+// nil is returned if tb has no file parts or if stopCh is closed.
+func (tb *Table) getAllPartsForForceMerge(stopCh <-chan struct{}) []*partWrapper {
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		default:
+		}
+		var pws []*partWrapper
+		tb.partsLock.Lock()
+		hasMerges := false
+		for _, pw := range tb.parts {
+			if pw.isInMerge {
+				hasMerges = true
+				break
+			}
+		}
+		if !hasMerges {
+			for _, pw := range tb.parts {
+				if pw.mp != nil {
+					// Inmemory parts are merged into file parts by background mergers.
+					continue
+				}
+				pw.isInMerge = true
+				pws = append(pws, pw)
+			}
+		}
+		tb.partsLock.Unlock()
+		if !hasMerges {
+			return pws
+		}
+
+		t := time.NewTimer(time.Second)
+		select {
+		case <-stopCh:
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
 // DebugFlush flushes all the added items to the storage,
 // so they become visible to search.
 //
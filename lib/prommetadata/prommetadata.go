@@ -0,0 +1,52 @@
+package prommetadata
+
+import (
+	"sync/atomic"
+)
+
+// Row contains metadata for a single metric obtained from `# HELP`, `# TYPE` and `# UNIT` lines.
+type Row struct {
+	Metric string
+	Type   string
+	Help   string
+	Unit   string
+}
+
+// Reset resets r.
+func (r *Row) Reset() {
+	r.Metric = ""
+	r.Type = ""
+	r.Help = ""
+	r.Unit = ""
+}
+
+// Init enables collecting metric metadata via Add.
+//
+// addFunc is called for every non-empty batch of rows passed to Add. It must be safe for calling from concurrently running goroutines.
+// addFunc mustn't hold references to rows after returning.
+//
+// Metadata isn't collected until Init is called, so components without /api/v1/metadata support do not waste resources on it.
+func Init(addFunc func(rows []Row)) {
+	addMetadata = addFunc
+	atomic.StoreUint32(&enabled, 1)
+}
+
+// IsEnabled returns true if metadata collection is enabled with Init.
+func IsEnabled() bool {
+	return atomic.LoadUint32(&enabled) != 0
+}
+
+var (
+	enabled     uint32
+	addMetadata func(rows []Row)
+)
+
+// Add registers the given metadata rows.
+//
+// It is safe calling Add from concurrently running goroutines.
+func Add(rows []Row) {
+	if !IsEnabled() || len(rows) == 0 {
+		return
+	}
+	addMetadata(rows)
+}
@@ -0,0 +1,72 @@
+package prompb
+
+import (
+	"fmt"
+)
+
+// Exemplar is an exemplar from Prometheus remote write protocol.
+//
+// See https://github.com/prometheus/prometheus/blob/main/prompb/types.proto
+type Exemplar struct {
+	// Labels contains optional labels for the exemplar such as trace_id.
+	Labels []Label
+
+	Value     float64
+	Timestamp int64
+}
+
+// Unmarshal unmarshals e from protobuf-encoded src.
+//
+// e refers to src after returning, so src mustn't be modified while e is in use.
+func (e *Exemplar) Unmarshal(src []byte) error {
+	labels := e.Labels[:0]
+	*e = Exemplar{}
+	for len(src) > 0 {
+		preSrc := src
+		fieldNum, wireType, tail, err := readTag(src)
+		if err != nil {
+			return fmt.Errorf("proto: Exemplar: %w", err)
+		}
+		src = tail
+		switch fieldNum {
+		case 1:
+			// labels
+			data, tail, err := readBytes(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Exemplar: cannot read labels: %w", err)
+			}
+			src = tail
+			labels = append(labels, Label{})
+			if err := labels[len(labels)-1].Unmarshal(data); err != nil {
+				return err
+			}
+		case 2:
+			// value
+			v, tail, err := readDouble(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Exemplar: cannot read value: %w", err)
+			}
+			src = tail
+			e.Value = v
+		case 3:
+			// timestamp
+			v, tail, err := readVarint(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Exemplar: cannot read timestamp: %w", err)
+			}
+			src = tail
+			e.Timestamp = int64(v)
+		default:
+			n, err := skipTypes(preSrc)
+			if err != nil {
+				return err
+			}
+			if n > len(preSrc) {
+				return fmt.Errorf("proto: Exemplar: unexpected end of data")
+			}
+			src = preSrc[n:]
+		}
+	}
+	e.Labels = labels
+	return nil
+}
@@ -0,0 +1,60 @@
+package prompb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTimeSeriesUnmarshalExemplars(t *testing.T) {
+	var label []byte
+	label = appendBytesField(label, 1, []byte("trace_id"))
+	label = appendBytesField(label, 2, []byte("abc"))
+
+	var exemplar []byte
+	exemplar = appendBytesField(exemplar, 1, label)
+	exemplar = appendDoubleField(exemplar, 2, 1.5)
+	exemplar = appendVarintField(exemplar, 3, 1234)
+
+	var sample []byte
+	sample = appendDoubleField(sample, 1, 42)
+	sample = appendVarintField(sample, 2, 1234)
+
+	var data []byte
+	data = appendBytesField(data, 2, sample)
+	data = appendBytesField(data, 3, exemplar)
+	data = appendBytesField(data, 3, appendDoubleField(nil, 2, 2))
+
+	var ts TimeSeries
+	if _, _, err := ts.Unmarshal(data, nil, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	exemplarsExpected := []Exemplar{
+		{
+			Labels: []Label{{
+				Name:  []byte("trace_id"),
+				Value: []byte("abc"),
+			}},
+			Value:     1.5,
+			Timestamp: 1234,
+		},
+		{
+			Value: 2,
+		},
+	}
+	if !reflect.DeepEqual(ts.Exemplars, exemplarsExpected) {
+		t.Fatalf("unexpected exemplars;\ngot\n%+v\nwant\n%+v", ts.Exemplars, exemplarsExpected)
+	}
+	samplesExpected := []Sample{{
+		Value:     42,
+		Timestamp: 1234,
+	}}
+	if !reflect.DeepEqual(ts.Samples, samplesExpected) {
+		t.Fatalf("unexpected samples;\ngot\n%+v\nwant\n%+v", ts.Samples, samplesExpected)
+	}
+
+	// Invalid exemplar must result in error.
+	data = appendBytesField(nil, 3, appendVarintField(nil, 2, 1))
+	if _, _, err := ts.Unmarshal(data, nil, nil); err == nil {
+		t.Fatalf("expecting non-nil error for invalid exemplar")
+	}
+}
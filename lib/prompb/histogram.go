@@ -0,0 +1,567 @@
+package prompb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+// Histogram is Prometheus native histogram.
+//
+// See https://github.com/prometheus/prometheus/blob/main/prompb/types.proto
+type Histogram struct {
+	// Count is the total number of observations.
+	Count float64
+
+	// Sum is the sum of observations.
+	Sum float64
+
+	// Schema defines the bucket layout. Bucket boundaries are powers of 2^(2^-Schema).
+	Schema int32
+
+	// ZeroThreshold is the width of the zero bucket.
+	ZeroThreshold float64
+
+	// ZeroCount is the number of observations in the zero bucket.
+	ZeroCount float64
+
+	NegativeSpans  []BucketSpan
+	NegativeDeltas []int64
+	NegativeCounts []float64
+
+	PositiveSpans  []BucketSpan
+	PositiveDeltas []int64
+	PositiveCounts []float64
+
+	// Timestamp is the timestamp in milliseconds.
+	Timestamp int64
+}
+
+// BucketSpan defines a number of consecutive buckets with their offset.
+type BucketSpan struct {
+	// Offset is the gap to the previous span or the starting bucket index for the first span.
+	Offset int32
+
+	// Length is the number of consecutive buckets.
+	Length uint32
+}
+
+// Unmarshal unmarshals h from src.
+func (h *Histogram) Unmarshal(src []byte) error {
+	*h = Histogram{}
+	for len(src) > 0 {
+		preSrc := src
+		fieldNum, wireType, tail, err := readTag(src)
+		if err != nil {
+			return fmt.Errorf("proto: Histogram: %w", err)
+		}
+		src = tail
+		switch fieldNum {
+		case 1, 6:
+			// count_int, zero_count_int
+			v, tail, err := readVarint(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Histogram: cannot read field %d: %w", fieldNum, err)
+			}
+			src = tail
+			if fieldNum == 1 {
+				h.Count = float64(v)
+			} else {
+				h.ZeroCount = float64(v)
+			}
+		case 2, 3, 5, 7:
+			// count_float, sum, zero_threshold, zero_count_float
+			v, tail, err := readDouble(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Histogram: cannot read field %d: %w", fieldNum, err)
+			}
+			src = tail
+			switch fieldNum {
+			case 2:
+				h.Count = v
+			case 3:
+				h.Sum = v
+			case 5:
+				h.ZeroThreshold = v
+			case 7:
+				h.ZeroCount = v
+			}
+		case 4:
+			// schema
+			v, tail, err := readVarint(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Histogram: cannot read schema: %w", err)
+			}
+			src = tail
+			h.Schema = int32(decodeZigZag(v))
+		case 8, 11:
+			// negative_spans, positive_spans
+			data, tail, err := readBytes(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Histogram: cannot read field %d: %w", fieldNum, err)
+			}
+			src = tail
+			var span BucketSpan
+			if err := span.Unmarshal(data); err != nil {
+				return err
+			}
+			if fieldNum == 8 {
+				h.NegativeSpans = append(h.NegativeSpans, span)
+			} else {
+				h.PositiveSpans = append(h.PositiveSpans, span)
+			}
+		case 9, 12:
+			// negative_deltas, positive_deltas
+			deltas := &h.PositiveDeltas
+			if fieldNum == 9 {
+				deltas = &h.NegativeDeltas
+			}
+			tail, err := readSint64s(deltas, src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Histogram: cannot read field %d: %w", fieldNum, err)
+			}
+			src = tail
+		case 10, 13:
+			// negative_counts, positive_counts
+			counts := &h.PositiveCounts
+			if fieldNum == 10 {
+				counts = &h.NegativeCounts
+			}
+			tail, err := readDoubles(counts, src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Histogram: cannot read field %d: %w", fieldNum, err)
+			}
+			src = tail
+		case 15:
+			// timestamp
+			v, tail, err := readVarint(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Histogram: cannot read timestamp: %w", err)
+			}
+			src = tail
+			h.Timestamp = int64(v)
+		default:
+			// Skip unknown fields such as reset_hint.
+			n, err := skipTypes(preSrc)
+			if err != nil {
+				return err
+			}
+			if n > len(preSrc) {
+				return fmt.Errorf("proto: Histogram: unexpected end of data")
+			}
+			src = preSrc[n:]
+		}
+	}
+	return nil
+}
+
+// Unmarshal unmarshals bs from src.
+func (bs *BucketSpan) Unmarshal(src []byte) error {
+	*bs = BucketSpan{}
+	for len(src) > 0 {
+		preSrc := src
+		fieldNum, wireType, tail, err := readTag(src)
+		if err != nil {
+			return fmt.Errorf("proto: BucketSpan: %w", err)
+		}
+		src = tail
+		switch fieldNum {
+		case 1, 2:
+			v, tail, err := readVarint(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: BucketSpan: cannot read field %d: %w", fieldNum, err)
+			}
+			src = tail
+			if fieldNum == 1 {
+				bs.Offset = int32(decodeZigZag(v))
+			} else {
+				bs.Length = uint32(v)
+			}
+		default:
+			n, err := skipTypes(preSrc)
+			if err != nil {
+				return err
+			}
+			if n > len(preSrc) {
+				return fmt.Errorf("proto: BucketSpan: unexpected end of data")
+			}
+			src = preSrc[n:]
+		}
+	}
+	return nil
+}
+
+// VMRangeBucket is a bucket of VictoriaMetrics histogram.
+//
+// See https://valyala.medium.com/improving-histogram-usability-for-prometheus-and-grafana-bc7e5df0e350
+type VMRangeBucket struct {
+	// Start is the lower bound for the bucket.
+	Start float64
+
+	// End is the upper bound for the bucket.
+	End float64
+
+	// Count is the number of observations in the bucket.
+	Count float64
+}
+
+// VMRange returns `vmrange` label value for b.
+func (b *VMRangeBucket) VMRange() string {
+	return formatBound(b.Start) + "..." + formatBound(b.End)
+}
+
+func formatBound(v float64) string {
+	// Use the shortest exact representation, so adjacent bucket bounds match.
+	return fmt.Sprintf("%g", v)
+}
+
+// AppendVMRangeBuckets appends buckets from h to dst and returns the result.
+//
+// Bucket bounds and counts are preserved, while the schema and the counter reset hint of h are dropped.
+func (h *Histogram) AppendVMRangeBuckets(dst []VMRangeBucket) ([]VMRangeBucket, error) {
+	if h.Schema < -4 || h.Schema > 8 {
+		return dst, fmt.Errorf("unsupported native histogram schema %d; supported range is [-4..8]", h.Schema)
+	}
+	negatives, err := h.bucketCounts(h.NegativeSpans, h.NegativeDeltas, h.NegativeCounts)
+	if err != nil {
+		return dst, fmt.Errorf("invalid negative buckets: %w", err)
+	}
+	positives, err := h.bucketCounts(h.PositiveSpans, h.PositiveDeltas, h.PositiveCounts)
+	if err != nil {
+		return dst, fmt.Errorf("invalid positive buckets: %w", err)
+	}
+	// Negative buckets are appended in ascending order of their bounds.
+	for i := len(negatives) - 1; i >= 0; i-- {
+		b := &negatives[i]
+		dst = append(dst, VMRangeBucket{
+			Start: -bucketUpperBound(b.index, h.Schema),
+			End:   -bucketUpperBound(b.index-1, h.Schema),
+			Count: b.count,
+		})
+	}
+	if h.ZeroCount > 0 || h.ZeroThreshold > 0 {
+		start := -h.ZeroThreshold
+		if h.ZeroThreshold == 0 {
+			start = 0
+		}
+		dst = append(dst, VMRangeBucket{
+			Start: start,
+			End:   h.ZeroThreshold,
+			Count: h.ZeroCount,
+		})
+	}
+	for i := range positives {
+		b := &positives[i]
+		dst = append(dst, VMRangeBucket{
+			Start: bucketUpperBound(b.index-1, h.Schema),
+			End:   bucketUpperBound(b.index, h.Schema),
+			Count: b.count,
+		})
+	}
+	return dst, nil
+}
+
+type bucketCount struct {
+	index int32
+	count float64
+}
+
+func (h *Histogram) bucketCounts(spans []BucketSpan, deltas []int64, counts []float64) ([]bucketCount, error) {
+	n := 0
+	for _, span := range spans {
+		n += int(span.Length)
+	}
+	isFloat := len(counts) > 0
+	if isFloat && len(counts) != n {
+		return nil, fmt.Errorf("the number of counts (%d) doesn't match the number of buckets in spans (%d)", len(counts), n)
+	}
+	if !isFloat && len(deltas) != n {
+		return nil, fmt.Errorf("the number of deltas (%d) doesn't match the number of buckets in spans (%d)", len(deltas), n)
+	}
+	bcs := make([]bucketCount, 0, n)
+	var index int32
+	var count int64
+	j := 0
+	for i, span := range spans {
+		if i == 0 {
+			index = span.Offset
+		} else {
+			index += span.Offset
+		}
+		for k := uint32(0); k < span.Length; k++ {
+			var v float64
+			if isFloat {
+				v = counts[j]
+			} else {
+				count += deltas[j]
+				v = float64(count)
+			}
+			bcs = append(bcs, bucketCount{
+				index: index,
+				count: v,
+			})
+			index++
+			j++
+		}
+	}
+	return bcs, nil
+}
+
+// bucketUpperBound returns the upper bound for the bucket with the given index and schema.
+func bucketUpperBound(index, schema int32) float64 {
+	if schema <= 0 {
+		// Bucket bounds are exact powers of 2 for non-positive schemas.
+		return math.Ldexp(1, int(index)<<uint(-schema))
+	}
+	// The base is 2^(2^-schema), so bucket bound is 2^(index/2^schema).
+	frac := int32(1) << uint(schema)
+	exp := index / frac
+	rem := index % frac
+	if rem < 0 {
+		rem += frac
+		exp--
+	}
+	return math.Ldexp(math.Exp2(float64(rem)/float64(frac)), int(exp))
+}
+
+// MarshalCompact appends compact binary representation of h to dst and returns the result.
+//
+// The compact representation is used for storing native histograms in VictoriaMetrics storage.
+// It doesn't contain h.Timestamp. Use UnmarshalCompact for unmarshaling the result.
+func (h *Histogram) MarshalCompact(dst []byte) []byte {
+	isFloat := len(h.NegativeCounts) > 0 || len(h.PositiveCounts) > 0
+	flags := byte(0)
+	if isFloat {
+		flags |= compactHistogramFloatCounts
+	}
+	dst = append(dst, flags)
+	dst = encoding.MarshalVarInt64(dst, int64(h.Schema))
+	dst = encoding.MarshalUint64(dst, math.Float64bits(h.ZeroThreshold))
+	dst = encoding.MarshalUint64(dst, math.Float64bits(h.ZeroCount))
+	dst = encoding.MarshalUint64(dst, math.Float64bits(h.Count))
+	dst = encoding.MarshalUint64(dst, math.Float64bits(h.Sum))
+	dst = marshalCompactBuckets(dst, h.NegativeSpans, h.NegativeDeltas, h.NegativeCounts, isFloat)
+	dst = marshalCompactBuckets(dst, h.PositiveSpans, h.PositiveDeltas, h.PositiveCounts, isFloat)
+	return dst
+}
+
+// compactHistogramFloatCounts is set in the compact histogram flags if bucket counts are stored as float64 values instead of integer deltas.
+const compactHistogramFloatCounts = 1
+
+func marshalCompactBuckets(dst []byte, spans []BucketSpan, deltas []int64, counts []float64, isFloat bool) []byte {
+	dst = encoding.MarshalVarUint64(dst, uint64(len(spans)))
+	for _, span := range spans {
+		dst = encoding.MarshalVarInt64(dst, int64(span.Offset))
+		dst = encoding.MarshalVarUint64(dst, uint64(span.Length))
+	}
+	if isFloat {
+		dst = encoding.MarshalVarUint64(dst, uint64(len(counts)))
+		for _, v := range counts {
+			dst = encoding.MarshalUint64(dst, math.Float64bits(v))
+		}
+		return dst
+	}
+	dst = encoding.MarshalVarUint64(dst, uint64(len(deltas)))
+	for _, v := range deltas {
+		dst = encoding.MarshalVarInt64(dst, v)
+	}
+	return dst
+}
+
+// UnmarshalCompact unmarshals h from src, which must be obtained via MarshalCompact.
+//
+// h.Timestamp isn't changed.
+func (h *Histogram) UnmarshalCompact(src []byte) error {
+	*h = Histogram{
+		Timestamp: h.Timestamp,
+	}
+	if len(src) < 1 {
+		return fmt.Errorf("cannot unmarshal compact histogram flags from empty data")
+	}
+	isFloat := src[0]&compactHistogramFloatCounts != 0
+	src, schema, err := encoding.UnmarshalVarInt64(src[1:])
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal schema: %w", err)
+	}
+	if schema < math.MinInt32 || schema > math.MaxInt32 {
+		return fmt.Errorf("schema=%d is out of int32 range", schema)
+	}
+	h.Schema = int32(schema)
+	if len(src) < 4*8 {
+		return fmt.Errorf("cannot unmarshal zero threshold, zero count, count and sum from %d bytes; need %d bytes", len(src), 4*8)
+	}
+	h.ZeroThreshold = math.Float64frombits(encoding.UnmarshalUint64(src))
+	h.ZeroCount = math.Float64frombits(encoding.UnmarshalUint64(src[8:]))
+	h.Count = math.Float64frombits(encoding.UnmarshalUint64(src[16:]))
+	h.Sum = math.Float64frombits(encoding.UnmarshalUint64(src[24:]))
+	src = src[4*8:]
+	src, err = unmarshalCompactBuckets(src, &h.NegativeSpans, &h.NegativeDeltas, &h.NegativeCounts, isFloat)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal negative buckets: %w", err)
+	}
+	src, err = unmarshalCompactBuckets(src, &h.PositiveSpans, &h.PositiveDeltas, &h.PositiveCounts, isFloat)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal positive buckets: %w", err)
+	}
+	if len(src) > 0 {
+		return fmt.Errorf("unexpected non-empty tail left after unmarshaling compact histogram; len(tail)=%d", len(src))
+	}
+	return nil
+}
+
+func unmarshalCompactBuckets(src []byte, spans *[]BucketSpan, deltas *[]int64, counts *[]float64, isFloat bool) ([]byte, error) {
+	src, spansLen, err := encoding.UnmarshalVarUint64(src)
+	if err != nil {
+		return src, fmt.Errorf("cannot unmarshal the number of spans: %w", err)
+	}
+	if spansLen > uint64(len(src)) {
+		return src, fmt.Errorf("too big number of spans: %d", spansLen)
+	}
+	for i := uint64(0); i < spansLen; i++ {
+		var offset int64
+		var length uint64
+		src, offset, err = encoding.UnmarshalVarInt64(src)
+		if err != nil {
+			return src, fmt.Errorf("cannot unmarshal span offset: %w", err)
+		}
+		src, length, err = encoding.UnmarshalVarUint64(src)
+		if err != nil {
+			return src, fmt.Errorf("cannot unmarshal span length: %w", err)
+		}
+		if offset < math.MinInt32 || offset > math.MaxInt32 || length > math.MaxUint32 {
+			return src, fmt.Errorf("span offset=%d or length=%d is out of range", offset, length)
+		}
+		*spans = append(*spans, BucketSpan{
+			Offset: int32(offset),
+			Length: uint32(length),
+		})
+	}
+	src, bucketsLen, err := encoding.UnmarshalVarUint64(src)
+	if err != nil {
+		return src, fmt.Errorf("cannot unmarshal the number of buckets: %w", err)
+	}
+	if bucketsLen > uint64(len(src)) {
+		return src, fmt.Errorf("too big number of buckets: %d", bucketsLen)
+	}
+	for i := uint64(0); i < bucketsLen; i++ {
+		if isFloat {
+			if len(src) < 8 {
+				return src, fmt.Errorf("cannot unmarshal bucket count from %d bytes; need 8 bytes", len(src))
+			}
+			*counts = append(*counts, math.Float64frombits(encoding.UnmarshalUint64(src)))
+			src = src[8:]
+			continue
+		}
+		var delta int64
+		src, delta, err = encoding.UnmarshalVarInt64(src)
+		if err != nil {
+			return src, fmt.Errorf("cannot unmarshal bucket delta: %w", err)
+		}
+		*deltas = append(*deltas, delta)
+	}
+	return src, nil
+}
+
+func readTag(src []byte) (int32, int, []byte, error) {
+	wire, n := binary.Uvarint(src)
+	if n <= 0 {
+		return 0, 0, src, fmt.Errorf("cannot read field tag")
+	}
+	fieldNum := int32(wire >> 3)
+	if fieldNum <= 0 {
+		return 0, 0, src, fmt.Errorf("illegal tag %d", fieldNum)
+	}
+	return fieldNum, int(wire & 0x7), src[n:], nil
+}
+
+func readVarint(src []byte, wireType int) (uint64, []byte, error) {
+	if wireType != 0 {
+		return 0, src, fmt.Errorf("unexpected wireType=%d; want 0", wireType)
+	}
+	v, n := binary.Uvarint(src)
+	if n <= 0 {
+		return 0, src, fmt.Errorf("cannot read varint")
+	}
+	return v, src[n:], nil
+}
+
+func readDouble(src []byte, wireType int) (float64, []byte, error) {
+	if wireType != 1 {
+		return 0, src, fmt.Errorf("unexpected wireType=%d; want 1", wireType)
+	}
+	if len(src) < 8 {
+		return 0, src, fmt.Errorf("cannot read double from %d bytes", len(src))
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(src))
+	return v, src[8:], nil
+}
+
+func readBytes(src []byte, wireType int) ([]byte, []byte, error) {
+	if wireType != 2 {
+		return nil, src, fmt.Errorf("unexpected wireType=%d; want 2", wireType)
+	}
+	size, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, src, fmt.Errorf("cannot read length")
+	}
+	src = src[n:]
+	if size > uint64(len(src)) {
+		return nil, src, fmt.Errorf("too big length=%d; remaining data size is %d bytes", size, len(src))
+	}
+	return src[:size], src[size:], nil
+}
+
+// readSint64s reads either packed or non-packed sint64 values from src and appends them to dst.
+func readSint64s(dst *[]int64, src []byte, wireType int) ([]byte, error) {
+	if wireType == 0 {
+		v, tail, err := readVarint(src, wireType)
+		if err != nil {
+			return src, err
+		}
+		*dst = append(*dst, decodeZigZag(v))
+		return tail, nil
+	}
+	data, tail, err := readBytes(src, wireType)
+	if err != nil {
+		return src, err
+	}
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return src, fmt.Errorf("cannot read packed varint")
+		}
+		data = data[n:]
+		*dst = append(*dst, decodeZigZag(v))
+	}
+	return tail, nil
+}
+
+// readDoubles reads either packed or non-packed double values from src and appends them to dst.
+func readDoubles(dst *[]float64, src []byte, wireType int) ([]byte, error) {
+	if wireType == 1 {
+		v, tail, err := readDouble(src, wireType)
+		if err != nil {
+			return src, err
+		}
+		*dst = append(*dst, v)
+		return tail, nil
+	}
+	data, tail, err := readBytes(src, wireType)
+	if err != nil {
+		return src, err
+	}
+	if len(data)%8 != 0 {
+		return src, fmt.Errorf("unexpected length of packed doubles: %d bytes", len(data))
+	}
+	for len(data) > 0 {
+		*dst = append(*dst, math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		data = data[8:]
+	}
+	return tail, nil
+}
+
+func decodeZigZag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
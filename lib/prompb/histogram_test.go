@@ -0,0 +1,212 @@
+package prompb
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBucketUpperBound(t *testing.T) {
+	f := func(index, schema int32, resultExpected float64) {
+		t.Helper()
+		result := bucketUpperBound(index, schema)
+		if math.Abs(result-resultExpected) > 1e-12*resultExpected {
+			t.Fatalf("unexpected upper bound for index=%d, schema=%d; got %v; want %v", index, schema, result, resultExpected)
+		}
+	}
+	f(0, 0, 1)
+	f(1, 0, 2)
+	f(-1, 0, 0.5)
+	f(3, 0, 8)
+	f(1, -1, 4)
+	f(-2, -2, 1.0/256)
+	f(1, 1, math.Sqrt2)
+	f(2, 1, 2)
+	f(-1, 1, 1/math.Sqrt2)
+	f(-3, 1, 1/(2*math.Sqrt2))
+	f(1, 3, math.Pow(2, 1.0/8))
+}
+
+func TestHistogramUnmarshal(t *testing.T) {
+	var data []byte
+	data = appendVarintField(data, 1, 7)                                  // count_int
+	data = appendDoubleField(data, 3, 12.5)                               // sum
+	data = appendVarintField(data, 4, encodeZigZag(1))                    // schema
+	data = appendDoubleField(data, 5, 0.001)                              // zero_threshold
+	data = appendVarintField(data, 6, 2)                                  // zero_count_int
+	data = appendBytesField(data, 11, marshalSpan(nil, -1, 2))            // positive_spans
+	data = appendBytesField(data, 11, marshalSpan(nil, 3, 1))             // positive_spans
+	data = appendBytesField(data, 12, appendPackedSint64s(nil, 2, -1, 1)) // positive_deltas
+	data = appendBytesField(data, 8, marshalSpan(nil, 0, 1))              // negative_spans
+	data = appendVarintField(data, 9, encodeZigZag(1))                    // non-packed negative_deltas
+	data = appendVarintField(data, 14, 1)                                 // reset_hint must be skipped
+	data = appendVarintField(data, 15, 1234)                              // timestamp
+
+	var h Histogram
+	if err := h.Unmarshal(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	hExpected := Histogram{
+		Count:          7,
+		Sum:            12.5,
+		Schema:         1,
+		ZeroThreshold:  0.001,
+		ZeroCount:      2,
+		NegativeSpans:  []BucketSpan{{Offset: 0, Length: 1}},
+		NegativeDeltas: []int64{1},
+		PositiveSpans:  []BucketSpan{{Offset: -1, Length: 2}, {Offset: 3, Length: 1}},
+		PositiveDeltas: []int64{2, -1, 1},
+		Timestamp:      1234,
+	}
+	if !reflect.DeepEqual(&h, &hExpected) {
+		t.Fatalf("unexpected histogram;\ngot\n%+v\nwant\n%+v", &h, &hExpected)
+	}
+
+	buckets, err := h.AppendVMRangeBuckets(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	bucketsExpected := []VMRangeBucket{
+		{Start: -1, End: -1 / math.Sqrt2, Count: 1},
+		{Start: -0.001, End: 0.001, Count: 2},
+		{Start: 0.5, End: 1 / math.Sqrt2, Count: 2},
+		{Start: 1 / math.Sqrt2, End: 1, Count: 1},
+		{Start: 2 * math.Sqrt2, End: 4, Count: 2},
+	}
+	if len(buckets) != len(bucketsExpected) {
+		t.Fatalf("unexpected buckets;\ngot\n%+v\nwant\n%+v", buckets, bucketsExpected)
+	}
+	for i := range buckets {
+		b, bExpected := buckets[i], bucketsExpected[i]
+		if !almostEqual(b.Start, bExpected.Start) || !almostEqual(b.End, bExpected.End) || b.Count != bExpected.Count {
+			t.Fatalf("unexpected bucket #%d; got %+v; want %+v", i, b, bExpected)
+		}
+	}
+
+	// Adjacent buckets must have identical bounds in vmrange.
+	if buckets[2].VMRange() != "0.5...0.7071067811865475" || buckets[3].VMRange() != "0.7071067811865475...1" {
+		t.Fatalf("unexpected vmrange values: %q, %q", buckets[2].VMRange(), buckets[3].VMRange())
+	}
+}
+
+func TestHistogramAppendVMRangeBucketsFailure(t *testing.T) {
+	f := func(h *Histogram) {
+		t.Helper()
+		if _, err := h.AppendVMRangeBuckets(nil); err == nil {
+			t.Fatalf("expecting non-nil error for %+v", h)
+		}
+	}
+	f(&Histogram{Schema: 9})
+	f(&Histogram{Schema: -5})
+	f(&Histogram{
+		PositiveSpans:  []BucketSpan{{Offset: 0, Length: 2}},
+		PositiveDeltas: []int64{1},
+	})
+	f(&Histogram{
+		NegativeSpans:  []BucketSpan{{Offset: 0, Length: 1}},
+		NegativeCounts: []float64{1, 2},
+	})
+}
+
+func TestHistogramUnmarshalFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		var h Histogram
+		if err := h.Unmarshal(data); err == nil {
+			t.Fatalf("expecting non-nil error for %x", data)
+		}
+	}
+	f([]byte{0x08})
+	f(appendVarintField(nil, 3, 1))
+	f([]byte{0x19, 0x01})
+	f([]byte{0x5a, 0x05, 0x01})
+	f(appendBytesField(nil, 13, []byte{1, 2, 3}))
+}
+
+func TestHistogramMarshalUnmarshalCompact(t *testing.T) {
+	f := func(h *Histogram) {
+		t.Helper()
+		data := h.MarshalCompact(nil)
+		h2 := Histogram{
+			Timestamp: h.Timestamp,
+		}
+		if err := h2.UnmarshalCompact(data); err != nil {
+			t.Fatalf("cannot unmarshal compact histogram: %s", err)
+		}
+		if !reflect.DeepEqual(&h2, h) {
+			t.Fatalf("unexpected histogram after unmarshaling;\ngot\n%+v\nwant\n%+v", &h2, h)
+		}
+
+		// Truncated data must result in error.
+		for i := 0; i < len(data); i++ {
+			if err := h2.UnmarshalCompact(data[:i]); err == nil {
+				t.Fatalf("expecting non-nil error when unmarshaling truncated data with length %d out of %d", i, len(data))
+			}
+		}
+	}
+	f(&Histogram{})
+	f(&Histogram{
+		Count:          7,
+		Sum:            12.5,
+		Schema:         -2,
+		ZeroThreshold:  0.001,
+		ZeroCount:      2,
+		NegativeSpans:  []BucketSpan{{Offset: 0, Length: 1}},
+		NegativeDeltas: []int64{1},
+		PositiveSpans:  []BucketSpan{{Offset: -1, Length: 2}, {Offset: 3, Length: 1}},
+		PositiveDeltas: []int64{2, -1, 1},
+		Timestamp:      1234,
+	})
+	f(&Histogram{
+		Count:          3.5,
+		Sum:            -1.25,
+		Schema:         8,
+		PositiveSpans:  []BucketSpan{{Offset: 100, Length: 2}},
+		PositiveCounts: []float64{1.5, 2},
+	})
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-12*math.Abs(b)
+}
+
+func marshalSpan(dst []byte, offset int32, length uint32) []byte {
+	dst = appendVarintField(dst, 1, encodeZigZag(int64(offset)))
+	return appendVarintField(dst, 2, uint64(length))
+}
+
+func appendPackedSint64s(dst []byte, vs ...int64) []byte {
+	for _, v := range vs {
+		dst = appendUvarint(dst, encodeZigZag(v))
+	}
+	return dst
+}
+
+func appendVarintField(dst []byte, fieldNum int, v uint64) []byte {
+	dst = appendUvarint(dst, uint64(fieldNum<<3))
+	return appendUvarint(dst, v)
+}
+
+func appendDoubleField(dst []byte, fieldNum int, v float64) []byte {
+	dst = appendUvarint(dst, uint64(fieldNum<<3|1))
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	return append(dst, b[:]...)
+}
+
+func appendBytesField(dst []byte, fieldNum int, data []byte) []byte {
+	dst = appendUvarint(dst, uint64(fieldNum<<3|2))
+	dst = appendUvarint(dst, uint64(len(data)))
+	return append(dst, data...)
+}
+
+func encodeZigZag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func appendUvarint(dst []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(dst, b[:n]...)
+}
@@ -0,0 +1,227 @@
+package prompb
+
+import (
+	"fmt"
+)
+
+// ReadRequest is Prometheus remote read request.
+//
+// See https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+type ReadRequest struct {
+	Queries []Query
+
+	// AcceptedResponseTypes contains response types accepted by the client in the order of preference.
+	//
+	// An empty list means ReadRequestSamples.
+	AcceptedResponseTypes []ReadRequestResponseType
+}
+
+// ReadRequestResponseType is the type of remote read response.
+type ReadRequestResponseType int32
+
+const (
+	// ReadRequestSamples means the response must contain ReadResponse with raw samples.
+	ReadRequestSamples = ReadRequestResponseType(0)
+
+	// ReadRequestStreamedXORChunks means the response must contain stream of ChunkedReadResponse messages with XOR-encoded chunks.
+	ReadRequestStreamedXORChunks = ReadRequestResponseType(1)
+)
+
+// Query is a single query in ReadRequest.
+type Query struct {
+	StartTimestampMs int64
+	EndTimestampMs   int64
+	Matchers         []LabelMatcher
+}
+
+// LabelMatcher is a label matcher in Query.
+type LabelMatcher struct {
+	Type  LabelMatcherType
+	Name  string
+	Value string
+}
+
+// LabelMatcherType is the type of LabelMatcher.
+type LabelMatcherType int32
+
+const (
+	// LabelMatcherEQ is `name="value"` matcher.
+	LabelMatcherEQ = LabelMatcherType(0)
+
+	// LabelMatcherNEQ is `name!="value"` matcher.
+	LabelMatcherNEQ = LabelMatcherType(1)
+
+	// LabelMatcherRE is `name=~"value"` matcher.
+	LabelMatcherRE = LabelMatcherType(2)
+
+	// LabelMatcherNRE is `name!~"value"` matcher.
+	LabelMatcherNRE = LabelMatcherType(3)
+)
+
+// Unmarshal unmarshals rr from src.
+func (rr *ReadRequest) Unmarshal(src []byte) error {
+	*rr = ReadRequest{}
+	for len(src) > 0 {
+		preSrc := src
+		fieldNum, wireType, tail, err := readTag(src)
+		if err != nil {
+			return fmt.Errorf("proto: ReadRequest: %w", err)
+		}
+		src = tail
+		switch fieldNum {
+		case 1:
+			// queries
+			data, tail, err := readBytes(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: ReadRequest: cannot read query: %w", err)
+			}
+			src = tail
+			var q Query
+			if err := q.Unmarshal(data); err != nil {
+				return err
+			}
+			rr.Queries = append(rr.Queries, q)
+		case 2:
+			// accepted_response_types
+			var types []int64
+			tail, err := readVarints(&types, src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: ReadRequest: cannot read accepted_response_types: %w", err)
+			}
+			src = tail
+			for _, t := range types {
+				rr.AcceptedResponseTypes = append(rr.AcceptedResponseTypes, ReadRequestResponseType(t))
+			}
+		default:
+			n, err := skipTypes(preSrc)
+			if err != nil {
+				return err
+			}
+			if n > len(preSrc) {
+				return fmt.Errorf("proto: ReadRequest: unexpected end of data")
+			}
+			src = preSrc[n:]
+		}
+	}
+	return nil
+}
+
+// Unmarshal unmarshals q from src.
+func (q *Query) Unmarshal(src []byte) error {
+	*q = Query{}
+	for len(src) > 0 {
+		preSrc := src
+		fieldNum, wireType, tail, err := readTag(src)
+		if err != nil {
+			return fmt.Errorf("proto: Query: %w", err)
+		}
+		src = tail
+		switch fieldNum {
+		case 1, 2:
+			// start_timestamp_ms, end_timestamp_ms
+			v, tail, err := readVarint(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Query: cannot read field %d: %w", fieldNum, err)
+			}
+			src = tail
+			if fieldNum == 1 {
+				q.StartTimestampMs = int64(v)
+			} else {
+				q.EndTimestampMs = int64(v)
+			}
+		case 3:
+			// matchers
+			data, tail, err := readBytes(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: Query: cannot read matcher: %w", err)
+			}
+			src = tail
+			var lm LabelMatcher
+			if err := lm.Unmarshal(data); err != nil {
+				return err
+			}
+			q.Matchers = append(q.Matchers, lm)
+		default:
+			// Skip unknown fields such as hints.
+			n, err := skipTypes(preSrc)
+			if err != nil {
+				return err
+			}
+			if n > len(preSrc) {
+				return fmt.Errorf("proto: Query: unexpected end of data")
+			}
+			src = preSrc[n:]
+		}
+	}
+	return nil
+}
+
+// Unmarshal unmarshals lm from src.
+func (lm *LabelMatcher) Unmarshal(src []byte) error {
+	*lm = LabelMatcher{}
+	for len(src) > 0 {
+		preSrc := src
+		fieldNum, wireType, tail, err := readTag(src)
+		if err != nil {
+			return fmt.Errorf("proto: LabelMatcher: %w", err)
+		}
+		src = tail
+		switch fieldNum {
+		case 1:
+			// type
+			v, tail, err := readVarint(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: LabelMatcher: cannot read type: %w", err)
+			}
+			src = tail
+			lm.Type = LabelMatcherType(v)
+		case 2, 3:
+			// name, value
+			data, tail, err := readBytes(src, wireType)
+			if err != nil {
+				return fmt.Errorf("proto: LabelMatcher: cannot read field %d: %w", fieldNum, err)
+			}
+			src = tail
+			if fieldNum == 2 {
+				lm.Name = string(data)
+			} else {
+				lm.Value = string(data)
+			}
+		default:
+			n, err := skipTypes(preSrc)
+			if err != nil {
+				return err
+			}
+			if n > len(preSrc) {
+				return fmt.Errorf("proto: LabelMatcher: unexpected end of data")
+			}
+			src = preSrc[n:]
+		}
+	}
+	return nil
+}
+
+// readVarints reads either packed or non-packed varint values from src and appends them to dst.
+func readVarints(dst *[]int64, src []byte, wireType int) ([]byte, error) {
+	if wireType == 0 {
+		v, tail, err := readVarint(src, wireType)
+		if err != nil {
+			return src, err
+		}
+		*dst = append(*dst, int64(v))
+		return tail, nil
+	}
+	data, tail, err := readBytes(src, wireType)
+	if err != nil {
+		return src, err
+	}
+	for len(data) > 0 {
+		v, rest, err := readVarint(data, 0)
+		if err != nil {
+			return src, fmt.Errorf("cannot read packed varint: %w", err)
+		}
+		data = rest
+		*dst = append(*dst, int64(v))
+	}
+	return tail, nil
+}
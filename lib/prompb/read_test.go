@@ -0,0 +1,88 @@
+package prompb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadRequestUnmarshal(t *testing.T) {
+	var lm1 []byte
+	lm1 = appendBytesField(lm1, 2, []byte("__name__"))
+	lm1 = appendBytesField(lm1, 3, []byte("up"))
+
+	var lm2 []byte
+	lm2 = appendVarintField(lm2, 1, uint64(LabelMatcherNRE))
+	lm2 = appendBytesField(lm2, 2, []byte("job"))
+	lm2 = appendBytesField(lm2, 3, []byte("foo|bar"))
+
+	var hints []byte
+	hints = appendVarintField(hints, 1, 15000)
+
+	var q []byte
+	q = appendVarintField(q, 1, 1000)
+	q = appendVarintField(q, 2, 2000)
+	q = appendBytesField(q, 3, lm1)
+	q = appendBytesField(q, 3, lm2)
+	q = appendBytesField(q, 4, hints)
+
+	var data []byte
+	data = appendBytesField(data, 1, q)
+	data = appendBytesField(data, 2, appendUvarint(appendUvarint(nil, 1), 0))
+
+	var rr ReadRequest
+	if err := rr.Unmarshal(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rrExpected := ReadRequest{
+		Queries: []Query{
+			{
+				StartTimestampMs: 1000,
+				EndTimestampMs:   2000,
+				Matchers: []LabelMatcher{
+					{
+						Type:  LabelMatcherEQ,
+						Name:  "__name__",
+						Value: "up",
+					},
+					{
+						Type:  LabelMatcherNRE,
+						Name:  "job",
+						Value: "foo|bar",
+					},
+				},
+			},
+		},
+		AcceptedResponseTypes: []ReadRequestResponseType{ReadRequestStreamedXORChunks, ReadRequestSamples},
+	}
+	if !reflect.DeepEqual(rr, rrExpected) {
+		t.Fatalf("unexpected ReadRequest;\ngot\n%#v\nwant\n%#v", rr, rrExpected)
+	}
+
+	// Non-packed accepted_response_types
+	data = appendBytesField(nil, 1, q)
+	data = appendVarintField(data, 2, 0)
+	if err := rr.Unmarshal(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(rr.AcceptedResponseTypes, []ReadRequestResponseType{ReadRequestSamples}) {
+		t.Fatalf("unexpected AcceptedResponseTypes: %v", rr.AcceptedResponseTypes)
+	}
+}
+
+func TestReadRequestUnmarshalFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		var rr ReadRequest
+		if err := rr.Unmarshal(data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	// Missing field data
+	f([]byte{0xa})
+	// Too big query length
+	f([]byte{0xa, 0x10, 0x1})
+	// Invalid query
+	f(appendBytesField(nil, 1, []byte{0x1a, 0x5}))
+	// Invalid wire type for start_timestamp_ms
+	f(appendBytesField(nil, 1, appendBytesField(nil, 1, []byte("foo"))))
+}
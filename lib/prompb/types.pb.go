@@ -17,8 +17,10 @@ type Sample struct {
 
 // TimeSeries is a timeseries.
 type TimeSeries struct {
-	Labels  []Label
-	Samples []Sample
+	Labels     []Label
+	Samples    []Sample
+	Exemplars  []Exemplar
+	Histograms []Histogram
 }
 
 // Label is a timeseries label
@@ -113,6 +115,8 @@ func (m *Sample) Unmarshal(dAtA []byte) error {
 func (m *TimeSeries) Unmarshal(dAtA []byte, dstLabels []Label, dstSamples []Sample) ([]Label, []Sample, error) {
 	labelsStart := len(dstLabels)
 	samplesStart := len(dstSamples)
+	m.Exemplars = m.Exemplars[:0]
+	m.Histograms = m.Histograms[:0]
 
 	l := len(dAtA)
 	iNdEx := 0
@@ -214,6 +218,74 @@ func (m *TimeSeries) Unmarshal(dAtA []byte, dstLabels []Label, dstSamples []Samp
 				return dstLabels, dstSamples, err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return dstLabels, dstSamples, fmt.Errorf("proto: wrong wireType = %d for field Exemplars", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return dstLabels, dstSamples, errIntOverflowTypes
+				}
+				if iNdEx >= l {
+					return dstLabels, dstSamples, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return dstLabels, dstSamples, errInvalidLengthTypes
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return dstLabels, dstSamples, io.ErrUnexpectedEOF
+			}
+			if cap(m.Exemplars) > len(m.Exemplars) {
+				m.Exemplars = m.Exemplars[:len(m.Exemplars)+1]
+			} else {
+				m.Exemplars = append(m.Exemplars, Exemplar{})
+			}
+			e := &m.Exemplars[len(m.Exemplars)-1]
+			if err := e.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return dstLabels, dstSamples, err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return dstLabels, dstSamples, fmt.Errorf("proto: wrong wireType = %d for field Histograms", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return dstLabels, dstSamples, errIntOverflowTypes
+				}
+				if iNdEx >= l {
+					return dstLabels, dstSamples, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return dstLabels, dstSamples, errInvalidLengthTypes
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return dstLabels, dstSamples, io.ErrUnexpectedEOF
+			}
+			m.Histograms = append(m.Histograms, Histogram{})
+			h := &m.Histograms[len(m.Histograms)-1]
+			if err := h.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return dstLabels, dstSamples, err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTypes(dAtA[iNdEx:])
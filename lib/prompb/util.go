@@ -6,6 +6,8 @@ func (wr *WriteRequest) Reset() {
 		ts := &wr.Timeseries[i]
 		ts.Labels = nil
 		ts.Samples = nil
+		ts.Exemplars = nil
+		ts.Histograms = nil
 	}
 	wr.Timeseries = wr.Timeseries[:0]
 
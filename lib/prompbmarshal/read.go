@@ -0,0 +1,224 @@
+package prompbmarshal
+
+// ReadResponse is Prometheus remote read response with SAMPLES response type.
+//
+// See https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+type ReadResponse struct {
+	// Results contains results for queries from ReadRequest in the same order.
+	Results []QueryResult
+}
+
+// QueryResult is the result for a single query from ReadRequest.
+type QueryResult struct {
+	Timeseries []TimeSeries
+}
+
+// MarshalToSizedBuffer marshals rr to the end of dAtA and returns the number of bytes written.
+//
+// dAtA must have at least rr.Size() bytes.
+func (rr *ReadResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	for iNdEx := len(rr.Results) - 1; iNdEx >= 0; iNdEx-- {
+		size, err := rr.Results[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintRemote(dAtA, i, uint64(size))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+// Size returns the size of marshaled rr.
+func (rr *ReadResponse) Size() (n int) {
+	for _, e := range rr.Results {
+		l := e.Size()
+		n += 1 + l + sovRemote(uint64(l))
+	}
+	return n
+}
+
+// MarshalToSizedBuffer marshals qr to the end of dAtA and returns the number of bytes written.
+//
+// dAtA must have at least qr.Size() bytes.
+func (qr *QueryResult) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	for iNdEx := len(qr.Timeseries) - 1; iNdEx >= 0; iNdEx-- {
+		size, err := qr.Timeseries[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintRemote(dAtA, i, uint64(size))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+// Size returns the size of marshaled qr.
+func (qr *QueryResult) Size() (n int) {
+	for _, e := range qr.Timeseries {
+		l := e.Size()
+		n += 1 + l + sovRemote(uint64(l))
+	}
+	return n
+}
+
+// ChunkedReadResponse is a single message in Prometheus remote read response with STREAMED_XOR_CHUNKS response type.
+//
+// See https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+type ChunkedReadResponse struct {
+	ChunkedSeries []ChunkedSeries
+
+	// QueryIndex is the index of the query from ReadRequest the ChunkedSeries belong to.
+	QueryIndex int64
+}
+
+// ChunkedSeries is a time series with samples encoded into chunks.
+type ChunkedSeries struct {
+	// Labels must be sorted by name.
+	Labels []Label
+
+	// Chunks must be sorted by time and mustn't overlap.
+	Chunks []Chunk
+}
+
+// Chunk is a chunk of samples for a time series.
+type Chunk struct {
+	MinTimeMs int64
+	MaxTimeMs int64
+	Type      ChunkEncoding
+	Data      []byte
+}
+
+// ChunkEncoding is the encoding of Chunk data.
+type ChunkEncoding int32
+
+// ChunkEncodingXOR is XOR encoding used by Prometheus TSDB.
+const ChunkEncodingXOR = ChunkEncoding(1)
+
+// MarshalToSizedBuffer marshals crr to the end of dAtA and returns the number of bytes written.
+//
+// dAtA must have at least crr.Size() bytes.
+func (crr *ChunkedReadResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	if crr.QueryIndex != 0 {
+		i = encodeVarintRemote(dAtA, i, uint64(crr.QueryIndex))
+		i--
+		dAtA[i] = 0x10
+	}
+	for iNdEx := len(crr.ChunkedSeries) - 1; iNdEx >= 0; iNdEx-- {
+		size, err := crr.ChunkedSeries[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintRemote(dAtA, i, uint64(size))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+// Size returns the size of marshaled crr.
+func (crr *ChunkedReadResponse) Size() (n int) {
+	for _, e := range crr.ChunkedSeries {
+		l := e.Size()
+		n += 1 + l + sovRemote(uint64(l))
+	}
+	if crr.QueryIndex != 0 {
+		n += 1 + sovRemote(uint64(crr.QueryIndex))
+	}
+	return n
+}
+
+// MarshalToSizedBuffer marshals cs to the end of dAtA and returns the number of bytes written.
+//
+// dAtA must have at least cs.Size() bytes.
+func (cs *ChunkedSeries) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	for iNdEx := len(cs.Chunks) - 1; iNdEx >= 0; iNdEx-- {
+		size, err := cs.Chunks[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintRemote(dAtA, i, uint64(size))
+		i--
+		dAtA[i] = 0x12
+	}
+	for iNdEx := len(cs.Labels) - 1; iNdEx >= 0; iNdEx-- {
+		size, err := cs.Labels[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintRemote(dAtA, i, uint64(size))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+// Size returns the size of marshaled cs.
+func (cs *ChunkedSeries) Size() (n int) {
+	for _, e := range cs.Labels {
+		l := e.Size()
+		n += 1 + l + sovRemote(uint64(l))
+	}
+	for _, e := range cs.Chunks {
+		l := e.Size()
+		n += 1 + l + sovRemote(uint64(l))
+	}
+	return n
+}
+
+// MarshalToSizedBuffer marshals c to the end of dAtA and returns the number of bytes written.
+//
+// dAtA must have at least c.Size() bytes.
+func (c *Chunk) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	if len(c.Data) > 0 {
+		i -= len(c.Data)
+		copy(dAtA[i:], c.Data)
+		i = encodeVarintRemote(dAtA, i, uint64(len(c.Data)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if c.Type != 0 {
+		i = encodeVarintRemote(dAtA, i, uint64(c.Type))
+		i--
+		dAtA[i] = 0x18
+	}
+	if c.MaxTimeMs != 0 {
+		i = encodeVarintRemote(dAtA, i, uint64(c.MaxTimeMs))
+		i--
+		dAtA[i] = 0x10
+	}
+	if c.MinTimeMs != 0 {
+		i = encodeVarintRemote(dAtA, i, uint64(c.MinTimeMs))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+// Size returns the size of marshaled c.
+func (c *Chunk) Size() (n int) {
+	if c.MinTimeMs != 0 {
+		n += 1 + sovRemote(uint64(c.MinTimeMs))
+	}
+	if c.MaxTimeMs != 0 {
+		n += 1 + sovRemote(uint64(c.MaxTimeMs))
+	}
+	if c.Type != 0 {
+		n += 1 + sovRemote(uint64(c.Type))
+	}
+	if l := len(c.Data); l > 0 {
+		n += 1 + l + sovRemote(uint64(l))
+	}
+	return n
+}
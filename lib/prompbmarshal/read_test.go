@@ -0,0 +1,73 @@
+package prompbmarshal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestMarshalReadResponse(t *testing.T) {
+	ts := TimeSeries{
+		Labels: []Label{
+			{
+				Name:  "__name__",
+				Value: "up",
+			},
+			{
+				Name:  "job",
+				Value: "foo",
+			},
+		},
+		Samples: []Sample{
+			{
+				Value:     1,
+				Timestamp: 1000,
+			},
+			{
+				Value:     2,
+				Timestamp: 2000,
+			},
+		},
+	}
+	tsData, err := ts.Marshal()
+	if err != nil {
+		t.Fatalf("cannot marshal time series: %s", err)
+	}
+	rr := &ReadResponse{
+		Results: []QueryResult{
+			{
+				Timeseries: []TimeSeries{ts, ts},
+			},
+			{},
+		},
+	}
+	prefix := []byte("prefix")
+	data := MarshalReadResponse(append([]byte{}, prefix...), rr)
+	if !bytes.HasPrefix(data, prefix) {
+		t.Fatalf("missing prefix in the marshaled data")
+	}
+	data = data[len(prefix):]
+	if len(data) != rr.Size() {
+		t.Fatalf("unexpected marshaled size; got %d; want %d", len(data), rr.Size())
+	}
+
+	// Build the expected data by hand.
+	var qr []byte
+	qr = appendBytesField(qr, tsData)
+	qr = appendBytesField(qr, tsData)
+	var dataExpected []byte
+	dataExpected = appendBytesField(dataExpected, qr)
+	dataExpected = appendBytesField(dataExpected, nil)
+	if !bytes.Equal(data, dataExpected) {
+		t.Fatalf("unexpected marshaled data;\ngot\n%X\nwant\n%X", data, dataExpected)
+	}
+}
+
+// appendBytesField appends bytes field with number 1 to dst.
+func appendBytesField(dst, data []byte) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], uint64(len(data)))
+	dst = append(dst, 0xa)
+	dst = append(dst, b[:n]...)
+	return append(dst, data...)
+}
@@ -19,6 +19,36 @@ func MarshalWriteRequest(dst []byte, wr *WriteRequest) []byte {
 	return dst[:dstLen+n]
 }
 
+// MarshalReadResponse marshals rr to dst and returns the result.
+func MarshalReadResponse(dst []byte, rr *ReadResponse) []byte {
+	size := rr.Size()
+	dstLen := len(dst)
+	if n := size - (cap(dst) - dstLen); n > 0 {
+		dst = append(dst[:cap(dst)], make([]byte, n)...)
+	}
+	dst = dst[:dstLen+size]
+	n, err := rr.MarshalToSizedBuffer(dst[dstLen:])
+	if err != nil {
+		panic(fmt.Errorf("BUG: unexpected error when marshaling ReadResponse: %w", err))
+	}
+	return dst[:dstLen+n]
+}
+
+// MarshalChunkedReadResponse marshals crr to dst and returns the result.
+func MarshalChunkedReadResponse(dst []byte, crr *ChunkedReadResponse) []byte {
+	size := crr.Size()
+	dstLen := len(dst)
+	if n := size - (cap(dst) - dstLen); n > 0 {
+		dst = append(dst[:cap(dst)], make([]byte, n)...)
+	}
+	dst = dst[:dstLen+size]
+	n, err := crr.MarshalToSizedBuffer(dst[dstLen:])
+	if err != nil {
+		panic(fmt.Errorf("BUG: unexpected error when marshaling ChunkedReadResponse: %w", err))
+	}
+	return dst[:dstLen+n]
+}
+
 // ResetWriteRequest resets wr.
 func ResetWriteRequest(wr *WriteRequest) {
 	wr.Timeseries = ResetTimeSeries(wr.Timeseries)
@@ -30,6 +60,7 @@ func ResetTimeSeries(tss []TimeSeries) []TimeSeries {
 		ts := tss[i]
 		ts.Labels = nil
 		ts.Samples = nil
+		ts.Exemplars = nil
 	}
 	return tss[:0]
 }
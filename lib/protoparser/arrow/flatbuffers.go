@@ -0,0 +1,188 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// fbTable is a minimal read-only accessor for flatbuffers tables.
+//
+// Arrow IPC metadata is encoded with flatbuffers. See https://google.github.io/flatbuffers/flatbuffers_internals.html
+// Only the subset needed for reading Arrow schemas and record batches is implemented.
+// All the accesses are bounds-checked, so malformed input results in errors instead of panics.
+type fbTable struct {
+	buf []byte
+
+	// pos is the table position in buf.
+	pos int
+
+	// vt is the vtable position in buf.
+	vt int
+
+	// vtLen is the vtable length in bytes.
+	vtLen int
+}
+
+var errInvalidFlatbuffer = fmt.Errorf("invalid flatbuffer")
+
+// getRootTable returns the root table from buf.
+func getRootTable(buf []byte) (fbTable, error) {
+	if len(buf) < 4 {
+		return fbTable{}, errInvalidFlatbuffer
+	}
+	return getTable(buf, int(binary.LittleEndian.Uint32(buf)))
+}
+
+func getTable(buf []byte, pos int) (fbTable, error) {
+	if pos < 0 || pos+4 > len(buf) {
+		return fbTable{}, errInvalidFlatbuffer
+	}
+	vt := pos - int(int32(binary.LittleEndian.Uint32(buf[pos:])))
+	if vt < 0 || vt+4 > len(buf) {
+		return fbTable{}, errInvalidFlatbuffer
+	}
+	vtLen := int(binary.LittleEndian.Uint16(buf[vt:]))
+	if vtLen < 4 || vt+vtLen > len(buf) {
+		return fbTable{}, errInvalidFlatbuffer
+	}
+	return fbTable{
+		buf:   buf,
+		pos:   pos,
+		vt:    vt,
+		vtLen: vtLen,
+	}, nil
+}
+
+// fieldPos returns the position in t.buf for the field with the given id and the given size.
+//
+// -1 is returned if the field is missing.
+func (t *fbTable) fieldPos(id, size int) (int, error) {
+	n := 4 + 2*id
+	if n+2 > t.vtLen {
+		return -1, nil
+	}
+	offset := int(binary.LittleEndian.Uint16(t.buf[t.vt+n:]))
+	if offset == 0 {
+		return -1, nil
+	}
+	p := t.pos + offset
+	if p+size > len(t.buf) {
+		return 0, errInvalidFlatbuffer
+	}
+	return p, nil
+}
+
+func (t *fbTable) getUint8(id int, defaultValue uint8) (uint8, error) {
+	p, err := t.fieldPos(id, 1)
+	if err != nil || p < 0 {
+		return defaultValue, err
+	}
+	return t.buf[p], nil
+}
+
+func (t *fbTable) getInt16(id int, defaultValue int16) (int16, error) {
+	p, err := t.fieldPos(id, 2)
+	if err != nil || p < 0 {
+		return defaultValue, err
+	}
+	return int16(binary.LittleEndian.Uint16(t.buf[p:])), nil
+}
+
+func (t *fbTable) getInt32(id int, defaultValue int32) (int32, error) {
+	p, err := t.fieldPos(id, 4)
+	if err != nil || p < 0 {
+		return defaultValue, err
+	}
+	return int32(binary.LittleEndian.Uint32(t.buf[p:])), nil
+}
+
+func (t *fbTable) getInt64(id int, defaultValue int64) (int64, error) {
+	p, err := t.fieldPos(id, 8)
+	if err != nil || p < 0 {
+		return defaultValue, err
+	}
+	return int64(binary.LittleEndian.Uint64(t.buf[p:])), nil
+}
+
+// getOffsetTarget returns the position referred by uoffset field with the given id.
+//
+// -1 is returned if the field is missing.
+func (t *fbTable) getOffsetTarget(id int) (int, error) {
+	p, err := t.fieldPos(id, 4)
+	if err != nil || p < 0 {
+		return p, err
+	}
+	return indirect(t.buf, p)
+}
+
+func indirect(buf []byte, p int) (int, error) {
+	target := p + int(binary.LittleEndian.Uint32(buf[p:]))
+	if target >= len(buf) {
+		return 0, errInvalidFlatbuffer
+	}
+	return target, nil
+}
+
+// getTable returns sub-table for the field with the given id.
+//
+// false is returned if the field is missing.
+func (t *fbTable) getTable(id int) (fbTable, bool, error) {
+	p, err := t.getOffsetTarget(id)
+	if err != nil || p < 0 {
+		return fbTable{}, false, err
+	}
+	st, err := getTable(t.buf, p)
+	if err != nil {
+		return fbTable{}, false, err
+	}
+	return st, true, nil
+}
+
+// getVector returns the start position and the number of items for the vector field with the given id.
+//
+// Every vector item must have itemSize bytes.
+func (t *fbTable) getVector(id, itemSize int) (int, int, error) {
+	p, err := t.getOffsetTarget(id)
+	if err != nil || p < 0 {
+		return 0, 0, err
+	}
+	if p+4 > len(t.buf) {
+		return 0, 0, errInvalidFlatbuffer
+	}
+	n := int(binary.LittleEndian.Uint32(t.buf[p:]))
+	start := p + 4
+	if n > (len(t.buf)-start)/itemSize {
+		return 0, 0, errInvalidFlatbuffer
+	}
+	return start, n, nil
+}
+
+// getString returns string for the field with the given id.
+func (t *fbTable) getString(id int) (string, error) {
+	start, n, err := t.getVector(id, 1)
+	if err != nil {
+		return "", err
+	}
+	return string(t.buf[start : start+n]), nil
+}
+
+// getTables returns tables from the vector field with the given id.
+func (t *fbTable) getTables(id int) ([]fbTable, error) {
+	start, n, err := t.getVector(id, 4)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]fbTable, n)
+	for i := range tables {
+		p, err := indirect(t.buf, start+4*i)
+		if err != nil {
+			return nil, err
+		}
+		st, err := getTable(t.buf, p)
+		if err != nil {
+			return nil, err
+		}
+		tables[i] = st
+	}
+	return tables, nil
+}
@@ -0,0 +1,534 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+)
+
+// Row is a single sample from Arrow record batch.
+type Row struct {
+	Metric    string
+	Tags      []Tag
+	Value     float64
+	Timestamp int64
+}
+
+// Tag is a label for Row.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// ColumnNames contains names for special columns in Arrow record batches.
+type ColumnNames struct {
+	// Metric is the name for metric name column.
+	Metric string
+
+	// Timestamp is the name for timestamp column.
+	Timestamp string
+
+	// Value is the name for value column.
+	Value string
+}
+
+// GetColumnNames returns special column names from `metric_column`, `timestamp_column` and `value_column` query args at req.
+//
+// Default names are used for missing query args.
+func GetColumnNames(req *http.Request) *ColumnNames {
+	q := req.URL.Query()
+	cns := &ColumnNames{
+		Metric:    q.Get("metric_column"),
+		Timestamp: q.Get("timestamp_column"),
+		Value:     q.Get("value_column"),
+	}
+	if cns.Metric == "" {
+		cns.Metric = "__name__"
+	}
+	if cns.Timestamp == "" {
+		cns.Timestamp = "timestamp"
+	}
+	if cns.Value == "" {
+		cns.Value = "value"
+	}
+	return cns
+}
+
+// Arrow IPC message header types. See https://github.com/apache/arrow/blob/master/format/Message.fbs
+const (
+	messageHeaderSchema          = 1
+	messageHeaderDictionaryBatch = 2
+	messageHeaderRecordBatch     = 3
+)
+
+// Arrow data types. See https://github.com/apache/arrow/blob/master/format/Schema.fbs
+const (
+	typeInt           = 2
+	typeFloatingPoint = 3
+	typeUtf8          = 5
+	typeTimestamp     = 10
+	typeLargeUtf8     = 20
+)
+
+type columnKind int
+
+const (
+	columnInt columnKind = iota
+	columnFloat
+	columnUtf8
+	columnLargeUtf8
+)
+
+type columnRole int
+
+const (
+	roleTag columnRole = iota
+	roleMetric
+	roleTimestamp
+	roleValue
+)
+
+// column describes a single column from Arrow schema.
+type column struct {
+	name string
+	kind columnKind
+	role columnRole
+
+	// byteWidth is the width in bytes for columnInt and columnFloat values.
+	byteWidth int
+
+	// isSigned is set to true for signed columnInt values.
+	isSigned bool
+
+	// timestampMultiplier and timestampDivisor are used for converting timestamp values to milliseconds.
+	timestampMultiplier int64
+	timestampDivisor    int64
+}
+
+// schema contains columns from Arrow schema message.
+type schema struct {
+	columns []column
+}
+
+func parseSchema(header fbTable, cns *ColumnNames) (*schema, error) {
+	endianness, err := header.getInt16(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if endianness != 0 {
+		return nil, fmt.Errorf("big-endian data isn't supported")
+	}
+	fields, err := header.getTables(1)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read schema fields: %w", err)
+	}
+	var sch schema
+	hasMetric := false
+	hasValue := false
+	for i := range fields {
+		c, err := parseColumn(&fields[i], cns)
+		if err != nil {
+			return nil, err
+		}
+		switch c.role {
+		case roleMetric:
+			hasMetric = true
+		case roleValue:
+			hasValue = true
+		}
+		sch.columns = append(sch.columns, *c)
+	}
+	if !hasMetric {
+		return nil, fmt.Errorf("missing metric name column %q", cns.Metric)
+	}
+	if !hasValue {
+		return nil, fmt.Errorf("missing value column %q", cns.Value)
+	}
+	return &sch, nil
+}
+
+func parseColumn(field *fbTable, cns *ColumnNames) (*column, error) {
+	name, err := field.getString(0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read field name: %w", err)
+	}
+	c := &column{
+		name: name,
+	}
+	switch name {
+	case cns.Metric:
+		c.role = roleMetric
+	case cns.Timestamp:
+		c.role = roleTimestamp
+	case cns.Value:
+		c.role = roleValue
+	default:
+		c.role = roleTag
+	}
+	if _, ok, err := field.getTable(4); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("dictionary-encoded column %q isn't supported", name)
+	}
+	if _, n, err := field.getVector(5, 4); err != nil || n > 0 {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("nested column %q isn't supported", name)
+	}
+	typeType, err := field.getUint8(2, 0)
+	if err != nil {
+		return nil, err
+	}
+	typ, _, err := field.getTable(3)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read type for column %q: %w", name, err)
+	}
+	switch typeType {
+	case typeInt:
+		bitWidth, err := typ.getInt32(0, 0)
+		if err != nil {
+			return nil, err
+		}
+		if bitWidth != 8 && bitWidth != 16 && bitWidth != 32 && bitWidth != 64 {
+			return nil, fmt.Errorf("unsupported bit width %d for int column %q", bitWidth, name)
+		}
+		isSigned, err := typ.getUint8(1, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.kind = columnInt
+		c.byteWidth = int(bitWidth / 8)
+		c.isSigned = isSigned != 0
+		// Integer timestamps are treated as milliseconds.
+		c.timestampMultiplier = 1
+		c.timestampDivisor = 1
+	case typeTimestamp:
+		unit, err := typ.getInt16(0, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.kind = columnInt
+		c.byteWidth = 8
+		c.isSigned = true
+		switch unit {
+		case 0:
+			c.timestampMultiplier, c.timestampDivisor = 1e3, 1
+		case 1:
+			c.timestampMultiplier, c.timestampDivisor = 1, 1
+		case 2:
+			c.timestampMultiplier, c.timestampDivisor = 1, 1e3
+		case 3:
+			c.timestampMultiplier, c.timestampDivisor = 1, 1e6
+		default:
+			return nil, fmt.Errorf("unsupported timestamp unit %d for column %q", unit, name)
+		}
+	case typeFloatingPoint:
+		precision, err := typ.getInt16(0, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.kind = columnFloat
+		switch precision {
+		case 1:
+			c.byteWidth = 4
+		case 2:
+			c.byteWidth = 8
+		default:
+			return nil, fmt.Errorf("unsupported floating point precision %d for column %q; only single and double precision is supported", precision, name)
+		}
+	case typeUtf8:
+		c.kind = columnUtf8
+	case typeLargeUtf8:
+		c.kind = columnLargeUtf8
+	default:
+		return nil, fmt.Errorf("unsupported type %d for column %q; supported types: Int, FloatingPoint, Timestamp, Utf8, LargeUtf8", typeType, name)
+	}
+	switch c.role {
+	case roleMetric, roleTag:
+		if c.kind != columnUtf8 && c.kind != columnLargeUtf8 {
+			return nil, fmt.Errorf("column %q must have Utf8 or LargeUtf8 type", name)
+		}
+	case roleTimestamp:
+		if c.kind != columnInt {
+			return nil, fmt.Errorf("timestamp column %q must have Int or Timestamp type", name)
+		}
+	case roleValue:
+		if c.kind != columnInt && c.kind != columnFloat {
+			return nil, fmt.Errorf("value column %q must have Int or FloatingPoint type", name)
+		}
+	}
+	return c, nil
+}
+
+// columnData contains buffers for a single column from Arrow record batch.
+type columnData struct {
+	c *column
+
+	// validity is the validity bitmap. It is empty if all the values are valid.
+	validity []byte
+
+	// offsets contains offsets for columnUtf8 and columnLargeUtf8 values.
+	offsets []byte
+
+	// values contains values.
+	values []byte
+}
+
+func (cd *columnData) isValid(i int) bool {
+	if len(cd.validity) == 0 {
+		return true
+	}
+	return cd.validity[i>>3]&(1<<(uint(i)&7)) != 0
+}
+
+func (cd *columnData) getInt(i int) int64 {
+	b := cd.values[i*cd.c.byteWidth:]
+	switch cd.c.byteWidth {
+	case 1:
+		if cd.c.isSigned {
+			return int64(int8(b[0]))
+		}
+		return int64(b[0])
+	case 2:
+		v := binary.LittleEndian.Uint16(b)
+		if cd.c.isSigned {
+			return int64(int16(v))
+		}
+		return int64(v)
+	case 4:
+		v := binary.LittleEndian.Uint32(b)
+		if cd.c.isSigned {
+			return int64(int32(v))
+		}
+		return int64(v)
+	default:
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+}
+
+func (cd *columnData) getFloat(i int) float64 {
+	switch cd.c.kind {
+	case columnInt:
+		if cd.c.byteWidth == 8 && !cd.c.isSigned {
+			return float64(binary.LittleEndian.Uint64(cd.values[i*8:]))
+		}
+		return float64(cd.getInt(i))
+	default:
+		if cd.c.byteWidth == 4 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(cd.values[i*4:])))
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(cd.values[i*8:]))
+	}
+}
+
+func (cd *columnData) getTimestamp(i int) int64 {
+	return cd.getInt(i) * cd.c.timestampMultiplier / cd.c.timestampDivisor
+}
+
+func (cd *columnData) getString(i int) string {
+	var start, end int
+	if cd.c.kind == columnUtf8 {
+		start = int(int32(binary.LittleEndian.Uint32(cd.offsets[i*4:])))
+		end = int(int32(binary.LittleEndian.Uint32(cd.offsets[(i+1)*4:])))
+	} else {
+		start = int(int64(binary.LittleEndian.Uint64(cd.offsets[i*8:])))
+		end = int(int64(binary.LittleEndian.Uint64(cd.offsets[(i+1)*8:])))
+	}
+	return bytesutil.ToUnsafeString(cd.values[start:end])
+}
+
+// validate verifies that cd contains enough data for rowsCount rows.
+func (cd *columnData) validate(rowsCount int) error {
+	if len(cd.validity) > 0 && len(cd.validity) < (rowsCount+7)/8 {
+		return fmt.Errorf("too short validity bitmap for column %q; got %d bytes; want at least %d bytes", cd.c.name, len(cd.validity), (rowsCount+7)/8)
+	}
+	switch cd.c.kind {
+	case columnInt, columnFloat:
+		if len(cd.values) < rowsCount*cd.c.byteWidth {
+			return fmt.Errorf("too short values buffer for column %q; got %d bytes; want at least %d bytes", cd.c.name, len(cd.values), rowsCount*cd.c.byteWidth)
+		}
+		return nil
+	}
+	offsetSize := 4
+	if cd.c.kind == columnLargeUtf8 {
+		offsetSize = 8
+	}
+	if rowsCount == 0 {
+		return nil
+	}
+	if len(cd.offsets) < (rowsCount+1)*offsetSize {
+		return fmt.Errorf("too short offsets buffer for column %q; got %d bytes; want at least %d bytes", cd.c.name, len(cd.offsets), (rowsCount+1)*offsetSize)
+	}
+	prevOffset := int64(0)
+	for i := 0; i <= rowsCount; i++ {
+		var offset int64
+		if offsetSize == 4 {
+			offset = int64(int32(binary.LittleEndian.Uint32(cd.offsets[i*4:])))
+		} else {
+			offset = int64(binary.LittleEndian.Uint64(cd.offsets[i*8:]))
+		}
+		if offset < prevOffset || offset > int64(len(cd.values)) {
+			return fmt.Errorf("invalid offset #%d for column %q: %d", i, cd.c.name, offset)
+		}
+		prevOffset = offset
+	}
+	return nil
+}
+
+// recordBatch contains columns data for Arrow record batch.
+type recordBatch struct {
+	rowsCount int
+	columns   []columnData
+}
+
+func (rb *recordBatch) reset() {
+	rb.rowsCount = 0
+	for i := range rb.columns {
+		rb.columns[i] = columnData{}
+	}
+	rb.columns = rb.columns[:0]
+}
+
+// fieldNodeSize and bufferSize are the sizes of FieldNode and Buffer structs from Message.fbs
+const (
+	fieldNodeSize = 16
+	bufferSize    = 16
+)
+
+// parseRecordBatch parses record batch with the given header and body according to sch.
+//
+// rb refers to body, so it cannot be used after body is changed.
+func (rb *recordBatch) parse(header fbTable, body []byte, sch *schema) error {
+	rowsCount, err := header.getInt64(0, 0)
+	if err != nil {
+		return err
+	}
+	if rowsCount < 0 || rowsCount > math.MaxInt32 {
+		return fmt.Errorf("invalid number of rows in record batch: %d", rowsCount)
+	}
+	if _, ok, err := header.getTable(3); err != nil || ok {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("compressed record batches aren't supported")
+	}
+	nodesStart, nodesCount, err := header.getVector(1, fieldNodeSize)
+	if err != nil {
+		return fmt.Errorf("cannot read field nodes: %w", err)
+	}
+	if nodesCount != len(sch.columns) {
+		return fmt.Errorf("unexpected number of field nodes in record batch; got %d; want %d", nodesCount, len(sch.columns))
+	}
+	buffersStart, buffersCount, err := header.getVector(2, bufferSize)
+	if err != nil {
+		return fmt.Errorf("cannot read buffers: %w", err)
+	}
+	getBuffer := func(idx int) ([]byte, error) {
+		if idx >= buffersCount {
+			return nil, fmt.Errorf("too small number of buffers in record batch: %d", buffersCount)
+		}
+		b := header.buf[buffersStart+idx*bufferSize:]
+		offset := int64(binary.LittleEndian.Uint64(b))
+		length := int64(binary.LittleEndian.Uint64(b[8:]))
+		if offset < 0 || length < 0 || offset > int64(len(body)) || length > int64(len(body))-offset {
+			return nil, fmt.Errorf("buffer #%d with offset=%d and length=%d is out of body with length %d", idx, offset, length, len(body))
+		}
+		return body[offset : offset+length], nil
+	}
+	rb.reset()
+	rb.rowsCount = int(rowsCount)
+	bufIdx := 0
+	for i := range sch.columns {
+		c := &sch.columns[i]
+		node := header.buf[nodesStart+i*fieldNodeSize:]
+		length := int64(binary.LittleEndian.Uint64(node))
+		nullCount := int64(binary.LittleEndian.Uint64(node[8:]))
+		if length != rowsCount {
+			return fmt.Errorf("unexpected number of values in column %q; got %d; want %d", c.name, length, rowsCount)
+		}
+		var cd columnData
+		cd.c = c
+		validity, err := getBuffer(bufIdx)
+		if err != nil {
+			return err
+		}
+		bufIdx++
+		if nullCount > 0 {
+			cd.validity = validity
+		}
+		if c.kind == columnUtf8 || c.kind == columnLargeUtf8 {
+			if cd.offsets, err = getBuffer(bufIdx); err != nil {
+				return err
+			}
+			bufIdx++
+		}
+		if cd.values, err = getBuffer(bufIdx); err != nil {
+			return err
+		}
+		bufIdx++
+		if nullCount > 0 && len(cd.validity) == 0 {
+			return fmt.Errorf("missing validity bitmap for column %q with %d nulls", c.name, nullCount)
+		}
+		if err := cd.validate(rb.rowsCount); err != nil {
+			return err
+		}
+		rb.columns = append(rb.columns, cd)
+	}
+	return nil
+}
+
+// appendRows appends rows in the range [start...end) from rb to dst and returns the result.
+//
+// tagsPool is used for storing row tags. The updated tagsPool is returned.
+// currentTimestamp is used for rows without timestamps.
+// The number of skipped rows with missing values is returned additionally.
+func (rb *recordBatch) appendRows(dst []Row, tagsPool []Tag, start, end int, currentTimestamp int64) ([]Row, []Tag, int, error) {
+	skippedRows := 0
+	for i := start; i < end; i++ {
+		var r Row
+		r.Timestamp = currentTimestamp
+		hasValue := false
+		tagsStart := len(tagsPool)
+		for j := range rb.columns {
+			cd := &rb.columns[j]
+			if !cd.isValid(i) {
+				continue
+			}
+			switch cd.c.role {
+			case roleMetric:
+				r.Metric = cd.getString(i)
+			case roleTimestamp:
+				r.Timestamp = cd.getTimestamp(i)
+			case roleValue:
+				r.Value = cd.getFloat(i)
+				hasValue = true
+			default:
+				value := cd.getString(i)
+				if len(value) == 0 {
+					// Skip labels without values, since they have no sense.
+					continue
+				}
+				tagsPool = append(tagsPool, Tag{
+					Key:   cd.c.name,
+					Value: value,
+				})
+			}
+		}
+		if !hasValue {
+			tagsPool = tagsPool[:tagsStart]
+			skippedRows++
+			continue
+		}
+		if len(r.Metric) == 0 {
+			return dst, tagsPool, skippedRows, fmt.Errorf("missing metric name at row #%d", i)
+		}
+		r.Tags = tagsPool[tagsStart:]
+		dst = append(dst, r)
+	}
+	return dst, tagsPool, skippedRows, nil
+}
@@ -0,0 +1,255 @@
+package arrow
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var maxMessageSize = flagutil.NewBytes("arrow.maxMessageSize", 64*1024*1024, "The maximum size in bytes of a single Apache Arrow IPC message "+
+	"accepted by /api/v1/import/arrow . Every record batch is sent in a separate message")
+
+// maxRowsPerCallback is the maximum number of rows passed to callback in a single call.
+//
+// Big record batches are split into smaller chunks in order to reduce memory usage.
+const maxRowsPerCallback = 10000
+
+// arrowFileMagic is the magic string at the beginning of Arrow IPC file format.
+const arrowFileMagic = "ARROW1"
+
+// continuationMarker starts every message in Arrow IPC stream format since Arrow 0.15.
+const continuationMarker = 0xFFFFFFFF
+
+// ParseStream parses Apache Arrow IPC data from r and calls callback for the parsed rows.
+//
+// Both Arrow IPC streaming format and Arrow IPC file format are supported.
+// See https://arrow.apache.org/docs/format/Columnar.html#serialization-and-interprocess-communication-ipc
+//
+// cns contains names for special columns. All the other columns are treated as labels.
+//
+// callback shouldn't hold rows after returning.
+func ParseStream(r io.Reader, isGzipped bool, cns *ColumnNames, callback func(rows []Row) error) error {
+	if isGzipped {
+		zr, err := common.GetGzipReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read gzipped Arrow data: %w", err)
+		}
+		defer common.PutGzipReader(zr)
+		r = zr
+	}
+	ctx := getStreamContext(r)
+	defer putStreamContext(ctx)
+
+	// Skip the magic for Arrow IPC file format. The file format contains streaming format messages after the magic.
+	if magic, err := ctx.br.Peek(len(arrowFileMagic)); err == nil && string(magic) == arrowFileMagic {
+		// The magic is padded to 8 bytes.
+		if _, err := ctx.br.Discard(8); err != nil {
+			readErrors.Inc()
+			return fmt.Errorf("cannot skip Arrow file magic: %w", err)
+		}
+	}
+
+	var sch *schema
+	for {
+		headerType, header, ok, err := ctx.readMessage()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// End of stream
+			return nil
+		}
+		switch headerType {
+		case messageHeaderSchema:
+			if sch != nil {
+				parseErrors.Inc()
+				return fmt.Errorf("unexpected duplicate schema message")
+			}
+			if sch, err = parseSchema(header, cns); err != nil {
+				parseErrors.Inc()
+				return fmt.Errorf("cannot parse Arrow schema: %w", err)
+			}
+		case messageHeaderDictionaryBatch:
+			parseErrors.Inc()
+			return fmt.Errorf("dictionary batches aren't supported")
+		case messageHeaderRecordBatch:
+			if sch == nil {
+				parseErrors.Inc()
+				return fmt.Errorf("missing schema message before record batch")
+			}
+			if err := ctx.rb.parse(header, ctx.body.B, sch); err != nil {
+				parseErrors.Inc()
+				return fmt.Errorf("cannot parse Arrow record batch: %w", err)
+			}
+			if err := ctx.processRecordBatch(callback); err != nil {
+				return err
+			}
+		default:
+			parseErrors.Inc()
+			return fmt.Errorf("unsupported Arrow message type %d", headerType)
+		}
+	}
+}
+
+func (ctx *streamContext) processRecordBatch(callback func(rows []Row) error) error {
+	rb := &ctx.rb
+	currentTimestamp := time.Now().UnixNano() / 1e6
+	for start := 0; start < rb.rowsCount; start += maxRowsPerCallback {
+		end := start + maxRowsPerCallback
+		if end > rb.rowsCount {
+			end = rb.rowsCount
+		}
+		ctx.resetRows()
+		var skippedRows int
+		var err error
+		ctx.rows, ctx.tagsPool, skippedRows, err = rb.appendRows(ctx.rows, ctx.tagsPool, start, end, currentTimestamp)
+		if err != nil {
+			parseErrors.Inc()
+			return fmt.Errorf("cannot read rows from Arrow record batch: %w", err)
+		}
+		rowsSkipped.Add(skippedRows)
+		rowsRead.Add(len(ctx.rows))
+		if err := callback(ctx.rows); err != nil {
+			return fmt.Errorf("error when processing imported data: %w", err)
+		}
+	}
+	return nil
+}
+
+// readMessage reads the next message from ctx.
+//
+// It returns the message header type and header. The message body is stored in ctx.body.
+// false is returned on the end of stream.
+func (ctx *streamContext) readMessage() (uint8, fbTable, bool, error) {
+	var sizeBuf [4]byte
+	if _, err := io.ReadFull(ctx.br, sizeBuf[:]); err != nil {
+		if err == io.EOF {
+			// The stream has no end-of-stream marker.
+			return 0, fbTable{}, false, nil
+		}
+		readErrors.Inc()
+		return 0, fbTable{}, false, fmt.Errorf("cannot read message size: %w", err)
+	}
+	size := binary.LittleEndian.Uint32(sizeBuf[:])
+	if size == continuationMarker {
+		if _, err := io.ReadFull(ctx.br, sizeBuf[:]); err != nil {
+			readErrors.Inc()
+			return 0, fbTable{}, false, fmt.Errorf("cannot read message size after continuation marker: %w", err)
+		}
+		size = binary.LittleEndian.Uint32(sizeBuf[:])
+	}
+	if size == 0 {
+		// End-of-stream marker
+		return 0, fbTable{}, false, nil
+	}
+	if int64(size) > int64(maxMessageSize.N) {
+		readErrors.Inc()
+		return 0, fbTable{}, false, fmt.Errorf("too big message metadata size: %d bytes; mustn't exceed -arrow.maxMessageSize=%d bytes", size, maxMessageSize.N)
+	}
+	ctx.metadata.B = bytesutil.Resize(ctx.metadata.B, int(size))
+	if _, err := io.ReadFull(ctx.br, ctx.metadata.B); err != nil {
+		readErrors.Inc()
+		return 0, fbTable{}, false, fmt.Errorf("cannot read message metadata with size %d bytes: %w", size, err)
+	}
+	readCalls.Inc()
+
+	msg, err := getRootTable(ctx.metadata.B)
+	if err != nil {
+		parseErrors.Inc()
+		return 0, fbTable{}, false, fmt.Errorf("cannot parse message metadata: %w", err)
+	}
+	headerType, err := msg.getUint8(1, 0)
+	if err != nil {
+		parseErrors.Inc()
+		return 0, fbTable{}, false, fmt.Errorf("cannot read message header type: %w", err)
+	}
+	header, ok, err := msg.getTable(2)
+	if err == nil && !ok {
+		err = fmt.Errorf("missing message header")
+	}
+	if err != nil {
+		parseErrors.Inc()
+		return 0, fbTable{}, false, fmt.Errorf("cannot read message header: %w", err)
+	}
+	bodyLength, err := msg.getInt64(3, 0)
+	if err != nil {
+		parseErrors.Inc()
+		return 0, fbTable{}, false, fmt.Errorf("cannot read message body length: %w", err)
+	}
+	if bodyLength < 0 || bodyLength > int64(maxMessageSize.N) {
+		readErrors.Inc()
+		return 0, fbTable{}, false, fmt.Errorf("invalid message body length: %d bytes; it mustn't exceed -arrow.maxMessageSize=%d bytes", bodyLength, maxMessageSize.N)
+	}
+	ctx.body.B = bytesutil.Resize(ctx.body.B, int(bodyLength))
+	if _, err := io.ReadFull(ctx.br, ctx.body.B); err != nil {
+		readErrors.Inc()
+		return 0, fbTable{}, false, fmt.Errorf("cannot read message body with length %d bytes: %w", bodyLength, err)
+	}
+	readCalls.Inc()
+	return headerType, header, true, nil
+}
+
+var (
+	readCalls   = metrics.NewCounter(`vm_protoparser_read_calls_total{type="arrow"}`)
+	readErrors  = metrics.NewCounter(`vm_protoparser_read_errors_total{type="arrow"}`)
+	rowsRead    = metrics.NewCounter(`vm_protoparser_rows_read_total{type="arrow"}`)
+	rowsSkipped = metrics.NewCounter(`vm_protoparser_rows_skipped_total{type="arrow"}`)
+	parseErrors = metrics.NewCounter(`vm_protoparser_parse_errors_total{type="arrow"}`)
+)
+
+type streamContext struct {
+	br       *bufio.Reader
+	metadata bytesutil.ByteBuffer
+	body     bytesutil.ByteBuffer
+	rb       recordBatch
+	rows     []Row
+	tagsPool []Tag
+}
+
+func (ctx *streamContext) resetRows() {
+	rows := ctx.rows
+	for i := range rows {
+		rows[i] = Row{}
+	}
+	ctx.rows = rows[:0]
+
+	tags := ctx.tagsPool
+	for i := range tags {
+		tags[i] = Tag{}
+	}
+	ctx.tagsPool = tags[:0]
+}
+
+func (ctx *streamContext) reset() {
+	ctx.br.Reset(nil)
+	ctx.metadata.Reset()
+	ctx.body.Reset()
+	ctx.rb.reset()
+	ctx.resetRows()
+}
+
+func getStreamContext(r io.Reader) *streamContext {
+	if v := streamContextPool.Get(); v != nil {
+		ctx := v.(*streamContext)
+		ctx.br.Reset(r)
+		return ctx
+	}
+	return &streamContext{
+		br: bufio.NewReaderSize(r, 64*1024),
+	}
+}
+
+func putStreamContext(ctx *streamContext) {
+	ctx.reset()
+	streamContextPool.Put(ctx)
+}
+
+var streamContextPool sync.Pool
@@ -0,0 +1,554 @@
+package arrow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+)
+
+func TestParseStreamSuccess(t *testing.T) {
+	f := func(data []byte, isGzipped bool, cns *ColumnNames, rowsExpected []string) {
+		t.Helper()
+		var rows []string
+		err := ParseStream(bytes.NewReader(data), isGzipped, cns, func(rs []Row) error {
+			for i := range rs {
+				rows = append(rows, rowString(&rs[i]))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(rows, rowsExpected) {
+			t.Fatalf("unexpected rows;\ngot\n%s\nwant\n%s", strings.Join(rows, "\n"), strings.Join(rowsExpected, "\n"))
+		}
+	}
+	cns := &ColumnNames{
+		Metric:    "__name__",
+		Timestamp: "timestamp",
+		Value:     "value",
+	}
+
+	columns := []testColumn{
+		utf8Column("__name__", "foo", "bar", "foo"),
+		utf8Column("job", "a", "", "\x00null"),
+		int64Column("timestamp", typeInt, 0, 1000, 2000, 3000),
+		float64Column("value", 1.5, math.Inf(1), -3),
+	}
+	rowsExpected := []string{
+		`foo{job="a"} 1.5 1000`,
+		`bar{} +Inf 2000`,
+		`foo{} -3 3000`,
+	}
+
+	// Stream format
+	data := marshalTestStream(columns, 1)
+	f(data, false, cns, rowsExpected)
+
+	// File format
+	f(append([]byte("ARROW1\x00\x00"), data...), false, cns, rowsExpected)
+
+	// Stream without end-of-stream marker
+	f(data[:len(data)-8], false, cns, rowsExpected)
+
+	// Multiple record batches
+	f(marshalTestStream(columns, 3), false, cns, append(append(rowsExpected, rowsExpected...), rowsExpected...))
+
+	// Gzipped stream
+	var bb bytes.Buffer
+	zw := gzip.NewWriter(&bb)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("cannot write gzipped data: %s", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("cannot close gzip writer: %s", err)
+	}
+	f(bb.Bytes(), true, cns, rowsExpected)
+
+	// Empty stream
+	f(nil, false, cns, nil)
+	f(marshalTestStream(columns, 0), false, cns, nil)
+
+	// Timestamp types, int values, LargeUtf8 labels and null values
+	f(marshalTestStream([]testColumn{
+		int64Column("ts", typeTimestamp, 0, 1, 2),
+		largeUtf8Column("name", "foo", "bar"),
+		int32Column("v", 10, -20),
+	}, 1), false, &ColumnNames{
+		Metric:    "name",
+		Timestamp: "ts",
+		Value:     "v",
+	}, []string{
+		`foo{} 10 1000`,
+		`bar{} -20 2000`,
+	})
+	f(marshalTestStream([]testColumn{
+		utf8Column("__name__", "foo", "bar", "baz"),
+		int64Column("timestamp", typeTimestamp, 3, 1e6, 2e6, 3e6),
+		float64Column("value", 1, math.NaN(), 3).withNulls(false, true, false),
+	}, 1), false, cns, []string{
+		`foo{} 1 1`,
+		`baz{} 3 3`,
+	})
+}
+
+func TestParseStreamMissingTimestamp(t *testing.T) {
+	data := marshalTestStream([]testColumn{
+		utf8Column("__name__", "foo"),
+		float64Column("value", 1),
+	}, 1)
+	var timestamps []int64
+	err := ParseStream(bytes.NewReader(data), false, &ColumnNames{
+		Metric:    "__name__",
+		Timestamp: "timestamp",
+		Value:     "value",
+	}, func(rows []Row) error {
+		for i := range rows {
+			timestamps = append(timestamps, rows[i].Timestamp)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(timestamps) != 1 || timestamps[0] <= 0 {
+		t.Fatalf("expecting the current timestamp for rows without timestamp column; got %v", timestamps)
+	}
+}
+
+func TestParseStreamFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		err := ParseStream(bytes.NewReader(data), false, &ColumnNames{
+			Metric:    "__name__",
+			Timestamp: "timestamp",
+			Value:     "value",
+		}, func(rows []Row) error {
+			return nil
+		})
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	columns := []testColumn{
+		utf8Column("__name__", "foo", "bar"),
+		int64Column("timestamp", typeInt, 0, 1000, 2000),
+		float64Column("value", 1, 2),
+	}
+
+	// Invalid gzip
+	if err := ParseStream(bytes.NewReader([]byte("foobar")), true, &ColumnNames{}, nil); err == nil {
+		t.Fatalf("expecting non-nil error for invalid gzip")
+	}
+
+	// Truncated stream
+	data := marshalTestStream(columns, 1)
+	f(data[:3])
+	f(data[:len(data)-20])
+
+	// Record batch without schema
+	schemaLen := len(marshalTestStream(columns, 0)) - 8
+	f(data[schemaLen:])
+
+	// Duplicate schema
+	f(append(data[:schemaLen:schemaLen], data...))
+
+	// Missing metric name column
+	f(marshalTestStream(columns[1:], 1))
+
+	// Missing value column
+	f(marshalTestStream(columns[:2], 1))
+
+	// Unsupported column type
+	f(marshalTestStream(append(columns, testColumn{
+		name:     "bool",
+		typeType: 6,
+		typ:      &fbTableValue{},
+	}), 1))
+
+	// Invalid type for special columns
+	f(marshalTestStream([]testColumn{
+		int64Column("__name__", typeInt, 0, 1),
+		float64Column("value", 1),
+	}, 1))
+	f(marshalTestStream([]testColumn{
+		utf8Column("__name__", "foo"),
+		utf8Column("value", "1"),
+	}, 1))
+	f(marshalTestStream([]testColumn{
+		utf8Column("__name__", "foo"),
+		float64Column("timestamp", 1),
+		float64Column("value", 1),
+	}, 1))
+
+	// Missing metric name
+	f(marshalTestStream([]testColumn{
+		utf8Column("__name__", "foo", ""),
+		float64Column("value", 1, 2),
+	}, 1))
+
+	// Inconsistent number of values
+	f(marshalTestStream([]testColumn{
+		utf8Column("__name__", "foo", "bar"),
+		float64Column("value", 1),
+	}, 1))
+
+	// Invalid offsets
+	f(marshalTestStream([]testColumn{
+		utf8Column("__name__", "foo").withBuffers([]byte{0, 0, 0, 0, 10, 0, 0, 0}, []byte("foo")),
+		float64Column("value", 1),
+	}, 1))
+
+	// Garbage
+	f([]byte("\xff\xff\xff\xff\x10\x00\x00\x00foobarbazfoobarbaz"))
+}
+
+func TestParseStreamTruncated(t *testing.T) {
+	// Verify that arbitrary truncated data doesn't result in panic.
+	data := marshalTestStream([]testColumn{
+		utf8Column("__name__", "foo", "bar"),
+		largeUtf8Column("job", "x", "y"),
+		int64Column("timestamp", typeTimestamp, 2, 1000, 2000),
+		float64Column("value", 1, 2).withNulls(false, true),
+	}, 2)
+	cns := &ColumnNames{
+		Metric:    "__name__",
+		Timestamp: "timestamp",
+		Value:     "value",
+	}
+	for i := 0; i < len(data); i++ {
+		_ = ParseStream(bytes.NewReader(data[:i]), false, cns, func(rows []Row) error {
+			return nil
+		})
+		// Corrupt a single byte
+		corrupted := append([]byte{}, data...)
+		corrupted[i] ^= 0xff
+		_ = ParseStream(bytes.NewReader(corrupted), false, cns, func(rows []Row) error {
+			return nil
+		})
+	}
+}
+
+func rowString(r *Row) string {
+	tags := make([]string, len(r.Tags))
+	for i, tag := range r.Tags {
+		tags[i] = fmt.Sprintf("%s=%q", tag.Key, tag.Value)
+	}
+	return fmt.Sprintf("%s{%s} %s %d", r.Metric, strings.Join(tags, ","), strconv.FormatFloat(r.Value, 'g', -1, 64), r.Timestamp)
+}
+
+// testColumn describes a column for marshalTestStream.
+type testColumn struct {
+	name     string
+	typeType uint8
+	typ      *fbTableValue
+
+	// nulls contains true for null values.
+	nulls []bool
+
+	// length is the number of values in the column.
+	length int
+
+	// buffers contains column buffers except of validity bitmap.
+	buffers [][]byte
+}
+
+func (tc testColumn) withNulls(nulls ...bool) testColumn {
+	tc.nulls = nulls
+	return tc
+}
+
+func (tc testColumn) withBuffers(buffers ...[]byte) testColumn {
+	tc.buffers = buffers
+	return tc
+}
+
+func utf8Column(name string, values ...string) testColumn {
+	var offsets, data []byte
+	var nulls []bool
+	offsets = appendUint32(offsets, 0)
+	for _, v := range values {
+		if v == "\x00null" {
+			nulls = append(nulls, true)
+		} else {
+			nulls = append(nulls, false)
+			data = append(data, v...)
+		}
+		offsets = appendUint32(offsets, uint32(len(data)))
+	}
+	return testColumn{
+		name:     name,
+		typeType: typeUtf8,
+		typ:      &fbTableValue{},
+		nulls:    nulls,
+		length:   len(values),
+		buffers:  [][]byte{offsets, data},
+	}
+}
+
+func largeUtf8Column(name string, values ...string) testColumn {
+	var offsets, data []byte
+	offsets = appendUint64(offsets, 0)
+	for _, v := range values {
+		data = append(data, v...)
+		offsets = appendUint64(offsets, uint64(len(data)))
+	}
+	return testColumn{
+		name:     name,
+		typeType: typeLargeUtf8,
+		typ:      &fbTableValue{},
+		length:   len(values),
+		buffers:  [][]byte{offsets, data},
+	}
+}
+
+// int64Column returns Int64 column if typeType is typeInt and Timestamp column with the given unit if typeType is typeTimestamp.
+func int64Column(name string, typeType uint8, unit int16, values ...int64) testColumn {
+	var data []byte
+	for _, v := range values {
+		data = appendUint64(data, uint64(v))
+	}
+	typ := &fbTableValue{
+		fields: []interface{}{int32(64), true},
+	}
+	if typeType == typeTimestamp {
+		typ = &fbTableValue{
+			fields: []interface{}{unit},
+		}
+	}
+	return testColumn{
+		name:     name,
+		typeType: typeType,
+		typ:      typ,
+		length:   len(values),
+		buffers:  [][]byte{data},
+	}
+}
+
+func int32Column(name string, values ...int32) testColumn {
+	var data []byte
+	for _, v := range values {
+		data = appendUint32(data, uint32(v))
+	}
+	return testColumn{
+		name:     name,
+		typeType: typeInt,
+		typ: &fbTableValue{
+			fields: []interface{}{int32(32), true},
+		},
+		length:  len(values),
+		buffers: [][]byte{data},
+	}
+}
+
+func float64Column(name string, values ...float64) testColumn {
+	var data []byte
+	for _, v := range values {
+		data = appendUint64(data, math.Float64bits(v))
+	}
+	return testColumn{
+		name:     name,
+		typeType: typeFloatingPoint,
+		typ: &fbTableValue{
+			fields: []interface{}{int16(2)},
+		},
+		length:  len(values),
+		buffers: [][]byte{data},
+	}
+}
+
+// marshalTestStream returns Arrow IPC stream with the schema for the given columns and batchesCount record batches with the given columns.
+func marshalTestStream(columns []testColumn, batchesCount int) []byte {
+	var fields []*fbTableValue
+	for _, c := range columns {
+		fields = append(fields, &fbTableValue{
+			fields: []interface{}{c.name, true, c.typeType, c.typ},
+		})
+	}
+	schema := &fbTableValue{
+		fields: []interface{}{int16(0), fields},
+	}
+	dst := appendTestMessage(nil, messageHeaderSchema, schema, nil)
+
+	var nodes, buffers, body []byte
+	rowsCount := 0
+	for _, c := range columns {
+		if c.length > rowsCount {
+			rowsCount = c.length
+		}
+		nullCount := 0
+		var validity []byte
+		for i, isNull := range c.nulls {
+			if i%8 == 0 {
+				validity = append(validity, 0)
+			}
+			if isNull {
+				nullCount++
+			} else {
+				validity[i/8] |= 1 << (i % 8)
+			}
+		}
+		if nullCount == 0 {
+			validity = nil
+		}
+		nodes = appendUint64(nodes, uint64(c.length))
+		nodes = appendUint64(nodes, uint64(nullCount))
+		for _, buf := range append([][]byte{validity}, c.buffers...) {
+			buffers = appendUint64(buffers, uint64(len(body)))
+			buffers = appendUint64(buffers, uint64(len(buf)))
+			body = append(body, buf...)
+			for len(body)%8 != 0 {
+				body = append(body, 0)
+			}
+		}
+	}
+	recordBatch := &fbTableValue{
+		fields: []interface{}{int64(rowsCount), fbStructVector{nodes, fieldNodeSize}, fbStructVector{buffers, bufferSize}},
+	}
+	for i := 0; i < batchesCount; i++ {
+		dst = appendTestMessage(dst, messageHeaderRecordBatch, recordBatch, body)
+	}
+	// End-of-stream marker
+	dst = appendUint32(dst, continuationMarker)
+	dst = appendUint32(dst, 0)
+	return dst
+}
+
+func appendTestMessage(dst []byte, headerType uint8, header *fbTableValue, body []byte) []byte {
+	msg := &fbTableValue{
+		fields: []interface{}{int16(4), headerType, header, int64(len(body))},
+	}
+	metadata := marshalFlatbuffer(msg)
+	for len(metadata)%8 != 0 {
+		metadata = append(metadata, 0)
+	}
+	dst = appendUint32(dst, continuationMarker)
+	dst = appendUint32(dst, uint32(len(metadata)))
+	dst = append(dst, metadata...)
+	return append(dst, body...)
+}
+
+// fbTableValue is a flatbuffers table for marshalFlatbuffer.
+//
+// fields may contain nil for missing fields, scalars (bool, uint8, int16, int32, int64),
+// strings, *fbTableValue, []*fbTableValue and fbStructVector.
+type fbTableValue struct {
+	fields []interface{}
+}
+
+// fbStructVector is a vector of structs with the given itemSize.
+type fbStructVector struct {
+	data     []byte
+	itemSize int
+}
+
+// marshalFlatbuffer marshals root table t into flatbuffer.
+//
+// Objects are written front-to-back, so every object is written before the objects it refers to.
+func marshalFlatbuffer(t *fbTableValue) []byte {
+	dst := make([]byte, 4)
+	var pos int
+	dst, pos = appendFlatbufferObject(dst, t)
+	binary.LittleEndian.PutUint32(dst, uint32(pos))
+	return dst
+}
+
+func appendFlatbufferObject(dst []byte, v interface{}) ([]byte, int) {
+	switch v := v.(type) {
+	case string:
+		pos := len(dst)
+		dst = appendUint32(dst, uint32(len(v)))
+		dst = append(dst, v...)
+		return append(dst, 0), pos
+	case fbStructVector:
+		pos := len(dst)
+		dst = appendUint32(dst, uint32(len(v.data)/v.itemSize))
+		return append(dst, v.data...), pos
+	case []*fbTableValue:
+		pos := len(dst)
+		dst = appendUint32(dst, uint32(len(v)))
+		refsPos := len(dst)
+		dst = append(dst, make([]byte, 4*len(v))...)
+		for i, t := range v {
+			var childPos int
+			dst, childPos = appendFlatbufferObject(dst, t)
+			refPos := refsPos + 4*i
+			binary.LittleEndian.PutUint32(dst[refPos:], uint32(childPos-refPos))
+		}
+		return dst, pos
+	case *fbTableValue:
+		vtPos := len(dst)
+		dst = appendUint16(dst, uint16(4+2*len(v.fields)))
+		sizePos := len(dst)
+		dst = append(dst, make([]byte, 2+2*len(v.fields))...)
+		tablePos := len(dst)
+		dst = appendUint32(dst, uint32(tablePos-vtPos))
+		type ref struct {
+			pos   int
+			value interface{}
+		}
+		var refs []ref
+		for i, field := range v.fields {
+			if field == nil {
+				continue
+			}
+			binary.LittleEndian.PutUint16(dst[vtPos+4+2*i:], uint16(len(dst)-tablePos))
+			switch x := field.(type) {
+			case bool:
+				if x {
+					dst = append(dst, 1)
+				} else {
+					dst = append(dst, 0)
+				}
+			case uint8:
+				dst = append(dst, x)
+			case int16:
+				dst = appendUint16(dst, uint16(x))
+			case int32:
+				dst = appendUint32(dst, uint32(x))
+			case int64:
+				dst = appendUint64(dst, uint64(x))
+			default:
+				refs = append(refs, ref{
+					pos:   len(dst),
+					value: x,
+				})
+				dst = appendUint32(dst, 0)
+			}
+		}
+		binary.LittleEndian.PutUint16(dst[sizePos:], uint16(len(dst)-tablePos))
+		for _, r := range refs {
+			var childPos int
+			dst, childPos = appendFlatbufferObject(dst, r.value)
+			binary.LittleEndian.PutUint32(dst[r.pos:], uint32(childPos-r.pos))
+		}
+		return dst, tablePos
+	default:
+		panic(fmt.Errorf("BUG: unexpected flatbuffer object type %T", v))
+	}
+}
+
+func appendUint16(dst []byte, v uint16) []byte {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	return append(dst, b[:]...)
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return append(dst, b[:]...)
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(dst, b[:]...)
+}
@@ -2,21 +2,51 @@ package common
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // GetTimestamp extracts unix timestamp in milliseconds from `timestamp` query arg.
 //
+// The `timestamp` query arg may contain one of the following values:
+//
+//   - unix timestamp in milliseconds, i.e. `1594370496905`
+//   - unix timestamp in seconds with fractional part, i.e. `1594370496.905`
+//   - RFC3339 time, i.e. `2020-07-10T08:41:36.905Z`
+//
 // It returns 0 if there is no `timestamp` query arg.
 func GetTimestamp(req *http.Request) (int64, error) {
 	ts := req.URL.Query().Get("timestamp")
 	if len(ts) == 0 {
 		return 0, nil
 	}
-	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	timestamp, err := parseTimestamp(ts)
 	if err != nil {
 		return 0, fmt.Errorf("cannot parse `timestamp=%s` query arg: %w", ts, err)
 	}
 	return timestamp, nil
 }
+
+func parseTimestamp(s string) (int64, error) {
+	if strings.IndexByte(s, 'T') >= 0 {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return 0, err
+		}
+		return t.UnixNano() / 1e6, nil
+	}
+	if strings.IndexByte(s, '.') >= 0 {
+		secs, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, err
+		}
+		if math.IsNaN(secs) || math.Abs(secs) > float64(math.MaxInt64/1e3) {
+			return 0, fmt.Errorf("timestamp in seconds is out of range")
+		}
+		return int64(math.Round(secs * 1e3)), nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
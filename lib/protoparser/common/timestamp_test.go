@@ -0,0 +1,53 @@
+package common
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestGetTimestampSuccess(t *testing.T) {
+	f := func(s string, timestampExpected int64) {
+		t.Helper()
+		req := &http.Request{
+			URL: &url.URL{
+				RawQuery: "timestamp=" + url.QueryEscape(s),
+			},
+		}
+		timestamp, err := GetTimestamp(req)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if timestamp != timestampExpected {
+			t.Fatalf("unexpected timestamp for %q; got %d; want %d", s, timestamp, timestampExpected)
+		}
+	}
+	f("", 0)
+	f("1594370496905", 1594370496905)
+	f("-123", -123)
+	f("1594370496", 1594370496)
+	f("1594370496.905", 1594370496905)
+	f("1594370496.", 1594370496000)
+	f("2020-07-10T08:41:36.905Z", 1594370496905)
+	f("2020-07-10T10:41:36+02:00", 1594370496000)
+}
+
+func TestGetTimestampFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		req := &http.Request{
+			URL: &url.URL{
+				RawQuery: "timestamp=" + url.QueryEscape(s),
+			},
+		}
+		if _, err := GetTimestamp(req); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+	f("foo")
+	f("123foo")
+	f("1.2.3")
+	f("2020-07-10T08:41:36")
+	f("1e300.5")
+	f("NaN.")
+}
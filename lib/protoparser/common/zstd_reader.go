@@ -0,0 +1,32 @@
+package common
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// GetZstdReader returns zstd reader from the pool.
+//
+// Return back the zstd reader when it no longer needed with PutZstdReader.
+func GetZstdReader(r io.Reader) (*zstd.Decoder, error) {
+	v := zstdReaderPool.Get()
+	if v == nil {
+		return zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	}
+	zr := v.(*zstd.Decoder)
+	if err := zr.Reset(r); err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+// PutZstdReader returns back zstd reader obtained via GetZstdReader.
+func PutZstdReader(zr *zstd.Decoder) {
+	// Release the reference to the underlying reader.
+	_ = zr.Reset(nil)
+	zstdReaderPool.Put(zr)
+}
+
+var zstdReaderPool sync.Pool
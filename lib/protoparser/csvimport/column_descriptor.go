@@ -2,6 +2,7 @@ package csvimport
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -24,6 +25,10 @@ type ColumnDescriptor struct {
 	// from the given column.
 	TagName string
 
+	// TagDefault is set to the default tag value, which is used
+	// when the given column is empty.
+	TagDefault string
+
 	// MetricName is set to metric name for value obtained from the given column.
 	MetricName string
 }
@@ -41,12 +46,16 @@ const maxColumnsPerRow = 64 * 1024
 //   - <column_pos> is numeric csv column position. The first column has position 1.
 //   - <column_type> is one of the following types:
 //     - time - the corresponding column contains timestamp. Timestamp format is determined by <extension>. The following formats are supported:
-//       - unix_s - unix timestamp in seconds
+//       - unix_s - unix timestamp in seconds. It may contain fractional part such as `1583951026.123`
 //       - unix_ms - unix timestamp in milliseconds
+//       - unix_us - unix timestamp in microseconds
 //       - unix_ns - unix_timestamp in nanoseconds
 //       - rfc3339 - RFC3339 format in the form `2006-01-02T15:04:05Z07:00`
+//       - custom:<layout> - custom layout according to https://golang.org/pkg/time/#Parse
 //     - label - the corresponding column contains metric label with the name set in <extension>.
+//       <extension> may contain `<name>=<default>`. In this case <default> is used as label value for empty column.
 //     - metric - the corresponding column contains metric value with the name set in <extension>.
+//       Empty metric values are skipped.
 //
 // s must contain at least a single 'metric' column and no more than a single `time` column.
 func ParseColumnDescriptors(s string) ([]ColumnDescriptor, error) {
@@ -88,6 +97,10 @@ func ParseColumnDescriptors(s string) ([]ColumnDescriptor, error) {
 			hasTimeCol = true
 		case "label":
 			cd.TagName = a[2]
+			if n := strings.IndexByte(cd.TagName, '='); n >= 0 {
+				cd.TagDefault = cd.TagName[n+1:]
+				cd.TagName = cd.TagName[:n]
+			}
 			if len(cd.TagName) == 0 {
 				return nil, fmt.Errorf("label name cannot be empty in the entry #%d %q", i+1, col)
 			}
@@ -126,16 +139,29 @@ func parseTimeFormat(format string) (func(s string) (int64, error), error) {
 		return parseUnixTimestampSeconds, nil
 	case "unix_ms":
 		return parseUnixTimestampMilliseconds, nil
+	case "unix_us":
+		return parseUnixTimestampMicroseconds, nil
 	case "unix_ns":
 		return parseUnixTimestampNanoseconds, nil
 	case "rfc3339":
 		return parseRFC3339, nil
 	default:
-		return nil, fmt.Errorf("unknown format for time parsing: %q; supported formats: unix_s, unix_ms, unix_ns, rfc3339", format)
+		return nil, fmt.Errorf("unknown format for time parsing: %q; supported formats: unix_s, unix_ms, unix_us, unix_ns, rfc3339, custom:<layout>", format)
 	}
 }
 
 func parseUnixTimestampSeconds(s string) (int64, error) {
+	if strings.IndexByte(s, '.') >= 0 {
+		// Timestamp with fractional seconds
+		f, err := fastfloat.Parse(s)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse timestamp seconds from %q: %w", s, err)
+		}
+		if math.Abs(f) > float64(int64(1<<63-1)/1e3) {
+			return 0, fmt.Errorf("too big unix timestamp in seconds: %s; must be smaller than %d", s, int64(1<<63-1)/1e3)
+		}
+		return int64(math.Round(f * 1e3)), nil
+	}
 	n, err := fastfloat.ParseInt64(s)
 	if err != nil {
 		return 0, fmt.Errorf("cannot parse timestamp seconds from %q: %w", s, err)
@@ -154,6 +180,14 @@ func parseUnixTimestampMilliseconds(s string) (int64, error) {
 	return n, nil
 }
 
+func parseUnixTimestampMicroseconds(s string) (int64, error) {
+	n, err := fastfloat.ParseInt64(s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse timestamp microseconds from %q: %w", s, err)
+	}
+	return n / 1e3, nil
+}
+
 func parseUnixTimestampNanoseconds(s string) (int64, error) {
 	n, err := fastfloat.ParseInt64(s)
 	if err != nil {
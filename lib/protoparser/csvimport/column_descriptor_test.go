@@ -59,6 +59,21 @@ func TestParseColumnDescriptorsSuccess(t *testing.T) {
 			ParseTimestamp: parseRFC3339,
 		},
 	})
+	f("2:time:unix_us,1:metric:temperature,3:label:city=unknown,4:label:country=", []ColumnDescriptor{
+		{
+			MetricName: "temperature",
+		},
+		{
+			ParseTimestamp: parseUnixTimestampMicroseconds,
+		},
+		{
+			TagName:    "city",
+			TagDefault: "unknown",
+		},
+		{
+			TagName: "country",
+		},
+	})
 }
 
 func TestParseColumnDescriptorsFailure(t *testing.T) {
@@ -96,6 +111,7 @@ func TestParseColumnDescriptorsFailure(t *testing.T) {
 
 	// empty label name
 	f("2:label:,1:metric:aaa")
+	f("2:label:=foo,1:metric:aaa")
 
 	// Empty metric name
 	f("1:metric:")
@@ -121,6 +137,25 @@ func TestParseUnixTimestampSeconds(t *testing.T) {
 	f("0", 0)
 	f("123", 123000)
 	f("-123", -123000)
+	f("123.456", 123456)
+	f("-1.5", -1500)
+}
+
+func TestParseUnixTimestampMicroseconds(t *testing.T) {
+	f := func(s string, tsExpected int64) {
+		t.Helper()
+		ts, err := parseUnixTimestampMicroseconds(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if ts != tsExpected {
+			t.Fatalf("unexpected ts when parsing %q; got %d; want %d", s, ts, tsExpected)
+		}
+	}
+	f("0", 0)
+	f("123", 0)
+	f("1583951026123456", 1583951026123)
+	f("-12345", -12)
 }
 
 func TestParseUnixTimestampMilliseconds(t *testing.T) {
@@ -219,6 +254,9 @@ func equalColumnDescriptor(x, y ColumnDescriptor) bool {
 	if x.TagName != y.TagName {
 		return false
 	}
+	if x.TagDefault != y.TagDefault {
+		return false
+	}
 	if x.MetricName != y.MetricName {
 		return false
 	}
@@ -13,6 +13,9 @@ type Rows struct {
 	// Rows contains parsed csv rows after the call to Unmarshal.
 	Rows []Row
 
+	// InvalidLines contains the number of invalid lines skipped during the last call to Unmarshal.
+	InvalidLines int
+
 	sc          scanner
 	tagsPool    []Tag
 	metricsPool []metric
@@ -29,6 +32,7 @@ func (rs *Rows) Reset() {
 		r.Timestamp = 0
 	}
 	rs.Rows = rs.Rows[:0]
+	rs.InvalidLines = 0
 
 	rs.sc.Init("")
 
@@ -71,10 +75,11 @@ type metric struct {
 // Unmarshal unmarshal csv lines from s according to the given cds.
 func (rs *Rows) Unmarshal(s string, cds []ColumnDescriptor) {
 	rs.sc.Init(s)
-	rs.Rows, rs.tagsPool, rs.metricsPool = parseRows(&rs.sc, rs.Rows[:0], rs.tagsPool[:0], rs.metricsPool[:0], cds)
+	rs.Rows, rs.tagsPool, rs.metricsPool, rs.InvalidLines = parseRows(&rs.sc, rs.Rows[:0], rs.tagsPool[:0], rs.metricsPool[:0], cds)
 }
 
-func parseRows(sc *scanner, dst []Row, tags []Tag, metrics []metric, cds []ColumnDescriptor) ([]Row, []Tag, []metric) {
+func parseRows(sc *scanner, dst []Row, tags []Tag, metrics []metric, cds []ColumnDescriptor) ([]Row, []Tag, []metric, int) {
+	invalidLinesCount := 0
 	for sc.NextLine() {
 		line := sc.Line
 		var r Row
@@ -98,9 +103,13 @@ func parseRows(sc *scanner, dst []Row, tags []Tag, metrics []metric, cds []Colum
 				continue
 			}
 			if tagName := cd.TagName; tagName != "" {
+				tagValue := sc.Column
+				if tagValue == "" {
+					tagValue = cd.TagDefault
+				}
 				tags = append(tags, Tag{
 					Key:   tagName,
-					Value: sc.Column,
+					Value: tagValue,
 				})
 				continue
 			}
@@ -109,9 +118,14 @@ func parseRows(sc *scanner, dst []Row, tags []Tag, metrics []metric, cds []Colum
 				// The given field is ignored.
 				continue
 			}
+			if sc.Column == "" {
+				// Skip empty metric value. This is usual case for wide csv files with sparse data.
+				continue
+			}
 			value, err := fastfloat.Parse(sc.Column)
 			if err != nil {
 				sc.Error = fmt.Errorf("cannot parse metric value for %q from %q: %w", metricName, sc.Column, err)
+				break
 			}
 			metrics = append(metrics, metric{
 				Name:  metricName,
@@ -124,10 +138,12 @@ func parseRows(sc *scanner, dst []Row, tags []Tag, metrics []metric, cds []Colum
 		if sc.Error != nil {
 			logger.Errorf("error when parsing csv line %q: %s; skipping this line", line, sc.Error)
 			invalidLines.Inc()
+			invalidLinesCount++
 			continue
 		}
 		if len(metrics) == 0 {
-			logger.Panicf("BUG: expecting at least a single metric in columnDescriptors=%#v", cds)
+			// All the metric columns are empty.
+			continue
 		}
 		r.Metric = metrics[0].Name
 		r.Tags = tags[tagsLen:]
@@ -142,7 +158,7 @@ func parseRows(sc *scanner, dst []Row, tags []Tag, metrics []metric, cds []Colum
 			})
 		}
 	}
-	return dst, tags, metrics
+	return dst, tags, metrics, invalidLinesCount
 }
 
 var invalidLines = metrics.NewCounter(`vm_rows_invalid_total{type="csvimport"}`)
@@ -178,4 +178,49 @@ func TestRowsUnmarshalSuccess(t *testing.T) {
 			Value:  -45.6,
 		},
 	})
+
+	// Wide csv with empty metric values and label defaults
+	f("1:label:region=unknown,2:time:unix_s,3:metric:revenue,4:metric:cost", "EU,1583951026.5,12,\n,1583951027,,3\nUS,1583951028,,", []Row{
+		{
+			Metric: "revenue",
+			Tags: []Tag{
+				{
+					Key:   "region",
+					Value: "EU",
+				},
+			},
+			Value:     12,
+			Timestamp: 1583951026500,
+		},
+		{
+			Metric: "cost",
+			Tags: []Tag{
+				{
+					Key:   "region",
+					Value: "unknown",
+				},
+			},
+			Value:     3,
+			Timestamp: 1583951027000,
+		},
+	})
+}
+
+func TestRowsUnmarshalInvalidLines(t *testing.T) {
+	cds, err := ParseColumnDescriptors("1:metric:foo,2:time:unix_ms")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var rs Rows
+	rs.Unmarshal("1,2\nfoo,3\n4,bar\n5,6\n7", cds)
+	if len(rs.Rows) != 2 {
+		t.Fatalf("unexpected number of rows; got %d; want 2", len(rs.Rows))
+	}
+	if rs.InvalidLines != 3 {
+		t.Fatalf("unexpected number of invalid lines; got %d; want 3", rs.InvalidLines)
+	}
+	rs.Reset()
+	if rs.InvalidLines != 0 {
+		t.Fatalf("unexpected number of invalid lines after reset; got %d; want 0", rs.InvalidLines)
+	}
 }
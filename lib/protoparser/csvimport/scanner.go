@@ -17,6 +17,9 @@ type scanner struct {
 	// It is cleared on NextLine call.
 	Error error
 
+	// hasTrailingEmptyColumn is set if sc.Line ends with comma, i.e. the last column is empty.
+	hasTrailingEmptyColumn bool
+
 	s string
 }
 
@@ -25,6 +28,7 @@ func (sc *scanner) Init(s string) {
 	sc.Line = ""
 	sc.Column = ""
 	sc.Error = nil
+	sc.hasTrailingEmptyColumn = false
 	sc.s = s
 }
 
@@ -36,6 +40,7 @@ func (sc *scanner) Init(s string) {
 func (sc *scanner) NextLine() bool {
 	s := sc.s
 	sc.Error = nil
+	sc.hasTrailingEmptyColumn = false
 	for len(s) > 0 {
 		n := strings.IndexByte(s, '\n')
 		var line string
@@ -61,24 +66,34 @@ func (sc *scanner) NextLine() bool {
 // sc.Error is set to error in the case of error.
 func (sc *scanner) NextColumn() bool {
 	s := sc.Line
-	if len(s) == 0 {
+	if sc.Error != nil {
 		return false
 	}
-	if sc.Error != nil {
+	if len(s) == 0 {
+		if sc.hasTrailingEmptyColumn {
+			sc.hasTrailingEmptyColumn = false
+			sc.Column = ""
+			return true
+		}
 		return false
 	}
 	if s[0] == '"' {
 		sc.Column, sc.Line, sc.Error = readQuotedField(s)
-		return sc.Error == nil
-	}
-	n := strings.IndexByte(s, ',')
-	if n >= 0 {
-		sc.Column = s[:n]
-		sc.Line = s[n+1:]
 	} else {
-		sc.Column = s
-		sc.Line = ""
+		n := strings.IndexByte(s, ',')
+		if n >= 0 {
+			sc.Column = s[:n]
+			sc.Line = s[n+1:]
+		} else {
+			sc.Column = s
+			sc.Line = ""
+		}
+	}
+	if sc.Error != nil {
+		return false
 	}
+	// The line ending with comma contains empty last column.
+	sc.hasTrailingEmptyColumn = len(sc.Line) == 0 && s[len(s)-1] == ','
 	return true
 }
 
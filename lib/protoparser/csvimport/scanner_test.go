@@ -60,6 +60,38 @@ func TestScannerSuccess(t *testing.T) {
 	}
 }
 
+func TestScannerTrailingEmptyColumn(t *testing.T) {
+	f := func(s string, columnsExpected []string) {
+		t.Helper()
+		var sc scanner
+		sc.Init(s)
+		if !sc.NextLine() {
+			t.Fatalf("expecting the first line")
+		}
+		var columns []string
+		for sc.NextColumn() {
+			columns = append(columns, sc.Column)
+		}
+		if sc.Error != nil {
+			t.Fatalf("unexpected error: %s", sc.Error)
+		}
+		if len(columns) != len(columnsExpected) {
+			t.Fatalf("unexpected columns for %q; got %q; want %q", s, columns, columnsExpected)
+		}
+		for i := range columns {
+			if columns[i] != columnsExpected[i] {
+				t.Fatalf("unexpected columns for %q; got %q; want %q", s, columns, columnsExpected)
+			}
+		}
+	}
+	f("foo,", []string{"foo", ""})
+	f("foo,,", []string{"foo", "", ""})
+	f(",", []string{"", ""})
+	f(`"foo",`, []string{"foo", ""})
+	f(`"foo,"`, []string{"foo,"})
+	f("foo,bar", []string{"foo", "bar"})
+}
+
 func TestScannerFailure(t *testing.T) {
 	f := func(s string) {
 		t.Helper()
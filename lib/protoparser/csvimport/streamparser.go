@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
@@ -25,18 +26,20 @@ var (
 // The callback can be called concurrently multiple times for streamed data from req.
 //
 // callback shouldn't hold rows after returning.
-func ParseStream(req *http.Request, callback func(rows []Row) error) error {
+//
+// Malformed lines are skipped. ParseStream returns the number of skipped lines.
+func ParseStream(req *http.Request, callback func(rows []Row) error) (int, error) {
 	q := req.URL.Query()
 	format := q.Get("format")
 	cds, err := ParseColumnDescriptors(format)
 	if err != nil {
-		return fmt.Errorf("cannot parse the provided csv format: %w", err)
+		return 0, fmt.Errorf("cannot parse the provided csv format: %w", err)
 	}
 	r := req.Body
 	if req.Header.Get("Content-Encoding") == "gzip" {
 		zr, err := common.GetGzipReader(r)
 		if err != nil {
-			return fmt.Errorf("cannot read gzipped csv data: %w", err)
+			return 0, fmt.Errorf("cannot read gzipped csv data: %w", err)
 		}
 		defer common.PutGzipReader(zr)
 		r = zr
@@ -45,7 +48,8 @@ func ParseStream(req *http.Request, callback func(rows []Row) error) error {
 	defer putStreamContext(ctx)
 	for ctx.Read() {
 		uw := getUnmarshalWork()
-		uw.callback = func(rows []Row) {
+		uw.callback = func(rows []Row, invalidLines int) {
+			atomic.AddUint64(&ctx.invalidLines, uint64(invalidLines))
 			if err := callback(rows); err != nil {
 				ctx.callbackErrLock.Lock()
 				if ctx.callbackErr == nil {
@@ -61,10 +65,11 @@ func ParseStream(req *http.Request, callback func(rows []Row) error) error {
 		common.ScheduleUnmarshalWork(uw)
 	}
 	ctx.wg.Wait()
+	invalidLines := int(atomic.LoadUint64(&ctx.invalidLines))
 	if err := ctx.Error(); err != nil {
-		return err
+		return invalidLines, err
 	}
-	return ctx.callbackErr
+	return invalidLines, ctx.callbackErr
 }
 
 func (ctx *streamContext) Read() bool {
@@ -98,6 +103,10 @@ type streamContext struct {
 	wg              sync.WaitGroup
 	callbackErrLock sync.Mutex
 	callbackErr     error
+
+	// invalidLines is the number of invalid lines skipped during parsing.
+	// It must be accessed via atomic operations.
+	invalidLines uint64
 }
 
 func (ctx *streamContext) Error() error {
@@ -120,6 +129,7 @@ func (ctx *streamContext) reset() {
 	ctx.tailBuf = ctx.tailBuf[:0]
 	ctx.err = nil
 	ctx.callbackErr = nil
+	ctx.invalidLines = 0
 }
 
 func getStreamContext(r io.Reader) *streamContext {
@@ -153,7 +163,7 @@ var streamContextPoolCh = make(chan *streamContext, cgroup.AvailableCPUs())
 
 type unmarshalWork struct {
 	rows     Rows
-	callback func(rows []Row)
+	callback func(rows []Row, invalidLines int)
 	cds      []ColumnDescriptor
 	reqBuf   []byte
 }
@@ -188,7 +198,7 @@ func (uw *unmarshalWork) Unmarshal() {
 		}
 	}
 
-	uw.callback(rows)
+	uw.callback(rows, uw.rows.InvalidLines)
 	putUnmarshalWork(uw)
 }
 
@@ -0,0 +1,113 @@
+package datadog
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var sanitizeMetricName = flag.Bool("datadog.sanitizeMetricName", true, "Sanitize metric names for the ingested DataDog data to comply with Prometheus naming rules, "+
+	"i.e. replace chars other than [a-zA-Z0-9_:] with '_'. See https://prometheus.io/docs/concepts/data_model/#metric-names-and-labels")
+
+// SanitizeMetricName returns sanitized metric name if -datadog.sanitizeMetricName is set.
+//
+// Otherwise it returns name as is.
+func SanitizeMetricName(name string) string {
+	if !*sanitizeMetricName {
+		return name
+	}
+	return unsupportedMetricNameChars.ReplaceAllString(name, "_")
+}
+
+var unsupportedMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// SplitTag splits DataDog tag into tag name and value.
+//
+// See https://docs.datadoghq.com/getting_started/tagging/#define-tags
+func SplitTag(tag string) (string, string) {
+	n := strings.IndexByte(tag, ':')
+	if n < 0 {
+		// No tag value.
+		return tag, "no_label_value"
+	}
+	return tag[:n], tag[n+1:]
+}
+
+// Request represents DataDog submit request.
+//
+// It is filled from /api/v1/series, /api/v2/series and /api/beta/sketches requests.
+type Request struct {
+	Series []Series `json:"series"`
+
+	// v2 holds the intermediate /api/v2/series request when it is sent in JSON.
+	v2 requestV2JSON
+}
+
+func (req *Request) reset() {
+	series := req.Series
+	for i := range series {
+		series[i].reset()
+	}
+	req.Series = series[:0]
+	req.v2.reset()
+}
+
+// Unmarshal unmarshals DataDog /api/v1/series request body from b to req.
+//
+// See https://docs.datadoghq.com/api/latest/metrics/#submit-metrics
+//
+// b shouldn't be modified when req is in use.
+func (req *Request) Unmarshal(b []byte) error {
+	req.reset()
+	if err := json.Unmarshal(b, req); err != nil {
+		return fmt.Errorf("cannot unmarshal %q: %w", b, err)
+	}
+	return nil
+}
+
+// Series represents a series item from DataDog POST request.
+//
+// See https://docs.datadoghq.com/api/latest/metrics/#submit-metrics
+type Series struct {
+	Host string `json:"host"`
+
+	// Device isn't documented in DataDog API, but it is sent by DataDog agent
+	// instead of the `device` tag.
+	Device string `json:"device"`
+
+	Metric string   `json:"metric"`
+	Points []Point  `json:"points"`
+	Tags   []string `json:"tags"`
+
+	// Interval and Type aren't decoded, since they aren't used by VictoriaMetrics.
+}
+
+func (s *Series) reset() {
+	s.Host = ""
+	s.Device = ""
+	s.Metric = ""
+	s.Points = s.Points[:0]
+
+	tags := s.Tags
+	for i := range tags {
+		tags[i] = ""
+	}
+	s.Tags = tags[:0]
+}
+
+// Point represents a point from DataDog POST request.
+//
+// The first item is the timestamp in seconds, while the second item is the value.
+type Point [2]float64
+
+// Timestamp returns timestamp in milliseconds from the given pt.
+func (pt *Point) Timestamp() int64 {
+	return int64(pt[0] * 1000)
+}
+
+// Value returns value from the given pt.
+func (pt *Point) Value() float64 {
+	return pt[1]
+}
@@ -0,0 +1,286 @@
+package datadog
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestSplitTag(t *testing.T) {
+	f := func(s, nameExpected, valueExpected string) {
+		t.Helper()
+		name, value := SplitTag(s)
+		if name != nameExpected {
+			t.Fatalf("unexpected name obtained from %q; got %q; want %q", s, name, nameExpected)
+		}
+		if value != valueExpected {
+			t.Fatalf("unexpected value obtained from %q; got %q; want %q", s, value, valueExpected)
+		}
+	}
+	f("", "", "no_label_value")
+	f("foo", "foo", "no_label_value")
+	f("foo:bar", "foo", "bar")
+	f("foo:bar:baz", "foo", "bar:baz")
+	f("foo:", "foo", "")
+	f(":bar", "", "bar")
+	f(":", "", "")
+}
+
+func TestSanitizeMetricName(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+		result := SanitizeMetricName(s)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for SanitizeMetricName(%q); got %q; want %q", s, result, resultExpected)
+		}
+	}
+	f("", "")
+	f("foo_bar:baz", "foo_bar:baz")
+	f("system.load.1", "system_load_1")
+	f("foo-bar/baz", "foo_bar_baz")
+}
+
+func TestRequestUnmarshalFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		var req Request
+		if err := req.Unmarshal([]byte(s)); err == nil {
+			t.Fatalf("expecting non-nil error for Unmarshal(%q)", s)
+		}
+	}
+	f("")
+	f("foobar")
+	f(`{"series":123`)
+	f(`1234`)
+	f(`[]`)
+	f(`{"series":[{"points":[[1,"foo"]]}]}`)
+}
+
+func TestRequestUnmarshalSuccess(t *testing.T) {
+	f := func(s string, reqExpected *Request) {
+		t.Helper()
+		var req Request
+		if err := req.Unmarshal([]byte(s)); err != nil {
+			t.Fatalf("unexpected error in Unmarshal(%q): %s", s, err)
+		}
+		if !reflect.DeepEqual(req.Series, reqExpected.Series) {
+			t.Fatalf("unexpected row;\ngot\n%+v\nwant\n%+v", &req, reqExpected)
+		}
+	}
+	f("{}", &Request{})
+	f(`
+{
+  "series": [
+    {
+      "host": "test.example.com",
+      "interval": 20,
+      "metric": "system.load.1",
+      "device": "/dev/sda",
+      "points": [[
+        1575317847,
+        0.5
+      ]],
+      "tags": [
+        "environment:test"
+      ],
+      "type": "rate"
+    }
+  ]
+}
+`, &Request{
+		Series: []Series{{
+			Host:   "test.example.com",
+			Device: "/dev/sda",
+			Metric: "system.load.1",
+			Points: []Point{{
+				1575317847,
+				0.5,
+			}},
+			Tags: []string{
+				"environment:test",
+			},
+		}},
+	})
+}
+
+func TestRequestUnmarshalMissingFieldsReset(t *testing.T) {
+	var req Request
+	if err := req.Unmarshal([]byte(`{"series":[{"host":"foo","device":"bar","metric":"baz","tags":["a:b"]}]}`)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The second request has no host, device and tags. Make sure they aren't inherited from the previous request.
+	if err := req.Unmarshal([]byte(`{"series":[{"metric":"qwe"}]}`)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(req.Series) != 1 {
+		t.Fatalf("unexpected number of series; got %d; want 1", len(req.Series))
+	}
+	s := &req.Series[0]
+	if s.Host != "" || s.Device != "" || s.Metric != "qwe" || len(s.Points) != 0 || len(s.Tags) != 0 {
+		t.Fatalf("unexpected series: %+v", s)
+	}
+}
+
+func TestRequestUnmarshalV2JSON(t *testing.T) {
+	var req Request
+	s := `{
+  "series": [
+    {
+      "metric": "system.load.1",
+      "type": 0,
+      "points": [
+        {
+          "timestamp": 1636629071,
+          "value": 0.7
+        }
+      ],
+      "resources": [
+        {
+          "name": "dummyhost",
+          "type": "host"
+        },
+        {
+          "name": "nvme0",
+          "type": "device"
+        }
+      ],
+      "tags": ["env:prod"]
+    }
+  ]
+}`
+	if err := req.UnmarshalV2([]byte(s), false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	seriesExpected := []Series{{
+		Host:   "dummyhost",
+		Metric: "system.load.1",
+		Points: []Point{{1636629071, 0.7}},
+		Tags:   []string{"env:prod", "device:nvme0"},
+	}}
+	if !reflect.DeepEqual(req.Series, seriesExpected) {
+		t.Fatalf("unexpected series;\ngot\n%+v\nwant\n%+v", req.Series, seriesExpected)
+	}
+
+	if err := req.UnmarshalV2([]byte(`{"series":[{"metric":"foo","points":"bar"}]}`), false); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestRequestUnmarshalV2Protobuf(t *testing.T) {
+	var point []byte
+	point = protowire.AppendTag(point, 1, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(1.5))
+	point = protowire.AppendTag(point, 2, protowire.VarintType)
+	point = protowire.AppendVarint(point, 1636629071)
+
+	var resource []byte
+	resource = protowire.AppendTag(resource, 1, protowire.BytesType)
+	resource = protowire.AppendString(resource, "host")
+	resource = protowire.AppendTag(resource, 2, protowire.BytesType)
+	resource = protowire.AppendString(resource, "dummyhost")
+
+	var series []byte
+	series = protowire.AppendTag(series, 1, protowire.BytesType)
+	series = protowire.AppendBytes(series, resource)
+	series = protowire.AppendTag(series, 2, protowire.BytesType)
+	series = protowire.AppendString(series, "system.load.1")
+	series = protowire.AppendTag(series, 3, protowire.BytesType)
+	series = protowire.AppendString(series, "env:prod")
+	series = protowire.AppendTag(series, 4, protowire.BytesType)
+	series = protowire.AppendBytes(series, point)
+	series = protowire.AppendTag(series, 5, protowire.VarintType)
+	series = protowire.AppendVarint(series, 3)
+
+	var payload []byte
+	payload = protowire.AppendTag(payload, 1, protowire.BytesType)
+	payload = protowire.AppendBytes(payload, series)
+
+	var req Request
+	if err := req.UnmarshalV2(payload, true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	seriesExpected := []Series{{
+		Host:   "dummyhost",
+		Metric: "system.load.1",
+		Points: []Point{{1636629071, 1.5}},
+		Tags:   []string{"env:prod"},
+	}}
+	if !reflect.DeepEqual(req.Series, seriesExpected) {
+		t.Fatalf("unexpected series;\ngot\n%+v\nwant\n%+v", req.Series, seriesExpected)
+	}
+
+	// Truncated payload
+	if err := req.UnmarshalV2(payload[:len(payload)-3], true); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestRequestUnmarshalSketches(t *testing.T) {
+	var ds []byte
+	ds = protowire.AppendTag(ds, 1, protowire.VarintType)
+	ds = protowire.AppendVarint(ds, 1636629071)
+	ds = protowire.AppendTag(ds, 2, protowire.VarintType)
+	ds = protowire.AppendVarint(ds, 3)
+	ds = protowire.AppendTag(ds, 6, protowire.Fixed64Type)
+	ds = protowire.AppendFixed64(ds, math.Float64bits(4.5))
+	var keys []byte
+	keys = protowire.AppendVarint(keys, protowire.EncodeZigZag(0))
+	keys = protowire.AppendVarint(keys, protowire.EncodeZigZag(1338))
+	ds = protowire.AppendTag(ds, 7, protowire.BytesType)
+	ds = protowire.AppendBytes(ds, keys)
+	var counts []byte
+	counts = protowire.AppendVarint(counts, 1)
+	counts = protowire.AppendVarint(counts, 2)
+	ds = protowire.AppendTag(ds, 8, protowire.BytesType)
+	ds = protowire.AppendBytes(ds, counts)
+
+	var sketch []byte
+	sketch = protowire.AppendTag(sketch, 1, protowire.BytesType)
+	sketch = protowire.AppendString(sketch, "request.latency")
+	sketch = protowire.AppendTag(sketch, 2, protowire.BytesType)
+	sketch = protowire.AppendString(sketch, "dummyhost")
+	sketch = protowire.AppendTag(sketch, 4, protowire.BytesType)
+	sketch = protowire.AppendString(sketch, "env:prod")
+	sketch = protowire.AppendTag(sketch, 7, protowire.BytesType)
+	sketch = protowire.AppendBytes(sketch, ds)
+
+	var payload []byte
+	payload = protowire.AppendTag(payload, 1, protowire.BytesType)
+	payload = protowire.AppendBytes(payload, sketch)
+
+	var req Request
+	if err := req.UnmarshalSketches(payload); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	seriesExpected := []Series{
+		{
+			Host:   "dummyhost",
+			Metric: "request.latency_sum",
+			Points: []Point{{1636629071, 4.5}},
+			Tags:   []string{"env:prod"},
+		},
+		{
+			Host:   "dummyhost",
+			Metric: "request.latency_count",
+			Points: []Point{{1636629071, 3}},
+			Tags:   []string{"env:prod"},
+		},
+		{
+			Host:   "dummyhost",
+			Metric: "request.latency_bucket",
+			Points: []Point{{1636629071, 1}},
+			Tags:   []string{"env:prod", "vmrange:0...1.000e-09"},
+		},
+		{
+			Host:   "dummyhost",
+			Metric: "request.latency_bucket",
+			Points: []Point{{1636629071, 2}},
+			Tags:   []string{"env:prod", "vmrange:1.000e+00...1.016e+00"},
+		},
+	}
+	if !reflect.DeepEqual(req.Series, seriesExpected) {
+		t.Fatalf("unexpected series;\ngot\n%+v\nwant\n%+v", req.Series, seriesExpected)
+	}
+}
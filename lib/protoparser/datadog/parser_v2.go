@@ -0,0 +1,181 @@
+package datadog
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// UnmarshalV2 unmarshals DataDog /api/v2/series request body from b to req.
+//
+// The body is expected in protobuf if isProtobuf is set. Otherwise it is expected in JSON.
+//
+// See https://docs.datadoghq.com/api/latest/metrics/#submit-metrics
+//
+// b shouldn't be modified when req is in use.
+func (req *Request) UnmarshalV2(b []byte, isProtobuf bool) error {
+	req.reset()
+	if isProtobuf {
+		if err := req.unmarshalMetricPayload(b); err != nil {
+			return fmt.Errorf("cannot unmarshal protobuf MetricPayload with size %d bytes: %w", len(b), err)
+		}
+		return nil
+	}
+	v2 := &req.v2
+	if err := json.Unmarshal(b, v2); err != nil {
+		return fmt.Errorf("cannot unmarshal %q: %w", b, err)
+	}
+	for i := range v2.Series {
+		req.Series = appendSeriesV2(req.Series, &v2.Series[i])
+	}
+	return nil
+}
+
+type requestV2JSON struct {
+	Series []seriesV2JSON `json:"series"`
+}
+
+func (r *requestV2JSON) reset() {
+	series := r.Series
+	for i := range series {
+		series[i].reset()
+	}
+	r.Series = series[:0]
+}
+
+type seriesV2JSON struct {
+	Metric    string         `json:"metric"`
+	Points    []pointV2JSON  `json:"points"`
+	Resources []resourceJSON `json:"resources"`
+	Tags      []string       `json:"tags"`
+}
+
+func (s *seriesV2JSON) reset() {
+	s.Metric = ""
+	s.Points = s.Points[:0]
+	s.Resources = s.Resources[:0]
+	s.Tags = s.Tags[:0]
+}
+
+type pointV2JSON struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type resourceJSON struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func appendSeriesV2(dst []Series, src *seriesV2JSON) []Series {
+	s := nextSeries(&dst)
+	s.Metric = src.Metric
+	for _, pt := range src.Points {
+		s.Points = append(s.Points, Point{float64(pt.Timestamp), pt.Value})
+	}
+	s.Tags = append(s.Tags, src.Tags...)
+	for _, r := range src.Resources {
+		s.addResource(r.Type, r.Name)
+	}
+	return dst
+}
+
+// addResource adds the given resource to s.
+//
+// The `host` resource is stored in s.Host, while the rest of resources are stored as `type:name` tags.
+func (s *Series) addResource(typ, name string) {
+	if typ == "host" {
+		s.Host = name
+		return
+	}
+	s.Tags = append(s.Tags, typ+":"+name)
+}
+
+// nextSeries appends an empty Series to dst and returns a pointer to it.
+//
+// It re-uses the already allocated Series entries if possible.
+func nextSeries(dst *[]Series) *Series {
+	ss := *dst
+	if cap(ss) > len(ss) {
+		ss = ss[:len(ss)+1]
+	} else {
+		ss = append(ss, Series{})
+	}
+	*dst = ss
+	s := &ss[len(ss)-1]
+	s.reset()
+	return s
+}
+
+// unmarshalMetricPayload unmarshals MetricPayload protobuf message from b.
+//
+// See https://github.com/DataDog/agent-payload/blob/master/proto/metrics/agent_payload.proto
+func (req *Request) unmarshalMetricPayload(b []byte) error {
+	return iterateProtobufFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		// repeated MetricSeries series = 1
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		s := nextSeries(&req.Series)
+		if err := s.unmarshalMetricSeries(v); err != nil {
+			return fmt.Errorf("cannot unmarshal MetricSeries: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *Series) unmarshalMetricSeries(b []byte) error {
+	return iterateProtobufFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			// repeated Resource resources = 1
+			var resType, resName string
+			err := iterateProtobufFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if typ != protowire.BytesType {
+					return nil
+				}
+				switch num {
+				case 1:
+					resType = bytesutil.ToUnsafeString(v)
+				case 2:
+					resName = bytesutil.ToUnsafeString(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal Resource: %w", err)
+			}
+			s.addResource(resType, resName)
+		case 2:
+			// string metric = 2
+			s.Metric = bytesutil.ToUnsafeString(v)
+		case 3:
+			// repeated string tags = 3
+			s.Tags = append(s.Tags, bytesutil.ToUnsafeString(v))
+		case 4:
+			// repeated MetricPoint points = 4
+			var pt Point
+			err := iterateProtobufFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				switch {
+				case num == 1 && typ == protowire.Fixed64Type:
+					// double value = 1
+					pt[1] = unmarshalDouble(v)
+				case num == 2 && typ == protowire.VarintType:
+					// int64 timestamp = 2
+					pt[0] = float64(int64(unmarshalVarint(v)))
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal MetricPoint: %w", err)
+			}
+			s.Points = append(s.Points, pt)
+		}
+		return nil
+	})
+}
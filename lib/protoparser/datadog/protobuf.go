@@ -0,0 +1,65 @@
+package datadog
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// iterateProtobufFields calls f for every field in the protobuf message b.
+//
+// v contains the payload for length-delimited fields and the encoded value for the rest of field types.
+func iterateProtobufFields(b []byte, f func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("cannot read field tag: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return fmt.Errorf("cannot read value for field #%d: %w", num, protowire.ParseError(n))
+		}
+		v := b[:n]
+		b = b[n:]
+		if typ == protowire.BytesType {
+			v, _ = protowire.ConsumeBytes(v)
+		}
+		if err := f(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendVarints appends varint values from v to dst.
+//
+// v may contain either a single varint value or packed repeated varint values.
+func appendVarints(dst []uint64, typ protowire.Type, v []byte) ([]uint64, error) {
+	if typ == protowire.VarintType {
+		return append(dst, unmarshalVarint(v)), nil
+	}
+	if typ != protowire.BytesType {
+		return dst, fmt.Errorf("unexpected wire type for varint field: %d", typ)
+	}
+	for len(v) > 0 {
+		n, size := protowire.ConsumeVarint(v)
+		if size < 0 {
+			return dst, fmt.Errorf("cannot read packed varint: %w", protowire.ParseError(size))
+		}
+		dst = append(dst, n)
+		v = v[size:]
+	}
+	return dst, nil
+}
+
+func unmarshalVarint(v []byte) uint64 {
+	n, _ := protowire.ConsumeVarint(v)
+	return n
+}
+
+func unmarshalDouble(v []byte) float64 {
+	n, _ := protowire.ConsumeFixed64(v)
+	return math.Float64frombits(n)
+}
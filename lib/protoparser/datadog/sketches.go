@@ -0,0 +1,198 @@
+package datadog
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// UnmarshalSketches unmarshals DataDog /api/beta/sketches request body from b to req.
+//
+// Every sketch is converted into a VictoriaMetrics histogram with the following series:
+//
+//	<metric>_bucket{vmrange="<start>...<end>"}
+//	<metric>_sum
+//	<metric>_count
+//
+// See https://valyala.medium.com/improving-histogram-usability-for-prometheus-and-grafana-bc7e5df0e350
+//
+// b shouldn't be modified when req is in use.
+func (req *Request) UnmarshalSketches(b []byte) error {
+	req.reset()
+	if err := req.unmarshalSketchPayload(b); err != nil {
+		return fmt.Errorf("cannot unmarshal protobuf SketchPayload with size %d bytes: %w", len(b), err)
+	}
+	return nil
+}
+
+// unmarshalSketchPayload unmarshals SketchPayload protobuf message from b.
+//
+// See https://github.com/DataDog/agent-payload/blob/master/proto/metrics/agent_payload.proto
+func (req *Request) unmarshalSketchPayload(b []byte) error {
+	var sk sketch
+	return iterateProtobufFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		// repeated Sketch sketches = 1
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		sk.reset()
+		if err := sk.unmarshalProtobuf(v); err != nil {
+			return fmt.Errorf("cannot unmarshal Sketch: %w", err)
+		}
+		req.Series = sk.appendSeries(req.Series)
+		return nil
+	})
+}
+
+type sketch struct {
+	metric      string
+	host        string
+	tags        []string
+	dogsketches []dogsketch
+}
+
+func (sk *sketch) reset() {
+	sk.metric = ""
+	sk.host = ""
+	sk.tags = sk.tags[:0]
+	sk.dogsketches = sk.dogsketches[:0]
+}
+
+func (sk *sketch) unmarshalProtobuf(b []byte) error {
+	return iterateProtobufFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			// string metric = 1
+			sk.metric = bytesutil.ToUnsafeString(v)
+		case 2:
+			// string host = 2
+			sk.host = bytesutil.ToUnsafeString(v)
+		case 4:
+			// repeated string tags = 4
+			sk.tags = append(sk.tags, bytesutil.ToUnsafeString(v))
+		case 7:
+			// repeated Dogsketch dogsketches = 7
+			sk.dogsketches = append(sk.dogsketches, dogsketch{})
+			ds := &sk.dogsketches[len(sk.dogsketches)-1]
+			if err := ds.unmarshalProtobuf(v); err != nil {
+				return fmt.Errorf("cannot unmarshal Dogsketch: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (sk *sketch) appendSeries(dst []Series) []Series {
+	for i := range sk.dogsketches {
+		ds := &sk.dogsketches[i]
+		ts := float64(ds.ts)
+		dst = sk.appendSingleSeries(dst, "_sum", "", ts, ds.sum)
+		dst = sk.appendSingleSeries(dst, "_count", "", ts, float64(ds.cnt))
+		for j, k := range ds.k {
+			if j >= len(ds.n) {
+				break
+			}
+			dst = sk.appendSingleSeries(dst, "_bucket", binVMRange(k), ts, float64(ds.n[j]))
+		}
+	}
+	return dst
+}
+
+func (sk *sketch) appendSingleSeries(dst []Series, suffix, vmrange string, ts, value float64) []Series {
+	s := nextSeries(&dst)
+	s.Host = sk.host
+	s.Metric = sk.metric + suffix
+	s.Tags = append(s.Tags, sk.tags...)
+	if vmrange != "" {
+		s.Tags = append(s.Tags, "vmrange:"+vmrange)
+	}
+	s.Points = append(s.Points, Point{ts, value})
+	return dst
+}
+
+// dogsketch represents Dogsketch protobuf message.
+type dogsketch struct {
+	ts  int64
+	cnt int64
+	sum float64
+
+	// k contains bin keys, while n contains the number of samples in the corresponding bins.
+	k []int32
+	n []uint32
+}
+
+func (ds *dogsketch) unmarshalProtobuf(b []byte) error {
+	var buf []uint64
+	var err error
+	return iterateProtobufFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch num {
+		case 1:
+			// int64 ts = 1
+			ds.ts = int64(unmarshalVarint(v))
+		case 2:
+			// int64 cnt = 2
+			ds.cnt = int64(unmarshalVarint(v))
+		case 6:
+			// double sum = 6
+			if typ == protowire.Fixed64Type {
+				ds.sum = unmarshalDouble(v)
+			}
+		case 7:
+			// repeated sint32 k = 7
+			buf, err = appendVarints(buf[:0], typ, v)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal k: %w", err)
+			}
+			for _, x := range buf {
+				ds.k = append(ds.k, int32(protowire.DecodeZigZag(x)))
+			}
+		case 8:
+			// repeated uint32 n = 8
+			buf, err = appendVarints(buf[:0], typ, v)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal n: %w", err)
+			}
+			for _, x := range buf {
+				ds.n = append(ds.n, uint32(x))
+			}
+		}
+		return nil
+	})
+}
+
+// The following constants must be in sync with the DataDog agent sketch config.
+//
+// See https://github.com/DataDog/datadog-agent/blob/main/pkg/quantile/config.go
+const (
+	sketchEps      = 1.0 / 128
+	sketchMinValue = 1e-9
+)
+
+var (
+	sketchGammaLn = math.Log1p(2 * sketchEps)
+	sketchBias    = 1 - int(math.Floor(math.Log(sketchMinValue)/sketchGammaLn))
+)
+
+// binVMRange returns vmrange label value for the sketch bin with the key k.
+func binVMRange(k int32) string {
+	switch {
+	case k == 0:
+		return fmt.Sprintf("0...%.3e", sketchMinValue)
+	case k < 0:
+		start, end := binBounds(-k)
+		return fmt.Sprintf("%.3e...%.3e", -end, -start)
+	default:
+		start, end := binBounds(k)
+		return fmt.Sprintf("%.3e...%.3e", start, end)
+	}
+}
+
+func binBounds(k int32) (float64, float64) {
+	exp := float64(int(k) - sketchBias)
+	return math.Exp(exp * sketchGammaLn), math.Exp((exp + 1) * sketchGammaLn)
+}
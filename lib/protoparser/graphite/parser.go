@@ -57,7 +57,7 @@ func (r *Row) reset() {
 
 // UnmarshalMetricAndTags unmarshals metric and optional tags from s.
 func (r *Row) UnmarshalMetricAndTags(s string, tagsPool []Tag) ([]Tag, error) {
-	if strings.Contains(s, " ") {
+	if indexWhitespace(s) >= 0 {
 		return tagsPool, fmt.Errorf("unexpected whitespace found in %q", s)
 	}
 	n := strings.IndexByte(s, ';')
@@ -84,19 +84,19 @@ func (r *Row) UnmarshalMetricAndTags(s string, tagsPool []Tag) ([]Tag, error) {
 
 func (r *Row) unmarshal(s string, tagsPool []Tag) ([]Tag, error) {
 	r.reset()
-	n := strings.IndexByte(s, ' ')
+	n := indexWhitespace(s)
 	if n < 0 {
 		return tagsPool, fmt.Errorf("cannot find whitespace between metric and value in %q", s)
 	}
 	metricAndTags := s[:n]
-	tail := s[n+1:]
+	tail := trimWhitespace(s[n+1:])
 
 	tagsPool, err := r.UnmarshalMetricAndTags(metricAndTags, tagsPool)
 	if err != nil {
 		return tagsPool, err
 	}
 
-	n = strings.IndexByte(tail, ' ')
+	n = indexWhitespace(tail)
 	if n < 0 {
 		// There is no timestamp. Use default timestamp instead.
 		v, err := fastfloat.Parse(tail)
@@ -110,15 +110,29 @@ func (r *Row) unmarshal(s string, tagsPool []Tag) ([]Tag, error) {
 	if err != nil {
 		return tagsPool, fmt.Errorf("cannot unmarshal value from %q: %w", tail[:n], err)
 	}
-	ts, err := fastfloat.Parse(tail[n+1:])
+	tail = trimWhitespace(tail[n+1:])
+	ts, err := fastfloat.Parse(tail)
 	if err != nil {
-		return tagsPool, fmt.Errorf("cannot unmarshal timestamp from %q: %w", tail[n+1:], err)
+		return tagsPool, fmt.Errorf("cannot unmarshal timestamp from %q: %w", tail, err)
 	}
 	r.Value = v
 	r.Timestamp = int64(ts)
 	return tagsPool, nil
 }
 
+// indexWhitespace returns the index of the first space or tab char in s.
+//
+// Graphite relays such as go-carbon or statsite may delimit line fields with tabs
+// or with multiple spaces, so both are accepted.
+func indexWhitespace(s string) int {
+	return strings.IndexAny(s, " \t")
+}
+
+// trimWhitespace removes leading and trailing spaces and tabs from s.
+func trimWhitespace(s string) string {
+	return strings.Trim(s, " \t")
+}
+
 func unmarshalRows(dst []Row, s string, tagsPool []Tag) ([]Row, []Tag) {
 	for len(s) > 0 {
 		n := strings.IndexByte(s, '\n')
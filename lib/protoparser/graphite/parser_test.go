@@ -25,6 +25,7 @@ func TestUnmarshalMetricAndTagsFailure(t *testing.T) {
 	f("foo;bar= baz")
 	f("foo;bar=b az")
 	f("foo;b ar=baz")
+	f("foo;bar=b\taz")
 }
 
 func TestUnmarshalMetricAndTagsSuccess(t *testing.T) {
@@ -223,6 +224,32 @@ func TestRowsUnmarshalSuccess(t *testing.T) {
 		}},
 	})
 
+	// Tabs and multiple spaces between fields
+	f("foo;bar=baz\t1\t2", &Rows{
+		Rows: []Row{{
+			Metric: "foo",
+			Tags: []Tag{{
+				Key:   "bar",
+				Value: "baz",
+			}},
+			Value:     1,
+			Timestamp: 2,
+		}},
+	})
+	f("foo.bar  \t 1.5   2  ", &Rows{
+		Rows: []Row{{
+			Metric:    "foo.bar",
+			Value:     1.5,
+			Timestamp: 2,
+		}},
+	})
+	f("foo.bar  1.5\t", &Rows{
+		Rows: []Row{{
+			Metric: "foo.bar",
+			Value:  1.5,
+		}},
+	})
+
 	// Multi lines
 	f("foo 0.3 2\naaa 3\nbar.baz 0.34 43\n", &Rows{
 		Rows: []Row{
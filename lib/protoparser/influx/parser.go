@@ -53,6 +53,11 @@ type Row struct {
 	Tags        []Tag
 	Fields      []Field
 	Timestamp   int64
+
+	// Nanos is the sub-millisecond part of the Timestamp in nanoseconds.
+	//
+	// It is set by ParseStream if the data is sent with sub-millisecond precision.
+	Nanos int32
 }
 
 func (r *Row) reset() {
@@ -60,6 +65,7 @@ func (r *Row) reset() {
 	r.Tags = nil
 	r.Fields = nil
 	r.Timestamp = 0
+	r.Nanos = 0
 }
 
 func (r *Row) unmarshal(s string, tagsPool []Tag, fieldsPool []Field, noEscapeChars bool) ([]Tag, []Field, error) {
@@ -195,6 +195,10 @@ func (uw *unmarshalWork) Unmarshal() {
 			if row.Timestamp == 0 {
 				row.Timestamp = currentTs
 			} else {
+				if tsMultiplier > 1 && row.Timestamp > 0 {
+					// Preserve the sub-millisecond part of the timestamp for metrics with nanosecond timestamps.
+					row.Nanos = int32(row.Timestamp % tsMultiplier * (1e6 / tsMultiplier))
+				}
 				row.Timestamp /= tsMultiplier
 			}
 		}
@@ -216,6 +220,7 @@ func (uw *unmarshalWork) Unmarshal() {
 		for i := range rows {
 			row := &rows[i]
 			row.Timestamp -= row.Timestamp % tsTrim
+			row.Nanos = 0
 		}
 	}
 
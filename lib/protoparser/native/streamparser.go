@@ -9,7 +9,6 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
-	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/metrics"
@@ -50,15 +49,31 @@ func ParseStream(req *http.Request, callback func(block *Block) error) error {
 		callbackErrLock sync.Mutex
 		callbackErr     error
 	)
+	hasCallbackError := func() bool {
+		callbackErrLock.Lock()
+		ok := callbackErr != nil
+		callbackErrLock.Unlock()
+		return ok
+	}
 	for {
+		if hasCallbackError() {
+			// Stop reading the remaining data, since it cannot be processed anyway.
+			wg.Wait()
+			return callbackErr
+		}
 		uw := getUnmarshalWork()
 		uw.tr = tr
-		uw.callback = func(block *Block) {
-			if err := callback(block); err != nil {
-				processErrors.Inc()
+		uw.callback = func(block *Block, err error) {
+			if err == nil {
+				if err = callback(block); err != nil {
+					processErrors.Inc()
+					err = fmt.Errorf("error when processing native block: %w", err)
+				}
+			}
+			if err != nil {
 				callbackErrLock.Lock()
 				if callbackErr == nil {
-					callbackErr = fmt.Errorf("error when processing native block: %w", err)
+					callbackErr = err
 				}
 				callbackErrLock.Unlock()
 			}
@@ -124,12 +139,31 @@ type Block struct {
 	MetricName storage.MetricName
 	Values     []float64
 	Timestamps []int64
+
+	// Nanos contains sub-millisecond parts of Timestamps in nanoseconds.
+	//
+	// It is empty if the block has no nanosecond timestamps.
+	Nanos []int32
+
+	// Histograms contains native histograms for Values. Empty histograms correspond to ordinary samples.
+	//
+	// It is empty if the block has no native histograms.
+	Histograms [][]byte
 }
 
 func (b *Block) reset() {
 	b.MetricName.Reset()
 	b.Values = b.Values[:0]
 	b.Timestamps = b.Timestamps[:0]
+	b.Nanos = b.Nanos[:0]
+	b.resetHistograms()
+}
+
+func (b *Block) resetHistograms() {
+	for i := range b.Histograms {
+		b.Histograms[i] = nil
+	}
+	b.Histograms = b.Histograms[:0]
 }
 
 var (
@@ -144,7 +178,7 @@ var (
 
 type unmarshalWork struct {
 	tr            storage.TimeRange
-	callback      func(block *Block)
+	callback      func(block *Block, err error)
 	metricNameBuf []byte
 	blockBuf      []byte
 	block         Block
@@ -161,11 +195,12 @@ func (uw *unmarshalWork) reset() {
 func (uw *unmarshalWork) Unmarshal() {
 	if err := uw.unmarshal(); err != nil {
 		parseErrors.Inc()
-		logger.Errorf("error when unmarshaling native block: %s", err)
+		// Pass the error to the callback, so ParseStream doesn't wait for the block forever.
+		uw.callback(nil, fmt.Errorf("error when unmarshaling native block: %w", err))
 		putUnmarshalWork(uw)
 		return
 	}
-	uw.callback(&uw.block)
+	uw.callback(&uw.block, nil)
 	putUnmarshalWork(uw)
 }
 
@@ -183,7 +218,25 @@ func (uw *unmarshalWork) unmarshal() error {
 	if len(tail) > 0 {
 		return fmt.Errorf("unexpected non-empty tail left after unmarshaling native block from %d bytes; len(tail)=%d bytes", len(uw.blockBuf), len(tail))
 	}
-	block.Timestamps, block.Values = tmpBlock.AppendRowsWithTimeRangeFilter(block.Timestamps[:0], block.Values[:0], uw.tr)
+	block.Nanos = block.Nanos[:0]
+	if !tmpBlock.HasNanosecondTimestamps() {
+		block.Timestamps, block.Values = tmpBlock.AppendRowsWithTimeRangeFilter(block.Timestamps[:0], block.Values[:0], uw.tr)
+	} else {
+		block.Timestamps, block.Values = tmpBlock.AppendRowsWithTimeRangeFilterNanos(block.Timestamps[:0], block.Values[:0], uw.tr)
+		timestamps := block.Timestamps
+		for i, ts := range timestamps {
+			nanos := ts % 1e6
+			if nanos < 0 {
+				nanos += 1e6
+			}
+			timestamps[i] = (ts - nanos) / 1e6
+			block.Nanos = append(block.Nanos, int32(nanos))
+		}
+	}
+	block.resetHistograms()
+	if tmpBlock.HasHistograms() {
+		block.Histograms = tmpBlock.AppendHistogramsWithTimeRangeFilter(block.Histograms, uw.tr)
+	}
 	rowsRead.Add(len(block.Timestamps))
 	return nil
 }
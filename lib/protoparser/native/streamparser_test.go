@@ -0,0 +1,117 @@
+package native
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestParseStream(t *testing.T) {
+	common.StartUnmarshalWorkers()
+	defer common.StopUnmarshalWorkers()
+
+	var data []byte
+	data = encoding.MarshalInt64(data, 0)
+	data = encoding.MarshalInt64(data, 1e15)
+	resultExpected := make(map[string][]int64)
+	for i := 0; i < 10; i++ {
+		var mn storage.MetricName
+		mn.MetricGroup = []byte(fmt.Sprintf("metric_%d", i))
+		mn.AddTag("job", "test")
+		timestamps := []int64{1000, 2000, 3000 + int64(i)}
+		values := []int64{1, 2, int64(i)}
+		data = appendNativeBlock(data, &mn, timestamps, values)
+		resultExpected[mn.String()] = timestamps
+	}
+
+	result := make(map[string][]int64)
+	var lock sync.Mutex
+	err := ParseStream(newRequest(t, data), func(block *Block) error {
+		lock.Lock()
+		result[block.MetricName.String()] = append([]int64{}, block.Timestamps...)
+		lock.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(result, resultExpected) {
+		t.Fatalf("unexpected result;\ngot\n%v\nwant\n%v", result, resultExpected)
+	}
+
+	// Callback error must be returned
+	err = ParseStream(newRequest(t, data), func(block *Block) error {
+		return fmt.Errorf("some error")
+	})
+	if err == nil || !strings.Contains(err.Error(), "some error") {
+		t.Fatalf("expecting callback error; got %v", err)
+	}
+}
+
+func TestParseStreamFailure(t *testing.T) {
+	common.StartUnmarshalWorkers()
+	defer common.StopUnmarshalWorkers()
+
+	f := func(data []byte) {
+		t.Helper()
+		err := ParseStream(newRequest(t, data), func(block *Block) error {
+			return nil
+		})
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// Missing time range
+	f(nil)
+	f([]byte("foo"))
+
+	var tr []byte
+	tr = encoding.MarshalInt64(tr, 0)
+	tr = encoding.MarshalInt64(tr, 1e15)
+
+	// Truncated block
+	var mn storage.MetricName
+	mn.MetricGroup = []byte("foo")
+	data := appendNativeBlock(tr, &mn, []int64{1, 2, 3}, []int64{4, 5, 6})
+	f(data[:len(data)-3])
+
+	// Corrupted block
+	var dst []byte
+	dst = append(dst, tr...)
+	mnBuf := mn.Marshal(nil)
+	dst = encoding.MarshalUint32(dst, uint32(len(mnBuf)))
+	dst = append(dst, mnBuf...)
+	dst = encoding.MarshalUint32(dst, 3)
+	dst = append(dst, "foo"...)
+	f(dst)
+}
+
+func appendNativeBlock(dst []byte, mn *storage.MetricName, timestamps, values []int64) []byte {
+	var b storage.Block
+	b.Init(&storage.TSID{}, timestamps, values, 0, 64)
+	tmp := mn.Marshal(nil)
+	dst = encoding.MarshalUint32(dst, uint32(len(tmp)))
+	dst = append(dst, tmp...)
+	tmp = b.MarshalPortable(tmp[:0])
+	dst = encoding.MarshalUint32(dst, uint32(len(tmp)))
+	dst = append(dst, tmp...)
+	return dst
+}
+
+func newRequest(t *testing.T, data []byte) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/api/v1/import/native", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("cannot create request: %s", err)
+	}
+	return req
+}
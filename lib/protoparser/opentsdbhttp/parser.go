@@ -2,6 +2,7 @@ package opentsdbhttp
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -69,7 +70,13 @@ func (r *Row) unmarshal(o *fastjson.Value, tagsPool []Tag) ([]Tag, error) {
 		if err != nil {
 			return tagsPool, fmt.Errorf("invalid `timestamp` in %s: %w", o, err)
 		}
-		r.Timestamp = int64(ts)
+		if ts != math.Floor(ts) {
+			// Timestamp in seconds with fractional part such as 1346846400.123.
+			// Convert it to milliseconds, so it isn't truncated to seconds.
+			r.Timestamp = int64(math.Round(ts * 1e3))
+		} else {
+			r.Timestamp = int64(ts)
+		}
 	} else {
 		// Allow missing timestamp. It is automatically populated
 		// with the current time in this case.
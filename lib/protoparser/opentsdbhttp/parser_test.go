@@ -131,12 +131,12 @@ func TestRowsUnmarshalSuccess(t *testing.T) {
 			}},
 		}},
 	})
-	// Timestamp as float64 (it is truncated to integer)
+	// Timestamp as float64 with fractional seconds (it is converted to milliseconds)
 	f(`{"metric": "foobar", "timestamp": 17.89, "value": -123.456, "tags": {"a":"b"}}`, &Rows{
 		Rows: []Row{{
 			Metric:    "foobar",
 			Value:     -123.456,
-			Timestamp: 17,
+			Timestamp: 17890,
 			Tags: []Tag{{
 				Key:   "a",
 				Value: "b",
@@ -30,8 +30,10 @@ var (
 // callback shouldn't hold rows after returning.
 func ParseStream(req *http.Request, callback func(rows []Row) error) error {
 	readCalls.Inc()
+	// Chunked requests are transparently decoded by net/http, so req.Body contains the request payload.
 	r := req.Body
-	if req.Header.Get("Content-Encoding") == "gzip" {
+	switch req.Header.Get("Content-Encoding") {
+	case "gzip":
 		zr, err := common.GetGzipReader(r)
 		if err != nil {
 			readErrors.Inc()
@@ -39,6 +41,14 @@ func ParseStream(req *http.Request, callback func(rows []Row) error) error {
 		}
 		defer common.PutGzipReader(zr)
 		r = zr
+	case "deflate":
+		zlr, err := common.GetZlibReader(r)
+		if err != nil {
+			readErrors.Inc()
+			return fmt.Errorf("cannot read deflated http protocol data: %w", err)
+		}
+		defer common.PutZlibReader(zlr)
+		r = zlr
 	}
 
 	ctx := getStreamContext(r)
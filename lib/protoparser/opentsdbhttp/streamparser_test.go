@@ -0,0 +1,111 @@
+package opentsdbhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseStream(t *testing.T) {
+	f := func(req *http.Request, timestampsExpected []int64) {
+		t.Helper()
+		var timestamps []int64
+		err := ParseStream(req, func(rows []Row) error {
+			for _, r := range rows {
+				timestamps = append(timestamps, r.Timestamp)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps;\ngot\n%v\nwant\n%v", timestamps, timestampsExpected)
+		}
+	}
+	newRequest := func(body []byte, contentEncoding string) *http.Request {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "http://localhost/api/put", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		if contentEncoding != "" {
+			req.Header.Set("Content-Encoding", contentEncoding)
+		}
+		return req
+	}
+
+	// Array of datapoints with timestamps in seconds, milliseconds and fractional seconds
+	data := []byte(`[
+		{"metric":"foo","timestamp":1346846400,"value":1,"tags":{"host":"a"}},
+		{"metric":"foo","timestamp":1346846400123,"value":2,"tags":{"host":"a"}},
+		{"metric":"foo","timestamp":1346846400.456,"value":3,"tags":{"host":"a"}}
+	]`)
+	timestampsExpected := []int64{1346846400000, 1346846400123, 1346846400456}
+	f(newRequest(data, ""), timestampsExpected)
+
+	// Gzipped request
+	var bb bytes.Buffer
+	zw := gzip.NewWriter(&bb)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("cannot compress data: %s", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("cannot close gzip writer: %s", err)
+	}
+	f(newRequest(bb.Bytes(), "gzip"), timestampsExpected)
+
+	// Deflated request
+	bb.Reset()
+	zlw := zlib.NewWriter(&bb)
+	if _, err := zlw.Write(data); err != nil {
+		t.Fatalf("cannot compress data: %s", err)
+	}
+	if err := zlw.Close(); err != nil {
+		t.Fatalf("cannot close zlib writer: %s", err)
+	}
+	f(newRequest(bb.Bytes(), "deflate"), timestampsExpected)
+}
+
+func TestParseStreamChunked(t *testing.T) {
+	var timestamps []int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		err := ParseStream(req, func(rows []Row) error {
+			for _, r := range rows {
+				timestamps = append(timestamps, r.Timestamp)
+			}
+			return nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	// Send the request body in multiple chunks with unknown length, so it is sent with chunked transfer encoding.
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte(`[{"metric":"foo","timestamp":1346846400,"value":1},`))
+		_, _ = pw.Write([]byte(`{"metric":"foo","timestamp":1346846401000,"value":2}]`))
+		_ = pw.Close()
+	}()
+	resp, err := http.Post(s.URL+"/api/put", "application/json", pr)
+	if err != nil {
+		t.Fatalf("cannot send request: %s", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status code; got %d; want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	timestampsExpected := []int64{1346846400000, 1346846401000}
+	if !reflect.DeepEqual(timestamps, timestampsExpected) {
+		t.Fatalf("unexpected timestamps;\ngot\n%v\nwant\n%v", timestamps, timestampsExpected)
+	}
+}
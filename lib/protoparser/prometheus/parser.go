@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/valyala/fastjson/fastfloat"
 )
@@ -14,6 +15,11 @@ import (
 type Rows struct {
 	Rows []Row
 
+	// Metadata contains metric metadata parsed from `# HELP`, `# TYPE` and `# UNIT` lines.
+	//
+	// It is populated only if metadata collection is enabled via prommetadata.Init.
+	Metadata []prommetadata.Row
+
 	tagsPool []Tag
 }
 
@@ -26,6 +32,11 @@ func (rs *Rows) Reset() {
 	}
 	rs.Rows = rs.Rows[:0]
 
+	for i := range rs.Metadata {
+		rs.Metadata[i].Reset()
+	}
+	rs.Metadata = rs.Metadata[:0]
+
 	for i := range rs.tagsPool {
 		rs.tagsPool[i].reset()
 	}
@@ -52,7 +63,15 @@ func stdErrLogger(s string) {
 // s shouldn't be modified while rs is in use.
 func (rs *Rows) UnmarshalWithErrLogger(s string, errLogger func(s string)) {
 	noEscapes := strings.IndexByte(s, '\\') < 0
-	rs.Rows, rs.tagsPool = unmarshalRows(rs.Rows[:0], s, rs.tagsPool[:0], noEscapes, errLogger)
+	var mds *[]prommetadata.Row
+	if prommetadata.IsEnabled() {
+		rs.Metadata = rs.Metadata[:0]
+		mds = &rs.Metadata
+	}
+	rs.Rows, rs.tagsPool = unmarshalRows(rs.Rows[:0], s, rs.tagsPool[:0], mds, noEscapes, errLogger)
+	if mds != nil {
+		prommetadata.Add(rs.Metadata)
+	}
 }
 
 // Row is a single Prometheus row.
@@ -185,23 +204,23 @@ func (r *Row) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error)
 
 var rowsReadScrape = metrics.NewCounter(`vm_protoparser_rows_read_total{type="promscrape"}`)
 
-func unmarshalRows(dst []Row, s string, tagsPool []Tag, noEscapes bool, errLogger func(s string)) ([]Row, []Tag) {
+func unmarshalRows(dst []Row, s string, tagsPool []Tag, mds *[]prommetadata.Row, noEscapes bool, errLogger func(s string)) ([]Row, []Tag) {
 	dstLen := len(dst)
 	for len(s) > 0 {
 		n := strings.IndexByte(s, '\n')
 		if n < 0 {
 			// The last line.
-			dst, tagsPool = unmarshalRow(dst, s, tagsPool, noEscapes, errLogger)
+			dst, tagsPool = unmarshalRow(dst, s, tagsPool, mds, noEscapes, errLogger)
 			break
 		}
-		dst, tagsPool = unmarshalRow(dst, s[:n], tagsPool, noEscapes, errLogger)
+		dst, tagsPool = unmarshalRow(dst, s[:n], tagsPool, mds, noEscapes, errLogger)
 		s = s[n+1:]
 	}
 	rowsReadScrape.Add(len(dst) - dstLen)
 	return dst, tagsPool
 }
 
-func unmarshalRow(dst []Row, s string, tagsPool []Tag, noEscapes bool, errLogger func(s string)) ([]Row, []Tag) {
+func unmarshalRow(dst []Row, s string, tagsPool []Tag, mds *[]prommetadata.Row, noEscapes bool, errLogger func(s string)) ([]Row, []Tag) {
 	if len(s) > 0 && s[len(s)-1] == '\r' {
 		s = s[:len(s)-1]
 	}
@@ -211,6 +230,9 @@ func unmarshalRow(dst []Row, s string, tagsPool []Tag, noEscapes bool, errLogger
 		return dst, tagsPool
 	}
 	if s[0] == '#' {
+		if mds != nil {
+			*mds = unmarshalMetadata(*mds, s[1:])
+		}
 		// Skip comment
 		return dst, tagsPool
 	}
@@ -233,6 +255,83 @@ func unmarshalRow(dst []Row, s string, tagsPool []Tag, noEscapes bool, errLogger
 
 var invalidLines = metrics.NewCounter(`vm_rows_invalid_total{type="prometheus"}`)
 
+// unmarshalMetadata appends metadata from `# HELP`, `# TYPE` or `# UNIT` comment to dst.
+//
+// s must contain the comment without the leading '#'.
+// Consecutive comments for the same metric are merged into a single row.
+func unmarshalMetadata(dst []prommetadata.Row, s string) []prommetadata.Row {
+	s = skipLeadingWhitespace(s)
+	n := nextWhitespace(s)
+	if n < 0 {
+		return dst
+	}
+	kind := s[:n]
+	if kind != "HELP" && kind != "TYPE" && kind != "UNIT" {
+		// Ordinary comment
+		return dst
+	}
+	s = skipLeadingWhitespace(s[n+1:])
+	metric := s
+	value := ""
+	n = nextWhitespace(s)
+	if n >= 0 {
+		metric = s[:n]
+		value = skipLeadingWhitespace(s[n+1:])
+	}
+	if len(metric) == 0 {
+		return dst
+	}
+	if len(dst) == 0 || dst[len(dst)-1].Metric != metric {
+		dst = append(dst, prommetadata.Row{
+			Metric: metric,
+		})
+	}
+	md := &dst[len(dst)-1]
+	switch kind {
+	case "HELP":
+		md.Help = unescapeHelp(value)
+	case "TYPE":
+		md.Type = skipTrailingWhitespace(value)
+	case "UNIT":
+		md.Unit = skipTrailingWhitespace(value)
+	}
+	return dst
+}
+
+// unescapeHelp unescapes `\\` and `\n` sequences in HELP text.
+//
+// See https://github.com/prometheus/docs/blob/master/content/docs/instrumenting/exposition_formats.md#comments-help-text-and-type-information
+func unescapeHelp(s string) string {
+	n := strings.IndexByte(s, '\\')
+	if n < 0 {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for {
+		b = append(b, s[:n]...)
+		s = s[n+1:]
+		if len(s) == 0 {
+			b = append(b, '\\')
+			break
+		}
+		switch s[0] {
+		case '\\':
+			b = append(b, '\\')
+		case 'n':
+			b = append(b, '\n')
+		default:
+			b = append(b, '\\', s[0])
+		}
+		s = s[1:]
+		n = strings.IndexByte(s, '\\')
+		if n < 0 {
+			b = append(b, s...)
+			break
+		}
+	}
+	return string(b)
+}
+
 func unmarshalTags(dst []Tag, s string, noEscapes bool) (string, []Tag, error) {
 	for {
 		s = skipLeadingWhitespace(s)
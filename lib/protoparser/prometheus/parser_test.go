@@ -4,6 +4,8 @@ import (
 	"math"
 	"reflect"
 	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
 )
 
 func TestGetRowsDiff(t *testing.T) {
@@ -497,3 +499,47 @@ cassandra_token_ownership_ratio 78.9`, &Rows{
 		},
 	})
 }
+
+func TestRowsUnmarshalMetadata(t *testing.T) {
+	f := func(s string, mdsExpected []prommetadata.Row) {
+		t.Helper()
+		var mds []prommetadata.Row
+		unmarshalRows(nil, s, nil, &mds, false, stdErrLogger)
+		if !reflect.DeepEqual(mds, mdsExpected) {
+			t.Fatalf("unexpected metadata for %q;\ngot\n%#v\nwant\n%#v", s, mds, mdsExpected)
+		}
+	}
+	f("", nil)
+	f("foo 123", nil)
+	f("# some comment\nfoo 1", nil)
+	f("# HELP", nil)
+	f("# TYPE foo counter\nfoo 1", []prommetadata.Row{{
+		Metric: "foo",
+		Type:   "counter",
+	}})
+	f("# HELP foo Some help text\n# TYPE foo gauge\n# UNIT foo seconds\nfoo 1", []prommetadata.Row{{
+		Metric: "foo",
+		Type:   "gauge",
+		Help:   "Some help text",
+		Unit:   "seconds",
+	}})
+	f("#  HELP  foo\tmulti\\nline \\\\ help\\x\n#TYPE foo summary  \r\n", []prommetadata.Row{{
+		Metric: "foo",
+		Type:   "summary",
+		Help:   "multi\nline \\ help\\x",
+	}})
+	f("# HELP foo\n# HELP bar bar help\n# TYPE bar counter\nbar 1\n# TYPE foo gauge", []prommetadata.Row{
+		{
+			Metric: "foo",
+		},
+		{
+			Metric: "bar",
+			Type:   "counter",
+			Help:   "bar help",
+		},
+		{
+			Metric: "foo",
+			Type:   "gauge",
+		},
+	})
+}
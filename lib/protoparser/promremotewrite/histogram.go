@@ -0,0 +1,141 @@
+package promremotewrite
+
+import (
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// SetKeepNativeHistograms enables passing native histograms to ParseStream callbacks as is.
+//
+// By default native histograms are converted to VictoriaMetrics histograms. See appendHistogramSeries.
+//
+// This function must be called before parsing the data.
+func SetKeepNativeHistograms(keep bool) {
+	keepNativeHistograms = keep
+}
+
+var keepNativeHistograms bool
+
+// filterHistograms removes invalid native histograms from tss.
+//
+// Stale native histograms are left as is.
+func filterHistograms(tss []prompb.TimeSeries) {
+	var buckets []prompb.VMRangeBucket
+	for i := range tss {
+		ts := &tss[i]
+		if len(ts.Histograms) == 0 {
+			continue
+		}
+		name := getMetricName(ts.Labels)
+		if len(name) == 0 {
+			invalidHistograms.Add(len(ts.Histograms))
+			invalidHistogramsLogger.Warnf("skipping %d native histograms without metric name", len(ts.Histograms))
+			ts.Histograms = ts.Histograms[:0]
+			continue
+		}
+		hs := ts.Histograms[:0]
+		for j := range ts.Histograms {
+			h := &ts.Histograms[j]
+			if !decimal.IsStaleNaN(h.Sum) {
+				var err error
+				buckets, err = h.AppendVMRangeBuckets(buckets[:0])
+				if err != nil {
+					invalidHistograms.Inc()
+					invalidHistogramsLogger.Warnf("skipping invalid native histogram %q: %s", name, err)
+					continue
+				}
+			}
+			hs = append(hs, *h)
+		}
+		ts.Histograms = hs
+	}
+}
+
+// appendHistogramSeries converts native histograms from tss to VictoriaMetrics histograms and appends them to dst.
+//
+// Every native histogram is converted to `<name>_count`, `<name>_sum` and `<name>_bucket{vmrange="<start>...<end>"}` series.
+// See https://valyala.medium.com/improving-histogram-usability-for-prometheus-and-grafana-bc7e5df0e350
+func appendHistogramSeries(dst []prompb.TimeSeries, tss []prompb.TimeSeries) []prompb.TimeSeries {
+	var buckets []prompb.VMRangeBucket
+	for i := range tss {
+		ts := &tss[i]
+		if len(ts.Histograms) == 0 {
+			continue
+		}
+		name := getMetricName(ts.Labels)
+		if len(name) == 0 {
+			invalidHistograms.Add(len(ts.Histograms))
+			invalidHistogramsLogger.Warnf("skipping %d native histograms without metric name", len(ts.Histograms))
+			continue
+		}
+		for j := range ts.Histograms {
+			h := &ts.Histograms[j]
+			if decimal.IsStaleNaN(h.Sum) {
+				// Propagate staleness marker.
+				dst = appendSeries(dst, ts.Labels, name, "_count", "", h.Timestamp, decimal.StaleNaN)
+				dst = appendSeries(dst, ts.Labels, name, "_sum", "", h.Timestamp, decimal.StaleNaN)
+				continue
+			}
+			var err error
+			buckets, err = h.AppendVMRangeBuckets(buckets[:0])
+			if err != nil {
+				invalidHistograms.Inc()
+				invalidHistogramsLogger.Warnf("skipping invalid native histogram %q: %s", name, err)
+				continue
+			}
+			dst = appendSeries(dst, ts.Labels, name, "_count", "", h.Timestamp, h.Count)
+			dst = appendSeries(dst, ts.Labels, name, "_sum", "", h.Timestamp, h.Sum)
+			for k := range buckets {
+				b := &buckets[k]
+				dst = appendSeries(dst, ts.Labels, name, "_bucket", b.VMRange(), h.Timestamp, b.Count)
+			}
+			histogramsConverted.Inc()
+		}
+	}
+	return dst
+}
+
+func appendSeries(dst []prompb.TimeSeries, labels []prompb.Label, name []byte, suffix, vmrange string, timestamp int64, value float64) []prompb.TimeSeries {
+	labelsDst := make([]prompb.Label, 0, len(labels)+1)
+	for _, label := range labels {
+		if string(label.Name) == "__name__" {
+			label.Value = append(append([]byte{}, name...), suffix...)
+		}
+		labelsDst = append(labelsDst, label)
+	}
+	if vmrange != "" {
+		labelsDst = append(labelsDst, prompb.Label{
+			Name:  []byte("vmrange"),
+			Value: []byte(vmrange),
+		})
+	}
+	return append(dst, prompb.TimeSeries{
+		Labels: labelsDst,
+		Samples: []prompb.Sample{{
+			Value:     value,
+			Timestamp: timestamp,
+		}},
+	})
+}
+
+func getMetricName(labels []prompb.Label) []byte {
+	for _, label := range labels {
+		if string(label.Name) == "__name__" {
+			return label.Value
+		}
+	}
+	return nil
+}
+
+// invalidHistogramsLogger limits the rate of warnings about invalid native histograms,
+// since a misconfigured client may send them in every request.
+var invalidHistogramsLogger = logger.WithThrottler("promremotewrite_invalid_histograms", 5*time.Second)
+
+var (
+	histogramsConverted = metrics.NewCounter(`vm_protoparser_native_histograms_converted_total{type="promremotewrite"}`)
+	invalidHistograms   = metrics.NewCounter(`vm_protoparser_native_histograms_invalid_total{type="promremotewrite"}`)
+)
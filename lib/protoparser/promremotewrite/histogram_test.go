@@ -0,0 +1,159 @@
+package promremotewrite
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+func TestAppendHistogramSeries(t *testing.T) {
+	f := func(tss []prompb.TimeSeries, resultExpected []string) {
+		t.Helper()
+		var result []string
+		for _, ts := range appendHistogramSeries(nil, tss) {
+			result = append(result, seriesString(&ts))
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", strings.Join(result, "\n"), strings.Join(resultExpected, "\n"))
+		}
+	}
+	labels := []prompb.Label{
+		{Name: []byte("__name__"), Value: []byte("http_request_duration_seconds")},
+		{Name: []byte("job"), Value: []byte("foo")},
+	}
+
+	// Series without histograms
+	f([]prompb.TimeSeries{{
+		Labels:  labels,
+		Samples: []prompb.Sample{{Value: 1, Timestamp: 2}},
+	}}, nil)
+
+	// Integer histogram
+	f([]prompb.TimeSeries{{
+		Labels: labels,
+		Histograms: []prompb.Histogram{{
+			Count:          5,
+			Sum:            3.5,
+			Schema:         0,
+			ZeroCount:      1,
+			PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}},
+			PositiveDeltas: []int64{3, -2},
+			Timestamp:      1000,
+		}},
+	}}, []string{
+		`http_request_duration_seconds_count{job="foo"} 5 1000`,
+		`http_request_duration_seconds_sum{job="foo"} 3.5 1000`,
+		`http_request_duration_seconds_bucket{job="foo",vmrange="0...0"} 1 1000`,
+		`http_request_duration_seconds_bucket{job="foo",vmrange="0.5...1"} 3 1000`,
+		`http_request_duration_seconds_bucket{job="foo",vmrange="1...2"} 1 1000`,
+	})
+
+	// Float histogram with negative buckets
+	f([]prompb.TimeSeries{{
+		Labels: labels,
+		Histograms: []prompb.Histogram{{
+			Count:          2.5,
+			Sum:            -1,
+			Schema:         -1,
+			NegativeSpans:  []prompb.BucketSpan{{Offset: 1, Length: 1}},
+			NegativeCounts: []float64{2.5},
+			Timestamp:      2000,
+		}},
+	}}, []string{
+		`http_request_duration_seconds_count{job="foo"} 2.5 2000`,
+		`http_request_duration_seconds_sum{job="foo"} -1 2000`,
+		`http_request_duration_seconds_bucket{job="foo",vmrange="-4...-1"} 2.5 2000`,
+	})
+
+	// Stale histogram
+	f([]prompb.TimeSeries{{
+		Labels: labels,
+		Histograms: []prompb.Histogram{{
+			Sum:       decimal.StaleNaN,
+			Timestamp: 3000,
+		}},
+	}}, []string{
+		`http_request_duration_seconds_count{job="foo"} stale 3000`,
+		`http_request_duration_seconds_sum{job="foo"} stale 3000`,
+	})
+
+	// Invalid histogram and histogram without metric name are skipped
+	f([]prompb.TimeSeries{
+		{
+			Labels: labels,
+			Histograms: []prompb.Histogram{{
+				Schema: 100,
+			}},
+		},
+		{
+			Labels: labels[1:],
+			Histograms: []prompb.Histogram{{
+				Count: 1,
+			}},
+		},
+	}, nil)
+}
+
+func TestFilterHistograms(t *testing.T) {
+	labels := []prompb.Label{
+		{Name: []byte("__name__"), Value: []byte("http_request_duration_seconds")},
+	}
+	tss := []prompb.TimeSeries{
+		{
+			Labels: labels,
+			Histograms: []prompb.Histogram{
+				{Schema: 100, Timestamp: 1000},
+				{Count: 1, ZeroCount: 1, Timestamp: 2000},
+				{Sum: decimal.StaleNaN, Timestamp: 3000},
+			},
+		},
+		{
+			Labels: nil,
+			Histograms: []prompb.Histogram{{
+				Count:     1,
+				Timestamp: 1000,
+			}},
+		},
+	}
+	filterHistograms(tss)
+
+	// The invalid histogram and the histogram without metric name must be removed.
+	var timestamps []int64
+	for _, h := range tss[0].Histograms {
+		timestamps = append(timestamps, h.Timestamp)
+	}
+	if !reflect.DeepEqual(timestamps, []int64{2000, 3000}) {
+		t.Fatalf("unexpected timestamps for the remaining histograms; got %v; want %v", timestamps, []int64{2000, 3000})
+	}
+	if len(tss[1].Histograms) > 0 {
+		t.Fatalf("histograms without metric name must be removed; got %d histograms", len(tss[1].Histograms))
+	}
+}
+
+func seriesString(ts *prompb.TimeSeries) string {
+	var name string
+	var tags []string
+	for _, label := range ts.Labels {
+		if string(label.Name) == "__name__" {
+			name = string(label.Value)
+			continue
+		}
+		tags = append(tags, fmt.Sprintf("%s=%q", label.Name, label.Value))
+	}
+	var samples []string
+	for _, s := range ts.Samples {
+		v := fmt.Sprintf("%g", s.Value)
+		if decimal.IsStaleNaN(s.Value) {
+			v = "stale"
+		} else if math.IsNaN(s.Value) {
+			v = "NaN"
+		}
+		samples = append(samples, fmt.Sprintf("%s %d", v, s.Timestamp))
+	}
+	return fmt.Sprintf("%s{%s} %s", name, strings.Join(tags, ","), strings.Join(samples, ","))
+}
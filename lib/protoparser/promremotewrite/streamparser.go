@@ -4,23 +4,59 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"net/http"
 	"sync"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/golang/snappy"
 )
 
 var maxInsertRequestSize = flagutil.NewBytes("maxInsertRequestSize", 32*1024*1024, "The maximum size in bytes of a single Prometheus remote_write API request")
 
+// SupportedContentEncodings contains the supported values for Content-Encoding header in Prometheus remote_write requests.
+const SupportedContentEncodings = "snappy, zstd, gzip"
+
 // ParseStream parses Prometheus remote_write message from reader and calls callback for the parsed timeseries.
 //
+// contentEncoding must contain the value of Content-Encoding request header. The following values are supported:
+//
+//   - `snappy` or empty value - snappy block compression according to Prometheus remote_write protocol
+//   - `zstd` - zstd compression
+//   - `gzip` - gzip compression
+//
 // callback shouldn't hold tss after returning.
-func ParseStream(r io.Reader, callback func(tss []prompb.TimeSeries) error) error {
+func ParseStream(r io.Reader, contentEncoding string, callback func(tss []prompb.TimeSeries) error) error {
+	isSnappy := false
+	switch contentEncoding {
+	case "", "snappy":
+		isSnappy = true
+	case "zstd":
+		zr, err := common.GetZstdReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read zstd-compressed request: %w", err)
+		}
+		defer common.PutZstdReader(zr)
+		r = zr
+	case "gzip":
+		zr, err := common.GetGzipReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read gzipped request: %w", err)
+		}
+		defer common.PutGzipReader(zr)
+		r = zr
+	default:
+		return &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("unsupported Content-Encoding: %q; supported values: %s", contentEncoding, SupportedContentEncodings),
+			StatusCode: http.StatusUnsupportedMediaType,
+		}
+	}
 	ctx := getPushCtx(r)
 	defer putPushCtx(ctx)
 	if err := ctx.Read(); err != nil {
@@ -32,25 +68,63 @@ func ParseStream(r io.Reader, callback func(tss []prompb.TimeSeries) error) erro
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/896
 	bb := bodyBufferPool.Get()
 	defer bodyBufferPool.Put(bb)
-	var err error
-	bb.B, err = snappy.Decode(bb.B[:cap(bb.B)], ctx.reqBuf.B)
-	if err != nil {
-		return fmt.Errorf("cannot decompress request with length %d: %w", len(ctx.reqBuf.B), err)
+	data := ctx.reqBuf.B
+	if isSnappy {
+		var err error
+		bb.B, err = snappy.Decode(bb.B[:cap(bb.B)], ctx.reqBuf.B)
+		if err != nil {
+			return fmt.Errorf("cannot decompress request with length %d: %w", len(ctx.reqBuf.B), err)
+		}
+		if len(bb.B) > maxInsertRequestSize.N {
+			return fmt.Errorf("too big unpacked request; mustn't exceed `-maxInsertRequestSize=%d` bytes; got %d bytes", maxInsertRequestSize.N, len(bb.B))
+		}
+		data = bb.B
 	}
-	if len(bb.B) > maxInsertRequestSize.N {
-		return fmt.Errorf("too big unpacked request; mustn't exceed `-maxInsertRequestSize=%d` bytes; got %d bytes", maxInsertRequestSize.N, len(bb.B))
+	return parseWriteRequest(data, callback)
+}
+
+// ParseWriteRequest parses uncompressed protobuf-encoded Prometheus WriteRequest from data and calls callback for the parsed timeseries.
+//
+// It is intended for transports, which take care of message framing and compression themselves, such as gRPC.
+//
+// callback shouldn't hold tss after returning.
+func ParseWriteRequest(data []byte, callback func(tss []prompb.TimeSeries) error) error {
+	readCalls.Inc()
+	if len(data) > maxInsertRequestSize.N {
+		readErrors.Inc()
+		return fmt.Errorf("too big request; mustn't exceed `-maxInsertRequestSize=%d` bytes; got %d bytes", maxInsertRequestSize.N, len(data))
 	}
+	return parseWriteRequest(data, callback)
+}
+
+// MaxRequestSize returns the maximum size in bytes for uncompressed WriteRequest set via -maxInsertRequestSize.
+func MaxRequestSize() int {
+	return maxInsertRequestSize.N
+}
+
+func parseWriteRequest(data []byte, callback func(tss []prompb.TimeSeries) error) error {
 	wr := getWriteRequest()
 	defer putWriteRequest(wr)
-	if err := wr.Unmarshal(bb.B); err != nil {
+	if err := wr.Unmarshal(data); err != nil {
 		unmarshalErrors.Inc()
-		return fmt.Errorf("cannot unmarshal prompb.WriteRequest with size %d bytes: %w", len(bb.B), err)
+		return fmt.Errorf("cannot unmarshal prompb.WriteRequest with size %d bytes: %w", len(data), err)
 	}
 
-	rows := 0
 	tss := wr.Timeseries
+	if hasHistograms(tss) {
+		if keepNativeHistograms {
+			filterHistograms(tss)
+		} else {
+			// Native histograms are converted to VictoriaMetrics histograms, so they are processed as ordinary samples.
+			tss = appendHistogramSeries(tss[:len(tss):len(tss)], tss)
+		}
+	}
+	rows := 0
 	for i := range tss {
 		rows += len(tss[i].Samples)
+		if keepNativeHistograms {
+			rows += len(tss[i].Histograms)
+		}
 	}
 	rowsRead.Add(rows)
 
@@ -60,6 +134,15 @@ func ParseStream(r io.Reader, callback func(tss []prompb.TimeSeries) error) erro
 	return nil
 }
 
+func hasHistograms(tss []prompb.TimeSeries) bool {
+	for i := range tss {
+		if len(tss[i].Histograms) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 var bodyBufferPool bytesutil.ByteBufferPool
 
 type pushCtx struct {
@@ -79,11 +162,11 @@ func (ctx *pushCtx) Read() error {
 	reqLen, err := ctx.reqBuf.ReadFrom(lr)
 	if err != nil {
 		readErrors.Inc()
-		return fmt.Errorf("cannot read compressed request in %d seconds: %w", fasttime.UnixTimestamp()-startTime, err)
+		return fmt.Errorf("cannot read request in %d seconds: %w", fasttime.UnixTimestamp()-startTime, err)
 	}
 	if reqLen > int64(maxInsertRequestSize.N) {
 		readErrors.Inc()
-		return fmt.Errorf("too big packed request; mustn't exceed `-maxInsertRequestSize=%d` bytes", maxInsertRequestSize.N)
+		return fmt.Errorf("too big request; mustn't exceed `-maxInsertRequestSize=%d` bytes", maxInsertRequestSize.N)
 	}
 	return nil
 }
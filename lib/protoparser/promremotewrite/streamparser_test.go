@@ -0,0 +1,125 @@
+package promremotewrite
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestParseStreamContentEncoding(t *testing.T) {
+	wr := &prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{{
+			Labels: []prompbmarshal.Label{
+				{Name: "__name__", Value: "foo"},
+				{Name: "job", Value: "bar"},
+			},
+			Samples: []prompbmarshal.Sample{
+				{Value: 1, Timestamp: 1000},
+				{Value: 2.5, Timestamp: 2000},
+			},
+		}},
+	}
+	data, err := wr.Marshal()
+	if err != nil {
+		t.Fatalf("cannot marshal WriteRequest: %s", err)
+	}
+	resultExpected := []string{`foo{job="bar"} 1 1000,2.5 2000`}
+
+	f := func(contentEncoding string, body []byte) {
+		t.Helper()
+		var result []string
+		err := ParseStream(bytes.NewReader(body), contentEncoding, func(tss []prompb.TimeSeries) error {
+			for i := range tss {
+				result = append(result, seriesString(&tss[i]))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error for Content-Encoding=%q: %s", contentEncoding, err)
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result for Content-Encoding=%q;\ngot\n%s\nwant\n%s", contentEncoding, result, resultExpected)
+		}
+	}
+
+	// snappy
+	snappyData := snappy.Encode(nil, data)
+	f("", snappyData)
+	f("snappy", snappyData)
+
+	// zstd
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("cannot create zstd writer: %s", err)
+	}
+	zstdData := zw.EncodeAll(data, nil)
+	f("zstd", zstdData)
+	// Verify the pooled zstd reader is properly reset between requests.
+	f("zstd", zstdData)
+
+	// gzip
+	var bb bytes.Buffer
+	gw := gzip.NewWriter(&bb)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatalf("cannot write gzipped data: %s", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("cannot close gzip writer: %s", err)
+	}
+	f("gzip", bb.Bytes())
+
+	// uncompressed data without framing
+	var result []string
+	err = ParseWriteRequest(data, func(tss []prompb.TimeSeries) error {
+		for i := range tss {
+			result = append(result, seriesString(&tss[i]))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error in ParseWriteRequest: %s", err)
+	}
+	if !reflect.DeepEqual(result, resultExpected) {
+		t.Fatalf("unexpected result for ParseWriteRequest;\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+}
+
+func TestParseStreamContentEncodingFailure(t *testing.T) {
+	f := func(contentEncoding string, body []byte, statusCodeExpected int) {
+		t.Helper()
+		err := ParseStream(bytes.NewReader(body), contentEncoding, func(tss []prompb.TimeSeries) error {
+			t.Fatalf("unexpected callback call")
+			return nil
+		})
+		if err == nil {
+			t.Fatalf("expecting non-nil error for Content-Encoding=%q", contentEncoding)
+		}
+		var esc *httpserver.ErrorWithStatusCode
+		statusCode := 0
+		if errors.As(err, &esc) {
+			statusCode = esc.StatusCode
+		}
+		if statusCode != statusCodeExpected {
+			t.Fatalf("unexpected status code for Content-Encoding=%q; got %d; want %d; err: %s", contentEncoding, statusCode, statusCodeExpected, err)
+		}
+	}
+
+	// Unsupported encoding
+	f("br", []byte("foo"), http.StatusUnsupportedMediaType)
+	f("deflate", []byte("foo"), http.StatusUnsupportedMediaType)
+
+	// Invalid compressed data
+	f("snappy", []byte("foobar"), 0)
+	f("zstd", []byte(strings.Repeat("foobar", 10)), 0)
+	f("gzip", []byte("foobar"), 0)
+}
@@ -0,0 +1,309 @@
+package statsd
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/valyala/histogram"
+)
+
+var (
+	flushInterval = flag.Duration("statsd.flushInterval", 10*time.Second, "The interval for flushing the aggregated statsd metrics to the storage")
+	stateTTL      = flag.Duration("statsd.stateTTL", 5*time.Minute, "The duration for keeping the aggregation state for statsd metrics without updates. "+
+		"Counters, gauges and histograms without updates during this duration are no longer flushed to the storage")
+	timerQuantiles = flagutil.NewArray("statsd.timerQuantiles", "Quantiles to calculate over timers, histograms and distributions "+
+		"received during -statsd.flushInterval. By default 0.5, 0.9 and 0.99 quantiles are calculated. Pass -statsd.timerQuantiles=none for disabling quantiles")
+)
+
+// PushFunc must push the aggregated tss to the storage.
+//
+// tss cannot be held after returning from PushFunc.
+type PushFunc func(tss []prompbmarshal.TimeSeries)
+
+// Aggregator aggregates statsd rows over -statsd.flushInterval.
+//
+// The aggregated series are passed to PushFunc after every -statsd.flushInterval:
+//
+//   - counters are converted into monotonically increasing Prometheus-like counters;
+//   - gauges are passed as is;
+//   - timers, histograms and distributions are converted into VictoriaMetrics histograms
+//     with `<metric>_bucket{vmrange="..."}`, `<metric>_sum` and `<metric>_count` series
+//     plus `<metric>{quantile="..."}` series calculated over -statsd.flushInterval;
+//   - sets are converted into gauges with the number of unique values seen during -statsd.flushInterval.
+type Aggregator struct {
+	pushFunc PushFunc
+	phis     []float64
+
+	mu sync.Mutex
+	m  map[string]*aggrState
+
+	keyBuf  []byte
+	tagsBuf []Tag
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// MustNewAggregator returns new Aggregator, which passes the aggregated series to pushFunc.
+//
+// MustStop must be called on the returned Aggregator when it is no longer needed.
+func MustNewAggregator(pushFunc PushFunc) *Aggregator {
+	phis, err := parseQuantiles(*timerQuantiles)
+	if err != nil {
+		logger.Fatalf("cannot parse -statsd.timerQuantiles: %s", err)
+	}
+	a := &Aggregator{
+		pushFunc: pushFunc,
+		phis:     phis,
+		m:        make(map[string]*aggrState),
+		stopCh:   make(chan struct{}),
+	}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.runFlusher(*flushInterval)
+	}()
+	return a
+}
+
+func parseQuantiles(a []string) ([]float64, error) {
+	if len(a) == 0 {
+		return []float64{0.5, 0.9, 0.99}, nil
+	}
+	if len(a) == 1 && a[0] == "none" {
+		return nil, nil
+	}
+	phis := make([]float64, 0, len(a))
+	for _, s := range a {
+		phi, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse quantile %q: %w", s, err)
+		}
+		if phi < 0 || phi > 1 {
+			return nil, fmt.Errorf("quantile must be in the range [0..1]; got %g", phi)
+		}
+		phis = append(phis, phi)
+	}
+	return phis, nil
+}
+
+// MustStop stops a and flushes the remaining aggregated series to PushFunc.
+func (a *Aggregator) MustStop() {
+	close(a.stopCh)
+	a.wg.Wait()
+	a.flush()
+}
+
+func (a *Aggregator) runFlusher(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.stopCh:
+			return
+		case <-t.C:
+			a.flush()
+		}
+	}
+}
+
+// Push adds rows to a.
+func (a *Aggregator) Push(rows []Row) {
+	currentTime := fasttime.UnixTimestamp()
+	a.mu.Lock()
+	for i := range rows {
+		a.pushRowLocked(&rows[i], currentTime)
+	}
+	a.mu.Unlock()
+}
+
+func (a *Aggregator) pushRowLocked(r *Row, currentTime uint64) {
+	typ := r.Type
+	if typ == TypeHistogram || typ == TypeDistribution {
+		// Timers, histograms and distributions are aggregated in the same way.
+		typ = TypeTimer
+	}
+
+	// Tags may be passed in arbitrary order, so sort them before constructing the key.
+	tags := append(a.tagsBuf[:0], r.Tags...)
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	a.tagsBuf = tags
+	key := append(a.keyBuf[:0], typ...)
+	key = append(key, 0)
+	key = append(key, r.Metric...)
+	for _, tag := range tags {
+		key = append(key, 0)
+		key = append(key, tag.Key...)
+		key = append(key, '=')
+		key = append(key, tag.Value...)
+	}
+	a.keyBuf = key
+
+	st := a.m[string(key)]
+	if st == nil {
+		st = newAggrState(typ, r.Metric, tags)
+		a.m[string(key)] = st
+	}
+	st.lastUpdate = currentTime
+	st.update(r)
+}
+
+func (a *Aggregator) flush() {
+	currentTime := fasttime.UnixTimestamp()
+	timestamp := int64(currentTime) * 1000
+	deadline := uint64(stateTTL.Seconds())
+	var tss []prompbmarshal.TimeSeries
+	a.mu.Lock()
+	for k, st := range a.m {
+		if currentTime-st.lastUpdate > deadline {
+			delete(a.m, k)
+			continue
+		}
+		tss = st.appendTimeSeries(tss, timestamp, a.phis)
+	}
+	a.mu.Unlock()
+	if len(tss) > 0 {
+		a.pushFunc(tss)
+	}
+}
+
+type aggrState struct {
+	typ        string
+	metric     string
+	labels     []prompbmarshal.Label
+	lastUpdate uint64
+
+	// updated is set if the state has been updated since the last flush.
+	updated bool
+
+	// value contains the counter total or the last gauge value.
+	value float64
+
+	// h, sum and count contain cumulative histogram for timers.
+	h     metrics.Histogram
+	sum   float64
+	count float64
+
+	// fh contains timer values seen since the last flush. It is used for calculating quantiles.
+	fh *histogram.Fast
+
+	// set contains unique set values seen since the last flush.
+	set map[string]struct{}
+}
+
+func newAggrState(typ, metric string, tags []Tag) *aggrState {
+	labels := make([]prompbmarshal.Label, 0, len(tags))
+	for _, tag := range tags {
+		labels = append(labels, prompbmarshal.Label{
+			Name:  cloneString(tag.Key),
+			Value: cloneString(tag.Value),
+		})
+	}
+	st := &aggrState{
+		typ:    typ,
+		metric: cloneString(metric),
+		labels: labels,
+	}
+	switch typ {
+	case TypeTimer:
+		st.fh = histogram.NewFast()
+	case TypeSet:
+		st.set = make(map[string]struct{})
+	}
+	return st
+}
+
+func (st *aggrState) update(r *Row) {
+	st.updated = true
+	switch st.typ {
+	case TypeCounter:
+		st.value += r.Value / r.SampleRate
+	case TypeGauge:
+		if r.IsGaugeDelta {
+			st.value += r.Value
+		} else {
+			st.value = r.Value
+		}
+	case TypeTimer:
+		// Every sampled value represents 1/SampleRate values.
+		n := math.Round(1 / r.SampleRate)
+		for i := 0; i < int(n); i++ {
+			st.h.Update(r.Value)
+			st.fh.Update(r.Value)
+		}
+		st.sum += r.Value * n
+		st.count += n
+	case TypeSet:
+		if _, ok := st.set[r.SetValue]; !ok {
+			st.set[cloneString(r.SetValue)] = struct{}{}
+		}
+	}
+}
+
+func (st *aggrState) appendTimeSeries(dst []prompbmarshal.TimeSeries, timestamp int64, phis []float64) []prompbmarshal.TimeSeries {
+	updated := st.updated
+	st.updated = false
+	switch st.typ {
+	case TypeCounter, TypeGauge:
+		dst = st.appendSeries(dst, "", "", "", timestamp, st.value)
+	case TypeTimer:
+		st.h.VisitNonZeroBuckets(func(vmrange string, count uint64) {
+			dst = st.appendSeries(dst, "_bucket", "vmrange", vmrange, timestamp, float64(count))
+		})
+		dst = st.appendSeries(dst, "_sum", "", "", timestamp, st.sum)
+		dst = st.appendSeries(dst, "_count", "", "", timestamp, st.count)
+		if updated {
+			for _, phi := range phis {
+				quantile := strconv.FormatFloat(phi, 'g', -1, 64)
+				dst = st.appendSeries(dst, "", "quantile", quantile, timestamp, st.fh.Quantile(phi))
+			}
+			st.fh.Reset()
+		}
+	case TypeSet:
+		if updated {
+			dst = st.appendSeries(dst, "", "", "", timestamp, float64(len(st.set)))
+			for k := range st.set {
+				delete(st.set, k)
+			}
+		}
+	}
+	return dst
+}
+
+func (st *aggrState) appendSeries(dst []prompbmarshal.TimeSeries, suffix, extraName, extraValue string, timestamp int64, value float64) []prompbmarshal.TimeSeries {
+	labels := make([]prompbmarshal.Label, 0, len(st.labels)+2)
+	labels = append(labels, prompbmarshal.Label{
+		Name:  "__name__",
+		Value: st.metric + suffix,
+	})
+	labels = append(labels, st.labels...)
+	if extraName != "" {
+		labels = append(labels, prompbmarshal.Label{
+			Name:  extraName,
+			Value: extraValue,
+		})
+	}
+	return append(dst, prompbmarshal.TimeSeries{
+		Labels: labels,
+		Samples: []prompbmarshal.Sample{{
+			Value:     value,
+			Timestamp: timestamp,
+		}},
+	})
+}
+
+func cloneString(s string) string {
+	return string(append([]byte{}, s...))
+}
@@ -0,0 +1,113 @@
+package statsd
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestParseQuantiles(t *testing.T) {
+	f := func(a []string, phisExpected []float64) {
+		t.Helper()
+		phis, err := parseQuantiles(a)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(phis, phisExpected) {
+			t.Fatalf("unexpected quantiles for %q; got %v; want %v", a, phis, phisExpected)
+		}
+	}
+	f(nil, []float64{0.5, 0.9, 0.99})
+	f([]string{"none"}, nil)
+	f([]string{"0", "0.75", "1"}, []float64{0, 0.75, 1})
+
+	for _, s := range []string{"foo", "-0.1", "1.1"} {
+		if _, err := parseQuantiles([]string{s}); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+}
+
+func TestAggregatorFlush(t *testing.T) {
+	var result []string
+	a := &Aggregator{
+		pushFunc: func(tss []prompbmarshal.TimeSeries) {
+			for _, ts := range tss {
+				result = append(result, timeSeriesString(ts))
+			}
+		},
+		phis: []float64{0.5},
+		m:    make(map[string]*aggrState),
+	}
+	f := func(s string, resultExpected []string) {
+		t.Helper()
+		var rows Rows
+		rows.Unmarshal(s)
+		a.Push(rows.Rows)
+		result = result[:0]
+		a.flush()
+		sort.Strings(result)
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result;\ngot\n%q\nwant\n%q", result, resultExpected)
+		}
+	}
+
+	// Counters are cumulative and take into account sample rate
+	f("foo:1|c|#b:2,a:1\nfoo:2|c|@0.5|#a:1,b:2", []string{
+		`foo{a="1",b="2"} 5`,
+	})
+	f("foo:1|c|#a:1,b:2", []string{
+		`foo{a="1",b="2"} 6`,
+	})
+
+	// Gauges and gauge deltas
+	f("bar:10|g\nbar:-3|g", []string{
+		`bar 7`,
+		`foo{a="1",b="2"} 6`,
+	})
+
+	// Sets are flushed only when updated
+	f("users:a|s\nusers:b|s\nusers:a|s", []string{
+		`bar 7`,
+		`foo{a="1",b="2"} 6`,
+		`users 2`,
+	})
+	f("", []string{
+		`bar 7`,
+		`foo{a="1",b="2"} 6`,
+	})
+
+	// Timers, histograms and distributions are merged into a single histogram
+	a.m = make(map[string]*aggrState)
+	f("t:1|ms\nt:1|h\nt:1|d", []string{
+		`t_bucket{vmrange="8.799e-01...1.000e+00"} 3`,
+		`t_count 3`,
+		`t_sum 3`,
+		`t{quantile="0.5"} 1`,
+	})
+	f("", []string{
+		`t_bucket{vmrange="8.799e-01...1.000e+00"} 3`,
+		`t_count 3`,
+		`t_sum 3`,
+	})
+}
+
+func timeSeriesString(ts prompbmarshal.TimeSeries) string {
+	var metric string
+	var tags []string
+	for _, label := range ts.Labels {
+		if label.Name == "__name__" {
+			metric = label.Value
+			continue
+		}
+		tags = append(tags, fmt.Sprintf("%s=%q", label.Name, label.Value))
+	}
+	if len(tags) > 0 {
+		metric += "{" + strings.Join(tags, ",") + "}"
+	}
+	return fmt.Sprintf("%s %g", metric, ts.Samples[0].Value)
+}
@@ -0,0 +1,289 @@
+package statsd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/valyala/fastjson/fastfloat"
+)
+
+// Rows contains parsed statsd rows.
+type Rows struct {
+	Rows []Row
+
+	tagsPool []Tag
+}
+
+// Reset resets rs.
+func (rs *Rows) Reset() {
+	// Reset items, so they can be GC'ed
+
+	for i := range rs.Rows {
+		rs.Rows[i].reset()
+	}
+	rs.Rows = rs.Rows[:0]
+
+	for i := range rs.tagsPool {
+		rs.tagsPool[i].reset()
+	}
+	rs.tagsPool = rs.tagsPool[:0]
+}
+
+// Unmarshal unmarshals statsd plaintext protocol rows from s.
+//
+// See https://github.com/statsd/statsd/blob/master/docs/metric_types.md
+// and https://docs.datadoghq.com/developers/dogstatsd/datagram_shell/
+//
+// s shouldn't be modified when rs is in use.
+func (rs *Rows) Unmarshal(s string) {
+	rs.Rows, rs.tagsPool = unmarshalRows(rs.Rows[:0], s, rs.tagsPool[:0])
+}
+
+// Supported metric types.
+const (
+	TypeCounter      = "c"
+	TypeGauge        = "g"
+	TypeTimer        = "ms"
+	TypeHistogram    = "h"
+	TypeDistribution = "d"
+	TypeSet          = "s"
+)
+
+// Row is a single statsd row.
+type Row struct {
+	Metric string
+	Tags   []Tag
+	Type   string
+	Value  float64
+
+	// SetValue contains the raw value for TypeSet rows.
+	SetValue string
+
+	// IsGaugeDelta is set for TypeGauge rows with the value prefixed with `+` or `-`.
+	// Such rows modify the current gauge value instead of replacing it.
+	IsGaugeDelta bool
+
+	// SampleRate is the sample rate for the row. It is set to 1 by default.
+	SampleRate float64
+}
+
+func (r *Row) reset() {
+	r.Metric = ""
+	r.Tags = nil
+	r.Type = ""
+	r.Value = 0
+	r.SetValue = ""
+	r.IsGaugeDelta = false
+	r.SampleRate = 0
+}
+
+func (r *Row) unmarshalValue(s string) error {
+	if r.Type == TypeSet {
+		if len(s) == 0 {
+			return fmt.Errorf("set value cannot be empty")
+		}
+		r.SetValue = s
+		return nil
+	}
+	r.IsGaugeDelta = r.Type == TypeGauge && len(s) > 0 && (s[0] == '+' || s[0] == '-')
+	sOrig := s
+	if len(s) > 0 && s[0] == '+' {
+		s = s[1:]
+	}
+	v, err := fastfloat.Parse(s)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal value from %q: %w", sOrig, err)
+	}
+	r.Value = v
+	return nil
+}
+
+// unmarshalRow appends rows for the line s to dst.
+//
+// The line has the following format:
+//
+//	<metric>:<value>[:<value>...]|<type>[|@<sample_rate>][|#<tag1>:<value1>,<tag2>:<value2>...]
+//
+// Multiple values share the same type, sample rate and tags.
+func unmarshalRow(dst []Row, s string, tagsPool []Tag) ([]Row, []Tag, error) {
+	if strings.HasPrefix(s, "_e{") || strings.HasPrefix(s, "_sc|") {
+		// Skip DogStatsD events and service checks, since they don't contain metrics.
+		return dst, tagsPool, nil
+	}
+	n := strings.IndexByte(s, ':')
+	if n < 0 {
+		return dst, tagsPool, fmt.Errorf("cannot find `:` between metric and value in %q", s)
+	}
+	metric := s[:n]
+	if len(metric) == 0 {
+		return dst, tagsPool, fmt.Errorf("metric cannot be empty")
+	}
+	tail := s[n+1:]
+	n = strings.IndexByte(tail, '|')
+	if n < 0 {
+		return dst, tagsPool, fmt.Errorf("cannot find `|` between value and type in %q", s)
+	}
+	values := tail[:n]
+	tail = tail[n+1:]
+	var typ string
+	n = strings.IndexByte(tail, '|')
+	if n < 0 {
+		typ = tail
+		tail = ""
+	} else {
+		typ = tail[:n]
+		tail = tail[n+1:]
+	}
+	switch typ {
+	case TypeCounter, TypeGauge, TypeTimer, TypeHistogram, TypeDistribution, TypeSet:
+	default:
+		return dst, tagsPool, fmt.Errorf("unsupported metric type %q", typ)
+	}
+
+	sampleRate := float64(1)
+	tagsStart := len(tagsPool)
+	for len(tail) > 0 {
+		var field string
+		n = strings.IndexByte(tail, '|')
+		if n < 0 {
+			field = tail
+			tail = ""
+		} else {
+			field = tail[:n]
+			tail = tail[n+1:]
+		}
+		if len(field) == 0 {
+			continue
+		}
+		switch field[0] {
+		case '@':
+			v, err := fastfloat.Parse(field[1:])
+			if err != nil {
+				return dst, tagsPool, fmt.Errorf("cannot unmarshal sample rate from %q: %w", field, err)
+			}
+			if v <= 0 || v > 1 {
+				return dst, tagsPool, fmt.Errorf("sample rate must be in the range (0..1]; got %g", v)
+			}
+			sampleRate = v
+		case '#':
+			tagsPool = unmarshalTags(tagsPool, field[1:])
+		default:
+			// Skip unsupported fields such as DogStatsD container id or timestamp.
+		}
+	}
+	tags := tagsPool[tagsStart:]
+	tags = tags[:len(tags):len(tags)]
+
+	rowsStart := len(dst)
+	for len(values) > 0 {
+		var value string
+		n = strings.IndexByte(values, ':')
+		if n < 0 {
+			value = values
+			values = ""
+		} else {
+			value = values[:n]
+			values = values[n+1:]
+		}
+		if cap(dst) > len(dst) {
+			dst = dst[:len(dst)+1]
+		} else {
+			dst = append(dst, Row{})
+		}
+		r := &dst[len(dst)-1]
+		r.reset()
+		r.Metric = metric
+		r.Tags = tags
+		r.Type = typ
+		r.SampleRate = sampleRate
+		if err := r.unmarshalValue(value); err != nil {
+			return dst[:rowsStart], tagsPool, err
+		}
+	}
+	if len(dst) == rowsStart {
+		return dst, tagsPool, fmt.Errorf("missing value in %q", s)
+	}
+	return dst, tagsPool, nil
+}
+
+func unmarshalRows(dst []Row, s string, tagsPool []Tag) ([]Row, []Tag) {
+	for len(s) > 0 {
+		n := strings.IndexByte(s, '\n')
+		if n < 0 {
+			// The last line.
+			return unmarshalLine(dst, s, tagsPool)
+		}
+		dst, tagsPool = unmarshalLine(dst, s[:n], tagsPool)
+		s = s[n+1:]
+	}
+	return dst, tagsPool
+}
+
+func unmarshalLine(dst []Row, s string, tagsPool []Tag) ([]Row, []Tag) {
+	if len(s) > 0 && s[len(s)-1] == '\r' {
+		s = s[:len(s)-1]
+	}
+	if len(s) == 0 {
+		// Skip empty line
+		return dst, tagsPool
+	}
+	var err error
+	dst, tagsPool, err = unmarshalRow(dst, s, tagsPool)
+	if err != nil {
+		logger.Errorf("cannot unmarshal statsd line %q: %s", s, err)
+		invalidLines.Inc()
+	}
+	return dst, tagsPool
+}
+
+var invalidLines = metrics.NewCounter(`vm_rows_invalid_total{type="statsd"}`)
+
+func unmarshalTags(dst []Tag, s string) []Tag {
+	for len(s) > 0 {
+		var tag string
+		n := strings.IndexByte(s, ',')
+		if n < 0 {
+			tag = s
+			s = ""
+		} else {
+			tag = s[:n]
+			s = s[n+1:]
+		}
+		if len(tag) == 0 {
+			continue
+		}
+		if cap(dst) > len(dst) {
+			dst = dst[:len(dst)+1]
+		} else {
+			dst = append(dst, Tag{})
+		}
+		dst[len(dst)-1].unmarshal(tag)
+	}
+	return dst
+}
+
+// Tag is a statsd tag.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+func (t *Tag) reset() {
+	t.Key = ""
+	t.Value = ""
+}
+
+func (t *Tag) unmarshal(s string) {
+	t.reset()
+	n := strings.IndexByte(s, ':')
+	if n < 0 {
+		// Tag without value. Use the same value as for DataDog tags without values.
+		t.Key = s
+		t.Value = "no_label_value"
+	} else {
+		t.Key = s[:n]
+		t.Value = s[n+1:]
+	}
+}
@@ -0,0 +1,190 @@
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowsUnmarshalFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		var rows Rows
+		rows.Unmarshal(s)
+		if len(rows.Rows) != 0 {
+			t.Fatalf("expecting zero rows; got %d rows", len(rows.Rows))
+		}
+
+		// Try again
+		rows.Unmarshal(s)
+		if len(rows.Rows) != 0 {
+			t.Fatalf("expecting zero rows; got %d rows", len(rows.Rows))
+		}
+	}
+
+	// Missing value
+	f("foo")
+	f("foo:")
+	f("foo:|c")
+
+	// Missing type
+	f("foo:123")
+	f("foo:123|")
+
+	// Unsupported type
+	f("foo:123|x")
+
+	// Invalid value
+	f("foo:bar|c")
+	f("foo:1:bar|ms")
+
+	// Empty metric
+	f(":123|c")
+
+	// Invalid sample rate
+	f("foo:123|c|@bar")
+	f("foo:123|c|@0")
+	f("foo:123|c|@1.5")
+
+	// Events and service checks
+	f("_e{5,4}:title|text")
+	f("_sc|foo|0")
+}
+
+func TestRowsUnmarshalSuccess(t *testing.T) {
+	f := func(s string, rowsExpected *Rows) {
+		t.Helper()
+		var rows Rows
+		rows.Unmarshal(s)
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		// Try unmarshaling again
+		rows.Unmarshal(s)
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows on second unmarshal;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		rows.Reset()
+		if len(rows.Rows) != 0 {
+			t.Fatalf("non-empty rows after reset: %+v", rows.Rows)
+		}
+	}
+
+	// Empty line
+	f("", &Rows{})
+	f("\r", &Rows{})
+	f("\n\n", &Rows{})
+
+	// Single line
+	f("foo.bar:123|c", &Rows{
+		Rows: []Row{{
+			Metric:     "foo.bar",
+			Type:       TypeCounter,
+			Value:      123,
+			SampleRate: 1,
+		}},
+	})
+
+	// Sample rate
+	f("foo:1.5|ms|@0.1", &Rows{
+		Rows: []Row{{
+			Metric:     "foo",
+			Type:       TypeTimer,
+			Value:      1.5,
+			SampleRate: 0.1,
+		}},
+	})
+
+	// Gauge delta
+	f("foo:-12|g", &Rows{
+		Rows: []Row{{
+			Metric:       "foo",
+			Type:         TypeGauge,
+			Value:        -12,
+			IsGaugeDelta: true,
+			SampleRate:   1,
+		}},
+	})
+	f("foo:+12|g\nbar:12|g", &Rows{
+		Rows: []Row{
+			{
+				Metric:       "foo",
+				Type:         TypeGauge,
+				Value:        12,
+				IsGaugeDelta: true,
+				SampleRate:   1,
+			},
+			{
+				Metric:     "bar",
+				Type:       TypeGauge,
+				Value:      12,
+				SampleRate: 1,
+			},
+		},
+	})
+
+	// Set
+	f("users:john|s", &Rows{
+		Rows: []Row{{
+			Metric:     "users",
+			Type:       TypeSet,
+			SetValue:   "john",
+			SampleRate: 1,
+		}},
+	})
+
+	// Tags
+	f("foo:1|h|@0.5|#env:prod,host:a:b,debug", &Rows{
+		Rows: []Row{{
+			Metric: "foo",
+			Tags: []Tag{
+				{
+					Key:   "env",
+					Value: "prod",
+				},
+				{
+					Key:   "host",
+					Value: "a:b",
+				},
+				{
+					Key:   "debug",
+					Value: "no_label_value",
+				},
+			},
+			Type:       TypeHistogram,
+			Value:      1,
+			SampleRate: 0.5,
+		}},
+	})
+
+	// Multiple values share type and tags. Unknown fields are ignored.
+	f("foo:1:2|d|#a:b|c:container_id|T1656581400", &Rows{
+		Rows: []Row{
+			{
+				Metric:     "foo",
+				Tags:       []Tag{{Key: "a", Value: "b"}},
+				Type:       TypeDistribution,
+				Value:      1,
+				SampleRate: 1,
+			},
+			{
+				Metric:     "foo",
+				Tags:       []Tag{{Key: "a", Value: "b"}},
+				Type:       TypeDistribution,
+				Value:      2,
+				SampleRate: 1,
+			},
+		},
+	})
+
+	// Invalid lines are skipped
+	f("foo:bar|c\r\n_e{1,1}:a|b\nbaz:3|c\r\n", &Rows{
+		Rows: []Row{{
+			Metric:     "baz",
+			Type:       TypeCounter,
+			Value:      3,
+			SampleRate: 1,
+		}},
+	})
+}
@@ -0,0 +1,272 @@
+package pushgateway
+
+import (
+	"flag"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+var (
+	writeInterval = flag.Duration("pushgateway.writeInterval", 30*time.Second, "The interval for re-writing the last pushed samples for groups "+
+		"pushed via Pushgateway API at /metrics/job/... . This emulates Pushgateway scraping, so the pushed metrics remain visible in queries "+
+		"until the group is deleted or expired. Set to zero for writing the pushed samples only once")
+	groupTTL = flag.Duration("pushgateway.groupTTL", 0, "The default duration for keeping groups pushed via Pushgateway API at /metrics/job/... "+
+		"since the last push. The group is deleted after the ttl. Zero value means that groups are kept until they are explicitly deleted. "+
+		"The ttl may be overridden per group via `ttl` query arg")
+)
+
+// PushFunc must write tss to the storage.
+//
+// tss cannot be held after returning from PushFunc.
+type PushFunc func(tss []prompbmarshal.TimeSeries)
+
+// Registry keeps the last pushed samples per group.
+//
+// Groups are identified by grouping labels such as `job` passed in the request path.
+type Registry struct {
+	pushFunc PushFunc
+
+	mu     sync.Mutex
+	groups map[string]*group
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+type group struct {
+	labels []prompbmarshal.Label
+
+	// series contains the last pushed series for the group keyed by their labels.
+	series map[string]*series
+
+	lastPush time.Time
+	ttl      time.Duration
+}
+
+type series struct {
+	labels []prompbmarshal.Label
+	value  float64
+}
+
+// MustNewRegistry creates new Registry, which writes pushed samples via pushFunc.
+//
+// MustStop must be called on the returned Registry when it is no longer needed.
+func MustNewRegistry(pushFunc PushFunc) *Registry {
+	reg := &Registry{
+		pushFunc: pushFunc,
+		groups:   make(map[string]*group),
+		stopCh:   make(chan struct{}),
+	}
+	reg.wg.Add(1)
+	go func() {
+		defer reg.wg.Done()
+		reg.runWriter()
+	}()
+	return reg
+}
+
+// MustStop stops reg.
+func (reg *Registry) MustStop() {
+	close(reg.stopCh)
+	reg.wg.Wait()
+}
+
+func (reg *Registry) runWriter() {
+	interval := *writeInterval
+	if interval <= 0 {
+		// Groups still must be expired.
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-reg.stopCh:
+			return
+		case tm := <-t.C:
+			reg.writeGroups(tm, *writeInterval > 0)
+		}
+	}
+}
+
+// Push pushes tss with the given groupLabels to reg.
+//
+// If replace is set, then all the previously pushed series for the group are replaced with tss.
+// Otherwise only the series with metric names from tss are replaced.
+// The replaced series, which are missing in tss, are marked as stale.
+//
+// The group is deleted after ttl since the last push. The default ttl is set via -pushgateway.groupTTL if ttl is negative.
+func (reg *Registry) Push(groupLabels []prompbmarshal.Label, tss []prompbmarshal.TimeSeries, replace bool, ttl time.Duration) {
+	if ttl < 0 {
+		ttl = *groupTTL
+	}
+	now := time.Now()
+	key := string(labelsKey(nil, groupLabels))
+
+	reg.mu.Lock()
+	g := reg.groups[key]
+	if g == nil {
+		g = &group{
+			labels: copyLabels(groupLabels),
+			series: make(map[string]*series),
+		}
+		reg.groups[key] = g
+	}
+	g.lastPush = now
+	g.ttl = ttl
+
+	pushedNames := make(map[string]bool)
+	pushed := make(map[string]*series, len(tss))
+	var buf []byte
+	for i := range tss {
+		ts := &tss[i]
+		if len(ts.Samples) == 0 {
+			continue
+		}
+		buf = labelsKey(buf[:0], ts.Labels)
+		s := &series{
+			labels: copyLabels(ts.Labels),
+			value:  ts.Samples[len(ts.Samples)-1].Value,
+		}
+		pushed[string(buf)] = s
+		pushedNames[metricName(ts.Labels)] = true
+	}
+	var stale []*series
+	for k, s := range g.series {
+		if !replace && !pushedNames[metricName(s.labels)] {
+			continue
+		}
+		if _, ok := pushed[k]; !ok {
+			stale = append(stale, s)
+		}
+		delete(g.series, k)
+	}
+	for k, s := range pushed {
+		g.series[k] = s
+	}
+	timestamp := now.UnixNano() / 1e6
+	var dst []prompbmarshal.TimeSeries
+	dst = appendStaleSeries(dst, stale, timestamp)
+	dst = g.appendTimeSeries(dst, timestamp)
+	reg.mu.Unlock()
+
+	reg.pushFunc(dst)
+}
+
+// Delete deletes the group with the given groupLabels from reg.
+//
+// All the series for the deleted group are marked as stale.
+func (reg *Registry) Delete(groupLabels []prompbmarshal.Label) {
+	key := string(labelsKey(nil, groupLabels))
+	reg.mu.Lock()
+	g := reg.groups[key]
+	delete(reg.groups, key)
+	reg.mu.Unlock()
+	if g == nil {
+		return
+	}
+	reg.pushFunc(g.appendStaleTimeSeries(nil, time.Now().UnixNano()/1e6))
+}
+
+func (reg *Registry) writeGroups(now time.Time, writeSamples bool) {
+	timestamp := now.UnixNano() / 1e6
+	var dst []prompbmarshal.TimeSeries
+	reg.mu.Lock()
+	for k, g := range reg.groups {
+		if g.ttl > 0 && now.Sub(g.lastPush) > g.ttl {
+			dst = g.appendStaleTimeSeries(dst, timestamp)
+			delete(reg.groups, k)
+			continue
+		}
+		if writeSamples {
+			dst = g.appendTimeSeries(dst, timestamp)
+		}
+	}
+	reg.mu.Unlock()
+	if len(dst) > 0 {
+		reg.pushFunc(dst)
+	}
+}
+
+func (g *group) appendTimeSeries(dst []prompbmarshal.TimeSeries, timestamp int64) []prompbmarshal.TimeSeries {
+	for _, s := range g.series {
+		dst = appendTimeSeries(dst, s.labels, timestamp, s.value)
+	}
+	// Add push_time_seconds metric in the same way as Pushgateway does.
+	dst = appendTimeSeries(dst, g.pushTimeLabels(), timestamp, float64(g.lastPush.UnixNano())/1e9)
+	return dst
+}
+
+func (g *group) appendStaleTimeSeries(dst []prompbmarshal.TimeSeries, timestamp int64) []prompbmarshal.TimeSeries {
+	for _, s := range g.series {
+		dst = appendTimeSeries(dst, s.labels, timestamp, decimal.StaleNaN)
+	}
+	return appendTimeSeries(dst, g.pushTimeLabels(), timestamp, decimal.StaleNaN)
+}
+
+func (g *group) pushTimeLabels() []prompbmarshal.Label {
+	labels := make([]prompbmarshal.Label, 0, len(g.labels)+1)
+	labels = append(labels, prompbmarshal.Label{
+		Name:  "__name__",
+		Value: "push_time_seconds",
+	})
+	return append(labels, g.labels...)
+}
+
+func appendStaleSeries(dst []prompbmarshal.TimeSeries, ss []*series, timestamp int64) []prompbmarshal.TimeSeries {
+	for _, s := range ss {
+		dst = appendTimeSeries(dst, s.labels, timestamp, decimal.StaleNaN)
+	}
+	return dst
+}
+
+func appendTimeSeries(dst []prompbmarshal.TimeSeries, labels []prompbmarshal.Label, timestamp int64, value float64) []prompbmarshal.TimeSeries {
+	return append(dst, prompbmarshal.TimeSeries{
+		// Copy labels, since PushFunc may modify them during relabeling.
+		Labels: append([]prompbmarshal.Label{}, labels...),
+		Samples: []prompbmarshal.Sample{{
+			Value:     value,
+			Timestamp: timestamp,
+		}},
+	})
+}
+
+func metricName(labels []prompbmarshal.Label) string {
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			return label.Value
+		}
+	}
+	return ""
+}
+
+func labelsKey(dst []byte, labels []prompbmarshal.Label) []byte {
+	a := make([]prompbmarshal.Label, len(labels))
+	copy(a, labels)
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Name < a[j].Name
+	})
+	for _, label := range a {
+		dst = strconv.AppendQuote(dst, label.Name)
+		dst = append(dst, '=')
+		dst = strconv.AppendQuote(dst, label.Value)
+		dst = append(dst, ',')
+	}
+	return dst
+}
+
+func copyLabels(labels []prompbmarshal.Label) []prompbmarshal.Label {
+	dst := make([]prompbmarshal.Label, len(labels))
+	for i, label := range labels {
+		dst[i] = prompbmarshal.Label{
+			Name:  cloneString(label.Name),
+			Value: cloneString(label.Value),
+		}
+	}
+	return dst
+}
@@ -0,0 +1,150 @@
+package pushgateway
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestRegistryPushDelete(t *testing.T) {
+	var pushed []prompbmarshal.TimeSeries
+	reg := MustNewRegistry(func(tss []prompbmarshal.TimeSeries) {
+		pushed = append(pushed[:0], tss...)
+	})
+	defer reg.MustStop()
+
+	groupLabels := []prompbmarshal.Label{{Name: "job", Value: "foo"}}
+	newSeries := func(name, instance string, value float64) prompbmarshal.TimeSeries {
+		return prompbmarshal.TimeSeries{
+			Labels: []prompbmarshal.Label{
+				{Name: "__name__", Value: name},
+				{Name: "kind", Value: instance},
+				{Name: "job", Value: "foo"},
+			},
+			Samples: []prompbmarshal.Sample{{Value: value}},
+		}
+	}
+	f := func(resultExpected map[string]string) {
+		t.Helper()
+		m := seriesValues(pushed)
+		if _, ok := resultExpected[`{__name__="push_time_seconds",job="foo"}`]; !ok {
+			if _, ok := m[`{__name__="push_time_seconds",job="foo"}`]; !ok && len(resultExpected) > 0 {
+				t.Fatalf("missing push_time_seconds metric in %v", m)
+			}
+			delete(m, `{__name__="push_time_seconds",job="foo"}`)
+		}
+		if !reflect.DeepEqual(m, resultExpected) {
+			t.Fatalf("unexpected series;\ngot\n%v\nwant\n%v", m, resultExpected)
+		}
+	}
+
+	// The initial push
+	reg.Push(groupLabels, []prompbmarshal.TimeSeries{
+		newSeries("a", "x", 1),
+		newSeries("a", "y", 2),
+		newSeries("b", "x", 3),
+	}, true, -1)
+	f(map[string]string{
+		`{__name__="a",kind="x",job="foo"}`: "1",
+		`{__name__="a",kind="y",job="foo"}`: "2",
+		`{__name__="b",kind="x",job="foo"}`: "3",
+	})
+
+	// POST replaces only series with the pushed metric names
+	reg.Push(groupLabels, []prompbmarshal.TimeSeries{
+		newSeries("a", "x", 4),
+	}, false, -1)
+	f(map[string]string{
+		`{__name__="a",kind="x",job="foo"}`: "4",
+		`{__name__="a",kind="y",job="foo"}`: "stale",
+		`{__name__="b",kind="x",job="foo"}`: "3",
+	})
+
+	// PUT replaces all the series for the group
+	reg.Push(groupLabels, []prompbmarshal.TimeSeries{
+		newSeries("c", "x", 5),
+	}, true, -1)
+	f(map[string]string{
+		`{__name__="a",kind="x",job="foo"}`: "stale",
+		`{__name__="b",kind="x",job="foo"}`: "stale",
+		`{__name__="c",kind="x",job="foo"}`: "5",
+	})
+
+	// DELETE marks all the series for the group as stale
+	reg.Delete(groupLabels)
+	f(map[string]string{
+		`{__name__="c",kind="x",job="foo"}`:        "stale",
+		`{__name__="push_time_seconds",job="foo"}`: "stale",
+	})
+
+	// DELETE for missing group doesn't push anything
+	pushed = pushed[:0]
+	reg.Delete(groupLabels)
+	f(map[string]string{})
+}
+
+func TestRegistryWriteGroups(t *testing.T) {
+	var pushed []prompbmarshal.TimeSeries
+	reg := MustNewRegistry(func(tss []prompbmarshal.TimeSeries) {
+		pushed = append(pushed[:0], tss...)
+	})
+	defer reg.MustStop()
+
+	groupLabels := []prompbmarshal.Label{{Name: "job", Value: "foo"}}
+	tss := []prompbmarshal.TimeSeries{{
+		Labels: []prompbmarshal.Label{
+			{Name: "__name__", Value: "a"},
+			{Name: "job", Value: "foo"},
+		},
+		Samples: []prompbmarshal.Sample{{Value: 1}},
+	}}
+	reg.Push(groupLabels, tss, true, time.Minute)
+
+	// The group must be re-written until it expires.
+	pushed = pushed[:0]
+	reg.writeGroups(time.Now(), true)
+	m := seriesValues(pushed)
+	if v, ok := m[`{__name__="a",job="foo"}`]; !ok || v != "1" {
+		t.Fatalf("unexpected series after re-write: %v", m)
+	}
+
+	// The group must be marked as stale after ttl.
+	pushed = pushed[:0]
+	reg.writeGroups(time.Now().Add(2*time.Minute), true)
+	m = seriesValues(pushed)
+	if v, ok := m[`{__name__="a",job="foo"}`]; !ok || v != "stale" {
+		t.Fatalf("unexpected series after group expiration: %v", m)
+	}
+
+	// The expired group mustn't be written anymore.
+	pushed = pushed[:0]
+	reg.writeGroups(time.Now().Add(3*time.Minute), true)
+	if len(pushed) > 0 {
+		t.Fatalf("unexpected series written for expired group: %v", seriesValues(pushed))
+	}
+}
+
+// seriesValues returns the last value per each series in tss.
+//
+// Stale markers are returned as "stale".
+func seriesValues(tss []prompbmarshal.TimeSeries) map[string]string {
+	m := make(map[string]string, len(tss))
+	for _, ts := range tss {
+		var a []string
+		for _, label := range ts.Labels {
+			a = append(a, fmt.Sprintf("%s=%q", label.Name, label.Value))
+		}
+		v := ts.Samples[len(ts.Samples)-1].Value
+		s := fmt.Sprintf("%g", v)
+		if decimal.IsStaleNaN(v) {
+			s = "stale"
+		}
+		m["{"+strings.Join(a, ",")+"}"] = s
+	}
+	return m
+}
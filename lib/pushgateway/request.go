@@ -0,0 +1,176 @@
+package pushgateway
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// PathPrefix is the path prefix for Pushgateway API requests.
+const PathPrefix = "/metrics/job"
+
+// HandleRequest processes Pushgateway API request at /metrics/job/<job>{/<label>/<value>}.
+//
+// PUT requests replace all the metrics for the group, POST requests replace metrics with the same names for the group,
+// while DELETE requests delete the group.
+//
+// See https://github.com/prometheus/pushgateway#api
+func (reg *Registry) HandleRequest(req *http.Request) error {
+	groupLabels, err := ParseGroupingKey(strings.TrimPrefix(req.URL.Path, "/metrics/"))
+	if err != nil {
+		return fmt.Errorf("cannot parse grouping key from %q: %w", req.URL.Path, err)
+	}
+	switch req.Method {
+	case http.MethodDelete:
+		deleteRequests.Inc()
+		reg.Delete(groupLabels)
+		return nil
+	case http.MethodPut, http.MethodPost:
+	default:
+		return fmt.Errorf("unsupported method %q; supported methods: PUT, POST, DELETE", req.Method)
+	}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/vnd.google.protobuf") {
+		return fmt.Errorf("protobuf format isn't supported; push metrics in Prometheus text exposition format")
+	}
+	ttl := time.Duration(-1)
+	if s := req.FormValue("ttl"); s != "" {
+		ttl, err = time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("cannot parse `ttl` query arg: %w", err)
+		}
+		if ttl < 0 {
+			return fmt.Errorf("`ttl` query arg cannot be negative; got %s", ttl)
+		}
+	}
+	extraLabels, err := common.GetExtraLabels(req)
+	if err != nil {
+		return err
+	}
+	var (
+		tss []prompbmarshal.TimeSeries
+		mu  sync.Mutex
+	)
+	isGzipped := req.Header.Get("Content-Encoding") == "gzip"
+	err = prometheus.ParseStream(req.Body, 0, isGzipped, func(rows []prometheus.Row) error {
+		mu.Lock()
+		tss = appendRows(tss, rows, groupLabels, extraLabels)
+		mu.Unlock()
+		return nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+	replace := req.Method == http.MethodPut
+	if replace {
+		putRequests.Inc()
+	} else {
+		postRequests.Inc()
+	}
+	reg.Push(groupLabels, tss, replace, ttl)
+	return nil
+}
+
+var (
+	putRequests    = metrics.NewCounter(`vm_pushgateway_requests_total{method="PUT"}`)
+	postRequests   = metrics.NewCounter(`vm_pushgateway_requests_total{method="POST"}`)
+	deleteRequests = metrics.NewCounter(`vm_pushgateway_requests_total{method="DELETE"}`)
+)
+
+// appendRows appends rows to dst.
+//
+// Grouping labels and extra labels override the labels with the same names in rows.
+func appendRows(dst []prompbmarshal.TimeSeries, rows []prometheus.Row, groupLabels, extraLabels []prompbmarshal.Label) []prompbmarshal.TimeSeries {
+	for i := range rows {
+		r := &rows[i]
+		labels := make([]prompbmarshal.Label, 0, 1+len(r.Tags)+len(groupLabels)+len(extraLabels))
+		// Copy strings from rows, since they cannot be held after returning from ParseStream callback.
+		labels = append(labels, prompbmarshal.Label{
+			Name:  "__name__",
+			Value: cloneString(r.Metric),
+		})
+		for j := range r.Tags {
+			tag := &r.Tags[j]
+			if hasLabel(groupLabels, tag.Key) || hasLabel(extraLabels, tag.Key) {
+				continue
+			}
+			labels = append(labels, prompbmarshal.Label{
+				Name:  cloneString(tag.Key),
+				Value: cloneString(tag.Value),
+			})
+		}
+		labels = append(labels, groupLabels...)
+		for _, label := range extraLabels {
+			if !hasLabel(groupLabels, label.Name) {
+				labels = append(labels, label)
+			}
+		}
+		dst = append(dst, prompbmarshal.TimeSeries{
+			Labels: labels,
+			Samples: []prompbmarshal.Sample{{
+				Value: r.Value,
+			}},
+		})
+	}
+	return dst
+}
+
+func cloneString(s string) string {
+	return string(append([]byte{}, s...))
+}
+
+func hasLabel(labels []prompbmarshal.Label, name string) bool {
+	for _, label := range labels {
+		if label.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseGroupingKey parses grouping labels from path in the form `job/<job>{/<label>/<value>}`.
+//
+// Label names with `@base64` suffix contain base64url-encoded values.
+func ParseGroupingKey(path string) ([]prompbmarshal.Label, error) {
+	path = strings.TrimSuffix(path, "/")
+	parts := strings.Split(path, "/")
+	if len(parts)%2 != 0 {
+		return nil, fmt.Errorf("missing value for the label %q", parts[len(parts)-1])
+	}
+	labels := make([]prompbmarshal.Label, 0, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		name, value := parts[i], parts[i+1]
+		if strings.HasSuffix(name, "@base64") {
+			name = strings.TrimSuffix(name, "@base64")
+			b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode base64-encoded value for the label %q: %w", name, err)
+			}
+			value = string(b)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("label name cannot be empty")
+		}
+		if name == "__name__" {
+			return nil, fmt.Errorf("`__name__` label cannot be used in grouping key")
+		}
+		if hasLabel(labels, name) {
+			return nil, fmt.Errorf("duplicate label %q", name)
+		}
+		labels = append(labels, prompbmarshal.Label{
+			Name:  name,
+			Value: value,
+		})
+	}
+	if labels[0].Name != "job" || labels[0].Value == "" {
+		return nil, fmt.Errorf("grouping key must start with non-empty `job` label")
+	}
+	return labels, nil
+}
@@ -0,0 +1,105 @@
+package pushgateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+)
+
+func TestParseGroupingKeySuccess(t *testing.T) {
+	f := func(path string, labelsExpected []prompbmarshal.Label) {
+		t.Helper()
+		labels, err := ParseGroupingKey(path)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", path, err)
+		}
+		if !reflect.DeepEqual(labels, labelsExpected) {
+			t.Fatalf("unexpected labels for %q;\ngot\n%+v\nwant\n%+v", path, labels, labelsExpected)
+		}
+	}
+	f("job/foo", []prompbmarshal.Label{{Name: "job", Value: "foo"}})
+	f("job/foo/", []prompbmarshal.Label{{Name: "job", Value: "foo"}})
+	f("job/foo/instance/bar", []prompbmarshal.Label{
+		{Name: "job", Value: "foo"},
+		{Name: "instance", Value: "bar"},
+	})
+	// base64-encoded values
+	f("job@base64/L3Zhci90bXA", []prompbmarshal.Label{{Name: "job", Value: "/var/tmp"}})
+	f("job@base64/L3Zhci90bXA=", []prompbmarshal.Label{{Name: "job", Value: "/var/tmp"}})
+	f("job/foo/path@base64/=", []prompbmarshal.Label{
+		{Name: "job", Value: "foo"},
+		{Name: "path", Value: ""},
+	})
+}
+
+func TestParseGroupingKeyFailure(t *testing.T) {
+	f := func(path string) {
+		t.Helper()
+		labels, err := ParseGroupingKey(path)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q; got labels %+v", path, labels)
+		}
+	}
+	f("")
+	f("job")
+	f("job/")
+	f("instance/foo")
+	f("job/foo/instance")
+	f("job/foo//bar")
+	f("job/foo/__name__/bar")
+	f("job/foo/instance/a/instance/b")
+	f("job/foo/job/bar")
+	f("job@base64/!!!")
+}
+
+func TestHandleRequestFailure(t *testing.T) {
+	reg := MustNewRegistry(func(tss []prompbmarshal.TimeSeries) {
+		t.Fatalf("unexpected push of %d series", len(tss))
+	})
+	defer reg.MustStop()
+	f := func(method, url, contentType, body string) {
+		t.Helper()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if err := reg.HandleRequest(req); err == nil {
+			t.Fatalf("expecting non-nil error for %s %s", method, url)
+		}
+	}
+	f(http.MethodGet, "/metrics/job/foo", "", "")
+	f(http.MethodPut, "/metrics/job/", "", "foo 1\n")
+	f(http.MethodPut, "/metrics/job/foo?ttl=bar", "", "foo 1\n")
+	f(http.MethodPut, "/metrics/job/foo?ttl=-1s", "", "foo 1\n")
+	f(http.MethodPut, "/metrics/job/foo", "application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited", "")
+}
+
+func TestHandleRequestSuccess(t *testing.T) {
+	common.StartUnmarshalWorkers()
+	defer common.StopUnmarshalWorkers()
+
+	var pushed []prompbmarshal.TimeSeries
+	reg := MustNewRegistry(func(tss []prompbmarshal.TimeSeries) {
+		pushed = append(pushed, tss...)
+	})
+	defer reg.MustStop()
+
+	req := httptest.NewRequest(http.MethodPost, "/metrics/job/backup/instance/db1?extra_label=env=prod",
+		strings.NewReader("# TYPE last_success gauge\nlast_success{instance=\"ignored\",kind=\"full\"} 123\n"))
+	if err := reg.HandleRequest(req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	m := seriesValues(pushed)
+	expected := map[string]string{
+		`{__name__="last_success",kind="full",job="backup",instance="db1",env="prod"}`: "123",
+	}
+	delete(m, `{__name__="push_time_seconds",job="backup",instance="db1"}`)
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("unexpected series;\ngot\n%v\nwant\n%v", m, expected)
+	}
+}
@@ -0,0 +1,189 @@
+package querytracer
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var denyQueryTracing = flag.Bool("denyQueryTracing", false, "Whether to disable the ability to trace queries. See https://docs.victoriametrics.com/#query-tracing")
+
+// Tracer represents query tracer.
+//
+// It must be created via New call.
+// Each created tracer must be finalized via Done or Donef call.
+//
+// Tracer may contain sub-tracers (branches) in order to build tree-like execution order.
+// Call Tracer.NewChild func for adding sub-tracer.
+//
+// All the Tracer methods are no-op for nil Tracer, so it is safe passing nil Tracer to functions.
+type Tracer struct {
+	// startTime is the time when Tracer was created
+	startTime time.Time
+
+	// doneTime is the time when Done or Donef was called
+	doneTime time.Time
+
+	// message is the message generated by NewChild, Printf or Donef call.
+	message string
+
+	// children is a list of children Tracer objects
+	children []*Tracer
+}
+
+// New creates a new instance of the tracer with the given fmt.Sprintf(format, args...) message.
+//
+// If enabled isn't set, then all function calls to the returned object will be no-op.
+//
+// Done or Donef must be called when the tracer should be finished.
+func New(enabled bool, format string, args ...interface{}) *Tracer {
+	if *denyQueryTracing || !enabled {
+		return nil
+	}
+	return &Tracer{
+		message:   fmt.Sprintf(format, args...),
+		startTime: time.Now(),
+	}
+}
+
+// Enabled returns true if the t is enabled.
+func (t *Tracer) Enabled() bool {
+	return t != nil
+}
+
+// NewChild adds a new child Tracer to t with the given fmt.Sprintf(format, args...) message.
+//
+// NewChild cannot be called from concurrent goroutines.
+// Create children tracers from a single goroutine and then pass them
+// to concurrent goroutines.
+func (t *Tracer) NewChild(format string, args ...interface{}) *Tracer {
+	if t == nil {
+		return nil
+	}
+	if !t.doneTime.IsZero() {
+		logger.Panicf("BUG: NewChild() cannot be called after Donef(%q) call", t.message)
+	}
+	child := &Tracer{
+		message:   fmt.Sprintf(format, args...),
+		startTime: time.Now(),
+	}
+	t.children = append(t.children, child)
+	return child
+}
+
+// Done finishes t.
+//
+// Done cannot be called multiple times.
+// Other Tracer functions cannot be called after Done call.
+func (t *Tracer) Done() {
+	if t == nil {
+		return
+	}
+	if !t.doneTime.IsZero() {
+		logger.Panicf("BUG: Donef(%q) already called", t.message)
+	}
+	t.doneTime = time.Now()
+}
+
+// Donef appends the given fmt.Sprintf(format, args..) message to t and finished it.
+//
+// Donef cannot be called multiple times.
+// Other Tracer functions cannot be called after Donef call.
+func (t *Tracer) Donef(format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	if !t.doneTime.IsZero() {
+		logger.Panicf("BUG: Donef(%q) already called", t.message)
+	}
+	t.message += ": " + fmt.Sprintf(format, args...)
+	t.doneTime = time.Now()
+}
+
+// Printf adds new fmt.Sprintf(format, args...) message to t.
+//
+// Printf cannot be called from concurrent goroutines.
+func (t *Tracer) Printf(format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	if !t.doneTime.IsZero() {
+		logger.Panicf("BUG: Printf() cannot be called after Done(%q) call", t.message)
+	}
+	now := time.Now()
+	child := &Tracer{
+		startTime: now,
+		doneTime:  now,
+		message:   fmt.Sprintf(format, args...),
+	}
+	t.children = append(t.children, child)
+}
+
+// String returns string representation of t.
+//
+// String must be called when t methods aren't called by other goroutines.
+func (t *Tracer) String() string {
+	if t == nil {
+		return ""
+	}
+	var sb strings.Builder
+	t.writeString(&sb, 0)
+	return sb.String()
+}
+
+func (t *Tracer) writeString(w io.Writer, level int) {
+	prefix := strings.Repeat("| ", level)
+	fmt.Fprintf(w, "%s- %.03fms: %s\n", prefix, t.getDurationMsec(), t.message)
+	for _, child := range t.children {
+		child.writeString(w, level+1)
+	}
+}
+
+// ToJSON returns JSON representation of t.
+//
+// ToJSON must be called when t methods aren't called by other goroutines.
+func (t *Tracer) ToJSON() string {
+	if t == nil {
+		return ""
+	}
+	var sb strings.Builder
+	t.writeJSON(&sb)
+	return sb.String()
+}
+
+func (t *Tracer) writeJSON(w io.Writer) {
+	fmt.Fprintf(w, `{"duration_msec":%.03f,"message":%s`, t.getDurationMsec(), jsonString(t.message))
+	if len(t.children) > 0 {
+		fmt.Fprintf(w, `,"children":[`)
+		for i, child := range t.children {
+			child.writeJSON(w)
+			if i+1 < len(t.children) {
+				fmt.Fprintf(w, `,`)
+			}
+		}
+		fmt.Fprintf(w, `]`)
+	}
+	fmt.Fprintf(w, `}`)
+}
+
+func jsonString(s string) string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		logger.Panicf("BUG: unexpected error when marshaling %q to JSON: %s", s, err)
+	}
+	return string(data)
+}
+
+func (t *Tracer) getDurationMsec() float64 {
+	doneTime := t.doneTime
+	if doneTime.IsZero() {
+		// The tracer isn't finished yet. Use the current time instead.
+		doneTime = time.Now()
+	}
+	return float64(doneTime.Sub(t.startTime)) / 1e6
+}
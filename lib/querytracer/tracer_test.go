@@ -0,0 +1,94 @@
+package querytracer
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+func TestTracerDisabled(t *testing.T) {
+	qt := New(false, "test")
+	if qt.Enabled() {
+		t.Fatalf("query tracer must be disabled")
+	}
+	qtChild := qt.NewChild("child done %d", 456)
+	if qtChild.Enabled() {
+		t.Fatalf("query tracer must be disabled")
+	}
+	qtChild.Printf("foo %d", 123)
+	qtChild.Done()
+	qt.Printf("parent %d", 789)
+	qt.Donef("foo %d", 33)
+	if s := qt.String(); s != "" {
+		t.Fatalf("unexpected string from disabled tracer: %q", s)
+	}
+	if s := qt.ToJSON(); s != "" {
+		t.Fatalf("unexpected JSON from disabled tracer: %q", s)
+	}
+}
+
+func TestTracerEnabled(t *testing.T) {
+	qt := New(true, "test")
+	if !qt.Enabled() {
+		t.Fatalf("query tracer must be enabled")
+	}
+	qtChild := qt.NewChild("child done %d", 456)
+	if !qtChild.Enabled() {
+		t.Fatalf("child query tracer must be enabled")
+	}
+	qtChild.Printf("foo %d", 123)
+	qtChild.Done()
+	qt.Printf("parent %d", 789)
+	qt.Donef("foo %d", 33)
+	s := qt.String()
+	sExpected := `- 0ms: test: foo 33
+| - 0ms: child done 456
+| | - 0ms: foo 123
+| - 0ms: parent 789
+`
+	if !areEqualTracesSkipDuration(s, sExpected) {
+		t.Fatalf("unexpected trace\ngot\n%s\nwant\n%s", s, sExpected)
+	}
+}
+
+func TestTracerToJSON(t *testing.T) {
+	qt := New(true, "test with \"quotes\" and\nnewline")
+	qtChild := qt.NewChild("child")
+	qtChild.Printf("foo %s", "\x00")
+	qtChild.Done()
+	qt.Done()
+	s := qt.ToJSON()
+	var v struct {
+		DurationMsec float64 `json:"duration_msec"`
+		Message      string  `json:"message"`
+		Children     []struct {
+			Message  string `json:"message"`
+			Children []struct {
+				Message string `json:"message"`
+			} `json:"children"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("cannot unmarshal trace %s: %s", s, err)
+	}
+	if v.Message != "test with \"quotes\" and\nnewline" {
+		t.Fatalf("unexpected message: %q", v.Message)
+	}
+	if v.DurationMsec < 0 {
+		t.Fatalf("unexpected negative duration: %v", v.DurationMsec)
+	}
+	if len(v.Children) != 1 || v.Children[0].Message != "child" {
+		t.Fatalf("unexpected children: %s", s)
+	}
+	if len(v.Children[0].Children) != 1 || v.Children[0].Children[0].Message != "foo \x00" {
+		t.Fatalf("unexpected grandchildren: %s", s)
+	}
+}
+
+func areEqualTracesSkipDuration(s1, s2 string) bool {
+	s1 = skipDurationRe.ReplaceAllString(s1, " 0ms: ")
+	s2 = skipDurationRe.ReplaceAllString(s2, " 0ms: ")
+	return s1 == s2
+}
+
+var skipDurationRe = regexp.MustCompile(" [0-9.]+ms: ")
@@ -29,6 +29,14 @@ type Block struct {
 	timestamps []int64
 	values     []int64
 
+	// histograms contains marshaled native histograms for rows if bh.Histograms is set.
+	//
+	// Empty histograms correspond to rows with ordinary float values.
+	histograms [][]byte
+
+	// histogramsBuf holds the contents for histograms.
+	histogramsBuf []byte
+
 	// Marshaled representation of block header.
 	headerData []byte
 
@@ -45,6 +53,8 @@ func (b *Block) Reset() {
 	b.nextIdx = 0
 	b.timestamps = b.timestamps[:0]
 	b.values = b.values[:0]
+	b.histograms = b.histograms[:0]
+	b.histogramsBuf = b.histogramsBuf[:0]
 
 	b.headerData = b.headerData[:0]
 	b.timestampsData = b.timestampsData[:0]
@@ -57,6 +67,11 @@ func (b *Block) CopyFrom(src *Block) {
 	b.nextIdx = 0
 	b.timestamps = append(b.timestamps[:0], src.timestamps[src.nextIdx:]...)
 	b.values = append(b.values[:0], src.values[src.nextIdx:]...)
+	b.histograms = b.histograms[:0]
+	b.histogramsBuf = b.histogramsBuf[:0]
+	if src.bh.Histograms && len(src.values) > 0 {
+		b.appendHistograms(src.histograms[src.nextIdx:])
+	}
 
 	b.headerData = append(b.headerData[:0], src.headerData...)
 	b.timestampsData = append(b.timestampsData[:0], src.timestampsData...)
@@ -79,8 +94,8 @@ func putBlock(b *Block) {
 var blockPool sync.Pool
 
 func (b *Block) fixupTimestamps() {
-	b.bh.MinTimestamp = b.timestamps[b.nextIdx]
-	b.bh.MaxTimestamp = b.timestamps[len(b.timestamps)-1]
+	b.bh.MinTimestamp = b.timestampToMsecs(b.timestamps[b.nextIdx])
+	b.bh.MaxTimestamp = b.timestampToMsecs(b.timestamps[len(b.timestamps)-1])
 }
 
 // RowsCount returns the number of rows in the block.
@@ -120,6 +135,9 @@ func (b *Block) assertUnmarshaled() {
 	if len(b.values) != len(b.timestamps) {
 		logger.Panicf("BUG: the number of values must match the number of timestamps; got %d vs %d", len(b.values), len(b.timestamps))
 	}
+	if b.bh.Histograms && len(b.histograms) != len(b.values) {
+		logger.Panicf("BUG: the number of histograms must match the number of values; got %d vs %d", len(b.histograms), len(b.values))
+	}
 	if b.nextIdx > len(b.values) {
 		logger.Panicf("BUG: nextIdx cannot exceed the number of values; got %d vs %d", b.nextIdx, len(b.values))
 	}
@@ -152,6 +170,14 @@ func (b *Block) deduplicateSamplesDuringMerge() {
 		// Nothing to dedup or the data is already marshaled.
 		return
 	}
+	if b.bh.NanosecondTimestamps {
+		// Samples with nanosecond timestamps are stored as is.
+		return
+	}
+	if b.bh.Histograms {
+		// Native histograms are stored as is.
+		return
+	}
 	srcTimestamps := b.timestamps[b.nextIdx:]
 	srcValues := b.values[b.nextIdx:]
 	timestamps, values := deduplicateSamplesDuringMerge(srcTimestamps, srcValues)
@@ -212,15 +238,31 @@ func (b *Block) MarshalData(timestampsBlockOffset, valuesBlockOffset uint64) ([]
 		logger.Panicf("BUG: the number of values must match the number of timestamps; got %d vs %d", len(values), len(timestamps))
 	}
 
-	b.valuesData, b.bh.ValuesMarshalType, b.bh.FirstValue = encoding.MarshalValues(b.valuesData[:0], values, b.bh.PrecisionBits)
+	b.valuesData = b.valuesData[:0]
+	if b.bh.Histograms {
+		// Histograms are stored in front of values.
+		b.valuesData = marshalHistograms(b.valuesData, b.histograms[b.nextIdx:])
+		b.histograms = b.histograms[:0]
+		b.histogramsBuf = b.histogramsBuf[:0]
+	}
+	b.valuesData, b.bh.ValuesMarshalType, b.bh.FirstValue = encoding.MarshalValues(b.valuesData, values, b.bh.PrecisionBits)
 	b.bh.ValuesBlockOffset = valuesBlockOffset
 	b.bh.ValuesBlockSize = uint32(len(b.valuesData))
 	b.values = b.values[:0]
 
-	b.timestampsData, b.bh.TimestampsMarshalType, b.bh.MinTimestamp = encoding.MarshalTimestamps(b.timestampsData[:0], timestamps, b.bh.PrecisionBits)
+	b.timestampsData = b.timestampsData[:0]
+	timestampsPrecisionBits := b.bh.PrecisionBits
+	if b.bh.NanosecondTimestamps {
+		// The first timestamp is stored in the data, since bh.MinTimestamp has millisecond precision.
+		b.timestampsData = encoding.MarshalVarInt64(b.timestampsData, timestamps[0])
+		// Nanosecond timestamps are stored without precision loss, since otherwise they lose their purpose.
+		timestampsPrecisionBits = 64
+	}
+	b.timestampsData, b.bh.TimestampsMarshalType, b.bh.MinTimestamp = encoding.MarshalTimestamps(b.timestampsData, timestamps, timestampsPrecisionBits)
 	b.bh.TimestampsBlockOffset = timestampsBlockOffset
 	b.bh.TimestampsBlockSize = uint32(len(b.timestampsData))
-	b.bh.MaxTimestamp = timestamps[len(timestamps)-1]
+	b.bh.MinTimestamp = b.timestampToMsecs(b.bh.MinTimestamp)
+	b.bh.MaxTimestamp = b.timestampToMsecs(timestamps[len(timestamps)-1])
 	b.timestamps = b.timestamps[:0]
 
 	b.bh.RowsCount = uint32(len(values))
@@ -252,17 +294,32 @@ func (b *Block) UnmarshalData() error {
 
 	var err error
 
-	b.timestamps, err = encoding.UnmarshalTimestamps(b.timestamps[:0], b.timestampsData, b.bh.TimestampsMarshalType, b.bh.MinTimestamp, int(b.bh.RowsCount))
+	timestampsData := b.timestampsData
+	firstTimestamp := b.bh.MinTimestamp
+	if b.bh.NanosecondTimestamps {
+		timestampsData, firstTimestamp, err = encoding.UnmarshalVarInt64(timestampsData)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal the first nanosecond timestamp: %w", err)
+		}
+	}
+	b.timestamps, err = encoding.UnmarshalTimestamps(b.timestamps[:0], timestampsData, b.bh.TimestampsMarshalType, firstTimestamp, int(b.bh.RowsCount))
 	if err != nil {
 		return err
 	}
-	if b.bh.PrecisionBits < 64 {
+	if b.bh.PrecisionBits < 64 && !b.bh.NanosecondTimestamps {
 		// Recover timestamps order after lossy compression.
 		encoding.EnsureNonDecreasingSequence(b.timestamps, b.bh.MinTimestamp, b.bh.MaxTimestamp)
 	}
 	b.timestampsData = b.timestampsData[:0]
 
-	b.values, err = encoding.UnmarshalValues(b.values[:0], b.valuesData, b.bh.ValuesMarshalType, b.bh.FirstValue, int(b.bh.RowsCount))
+	valuesData := b.valuesData
+	if b.bh.Histograms {
+		valuesData, err = b.unmarshalHistograms(valuesData, int(b.bh.RowsCount))
+		if err != nil {
+			return err
+		}
+	}
+	b.values, err = encoding.UnmarshalValues(b.values[:0], valuesData, b.bh.ValuesMarshalType, b.bh.FirstValue, int(b.bh.RowsCount))
 	if err != nil {
 		return err
 	}
@@ -279,33 +336,70 @@ func (b *Block) UnmarshalData() error {
 
 // AppendRowsWithTimeRangeFilter filters samples from b according to tr and appends them to dst*.
 //
+// Timestamps are appended in milliseconds. Use AppendRowsWithTimeRangeFilterNanos for obtaining nanosecond timestamps.
+//
 // It is expected that UnmarshalData has been already called on b.
 func (b *Block) AppendRowsWithTimeRangeFilter(dstTimestamps []int64, dstValues []float64, tr TimeRange) ([]int64, []float64) {
 	timestamps, values := b.filterTimestamps(tr)
-	dstTimestamps = append(dstTimestamps, timestamps...)
+	if b.bh.NanosecondTimestamps {
+		for _, timestamp := range timestamps {
+			dstTimestamps = append(dstTimestamps, nanosToMsecs(timestamp))
+		}
+	} else {
+		dstTimestamps = append(dstTimestamps, timestamps...)
+	}
+	dstValues = decimal.AppendDecimalToFloat(dstValues, values, b.bh.Scale)
+	return dstTimestamps, dstValues
+}
+
+// AppendRowsWithTimeRangeFilterNanos filters samples from b according to tr and appends them to dst*.
+//
+// Timestamps are appended in nanoseconds. Millisecond timestamps are converted to nanoseconds.
+//
+// It is expected that UnmarshalData has been already called on b.
+func (b *Block) AppendRowsWithTimeRangeFilterNanos(dstTimestamps []int64, dstValues []float64, tr TimeRange) ([]int64, []float64) {
+	timestamps, values := b.filterTimestamps(tr)
+	if b.bh.NanosecondTimestamps {
+		dstTimestamps = append(dstTimestamps, timestamps...)
+	} else {
+		for _, timestamp := range timestamps {
+			dstTimestamps = append(dstTimestamps, timestamp*1e6)
+		}
+	}
 	dstValues = decimal.AppendDecimalToFloat(dstValues, values, b.bh.Scale)
 	return dstTimestamps, dstValues
 }
 
 func (b *Block) filterTimestamps(tr TimeRange) ([]int64, []int64) {
+	i, j := b.getRowsRange(tr)
+	if i == j {
+		return nil, nil
+	}
+	return b.timestamps[i:j], b.values[i:j]
+}
+
+// getRowsRange returns the range [i:j) for rows in b on the given tr.
+func (b *Block) getRowsRange(tr TimeRange) (int, int) {
 	timestamps := b.timestamps
+	minTimestamp := b.minTimestampFromMsecs(tr.MinTimestamp)
+	maxTimestamp := b.maxTimestampFromMsecs(tr.MaxTimestamp)
 
 	// Skip timestamps smaller than tr.MinTimestamp.
 	i := 0
-	for i < len(timestamps) && timestamps[i] < tr.MinTimestamp {
+	for i < len(timestamps) && timestamps[i] < minTimestamp {
 		i++
 	}
 
 	// Skip timestamps bigger than tr.MaxTimestamp.
 	j := len(timestamps)
-	for j > i && timestamps[j-1] > tr.MaxTimestamp {
+	for j > i && timestamps[j-1] > maxTimestamp {
 		j--
 	}
 
 	if i == j {
-		return nil, nil
+		return 0, 0
 	}
-	return timestamps[i:j], b.values[i:j]
+	return i, j
 }
 
 // MarshalPortable marshals b to dst, so it could be portably migrated to other VictoriaMetrics instance.
@@ -318,8 +412,8 @@ func (b *Block) MarshalPortable(dst []byte) []byte {
 	dst = encoding.MarshalVarInt64(dst, b.bh.FirstValue)
 	dst = encoding.MarshalVarUint64(dst, uint64(b.bh.RowsCount))
 	dst = encoding.MarshalVarInt64(dst, int64(b.bh.Scale))
-	dst = append(dst, byte(b.bh.TimestampsMarshalType))
-	dst = append(dst, byte(b.bh.ValuesMarshalType))
+	dst = append(dst, b.bh.marshalTimestampsMarshalType())
+	dst = append(dst, b.bh.marshalValuesMarshalType())
 	dst = encoding.MarshalBytes(dst, b.timestampsData)
 	dst = encoding.MarshalBytes(dst, b.valuesData)
 
@@ -365,12 +459,12 @@ func (b *Block) UnmarshalPortable(src []byte) ([]byte, error) {
 	if len(src) < 1 {
 		return src, fmt.Errorf("cannot unmarshal marshalType for timestamps from %d bytes; need at least %d bytes", len(src), 1)
 	}
-	b.bh.TimestampsMarshalType = encoding.MarshalType(src[0])
+	b.bh.unmarshalTimestampsMarshalType(src[0])
 	src = src[1:]
 	if len(src) < 1 {
 		return src, fmt.Errorf("cannot unmarshal marshalType for values from %d bytes; need at least %d bytes", len(src), 1)
 	}
-	b.bh.ValuesMarshalType = encoding.MarshalType(src[0])
+	b.bh.unmarshalValuesMarshalType(src[0])
 	src = src[1:]
 	b.bh.PrecisionBits = 64
 
@@ -77,6 +77,16 @@ type blockHeader struct {
 	//
 	// Lower PrecisionBits give better block compression and speed.
 	PrecisionBits uint8
+
+	// NanosecondTimestamps is set to true if the block contains timestamps in nanoseconds.
+	//
+	// MinTimestamp and MaxTimestamp are always in milliseconds.
+	NanosecondTimestamps bool
+
+	// Histograms is set to true if the block contains native histograms.
+	//
+	// Values contain the number of observations in every histogram.
+	Histograms bool
 }
 
 // Less returns true if b is less than src.
@@ -109,7 +119,7 @@ func (bh *blockHeader) Marshal(dst []byte) []byte {
 	dst = encoding.MarshalUint32(dst, bh.ValuesBlockSize)
 	dst = encoding.MarshalUint32(dst, bh.RowsCount)
 	dst = encoding.MarshalInt16(dst, bh.Scale)
-	dst = append(dst, byte(bh.TimestampsMarshalType), byte(bh.ValuesMarshalType), bh.PrecisionBits)
+	dst = append(dst, bh.marshalTimestampsMarshalType(), bh.marshalValuesMarshalType(), bh.PrecisionBits)
 	return dst
 }
 
@@ -143,9 +153,9 @@ func (bh *blockHeader) Unmarshal(src []byte) ([]byte, error) {
 	src = src[4:]
 	bh.Scale = encoding.UnmarshalInt16(src)
 	src = src[2:]
-	bh.TimestampsMarshalType = encoding.MarshalType(src[0])
+	bh.unmarshalTimestampsMarshalType(src[0])
 	src = src[1:]
-	bh.ValuesMarshalType = encoding.MarshalType(src[0])
+	bh.unmarshalValuesMarshalType(src[0])
 	src = src[1:]
 	bh.PrecisionBits = uint8(src[0])
 	src = src[1:]
@@ -154,6 +164,32 @@ func (bh *blockHeader) Unmarshal(src []byte) ([]byte, error) {
 	return src, err
 }
 
+func (bh *blockHeader) marshalTimestampsMarshalType() byte {
+	mt := byte(bh.TimestampsMarshalType)
+	if bh.NanosecondTimestamps {
+		mt |= nanosecondTimestampsFlag
+	}
+	return mt
+}
+
+func (bh *blockHeader) unmarshalTimestampsMarshalType(mt byte) {
+	bh.TimestampsMarshalType = encoding.MarshalType(mt &^ nanosecondTimestampsFlag)
+	bh.NanosecondTimestamps = mt&nanosecondTimestampsFlag != 0
+}
+
+func (bh *blockHeader) marshalValuesMarshalType() byte {
+	mt := byte(bh.ValuesMarshalType)
+	if bh.Histograms {
+		mt |= histogramsFlag
+	}
+	return mt
+}
+
+func (bh *blockHeader) unmarshalValuesMarshalType(mt byte) {
+	bh.ValuesMarshalType = encoding.MarshalType(mt &^ histogramsFlag)
+	bh.Histograms = mt&histogramsFlag != 0
+}
+
 func (bh *blockHeader) validate() error {
 	if bh.RowsCount == 0 {
 		return fmt.Errorf("RowsCount in block header cannot be zero")
@@ -173,8 +209,12 @@ func (bh *blockHeader) validate() error {
 	if bh.TimestampsBlockSize > 2*maxBlockSize {
 		return fmt.Errorf("too big TimestampsBlockSize; got %d; cannot exceed %d", bh.TimestampsBlockSize, 2*maxBlockSize)
 	}
-	if bh.ValuesBlockSize > 2*maxBlockSize {
-		return fmt.Errorf("too big ValuesBlockSize; got %d; cannot exceed %d", bh.ValuesBlockSize, 2*maxBlockSize)
+	maxValuesBlockSize := uint32(2 * maxBlockSize)
+	if bh.Histograms {
+		maxValuesBlockSize = maxHistogramsBlockSize
+	}
+	if bh.ValuesBlockSize > maxValuesBlockSize {
+		return fmt.Errorf("too big ValuesBlockSize; got %d; cannot exceed %d", bh.ValuesBlockSize, maxValuesBlockSize)
 	}
 	return nil
 }
@@ -101,6 +101,11 @@ func (bsm *blockStreamMerger) nextBlock() error {
 	return nil
 }
 
+// deletedRanges returns deleted ranges for the stream containing bsm.Block.
+func (bsm *blockStreamMerger) deletedRanges() *deletedRanges {
+	return bsm.bsrHeap[0].drs
+}
+
 func (bsm *blockStreamMerger) Error() error {
 	if bsm.err == io.EOF {
 		return nil
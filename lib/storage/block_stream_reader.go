@@ -8,6 +8,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/filestream"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -25,6 +26,9 @@ type blockStreamReader struct {
 
 	ph partHeader
 
+	// drs contains deleted ranges, which must be applied to blocks read from the stream during merge.
+	drs *deletedRanges
+
 	// Use io.Reader type for timestampsReader and valuesReader
 	// in order to remove I2I conversion in readBlock
 	// when passing them to fs.ReadFullData
@@ -74,6 +78,7 @@ func (bsr *blockStreamReader) reset() {
 	bsr.path = ""
 
 	bsr.ph.Reset()
+	bsr.drs = nil
 
 	bsr.timestampsReader = nil
 	bsr.valuesReader = nil
@@ -141,20 +146,20 @@ func (bsr *blockStreamReader) InitFromFilePart(path string) error {
 	}
 
 	timestampsPath := path + "/timestamps.bin"
-	timestampsFile, err := filestream.Open(timestampsPath, true)
+	timestampsFile, err := encryption.Open(timestampsPath, true)
 	if err != nil {
 		return fmt.Errorf("cannot open timestamps file in stream mode: %w", err)
 	}
 
 	valuesPath := path + "/values.bin"
-	valuesFile, err := filestream.Open(valuesPath, true)
+	valuesFile, err := encryption.Open(valuesPath, true)
 	if err != nil {
 		timestampsFile.MustClose()
 		return fmt.Errorf("cannot open values file in stream mode: %w", err)
 	}
 
 	indexPath := path + "/index.bin"
-	indexFile, err := filestream.Open(indexPath, true)
+	indexFile, err := encryption.Open(indexPath, true)
 	if err != nil {
 		timestampsFile.MustClose()
 		valuesFile.MustClose()
@@ -162,7 +167,7 @@ func (bsr *blockStreamReader) InitFromFilePart(path string) error {
 	}
 
 	metaindexPath := path + "/metaindex.bin"
-	metaindexFile, err := filestream.Open(metaindexPath, true)
+	metaindexFile, err := encryption.Open(metaindexPath, true)
 	if err != nil {
 		timestampsFile.MustClose()
 		valuesFile.MustClose()
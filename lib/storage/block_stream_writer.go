@@ -9,6 +9,7 @@ import (
 	"sync/atomic"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/filestream"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -28,6 +29,12 @@ type blockStreamWriter struct {
 	indexWriter     filestream.WriteCloser
 	metaindexWriter filestream.WriteCloser
 
+	// checksumsWriter is an optional writer for per-block checksums. See checksumsFilename.
+	//
+	// It is nil for in-memory parts, since they are re-written into file-based parts.
+	checksumsWriter filestream.WriteCloser
+	checksumsData   []byte
+
 	mr metaindexRow
 
 	timestampsBlockOffset uint64
@@ -46,6 +53,9 @@ type blockStreamWriter struct {
 	// since such metrics have identical timestamps.
 	prevTimestampsData        []byte
 	prevTimestampsBlockOffset uint64
+
+	// bandwidthLimiter is an optional limiter for the write bandwidth during merges.
+	bandwidthLimiter *bandwidthLimiter
 }
 
 func (bsw *blockStreamWriter) assertWriteClosers() {
@@ -62,6 +72,8 @@ func (bsw *blockStreamWriter) reset() {
 	bsw.valuesWriter = nil
 	bsw.indexWriter = nil
 	bsw.metaindexWriter = nil
+	bsw.checksumsWriter = nil
+	bsw.checksumsData = bsw.checksumsData[:0]
 
 	bsw.mr.Reset()
 
@@ -77,6 +89,13 @@ func (bsw *blockStreamWriter) reset() {
 
 	bsw.prevTimestampsData = bsw.prevTimestampsData[:0]
 	bsw.prevTimestampsBlockOffset = 0
+
+	bsw.bandwidthLimiter = nil
+}
+
+// bytesWritten returns the number of data bytes written to bsw.
+func (bsw *blockStreamWriter) bytesWritten() uint64 {
+	return bsw.timestampsBlockOffset + bsw.valuesBlockOffset
 }
 
 // InitFromInmemoryPart initialzes bsw from inmemory part.
@@ -108,14 +127,14 @@ func (bsw *blockStreamWriter) InitFromFilePart(path string, nocache bool, compre
 
 	// Create part files in the directory.
 	timestampsPath := path + "/timestamps.bin"
-	timestampsFile, err := filestream.Create(timestampsPath, nocache)
+	timestampsFile, err := encryption.Create(timestampsPath, nocache)
 	if err != nil {
 		fs.MustRemoveAll(path)
 		return fmt.Errorf("cannot create timestamps file: %w", err)
 	}
 
 	valuesPath := path + "/values.bin"
-	valuesFile, err := filestream.Create(valuesPath, nocache)
+	valuesFile, err := encryption.Create(valuesPath, nocache)
 	if err != nil {
 		timestampsFile.MustClose()
 		fs.MustRemoveAll(path)
@@ -123,7 +142,7 @@ func (bsw *blockStreamWriter) InitFromFilePart(path string, nocache bool, compre
 	}
 
 	indexPath := path + "/index.bin"
-	indexFile, err := filestream.Create(indexPath, nocache)
+	indexFile, err := encryption.Create(indexPath, nocache)
 	if err != nil {
 		timestampsFile.MustClose()
 		valuesFile.MustClose()
@@ -134,7 +153,7 @@ func (bsw *blockStreamWriter) InitFromFilePart(path string, nocache bool, compre
 	// Always cache metaindex file in OS page cache, since it is immediately
 	// read after the merge.
 	metaindexPath := path + "/metaindex.bin"
-	metaindexFile, err := filestream.Create(metaindexPath, false)
+	metaindexFile, err := encryption.Create(metaindexPath, false)
 	if err != nil {
 		timestampsFile.MustClose()
 		valuesFile.MustClose()
@@ -143,6 +162,18 @@ func (bsw *blockStreamWriter) InitFromFilePart(path string, nocache bool, compre
 		return fmt.Errorf("cannot create metaindex file: %w", err)
 	}
 
+	// Checksums file contains no sensitive data, so it isn't encrypted.
+	checksumsPath := path + "/" + checksumsFilename
+	checksumsFile, err := filestream.Create(checksumsPath, nocache)
+	if err != nil {
+		timestampsFile.MustClose()
+		valuesFile.MustClose()
+		indexFile.MustClose()
+		metaindexFile.MustClose()
+		fs.MustRemoveAll(path)
+		return fmt.Errorf("cannot create checksums file: %w", err)
+	}
+
 	bsw.reset()
 	bsw.compressLevel = compressLevel
 	bsw.path = path
@@ -151,6 +182,7 @@ func (bsw *blockStreamWriter) InitFromFilePart(path string, nocache bool, compre
 	bsw.valuesWriter = valuesFile
 	bsw.indexWriter = indexFile
 	bsw.metaindexWriter = metaindexFile
+	bsw.checksumsWriter = checksumsFile
 
 	bsw.assertWriteClosers()
 
@@ -173,6 +205,9 @@ func (bsw *blockStreamWriter) MustClose() {
 	bsw.valuesWriter.(filestream.WriteCloser).MustClose()
 	bsw.indexWriter.MustClose()
 	bsw.metaindexWriter.MustClose()
+	if bsw.checksumsWriter != nil {
+		bsw.checksumsWriter.MustClose()
+	}
 
 	// Sync bsw.path contents to make sure it doesn't disappear
 	// after system crash or power loss.
@@ -200,6 +235,9 @@ func (bsw *blockStreamWriter) WriteExternalBlock(b *Block, ph *partHeader, rowsM
 	}
 	bsw.indexData = append(bsw.indexData, headerData...)
 	bsw.mr.RegisterBlockHeader(&b.bh)
+	if bsw.checksumsWriter != nil {
+		bsw.checksumsData = encoding.MarshalUint32(bsw.checksumsData, blockChecksum(headerData, timestampsData, valuesData))
+	}
 	if len(bsw.indexData) >= maxBlockSize {
 		bsw.flushIndexData()
 	}
@@ -231,6 +269,10 @@ func updatePartHeader(b *Block, ph *partHeader) {
 }
 
 func (bsw *blockStreamWriter) flushIndexData() {
+	if len(bsw.checksumsData) > 0 {
+		fs.MustWriteData(bsw.checksumsWriter, bsw.checksumsData)
+		bsw.checksumsData = bsw.checksumsData[:0]
+	}
 	if len(bsw.indexData) == 0 {
 		return
 	}
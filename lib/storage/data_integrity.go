@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// checksumsFilename is the name of the file with per-block checksums in file-based parts.
+//
+// The file contains marshaled uint32 checksum per each block in the order blocks are stored in the part.
+// See blockChecksum. Parts created by older releases have no checksums file.
+const checksumsFilename = "checksums.bin"
+
+// quarantineDirname is the name of the directory inside -storageDataPath, where corrupted parts are moved.
+const quarantineDirname = "quarantine"
+
+var crc32Table = crc32.MakeTable(crc32.Castagnoli)
+
+// blockChecksum returns checksum for the block with the given marshaled header, timestamps and values.
+func blockChecksum(headerData, timestampsData, valuesData []byte) uint32 {
+	h := crc32.Update(0, crc32Table, headerData)
+	h = crc32.Update(h, crc32Table, timestampsData)
+	return crc32.Update(h, crc32Table, valuesData)
+}
+
+// DataIntegrityCheckResult is the result of CheckDataIntegrity.
+type DataIntegrityCheckResult struct {
+	// PartsChecked is the number of checked parts.
+	PartsChecked uint64
+
+	// PartsWithoutChecksums is the number of checked parts without per-block checksums.
+	//
+	// Such parts are verified by unpacking all their blocks.
+	PartsWithoutChecksums uint64
+
+	// BlocksChecked is the number of checked blocks.
+	BlocksChecked uint64
+
+	// CorruptedParts contains parts with corrupted data.
+	CorruptedParts []CorruptedPart
+}
+
+// CorruptedPart describes a part with corrupted data.
+type CorruptedPart struct {
+	// Path is the path to the part.
+	Path string
+
+	// QuarantinePath is the path the part has been moved to. It is empty if the part hasn't been quarantined.
+	QuarantinePath string
+
+	// CorruptedBlocks is the number of blocks with checksum mismatch.
+	CorruptedBlocks uint64
+
+	// Err describes the first detected corruption.
+	Err error
+}
+
+// CheckDataIntegrity verifies data for all the parts in the storage at the given path.
+//
+// Parts with corrupted data are moved under <path>/quarantine if quarantine is set, so the storage can be opened without them.
+//
+// The storage at the given path mustn't be opened during the check.
+func CheckDataIntegrity(path string, quarantine bool) (*DataIntegrityCheckResult, error) {
+	path = filepath.Clean(path)
+	var result DataIntegrityCheckResult
+	for _, kind := range []string{"small", "big"} {
+		partitionsPath := path + "/data/" + kind
+		if !fs.IsPathExist(partitionsPath) {
+			continue
+		}
+		ptNames, err := readSubdirs(partitionsPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, ptName := range ptNames {
+			if ptName == "snapshots" {
+				continue
+			}
+			partsPath := partitionsPath + "/" + ptName
+			partNames, err := readSubdirs(partsPath)
+			if err != nil {
+				return nil, err
+			}
+			for _, partName := range partNames {
+				if partName == "tmp" || partName == "txn" || partName == "snapshots" {
+					continue
+				}
+				partPath := partsPath + "/" + partName
+				if fs.IsEmptyDir(partPath) {
+					continue
+				}
+				cp := checkPartIntegrity(partPath, &result)
+				if cp == nil {
+					continue
+				}
+				if quarantine {
+					dstPath := path + "/" + quarantineDirname + "/" + kind + "/" + ptName + "/" + partName
+					if err := movePartToQuarantine(partPath, dstPath); err != nil {
+						return nil, err
+					}
+					cp.QuarantinePath = dstPath
+				}
+				result.CorruptedParts = append(result.CorruptedParts, *cp)
+			}
+		}
+	}
+	return &result, nil
+}
+
+// checkPartIntegrity verifies the part at partPath and updates result stats.
+//
+// It returns non-nil CorruptedPart if the part contains corrupted data.
+func checkPartIntegrity(partPath string, result *DataIntegrityCheckResult) *CorruptedPart {
+	result.PartsChecked++
+	cp := &CorruptedPart{
+		Path: partPath,
+	}
+
+	var checksums []byte
+	checksumsPath := partPath + "/" + checksumsFilename
+	hasChecksums := fs.IsPathExist(checksumsPath)
+	if hasChecksums {
+		data, err := ioutil.ReadFile(checksumsPath)
+		if err != nil {
+			cp.Err = fmt.Errorf("cannot read checksums: %w", err)
+			return cp
+		}
+		checksums = data
+	} else {
+		result.PartsWithoutChecksums++
+	}
+
+	bsr := getBlockStreamReader()
+	if err := bsr.InitFromFilePart(partPath); err != nil {
+		cp.Err = err
+		return cp
+	}
+	defer putBlockStreamReader(bsr)
+
+	if hasChecksums && uint64(len(checksums)) != 4*bsr.ph.BlocksCount {
+		cp.Err = fmt.Errorf("unexpected size of %s; got %d bytes; want %d bytes for %d blocks", checksumsFilename, len(checksums), 4*bsr.ph.BlocksCount, bsr.ph.BlocksCount)
+		return cp
+	}
+	blockIdx := uint64(0)
+	for bsr.NextBlock() {
+		b := &bsr.Block
+		result.BlocksChecked++
+		if hasChecksums {
+			checksumExpected := encoding.UnmarshalUint32(checksums[4*blockIdx:])
+			if checksum := blockChecksum(b.headerData, b.timestampsData, b.valuesData); checksum != checksumExpected {
+				if cp.Err == nil {
+					cp.Err = fmt.Errorf("checksum mismatch for block #%d with TSID=%+v on time range %s; got %08X; want %08X",
+						blockIdx, &b.bh.TSID, &TimeRange{MinTimestamp: b.bh.MinTimestamp, MaxTimestamp: b.bh.MaxTimestamp}, checksum, checksumExpected)
+				}
+				cp.CorruptedBlocks++
+			}
+		} else if err := b.UnmarshalData(); err != nil {
+			if cp.Err == nil {
+				cp.Err = fmt.Errorf("cannot unpack block #%d with TSID=%+v: %w", blockIdx, &b.bh.TSID, err)
+			}
+			cp.CorruptedBlocks++
+		}
+		blockIdx++
+	}
+	if err := bsr.Error(); err != nil {
+		if cp.Err == nil {
+			cp.Err = err
+		}
+		return cp
+	}
+	if blockIdx != bsr.ph.BlocksCount {
+		if cp.Err == nil {
+			cp.Err = fmt.Errorf("unexpected number of blocks; got %d; want %d", blockIdx, bsr.ph.BlocksCount)
+		}
+		return cp
+	}
+	if cp.Err != nil {
+		return cp
+	}
+	return nil
+}
+
+func movePartToQuarantine(srcPath, dstPath string) error {
+	if err := fs.MkdirAllIfNotExist(filepath.Dir(dstPath)); err != nil {
+		return fmt.Errorf("cannot create quarantine directory: %w", err)
+	}
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		return fmt.Errorf("cannot move corrupted part %q to quarantine %q: %w", srcPath, dstPath, err)
+	}
+	fs.MustSyncPath(filepath.Dir(srcPath))
+	fs.MustSyncPath(filepath.Dir(dstPath))
+	logger.Infof("moved corrupted part %q to quarantine %q", srcPath, dstPath)
+	return nil
+}
+
+func readSubdirs(path string) ([]string, error) {
+	fis, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read directory %q: %w", path, err)
+	}
+	var names []string
+	for _, fi := range fis {
+		if fs.IsDirOrSymlink(fi) {
+			names = append(names, fi.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestCheckDataIntegrity(t *testing.T) {
+	const path = "TestCheckDataIntegrity"
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	timestamp := time.Now().UnixNano() / 1e6
+	var mrs []MetricRow
+	for i := 0; i < 1000; i++ {
+		mn := MetricName{
+			MetricGroup: []byte("metric"),
+		}
+		mn.AddTag("job", "test")
+		mn.AddTag("instance", string(rune('a'+i%26)))
+		mrs = append(mrs, MetricRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     timestamp - int64(i)*1000,
+			Value:         float64(i),
+		})
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	s.MustClose()
+
+	checkResult := func(quarantine bool, corruptedPartsExpected int) *DataIntegrityCheckResult {
+		t.Helper()
+		result, err := CheckDataIntegrity(path, quarantine)
+		if err != nil {
+			t.Fatalf("cannot check data integrity: %s", err)
+		}
+		if result.PartsWithoutChecksums != 0 {
+			t.Fatalf("unexpected number of parts without checksums; got %d; want 0", result.PartsWithoutChecksums)
+		}
+		if len(result.CorruptedParts) != corruptedPartsExpected {
+			t.Fatalf("unexpected number of corrupted parts; got %d; want %d; parts: %+v", len(result.CorruptedParts), corruptedPartsExpected, result.CorruptedParts)
+		}
+		return result
+	}
+	result := checkResult(false, 0)
+	if result.PartsChecked == 0 || result.BlocksChecked == 0 {
+		t.Fatalf("expecting non-zero number of checked parts and blocks; got %+v", result)
+	}
+
+	// Corrupt values data for a part.
+	valuesPaths, err := filepath.Glob(path + "/data/*/*/*/values.bin")
+	if err != nil {
+		t.Fatalf("cannot find values files: %s", err)
+	}
+	if len(valuesPaths) == 0 {
+		t.Fatalf("cannot find values files")
+	}
+	data, err := ioutil.ReadFile(valuesPaths[0])
+	if err != nil {
+		t.Fatalf("cannot read %q: %s", valuesPaths[0], err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := ioutil.WriteFile(valuesPaths[0], data, 0644); err != nil {
+		t.Fatalf("cannot write %q: %s", valuesPaths[0], err)
+	}
+	partPath := filepath.Dir(valuesPaths[0])
+
+	result = checkResult(false, 1)
+	cp := &result.CorruptedParts[0]
+	if cp.Path != partPath {
+		t.Fatalf("unexpected corrupted part; got %q; want %q", cp.Path, partPath)
+	}
+	if cp.CorruptedBlocks == 0 || cp.Err == nil {
+		t.Fatalf("expecting non-zero corrupted blocks and non-nil error; got %+v", cp)
+	}
+	if cp.QuarantinePath != "" {
+		t.Fatalf("the part mustn't be quarantined; got quarantine path %q", cp.QuarantinePath)
+	}
+
+	// Move the corrupted part to quarantine.
+	result = checkResult(true, 1)
+	cp = &result.CorruptedParts[0]
+	if fs.IsPathExist(partPath) {
+		t.Fatalf("corrupted part %q must be moved to quarantine", partPath)
+	}
+	if !fs.IsPathExist(cp.QuarantinePath + "/values.bin") {
+		t.Fatalf("cannot find quarantined part at %q", cp.QuarantinePath)
+	}
+	checkResult(false, 0)
+
+	// The storage must be opened without the quarantined part.
+	s, err = OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage after quarantine: %s", err)
+	}
+	s.MustClose()
+}
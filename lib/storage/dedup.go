@@ -1,7 +1,11 @@
 package storage
 
 import (
+	"fmt"
+	"math"
 	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 )
 
 // SetMinScrapeIntervalForDeduplication sets the minimum interval for data points during de-duplication.
@@ -15,8 +19,47 @@ func SetMinScrapeIntervalForDeduplication(interval time.Duration) {
 
 var minScrapeInterval = int64(0)
 
+// dedupPolicy defines which sample is left per each -dedup.minScrapeInterval.
+type dedupPolicy int
+
+const (
+	dedupPolicyFirst dedupPolicy = iota
+	dedupPolicyLast
+	dedupPolicyMin
+	dedupPolicyMax
+)
+
+var dedupPolicyNames = map[string]dedupPolicy{
+	"first": dedupPolicyFirst,
+	"last":  dedupPolicyLast,
+	"min":   dedupPolicyMin,
+	"max":   dedupPolicyMax,
+}
+
+// SetDedupPolicy sets the policy for selecting the sample, which is left per each interval during de-duplication.
+//
+// Supported policies: first, last, min and max. The first sample is left by default.
+// NaN values are left by min and max policies only if there are no other samples on the interval.
+//
+// This function must be called before initializing the storage.
+func SetDedupPolicy(policy string) error {
+	dp, ok := dedupPolicyNames[policy]
+	if !ok {
+		return fmt.Errorf("unsupported dedup policy %q; supported values: first, last, min, max", policy)
+	}
+	dedupSamplePolicy = dp
+	return nil
+}
+
+var dedupSamplePolicy = dedupPolicyFirst
+
 // DeduplicateSamples removes samples from src* if they are closer to each other than minScrapeInterval.
+//
+// Samples older than the offsets from -downsampling.period are de-duplicated with the corresponding intervals.
 func DeduplicateSamples(srcTimestamps []int64, srcValues []float64) ([]int64, []float64) {
+	if len(downsamplingPeriods) > 0 {
+		return downsampleSamples(srcTimestamps, srcValues, currentTimestampMsecs())
+	}
 	if minScrapeInterval <= 0 {
 		return srcTimestamps, srcValues
 	}
@@ -28,6 +71,9 @@ func DeduplicateSamples(srcTimestamps []int64, srcValues []float64) ([]int64, []
 }
 
 func deduplicateInternal(interval int64, srcTimestamps []int64, srcValues []float64) ([]int64, []float64) {
+	if dedupSamplePolicy != dedupPolicyFirst {
+		return deduplicateWithPolicyInternal(interval, srcTimestamps, srcValues)
+	}
 	tsNext := (srcTimestamps[0] - srcTimestamps[0]%interval) + interval
 	dstTimestamps := srcTimestamps[:1]
 	dstValues := srcValues[:1]
@@ -50,6 +96,9 @@ func deduplicateInternal(interval int64, srcTimestamps []int64, srcValues []floa
 }
 
 func deduplicateSamplesDuringMerge(srcTimestamps, srcValues []int64) ([]int64, []int64) {
+	if len(downsamplingPeriods) > 0 {
+		return downsampleSamplesDuringMerge(srcTimestamps, srcValues, currentTimestampMsecs())
+	}
 	if minScrapeInterval <= 0 {
 		return srcTimestamps, srcValues
 	}
@@ -61,6 +110,9 @@ func deduplicateSamplesDuringMerge(srcTimestamps, srcValues []int64) ([]int64, [
 }
 
 func deduplicateDuringMergeInternal(interval int64, srcTimestamps, srcValues []int64) ([]int64, []int64) {
+	if dedupSamplePolicy != dedupPolicyFirst {
+		return deduplicateDuringMergeWithPolicyInternal(interval, srcTimestamps, srcValues)
+	}
 	tsNext := (srcTimestamps[0] - srcTimestamps[0]%interval) + interval
 	dstTimestamps := srcTimestamps[:1]
 	dstValues := srcValues[:1]
@@ -82,6 +134,68 @@ func deduplicateDuringMergeInternal(interval int64, srcTimestamps, srcValues []i
 	return dstTimestamps, dstValues
 }
 
+func deduplicateWithPolicyInternal(interval int64, srcTimestamps []int64, srcValues []float64) ([]int64, []float64) {
+	dstTimestamps := srcTimestamps[:0]
+	dstValues := srcValues[:0]
+	i := 0
+	for i < len(srcTimestamps) {
+		ts := srcTimestamps[i]
+		tsNext := (ts - ts%interval) + interval
+		best := i
+		for i++; i < len(srcTimestamps) && srcTimestamps[i] < tsNext; i++ {
+			v, vBest := srcValues[i], srcValues[best]
+			switch dedupSamplePolicy {
+			case dedupPolicyLast:
+				best = i
+			case dedupPolicyMin:
+				if math.IsNaN(vBest) && !math.IsNaN(v) || v < vBest {
+					best = i
+				}
+			case dedupPolicyMax:
+				if math.IsNaN(vBest) && !math.IsNaN(v) || v > vBest {
+					best = i
+				}
+			}
+		}
+		dstTimestamps = append(dstTimestamps, srcTimestamps[best])
+		dstValues = append(dstValues, srcValues[best])
+	}
+	return dstTimestamps, dstValues
+}
+
+// staleNaNDecimal is the decimal representation of Prometheus staleness mark.
+var staleNaNDecimal, _ = decimal.FromFloat(decimal.StaleNaN)
+
+func deduplicateDuringMergeWithPolicyInternal(interval int64, srcTimestamps, srcValues []int64) ([]int64, []int64) {
+	dstTimestamps := srcTimestamps[:0]
+	dstValues := srcValues[:0]
+	i := 0
+	for i < len(srcTimestamps) {
+		ts := srcTimestamps[i]
+		tsNext := (ts - ts%interval) + interval
+		best := i
+		for i++; i < len(srcTimestamps) && srcTimestamps[i] < tsNext; i++ {
+			v, vBest := srcValues[i], srcValues[best]
+			// Values have the same scale inside a block, so they can be compared directly.
+			switch dedupSamplePolicy {
+			case dedupPolicyLast:
+				best = i
+			case dedupPolicyMin:
+				if vBest == staleNaNDecimal && v != staleNaNDecimal || v < vBest && v != staleNaNDecimal {
+					best = i
+				}
+			case dedupPolicyMax:
+				if vBest == staleNaNDecimal && v != staleNaNDecimal || v > vBest && v != staleNaNDecimal {
+					best = i
+				}
+			}
+		}
+		dstTimestamps = append(dstTimestamps, srcTimestamps[best])
+		dstValues = append(dstValues, srcValues[best])
+	}
+	return dstTimestamps, dstValues
+}
+
 func needsDedup(timestamps []int64, interval int64) bool {
 	if len(timestamps) == 0 || interval <= 0 {
 		return false
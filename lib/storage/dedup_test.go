@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"fmt"
+	"math"
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 )
 
 func TestNeedsDedup(t *testing.T) {
@@ -157,3 +161,88 @@ func TestDeduplicateSamplesDuringMerge(t *testing.T) {
 	f(time.Second, timestamps, timestamps)
 	f(2*time.Second, timestamps, timestampsExpected)
 }
+
+func TestSetDedupPolicy(t *testing.T) {
+	defer func() {
+		dedupSamplePolicy = dedupPolicyFirst
+	}()
+	f := func(policy string, dpExpected dedupPolicy) {
+		t.Helper()
+		if err := SetDedupPolicy(policy); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if dedupSamplePolicy != dpExpected {
+			t.Fatalf("unexpected dedup policy for %q; got %d; want %d", policy, dedupSamplePolicy, dpExpected)
+		}
+	}
+	f("first", dedupPolicyFirst)
+	f("last", dedupPolicyLast)
+	f("min", dedupPolicyMin)
+	f("max", dedupPolicyMax)
+
+	for _, policy := range []string{"", "foo", "MAX"} {
+		if err := SetDedupPolicy(policy); err == nil {
+			t.Fatalf("expecting non-nil error for %q", policy)
+		}
+	}
+}
+
+func TestDeduplicateSamplesWithPolicy(t *testing.T) {
+	defer func() {
+		SetMinScrapeIntervalForDeduplication(0)
+		dedupSamplePolicy = dedupPolicyFirst
+	}()
+	SetMinScrapeIntervalForDeduplication(100 * time.Millisecond)
+	nan := decimal.StaleNaN
+	timestamps := []int64{0, 50, 90, 100, 120, 180, 200, 250, 300}
+	values := []float64{5, 3, 4, 1, 9, 2, nan, 7, 8}
+
+	f := func(policy string, timestampsExpected []int64, valuesExpected []float64) {
+		t.Helper()
+		if err := SetDedupPolicy(policy); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// Verify deduplication at query time.
+		timestampsCopy := append([]int64{}, timestamps...)
+		valuesCopy := append([]float64{}, values...)
+		timestampsCopy, valuesCopy = DeduplicateSamples(timestampsCopy, valuesCopy)
+		if !reflect.DeepEqual(timestampsCopy, timestampsExpected) {
+			t.Fatalf("unexpected timestamps for policy %q;\ngot\n%v\nwant\n%v", policy, timestampsCopy, timestampsExpected)
+		}
+		// Compare string representations, since NaN != NaN.
+		if fmt.Sprint(valuesCopy) != fmt.Sprint(valuesExpected) {
+			t.Fatalf("unexpected values for policy %q;\ngot\n%v\nwant\n%v", policy, valuesCopy, valuesExpected)
+		}
+
+		// Verify deduplication during merge, where NaN is represented by staleNaNDecimal.
+		timestampsCopy = append([]int64{}, timestamps...)
+		var valuesInt []int64
+		for _, v := range values {
+			if math.IsNaN(v) {
+				valuesInt = append(valuesInt, staleNaNDecimal)
+			} else {
+				valuesInt = append(valuesInt, int64(v))
+			}
+		}
+		var valuesIntExpected []int64
+		for _, v := range valuesExpected {
+			if math.IsNaN(v) {
+				valuesIntExpected = append(valuesIntExpected, staleNaNDecimal)
+			} else {
+				valuesIntExpected = append(valuesIntExpected, int64(v))
+			}
+		}
+		timestampsCopy, valuesInt = deduplicateSamplesDuringMerge(timestampsCopy, valuesInt)
+		if !reflect.DeepEqual(timestampsCopy, timestampsExpected) {
+			t.Fatalf("unexpected timestamps during merge for policy %q;\ngot\n%v\nwant\n%v", policy, timestampsCopy, timestampsExpected)
+		}
+		if !reflect.DeepEqual(valuesInt, valuesIntExpected) {
+			t.Fatalf("unexpected values during merge for policy %q;\ngot\n%v\nwant\n%v", policy, valuesInt, valuesIntExpected)
+		}
+	}
+	f("first", []int64{0, 100, 200, 300}, []float64{5, 1, nan, 8})
+	f("last", []int64{90, 180, 250, 300}, []float64{4, 2, 7, 8})
+	f("min", []int64{50, 100, 250, 300}, []float64{3, 1, 7, 8})
+	f("max", []int64{0, 120, 250, 300}, []float64{5, 9, 7, 8})
+}
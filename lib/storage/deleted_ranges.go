@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+// deletedRange contains metricIDs with deleted samples on the given time range.
+type deletedRange struct {
+	tr        TimeRange
+	metricIDs *uint64set.Set
+
+	// createdAt is the part mergeIdx at the time of the deletion.
+	//
+	// The range applies only to parts with smaller mergeIdx, i.e. to parts created before the deletion,
+	// so samples added on the deleted time range after the deletion remain visible.
+	createdAt uint64
+}
+
+// deletedRanges contains time ranges with deleted samples registered via Storage.DeleteMetricsOnTimeRange.
+//
+// deletedRanges is immutable, so it may be safely shared among goroutines.
+// Samples on deleted ranges are filtered out during search and are physically removed during background merges.
+type deletedRanges struct {
+	ranges []deletedRange
+}
+
+// forPart returns deleted ranges, which must be applied to samples from p.
+//
+// nil is returned if p has no deleted samples. This allows skipping per-block checks for the majority of parts.
+func (drs *deletedRanges) forPart(p *part) *deletedRanges {
+	if drs == nil {
+		return nil
+	}
+	var ranges []deletedRange
+	for _, dr := range drs.ranges {
+		if dr.createdAt > p.mergeIdx && dr.tr.MinTimestamp <= p.ph.MaxTimestamp && dr.tr.MaxTimestamp >= p.ph.MinTimestamp {
+			ranges = append(ranges, dr)
+		}
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+	return &deletedRanges{
+		ranges: ranges,
+	}
+}
+
+// overlapsBlock returns true if the block for the given metricID on the given time range may contain deleted samples.
+func (drs *deletedRanges) overlapsBlock(metricID uint64, minTimestamp, maxTimestamp int64) bool {
+	if drs == nil {
+		return false
+	}
+	for i := range drs.ranges {
+		dr := &drs.ranges[i]
+		if dr.tr.MinTimestamp <= maxTimestamp && dr.tr.MaxTimestamp >= minTimestamp && dr.metricIDs.Has(metricID) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversBlock returns true if all the samples of the block for the given metricID on the given time range are deleted.
+func (drs *deletedRanges) coversBlock(metricID uint64, minTimestamp, maxTimestamp int64) bool {
+	if drs == nil {
+		return false
+	}
+	for i := range drs.ranges {
+		dr := &drs.ranges[i]
+		if dr.tr.MinTimestamp <= minTimestamp && dr.tr.MaxTimestamp >= maxTimestamp && dr.metricIDs.Has(metricID) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDeleted returns true if the sample with the given timestamp for the given metricID is deleted.
+func (drs *deletedRanges) isDeleted(metricID uint64, timestamp int64) bool {
+	return drs.overlapsBlock(metricID, timestamp, timestamp)
+}
+
+// removeDeletedRows removes samples on drs from the unmarshaled b and returns the number of removed samples.
+//
+// bh.RowsCount, bh.MinTimestamp and bh.MaxTimestamp are updated for the remaining samples.
+func (b *Block) removeDeletedRows(drs *deletedRanges) int {
+	b.assertUnmarshaled()
+	metricID := b.bh.TSID.MetricID
+	timestamps := b.timestamps[:b.nextIdx]
+	values := b.values[:b.nextIdx]
+	var histograms [][]byte
+	if b.bh.Histograms {
+		histograms = b.histograms[:b.nextIdx]
+	}
+	for i, timestamp := range b.timestamps[b.nextIdx:] {
+		if drs.isDeleted(metricID, b.timestampToMsecs(timestamp)) {
+			continue
+		}
+		timestamps = append(timestamps, timestamp)
+		values = append(values, b.values[b.nextIdx+i])
+		if b.bh.Histograms {
+			histograms = append(histograms, b.histograms[b.nextIdx+i])
+		}
+	}
+	n := len(b.timestamps) - len(timestamps)
+	b.timestamps = timestamps
+	b.values = values
+	if b.bh.Histograms {
+		b.histograms = histograms
+	}
+	b.bh.RowsCount = uint32(len(timestamps) - b.nextIdx)
+	if b.bh.RowsCount > 0 {
+		b.fixupTimestamps()
+	}
+	return n
+}
+
+// addRange returns new deletedRanges with the added range for the given metricIDs.
+//
+// The range applies to parts with mergeIdx smaller than createdAt.
+// Ranges with samples older than minTimestamp are dropped from the returned deletedRanges,
+// since they are already out of retention.
+func (drs *deletedRanges) addRange(tr TimeRange, metricIDs *uint64set.Set, minTimestamp int64, createdAt uint64) *deletedRanges {
+	var drsNew deletedRanges
+	if drs != nil {
+		for _, dr := range drs.ranges {
+			if dr.tr.MaxTimestamp < minTimestamp {
+				continue
+			}
+			drsNew.ranges = append(drsNew.ranges, dr)
+		}
+	}
+	drsNew.ranges = append(drsNew.ranges, deletedRange{
+		tr:        tr,
+		metricIDs: metricIDs,
+		createdAt: createdAt,
+	})
+	return &drsNew
+}
+
+func (drs *deletedRanges) marshal(dst []byte) []byte {
+	dst = encoding.MarshalUint64(dst, uint64(len(drs.ranges)))
+	for _, dr := range drs.ranges {
+		dst = encoding.MarshalInt64(dst, dr.tr.MinTimestamp)
+		dst = encoding.MarshalInt64(dst, dr.tr.MaxTimestamp)
+		dst = encoding.MarshalUint64(dst, dr.createdAt)
+		dst = marshalUint64Set(dst, dr.metricIDs)
+	}
+	return dst
+}
+
+func (drs *deletedRanges) unmarshal(src []byte) error {
+	if len(src) < 8 {
+		return fmt.Errorf("cannot unmarshal the number of ranges; got %d bytes; want at least 8 bytes", len(src))
+	}
+	rangesLen := encoding.UnmarshalUint64(src)
+	src = src[8:]
+	drs.ranges = drs.ranges[:0]
+	for i := uint64(0); i < rangesLen; i++ {
+		if len(src) < 32 {
+			return fmt.Errorf("cannot unmarshal range #%d; got %d bytes; want at least 32 bytes", i, len(src))
+		}
+		var dr deletedRange
+		dr.tr.MinTimestamp = encoding.UnmarshalInt64(src)
+		dr.tr.MaxTimestamp = encoding.UnmarshalInt64(src[8:])
+		dr.createdAt = encoding.UnmarshalUint64(src[16:])
+		metricIDs, tail, err := unmarshalUint64Set(src[24:])
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal metricIDs for range #%d: %w", i, err)
+		}
+		dr.metricIDs = metricIDs
+		src = tail
+		drs.ranges = append(drs.ranges, dr)
+	}
+	if len(src) > 0 {
+		return fmt.Errorf("unexpected non-empty tail left after unmarshaling deleted ranges; len(tail)=%d", len(src))
+	}
+	return nil
+}
+
+func mustLoadDeletedRanges(path string) *deletedRanges {
+	var drs deletedRanges
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &drs
+		}
+		logger.Panicf("FATAL: cannot read deleted ranges: %s", err)
+	}
+	if err := drs.unmarshal(data); err != nil {
+		logger.Panicf("FATAL: cannot unmarshal deleted ranges from %q: %s", path, err)
+	}
+	return &drs
+}
+
+func mustSaveDeletedRanges(path string, drs *deletedRanges) {
+	data := drs.marshal(nil)
+	tmpPath := path + ".tmp"
+	fs.MustRemoveAll(tmpPath)
+	if err := fs.WriteFileAtomically(tmpPath, data); err != nil {
+		logger.Panicf("FATAL: cannot store deleted ranges: %s", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		logger.Panicf("FATAL: cannot move %q to %q: %s", tmpPath, path, err)
+	}
+	fs.MustSyncPath(filepath.Dir(path))
+}
+
+// nextDeletedRangeMergeIdx returns mergeIdx for the new deleted range.
+//
+// The returned mergeIdx is bigger than mergeIdx for all the existing parts in tb.
+func (tb *table) nextDeletedRangeMergeIdx() uint64 {
+	mergeIdx := uint64(time.Now().UnixNano())
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+	for _, ptw := range ptws {
+		if n := atomic.LoadUint64(&ptw.pt.mergeIdx) + 1; n > mergeIdx {
+			mergeIdx = n
+		}
+	}
+	return mergeIdx
+}
+
+// bumpMergeIdx makes sure parts created in tb after the call have mergeIdx bigger than the given mergeIdx.
+func (tb *table) bumpMergeIdx(mergeIdx uint64) {
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+	for _, ptw := range ptws {
+		pt := ptw.pt
+		for {
+			n := atomic.LoadUint64(&pt.mergeIdx)
+			if n >= mergeIdx || atomic.CompareAndSwapUint64(&pt.mergeIdx, n, mergeIdx) {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+func newTestDeletedRanges(minTimestamp, maxTimestamp int64, metricIDs ...uint64) *deletedRanges {
+	var m uint64set.Set
+	m.AddMulti(metricIDs)
+	tr := TimeRange{
+		MinTimestamp: minTimestamp,
+		MaxTimestamp: maxTimestamp,
+	}
+	var drs *deletedRanges
+	return drs.addRange(tr, &m, 0, 1)
+}
+
+func TestDeletedRangesOverlapsBlock(t *testing.T) {
+	drs := newTestDeletedRanges(10, 20, 1, 3)
+	f := func(metricID uint64, minTimestamp, maxTimestamp int64, overlapsExpected, coversExpected bool) {
+		t.Helper()
+		if overlaps := drs.overlapsBlock(metricID, minTimestamp, maxTimestamp); overlaps != overlapsExpected {
+			t.Fatalf("unexpected overlapsBlock(%d, %d, %d); got %v; want %v", metricID, minTimestamp, maxTimestamp, overlaps, overlapsExpected)
+		}
+		if covers := drs.coversBlock(metricID, minTimestamp, maxTimestamp); covers != coversExpected {
+			t.Fatalf("unexpected coversBlock(%d, %d, %d); got %v; want %v", metricID, minTimestamp, maxTimestamp, covers, coversExpected)
+		}
+	}
+	f(1, 0, 9, false, false)
+	f(1, 0, 10, true, false)
+	f(1, 12, 18, true, true)
+	f(3, 10, 20, true, true)
+	f(1, 15, 30, true, false)
+	f(1, 21, 30, false, false)
+	f(2, 12, 18, false, false)
+
+	// nil deletedRanges must contain nothing
+	drs = nil
+	f(1, 12, 18, false, false)
+}
+
+func TestBlockRemoveDeletedRows(t *testing.T) {
+	f := func(drs *deletedRanges, timestamps, timestampsExpected []int64) {
+		t.Helper()
+		var b Block
+		values := make([]int64, len(timestamps))
+		for i := range values {
+			values[i] = int64(i)
+		}
+		b.Init(&TSID{MetricID: 1}, timestamps, values, 0, 64)
+		n := b.removeDeletedRows(drs)
+		if n != len(timestamps)-len(timestampsExpected) {
+			t.Fatalf("unexpected number of removed rows; got %d; want %d", n, len(timestamps)-len(timestampsExpected))
+		}
+		if int(b.bh.RowsCount) != len(timestampsExpected) {
+			t.Fatalf("unexpected RowsCount; got %d; want %d", b.bh.RowsCount, len(timestampsExpected))
+		}
+		if len(timestampsExpected) == 0 {
+			if len(b.timestamps) != 0 || len(b.values) != 0 {
+				t.Fatalf("expecting empty block; got timestamps=%d, values=%d", b.timestamps, b.values)
+			}
+			return
+		}
+		if !reflect.DeepEqual(b.timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps; got %d; want %d", b.timestamps, timestampsExpected)
+		}
+		for i, timestamp := range b.timestamps {
+			if timestamps[b.values[i]] != timestamp {
+				t.Fatalf("value at position %d doesn't match timestamp %d", i, timestamp)
+			}
+		}
+		if b.bh.MinTimestamp != timestampsExpected[0] || b.bh.MaxTimestamp != timestampsExpected[len(timestampsExpected)-1] {
+			t.Fatalf("unexpected block time range: [%d..%d]", b.bh.MinTimestamp, b.bh.MaxTimestamp)
+		}
+	}
+	drs := newTestDeletedRanges(10, 20, 1)
+	f(drs, []int64{1, 2, 3}, []int64{1, 2, 3})
+	f(drs, []int64{5, 10, 15, 20, 25}, []int64{5, 25})
+	f(drs, []int64{10, 11, 30}, []int64{30})
+	f(drs, []int64{10, 15, 20}, nil)
+
+	// Samples for other metricIDs must be left untouched.
+	f(newTestDeletedRanges(10, 20, 2), []int64{5, 10, 15}, []int64{5, 10, 15})
+
+	// Multiple ranges
+	var m uint64set.Set
+	m.Add(1)
+	drs = drs.addRange(TimeRange{MinTimestamp: 30, MaxTimestamp: 40}, &m, 0, 1)
+	f(drs, []int64{5, 15, 25, 35, 45}, []int64{5, 25, 45})
+}
+
+func TestDeletedRangesAddRange(t *testing.T) {
+	var m uint64set.Set
+	m.Add(2)
+	drs := newTestDeletedRanges(10, 20, 1)
+
+	// The same time range deleted later must be kept separately, since it applies to distinct parts.
+	drs = drs.addRange(TimeRange{MinTimestamp: 10, MaxTimestamp: 20}, &m, 0, 2)
+	if len(drs.ranges) != 2 {
+		t.Fatalf("unexpected number of ranges; got %d; want 2", len(drs.ranges))
+	}
+	if drs.ranges[0].metricIDs.Has(2) || drs.ranges[1].metricIDs.Has(1) || drs.ranges[1].createdAt != 2 {
+		t.Fatalf("unexpected ranges after adding the same time range: %+v", drs.ranges)
+	}
+
+	// Ranges outside retention must be dropped.
+	drs = drs.addRange(TimeRange{MinTimestamp: 50, MaxTimestamp: 60}, &m, 25, 3)
+	if len(drs.ranges) != 1 || drs.ranges[0].tr.MinTimestamp != 50 {
+		t.Fatalf("unexpected ranges after dropping outdated range: %+v", drs.ranges)
+	}
+}
+
+func TestDeletedRangesForPart(t *testing.T) {
+	var m uint64set.Set
+	m.Add(1)
+	drs := newTestDeletedRanges(10, 20, 1).addRange(TimeRange{MinTimestamp: 30, MaxTimestamp: 40}, &m, 0, 10)
+	f := func(mergeIdx uint64, minTimestamp, maxTimestamp int64, rangesExpected int) {
+		t.Helper()
+		p := &part{
+			mergeIdx: mergeIdx,
+		}
+		p.ph.MinTimestamp = minTimestamp
+		p.ph.MaxTimestamp = maxTimestamp
+		drsPart := drs.forPart(p)
+		if rangesExpected == 0 {
+			if drsPart != nil {
+				t.Fatalf("expecting nil deleted ranges for part; got %+v", drsPart.ranges)
+			}
+			return
+		}
+		if drsPart == nil || len(drsPart.ranges) != rangesExpected {
+			t.Fatalf("unexpected deleted ranges for part; got %+v; want %d ranges", drsPart, rangesExpected)
+		}
+	}
+	// parts created before the deletion
+	f(0, 0, 100, 2)
+	f(0, 15, 25, 1)
+	f(0, 21, 29, 0)
+	// parts created after the first deletion
+	f(5, 0, 100, 1)
+	f(5, 0, 25, 0)
+	// parts created after all the deletions
+	f(10, 0, 100, 0)
+
+	// nil deleted ranges
+	drs = nil
+	f(0, 0, 100, 0)
+}
+
+func TestDeletedRangesMarshalUnmarshal(t *testing.T) {
+	f := func(drs *deletedRanges) {
+		t.Helper()
+		data := drs.marshal(nil)
+		var drs2 deletedRanges
+		if err := drs2.unmarshal(data); err != nil {
+			t.Fatalf("cannot unmarshal deleted ranges: %s", err)
+		}
+		if len(drs.ranges) != len(drs2.ranges) {
+			t.Fatalf("unexpected number of ranges; got %d; want %d", len(drs2.ranges), len(drs.ranges))
+		}
+		for i := range drs.ranges {
+			dr := &drs.ranges[i]
+			dr2 := &drs2.ranges[i]
+			if dr.tr != dr2.tr {
+				t.Fatalf("unexpected time range #%d; got %s; want %s", i, &dr2.tr, &dr.tr)
+			}
+			if dr.createdAt != dr2.createdAt {
+				t.Fatalf("unexpected createdAt for range #%d; got %d; want %d", i, dr2.createdAt, dr.createdAt)
+			}
+			if !dr.metricIDs.Equal(dr2.metricIDs) {
+				t.Fatalf("unexpected metricIDs for range #%d; got %d; want %d", i, dr2.metricIDs.AppendTo(nil), dr.metricIDs.AppendTo(nil))
+			}
+		}
+		if err := drs2.unmarshal(data[:len(data)-1]); err == nil {
+			t.Fatalf("expecting non-nil error when unmarshaling truncated data")
+		}
+	}
+	f(&deletedRanges{})
+	f(newTestDeletedRanges(10, 20, 1, 2, 3))
+	var m uint64set.Set
+	m.AddMulti([]uint64{4, 5, 1 << 40})
+	f(newTestDeletedRanges(-10, 20, 1).addRange(TimeRange{MinTimestamp: 30, MaxTimestamp: 40}, &m, 0, 1<<62))
+}
+
+func TestStorageDeleteMetricsOnTimeRange(t *testing.T) {
+	path := "TestStorageDeleteMetricsOnTimeRange"
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	const rowsPerMetric = 100
+	now := time.Now().UnixNano() / 1e6
+	minTimestamp := now - rowsPerMetric*1000
+	var mrs []MetricRow
+	for _, metricGroup := range []string{"foo", "bar"} {
+		mn := MetricName{
+			MetricGroup: []byte(metricGroup),
+		}
+		metricNameRaw := mn.marshalRaw(nil)
+		for i := 0; i < rowsPerMetric; i++ {
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     minTimestamp + int64(i)*1000,
+				Value:         float64(i),
+			})
+		}
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	s.DebugFlush()
+
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("foo"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	trDelete := TimeRange{
+		MinTimestamp: minTimestamp + 10*1000,
+		MaxTimestamp: minTimestamp + 29*1000,
+	}
+	n, err := s.DeleteMetricsOnTimeRange([]*TagFilters{tfs}, trDelete)
+	if err != nil {
+		t.Fatalf("cannot delete samples: %s", err)
+	}
+	if n != 1 {
+		t.Fatalf("unexpected number of metrics with deleted samples; got %d; want 1", n)
+	}
+
+	// Deleted samples must become invisible immediately.
+	rowsExpected := map[string]int{
+		"foo": rowsPerMetric - 20,
+		"bar": rowsPerMetric,
+	}
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows after deletion: %s", err)
+	}
+
+	// Deleted samples must be removed during merge.
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if rowsDeleted := m.TableMetrics.SmallRowsDeleted + m.TableMetrics.BigRowsDeleted; rowsDeleted != 20 {
+		t.Fatalf("unexpected number of deleted rows after merge; got %d; want 20", rowsDeleted)
+	}
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows after merge: %s", err)
+	}
+
+	// Samples added on the deleted time range after the deletion must be visible.
+	var mrsReimported []MetricRow
+	for _, mr := range mrs[:rowsPerMetric] {
+		if mr.Timestamp >= trDelete.MinTimestamp && mr.Timestamp <= trDelete.MaxTimestamp {
+			mrsReimported = append(mrsReimported, mr)
+		}
+	}
+	if err := s.AddRows(mrsReimported, defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	s.DebugFlush()
+	rowsExpected["foo"] = rowsPerMetric
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows after re-importing deleted samples: %s", err)
+	}
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows after merging re-imported samples: %s", err)
+	}
+
+	// Deleted ranges must be persisted.
+	s.MustClose()
+	s, err = OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot re-open storage: %s", err)
+	}
+	if drs := s.getDeletedRanges(); len(drs.ranges) != 1 || drs.ranges[0].tr != trDelete {
+		t.Fatalf("unexpected deleted ranges after re-opening the storage: %+v", drs.ranges)
+	}
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows after re-opening the storage: %s", err)
+	}
+
+	s.MustClose()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
+func testCountStorageRows(s *Storage, minTimestamp, maxTimestamp int64, rowsExpected map[string]int) error {
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte(".+"), false, true); err != nil {
+		return fmt.Errorf("cannot add tag filter: %w", err)
+	}
+	tr := TimeRange{
+		MinTimestamp: minTimestamp,
+		MaxTimestamp: maxTimestamp,
+	}
+	rows := make(map[string]int)
+	var sr Search
+	var mn MetricName
+	var b Block
+	var timestamps []int64
+	var values []float64
+	sr.Init(s, []*TagFilters{tfs}, tr, 1e5, noDeadline)
+	for sr.NextMetricBlock() {
+		if err := mn.Unmarshal(sr.MetricBlockRef.MetricName); err != nil {
+			return fmt.Errorf("cannot unmarshal metric name: %w", err)
+		}
+		sr.MetricBlockRef.BlockRef.MustReadBlock(&b, true)
+		if err := b.UnmarshalData(); err != nil {
+			return fmt.Errorf("cannot unmarshal block: %w", err)
+		}
+		timestamps, values = b.AppendRowsWithTimeRangeFilter(timestamps[:0], values[:0], tr)
+		if len(timestamps) > 0 {
+			rows[string(mn.MetricGroup)] += len(timestamps)
+		}
+	}
+	if err := sr.Error(); err != nil {
+		return fmt.Errorf("search error: %w", err)
+	}
+	sr.MustClose()
+	if !reflect.DeepEqual(rows, rowsExpected) {
+		return fmt.Errorf("unexpected rows; got %v; want %v", rows, rowsExpected)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+)
+
+// seriesDiskUsageCacheDuration is the duration for caching the results of Storage.getSeriesDiskUsage.
+//
+// The calculation of disk usage requires reading block headers for all the parts, so it mustn't be performed on every request.
+const seriesDiskUsageCacheDuration = 5 * 60
+
+// seriesDiskUsage contains approximate on-disk sizes for time series.
+type seriesDiskUsage struct {
+	mu sync.Mutex
+
+	// bytesByMetricID contains the approximate number of bytes occupied on disk by every metricID.
+	bytesByMetricID map[uint64]uint64
+
+	// updatedAt is the unix timestamp in seconds when bytesByMetricID has been calculated.
+	updatedAt uint64
+}
+
+// getSeriesDiskUsage returns approximate on-disk sizes in bytes per each metricID in s.
+//
+// The size for each metricID includes the compressed size of timestamps and values for the metricID plus the size of the corresponding block headers.
+// Recently added samples, which aren't flushed to disk yet, are accounted with the size they are going to occupy on disk.
+//
+// The result is cached for seriesDiskUsageCacheDuration. The caller mustn't modify the returned map.
+func (s *Storage) getSeriesDiskUsage(deadline uint64) (map[uint64]uint64, error) {
+	sdu := &s.seriesDiskUsage
+	sdu.mu.Lock()
+	defer sdu.mu.Unlock()
+
+	currentTime := fasttime.UnixTimestamp()
+	if sdu.bytesByMetricID != nil && currentTime-sdu.updatedAt < seriesDiskUsageCacheDuration {
+		return sdu.bytesByMetricID, nil
+	}
+	m := make(map[uint64]uint64)
+	ptws := s.tb.GetPartitions(nil)
+	defer s.tb.PutPartitions(ptws)
+	for _, ptw := range ptws {
+		if err := ptw.pt.collectSeriesDiskUsage(m, deadline); err != nil {
+			return nil, fmt.Errorf("cannot calculate disk usage for partition %q: %w", ptw.pt.name, err)
+		}
+	}
+	sdu.bytesByMetricID = m
+	sdu.updatedAt = currentTime
+	return m, nil
+}
+
+// collectSeriesDiskUsage adds approximate on-disk sizes per each metricID in pt to m.
+func (pt *partition) collectSeriesDiskUsage(m map[uint64]uint64, deadline uint64) error {
+	pws := pt.GetParts(nil)
+	defer pt.PutParts(pws)
+	for _, pw := range pws {
+		if fasttime.UnixTimestamp() > deadline {
+			return fmt.Errorf("the deadline has been exceeded")
+		}
+		p := pw.p
+		err := forEachPartBlockHeader(p, func(bh *blockHeader, indexBytes uint64) {
+			m[bh.TSID.MetricID] += uint64(bh.TimestampsBlockSize) + uint64(bh.ValuesBlockSize) + indexBytes
+		})
+		if err != nil {
+			return fmt.Errorf("cannot read block headers from part %q: %w", p.path, err)
+		}
+	}
+	return nil
+}
+
+// forEachPartBlockHeader calls f for every block header in p.
+//
+// indexBytes passed to f is the approximate compressed size of the block header in the index file of p.
+func forEachPartBlockHeader(p *part, f func(bh *blockHeader, indexBytes uint64)) error {
+	var compressedBuf, buf []byte
+	var bhs []blockHeader
+	for i := range p.metaindex {
+		mr := &p.metaindex[i]
+		compressedBuf = bytesutil.Resize(compressedBuf[:0], int(mr.IndexBlockSize))
+		p.indexFile.MustReadAt(compressedBuf, int64(mr.IndexBlockOffset))
+		var err error
+		buf, err = encoding.DecompressZSTD(buf[:0], compressedBuf)
+		if err != nil {
+			return fmt.Errorf("cannot decompress index block: %w", err)
+		}
+		bhs, err = unmarshalBlockHeaders(bhs[:0], buf, int(mr.BlockHeadersCount))
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal index block: %w", err)
+		}
+		indexBytes := uint64(mr.IndexBlockSize) / uint64(len(bhs))
+		for j := range bhs {
+			f(&bhs[j], indexBytes)
+		}
+	}
+	return nil
+}
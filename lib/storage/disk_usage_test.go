@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStorageTSDBStatusDiskUsage(t *testing.T) {
+	const path = "TestStorageTSDBStatusDiskUsage"
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	minTimestamp := time.Now().Add(-time.Hour).UnixNano() / 1e6
+	addRows := func(metricGroup string, seriesCount, rowsPerSeries int) {
+		t.Helper()
+		var mrs []MetricRow
+		for i := 0; i < seriesCount; i++ {
+			mn := MetricName{
+				MetricGroup: []byte(metricGroup),
+				Tags: []Tag{{
+					Key:   []byte("instance"),
+					Value: []byte(fmt.Sprintf("host-%d", i)),
+				}},
+			}
+			metricNameRaw := mn.marshalRaw(nil)
+			for j := 0; j < rowsPerSeries; j++ {
+				mrs = append(mrs, MetricRow{
+					MetricNameRaw: metricNameRaw,
+					Timestamp:     minTimestamp + int64(j)*1000,
+					Value:         rand.Float64(),
+				})
+			}
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("cannot add rows: %s", err)
+		}
+	}
+	addRows("foo", 10, 1000)
+	addRows("bar", 10, 10)
+	s.DebugFlush()
+
+	date := uint64(minTimestamp) / msecPerDay
+	status, err := s.GetTSDBStatusWithFiltersForDate(nil, date, "", 10, false, noDeadline)
+	if err != nil {
+		t.Fatalf("cannot obtain tsdb status: %s", err)
+	}
+	if status.TotalBytes != 0 || len(status.BytesByMetricName) != 0 || len(status.BytesByLabelValuePair) != 0 {
+		t.Fatalf("disk usage mustn't be returned if it isn't requested; got %+v", status)
+	}
+
+	status, err = s.GetTSDBStatusWithFiltersForDate(nil, date, "", 10, true, noDeadline)
+	if err != nil {
+		t.Fatalf("cannot obtain tsdb status with disk usage: %s", err)
+	}
+	if status.TotalSeries != 20 {
+		t.Fatalf("unexpected TotalSeries; got %d; want 20", status.TotalSeries)
+	}
+	if status.TotalBytes == 0 {
+		t.Fatalf("TotalBytes must be positive")
+	}
+	if len(status.BytesByMetricName) != 2 {
+		t.Fatalf("unexpected number of entries in BytesByMetricName; got %d; want 2", len(status.BytesByMetricName))
+	}
+	foo, bar := status.BytesByMetricName[0], status.BytesByMetricName[1]
+	if foo.Name != "foo" || bar.Name != "bar" {
+		t.Fatalf("unexpected order of metric names in BytesByMetricName; got %q, %q; want %q, %q", foo.Name, bar.Name, "foo", "bar")
+	}
+	if foo.Count+bar.Count != status.TotalBytes {
+		t.Fatalf("the sum of BytesByMetricName must match TotalBytes; got %d+%d; want %d", foo.Count, bar.Count, status.TotalBytes)
+	}
+	for _, e := range status.BytesByLabelValuePair {
+		if e.Count > status.TotalBytes {
+			t.Fatalf("BytesByLabelValuePair entry %q cannot exceed TotalBytes; got %d; want up to %d", e.Name, e.Count, status.TotalBytes)
+		}
+	}
+
+	// Verify that match[] filters are applied to disk usage.
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("bar"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	status, err = s.GetTSDBStatusWithFiltersForDate([]*TagFilters{tfs}, date, "", 10, true, noDeadline)
+	if err != nil {
+		t.Fatalf("cannot obtain tsdb status with filters: %s", err)
+	}
+	if status.TotalBytes != bar.Count {
+		t.Fatalf("unexpected TotalBytes for filtered series; got %d; want %d", status.TotalBytes, bar.Count)
+	}
+
+	s.MustClose()
+}
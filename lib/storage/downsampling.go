@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+// downsamplingPeriod leaves a single sample per Interval for samples older than Offset.
+type downsamplingPeriod struct {
+	Offset   int64
+	Interval int64
+}
+
+// downsamplingPeriods contains periods sorted by Offset in descending order.
+var downsamplingPeriods []downsamplingPeriod
+
+// SetDownsamplingPeriods sets downsampling periods from -downsampling.period values.
+//
+// Every value must be in the form `offset:interval`, for example, `30d:5m`.
+// Downsampling is disabled if periods is empty.
+//
+// This function must be called before initializing the storage.
+func SetDownsamplingPeriods(periods []string) error {
+	dps, err := parseDownsamplingPeriods(periods)
+	if err != nil {
+		return err
+	}
+	downsamplingPeriods = dps
+	return nil
+}
+
+func parseDownsamplingPeriods(periods []string) ([]downsamplingPeriod, error) {
+	var dps []downsamplingPeriod
+	for _, s := range periods {
+		n := strings.IndexByte(s, ':')
+		if n < 0 {
+			return nil, fmt.Errorf("missing ':' in %q; expecting <offset>:<interval>", s)
+		}
+		offset, err := metricsql.PositiveDurationValue(s[:n], 0)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse offset in %q: %w", s, err)
+		}
+		interval, err := metricsql.PositiveDurationValue(s[n+1:], 0)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse interval in %q: %w", s, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("interval in %q must be positive", s)
+		}
+		dps = append(dps, downsamplingPeriod{
+			Offset:   offset,
+			Interval: interval,
+		})
+	}
+	sort.Slice(dps, func(i, j int) bool {
+		return dps[i].Offset > dps[j].Offset
+	})
+	for i := 1; i < len(dps); i++ {
+		prev, dp := &dps[i-1], &dps[i]
+		if prev.Offset == dp.Offset {
+			return nil, fmt.Errorf("duplicate offset %dms in -downsampling.period", dp.Offset)
+		}
+		// Older data must have coarser resolution. Intervals must be multiple of each other,
+		// so the samples left after downsampling with the smaller interval remain aligned
+		// to the bigger interval after the data gets older.
+		if prev.Interval <= dp.Interval || prev.Interval%dp.Interval != 0 {
+			return nil, fmt.Errorf("interval=%dms for offset=%dms must be bigger than and multiple of interval=%dms for offset=%dms",
+				prev.Interval, prev.Offset, dp.Interval, dp.Offset)
+		}
+	}
+	return dps, nil
+}
+
+// forEachDedupInterval calls f for every timestamps[start:end] range, which must be de-duplicated with the same interval.
+//
+// timestamps must be sorted. now is the current time in milliseconds.
+func forEachDedupInterval(timestamps []int64, now int64, f func(start, end int, interval int64)) {
+	start := 0
+	for _, dp := range downsamplingPeriods {
+		// Samples with timestamps smaller than deadline are older than dp.Offset.
+		deadline := now - dp.Offset
+		end := start + sort.Search(len(timestamps)-start, func(i int) bool {
+			return timestamps[start+i] >= deadline
+		})
+		if end > start {
+			interval := dp.Interval
+			if interval < minScrapeInterval {
+				interval = minScrapeInterval
+			}
+			f(start, end, interval)
+			start = end
+		}
+	}
+	if start < len(timestamps) {
+		f(start, len(timestamps), minScrapeInterval)
+	}
+}
+
+func downsampleSamples(srcTimestamps []int64, srcValues []float64, now int64) ([]int64, []float64) {
+	dstTimestamps := srcTimestamps[:0]
+	dstValues := srcValues[:0]
+	forEachDedupInterval(srcTimestamps, now, func(start, end int, interval int64) {
+		timestamps := srcTimestamps[start:end]
+		values := srcValues[start:end]
+		if needsDedup(timestamps, interval) {
+			timestamps, values = deduplicateInternal(interval, timestamps, values)
+		}
+		dstTimestamps = append(dstTimestamps, timestamps...)
+		dstValues = append(dstValues, values...)
+	})
+	return dstTimestamps, dstValues
+}
+
+func downsampleSamplesDuringMerge(srcTimestamps, srcValues []int64, now int64) ([]int64, []int64) {
+	dstTimestamps := srcTimestamps[:0]
+	dstValues := srcValues[:0]
+	forEachDedupInterval(srcTimestamps, now, func(start, end int, interval int64) {
+		timestamps := srcTimestamps[start:end]
+		values := srcValues[start:end]
+		if needsDedup(timestamps, interval) {
+			timestamps, values = deduplicateDuringMergeInternal(interval, timestamps, values)
+		}
+		dstTimestamps = append(dstTimestamps, timestamps...)
+		dstValues = append(dstValues, values...)
+	})
+	return dstTimestamps, dstValues
+}
+
+func currentTimestampMsecs() int64 {
+	return int64(fasttime.UnixTimestamp()) * 1000
+}
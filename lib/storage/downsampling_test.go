@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDownsamplingPeriodsSuccess(t *testing.T) {
+	f := func(periods []string, dpsExpected []downsamplingPeriod) {
+		t.Helper()
+		dps, err := parseDownsamplingPeriods(periods)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(dps, dpsExpected) {
+			t.Fatalf("unexpected downsampling periods;\ngot\n%v\nwant\n%v", dps, dpsExpected)
+		}
+	}
+	f(nil, nil)
+	f([]string{"30d:5m"}, []downsamplingPeriod{
+		{Offset: 30 * 24 * 3600 * 1000, Interval: 5 * 60 * 1000},
+	})
+	f([]string{"30d:5m", "180d:1h"}, []downsamplingPeriod{
+		{Offset: 180 * 24 * 3600 * 1000, Interval: 3600 * 1000},
+		{Offset: 30 * 24 * 3600 * 1000, Interval: 5 * 60 * 1000},
+	})
+}
+
+func TestParseDownsamplingPeriodsFailure(t *testing.T) {
+	f := func(periods []string) {
+		t.Helper()
+		if _, err := parseDownsamplingPeriods(periods); err == nil {
+			t.Fatalf("expecting non-nil error for %q", periods)
+		}
+	}
+	// missing interval
+	f([]string{"30d"})
+	// invalid offset
+	f([]string{"foo:5m"})
+	// invalid interval
+	f([]string{"30d:bar"})
+	f([]string{"30d:0s"})
+	// duplicate offsets
+	f([]string{"30d:5m", "30d:1h"})
+	// older data with finer resolution
+	f([]string{"30d:1h", "180d:5m"})
+	// intervals aren't multiple of each other
+	f([]string{"30d:7m", "180d:1h"})
+}
+
+func TestDownsampleSamples(t *testing.T) {
+	defer func() {
+		downsamplingPeriods = nil
+	}()
+	dps, err := parseDownsamplingPeriods([]string{"100ms:10ms", "200ms:50ms"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	downsamplingPeriods = dps
+
+	f := func(timestamps []int64, now int64, timestampsExpected []int64) {
+		t.Helper()
+		values := make([]float64, len(timestamps))
+		valuesInt := make([]int64, len(timestamps))
+		for i, ts := range timestamps {
+			values[i] = float64(ts)
+			valuesInt[i] = ts
+		}
+		timestampsCopy := append([]int64{}, timestamps...)
+		resultTimestamps, resultValues := downsampleSamples(timestampsCopy, values, now)
+		if !reflect.DeepEqual(resultTimestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps;\ngot\n%v\nwant\n%v", resultTimestamps, timestampsExpected)
+		}
+		for i, ts := range resultTimestamps {
+			if resultValues[i] != float64(ts) {
+				t.Fatalf("unexpected value at position %d; got %v; want %v", i, resultValues[i], float64(ts))
+			}
+		}
+
+		timestampsCopy = append([]int64{}, timestamps...)
+		resultTimestamps, resultValuesInt := downsampleSamplesDuringMerge(timestampsCopy, valuesInt, now)
+		if !reflect.DeepEqual(resultTimestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps during merge;\ngot\n%v\nwant\n%v", resultTimestamps, timestampsExpected)
+		}
+		for i, ts := range resultTimestamps {
+			if resultValuesInt[i] != ts {
+				t.Fatalf("unexpected value during merge at position %d; got %v; want %v", i, resultValuesInt[i], ts)
+			}
+		}
+	}
+	f([]int64{}, 1000, []int64{})
+
+	// All the samples are fresh
+	f([]int64{901, 902, 950, 999}, 1000, []int64{901, 902, 950, 999})
+
+	// All the samples are older than 100ms
+	f([]int64{801, 802, 811, 850, 899}, 1000, []int64{801, 811, 850, 899})
+
+	// All the samples are older than 200ms
+	f([]int64{601, 602, 649, 650, 700, 799}, 1000, []int64{601, 650, 700, 799})
+
+	// Samples span all the periods
+	f([]int64{701, 720, 760, 801, 805, 815, 901, 905}, 1000, []int64{701, 760, 801, 815, 901, 905})
+}
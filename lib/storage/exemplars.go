@@ -0,0 +1,501 @@
+package storage
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var (
+	maxExemplarsSizeBytes = 32 * 1024 * 1024
+	maxExemplarsPerSeries = 10
+)
+
+// SetExemplarsLimits sets limits for in-memory exemplars storage.
+//
+// maxSizeBytes is the maximum memory size for exemplars across all the time series. Exemplars aren't stored if it is set to 0.
+// maxPerSeries is the maximum number of the most recent exemplars to keep per each time series.
+//
+// This function must be called before OpenStorage.
+func SetExemplarsLimits(maxSizeBytes, maxPerSeries int) {
+	if maxPerSeries <= 0 {
+		maxPerSeries = 1
+	}
+	maxExemplarsSizeBytes = maxSizeBytes
+	maxExemplarsPerSeries = maxPerSeries
+}
+
+// Exemplar is an exemplar for a sample of a time series.
+//
+// Exemplars usually contain trace_id label, which links the sample to the corresponding trace.
+type Exemplar struct {
+	Labels    []Tag
+	Value     float64
+	Timestamp int64
+}
+
+// MetricExemplars contains exemplars for a single time series.
+type MetricExemplars struct {
+	MetricName MetricName
+
+	// Exemplars are sorted by Timestamp.
+	Exemplars []Exemplar
+}
+
+// MarshalExemplars appends marshaled exemplars to dst and returns the result.
+//
+// The result may be passed to ExemplarRow.Exemplars.
+func MarshalExemplars(dst []byte, exemplars []Exemplar) []byte {
+	dst = encoding.MarshalVarUint64(dst, uint64(len(exemplars)))
+	for i := range exemplars {
+		dst = exemplars[i].marshal(dst)
+	}
+	return dst
+}
+
+func (e *Exemplar) marshal(dst []byte) []byte {
+	dst = encoding.MarshalInt64(dst, e.Timestamp)
+	dst = encoding.MarshalUint64(dst, math.Float64bits(e.Value))
+	dst = encoding.MarshalVarUint64(dst, uint64(len(e.Labels)))
+	for i := range e.Labels {
+		t := &e.Labels[i]
+		dst = encoding.MarshalBytes(dst, t.Key)
+		dst = encoding.MarshalBytes(dst, t.Value)
+	}
+	return dst
+}
+
+// unmarshal unmarshals e from src and returns the remaining tail.
+//
+// e refers to src after returning.
+func (e *Exemplar) unmarshal(src []byte) ([]byte, error) {
+	if len(src) < 16 {
+		return src, fmt.Errorf("cannot unmarshal exemplar timestamp and value; want at least 16 bytes; got %d bytes", len(src))
+	}
+	e.Timestamp = encoding.UnmarshalInt64(src)
+	e.Value = math.Float64frombits(encoding.UnmarshalUint64(src[8:]))
+	tail, n, err := encoding.UnmarshalVarUint64(src[16:])
+	if err != nil {
+		return tail, fmt.Errorf("cannot unmarshal the number of exemplar labels: %w", err)
+	}
+	if n > uint64(len(tail)) {
+		return tail, fmt.Errorf("too big number of exemplar labels: %d; it cannot exceed %d", n, len(tail))
+	}
+	labels := e.Labels[:0]
+	for i := uint64(0); i < n; i++ {
+		var key, value []byte
+		tail, key, err = encoding.UnmarshalBytes(tail)
+		if err != nil {
+			return tail, fmt.Errorf("cannot unmarshal exemplar label name: %w", err)
+		}
+		tail, value, err = encoding.UnmarshalBytes(tail)
+		if err != nil {
+			return tail, fmt.Errorf("cannot unmarshal exemplar label value: %w", err)
+		}
+		labels = append(labels, Tag{
+			Key:   key,
+			Value: value,
+		})
+	}
+	e.Labels = labels
+	return tail, nil
+}
+
+// exemplarItemOverhead is the approximate memory overhead for every exemplar stored in exemplarsStorage.
+const exemplarItemOverhead = 48
+
+// exemplarsRingOverhead is the approximate memory overhead for every time series in exemplarsStorage.
+const exemplarsRingOverhead = 128
+
+// exemplarsStorage holds a bounded ring of the most recent exemplars per each time series in memory.
+//
+// The least recently updated time series are evicted when the memory budget is exceeded.
+type exemplarsStorage struct {
+	// Atomic counters must go at the top of the structure in order to properly align by 8 bytes on 32-bit archs.
+	exemplarsAdded         uint64
+	outOfOrderExemplars    uint64
+	invalidExemplars       uint64
+	unknownSeriesExemplars uint64
+	evictedSeries          uint64
+
+	maxSizeBytes int
+	maxPerSeries int
+
+	mu        sync.Mutex
+	m         map[uint64]*exemplarsRing
+	lru       list.List
+	sizeBytes int
+	itemsLen  int
+}
+
+// exemplarsRing contains the most recent exemplars for a single time series.
+type exemplarsRing struct {
+	metricID uint64
+
+	// items contains marshaled exemplars.
+	// The next exemplar is written at items[next]. The oldest exemplar is located at items[next] when the ring is full.
+	items []exemplarItem
+	next  int
+
+	lruElem *list.Element
+}
+
+type exemplarItem struct {
+	timestamp int64
+	data      []byte
+}
+
+func newExemplarsStorage(maxSizeBytes, maxPerSeries int) *exemplarsStorage {
+	es := &exemplarsStorage{
+		maxSizeBytes: maxSizeBytes,
+		maxPerSeries: maxPerSeries,
+		m:            make(map[uint64]*exemplarsRing),
+	}
+	es.lru.Init()
+	return es
+}
+
+func (es *exemplarsStorage) isEnabled() bool {
+	return es.maxSizeBytes > 0
+}
+
+// add adds exemplars marshaled with MarshalExemplars to the time series with the given metricID.
+func (es *exemplarsStorage) add(metricID uint64, src []byte) error {
+	if !es.isEnabled() {
+		return nil
+	}
+	tail, n, err := encoding.UnmarshalVarUint64(src)
+	if err != nil {
+		atomic.AddUint64(&es.invalidExemplars, 1)
+		return fmt.Errorf("cannot unmarshal the number of exemplars: %w", err)
+	}
+	var e Exemplar
+	for i := uint64(0); i < n; i++ {
+		data := tail
+		tail, err = e.unmarshal(tail)
+		if err != nil {
+			atomic.AddUint64(&es.invalidExemplars, 1)
+			return fmt.Errorf("cannot unmarshal exemplar #%d: %w", i, err)
+		}
+		es.addItem(metricID, e.Timestamp, data[:len(data)-len(tail)])
+	}
+	if len(tail) > 0 {
+		atomic.AddUint64(&es.invalidExemplars, 1)
+		return fmt.Errorf("unexpected non-empty tail left after unmarshaling %d exemplars; len(tail)=%d", n, len(tail))
+	}
+	return nil
+}
+
+func (es *exemplarsStorage) addItem(metricID uint64, timestamp int64, data []byte) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	r := es.m[metricID]
+	if r == nil {
+		r = &exemplarsRing{
+			metricID: metricID,
+		}
+		r.lruElem = es.lru.PushBack(r)
+		es.m[metricID] = r
+		es.sizeBytes += exemplarsRingOverhead
+	} else {
+		es.lru.MoveToBack(r.lruElem)
+	}
+	if len(r.items) > 0 {
+		newest := &r.items[(r.next-1+len(r.items))%len(r.items)]
+		if timestamp < newest.timestamp {
+			// Drop out of order exemplars, since they are usually sent again after the newer exemplars.
+			atomic.AddUint64(&es.outOfOrderExemplars, 1)
+			return
+		}
+		if timestamp == newest.timestamp && string(data) == string(newest.data) {
+			// Skip duplicate exemplar.
+			return
+		}
+	}
+	item := exemplarItem{
+		timestamp: timestamp,
+		data:      append([]byte{}, data...),
+	}
+	if len(r.items) < es.maxPerSeries {
+		r.items = append(r.items, item)
+		es.itemsLen++
+	} else {
+		es.sizeBytes -= exemplarItemOverhead + len(r.items[r.next].data)
+		r.items[r.next] = item
+	}
+	r.next = (r.next + 1) % es.maxPerSeries
+	es.sizeBytes += exemplarItemOverhead + len(item.data)
+	atomic.AddUint64(&es.exemplarsAdded, 1)
+
+	// Evict the least recently updated time series until the memory usage drops below the limit.
+	for es.sizeBytes > es.maxSizeBytes && es.lru.Len() > 0 {
+		es.removeRingLocked(es.lru.Front().Value.(*exemplarsRing))
+		atomic.AddUint64(&es.evictedSeries, 1)
+	}
+}
+
+func (es *exemplarsStorage) removeRingLocked(r *exemplarsRing) {
+	for _, item := range r.items {
+		es.sizeBytes -= exemplarItemOverhead + len(item.data)
+	}
+	es.sizeBytes -= exemplarsRingOverhead
+	es.itemsLen -= len(r.items)
+	es.lru.Remove(r.lruElem)
+	delete(es.m, r.metricID)
+}
+
+// appendExemplars appends exemplars on the given tr for the given metricID to dst and returns the result.
+//
+// The appended exemplars are sorted by timestamp.
+func (es *exemplarsStorage) appendExemplars(dst []Exemplar, metricID uint64, tr TimeRange) ([]Exemplar, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	r := es.m[metricID]
+	if r == nil {
+		return dst, nil
+	}
+	start := 0
+	if len(r.items) == es.maxPerSeries {
+		start = r.next
+	}
+	for i := range r.items {
+		item := &r.items[(start+i)%len(r.items)]
+		if item.timestamp < tr.MinTimestamp || item.timestamp > tr.MaxTimestamp {
+			continue
+		}
+		// Unmarshal a copy of item.data, since it may be overwritten after returning.
+		data := append([]byte{}, item.data...)
+		dst = append(dst, Exemplar{})
+		e := &dst[len(dst)-1]
+		if _, err := e.unmarshal(data); err != nil {
+			return dst, fmt.Errorf("cannot unmarshal exemplar for metricID=%d: %w", metricID, err)
+		}
+	}
+	return dst, nil
+}
+
+// exemplarsFilename is the name of the file in the cache directory for persisting exemplars across restarts.
+const exemplarsFilename = "exemplars"
+
+func (es *exemplarsStorage) mustSave(path string) {
+	if !es.isEnabled() {
+		return
+	}
+	logger.Infof("saving exemplars to %q...", path)
+	startTime := time.Now()
+
+	es.mu.Lock()
+	var dst []byte
+	for e := es.lru.Front(); e != nil; e = e.Next() {
+		r := e.Value.(*exemplarsRing)
+		start := 0
+		if len(r.items) == es.maxPerSeries {
+			start = r.next
+		}
+		dst = encoding.MarshalUint64(dst, r.metricID)
+		dst = encoding.MarshalVarUint64(dst, uint64(len(r.items)))
+		for i := range r.items {
+			dst = append(dst, r.items[(start+i)%len(r.items)].data...)
+		}
+	}
+	itemsLen := es.itemsLen
+	es.mu.Unlock()
+
+	if err := ioutil.WriteFile(path, dst, 0644); err != nil {
+		logger.Panicf("FATAL: cannot write %d bytes to %q: %s", len(dst), path, err)
+	}
+	logger.Infof("saved exemplars to %q in %.3f seconds; exemplarsCount: %d; sizeBytes: %d", path, time.Since(startTime).Seconds(), itemsLen, len(dst))
+}
+
+func (es *exemplarsStorage) mustLoad(path string) {
+	if !es.isEnabled() {
+		return
+	}
+	logger.Infof("loading exemplars from %q...", path)
+	startTime := time.Now()
+	if !fs.IsPathExist(path) {
+		logger.Infof("nothing to load from %q", path)
+		return
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Panicf("FATAL: cannot read %s: %s", path, err)
+	}
+	srcOrigLen := len(src)
+	for len(src) > 0 {
+		if len(src) < 8 {
+			logger.Errorf("discarding the rest of %s, since it has broken metricID; got %d bytes; want at least 8 bytes", path, len(src))
+			break
+		}
+		metricID := encoding.UnmarshalUint64(src)
+		tail, n, err := encoding.UnmarshalVarUint64(src[8:])
+		if err != nil {
+			logger.Errorf("discarding the rest of %s, since it has broken exemplars count: %s", path, err)
+			break
+		}
+		// Re-use the marshaled format of ExemplarRow.Exemplars, so the loaded exemplars pass the usual checks.
+		data := encoding.MarshalVarUint64(nil, n)
+		itemsStart := len(data)
+		var e Exemplar
+		for i := uint64(0); i < n && err == nil; i++ {
+			itemStart := tail
+			tail, err = e.unmarshal(tail)
+			data = append(data, itemStart[:len(itemStart)-len(tail)]...)
+		}
+		if err != nil {
+			logger.Errorf("discarding the rest of %s, since it has broken exemplar: %s", path, err)
+			break
+		}
+		if len(data) > itemsStart {
+			if err := es.add(metricID, data); err != nil {
+				logger.Panicf("BUG: cannot add exemplars loaded from %s: %s", path, err)
+			}
+		}
+		src = tail
+	}
+	atomic.StoreUint64(&es.exemplarsAdded, 0)
+	logger.Infof("loaded exemplars from %q in %.3f seconds; exemplarsCount: %d; sizeBytes: %d", path, time.Since(startTime).Seconds(), es.itemsLen, srcOrigLen)
+}
+
+func (es *exemplarsStorage) updateMetrics(m *Metrics) {
+	es.mu.Lock()
+	m.ExemplarsCount += uint64(es.itemsLen)
+	m.ExemplarsSeries += uint64(len(es.m))
+	m.ExemplarsSizeBytes += uint64(es.sizeBytes)
+	es.mu.Unlock()
+
+	m.ExemplarsMaxSizeBytes += uint64(es.maxSizeBytes)
+	m.ExemplarsAdded += atomic.LoadUint64(&es.exemplarsAdded)
+	m.ExemplarsOutOfOrder += atomic.LoadUint64(&es.outOfOrderExemplars)
+	m.ExemplarsInvalid += atomic.LoadUint64(&es.invalidExemplars)
+	m.ExemplarsUnknownSeries += atomic.LoadUint64(&es.unknownSeriesExemplars)
+	m.ExemplarsEvictedSeries += atomic.LoadUint64(&es.evictedSeries)
+}
+
+// ExemplarRow contains exemplars for the time series with the given MetricNameRaw.
+type ExemplarRow struct {
+	// MetricNameRaw contains raw metric name, which must be decoded
+	// with MetricName.UnmarshalRaw.
+	MetricNameRaw []byte
+
+	// Exemplars must be marshaled with MarshalExemplars.
+	Exemplars []byte
+}
+
+// AddExemplars adds exemplars from ers to s.
+//
+// Exemplars are stored only for already existing time series, so they must be added after the corresponding samples.
+// Exemplars for unknown time series are ignored.
+func (s *Storage) AddExemplars(ers []ExemplarRow) error {
+	if !s.exemplars.isEnabled() || len(ers) == 0 {
+		return nil
+	}
+	var (
+		tsid              TSID
+		prevMetricNameRaw []byte
+		mn                MetricName
+		metricName        []byte
+		is                *indexSearch
+	)
+	idb := s.idb()
+	var firstErr error
+	for i := range ers {
+		er := &ers[i]
+		if string(er.MetricNameRaw) != string(prevMetricNameRaw) {
+			prevMetricNameRaw = nil
+			if !s.getTSIDFromCache(&tsid, er.MetricNameRaw) {
+				// Slow path - search for TSID in indexdb.
+				if err := mn.UnmarshalRaw(er.MetricNameRaw); err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("cannot unmarshal MetricNameRaw %q: %w", er.MetricNameRaw, err)
+					}
+					continue
+				}
+				mn.sortTags()
+				metricName = mn.Marshal(metricName[:0])
+				if is == nil {
+					is = idb.getIndexSearch(noDeadline)
+				}
+				if err := is.getTSIDByMetricName(&tsid, metricName); err != nil {
+					if err == io.EOF {
+						// Skip exemplars for unknown time series.
+						atomic.AddUint64(&s.exemplars.unknownSeriesExemplars, 1)
+						continue
+					}
+					if firstErr == nil {
+						firstErr = fmt.Errorf("cannot search TSID for %s: %w", &mn, err)
+					}
+					continue
+				}
+				s.putTSIDToCache(&tsid, er.MetricNameRaw)
+			}
+			prevMetricNameRaw = er.MetricNameRaw
+		}
+		if err := s.exemplars.add(tsid.MetricID, er.Exemplars); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("cannot add exemplars for %s: %w", getUserReadableMetricName(er.MetricNameRaw), err)
+		}
+	}
+	if is != nil {
+		idb.putIndexSearch(is)
+	}
+	return firstErr
+}
+
+// SearchExemplars returns exemplars on the given tr for time series matching the given tfss.
+//
+// Time series without exemplars on the given tr are skipped.
+func (s *Storage) SearchExemplars(tfss []*TagFilters, tr TimeRange, maxMetrics int, deadline uint64) ([]MetricExemplars, error) {
+	if !s.exemplars.isEnabled() {
+		return nil, nil
+	}
+	tsids, err := s.searchTSIDs(tfss, tr, maxMetrics, deadline)
+	if err != nil {
+		return nil, err
+	}
+	idb := s.idb()
+	var mes []MetricExemplars
+	var exemplars []Exemplar
+	var metricName []byte
+	for i := range tsids {
+		if i&paceLimiterSlowIterationsMask == 0 {
+			if err := checkSearchDeadlineAndPace(deadline); err != nil {
+				return nil, err
+			}
+		}
+		metricID := tsids[i].MetricID
+		exemplars, err = s.exemplars.appendExemplars(exemplars[:0], metricID, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(exemplars) == 0 {
+			continue
+		}
+		metricName, err = idb.searchMetricNameWithCache(metricName[:0], metricID)
+		if err != nil {
+			if err == io.EOF {
+				// Skip missing metricName for metricID.
+				// It should be automatically fixed. See indexDB.searchMetricName for details.
+				continue
+			}
+			return nil, fmt.Errorf("error when searching metricName for metricID=%d: %w", metricID, err)
+		}
+		mes = append(mes, MetricExemplars{})
+		me := &mes[len(mes)-1]
+		if err = me.MetricName.Unmarshal(metricName); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal metricName=%q: %w", metricName, err)
+		}
+		me.Exemplars = append([]Exemplar{}, exemplars...)
+	}
+	return mes, nil
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExemplarsMarshalUnmarshal(t *testing.T) {
+	exemplars := []Exemplar{
+		{
+			Value:     1.5,
+			Timestamp: 123,
+		},
+		{
+			Labels: []Tag{
+				{Key: []byte("trace_id"), Value: []byte("abc")},
+				{Key: []byte("span_id"), Value: []byte("")},
+			},
+			Value:     -2,
+			Timestamp: -456,
+		},
+	}
+	data := MarshalExemplars(nil, exemplars)
+
+	es := newExemplarsStorage(1e6, 10)
+	if err := es.add(1, data); err != nil {
+		t.Fatalf("cannot add exemplars: %s", err)
+	}
+	result, err := es.appendExemplars(nil, 1, TimeRange{MinTimestamp: -1000, MaxTimestamp: 1000})
+	if err != nil {
+		t.Fatalf("cannot obtain exemplars: %s", err)
+	}
+	// The second exemplar is dropped, since it is older than the first one.
+	resultExpected := exemplars[:1]
+	if !reflect.DeepEqual(result, resultExpected) {
+		t.Fatalf("unexpected exemplars;\ngot\n%+v\nwant\n%+v", result, resultExpected)
+	}
+
+	// Truncated data must result in error.
+	for i := 0; i < len(data); i++ {
+		if err := es.add(2, data[:i]); err == nil {
+			t.Fatalf("expecting non-nil error when adding truncated data with length %d out of %d", i, len(data))
+		}
+	}
+}
+
+func TestExemplarsStorage(t *testing.T) {
+	es := newExemplarsStorage(1e6, 3)
+	addExemplar := func(metricID uint64, timestamp int64, traceID string) {
+		t.Helper()
+		data := MarshalExemplars(nil, []Exemplar{{
+			Labels: []Tag{{
+				Key:   []byte("trace_id"),
+				Value: []byte(traceID),
+			}},
+			Value:     float64(timestamp),
+			Timestamp: timestamp,
+		}})
+		if err := es.add(metricID, data); err != nil {
+			t.Fatalf("cannot add exemplar: %s", err)
+		}
+	}
+	checkExemplars := func(metricID uint64, tr TimeRange, traceIDsExpected []string) {
+		t.Helper()
+		exemplars, err := es.appendExemplars(nil, metricID, tr)
+		if err != nil {
+			t.Fatalf("cannot obtain exemplars: %s", err)
+		}
+		var traceIDs []string
+		for _, e := range exemplars {
+			traceIDs = append(traceIDs, string(e.Labels[0].Value))
+		}
+		if !reflect.DeepEqual(traceIDs, traceIDsExpected) {
+			t.Fatalf("unexpected exemplars for metricID=%d; got %q; want %q", metricID, traceIDs, traceIDsExpected)
+		}
+	}
+	trAll := TimeRange{
+		MinTimestamp: 0,
+		MaxTimestamp: 1e9,
+	}
+
+	for i := 1; i <= 5; i++ {
+		addExemplar(1, int64(i*10), fmt.Sprintf("t%d", i))
+	}
+	// Only the last 3 exemplars must be left.
+	checkExemplars(1, trAll, []string{"t3", "t4", "t5"})
+	checkExemplars(1, TimeRange{MinTimestamp: 35, MaxTimestamp: 45}, []string{"t4"})
+
+	// Out of order and duplicate exemplars must be ignored.
+	addExemplar(1, 20, "t_old")
+	addExemplar(1, 50, "t5")
+	checkExemplars(1, trAll, []string{"t3", "t4", "t5"})
+	if n := es.outOfOrderExemplars; n != 1 {
+		t.Fatalf("unexpected number of out of order exemplars; got %d; want 1", n)
+	}
+
+	// Exemplars with the same timestamp, but with distinct labels must be stored.
+	addExemplar(1, 50, "t6")
+	checkExemplars(1, trAll, []string{"t4", "t5", "t6"})
+
+	addExemplar(2, 10, "x1")
+	checkExemplars(2, trAll, []string{"x1"})
+	checkExemplars(3, trAll, nil)
+
+	var m Metrics
+	es.updateMetrics(&m)
+	if m.ExemplarsCount != 4 {
+		t.Fatalf("unexpected number of exemplars; got %d; want 4", m.ExemplarsCount)
+	}
+	if m.ExemplarsSeries != 2 {
+		t.Fatalf("unexpected number of series; got %d; want 2", m.ExemplarsSeries)
+	}
+
+	// The least recently updated time series must be evicted when the memory limit is exceeded.
+	es.maxSizeBytes = es.sizeBytes
+	addExemplar(1, 60, "t7")
+	checkExemplars(1, trAll, []string{"t5", "t6", "t7"})
+	checkExemplars(2, trAll, []string{"x1"})
+	addExemplar(3, 10, "y1")
+	checkExemplars(1, trAll, []string{"t5", "t6", "t7"})
+	checkExemplars(2, trAll, nil)
+	checkExemplars(3, trAll, []string{"y1"})
+	if n := es.evictedSeries; n != 1 {
+		t.Fatalf("unexpected number of evicted series; got %d; want 1", n)
+	}
+}
+
+func TestStorageExemplars(t *testing.T) {
+	const path = "TestStorageExemplars"
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	timestamp := time.Now().UnixNano() / 1e6
+	metricNameRaw := func(metricGroup string) []byte {
+		mn := MetricName{
+			MetricGroup: []byte(metricGroup),
+		}
+		mn.AddTag("job", "test")
+		return mn.marshalRaw(nil)
+	}
+	exemplarsData := func(traceID string) []byte {
+		return MarshalExemplars(nil, []Exemplar{{
+			Labels: []Tag{{
+				Key:   []byte("trace_id"),
+				Value: []byte(traceID),
+			}},
+			Value:     1,
+			Timestamp: timestamp,
+		}})
+	}
+	mrs := []MetricRow{
+		{
+			MetricNameRaw: metricNameRaw("foo"),
+			Timestamp:     timestamp,
+			Value:         1,
+		},
+		{
+			MetricNameRaw: metricNameRaw("bar"),
+			Timestamp:     timestamp,
+			Value:         2,
+		},
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	s.DebugFlush()
+
+	// Reset tsidCache in order to verify that exemplars are added for series missing in the cache.
+	s.tsidCache.Reset()
+
+	ers := []ExemplarRow{
+		{
+			MetricNameRaw: metricNameRaw("foo"),
+			Exemplars:     exemplarsData("foo_trace"),
+		},
+		{
+			MetricNameRaw: metricNameRaw("unknown"),
+			Exemplars:     exemplarsData("unknown_trace"),
+		},
+	}
+	if err := s.AddExemplars(ers); err != nil {
+		t.Fatalf("cannot add exemplars: %s", err)
+	}
+
+	checkExemplars := func(s *Storage) {
+		t.Helper()
+		tfs := NewTagFilters()
+		if err := tfs.Add([]byte("job"), []byte("test"), false, false); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		tr := TimeRange{
+			MinTimestamp: timestamp - 1000,
+			MaxTimestamp: timestamp + 1000,
+		}
+		mes, err := s.SearchExemplars([]*TagFilters{tfs}, tr, 1e5, noDeadline)
+		if err != nil {
+			t.Fatalf("cannot search exemplars: %s", err)
+		}
+		if len(mes) != 1 {
+			t.Fatalf("unexpected number of series with exemplars; got %d; want 1", len(mes))
+		}
+		me := &mes[0]
+		if string(me.MetricName.MetricGroup) != "foo" {
+			t.Fatalf("unexpected metric name; got %s; want foo", &me.MetricName)
+		}
+		if len(me.Exemplars) != 1 || string(me.Exemplars[0].Labels[0].Value) != "foo_trace" {
+			t.Fatalf("unexpected exemplars: %+v", me.Exemplars)
+		}
+	}
+	checkExemplars(s)
+
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.ExemplarsUnknownSeries != 1 {
+		t.Fatalf("unexpected number of exemplars for unknown series; got %d; want 1", m.ExemplarsUnknownSeries)
+	}
+
+	// Exemplars must be preserved after the restart.
+	s.MustClose()
+	s, err = OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot re-open storage: %s", err)
+	}
+	checkExemplars(s)
+	s.MustClose()
+}
@@ -82,6 +82,9 @@ type indexDB struct {
 	name string
 	tb   *mergeset.Table
 
+	// compactor removes unneeded entries from tb during indexdb compaction.
+	compactor *indexCompactor
+
 	extDB     *indexDB
 	extDBLock sync.Mutex
 
@@ -104,7 +107,9 @@ func openIndexDB(path string, s *Storage) (*indexDB, error) {
 		logger.Panicf("BUG: Storage must be nin-nil")
 	}
 
-	tb, err := mergeset.OpenTable(path, invalidateTagFiltersCache, mergeTagToMetricIDsRows)
+	ic := &indexCompactor{}
+	ic.setFilter(nil)
+	tb, err := mergeset.OpenTable(path, invalidateTagFiltersCache, ic.prepareBlock)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open indexDB %q: %w", path, err)
 	}
@@ -115,9 +120,10 @@ func openIndexDB(path string, s *Storage) (*indexDB, error) {
 	mem := memory.Allowed()
 
 	db := &indexDB{
-		refCount: 1,
-		tb:       tb,
-		name:     name,
+		refCount:  1,
+		tb:        tb,
+		name:      name,
+		compactor: ic,
 
 		tagFiltersCache:            workingsetcache.New(mem/32, time.Hour),
 		s:                          s,
@@ -154,6 +160,8 @@ type IndexDBMetrics struct {
 	IndexBlocksWithMetricIDsProcessed      uint64
 	IndexBlocksWithMetricIDsIncorrectOrder uint64
 
+	CompactionItemsRemoved uint64
+
 	MinTimestampForCompositeIndex     uint64
 	CompositeFilterSuccessConversions uint64
 	CompositeFilterMissingConversions uint64
@@ -191,6 +199,8 @@ func (db *indexDB) UpdateMetrics(m *IndexDBMetrics) {
 	m.IndexBlocksWithMetricIDsProcessed = atomic.LoadUint64(&indexBlocksWithMetricIDsProcessed)
 	m.IndexBlocksWithMetricIDsIncorrectOrder = atomic.LoadUint64(&indexBlocksWithMetricIDsIncorrectOrder)
 
+	m.CompactionItemsRemoved = atomic.LoadUint64(&indexDBCompactionItemsRemoved)
+
 	m.MinTimestampForCompositeIndex = uint64(db.s.minTimestampForCompositeIndex)
 	m.CompositeFilterSuccessConversions = atomic.LoadUint64(&compositeFilterSuccessConversions)
 	m.CompositeFilterMissingConversions = atomic.LoadUint64(&compositeFilterMissingConversions)
@@ -1174,6 +1184,145 @@ func (is *indexSearch) searchTagValueSuffixesForPrefix(tvss map[string]struct{},
 	return nil
 }
 
+// SearchTagValuesWithPrefix returns up to maxTagValues tag values for the given tagKey starting with the given tagValuePrefix on the given tr.
+//
+// If tfss is non-empty, then only tag values for time series matching tfss are returned.
+// maxMetrics limits the number of time series matching tfss.
+//
+// This allows implementing fast autocomplete for label names and label values.
+func (db *indexDB) SearchTagValuesWithPrefix(tr TimeRange, tagKey, tagValuePrefix []byte, tfss []*TagFilters, maxTagValues, maxMetrics int, deadline uint64) ([]string, error) {
+	if len(tfss) > 0 && tr.MinTimestamp >= db.s.minTimestampForCompositeIndex {
+		tfss = convertToCompositeTagFilterss(tfss)
+	}
+	tvs := make(map[string]struct{})
+	is := db.getIndexSearch(deadline)
+	err := is.searchTagValuesWithPrefix(tvs, tr, tagKey, tagValuePrefix, tfss, maxTagValues, maxMetrics)
+	db.putIndexSearch(is)
+	if err != nil {
+		return nil, err
+	}
+	if len(tvs) < maxTagValues {
+		ok := db.doExtDB(func(extDB *indexDB) {
+			is := extDB.getIndexSearch(deadline)
+			err = is.searchTagValuesWithPrefix(tvs, tr, tagKey, tagValuePrefix, tfss, maxTagValues, maxMetrics)
+			extDB.putIndexSearch(is)
+		})
+		if ok && err != nil {
+			return nil, err
+		}
+	}
+
+	tagValues := make([]string, 0, len(tvs))
+	for tv := range tvs {
+		tagValues = append(tagValues, tv)
+	}
+	// Do not sort tagValues, since they must be sorted by vmselect.
+	return tagValues, nil
+}
+
+func (is *indexSearch) searchTagValuesWithPrefix(tvs map[string]struct{}, tr TimeRange, tagKey, tagValuePrefix []byte, tfss []*TagFilters, maxTagValues, maxMetrics int) error {
+	var filter *uint64set.Set
+	if len(tfss) > 0 {
+		metricIDs, err := is.searchMetricIDs(tfss, tr, maxMetrics)
+		if err != nil {
+			return err
+		}
+		if len(metricIDs) == 0 {
+			// Nothing found
+			return nil
+		}
+		filter = &uint64set.Set{}
+		filter.AddMulti(metricIDs)
+	}
+	kb := &is.kb
+	minDate := uint64(tr.MinTimestamp) / msecPerDay
+	maxDate := uint64(tr.MaxTimestamp) / msecPerDay
+	if minDate > maxDate || maxDate-minDate > maxDaysForPerDaySearch {
+		nsPrefix := byte(nsPrefixTagToMetricIDs)
+		kb.B = is.marshalCommonPrefix(kb.B[:0], nsPrefix)
+		kb.B = marshalTagValue(kb.B, tagKey)
+		kb.B = marshalTagValue(kb.B, tagValuePrefix)
+		kb.B = kb.B[:len(kb.B)-1] // remove tagSeparatorChar from the end of kb.B
+		prefix := append([]byte(nil), kb.B...)
+		return is.searchTagValuesForPrefix(tvs, nsPrefix, prefix, filter, maxTagValues)
+	}
+	// Search per-day index starting from the most recent day,
+	// since recent tag values are more likely to be needed for autocomplete.
+	nsPrefix := byte(nsPrefixDateTagToMetricIDs)
+	for date := maxDate; date >= minDate && len(tvs) < maxTagValues; date-- {
+		kb.B = is.marshalCommonPrefix(kb.B[:0], nsPrefix)
+		kb.B = encoding.MarshalUint64(kb.B, date)
+		kb.B = marshalTagValue(kb.B, tagKey)
+		kb.B = marshalTagValue(kb.B, tagValuePrefix)
+		kb.B = kb.B[:len(kb.B)-1] // remove tagSeparatorChar from the end of kb.B
+		prefix := append([]byte(nil), kb.B...)
+		if err := is.searchTagValuesForPrefix(tvs, nsPrefix, prefix, filter, maxTagValues); err != nil {
+			return err
+		}
+		if date == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+func (is *indexSearch) searchTagValuesForPrefix(tvs map[string]struct{}, nsPrefix byte, prefix []byte, filter *uint64set.Set, maxTagValues int) error {
+	kb := &is.kb
+	ts := &is.ts
+	mp := &is.mp
+	mp.Reset()
+	dmis := is.db.s.getDeletedMetricIDs()
+	loopsPaceLimiter := 0
+	ts.Seek(prefix)
+	for len(tvs) < maxTagValues && ts.NextItem() {
+		if loopsPaceLimiter&paceLimiterFastIterationsMask == 0 {
+			if err := checkSearchDeadlineAndPace(is.deadline); err != nil {
+				return err
+			}
+		}
+		loopsPaceLimiter++
+		item := ts.Item
+		if !bytes.HasPrefix(item, prefix) {
+			break
+		}
+		if err := mp.Init(item, nsPrefix); err != nil {
+			return err
+		}
+		if mp.IsDeletedTag(dmis) {
+			continue
+		}
+		if len(mp.Tag.Value) == 0 {
+			// Skip empty values, since they have no any meaning.
+			continue
+		}
+		if filter != nil && !mp.hasMetricIDsFrom(filter) {
+			// The row doesn't contain the matching metricIDs.
+			// The next row may contain them for the same tag value.
+			continue
+		}
+
+		// Store tag value
+		tvs[string(mp.Tag.Value)] = struct{}{}
+
+		if mp.MetricIDsLen() < maxMetricIDsPerRow/2 {
+			// There is no need in searching for the next tag value,
+			// since it is likely it is located in the next row,
+			// because the current row contains incomplete metricIDs set.
+			continue
+		}
+		// Search for the next tag value.
+		// The last char in kb.B must be tagSeparatorChar.
+		// Just increment it in order to jump to the next tag value.
+		kb.B = mp.MarshalPrefix(kb.B[:0])
+		kb.B[len(kb.B)-1]++
+		ts.Seek(kb.B)
+	}
+	if err := ts.Error(); err != nil {
+		return fmt.Errorf("error when searching for tag values with prefix %q: %w", prefix, err)
+	}
+	return nil
+}
+
 // GetSeriesCount returns the approximate number of unique timeseries in the db.
 //
 // It includes the deleted series too and may count the same series
@@ -1240,9 +1389,13 @@ func (is *indexSearch) getSeriesCount() (uint64, error) {
 }
 
 // GetTSDBStatusWithFiltersForDate returns topN entries for tsdb status for the given tfss and the given date.
-func (db *indexDB) GetTSDBStatusWithFiltersForDate(tfss []*TagFilters, date uint64, topN int, deadline uint64) (*TSDBStatus, error) {
+//
+// If focusLabel isn't empty, then the top values for the label with focusLabel name are returned in TSDBStatus.SeriesCountByFocusLabelValue.
+//
+// If bytesByMetricID isn't nil, then it is used for calculating TSDBStatus.BytesByMetricName and TSDBStatus.BytesByLabelValuePair.
+func (db *indexDB) GetTSDBStatusWithFiltersForDate(tfss []*TagFilters, date uint64, focusLabel string, topN int, bytesByMetricID map[uint64]uint64, deadline uint64) (*TSDBStatus, error) {
 	is := db.getIndexSearch(deadline)
-	status, err := is.getTSDBStatusWithFiltersForDate(tfss, date, topN)
+	status, err := is.getTSDBStatusWithFiltersForDate(tfss, date, focusLabel, topN, bytesByMetricID)
 	db.putIndexSearch(is)
 	if err != nil {
 		return nil, err
@@ -1252,7 +1405,7 @@ func (db *indexDB) GetTSDBStatusWithFiltersForDate(tfss []*TagFilters, date uint
 	}
 	ok := db.doExtDB(func(extDB *indexDB) {
 		is := extDB.getIndexSearch(deadline)
-		status, err = is.getTSDBStatusWithFiltersForDate(tfss, date, topN)
+		status, err = is.getTSDBStatusWithFiltersForDate(tfss, date, focusLabel, topN, bytesByMetricID)
 		extDB.putIndexSearch(is)
 	})
 	if ok && err != nil {
@@ -1262,7 +1415,7 @@ func (db *indexDB) GetTSDBStatusWithFiltersForDate(tfss []*TagFilters, date uint
 }
 
 // getTSDBStatusWithFiltersForDate returns topN entries for tsdb status for the given tfss and the given date.
-func (is *indexSearch) getTSDBStatusWithFiltersForDate(tfss []*TagFilters, date uint64, topN int) (*TSDBStatus, error) {
+func (is *indexSearch) getTSDBStatusWithFiltersForDate(tfss []*TagFilters, date uint64, focusLabel string, topN int, bytesByMetricID map[uint64]uint64) (*TSDBStatus, error) {
 	var filter *uint64set.Set
 	if len(tfss) > 0 {
 		tr := TimeRange{
@@ -1286,9 +1439,14 @@ func (is *indexSearch) getTSDBStatusWithFiltersForDate(tfss []*TagFilters, date
 	thLabelValueCountByLabelName := newTopHeap(topN)
 	thSeriesCountByLabelValuePair := newTopHeap(topN)
 	thSeriesCountByMetricName := newTopHeap(topN)
+	thSeriesCountByFocusLabelValue := newTopHeap(topN)
+	thBytesByLabelValuePair := newTopHeap(topN)
+	thBytesByMetricName := newTopHeap(topN)
 	var tmp, labelName, labelNameValue []byte
-	var labelValueCountByLabelName, seriesCountByLabelValuePair uint64
+	var labelValueCountByLabelName, seriesCountByLabelValuePair, bytesByLabelValuePair uint64
+	var totalSeries, totalLabelValuePairs, totalBytes uint64
 	nameEqualBytes := []byte("__name__=")
+	focusLabelEqualBytes := []byte(focusLabel + "=")
 
 	loopsPaceLimiter := 0
 	kb.B = is.marshalCommonPrefix(kb.B[:0], nsPrefixDateTagToMetricIDs)
@@ -1307,6 +1465,7 @@ func (is *indexSearch) getTSDBStatusWithFiltersForDate(tfss []*TagFilters, date
 			break
 		}
 		matchingSeriesCount := 0
+		matchingBytes := uint64(0)
 		if filter != nil {
 			if err := mp.Init(item, nsPrefixDateTagToMetricIDs); err != nil {
 				return nil, err
@@ -1315,6 +1474,7 @@ func (is *indexSearch) getTSDBStatusWithFiltersForDate(tfss []*TagFilters, date
 			for _, metricID := range mp.MetricIDs {
 				if filter.Has(metricID) {
 					matchingSeriesCount++
+					matchingBytes += bytesByMetricID[metricID]
 				}
 			}
 			if matchingSeriesCount == 0 {
@@ -1350,7 +1510,15 @@ func (is *indexSearch) getTSDBStatusWithFiltersForDate(tfss []*TagFilters, date
 			if bytes.HasPrefix(labelNameValue, nameEqualBytes) {
 				thSeriesCountByMetricName.pushIfNonEmpty(labelNameValue[len(nameEqualBytes):], seriesCountByLabelValuePair)
 			}
+			if len(focusLabel) > 0 && bytes.HasPrefix(labelNameValue, focusLabelEqualBytes) {
+				thSeriesCountByFocusLabelValue.pushIfNonEmpty(labelNameValue[len(focusLabelEqualBytes):], seriesCountByLabelValuePair)
+			}
+			thBytesByLabelValuePair.pushIfNonEmpty(labelNameValue, bytesByLabelValuePair)
+			if bytes.HasPrefix(labelNameValue, nameEqualBytes) {
+				thBytesByMetricName.pushIfNonEmpty(labelNameValue[len(nameEqualBytes):], bytesByLabelValuePair)
+			}
 			seriesCountByLabelValuePair = 0
+			bytesByLabelValuePair = 0
 			labelValueCountByLabelName++
 			labelNameValue = append(labelNameValue[:0], tmp...)
 		}
@@ -1359,11 +1527,23 @@ func (is *indexSearch) getTSDBStatusWithFiltersForDate(tfss []*TagFilters, date
 				return nil, err
 			}
 			matchingSeriesCount = mp.MetricIDsLen()
+			if bytesByMetricID != nil {
+				mp.ParseMetricIDs()
+				for _, metricID := range mp.MetricIDs {
+					matchingBytes += bytesByMetricID[metricID]
+				}
+			}
 		}
 		// Take into account deleted timeseries too.
 		// It is OK if series can be counted multiple times in rare cases -
 		// the returned number is an estimation.
 		seriesCountByLabelValuePair += uint64(matchingSeriesCount)
+		bytesByLabelValuePair += matchingBytes
+		totalLabelValuePairs += uint64(matchingSeriesCount)
+		if bytes.Equal(labelName, nameEqualBytes[:len(nameEqualBytes)-1]) {
+			totalSeries += uint64(matchingSeriesCount)
+			totalBytes += matchingBytes
+		}
 	}
 	if err := ts.Error(); err != nil {
 		return nil, fmt.Errorf("error when counting time series by metric names: %w", err)
@@ -1373,10 +1553,25 @@ func (is *indexSearch) getTSDBStatusWithFiltersForDate(tfss []*TagFilters, date
 	if bytes.HasPrefix(labelNameValue, nameEqualBytes) {
 		thSeriesCountByMetricName.pushIfNonEmpty(labelNameValue[len(nameEqualBytes):], seriesCountByLabelValuePair)
 	}
+	if len(focusLabel) > 0 && bytes.HasPrefix(labelNameValue, focusLabelEqualBytes) {
+		thSeriesCountByFocusLabelValue.pushIfNonEmpty(labelNameValue[len(focusLabelEqualBytes):], seriesCountByLabelValuePair)
+	}
+	thBytesByLabelValuePair.pushIfNonEmpty(labelNameValue, bytesByLabelValuePair)
+	if bytes.HasPrefix(labelNameValue, nameEqualBytes) {
+		thBytesByMetricName.pushIfNonEmpty(labelNameValue[len(nameEqualBytes):], bytesByLabelValuePair)
+	}
 	status := &TSDBStatus{
-		SeriesCountByMetricName:     thSeriesCountByMetricName.getSortedResult(),
-		LabelValueCountByLabelName:  thLabelValueCountByLabelName.getSortedResult(),
-		SeriesCountByLabelValuePair: thSeriesCountByLabelValuePair.getSortedResult(),
+		TotalSeries:                  totalSeries,
+		TotalLabelValuePairs:         totalLabelValuePairs,
+		SeriesCountByMetricName:      thSeriesCountByMetricName.getSortedResult(),
+		LabelValueCountByLabelName:   thLabelValueCountByLabelName.getSortedResult(),
+		SeriesCountByLabelValuePair:  thSeriesCountByLabelValuePair.getSortedResult(),
+		SeriesCountByFocusLabelValue: thSeriesCountByFocusLabelValue.getSortedResult(),
+	}
+	if bytesByMetricID != nil {
+		status.TotalBytes = totalBytes
+		status.BytesByMetricName = thBytesByMetricName.getSortedResult()
+		status.BytesByLabelValuePair = thBytesByLabelValuePair.getSortedResult()
 	}
 	return status, nil
 }
@@ -1385,9 +1580,26 @@ func (is *indexSearch) getTSDBStatusWithFiltersForDate(tfss []*TagFilters, date
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#tsdb-stats
 type TSDBStatus struct {
+	// TotalSeries is the number of series for the given date.
+	TotalSeries uint64
+
+	// TotalLabelValuePairs is the number of label=value pairs over all the series for the given date.
+	TotalLabelValuePairs uint64
+
 	SeriesCountByMetricName     []TopHeapEntry
 	LabelValueCountByLabelName  []TopHeapEntry
 	SeriesCountByLabelValuePair []TopHeapEntry
+
+	// SeriesCountByFocusLabelValue contains top values for the focusLabel passed to GetTSDBStatusWithFiltersForDate.
+	SeriesCountByFocusLabelValue []TopHeapEntry
+
+	// TotalBytes is the approximate number of bytes occupied on disk by all the series for the given date.
+	//
+	// TotalBytes, BytesByMetricName and BytesByLabelValuePair are set only if disk usage is requested.
+	// Series sizes cover the whole retention, not only the given date.
+	TotalBytes            uint64
+	BytesByMetricName     []TopHeapEntry
+	BytesByLabelValuePair []TopHeapEntry
 }
 
 func (status *TSDBStatus) hasEntries() bool {
@@ -2330,7 +2542,19 @@ func (is *indexSearch) updateMetricIDsForOrSuffix(prefix []byte, metricIDs *uint
 
 var errFallbackToGlobalSearch = errors.New("fall back from per-day index search to global index search")
 
-const maxDaysForPerDaySearch = 40
+// SetMaxDaysForPerDaySearch sets the maximum number of days, which may be covered by a search via per-day index.
+//
+// Searches over longer time ranges use the global index.
+//
+// This function must be called before any calling any storage functions.
+func SetMaxDaysForPerDaySearch(days int) {
+	if days <= 0 {
+		return
+	}
+	maxDaysForPerDaySearch = uint64(days)
+}
+
+var maxDaysForPerDaySearch uint64 = 40
 
 func (is *indexSearch) tryUpdatingMetricIDsForDateRange(metricIDs *uint64set.Set, tfs *TagFilters, tr TimeRange, maxMetrics int) error {
 	atomic.AddUint64(&is.db.dateRangeSearchCalls, 1)
@@ -2989,6 +3213,17 @@ func (mp *tagToMetricIDsRowParser) ParseMetricIDs() {
 	}
 }
 
+// hasMetricIDsFrom returns true if mp contains at least a single metricID from filter.
+func (mp *tagToMetricIDsRowParser) hasMetricIDsFrom(filter *uint64set.Set) bool {
+	mp.ParseMetricIDs()
+	for _, metricID := range mp.MetricIDs {
+		if filter.Has(metricID) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsDeletedTag verifies whether the tag from mp is deleted according to dmis.
 //
 // dmis must contain deleted MetricIDs.
@@ -3006,12 +3241,19 @@ func (mp *tagToMetricIDsRowParser) IsDeletedTag(dmis *uint64set.Set) bool {
 }
 
 func mergeTagToMetricIDsRows(data []byte, items []mergeset.Item) ([]byte, []mergeset.Item) {
-	data, items = mergeTagToMetricIDsRowsInternal(data, items, nsPrefixTagToMetricIDs)
-	data, items = mergeTagToMetricIDsRowsInternal(data, items, nsPrefixDateTagToMetricIDs)
+	return mergeTagToMetricIDsRowsWithDeleted(data, items, nil)
+}
+
+// mergeTagToMetricIDsRowsWithDeleted merges tag->metricIDs rows in items.
+//
+// MetricIDs from dmis are removed from the merged rows if dmis isn't nil.
+func mergeTagToMetricIDsRowsWithDeleted(data []byte, items []mergeset.Item, dmis *uint64set.Set) ([]byte, []mergeset.Item) {
+	data, items = mergeTagToMetricIDsRowsInternal(data, items, nsPrefixTagToMetricIDs, dmis)
+	data, items = mergeTagToMetricIDsRowsInternal(data, items, nsPrefixDateTagToMetricIDs, dmis)
 	return data, items
 }
 
-func mergeTagToMetricIDsRowsInternal(data []byte, items []mergeset.Item, nsPrefix byte) ([]byte, []mergeset.Item) {
+func mergeTagToMetricIDsRowsInternal(data []byte, items []mergeset.Item, nsPrefix byte, dmis *uint64set.Set) ([]byte, []mergeset.Item) {
 	// Perform quick checks whether items contain rows starting from nsPrefix
 	// based on the fact that items are sorted.
 	if len(items) <= 2 {
@@ -3065,7 +3307,15 @@ func mergeTagToMetricIDsRowsInternal(data []byte, items []mergeset.Item, nsPrefi
 			dstData, dstItems = tmm.flushPendingMetricIDs(dstData, dstItems, mpPrev)
 		}
 		mp.ParseMetricIDs()
-		tmm.pendingMetricIDs = append(tmm.pendingMetricIDs, mp.MetricIDs...)
+		if dmis == nil {
+			tmm.pendingMetricIDs = append(tmm.pendingMetricIDs, mp.MetricIDs...)
+		} else {
+			for _, metricID := range mp.MetricIDs {
+				if !dmis.Has(metricID) {
+					tmm.pendingMetricIDs = append(tmm.pendingMetricIDs, metricID)
+				}
+			}
+		}
 		mpPrev, mp = mp, mpPrev
 		if len(tmm.pendingMetricIDs) >= maxMetricIDsPerRow {
 			dstData, dstItems = tmm.flushPendingMetricIDs(dstData, dstItems, mpPrev)
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/mergeset"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+// SetIndexDBCompactionInterval sets the interval for checking whether indexdb must be compacted in background.
+//
+// Background compaction is disabled if d is 0.
+//
+// This function must be called before opening the storage.
+func SetIndexDBCompactionInterval(d time.Duration) {
+	indexDBCompactionInterval = d
+}
+
+var indexDBCompactionInterval time.Duration
+
+// indexDBCompactionExpiredDays is the number of days the retention must advance since the previous compaction
+// before the next background compaction is started for removing per-day entries outside the retention.
+const indexDBCompactionExpiredDays = 30
+
+// CompactIndexDB removes entries for deleted series and per-day entries outside the retention from indexdb.
+//
+// The compaction merges all the indexdb parts, so it may take a lot of time and disk IO on big indexdb.
+// Only a single compaction may run at a time.
+func (s *Storage) CompactIndexDB() error {
+	s.indexDBCompactionLock.Lock()
+	defer s.indexDBCompactionLock.Unlock()
+
+	dmis := s.getDeletedMetricIDs()
+	minDate := s.getIndexDBCompactionMinDate()
+	logger.Infof("starting indexdb compaction; deleted series: %d; minDate: %d", dmis.Len(), minDate)
+	startTime := time.Now()
+	atomic.AddUint64(&s.activeIndexDBCompactions, 1)
+	defer atomic.AddUint64(&s.activeIndexDBCompactions, ^uint64(0))
+
+	idb := s.idb()
+	if err := idb.compact(dmis, minDate, s.stop); err != nil {
+		return err
+	}
+	var err error
+	idb.doExtDB(func(extDB *indexDB) {
+		err = extDB.compact(dmis, minDate, s.stop)
+	})
+	if err != nil {
+		return err
+	}
+	s.indexDBCompactionDeletedMetricIDs = dmis.Len()
+	s.indexDBCompactionMinDate = minDate
+	logger.Infof("indexdb compaction has been finished in %.3f seconds", time.Since(startTime).Seconds())
+	return nil
+}
+
+// getIndexDBCompactionMinDate returns the minimum date for per-day entries, which must be left in indexdb after compaction.
+func (s *Storage) getIndexDBCompactionMinDate() uint64 {
+	minTimestamp := int64(fasttime.UnixTimestamp()*1000) - s.retentionMsecs
+	if minTimestamp < 0 {
+		return 0
+	}
+	// Leave an additional day for the data, which is going to be removed from the storage.
+	minDate := uint64(minTimestamp) / msecPerDay
+	if minDate > 0 {
+		minDate--
+	}
+	return minDate
+}
+
+func (s *Storage) startIndexDBCompactor() {
+	if indexDBCompactionInterval <= 0 {
+		return
+	}
+	// Do not compact indexdb right after the start, since it has been probably compacted before the restart.
+	s.indexDBCompactionDeletedMetricIDs = s.getDeletedMetricIDs().Len()
+	s.indexDBCompactionMinDate = s.getIndexDBCompactionMinDate()
+	s.indexDBCompactorWG.Add(1)
+	go func() {
+		s.indexDBCompactor()
+		s.indexDBCompactorWG.Done()
+	}()
+}
+
+func (s *Storage) indexDBCompactor() {
+	ticker := time.NewTicker(indexDBCompactionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if !s.needIndexDBCompaction() {
+				continue
+			}
+			if err := s.CompactIndexDB(); err != nil {
+				logger.Errorf("cannot compact indexdb: %s", err)
+			}
+		}
+	}
+}
+
+// needIndexDBCompaction returns true if new series have been deleted or if the retention has advanced
+// by indexDBCompactionExpiredDays since the previous compaction.
+func (s *Storage) needIndexDBCompaction() bool {
+	s.indexDBCompactionLock.Lock()
+	defer s.indexDBCompactionLock.Unlock()
+
+	if s.getDeletedMetricIDs().Len() != s.indexDBCompactionDeletedMetricIDs {
+		return true
+	}
+	return s.getIndexDBCompactionMinDate() >= s.indexDBCompactionMinDate+indexDBCompactionExpiredDays
+}
+
+// compact removes entries for metricIDs from dmis and per-day entries older than minDate from db.
+//
+// The compaction is stopped when stopCh is closed.
+func (db *indexDB) compact(dmis *uint64set.Set, minDate uint64, stopCh <-chan struct{}) error {
+	db.compactor.setFilter(&indexCompactionFilter{
+		deletedMetricIDs: dmis,
+		minDate:          minDate,
+	})
+	defer db.compactor.setFilter(nil)
+	if err := db.tb.ForceMergeAllParts(stopCh); err != nil {
+		return fmt.Errorf("cannot compact indexdb %q: %w", db.name, err)
+	}
+	return nil
+}
+
+// indexCompactor removes unneeded items from indexdb during merges when the compaction filter is set.
+type indexCompactor struct {
+	// filter contains *indexCompactionFilter. It is nil if the compaction isn't running.
+	filter atomic.Value
+}
+
+func (ic *indexCompactor) setFilter(f *indexCompactionFilter) {
+	ic.filter.Store(f)
+}
+
+func (ic *indexCompactor) getFilter() *indexCompactionFilter {
+	return ic.filter.Load().(*indexCompactionFilter)
+}
+
+// prepareBlock implements mergeset.PrepareBlockCallback.
+func (ic *indexCompactor) prepareBlock(data []byte, items []mergeset.Item) ([]byte, []mergeset.Item) {
+	f := ic.getFilter()
+	if f == nil {
+		return mergeTagToMetricIDsRows(data, items)
+	}
+	items = f.filterItems(data, items)
+	return mergeTagToMetricIDsRowsWithDeleted(data, items, f.deletedMetricIDs)
+}
+
+// indexCompactionFilter determines items to remove from indexdb during compaction.
+type indexCompactionFilter struct {
+	// deletedMetricIDs contains metricIDs for deleted series.
+	deletedMetricIDs *uint64set.Set
+
+	// minDate is the minimum date for per-day entries to keep.
+	minDate uint64
+}
+
+// filterItems removes unneeded items from items.
+//
+// The first and the last items are left as is, since mergeset.PrepareBlockCallback mustn't change them.
+// The remaining items stay sorted, since only the whole items are removed.
+func (f *indexCompactionFilter) filterItems(data []byte, items []mergeset.Item) []mergeset.Item {
+	if len(items) <= 2 {
+		return items
+	}
+	// The filter is shared among concurrent merges, so use a local parser.
+	var mp tagToMetricIDsRowParser
+	dstItems := items[:1]
+	for _, it := range items[1 : len(items)-1] {
+		if f.canRemoveItem(&mp, it.Bytes(data)) {
+			continue
+		}
+		dstItems = append(dstItems, it)
+	}
+	dstItems = append(dstItems, items[len(items)-1])
+	atomic.AddUint64(&indexDBCompactionItemsRemoved, uint64(len(items)-len(dstItems)))
+	return dstItems
+}
+
+// canRemoveItem returns true if the given indexdb item may be removed during compaction.
+func (f *indexCompactionFilter) canRemoveItem(mp *tagToMetricIDsRowParser, item []byte) bool {
+	if len(item) == 0 {
+		return false
+	}
+	dmis := f.deletedMetricIDs
+	tail := item[commonPrefixLen:]
+	switch item[0] {
+	case nsPrefixMetricNameToTSID:
+		// MetricName -> TSID entry ends with the marshaled TSID, which ends with MetricID.
+		if len(tail) < marshaledTSIDSize {
+			return false
+		}
+		metricID := encoding.UnmarshalUint64(tail[len(tail)-8:])
+		return dmis.Has(metricID)
+	case nsPrefixMetricIDToTSID, nsPrefixMetricIDToMetricName:
+		if len(tail) < 8 {
+			return false
+		}
+		metricID := encoding.UnmarshalUint64(tail)
+		return dmis.Has(metricID)
+	case nsPrefixDateToMetricID:
+		if len(tail) < 16 {
+			return false
+		}
+		date := encoding.UnmarshalUint64(tail)
+		metricID := encoding.UnmarshalUint64(tail[8:])
+		return date < f.minDate || dmis.Has(metricID)
+	case nsPrefixTagToMetricIDs, nsPrefixDateTagToMetricIDs:
+		if err := mp.Init(item, item[0]); err != nil {
+			return false
+		}
+		if item[0] == nsPrefixDateTagToMetricIDs && mp.Date < f.minDate {
+			return true
+		}
+		return mp.IsDeletedTag(dmis)
+	default:
+		// Deleted metricIDs must be preserved, since they are used for filtering out deleted series in the data.
+		return false
+	}
+}
+
+var indexDBCompactionItemsRemoved uint64
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/mergeset"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+func TestIndexCompactionFilterItems(t *testing.T) {
+	var dmis uint64set.Set
+	dmis.Add(2)
+	f := &indexCompactionFilter{
+		deletedMetricIDs: &dmis,
+		minDate:          10,
+	}
+	var data []byte
+	var items []mergeset.Item
+	addItem := func(nsPrefix byte, parts ...uint64) {
+		start := len(data)
+		data = marshalCommonPrefix(data, nsPrefix)
+		for _, part := range parts {
+			data = encoding.MarshalUint64(data, part)
+		}
+		items = append(items, mergeset.Item{
+			Start: uint32(start),
+			End:   uint32(len(data)),
+		})
+	}
+	addTagItem := func(nsPrefix byte, date uint64, metricIDs ...uint64) {
+		start := len(data)
+		data = marshalCommonPrefix(data, nsPrefix)
+		if nsPrefix == nsPrefixDateTagToMetricIDs {
+			data = encoding.MarshalUint64(data, date)
+		}
+		tag := Tag{
+			Key:   []byte("job"),
+			Value: []byte("foo"),
+		}
+		data = tag.Marshal(data)
+		for _, metricID := range metricIDs {
+			data = encoding.MarshalUint64(data, metricID)
+		}
+		items = append(items, mergeset.Item{
+			Start: uint32(start),
+			End:   uint32(len(data)),
+		})
+	}
+
+	// The first and the last items must be preserved even if they may be removed.
+	addTagItem(nsPrefixTagToMetricIDs, 0, 2)
+	addTagItem(nsPrefixTagToMetricIDs, 0, 1, 2)
+	addTagItem(nsPrefixTagToMetricIDs, 0, 2)
+	addItem(nsPrefixMetricIDToTSID, 1, 123)
+	addItem(nsPrefixMetricIDToTSID, 2, 123)
+	addItem(nsPrefixMetricIDToMetricName, 2, 123)
+	addItem(nsPrefixDeletedMetricID, 2)
+	addItem(nsPrefixDateToMetricID, 9, 1)
+	addItem(nsPrefixDateToMetricID, 10, 1)
+	addItem(nsPrefixDateToMetricID, 10, 2)
+	addTagItem(nsPrefixDateTagToMetricIDs, 9, 1)
+	addTagItem(nsPrefixDateTagToMetricIDs, 10, 1)
+	addTagItem(nsPrefixDateTagToMetricIDs, 10, 2)
+
+	resultItems := f.filterItems(data, append([]mergeset.Item{}, items...))
+	var result, expected []string
+	for _, it := range resultItems {
+		result = append(result, it.String(data))
+	}
+	for _, i := range []int{0, 1, 3, 6, 8, 11, 12} {
+		expected = append(expected, items[i].String(data))
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("unexpected number of items after filtering; got %d; want %d", len(result), len(expected))
+	}
+	for i := range result {
+		if result[i] != expected[i] {
+			t.Fatalf("unexpected item #%d after filtering;\ngot\n%X\nwant\n%X", i, result[i], expected[i])
+		}
+	}
+}
+
+func TestStorageCompactIndexDB(t *testing.T) {
+	const path = "TestStorageCompactIndexDB"
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	const seriesCount = 1000
+	now := time.Now().UnixNano() / 1e6
+	addRows := func(metricGroup string) {
+		t.Helper()
+		var mrs []MetricRow
+		for i := 0; i < seriesCount; i++ {
+			mn := MetricName{
+				MetricGroup: []byte(metricGroup),
+				Tags: []Tag{{
+					Key:   []byte("instance"),
+					Value: []byte(fmt.Sprintf("host-%d", i)),
+				}},
+			}
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: mn.marshalRaw(nil),
+				Timestamp:     now,
+				Value:         float64(i),
+			})
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("cannot add rows: %s", err)
+		}
+		s.DebugFlush()
+	}
+	addRows("foo")
+	addRows("bar")
+
+	countItems := func(nsPrefix byte) int {
+		t.Helper()
+		idb := s.idb()
+		is := idb.getIndexSearch(noDeadline)
+		defer idb.putIndexSearch(is)
+		prefix := marshalCommonPrefix(nil, nsPrefix)
+		ts := &is.ts
+		ts.Seek(prefix)
+		n := 0
+		for ts.NextItem() && bytes.HasPrefix(ts.Item, prefix) {
+			n++
+		}
+		if err := ts.Error(); err != nil {
+			t.Fatalf("cannot search index items: %s", err)
+		}
+		return n
+	}
+	if n := countItems(nsPrefixMetricIDToMetricName); n != 2*seriesCount {
+		t.Fatalf("unexpected number of MetricID->MetricName entries before compaction; got %d; want %d", n, 2*seriesCount)
+	}
+
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("foo"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	if _, err := s.DeleteMetrics([]*TagFilters{tfs}); err != nil {
+		t.Fatalf("cannot delete metrics: %s", err)
+	}
+	s.DebugFlush()
+	if err := s.CompactIndexDB(); err != nil {
+		t.Fatalf("cannot compact indexdb: %s", err)
+	}
+
+	// Entries for deleted series must be removed except of a few entries at block boundaries.
+	for _, nsPrefix := range []byte{nsPrefixMetricNameToTSID, nsPrefixMetricIDToTSID, nsPrefixMetricIDToMetricName, nsPrefixDateToMetricID} {
+		if n := countItems(nsPrefix); n < seriesCount || n > seriesCount+2 {
+			t.Fatalf("unexpected number of entries with nsPrefix=%d after compaction; got %d; want %d", nsPrefix, n, seriesCount)
+		}
+	}
+	// Deleted metricIDs must be preserved.
+	if n := countItems(nsPrefixDeletedMetricID); n != seriesCount {
+		t.Fatalf("unexpected number of deleted metricIDs after compaction; got %d; want %d", n, seriesCount)
+	}
+	if err := testCountStorageRows(s, now-1000, now+1000, map[string]int{"bar": seriesCount}); err != nil {
+		t.Fatalf("unexpected rows after compaction: %s", err)
+	}
+
+	// Deleted series must be registered again after the compaction.
+	addRows("foo")
+	if err := testCountStorageRows(s, now-1000, now+1000, map[string]int{"foo": seriesCount, "bar": seriesCount}); err != nil {
+		t.Fatalf("unexpected rows after adding deleted series: %s", err)
+	}
+	s.MustClose()
+}
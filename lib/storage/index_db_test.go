@@ -1615,6 +1615,48 @@ func TestSearchTSIDWithTimeRange(t *testing.T) {
 		t.Fatalf("unexpected tagValues; got\n%s\nwant\n%s", tvs, tagValues)
 	}
 
+	// Check SearchTagValuesWithPrefix.
+	tvs, err = db.SearchTagValuesWithPrefix(TimeRange{
+		MinTimestamp: int64(now) - msecPerDay,
+		MaxTimestamp: int64(now),
+	}, []byte("uniqueid"), []byte("99"), nil, 10000, 1e5, noDeadline)
+	if err != nil {
+		t.Fatalf("unexpected error in SearchTagValuesWithPrefix: %s", err)
+	}
+	sort.Strings(tvs)
+	tvsExpected := []string{"99", "990", "991", "992", "993", "994", "995", "996", "997", "998", "999"}
+	if !reflect.DeepEqual(tvs, tvsExpected) {
+		t.Fatalf("unexpected tag values with prefix; got\n%s\nwant\n%s", tvs, tvsExpected)
+	}
+
+	// Check SearchTagValuesWithPrefix with limit.
+	tvs, err = db.SearchTagValuesWithPrefix(TimeRange{
+		MinTimestamp: int64(now) - msecPerDay,
+		MaxTimestamp: int64(now),
+	}, []byte("uniqueid"), []byte("99"), nil, 3, 1e5, noDeadline)
+	if err != nil {
+		t.Fatalf("unexpected error in SearchTagValuesWithPrefix with limit: %s", err)
+	}
+	if len(tvs) != 3 {
+		t.Fatalf("unexpected number of tag values with prefix; got %d; want 3", len(tvs))
+	}
+
+	// Check SearchTagValuesWithPrefix with filters.
+	tfsDay := NewTagFilters()
+	if err := tfsDay.Add([]byte("day"), []byte("1"), false, false); err != nil {
+		t.Fatalf("cannot add filter: %s", err)
+	}
+	tvs, err = db.SearchTagValuesWithPrefix(TimeRange{
+		MinTimestamp: int64(now - msecPerDay*days),
+		MaxTimestamp: int64(now),
+	}, []byte("day"), nil, []*TagFilters{tfsDay}, 10000, 1e5, noDeadline)
+	if err != nil {
+		t.Fatalf("unexpected error in SearchTagValuesWithPrefix with filters: %s", err)
+	}
+	if !reflect.DeepEqual(tvs, []string{"1"}) {
+		t.Fatalf("unexpected tag values with filters; got %s; want [1]", tvs)
+	}
+
 	// Create a filter that will match series that occur across multiple days
 	tfs := NewTagFilters()
 	if err := tfs.Add([]byte("constant"), []byte("const"), false, false); err != nil {
@@ -1650,7 +1692,7 @@ func TestSearchTSIDWithTimeRange(t *testing.T) {
 	}
 
 	// Check GetTSDBStatusWithFiltersForDate with nil filters.
-	status, err := db.GetTSDBStatusWithFiltersForDate(nil, baseDate, 5, noDeadline)
+	status, err := db.GetTSDBStatusWithFiltersForDate(nil, baseDate, "", 5, nil, noDeadline)
 	if err != nil {
 		t.Fatalf("error in GetTSDBStatusWithFiltersForDate with nil filters: %s", err)
 	}
@@ -1712,13 +1754,22 @@ func TestSearchTSIDWithTimeRange(t *testing.T) {
 	if !reflect.DeepEqual(status.SeriesCountByLabelValuePair, expectedSeriesCountByLabelValuePair) {
 		t.Fatalf("unexpected SeriesCountByLabelValuePair;\ngot\n%v\nwant\n%v", status.SeriesCountByLabelValuePair, expectedSeriesCountByLabelValuePair)
 	}
+	if len(status.SeriesCountByFocusLabelValue) > 0 {
+		t.Fatalf("unexpected non-empty SeriesCountByFocusLabelValue for empty focusLabel: %v", status.SeriesCountByFocusLabelValue)
+	}
+	if status.TotalSeries != 1000 {
+		t.Fatalf("unexpected TotalSeries; got %d; want %d", status.TotalSeries, 1000)
+	}
+	if status.TotalLabelValuePairs != 4000 {
+		t.Fatalf("unexpected TotalLabelValuePairs; got %d; want %d", status.TotalLabelValuePairs, 4000)
+	}
 
 	// Check GetTSDBStatusWithFiltersForDate
 	tfs = NewTagFilters()
 	if err := tfs.Add([]byte("day"), []byte("0"), false, false); err != nil {
 		t.Fatalf("cannot add filter: %s", err)
 	}
-	status, err = db.GetTSDBStatusWithFiltersForDate([]*TagFilters{tfs}, baseDate, 5, noDeadline)
+	status, err = db.GetTSDBStatusWithFiltersForDate([]*TagFilters{tfs}, baseDate, "day", 5, nil, noDeadline)
 	if err != nil {
 		t.Fatalf("error in GetTSDBStatusWithFiltersForDate: %s", err)
 	}
@@ -1734,6 +1785,21 @@ func TestSearchTSIDWithTimeRange(t *testing.T) {
 	if !reflect.DeepEqual(status.SeriesCountByMetricName, expectedSeriesCountByMetricName) {
 		t.Fatalf("unexpected SeriesCountByMetricName;\ngot\n%v\nwant\n%v", status.SeriesCountByMetricName, expectedSeriesCountByMetricName)
 	}
+	expectedSeriesCountByFocusLabelValue := []TopHeapEntry{
+		{
+			Name:  "0",
+			Count: 1000,
+		},
+	}
+	if !reflect.DeepEqual(status.SeriesCountByFocusLabelValue, expectedSeriesCountByFocusLabelValue) {
+		t.Fatalf("unexpected SeriesCountByFocusLabelValue;\ngot\n%v\nwant\n%v", status.SeriesCountByFocusLabelValue, expectedSeriesCountByFocusLabelValue)
+	}
+	if status.TotalSeries != 1000 {
+		t.Fatalf("unexpected TotalSeries; got %d; want %d", status.TotalSeries, 1000)
+	}
+	if status.TotalLabelValuePairs != 4000 {
+		t.Fatalf("unexpected TotalLabelValuePairs; got %d; want %d", status.TotalLabelValuePairs, 4000)
+	}
 }
 
 func toTFPointers(tfs []tagFilter) []*tagFilter {
@@ -14,14 +14,17 @@ import (
 //
 // mergeBlockStreams returns immediately if stopCh is closed.
 //
+// Samples with timestamps smaller than retentionDeadline are dropped. rfm may override retentionDeadline
+// for time series matching -retentionFilter. Samples on deleted ranges from bsrs are dropped as well. now is the current time in milliseconds.
+//
 // rowsMerged is atomically updated with the number of merged rows during the merge.
 func mergeBlockStreams(ph *partHeader, bsw *blockStreamWriter, bsrs []*blockStreamReader, stopCh <-chan struct{},
-	dmis *uint64set.Set, retentionDeadline int64, rowsMerged, rowsDeleted *uint64) error {
+	dmis *uint64set.Set, rfm *retentionFilterMetricIDs, now, retentionDeadline int64, rowsMerged, rowsDeleted *uint64) error {
 	ph.Reset()
 
 	bsm := bsmPool.Get().(*blockStreamMerger)
 	bsm.Init(bsrs)
-	err := mergeBlockStreamsInternal(ph, bsw, bsm, stopCh, dmis, retentionDeadline, rowsMerged, rowsDeleted)
+	err := mergeBlockStreamsInternal(ph, bsw, bsm, stopCh, dmis, rfm, now, retentionDeadline, rowsMerged, rowsDeleted)
 	bsm.reset()
 	bsmPool.Put(bsm)
 	bsw.MustClose()
@@ -40,28 +43,52 @@ var bsmPool = &sync.Pool{
 var errForciblyStopped = fmt.Errorf("forcibly stopped")
 
 func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *blockStreamMerger, stopCh <-chan struct{},
-	dmis *uint64set.Set, retentionDeadline int64, rowsMerged, rowsDeleted *uint64) error {
+	dmis *uint64set.Set, rfm *retentionFilterMetricIDs, now, retentionDeadline int64, rowsMerged, rowsDeleted *uint64) error {
 	pendingBlockIsEmpty := true
 	pendingBlock := getBlock()
 	defer putBlock(pendingBlock)
 	tmpBlock := getBlock()
 	defer putBlock(tmpBlock)
+	bytesWritten := uint64(0)
 	for bsm.NextBlock() {
 		select {
 		case <-stopCh:
 			return errForciblyStopped
 		default:
 		}
+		if bl := bsw.bandwidthLimiter; bl != nil {
+			n := bsw.bytesWritten()
+			bl.Register(n-bytesWritten, stopCh)
+			bytesWritten = n
+		}
 		if dmis.Has(bsm.Block.bh.TSID.MetricID) {
 			// Skip blocks for deleted metrics.
 			atomic.AddUint64(rowsDeleted, uint64(bsm.Block.bh.RowsCount))
 			continue
 		}
-		if bsm.Block.bh.MaxTimestamp < retentionDeadline {
+		rd := rfm.getRetentionDeadline(bsm.Block.bh.TSID.MetricID, now, retentionDeadline)
+		if bsm.Block.bh.MaxTimestamp < rd {
 			// Skip blocks out of the given retention.
 			atomic.AddUint64(rowsDeleted, uint64(bsm.Block.bh.RowsCount))
 			continue
 		}
+		drs := bsm.deletedRanges()
+		if drs.overlapsBlock(bsm.Block.bh.TSID.MetricID, bsm.Block.bh.MinTimestamp, bsm.Block.bh.MaxTimestamp) {
+			if drs.coversBlock(bsm.Block.bh.TSID.MetricID, bsm.Block.bh.MinTimestamp, bsm.Block.bh.MaxTimestamp) {
+				// Skip blocks with deleted samples.
+				atomic.AddUint64(rowsDeleted, uint64(bsm.Block.bh.RowsCount))
+				continue
+			}
+			// Slow path - drop deleted samples from the block.
+			if err := bsm.Block.UnmarshalData(); err != nil {
+				return fmt.Errorf("cannot unmarshal block for dropping deleted samples: %w", err)
+			}
+			n := bsm.Block.removeDeletedRows(drs)
+			atomic.AddUint64(rowsDeleted, uint64(n))
+			if bsm.Block.bh.RowsCount == 0 {
+				continue
+			}
+		}
 		if pendingBlockIsEmpty {
 			// Load the next block if pendingBlock is empty.
 			pendingBlock.CopyFrom(bsm.Block)
@@ -93,11 +120,15 @@ func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *bloc
 		if err := unmarshalAndCalibrateScale(pendingBlock, bsm.Block); err != nil {
 			return fmt.Errorf("cannot unmarshal and calibrate scale for blocks to be merged: %w", err)
 		}
+		calibrateTimestamps(pendingBlock, bsm.Block)
+		calibrateHistograms(pendingBlock, bsm.Block)
 		tmpBlock.Reset()
 		tmpBlock.bh.TSID = bsm.Block.bh.TSID
 		tmpBlock.bh.Scale = bsm.Block.bh.Scale
 		tmpBlock.bh.PrecisionBits = minUint8(pendingBlock.bh.PrecisionBits, bsm.Block.bh.PrecisionBits)
-		mergeBlocks(tmpBlock, pendingBlock, bsm.Block, retentionDeadline, rowsDeleted)
+		tmpBlock.bh.NanosecondTimestamps = bsm.Block.bh.NanosecondTimestamps
+		tmpBlock.bh.Histograms = bsm.Block.bh.Histograms
+		mergeBlocks(tmpBlock, pendingBlock, bsm.Block, rd, rowsDeleted)
 		if len(tmpBlock.timestamps) <= maxRowsPerBlock {
 			// More entries may be added to tmpBlock. Swap it with pendingBlock,
 			// so more entries may be added to pendingBlock on the next iteration.
@@ -118,6 +149,9 @@ func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *bloc
 		tmpBlock.nextIdx = 0
 		tmpBlock.timestamps = tmpBlock.timestamps[:maxRowsPerBlock]
 		tmpBlock.values = tmpBlock.values[:maxRowsPerBlock]
+		if tmpBlock.bh.Histograms {
+			tmpBlock.histograms = tmpBlock.histograms[:maxRowsPerBlock]
+		}
 		tmpBlock.fixupTimestamps()
 		bsw.WriteExternalBlock(tmpBlock, ph, rowsMerged, true)
 	}
@@ -167,6 +201,9 @@ func mergeBlocks(ob, ib1, ib2 *Block, retentionDeadline int64, rowsDeleted *uint
 		}
 		ob.timestamps = append(ob.timestamps, ib1.timestamps[ib1.nextIdx:i]...)
 		ob.values = append(ob.values, ib1.values[ib1.nextIdx:i]...)
+		if ob.bh.Histograms {
+			ob.appendHistograms(ib1.histograms[ib1.nextIdx:i])
+		}
 		ib1.nextIdx = i
 		if ib1.nextIdx >= len(ib1.timestamps) {
 			appendRows(ob, ib2)
@@ -177,6 +214,7 @@ func mergeBlocks(ob, ib1, ib2 *Block, retentionDeadline int64, rowsDeleted *uint
 }
 
 func skipSamplesOutsideRetention(b *Block, retentionDeadline int64, rowsDeleted *uint64) {
+	retentionDeadline = b.minTimestampFromMsecs(retentionDeadline)
 	timestamps := b.timestamps
 	nextIdx := b.nextIdx
 	nextIdxOrig := nextIdx
@@ -192,6 +230,9 @@ func skipSamplesOutsideRetention(b *Block, retentionDeadline int64, rowsDeleted
 func appendRows(ob, ib *Block) {
 	ob.timestamps = append(ob.timestamps, ib.timestamps[ib.nextIdx:]...)
 	ob.values = append(ob.values, ib.values[ib.nextIdx:]...)
+	if ob.bh.Histograms {
+		ob.appendHistograms(ib.histograms[ib.nextIdx:])
+	}
 }
 
 func unmarshalAndCalibrateScale(b1, b2 *Block) error {
@@ -208,6 +249,18 @@ func unmarshalAndCalibrateScale(b1, b2 *Block) error {
 	return nil
 }
 
+// calibrateTimestamps converts timestamps in the unmarshaled b1 and b2 to the same precision.
+//
+// Millisecond timestamps are converted to nanosecond timestamps if one of the blocks contains nanosecond timestamps.
+// This may happen after changing the list of metrics with nanosecond timestamps.
+func calibrateTimestamps(b1, b2 *Block) {
+	if b1.bh.NanosecondTimestamps == b2.bh.NanosecondTimestamps {
+		return
+	}
+	b1.convertToNanosecondTimestamps()
+	b2.convertToNanosecondTimestamps()
+}
+
 func minUint8(a, b uint8) uint8 {
 	if a < b {
 		return a
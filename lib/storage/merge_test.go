@@ -4,6 +4,8 @@ import (
 	"errors"
 	"math/rand"
 	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
 )
 
 func TestMergeBlockStreamsOneStreamOneRow(t *testing.T) {
@@ -365,7 +367,7 @@ func TestMergeForciblyStop(t *testing.T) {
 	ch := make(chan struct{})
 	var rowsMerged, rowsDeleted uint64
 	close(ch)
-	if err := mergeBlockStreams(&mp.ph, &bsw, bsrs, ch, nil, 0, &rowsMerged, &rowsDeleted); !errors.Is(err, errForciblyStopped) {
+	if err := mergeBlockStreams(&mp.ph, &bsw, bsrs, ch, nil, nil, 0, 0, &rowsMerged, &rowsDeleted); !errors.Is(err, errForciblyStopped) {
 		t.Fatalf("unexpected error in mergeBlockStreams: got %v; want %v", err, errForciblyStopped)
 	}
 	if rowsMerged != 0 {
@@ -376,6 +378,74 @@ func TestMergeForciblyStop(t *testing.T) {
 	}
 }
 
+func TestMergeBlockStreamsWithRetentionFilters(t *testing.T) {
+	defer func() {
+		retentionFilters = nil
+	}()
+	rfs, err := parseRetentionFilters([]string{`{env="dev"}:50ms`})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	retentionFilters = rfs
+	var metricIDs uint64set.Set
+	metricIDs.Add(1)
+	rfm := &retentionFilterMetricIDs{
+		metricIDs: []*uint64set.Set{&metricIDs},
+	}
+
+	// Put interleaved samples for metricID=1 and metricID=2 into two streams,
+	// so the blocks for the same metricID are merged.
+	var bsrs []*blockStreamReader
+	for i := 0; i < 2; i++ {
+		var rows []rawRow
+		for _, metricID := range []uint64{1, 2} {
+			for ts := int64(i); ts < 100; ts += 2 {
+				rows = append(rows, rawRow{
+					TSID: TSID{
+						MetricID: metricID,
+					},
+					Timestamp:     ts,
+					Value:         float64(ts),
+					PrecisionBits: defaultPrecisionBits,
+				})
+			}
+		}
+		bsrs = append(bsrs, newTestBlockStreamReader(t, rows))
+	}
+
+	var mp inmemoryPart
+	var bsw blockStreamWriter
+	bsw.InitFromInmemoryPart(&mp)
+	var rowsMerged, rowsDeleted uint64
+	if err := mergeBlockStreams(&mp.ph, &bsw, bsrs, nil, nil, rfm, 100, 0, &rowsMerged, &rowsDeleted); err != nil {
+		t.Fatalf("unexpected error in mergeBlockStreams: %s", err)
+	}
+	if rowsDeleted != 50 {
+		t.Fatalf("unexpected rowsDeleted; got %d; want %d", rowsDeleted, 50)
+	}
+	if rowsMerged != 150 {
+		t.Fatalf("unexpected rowsMerged; got %d; want %d", rowsMerged, 150)
+	}
+
+	var bsr blockStreamReader
+	bsr.InitFromInmemoryPart(&mp)
+	for bsr.NextBlock() {
+		if err := bsr.Block.UnmarshalData(); err != nil {
+			t.Fatalf("cannot unmarshal block: %s", err)
+		}
+		minTimestampExpected := int64(0)
+		if bsr.Block.bh.TSID.MetricID == 1 {
+			minTimestampExpected = 50
+		}
+		if bsr.Block.bh.MinTimestamp != minTimestampExpected {
+			t.Fatalf("unexpected MinTimestamp for metricID=%d; got %d; want %d", bsr.Block.bh.TSID.MetricID, bsr.Block.bh.MinTimestamp, minTimestampExpected)
+		}
+	}
+	if err := bsr.Error(); err != nil {
+		t.Fatalf("unexpected error when reading merged blocks: %s", err)
+	}
+}
+
 func testMergeBlockStreams(t *testing.T, bsrs []*blockStreamReader, expectedBlocksCount, expectedRowsCount int, expectedMinTimestamp, expectedMaxTimestamp int64) {
 	t.Helper()
 
@@ -385,7 +455,7 @@ func testMergeBlockStreams(t *testing.T, bsrs []*blockStreamReader, expectedBloc
 	bsw.InitFromInmemoryPart(&mp)
 
 	var rowsMerged, rowsDeleted uint64
-	if err := mergeBlockStreams(&mp.ph, &bsw, bsrs, nil, nil, 0, &rowsMerged, &rowsDeleted); err != nil {
+	if err := mergeBlockStreams(&mp.ph, &bsw, bsrs, nil, nil, nil, 0, 0, &rowsMerged, &rowsDeleted); err != nil {
 		t.Fatalf("unexpected error in mergeBlockStreams: %s", err)
 	}
 
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
+)
+
+// SetBigMergeMaxBytesPerSecond sets the maximum write bandwidth shared by all the merges into big parts.
+//
+// The bandwidth isn't limited if n is 0.
+//
+// This function must be called before initializing the storage.
+func SetBigMergeMaxBytesPerSecond(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	bigMergeBandwidthLimiter.perSecondLimit = n
+}
+
+var bigMergeBandwidthLimiter bandwidthLimiter
+
+// bandwidthLimiter limits the number of bytes written per second by concurrent goroutines.
+type bandwidthLimiter struct {
+	// perSecondLimit is the maximum number of bytes, which can be written per second.
+	// The bandwidth isn't limited if perSecondLimit is 0.
+	perSecondLimit int64
+
+	mu sync.Mutex
+
+	// budget is the number of bytes, which can be written until deadline.
+	budget   int64
+	deadline time.Time
+}
+
+// Register registers n written bytes.
+//
+// It blocks until the bandwidth becomes available or until stopCh is closed.
+func (bl *bandwidthLimiter) Register(n uint64, stopCh <-chan struct{}) {
+	if bl.perSecondLimit <= 0 || n == 0 {
+		return
+	}
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	for bl.budget <= 0 {
+		if d := time.Until(bl.deadline); d > 0 {
+			t := timerpool.Get(d)
+			select {
+			case <-stopCh:
+				timerpool.Put(t)
+				return
+			case <-t.C:
+				timerpool.Put(t)
+			}
+		}
+		bl.budget += bl.perSecondLimit
+		bl.deadline = time.Now().Add(time.Second)
+	}
+	bl.budget -= int64(n)
+}
+
+// SetBigMergeWindow limits merges of big parts to the given daily time window in UTC.
+//
+// The window must be in the form `HH:MM-HH:MM`, for example, `22:00-06:00`. Big merges are allowed at any time if window is empty.
+//
+// This function must be called before initializing the storage.
+func SetBigMergeWindow(window string) error {
+	tw, err := parseTimeWindow(window)
+	if err != nil {
+		return err
+	}
+	bigMergeWindow = tw
+	return nil
+}
+
+var bigMergeWindow *timeWindow
+
+// timeWindow is a daily time window.
+type timeWindow struct {
+	// startMinute and endMinute are the number of minutes since the start of the day.
+	startMinute int
+	endMinute   int
+}
+
+func parseTimeWindow(s string) (*timeWindow, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var startHour, startMinute, endHour, endMinute int
+	if _, err := fmt.Sscanf(s, "%d:%d-%d:%d", &startHour, &startMinute, &endHour, &endMinute); err != nil {
+		return nil, fmt.Errorf("cannot parse time window %q; expecting HH:MM-HH:MM: %w", s, err)
+	}
+	for _, hour := range []int{startHour, endHour} {
+		if hour < 0 || hour > 23 {
+			return nil, fmt.Errorf("invalid hour %d in time window %q; it must be in the range [0..23]", hour, s)
+		}
+	}
+	for _, minute := range []int{startMinute, endMinute} {
+		if minute < 0 || minute > 59 {
+			return nil, fmt.Errorf("invalid minute %d in time window %q; it must be in the range [0..59]", minute, s)
+		}
+	}
+	tw := &timeWindow{
+		startMinute: startHour*60 + startMinute,
+		endMinute:   endHour*60 + endMinute,
+	}
+	if tw.startMinute == tw.endMinute {
+		return nil, fmt.Errorf("time window %q cannot be empty", s)
+	}
+	return tw, nil
+}
+
+// contains returns true if t is inside tw.
+//
+// The window spans midnight if its start is bigger than its end.
+func (tw *timeWindow) contains(t time.Time) bool {
+	if tw == nil {
+		return true
+	}
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	if tw.startMinute < tw.endMinute {
+		return minute >= tw.startMinute && minute < tw.endMinute
+	}
+	return minute >= tw.startMinute || minute < tw.endMinute
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeWindowSuccess(t *testing.T) {
+	f := func(s string, twExpected *timeWindow) {
+		t.Helper()
+		tw, err := parseTimeWindow(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if twExpected == nil {
+			if tw != nil {
+				t.Fatalf("expecting nil time window; got %+v", tw)
+			}
+			return
+		}
+		if *tw != *twExpected {
+			t.Fatalf("unexpected time window for %q; got %+v; want %+v", s, tw, twExpected)
+		}
+	}
+	f("", nil)
+	f("01:00-06:30", &timeWindow{startMinute: 60, endMinute: 6*60 + 30})
+	f("22:00-06:00", &timeWindow{startMinute: 22 * 60, endMinute: 6 * 60})
+	f("0:00-23:59", &timeWindow{startMinute: 0, endMinute: 23*60 + 59})
+}
+
+func TestParseTimeWindowFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if _, err := parseTimeWindow(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+	f("foo")
+	f("01:00")
+	f("24:00-06:00")
+	f("01:60-06:00")
+	f("-1:00-06:00")
+	f("06:00-06:00")
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	f := func(window string, hour, minute int, resultExpected bool) {
+		t.Helper()
+		tw, err := parseTimeWindow(window)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		ts := time.Date(2021, 12, 3, hour, minute, 0, 0, time.UTC)
+		result := tw.contains(ts)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q at %s; got %v; want %v", window, ts, result, resultExpected)
+		}
+	}
+	f("", 12, 0, true)
+
+	f("01:00-06:00", 0, 59, false)
+	f("01:00-06:00", 1, 0, true)
+	f("01:00-06:00", 5, 59, true)
+	f("01:00-06:00", 6, 0, false)
+
+	// window spanning midnight
+	f("22:00-06:00", 21, 59, false)
+	f("22:00-06:00", 22, 0, true)
+	f("22:00-06:00", 0, 0, true)
+	f("22:00-06:00", 5, 59, true)
+	f("22:00-06:00", 6, 0, false)
+	f("22:00-06:00", 12, 0, false)
+}
+
+func TestBandwidthLimiter(t *testing.T) {
+	var bl bandwidthLimiter
+
+	// Zero limit must never block.
+	bl.Register(1e9, nil)
+
+	bl.perSecondLimit = 1000
+	startTime := time.Now()
+	// The first second budget is available immediately.
+	bl.Register(1000, nil)
+	if d := time.Since(startTime); d > 500*time.Millisecond {
+		t.Fatalf("unexpected delay for the first registration; got %s", d)
+	}
+	// The next registration must wait for the next second.
+	bl.Register(1, nil)
+	if d := time.Since(startTime); d < 900*time.Millisecond {
+		t.Fatalf("expecting the delay for exceeded bandwidth; got %s", d)
+	}
+
+	// Closed stopCh must unblock Register.
+	bl.Register(1000, nil)
+	stopCh := make(chan struct{})
+	close(stopCh)
+	startTime = time.Now()
+	bl.Register(1, stopCh)
+	if d := time.Since(startTime); d > 500*time.Millisecond {
+		t.Fatalf("unexpected delay for closed stopCh; got %s", d)
+	}
+}
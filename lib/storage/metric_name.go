@@ -593,6 +593,13 @@ func unmarshalBytesFast(src []byte) ([]byte, []byte, error) {
 	return src[n:], src[:n], nil
 }
 
+// SortTags sorts tags in mn to canonical form needed for storing in the index.
+//
+// It must be called before mn.Marshal if the resulting marshaled name is compared to metric names obtained from the storage.
+func (mn *MetricName) SortTags() {
+	mn.sortTags()
+}
+
 // sortTags sorts tags in mn to canonical form needed for storing in the index.
 //
 // The function also de-duplicates tags with identical keys in mn. The last tag value
@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
+)
+
+var maxMetricsMetadataSizeBytes = 16 * 1024 * 1024
+
+// SetMetricsMetadataMaxSizeBytes sets the maximum memory size for metric metadata from `# HELP`, `# TYPE` and `# UNIT` lines.
+//
+// The least recently seen metadata entries are dropped when the limit is exceeded.
+//
+// This function must be called before OpenStorage.
+func SetMetricsMetadataMaxSizeBytes(maxSizeBytes int) {
+	maxMetricsMetadataSizeBytes = maxSizeBytes
+}
+
+// metricsMetadataRetentionSeconds is the duration for keeping metadata, which wasn't updated.
+const metricsMetadataRetentionSeconds = 24 * 3600
+
+// metricsMetadataEntryOverhead is the approximate memory overhead for a single metadata entry.
+const metricsMetadataEntryOverhead = 64
+
+// metricsMetadataFilename is the name of the file in the cache directory for persisting metric metadata across restarts.
+const metricsMetadataFilename = "metrics_metadata"
+
+type metricsMetadataEntry struct {
+	Type string
+	Help string
+	Unit string
+}
+
+func (e *metricsMetadataEntry) sizeBytes(metric string) int {
+	return len(metric) + len(e.Type) + len(e.Help) + len(e.Unit) + metricsMetadataEntryOverhead
+}
+
+// metricsMetadataStorage holds deduplicated metric metadata.
+type metricsMetadataStorage struct {
+	// evictedEntries is the number of entries dropped because of maxSizeBytes limit.
+	//
+	// It must be accessed atomically.
+	evictedEntries uint64
+
+	maxSizeBytes int
+
+	mu sync.Mutex
+
+	// m maps metric name to metadata entries with their last seen timestamps in seconds.
+	m map[string]map[metricsMetadataEntry]uint64
+
+	entriesLen int
+	sizeBytes  int
+
+	lastCleanupTimestamp uint64
+}
+
+func newMetricsMetadataStorage(maxSizeBytes int) *metricsMetadataStorage {
+	return &metricsMetadataStorage{
+		maxSizeBytes: maxSizeBytes,
+		m:            make(map[string]map[metricsMetadataEntry]uint64),
+	}
+}
+
+func (ms *metricsMetadataStorage) add(rows []prommetadata.Row, timestamp uint64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for i := range rows {
+		r := &rows[i]
+		if r.Metric == "" || (r.Type == "" && r.Help == "" && r.Unit == "") {
+			continue
+		}
+		ms.addEntryLocked(r.Metric, metricsMetadataEntry{
+			Type: r.Type,
+			Help: r.Help,
+			Unit: r.Unit,
+		}, timestamp)
+	}
+	if ms.sizeBytes > ms.maxSizeBytes {
+		ms.evictLocked()
+	}
+	currentTimestamp := fasttime.UnixTimestamp()
+	if currentTimestamp-ms.lastCleanupTimestamp > 60 {
+		ms.cleanupLocked(currentTimestamp)
+		ms.lastCleanupTimestamp = currentTimestamp
+	}
+}
+
+func (ms *metricsMetadataStorage) addEntryLocked(metric string, e metricsMetadataEntry, timestamp uint64) {
+	entries := ms.m[metric]
+	if entries == nil {
+		entries = make(map[metricsMetadataEntry]uint64)
+		ms.m[cloneString(metric)] = entries
+	}
+	prevTimestamp, ok := entries[e]
+	if !ok {
+		// Clone strings, since they may refer to the scraped response body, which may be re-used by the caller.
+		e.Type = cloneString(e.Type)
+		e.Help = cloneString(e.Help)
+		e.Unit = cloneString(e.Unit)
+		ms.entriesLen++
+		ms.sizeBytes += e.sizeBytes(metric)
+	} else if prevTimestamp > timestamp {
+		return
+	}
+	entries[e] = timestamp
+}
+
+func (ms *metricsMetadataStorage) deleteEntryLocked(metric string, e metricsMetadataEntry) {
+	entries := ms.m[metric]
+	delete(entries, e)
+	if len(entries) == 0 {
+		delete(ms.m, metric)
+	}
+	ms.entriesLen--
+	ms.sizeBytes -= e.sizeBytes(metric)
+}
+
+// evictLocked drops the least recently seen entries until their size drops below 90% of maxSizeBytes.
+//
+// The additional 10% are freed in order to reduce the frequency of evictions.
+func (ms *metricsMetadataStorage) evictLocked() {
+	type evictionItem struct {
+		metric    string
+		e         metricsMetadataEntry
+		timestamp uint64
+	}
+	items := make([]evictionItem, 0, ms.entriesLen)
+	for metric, entries := range ms.m {
+		for e, timestamp := range entries {
+			items = append(items, evictionItem{
+				metric:    metric,
+				e:         e,
+				timestamp: timestamp,
+			})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].timestamp < items[j].timestamp
+	})
+	maxSizeBytes := ms.maxSizeBytes * 9 / 10
+	evicted := uint64(0)
+	for i := range items {
+		if ms.sizeBytes <= maxSizeBytes {
+			break
+		}
+		ms.deleteEntryLocked(items[i].metric, items[i].e)
+		evicted++
+	}
+	atomic.AddUint64(&ms.evictedEntries, evicted)
+}
+
+func (ms *metricsMetadataStorage) cleanupLocked(currentTimestamp uint64) {
+	for metric, entries := range ms.m {
+		for e, timestamp := range entries {
+			if currentTimestamp > timestamp+metricsMetadataRetentionSeconds {
+				ms.deleteEntryLocked(metric, e)
+			}
+		}
+	}
+}
+
+func (ms *metricsMetadataStorage) search(metric string, limit, limitPerMetric int) []prommetadata.Row {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var metricNames []string
+	if metric != "" {
+		if _, ok := ms.m[metric]; ok {
+			metricNames = append(metricNames, metric)
+		}
+	} else {
+		for k := range ms.m {
+			metricNames = append(metricNames, k)
+		}
+	}
+	sort.Strings(metricNames)
+	if limit > 0 && len(metricNames) > limit {
+		metricNames = metricNames[:limit]
+	}
+	var rows []prommetadata.Row
+	for _, k := range metricNames {
+		rowsLen := len(rows)
+		for e := range ms.m[k] {
+			rows = append(rows, prommetadata.Row{
+				Metric: k,
+				Type:   e.Type,
+				Help:   e.Help,
+				Unit:   e.Unit,
+			})
+		}
+		rowsMetric := rows[rowsLen:]
+		sort.Slice(rowsMetric, func(i, j int) bool {
+			a, b := &rowsMetric[i], &rowsMetric[j]
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+			if a.Help != b.Help {
+				return a.Help < b.Help
+			}
+			return a.Unit < b.Unit
+		})
+		if limitPerMetric > 0 && len(rowsMetric) > limitPerMetric {
+			rows = rows[:rowsLen+limitPerMetric]
+		}
+	}
+	return rows
+}
+
+func (ms *metricsMetadataStorage) mustSave(path string) {
+	logger.Infof("saving metrics metadata to %q...", path)
+	startTime := time.Now()
+
+	ms.mu.Lock()
+	var dst []byte
+	for metric, entries := range ms.m {
+		for e, timestamp := range entries {
+			dst = encoding.MarshalBytes(dst, []byte(metric))
+			dst = encoding.MarshalBytes(dst, []byte(e.Type))
+			dst = encoding.MarshalBytes(dst, []byte(e.Help))
+			dst = encoding.MarshalBytes(dst, []byte(e.Unit))
+			dst = encoding.MarshalUint64(dst, timestamp)
+		}
+	}
+	entriesLen := ms.entriesLen
+	ms.mu.Unlock()
+
+	if err := ioutil.WriteFile(path, dst, 0644); err != nil {
+		logger.Panicf("FATAL: cannot write %d bytes to %q: %s", len(dst), path, err)
+	}
+	logger.Infof("saved metrics metadata to %q in %.3f seconds; entriesCount: %d; sizeBytes: %d", path, time.Since(startTime).Seconds(), entriesLen, len(dst))
+}
+
+func (ms *metricsMetadataStorage) mustLoad(path string) {
+	logger.Infof("loading metrics metadata from %q...", path)
+	startTime := time.Now()
+	if !fs.IsPathExist(path) {
+		logger.Infof("nothing to load from %q", path)
+		return
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Panicf("FATAL: cannot read %s: %s", path, err)
+	}
+	srcOrigLen := len(src)
+	var rows []prommetadata.Row
+	var timestamps []uint64
+	for len(src) > 0 {
+		var r prommetadata.Row
+		var timestamp uint64
+		src, err = unmarshalMetricsMetadataEntry(&r, &timestamp, src)
+		if err != nil {
+			logger.Errorf("discarding the rest of %s, since it has broken metadata entry: %s", path, err)
+			break
+		}
+		rows = append(rows, r)
+		timestamps = append(timestamps, timestamp)
+	}
+	for i := range rows {
+		ms.add(rows[i:i+1], timestamps[i])
+	}
+	logger.Infof("loaded metrics metadata from %q in %.3f seconds; entriesCount: %d; sizeBytes: %d", path, time.Since(startTime).Seconds(), ms.entriesLen, srcOrigLen)
+}
+
+func unmarshalMetricsMetadataEntry(r *prommetadata.Row, timestamp *uint64, src []byte) ([]byte, error) {
+	fields := []*string{&r.Metric, &r.Type, &r.Help, &r.Unit}
+	for _, field := range fields {
+		tail, data, err := encoding.UnmarshalBytes(src)
+		if err != nil {
+			return tail, err
+		}
+		*field = string(data)
+		src = tail
+	}
+	if len(src) < 8 {
+		return src, fmt.Errorf("cannot unmarshal timestamp from %d bytes; need at least 8 bytes", len(src))
+	}
+	*timestamp = encoding.UnmarshalUint64(src)
+	return src[8:], nil
+}
+
+func (ms *metricsMetadataStorage) updateMetrics(m *Metrics) {
+	ms.mu.Lock()
+	m.MetricsMetadataEntries += uint64(ms.entriesLen)
+	m.MetricsMetadataSizeBytes += uint64(ms.sizeBytes)
+	ms.mu.Unlock()
+
+	m.MetricsMetadataMaxSizeBytes += uint64(ms.maxSizeBytes)
+	m.MetricsMetadataEvictedEntries += atomic.LoadUint64(&ms.evictedEntries)
+}
+
+// AddMetricsMetadata adds metric metadata rows obtained from `# HELP`, `# TYPE` and `# UNIT` lines to s.
+//
+// Duplicate rows are stored only once.
+func (s *Storage) AddMetricsMetadata(rows []prommetadata.Row) {
+	s.metricsMetadata.add(rows, fasttime.UnixTimestamp())
+}
+
+// SearchMetricsMetadata returns metric metadata rows sorted by metric name.
+//
+// If metric is non-empty, then only rows for the given metric are returned.
+// limit limits the number of returned metrics, while limitPerMetric limits the number of returned rows per metric.
+// Non-positive limits mean no limit.
+func (s *Storage) SearchMetricsMetadata(metric string, limit, limitPerMetric int) []prommetadata.Row {
+	return s.metricsMetadata.search(metric, limit, limitPerMetric)
+}
+
+func cloneString(s string) string {
+	return string(append([]byte{}, s...))
+}
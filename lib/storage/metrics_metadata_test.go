@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
+)
+
+func TestMetricsMetadataStorageAddSearch(t *testing.T) {
+	ms := newMetricsMetadataStorage(1e6)
+	timestamp := fasttime.UnixTimestamp()
+	ms.add([]prommetadata.Row{
+		{
+			Metric: "foo",
+			Type:   "counter",
+			Help:   "foo help",
+		},
+		{
+			Metric: "bar",
+			Type:   "gauge",
+		},
+		{
+			// Rows without metadata must be ignored
+			Metric: "baz",
+		},
+	}, timestamp)
+	ms.add([]prommetadata.Row{
+		{
+			Metric: "foo",
+			Type:   "counter",
+			Help:   "another foo help",
+		},
+		{
+			// Duplicate row
+			Metric: "bar",
+			Type:   "gauge",
+		},
+	}, timestamp)
+
+	f := func(metric string, limit, limitPerMetric int, rowsExpected []prommetadata.Row) {
+		t.Helper()
+		rows := ms.search(metric, limit, limitPerMetric)
+		if !reflect.DeepEqual(rows, rowsExpected) {
+			t.Fatalf("unexpected rows for search(%q, %d, %d);\ngot\n%#v\nwant\n%#v", metric, limit, limitPerMetric, rows, rowsExpected)
+		}
+	}
+	barRow := prommetadata.Row{
+		Metric: "bar",
+		Type:   "gauge",
+	}
+	fooRows := []prommetadata.Row{
+		{
+			Metric: "foo",
+			Type:   "counter",
+			Help:   "another foo help",
+		},
+		{
+			Metric: "foo",
+			Type:   "counter",
+			Help:   "foo help",
+		},
+	}
+	f("", 0, 0, append([]prommetadata.Row{barRow}, fooRows...))
+	f("", 1, 0, []prommetadata.Row{barRow})
+	f("", 0, 1, []prommetadata.Row{barRow, fooRows[0]})
+	f("foo", 0, 0, fooRows)
+	f("baz", 0, 0, nil)
+	f("non-existing", 0, 0, nil)
+
+	var m Metrics
+	ms.updateMetrics(&m)
+	if m.MetricsMetadataEntries != 3 {
+		t.Fatalf("unexpected number of entries; got %d; want 3", m.MetricsMetadataEntries)
+	}
+}
+
+func TestMetricsMetadataStorageEvict(t *testing.T) {
+	ms := newMetricsMetadataStorage(10 * (metricsMetadataEntryOverhead + 10))
+	timestamp := fasttime.UnixTimestamp()
+	for i := 0; i < 20; i++ {
+		ms.add([]prommetadata.Row{{
+			Metric: fmt.Sprintf("metric_%02d", i),
+			Type:   "gauge",
+		}}, timestamp+uint64(i))
+	}
+	if ms.sizeBytes > ms.maxSizeBytes {
+		t.Fatalf("too big size for metadata entries; got %d bytes; mustn't exceed %d bytes", ms.sizeBytes, ms.maxSizeBytes)
+	}
+	if n := ms.evictedEntries; n == 0 {
+		t.Fatalf("expecting non-zero number of evicted entries")
+	}
+	// The most recently seen entries must be preserved.
+	rows := ms.search("", 0, 0)
+	if len(rows) == 0 || rows[len(rows)-1].Metric != "metric_19" {
+		t.Fatalf("the most recently seen entry must be preserved; got %#v", rows)
+	}
+	if rows := ms.search("metric_00", 0, 0); len(rows) != 0 {
+		t.Fatalf("the least recently seen entry must be evicted; got %#v", rows)
+	}
+}
+
+func TestStorageMetricsMetadata(t *testing.T) {
+	const path = "TestStorageMetricsMetadata"
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	rowsExpected := []prommetadata.Row{{
+		Metric: "foo",
+		Type:   "counter",
+		Help:   "foo help",
+		Unit:   "seconds",
+	}}
+	s.AddMetricsMetadata(rowsExpected)
+
+	// Metadata must be preserved after the restart.
+	s.MustClose()
+	s, err = OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot re-open storage: %s", err)
+	}
+	rows := s.SearchMetricsMetadata("", 0, 0)
+	if !reflect.DeepEqual(rows, rowsExpected) {
+		t.Fatalf("unexpected rows after the restart;\ngot\n%#v\nwant\n%#v", rows, rowsExpected)
+	}
+	s.MustClose()
+}
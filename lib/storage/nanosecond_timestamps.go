@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+)
+
+// SetNanosecondPrecisionMetricPrefixes enables nanosecond timestamps for time series with metric names starting with the given prefixes.
+//
+// Timestamps for the remaining time series are stored with millisecond precision.
+//
+// This function must be called before initializing the storage.
+func SetNanosecondPrecisionMetricPrefixes(prefixes []string) error {
+	var a []string
+	for _, prefix := range prefixes {
+		if len(prefix) == 0 {
+			return fmt.Errorf("metric name prefix cannot be empty")
+		}
+		a = append(a, prefix)
+	}
+	nanosecondPrecisionMetricPrefixes = a
+	return nil
+}
+
+var nanosecondPrecisionMetricPrefixes []string
+
+// isNanosecondPrecisionMetric returns true if samples for the given metricNameRaw must be stored with nanosecond timestamps.
+func isNanosecondPrecisionMetric(metricNameRaw []byte) bool {
+	if len(nanosecondPrecisionMetricPrefixes) == 0 {
+		return false
+	}
+	// The first entry in metricNameRaw is an empty key, while the second entry is the metric name.
+	// See MetricName.marshalRaw for details.
+	tail, _, err := unmarshalBytesFast(metricNameRaw)
+	if err != nil {
+		return false
+	}
+	_, metricGroup, err := unmarshalBytesFast(tail)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range nanosecondPrecisionMetricPrefixes {
+		if len(metricGroup) >= len(prefix) && string(metricGroup[:len(prefix)]) == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// nanosecondTimestampsFlag is set in the marshaled TimestampsMarshalType for blocks with nanosecond timestamps.
+//
+// This keeps the on-disk format compatible with blocks containing millisecond timestamps.
+const nanosecondTimestampsFlag = 0x80
+
+// The range of timestamps in milliseconds, which may be represented in nanoseconds.
+const (
+	minNanosecondTimestampMsecs = math.MinInt64/1000000 + 1
+	maxNanosecondTimestampMsecs = math.MaxInt64/1000000 - 1
+)
+
+// nanosToMsecs converts the given timestamp in nanoseconds to milliseconds.
+func nanosToMsecs(nanos int64) int64 {
+	msecs := nanos / 1e6
+	if nanos%1e6 < 0 {
+		msecs--
+	}
+	return msecs
+}
+
+// timestampToMsecs converts timestamp from b to milliseconds.
+func (b *Block) timestampToMsecs(timestamp int64) int64 {
+	if !b.bh.NanosecondTimestamps {
+		return timestamp
+	}
+	return nanosToMsecs(timestamp)
+}
+
+// minTimestampFromMsecs returns the minimum timestamp for b, which corresponds to the given msecs.
+func (b *Block) minTimestampFromMsecs(msecs int64) int64 {
+	if !b.bh.NanosecondTimestamps {
+		return msecs
+	}
+	if msecs < minNanosecondTimestampMsecs {
+		return math.MinInt64
+	}
+	if msecs > maxNanosecondTimestampMsecs {
+		return math.MaxInt64
+	}
+	return msecs * 1e6
+}
+
+// maxTimestampFromMsecs returns the maximum timestamp for b, which corresponds to the given msecs.
+func (b *Block) maxTimestampFromMsecs(msecs int64) int64 {
+	if !b.bh.NanosecondTimestamps {
+		return msecs
+	}
+	if msecs < minNanosecondTimestampMsecs {
+		return math.MinInt64
+	}
+	if msecs > maxNanosecondTimestampMsecs {
+		return math.MaxInt64
+	}
+	return msecs*1e6 + (1e6 - 1)
+}
+
+// convertToNanosecondTimestamps converts millisecond timestamps in the unmarshaled b to nanosecond timestamps.
+func (b *Block) convertToNanosecondTimestamps() {
+	if b.bh.NanosecondTimestamps {
+		return
+	}
+	timestamps := b.timestamps
+	for i := range timestamps {
+		timestamps[i] *= 1e6
+	}
+	b.bh.NanosecondTimestamps = true
+}
+
+// HasNanosecondTimestamps returns true if b contains timestamps with nanosecond precision.
+func (b *Block) HasNanosecondTimestamps() bool {
+	return b.bh.NanosecondTimestamps
+}
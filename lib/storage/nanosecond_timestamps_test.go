@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIsNanosecondPrecisionMetric(t *testing.T) {
+	defer func() {
+		_ = SetNanosecondPrecisionMetricPrefixes(nil)
+	}()
+	if err := SetNanosecondPrecisionMetricPrefixes([]string{"net_", "foo"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(metricGroup string, resultExpected bool) {
+		t.Helper()
+		mn := MetricName{
+			MetricGroup: []byte(metricGroup),
+			Tags: []Tag{{
+				Key:   []byte("net_"),
+				Value: []byte("foo"),
+			}},
+		}
+		metricNameRaw := mn.marshalRaw(nil)
+		result := isNanosecondPrecisionMetric(metricNameRaw)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %v; want %v", metricGroup, result, resultExpected)
+		}
+	}
+	f("net_rx_bytes", true)
+	f("net_", true)
+	f("foo", true)
+	f("foobar", true)
+	f("net", false)
+	f("bar", false)
+	f("", false)
+
+	if err := SetNanosecondPrecisionMetricPrefixes([]string{"foo", ""}); err == nil {
+		t.Fatalf("expecting non-nil error for empty prefix")
+	}
+}
+
+func TestNanosToMsecs(t *testing.T) {
+	f := func(nanos, msecsExpected int64) {
+		t.Helper()
+		msecs := nanosToMsecs(nanos)
+		if msecs != msecsExpected {
+			t.Fatalf("unexpected msecs for nanos=%d; got %d; want %d", nanos, msecs, msecsExpected)
+		}
+	}
+	f(0, 0)
+	f(999999, 0)
+	f(1000000, 1)
+	f(1234567890, 1234)
+	f(-1, -1)
+	f(-1000000, -1)
+	f(-1000001, -2)
+}
+
+func TestBlockNanosecondTimestampsMarshalUnmarshal(t *testing.T) {
+	var timestamps, values []int64
+	ts := int64(1600000000000) * 1e6
+	for i := 0; i < 1000; i++ {
+		// Put multiple samples into the same millisecond.
+		ts += int64(i%3) * 123
+		timestamps = append(timestamps, ts)
+		values = append(values, int64(i))
+	}
+	var b Block
+	b.Init(&TSID{MetricID: 123}, timestamps, values, 0, 64)
+	b.bh.NanosecondTimestamps = true
+	headerData, timestampsData, valuesData := b.MarshalData(0, 0)
+	if b.bh.MinTimestamp != nanosToMsecs(timestamps[0]) {
+		t.Fatalf("unexpected MinTimestamp; got %d; want %d", b.bh.MinTimestamp, nanosToMsecs(timestamps[0]))
+	}
+	if b.bh.MaxTimestamp != nanosToMsecs(timestamps[len(timestamps)-1]) {
+		t.Fatalf("unexpected MaxTimestamp; got %d; want %d", b.bh.MaxTimestamp, nanosToMsecs(timestamps[len(timestamps)-1]))
+	}
+
+	// Unmarshal the block from the marshaled header and data.
+	var b2 Block
+	tail, err := b2.bh.Unmarshal(headerData)
+	if err != nil {
+		t.Fatalf("cannot unmarshal block header: %s", err)
+	}
+	if len(tail) > 0 {
+		t.Fatalf("unexpected non-empty tail after unmarshaling block header: %X", tail)
+	}
+	if !b2.bh.NanosecondTimestamps {
+		t.Fatalf("NanosecondTimestamps must be set in the unmarshaled block header")
+	}
+	b2.timestampsData = append(b2.timestampsData[:0], timestampsData...)
+	b2.valuesData = append(b2.valuesData[:0], valuesData...)
+	if err := b2.UnmarshalData(); err != nil {
+		t.Fatalf("cannot unmarshal block data: %s", err)
+	}
+	if !reflect.DeepEqual(b2.timestamps, timestamps) {
+		t.Fatalf("unexpected timestamps after unmarshaling")
+	}
+
+	// Verify portable marshaling.
+	var b3 Block
+	data := b2.MarshalPortable(nil)
+	tail, err = b3.UnmarshalPortable(data)
+	if err != nil {
+		t.Fatalf("cannot unmarshal portable block: %s", err)
+	}
+	if len(tail) > 0 {
+		t.Fatalf("unexpected non-empty tail after unmarshaling portable block: %X", tail)
+	}
+	tr := TimeRange{
+		MinTimestamp: 0,
+		MaxTimestamp: math.MaxInt64,
+	}
+	nanos, _ := b3.AppendRowsWithTimeRangeFilterNanos(nil, nil, tr)
+	if !reflect.DeepEqual(nanos, timestamps) {
+		t.Fatalf("unexpected nanosecond timestamps after portable unmarshaling")
+	}
+	msecs, _ := b3.AppendRowsWithTimeRangeFilter(nil, nil, tr)
+	for i, ts := range msecs {
+		if ts != nanosToMsecs(timestamps[i]) {
+			t.Fatalf("unexpected timestamp at position %d; got %d; want %d", i, ts, nanosToMsecs(timestamps[i]))
+		}
+	}
+
+	// Verify time range filtering, which is performed in milliseconds.
+	tr = TimeRange{
+		MinTimestamp: nanosToMsecs(timestamps[100]),
+		MaxTimestamp: nanosToMsecs(timestamps[200]),
+	}
+	nanos, _ = b3.AppendRowsWithTimeRangeFilterNanos(nil, nil, tr)
+	for _, ts := range nanos {
+		if msecs := nanosToMsecs(ts); msecs < tr.MinTimestamp || msecs > tr.MaxTimestamp {
+			t.Fatalf("timestamp %d is outside the time range %s", ts, &tr)
+		}
+	}
+	for _, ts := range timestamps {
+		if msecs := nanosToMsecs(ts); msecs >= tr.MinTimestamp && msecs <= tr.MaxTimestamp {
+			if len(nanos) == 0 || nanos[0] != ts {
+				t.Fatalf("missing timestamp %d on the time range %s", ts, &tr)
+			}
+			nanos = nanos[1:]
+		}
+	}
+}
+
+func TestStorageNanosecondTimestamps(t *testing.T) {
+	const path = "TestStorageNanosecondTimestamps"
+	defer func() {
+		_ = os.RemoveAll(path)
+		_ = SetNanosecondPrecisionMetricPrefixes(nil)
+	}()
+	if err := SetNanosecondPrecisionMetricPrefixes([]string{"net_"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	const rowsPerBatch = 1000
+	minTimestamp := time.Now().Add(-time.Hour).UnixNano() / 1e6
+	addRows := func(metricGroup string, offset int) {
+		t.Helper()
+		mn := MetricName{
+			MetricGroup: []byte(metricGroup),
+		}
+		metricNameRaw := mn.marshalRaw(nil)
+		var mrs []MetricRow
+		for i := 0; i < rowsPerBatch; i++ {
+			// Put 10 distinct samples into every millisecond.
+			n := offset + i
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     minTimestamp + int64(n/10),
+				Nanos:         int32(n%10) * 1000,
+				Value:         float64(n),
+			})
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("cannot add rows: %s", err)
+		}
+		s.DebugFlush()
+	}
+	// Add overlapping batches in order to verify merging of blocks with nanosecond timestamps.
+	addRows("net_rx", 0)
+	addRows("net_rx", rowsPerBatch/2)
+	addRows("net_rx", rowsPerBatch)
+	addRows("cpu", 0)
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+
+	checkRows := func(metricGroup string, timestampsExpected []int64) {
+		t.Helper()
+		tfs := NewTagFilters()
+		if err := tfs.Add(nil, []byte(metricGroup), false, false); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		tr := TimeRange{
+			MinTimestamp: minTimestamp,
+			MaxTimestamp: minTimestamp + rowsPerBatch,
+		}
+		var sr Search
+		var b Block
+		var timestamps []int64
+		var values []float64
+		sr.Init(s, []*TagFilters{tfs}, tr, 1e5, noDeadline)
+		for sr.NextMetricBlock() {
+			sr.MetricBlockRef.BlockRef.MustReadBlock(&b, true)
+			if err := b.UnmarshalData(); err != nil {
+				t.Fatalf("cannot unmarshal block: %s", err)
+			}
+			timestamps, values = b.AppendRowsWithTimeRangeFilterNanos(timestamps, values, tr)
+		}
+		if err := sr.Error(); err != nil {
+			t.Fatalf("search error: %s", err)
+		}
+		sr.MustClose()
+		if !reflect.DeepEqual(timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps for %q; got %d timestamps; want %d timestamps", metricGroup, len(timestamps), len(timestampsExpected))
+		}
+	}
+
+	var timestampsExpected []int64
+	for n := 0; n < 2*rowsPerBatch; n++ {
+		ts := (minTimestamp+int64(n/10))*1e6 + int64(n%10)*1000
+		timestampsExpected = append(timestampsExpected, ts)
+		if n >= rowsPerBatch/2 && n < rowsPerBatch+rowsPerBatch/2 {
+			// The sample from the overlapping batch.
+			timestampsExpected = append(timestampsExpected, ts)
+		}
+	}
+	checkRows("net_rx", timestampsExpected)
+
+	// Sub-millisecond parts must be dropped for metrics without nanosecond timestamps.
+	timestampsExpected = timestampsExpected[:0]
+	for i := 0; i < rowsPerBatch; i++ {
+		timestampsExpected = append(timestampsExpected, (minTimestamp+int64(i/10))*1e6)
+	}
+	checkRows("cpu", timestampsExpected)
+
+	s.MustClose()
+}
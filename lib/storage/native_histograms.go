@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+// histogramsFlag is set in the marshaled ValuesMarshalType for blocks with native histograms.
+//
+// This keeps the on-disk format compatible with blocks containing only float values.
+const histogramsFlag = 0x80
+
+// maxHistogramsBlockSize is the maximum size of values data for blocks with native histograms.
+//
+// It is bigger than maxBlockSize, since every row in such blocks contains the whole histogram.
+const maxHistogramsBlockSize = 32 * 1024 * 1024
+
+// histogramsCompressLevel is zstd compression level for native histograms.
+//
+// Histograms for the same time series usually have similar bucket layouts, so they are compressed well.
+const histogramsCompressLevel = 1
+
+// HasHistograms returns true if b contains native histograms.
+func (b *Block) HasHistograms() bool {
+	return b.bh.Histograms
+}
+
+// AppendHistogramsWithTimeRangeFilter appends native histograms for samples from b on the given tr to dst.
+//
+// The appended histograms match the samples returned from AppendRowsWithTimeRangeFilter for the same tr.
+// Empty histograms correspond to samples with ordinary float values.
+// The appended histograms don't refer to b, so they remain valid after b is changed.
+//
+// It is expected that UnmarshalData has been already called on b.
+func (b *Block) AppendHistogramsWithTimeRangeFilter(dst [][]byte, tr TimeRange) [][]byte {
+	i, j := b.getRowsRange(tr)
+	if !b.bh.Histograms {
+		for ; i < j; i++ {
+			dst = append(dst, nil)
+		}
+		return dst
+	}
+	histograms := b.histograms[i:j]
+	n := 0
+	for _, h := range histograms {
+		n += len(h)
+	}
+	buf := make([]byte, 0, n)
+	for _, h := range histograms {
+		if len(h) == 0 {
+			dst = append(dst, nil)
+			continue
+		}
+		start := len(buf)
+		buf = append(buf, h...)
+		dst = append(dst, buf[start:len(buf):len(buf)])
+	}
+	return dst
+}
+
+// setHistograms sets histograms for rows in b initialized with Init.
+//
+// Empty histograms correspond to rows with ordinary float values.
+func (b *Block) setHistograms(histograms [][]byte) {
+	b.bh.Histograms = true
+	b.histograms = b.histograms[:0]
+	b.histogramsBuf = b.histogramsBuf[:0]
+	b.appendHistograms(histograms)
+}
+
+// appendHistograms appends copies of histograms to b.
+func (b *Block) appendHistograms(histograms [][]byte) {
+	for _, h := range histograms {
+		if len(h) == 0 {
+			b.histograms = append(b.histograms, nil)
+			continue
+		}
+		start := len(b.histogramsBuf)
+		b.histogramsBuf = append(b.histogramsBuf, h...)
+		b.histograms = append(b.histograms, b.histogramsBuf[start:len(b.histogramsBuf):len(b.histogramsBuf)])
+	}
+}
+
+// convertToHistograms converts the unmarshaled b with float values to a block with native histograms.
+//
+// Rows in the converted block keep their float values.
+func (b *Block) convertToHistograms() {
+	if b.bh.Histograms {
+		return
+	}
+	b.histograms = b.histograms[:0]
+	for range b.values {
+		b.histograms = append(b.histograms, nil)
+	}
+	b.bh.Histograms = true
+}
+
+// calibrateHistograms converts the unmarshaled b1 and b2 to blocks with native histograms if one of them contains native histograms.
+//
+// This may happen when a time series contains both float samples and native histograms.
+func calibrateHistograms(b1, b2 *Block) {
+	if b1.bh.Histograms == b2.bh.Histograms {
+		return
+	}
+	b1.convertToHistograms()
+	b2.convertToHistograms()
+}
+
+// marshalHistograms appends marshaled histograms to dst and returns the result.
+func marshalHistograms(dst []byte, histograms [][]byte) []byte {
+	bb := histogramsBufPool.Get()
+	for _, h := range histograms {
+		bb.B = encoding.MarshalBytes(bb.B, h)
+	}
+	compressedBB := histogramsBufPool.Get()
+	compressedBB.B = encoding.CompressZSTDLevel(compressedBB.B[:0], bb.B, histogramsCompressLevel)
+	dst = encoding.MarshalBytes(dst, compressedBB.B)
+	histogramsBufPool.Put(compressedBB)
+	histogramsBufPool.Put(bb)
+	return dst
+}
+
+// unmarshalHistograms unmarshals rowsCount histograms from src into b and returns the remaining tail.
+func (b *Block) unmarshalHistograms(src []byte, rowsCount int) ([]byte, error) {
+	tail, compressedData, err := encoding.UnmarshalBytes(src)
+	if err != nil {
+		return tail, fmt.Errorf("cannot unmarshal compressed histograms: %w", err)
+	}
+	b.histogramsBuf, err = encoding.DecompressZSTD(b.histogramsBuf[:0], compressedData)
+	if err != nil {
+		return tail, fmt.Errorf("cannot decompress histograms: %w", err)
+	}
+	b.histograms = b.histograms[:0]
+	data := b.histogramsBuf
+	for i := 0; i < rowsCount; i++ {
+		var h []byte
+		data, h, err = encoding.UnmarshalBytes(data)
+		if err != nil {
+			return tail, fmt.Errorf("cannot unmarshal histogram #%d out of %d: %w", i, rowsCount, err)
+		}
+		if len(h) == 0 {
+			h = nil
+		}
+		b.histograms = append(b.histograms, h[:len(h):len(h)])
+	}
+	if len(data) > 0 {
+		return tail, fmt.Errorf("unexpected non-empty tail left after unmarshaling %d histograms; len(tail)=%d", rowsCount, len(data))
+	}
+	return tail, nil
+}
+
+var histogramsBufPool bytesutil.ByteBufferPool
+
+// copyHistogram returns a copy of h for storing in rawRow.
+//
+// The copy is needed, since h usually refers to a buffer, which is re-used by the caller after adding rows to the storage.
+func copyHistogram(h []byte) []byte {
+	if len(h) == 0 {
+		return nil
+	}
+	return append([]byte{}, h...)
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBlockHistogramsMarshalUnmarshal(t *testing.T) {
+	var timestamps, values []int64
+	var histograms [][]byte
+	for i := 0; i < 1000; i++ {
+		timestamps = append(timestamps, int64(i)*1000)
+		values = append(values, int64(i))
+		if i%7 == 0 {
+			// Mix float samples with histograms.
+			histograms = append(histograms, nil)
+		} else {
+			histograms = append(histograms, []byte(fmt.Sprintf("histogram_%d", i)))
+		}
+	}
+	var b Block
+	b.Init(&TSID{MetricID: 123}, timestamps, values, 0, 64)
+	b.setHistograms(histograms)
+	headerData, timestampsData, valuesData := b.MarshalData(0, 0)
+
+	var b2 Block
+	tail, err := b2.bh.Unmarshal(headerData)
+	if err != nil {
+		t.Fatalf("cannot unmarshal block header: %s", err)
+	}
+	if len(tail) > 0 {
+		t.Fatalf("unexpected non-empty tail after unmarshaling block header: %X", tail)
+	}
+	if !b2.HasHistograms() {
+		t.Fatalf("Histograms must be set in the unmarshaled block header")
+	}
+	b2.timestampsData = append(b2.timestampsData[:0], timestampsData...)
+	b2.valuesData = append(b2.valuesData[:0], valuesData...)
+	if err := b2.UnmarshalData(); err != nil {
+		t.Fatalf("cannot unmarshal block data: %s", err)
+	}
+	if !reflect.DeepEqual(b2.values, values) {
+		t.Fatalf("unexpected values after unmarshaling")
+	}
+
+	// Verify portable marshaling.
+	var b3 Block
+	data := b2.MarshalPortable(nil)
+	tail, err = b3.UnmarshalPortable(data)
+	if err != nil {
+		t.Fatalf("cannot unmarshal portable block: %s", err)
+	}
+	if len(tail) > 0 {
+		t.Fatalf("unexpected non-empty tail after unmarshaling portable block: %X", tail)
+	}
+	tr := TimeRange{
+		MinTimestamp: 0,
+		MaxTimestamp: math.MaxInt64,
+	}
+	result := b3.AppendHistogramsWithTimeRangeFilter(nil, tr)
+	if !reflect.DeepEqual(result, histograms) {
+		t.Fatalf("unexpected histograms after portable unmarshaling")
+	}
+
+	// Verify time range filtering.
+	tr = TimeRange{
+		MinTimestamp: 100 * 1000,
+		MaxTimestamp: 200 * 1000,
+	}
+	result = b3.AppendHistogramsWithTimeRangeFilter(nil, tr)
+	if !reflect.DeepEqual(result, histograms[100:201]) {
+		t.Fatalf("unexpected histograms on the time range %s", &tr)
+	}
+	timestampsResult, _ := b3.AppendRowsWithTimeRangeFilter(nil, nil, tr)
+	if len(timestampsResult) != len(result) {
+		t.Fatalf("the number of histograms must match the number of timestamps; got %d vs %d", len(result), len(timestampsResult))
+	}
+}
+
+func TestStorageNativeHistograms(t *testing.T) {
+	const path = "TestStorageNativeHistograms"
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	const rowsPerBatch = 1000
+	minTimestamp := time.Now().Add(-time.Hour).UnixNano() / 1e6
+	histogramForRow := func(n int) []byte {
+		if n%10 == 0 {
+			// Time series may contain float samples among histograms.
+			return nil
+		}
+		return []byte(fmt.Sprintf("histogram_%d", n))
+	}
+	addRows := func(metricGroup string, offset int, withHistograms bool) {
+		t.Helper()
+		mn := MetricName{
+			MetricGroup: []byte(metricGroup),
+		}
+		metricNameRaw := mn.marshalRaw(nil)
+		var mrs []MetricRow
+		for i := 0; i < rowsPerBatch; i++ {
+			n := offset + i
+			mr := MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     minTimestamp + int64(n)*2,
+				Value:         float64(n),
+			}
+			if withHistograms {
+				mr.Histogram = histogramForRow(n)
+			} else {
+				// Use distinct timestamps for float samples, so the order of merged samples is deterministic.
+				mr.Timestamp++
+			}
+			mrs = append(mrs, mr)
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("cannot add rows: %s", err)
+		}
+		s.DebugFlush()
+	}
+	// Add overlapping batches in order to verify merging of blocks with histograms and blocks with float samples.
+	addRows("request_duration", 0, true)
+	addRows("request_duration", rowsPerBatch/2, false)
+	addRows("request_duration", rowsPerBatch, true)
+	addRows("cpu", 0, false)
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+
+	checkRows := func(metricGroup string, histogramsExpected [][]byte) {
+		t.Helper()
+		tfs := NewTagFilters()
+		if err := tfs.Add(nil, []byte(metricGroup), false, false); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		tr := TimeRange{
+			MinTimestamp: minTimestamp,
+			MaxTimestamp: minTimestamp + 4*rowsPerBatch,
+		}
+		var sr Search
+		var b Block
+		var timestamps []int64
+		var values []float64
+		var histograms [][]byte
+		sr.Init(s, []*TagFilters{tfs}, tr, 1e5, noDeadline)
+		for sr.NextMetricBlock() {
+			sr.MetricBlockRef.BlockRef.MustReadBlock(&b, true)
+			if err := b.UnmarshalData(); err != nil {
+				t.Fatalf("cannot unmarshal block: %s", err)
+			}
+			timestamps, values = b.AppendRowsWithTimeRangeFilter(timestamps, values, tr)
+			histograms = b.AppendHistogramsWithTimeRangeFilter(histograms, tr)
+		}
+		if err := sr.Error(); err != nil {
+			t.Fatalf("search error: %s", err)
+		}
+		sr.MustClose()
+		if len(timestamps) != len(histogramsExpected) || len(values) != len(histogramsExpected) {
+			t.Fatalf("unexpected number of rows for %q; got %d; want %d", metricGroup, len(timestamps), len(histogramsExpected))
+		}
+		if !reflect.DeepEqual(histograms, histogramsExpected) {
+			t.Fatalf("unexpected histograms for %q", metricGroup)
+		}
+	}
+
+	var histogramsExpected [][]byte
+	for n := 0; n < 2*rowsPerBatch; n++ {
+		histogramsExpected = append(histogramsExpected, histogramForRow(n))
+		if n >= rowsPerBatch/2 && n < rowsPerBatch+rowsPerBatch/2 {
+			// The float sample from the overlapping batch.
+			histogramsExpected = append(histogramsExpected, nil)
+		}
+	}
+	checkRows("request_duration", histogramsExpected)
+	checkRows("cpu", make([][]byte, rowsPerBatch))
+
+	s.MustClose()
+}
@@ -3,20 +3,35 @@ package storage
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/filestream"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/memory"
 )
 
+var indexBlocksCachePercent = 25.0
+
+// SetIndexBlocksCachePercent sets the limit on the number of cached index blocks per part as a percent of allowed memory.
+//
+// This function must be called before opening the storage.
+func SetIndexBlocksCachePercent(percent float64) {
+	indexBlocksCachePercent = percent
+}
+
 func getMaxCachedIndexBlocksPerPart() int {
 	maxCachedIndexBlocksPerPartOnce.Do(func() {
-		n := memory.Allowed() / 1024 / 1024 / 8
+		// Allow caching up to a block per MiB of the given memory share per part.
+		// The limit is halved for data parts in order to preserve their historical cache size,
+		// which is two times smaller than the cache size for indexdb parts.
+		n := int(float64(memory.Allowed())*indexBlocksCachePercent/100) / 1024 / 1024 / 2
 		if n < 16 {
 			n = 16
 		}
@@ -42,6 +57,11 @@ type part struct {
 	// Total size in bytes of part data.
 	size uint64
 
+	// mergeIdx is the index of the part in the partition. Parts created later have bigger mergeIdx.
+	//
+	// It is used for applying deleted ranges only to parts created before the deletion.
+	mergeIdx uint64
+
 	timestampsFile fs.MustReadAtCloser
 	valuesFile     fs.MustReadAtCloser
 	indexFile      fs.MustReadAtCloser
@@ -61,19 +81,19 @@ func openFilePart(path string) (*part, error) {
 	}
 
 	timestampsPath := path + "/timestamps.bin"
-	timestampsFile := fs.MustOpenReaderAt(timestampsPath)
+	timestampsFile := encryption.MustOpenReaderAt(timestampsPath)
 	timestampsSize := fs.MustFileSize(timestampsPath)
 
 	valuesPath := path + "/values.bin"
-	valuesFile := fs.MustOpenReaderAt(valuesPath)
+	valuesFile := encryption.MustOpenReaderAt(valuesPath)
 	valuesSize := fs.MustFileSize(valuesPath)
 
 	indexPath := path + "/index.bin"
-	indexFile := fs.MustOpenReaderAt(indexPath)
+	indexFile := encryption.MustOpenReaderAt(indexPath)
 	indexSize := fs.MustFileSize(indexPath)
 
 	metaindexPath := path + "/metaindex.bin"
-	metaindexFile, err := filestream.Open(metaindexPath, true)
+	metaindexFile, err := encryption.Open(metaindexPath, true)
 	if err != nil {
 		timestampsFile.MustClose()
 		valuesFile.MustClose()
@@ -83,7 +103,27 @@ func openFilePart(path string) (*part, error) {
 	metaindexSize := fs.MustFileSize(metaindexPath)
 
 	size := timestampsSize + valuesSize + indexSize + metaindexSize
-	return newPart(&ph, path, size, metaindexFile, timestampsFile, valuesFile, indexFile)
+	p, err := newPart(&ph, path, size, metaindexFile, timestampsFile, valuesFile, indexFile)
+	if err != nil {
+		return nil, err
+	}
+	p.mergeIdx = parsePartMergeIdx(path)
+	return p, nil
+}
+
+// parsePartMergeIdx returns mergeIdx from the hex suffix of the given part path.
+//
+// Zero is returned for parts with unexpected suffix, so all the deleted ranges are applied to them.
+func parsePartMergeIdx(path string) uint64 {
+	n := strings.LastIndexByte(path, '_')
+	if n < 0 {
+		return 0
+	}
+	mergeIdx, err := strconv.ParseUint(path[n+1:], 16, 64)
+	if err != nil {
+		return 0
+	}
+	return mergeIdx
 }
 
 // newPart returns new part initialized with the given arguments.
@@ -103,7 +103,40 @@ const rawRowsFlushInterval = time.Second
 
 // The interval for flushing inmemory parts to persistent storage,
 // so they survive process crash.
-const inmemoryPartsFlushInterval = 5 * time.Second
+var inmemoryPartsFlushInterval = 5 * time.Second
+
+// SetInmemoryDataFlushInterval sets the interval for flushing inmemory parts to persistent storage.
+//
+// Bigger intervals reduce write amplification, since inmemory parts are merged in memory before being written to disk,
+// at the cost of bigger data loss on unclean shutdown. The minimum supported interval is 1s.
+//
+// This function must be called before initializing the storage.
+func SetInmemoryDataFlushInterval(d time.Duration) {
+	if d < time.Second {
+		d = time.Second
+	}
+	inmemoryPartsFlushInterval = d
+}
+
+// SetInmemoryDataMaxSize sets the maximum size of inmemory parts per partition.
+//
+// Inmemory parts are flushed to persistent storage when their size exceeds maxSize
+// without waiting for the interval set via SetInmemoryDataFlushInterval.
+// The limit is automatically calculated from the allowed memory if maxSize is 0.
+//
+// This function must be called before initializing the storage.
+func SetInmemoryDataMaxSize(maxSize int64) {
+	inmemoryDataMaxSize = maxSize
+}
+
+var inmemoryDataMaxSize int64
+
+func getInmemoryDataMaxSize() uint64 {
+	if inmemoryDataMaxSize > 0 {
+		return uint64(inmemoryDataMaxSize)
+	}
+	return uint64(memory.Allowed()) / 16
+}
 
 // partition represents a partition.
 type partition struct {
@@ -121,6 +154,10 @@ type partition struct {
 
 	smallAssistedMerges uint64
 
+	inmemoryMergesCount uint64
+	inmemoryRowsMerged  uint64
+	inmemoryRowsDeleted uint64
+
 	smallMergeNeedFreeDiskSpace uint64
 	bigMergeNeedFreeDiskSpace   uint64
 
@@ -132,11 +169,19 @@ type partition struct {
 	// The callack that returns deleted metric ids which must be skipped during merge.
 	getDeletedMetricIDs func() *uint64set.Set
 
+	// The callback that returns metricIDs matching -retentionFilter.
+	// It may return nil if there are no retention filters.
+	getRetentionFilterMetricIDs func() *retentionFilterMetricIDs
+
+	// The callback that returns time ranges with deleted samples, which must be removed during merge.
+	// It may be nil.
+	getDeletedRanges func() *deletedRanges
+
 	// data retention in milliseconds.
 	// Used for deleting data outside the retention during background merge.
 	retentionMsecs int64
 
-	// Name is the name of the partition in the form YYYY_MM.
+	// Name is the name of the partition in the form YYYY_MM or YYYY_MM_DD.
 	name string
 
 	// The time range for the partition. Usually this is a whole month.
@@ -207,10 +252,13 @@ func (pw *partWrapper) decRef() {
 	pw.p = nil
 }
 
-// createPartition creates new partition for the given timestamp and the given paths
+// createPartition creates new partition with the given name and the given paths
 // to small and big partitions.
-func createPartition(timestamp int64, smallPartitionsPath, bigPartitionsPath string, getDeletedMetricIDs func() *uint64set.Set, retentionMsecs int64) (*partition, error) {
-	name := timestampToPartitionName(timestamp)
+func createPartition(name string, smallPartitionsPath, bigPartitionsPath string, getDeletedMetricIDs func() *uint64set.Set, getRetentionFilterMetricIDs func() *retentionFilterMetricIDs, getDeletedRanges func() *deletedRanges, retentionMsecs int64) (*partition, error) {
+	var tr TimeRange
+	if err := tr.fromPartitionName(name); err != nil {
+		return nil, err
+	}
 	smallPartsPath := filepath.Clean(smallPartitionsPath) + "/" + name
 	bigPartsPath := filepath.Clean(bigPartitionsPath) + "/" + name
 	logger.Infof("creating a partition %q with smallPartsPath=%q, bigPartsPath=%q", name, smallPartsPath, bigPartsPath)
@@ -222,8 +270,8 @@ func createPartition(timestamp int64, smallPartitionsPath, bigPartitionsPath str
 		return nil, fmt.Errorf("cannot create directories for big parts %q: %w", bigPartsPath, err)
 	}
 
-	pt := newPartition(name, smallPartsPath, bigPartsPath, getDeletedMetricIDs, retentionMsecs)
-	pt.tr.fromPartitionTimestamp(timestamp)
+	pt := newPartition(name, smallPartsPath, bigPartsPath, getDeletedMetricIDs, getRetentionFilterMetricIDs, getDeletedRanges, retentionMsecs)
+	pt.tr = tr
 	pt.startMergeWorkers()
 	pt.startRawRowsFlusher()
 	pt.startInmemoryPartsFlusher()
@@ -248,13 +296,13 @@ func (pt *partition) Drop() {
 }
 
 // openPartition opens the existing partition from the given paths.
-func openPartition(smallPartsPath, bigPartsPath string, getDeletedMetricIDs func() *uint64set.Set, retentionMsecs int64) (*partition, error) {
+func openPartition(smallPartsPath, bigPartsPath string, getDeletedMetricIDs func() *uint64set.Set, getRetentionFilterMetricIDs func() *retentionFilterMetricIDs, getDeletedRanges func() *deletedRanges, retentionMsecs int64) (*partition, error) {
 	smallPartsPath = filepath.Clean(smallPartsPath)
 	bigPartsPath = filepath.Clean(bigPartsPath)
 
 	n := strings.LastIndexByte(smallPartsPath, '/')
 	if n < 0 {
-		return nil, fmt.Errorf("cannot find partition name from smallPartsPath %q; must be in the form /path/to/smallparts/YYYY_MM", smallPartsPath)
+		return nil, fmt.Errorf("cannot find partition name from smallPartsPath %q; must be in the form /path/to/smallparts/YYYY_MM or /path/to/smallparts/YYYY_MM_DD", smallPartsPath)
 	}
 	name := smallPartsPath[n+1:]
 
@@ -272,7 +320,7 @@ func openPartition(smallPartsPath, bigPartsPath string, getDeletedMetricIDs func
 		return nil, fmt.Errorf("cannot open big parts from %q: %w", bigPartsPath, err)
 	}
 
-	pt := newPartition(name, smallPartsPath, bigPartsPath, getDeletedMetricIDs, retentionMsecs)
+	pt := newPartition(name, smallPartsPath, bigPartsPath, getDeletedMetricIDs, getRetentionFilterMetricIDs, getDeletedRanges, retentionMsecs)
 	pt.smallParts = smallParts
 	pt.bigParts = bigParts
 	if err := pt.tr.fromPartitionName(name); err != nil {
@@ -286,14 +334,16 @@ func openPartition(smallPartsPath, bigPartsPath string, getDeletedMetricIDs func
 	return pt, nil
 }
 
-func newPartition(name, smallPartsPath, bigPartsPath string, getDeletedMetricIDs func() *uint64set.Set, retentionMsecs int64) *partition {
+func newPartition(name, smallPartsPath, bigPartsPath string, getDeletedMetricIDs func() *uint64set.Set, getRetentionFilterMetricIDs func() *retentionFilterMetricIDs, getDeletedRanges func() *deletedRanges, retentionMsecs int64) *partition {
 	p := &partition{
 		name:           name,
 		smallPartsPath: smallPartsPath,
 		bigPartsPath:   bigPartsPath,
 
-		getDeletedMetricIDs: getDeletedMetricIDs,
-		retentionMsecs:      retentionMsecs,
+		getDeletedMetricIDs:         getDeletedMetricIDs,
+		getRetentionFilterMetricIDs: getRetentionFilterMetricIDs,
+		getDeletedRanges:            getDeletedRanges,
+		retentionMsecs:              retentionMsecs,
 
 		mergeIdx: uint64(time.Now().UnixNano()),
 		stopCh:   make(chan struct{}),
@@ -345,6 +395,13 @@ type partitionMetrics struct {
 
 	SmallAssistedMerges uint64
 
+	InmemorySizeBytes   uint64
+	InmemoryRowsCount   uint64
+	InmemoryPartsCount  uint64
+	InmemoryMergesCount uint64
+	InmemoryRowsMerged  uint64
+	InmemoryRowsDeleted uint64
+
 	SmallMergeNeedFreeDiskSpace uint64
 	BigMergeNeedFreeDiskSpace   uint64
 }
@@ -381,6 +438,11 @@ func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 		m.SmallBlocksCount += p.ph.BlocksCount
 		m.SmallSizeBytes += p.size
 		m.SmallPartsRefCount += atomic.LoadUint64(&pw.refCount)
+		if pw.mp != nil {
+			m.InmemorySizeBytes += p.size
+			m.InmemoryRowsCount += p.ph.RowsCount
+			m.InmemoryPartsCount++
+		}
 	}
 
 	m.BigPartsCount += uint64(len(pt.bigParts))
@@ -408,6 +470,10 @@ func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 
 	m.SmallAssistedMerges += atomic.LoadUint64(&pt.smallAssistedMerges)
 
+	m.InmemoryMergesCount += atomic.LoadUint64(&pt.inmemoryMergesCount)
+	m.InmemoryRowsMerged += atomic.LoadUint64(&pt.inmemoryRowsMerged)
+	m.InmemoryRowsDeleted += atomic.LoadUint64(&pt.inmemoryRowsDeleted)
+
 	m.SmallMergeNeedFreeDiskSpace += atomic.LoadUint64(&pt.smallMergeNeedFreeDiskSpace)
 	m.BigMergeNeedFreeDiskSpace += atomic.LoadUint64(&pt.bigMergeNeedFreeDiskSpace)
 }
@@ -545,6 +611,7 @@ func (pt *partition) addRowsPart(rows []rawRow) {
 	if err != nil {
 		logger.Panicf("BUG: cannot create part from %q: %s", &mp.ph, err)
 	}
+	p.mergeIdx = pt.nextMergeIdx()
 
 	pw := &partWrapper{
 		p:        p,
@@ -556,6 +623,8 @@ func (pt *partition) addRowsPart(rows []rawRow) {
 	pt.smallParts = append(pt.smallParts, pw)
 	ok := len(pt.smallParts) <= maxSmallPartsPerPartition
 	pt.partsLock.Unlock()
+
+	pt.mergeInmemoryPartsIfNeeded()
 	if ok {
 		return
 	}
@@ -748,7 +817,9 @@ func (pt *partition) startInmemoryPartsFlusher() {
 }
 
 func (pt *partition) inmemoryPartsFlusher() {
-	ticker := time.NewTicker(inmemoryPartsFlushInterval)
+	// Check inmemory parts every second, so they are flushed to disk
+	// not later than a second after inmemoryPartsFlushInterval.
+	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 	var pwsBuf []*partWrapper
 	var err error
@@ -773,12 +844,23 @@ func (pt *partition) flushInmemoryParts(dstPws []*partWrapper, force bool) ([]*p
 	}
 
 	// Inmemory parts may present only in small parts.
+	// Flush all the inmemory parts at once if at least a single part must be flushed,
+	// so they are merged into a single file-based part.
+	needFlush := force
 	pt.partsLock.Lock()
 	for _, pw := range pt.smallParts {
 		if pw.mp == nil || pw.isInMerge {
 			continue
 		}
-		if force || currentTime-pw.mp.creationTime >= uint64(flushSeconds) {
+		if currentTime-pw.mp.creationTime >= uint64(flushSeconds) {
+			needFlush = true
+		}
+	}
+	if needFlush {
+		for _, pw := range pt.smallParts {
+			if pw.mp == nil || pw.isInMerge {
+				continue
+			}
 			pw.isInMerge = true
 			dstPws = append(dstPws, pw)
 		}
@@ -791,6 +873,122 @@ func (pt *partition) flushInmemoryParts(dstPws []*partWrapper, force bool) ([]*p
 	return dstPws, nil
 }
 
+// mergeInmemoryPartsIfNeeded merges inmemory parts into bigger inmemory part if there are many inmemory parts with similar sizes.
+//
+// Inmemory parts are flushed to disk if their summary size exceeds getInmemoryDataMaxSize().
+func (pt *partition) mergeInmemoryPartsIfNeeded() {
+	maxSize := getInmemoryDataMaxSize()
+	var pws []*partWrapper
+	size := uint64(0)
+	pt.partsLock.Lock()
+	for _, pw := range pt.smallParts {
+		if pw.mp == nil {
+			continue
+		}
+		size += pw.p.size
+		if !pw.isInMerge {
+			pws = append(pws, pw)
+		}
+	}
+	if size > maxSize {
+		for _, pw := range pws {
+			pw.isInMerge = true
+		}
+		pt.partsLock.Unlock()
+		if err := pt.mergePartsOptimal(pws, nil); err != nil {
+			logger.Panicf("FATAL: cannot flush %d inmemory parts: %s", len(pws), err)
+		}
+		return
+	}
+	pms, _ := appendPartsToMerge(nil, pws, defaultPartsToMerge, maxSize)
+	for _, pw := range pms {
+		pw.isInMerge = true
+	}
+	pt.partsLock.Unlock()
+	if len(pms) == 0 {
+		return
+	}
+	pt.mergeInmemoryParts(pms)
+}
+
+// mergeInmemoryParts merges inmemory pws into a single inmemory part.
+//
+// All the parts inside pws must have isInMerge field set to true.
+func (pt *partition) mergeInmemoryParts(pws []*partWrapper) {
+	defer pt.releasePartsToMerge(pws)
+
+	bsrs := make([]*blockStreamReader, 0, len(pws))
+	// The merged part must be flushed to disk when the oldest source part must be flushed.
+	creationTime := uint64(1<<64 - 1)
+	for _, pw := range pws {
+		bsr := getBlockStreamReader()
+		bsr.InitFromInmemoryPart(pw.mp)
+		bsrs = append(bsrs, bsr)
+		if pw.mp.creationTime < creationTime {
+			creationTime = pw.mp.creationTime
+		}
+	}
+	mp := getInmemoryPart()
+	bsw := getBlockStreamWriter()
+	bsw.InitFromInmemoryPart(mp)
+
+	// mergeIdx must be obtained before reading deleted ranges, so ranges added during the merge are applied to the merged part.
+	mergeIdx := pt.nextMergeIdx()
+	dmis := pt.getDeletedMetricIDs()
+	var rfm *retentionFilterMetricIDs
+	if pt.getRetentionFilterMetricIDs != nil {
+		rfm = pt.getRetentionFilterMetricIDs()
+	}
+	pt.initDeletedRanges(bsrs, pws)
+	now := timestampFromTime(time.Now())
+	retentionDeadline := now - pt.retentionMsecs
+	atomic.AddUint64(&pt.inmemoryMergesCount, 1)
+	err := mergeBlockStreams(&mp.ph, bsw, bsrs, nil, dmis, rfm, now, retentionDeadline, &pt.inmemoryRowsMerged, &pt.inmemoryRowsDeleted)
+	putBlockStreamWriter(bsw)
+	for _, bsr := range bsrs {
+		putBlockStreamReader(bsr)
+	}
+	if err != nil {
+		logger.Panicf("FATAL: cannot merge %d inmemory parts: %s", len(pws), err)
+	}
+	mp.creationTime = creationTime
+
+	var newPW *partWrapper
+	if mp.ph.RowsCount > 0 {
+		p, err := mp.NewPart()
+		if err != nil {
+			logger.Panicf("BUG: cannot create part from %q: %s", &mp.ph, err)
+		}
+		p.mergeIdx = mergeIdx
+		newPW = &partWrapper{
+			p:        p,
+			mp:       mp,
+			refCount: 1,
+		}
+	} else {
+		// All the rows have been deleted during the merge.
+		putInmemoryPart(mp)
+	}
+
+	m := make(map[*partWrapper]bool, len(pws))
+	for _, pw := range pws {
+		m[pw] = true
+	}
+	pt.partsLock.Lock()
+	var removedParts int
+	pt.smallParts, removedParts = removeParts(pt.smallParts, m, false)
+	if newPW != nil {
+		pt.smallParts = append(pt.smallParts, newPW)
+	}
+	pt.partsLock.Unlock()
+	if removedParts != len(m) {
+		logger.Panicf("BUG: unexpected number of inmemory parts removed; got %d, want %d", removedParts, len(m))
+	}
+	for _, pw := range pws {
+		pw.decRef()
+	}
+}
+
 func (pt *partition) mergePartsOptimal(pws []*partWrapper, stopCh <-chan struct{}) error {
 	defer func() {
 		// Remove isInMerge flag from pws.
@@ -817,25 +1015,58 @@ func (pt *partition) mergePartsOptimal(pws []*partWrapper, stopCh <-chan struct{
 	return nil
 }
 
-// ForceMergeAllParts runs merge for all the parts in pt - small and big.
+// ForceMergeAllParts merges all the parts in pt - small and big - into a single part.
+//
+// It waits until background merges for pt parts are finished before starting the merge.
+// pt may contain multiple parts after the call if new data is ingested into it in the mean time.
 func (pt *partition) ForceMergeAllParts() error {
-	var pws []*partWrapper
-	pt.partsLock.Lock()
-	if !hasActiveMerges(pt.smallParts) && !hasActiveMerges(pt.bigParts) {
-		pws = appendAllPartsToMerge(pws, pt.smallParts)
-		pws = appendAllPartsToMerge(pws, pt.bigParts)
+	prevPartsCount := 0
+	for {
+		pws := pt.getAllPartsForForceMerge()
+		if len(pws) == 0 {
+			// Nothing to merge or pt is stopped.
+			return nil
+		}
+		if prevPartsCount > 0 && (len(pws) == 1 || len(pws) >= prevPartsCount) {
+			// All the parts have been merged into a single part or new parts are created
+			// faster than they are merged because of data ingestion.
+			pt.releasePartsToMerge(pws)
+			return nil
+		}
+		prevPartsCount = len(pws)
+		// If len(pws) == 1, then the merge must run anyway, so deleted time series could be removed from the part.
+		if err := pt.mergePartsOptimal(pws, pt.stopCh); err != nil {
+			return fmt.Errorf("cannot force merge %d parts from partition %q: %w", len(pws), pt.name, err)
+		}
 	}
-	pt.partsLock.Unlock()
+}
 
-	if len(pws) == 0 {
-		// Nothing to merge.
-		return nil
-	}
-	// If len(pws) == 1, then the merge must run anyway, so deleted time series could be removed from the part.
-	if err := pt.mergePartsOptimal(pws, pt.stopCh); err != nil {
-		return fmt.Errorf("cannot force merge %d parts from partition %q: %w", len(pws), pt.name, err)
+// getAllPartsForForceMerge returns all the parts from pt after waiting for active merges.
+//
+// The returned parts are marked with isInMerge flag.
+// nil is returned if pt has no parts or if pt is stopped.
+func (pt *partition) getAllPartsForForceMerge() []*partWrapper {
+	for {
+		var pws []*partWrapper
+		pt.partsLock.Lock()
+		hasMerges := hasActiveMerges(pt.smallParts) || hasActiveMerges(pt.bigParts)
+		if !hasMerges {
+			pws = appendAllPartsToMerge(pws, pt.smallParts)
+			pws = appendAllPartsToMerge(pws, pt.bigParts)
+		}
+		pt.partsLock.Unlock()
+		if !hasMerges {
+			return pws
+		}
+
+		t := time.NewTimer(time.Second)
+		select {
+		case <-pt.stopCh:
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
 	}
-	return nil
 }
 
 func appendAllPartsToMerge(dst, src []*partWrapper) []*partWrapper {
@@ -987,6 +1218,10 @@ func getMaxOutBytes(path string, workersCount int) uint64 {
 }
 
 func (pt *partition) mergeBigParts(isFinal bool) error {
+	if !bigMergeWindow.contains(time.Now()) {
+		// Postpone merging big parts until the window configured via SetBigMergeWindow.
+		return errNothingToMerge
+	}
 	maxOutBytes := getMaxOutBytes(pt.bigPartsPath, bigMergeWorkersCount)
 
 	pt.partsLock.Lock()
@@ -1001,7 +1236,7 @@ func (pt *partition) mergeSmallParts(isFinal bool) error {
 	// Try merging small parts to a big part at first.
 	maxBigPartOutBytes := getMaxOutBytes(pt.bigPartsPath, bigMergeWorkersCount)
 	pt.partsLock.Lock()
-	pws, needFreeSpace := getPartsToMerge(pt.smallParts, maxBigPartOutBytes, isFinal)
+	pws, needFreeSpace := getPartsToMerge(getFileParts(pt.smallParts), maxBigPartOutBytes, isFinal)
 	pt.partsLock.Unlock()
 	atomicSetBool(&pt.bigMergeNeedFreeDiskSpace, needFreeSpace)
 
@@ -1021,7 +1256,7 @@ func (pt *partition) mergeSmallParts(isFinal bool) error {
 	// The output small part doesn't fit small parts storage. Try merging small parts according to maxSmallPartOutBytes limit.
 	pt.releasePartsToMerge(pws)
 	pt.partsLock.Lock()
-	pws, needFreeSpace = getPartsToMerge(pt.smallParts, maxSmallPartOutBytes, isFinal)
+	pws, needFreeSpace = getPartsToMerge(getFileParts(pt.smallParts), maxSmallPartOutBytes, isFinal)
 	pt.partsLock.Unlock()
 	atomicSetBool(&pt.smallMergeNeedFreeDiskSpace, needFreeSpace)
 
@@ -1106,6 +1341,9 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}) erro
 	if err := bsw.InitFromFilePart(tmpPartPath, nocache, compressLevel); err != nil {
 		return fmt.Errorf("cannot create destination part %q: %w", tmpPartPath, err)
 	}
+	if isBigPart {
+		bsw.bandwidthLimiter = &bigMergeBandwidthLimiter
+	}
 
 	// Merge parts.
 	dmis := pt.getDeletedMetricIDs()
@@ -1121,8 +1359,14 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}) erro
 		atomic.AddUint64(&pt.smallMergesCount, 1)
 		atomic.AddUint64(&pt.activeSmallMerges, 1)
 	}
-	retentionDeadline := timestampFromTime(startTime) - pt.retentionMsecs
-	err := mergeBlockStreams(&ph, bsw, bsrs, stopCh, dmis, retentionDeadline, rowsMerged, rowsDeleted)
+	var rfm *retentionFilterMetricIDs
+	if pt.getRetentionFilterMetricIDs != nil {
+		rfm = pt.getRetentionFilterMetricIDs()
+	}
+	pt.initDeletedRanges(bsrs, pws)
+	now := timestampFromTime(startTime)
+	retentionDeadline := now - pt.retentionMsecs
+	err := mergeBlockStreams(&ph, bsw, bsrs, stopCh, dmis, rfm, now, retentionDeadline, rowsMerged, rowsDeleted)
 	if isBigPart {
 		atomic.AddUint64(&pt.activeBigMerges, ^uint64(0))
 	} else {
@@ -1238,6 +1482,17 @@ func getCompressLevelForRowsCount(rowsCount, blocksCount uint64) int {
 	return 5
 }
 
+// initDeletedRanges sets deleted ranges for bsrs created from the corresponding source parts pws.
+func (pt *partition) initDeletedRanges(bsrs []*blockStreamReader, pws []*partWrapper) {
+	if pt.getDeletedRanges == nil {
+		return
+	}
+	drs := pt.getDeletedRanges()
+	for i, pw := range pws {
+		bsrs[i].drs = drs.forPart(pw.p)
+	}
+}
+
 func (pt *partition) nextMergeIdx() uint64 {
 	return atomic.AddUint64(&pt.mergeIdx, 1)
 }
@@ -1341,6 +1596,20 @@ func (pt *partition) removeStaleParts() {
 //
 // The summary size of the returned parts must be smaller than maxOutBytes.
 // The function returns true if pws contains parts, which cannot be merged because of maxOutBytes limit.
+// getFileParts returns file-based parts from pws.
+//
+// Inmemory parts are merged in memory via mergeInmemoryPartsIfNeeded and are flushed to disk by inmemoryPartsFlusher,
+// so they shouldn't be written to disk by small merges before inmemoryPartsFlushInterval.
+func getFileParts(pws []*partWrapper) []*partWrapper {
+	dst := make([]*partWrapper, 0, len(pws))
+	for _, pw := range pws {
+		if pw.mp == nil {
+			dst = append(dst, pw)
+		}
+	}
+	return dst
+}
+
 func getPartsToMerge(pws []*partWrapper, maxOutBytes uint64, isFinal bool) ([]*partWrapper, bool) {
 	pwsRemaining := make([]*partWrapper, 0, len(pws))
 	for _, pw := range pws {
@@ -0,0 +1,550 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// Detached partition directory has the following layout:
+//
+//   small/<name> - small parts of the partition
+//   big/<name>   - big parts of the partition
+//   series.bin    - TSIDs and metric names for all the series stored in the partition
+//
+// series.bin allows attaching the partition to another storage with distinct indexdb.
+
+const detachedSeriesFilename = "series.bin"
+
+// DetachPartition detaches the partition with the given name in the form YYYY_MM or YYYY_MM_DD from s
+// and moves it to a new directory under <path>/detached.
+//
+// The partition data becomes invisible to search after the call. Rows for the partition
+// are rejected until the detach is complete.
+//
+// The returned directory can be moved to another storage and attached there via AttachPartition.
+func (s *Storage) DetachPartition(name string) (string, error) {
+	var tr TimeRange
+	if err := tr.fromPartitionName(name); err != nil {
+		return "", err
+	}
+	logger.Infof("detaching partition %q", name)
+	startTime := time.Now()
+	dir := s.newDetachedPartitionDir(name)
+	if err := s.tb.detachPartition(name, dir); err != nil {
+		return "", err
+	}
+	if err := s.writeDetachedSeries(dir, name); err != nil {
+		return "", fmt.Errorf("cannot write series for partition %q detached to %q: %w", name, dir, err)
+	}
+	logger.Infof("detached partition %q to %q in %.3f seconds", name, dir, time.Since(startTime).Seconds())
+	return dir, nil
+}
+
+// ExportPartition exports the partition with the given name in the form YYYY_MM or YYYY_MM_DD to a new directory under <path>/detached.
+//
+// The partition remains available in s. The exported data files are hard links to the partition files, so the export is fast
+// and doesn't occupy additional disk space until the partition is changed by background merges.
+//
+// The returned directory has the same layout as the directory returned from DetachPartition.
+func (s *Storage) ExportPartition(name string) (string, error) {
+	var tr TimeRange
+	if err := tr.fromPartitionName(name); err != nil {
+		return "", err
+	}
+	logger.Infof("exporting partition %q", name)
+	startTime := time.Now()
+	dir := s.newDetachedPartitionDir(name)
+	if err := s.tb.exportPartition(name, dir); err != nil {
+		fs.MustRemoveAll(dir)
+		return "", err
+	}
+	if err := s.writeDetachedSeries(dir, name); err != nil {
+		fs.MustRemoveAll(dir)
+		return "", fmt.Errorf("cannot write series for partition %q exported to %q: %w", name, dir, err)
+	}
+	logger.Infof("exported partition %q to %q in %.3f seconds", name, dir, time.Since(startTime).Seconds())
+	return dir, nil
+}
+
+// AttachPartition attaches the partition from the given dir created by DetachPartition or ExportPartition.
+//
+// The dir must be located on the same filesystem as the storage, since partition parts are moved from dir to the storage.
+// The data is merged with the existing data for the partition if s already contains it.
+// The dir is removed after successful attach.
+func (s *Storage) AttachPartition(dir string) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+	dir = filepath.Clean(dir)
+	name, err := getDetachedPartitionName(dir)
+	if err != nil {
+		return err
+	}
+	var tr TimeRange
+	if err := tr.fromPartitionName(name); err != nil {
+		return err
+	}
+	if minTimestamp, _ := s.tb.getMinMaxTimestamps(); tr.MaxTimestamp < minTimestamp {
+		return fmt.Errorf("cannot attach partition %q from %q, since it is outside the configured retention", name, dir)
+	}
+	logger.Infof("attaching partition %q from %q", name, dir)
+	startTime := time.Now()
+
+	// Register series before attaching the data, so the data becomes searchable immediately after it is attached.
+	seriesCount, err := s.registerDetachedSeries(dir+"/"+detachedSeriesFilename, tr)
+	if err != nil {
+		return fmt.Errorf("cannot register series for partition %q from %q: %w", name, dir, err)
+	}
+	partsCount, err := s.tb.attachPartition(name, dir+"/small/"+name, dir+"/big/"+name)
+	if err != nil {
+		return fmt.Errorf("cannot attach partition %q from %q: %w", name, dir, err)
+	}
+	fs.MustRemoveAll(dir)
+	logger.Infof("attached partition %q with %d parts and %d series from %q in %.3f seconds", name, partsCount, seriesCount, dir, time.Since(startTime).Seconds())
+	return nil
+}
+
+// newDetachedPartitionDir returns a new directory for the detached partition with the given name.
+//
+// The directory is located at the data path with the partition, so partition parts could be moved to it without copying.
+func (s *Storage) newDetachedPartitionDir(name string) string {
+	dp := s.tb.dataPathForPartitionName(name)
+	return fmt.Sprintf("%s/detached/%s_%016X", filepath.Dir(dp.path), name, nextSnapshotIdx())
+}
+
+func getDetachedPartitionName(dir string) (string, error) {
+	fis, err := ioutil.ReadDir(dir + "/small")
+	if err != nil {
+		return "", fmt.Errorf("cannot read detached partition directory: %w", err)
+	}
+	var names []string
+	for _, fi := range fis {
+		if fs.IsDirOrSymlink(fi) {
+			names = append(names, fi.Name())
+		}
+	}
+	if len(names) != 1 {
+		return "", fmt.Errorf("%q must contain a single partition directory; found %q", dir+"/small", names)
+	}
+	if !fs.IsPathExist(dir + "/" + detachedSeriesFilename) {
+		return "", fmt.Errorf("missing %q; the partition must be detached or exported with DetachPartition or ExportPartition", dir+"/"+detachedSeriesFilename)
+	}
+	return names[0], nil
+}
+
+// detachedSeries contains TSID with the time range for its samples in the partition.
+type detachedSeries struct {
+	TSID         TSID
+	MinTimestamp int64
+	MaxTimestamp int64
+}
+
+// writeDetachedSeries writes series for the partition with the given name from dir to dir/series.bin.
+func (s *Storage) writeDetachedSeries(dir, name string) error {
+	m := make(map[uint64]*detachedSeries)
+	if err := collectPartsSeries(m, dir+"/small/"+name); err != nil {
+		return err
+	}
+	if err := collectPartsSeries(m, dir+"/big/"+name); err != nil {
+		return err
+	}
+	dss := make([]*detachedSeries, 0, len(m))
+	for _, ds := range m {
+		dss = append(dss, ds)
+	}
+	sort.Slice(dss, func(i, j int) bool {
+		return dss[i].TSID.Less(&dss[j].TSID)
+	})
+
+	// Metric names are written to file, so it must be encrypted if encryption at rest is enabled.
+	path := dir + "/" + detachedSeriesFilename
+	tmpPath := path + ".tmp"
+	w, err := encryption.Create(tmpPath, true)
+	if err != nil {
+		return err
+	}
+	idb := s.idb()
+	var buf, compressedBuf, metricName []byte
+	missingMetricNames := 0
+	flush := func() error {
+		compressedBuf = encoding.CompressZSTDLevel(compressedBuf[:0], buf, 1)
+		if _, err := w.Write(encoding.MarshalUint64(nil, uint64(len(compressedBuf)))); err != nil {
+			return err
+		}
+		if _, err := w.Write(compressedBuf); err != nil {
+			return err
+		}
+		buf = buf[:0]
+		return nil
+	}
+	for _, ds := range dss {
+		metricName, err = idb.searchMetricNameWithCache(metricName[:0], ds.TSID.MetricID)
+		if err == io.EOF {
+			// The series has been deleted or its metric name is missing in the indexdb. Skip it.
+			missingMetricNames++
+			continue
+		}
+		if err != nil {
+			w.MustClose()
+			return fmt.Errorf("cannot find metric name for metricID=%d: %w", ds.TSID.MetricID, err)
+		}
+		buf = ds.TSID.Marshal(buf)
+		buf = encoding.MarshalInt64(buf, ds.MinTimestamp)
+		buf = encoding.MarshalInt64(buf, ds.MaxTimestamp)
+		buf = encoding.MarshalBytes(buf, metricName)
+		if len(buf) >= 64*1024 {
+			if err := flush(); err != nil {
+				w.MustClose()
+				return fmt.Errorf("cannot write to %q: %w", tmpPath, err)
+			}
+		}
+	}
+	if len(buf) > 0 {
+		if err := flush(); err != nil {
+			w.MustClose()
+			return fmt.Errorf("cannot write to %q: %w", tmpPath, err)
+		}
+	}
+	w.MustClose()
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("cannot rename %q to %q: %w", tmpPath, path, err)
+	}
+	fs.MustSyncPath(dir)
+	if missingMetricNames > 0 {
+		logger.Warnf("skipped %d series without metric names in indexdb when writing %q; data for these series won't be available after attaching the partition",
+			missingMetricNames, path)
+	}
+	return nil
+}
+
+// collectPartsSeries collects series from all the parts located in partsPath to m.
+func collectPartsSeries(m map[uint64]*detachedSeries, partsPath string) error {
+	fis, err := ioutil.ReadDir(partsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot read directory with parts: %w", err)
+	}
+	for _, fi := range fis {
+		fn := fi.Name()
+		if !fs.IsDirOrSymlink(fi) || fn == "tmp" || fn == "txn" || fn == "snapshots" {
+			continue
+		}
+		partPath := partsPath + "/" + fn
+		p, err := openFilePart(partPath)
+		if err != nil {
+			return fmt.Errorf("cannot open part %q: %w", partPath, err)
+		}
+		err = collectPartSeries(m, p)
+		p.MustClose()
+		if err != nil {
+			return fmt.Errorf("cannot read block headers from part %q: %w", partPath, err)
+		}
+	}
+	return nil
+}
+
+func collectPartSeries(m map[uint64]*detachedSeries, p *part) error {
+	return forEachPartBlockHeader(p, func(bh *blockHeader, indexBytes uint64) {
+		ds := m[bh.TSID.MetricID]
+		if ds == nil {
+			m[bh.TSID.MetricID] = &detachedSeries{
+				TSID:         bh.TSID,
+				MinTimestamp: bh.MinTimestamp,
+				MaxTimestamp: bh.MaxTimestamp,
+			}
+			return
+		}
+		if bh.MinTimestamp < ds.MinTimestamp {
+			ds.MinTimestamp = bh.MinTimestamp
+		}
+		if bh.MaxTimestamp > ds.MaxTimestamp {
+			ds.MaxTimestamp = bh.MaxTimestamp
+		}
+	})
+}
+
+// registerDetachedSeries registers series from the given path in s indexdb.
+//
+// It returns the number of registered series.
+func (s *Storage) registerDetachedSeries(path string, tr TimeRange) (int, error) {
+	r, err := encryption.Open(path, true)
+	if err != nil {
+		return 0, err
+	}
+	defer r.MustClose()
+
+	idb := s.idb()
+	is := idb.getIndexSearch(noDeadline)
+	defer idb.putIndexSearch(is)
+	mn := GetMetricName()
+	defer PutMetricName(mn)
+	var sizeBuf [8]byte
+	var compressedBuf, buf, metricName []byte
+	seriesCount := 0
+	for {
+		if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+			if err == io.EOF {
+				return seriesCount, nil
+			}
+			return seriesCount, fmt.Errorf("cannot read block size from %q: %w", path, err)
+		}
+		compressedBuf = bytesutil.Resize(compressedBuf[:0], int(encoding.UnmarshalUint64(sizeBuf[:])))
+		if _, err := io.ReadFull(r, compressedBuf); err != nil {
+			return seriesCount, fmt.Errorf("cannot read block from %q: %w", path, err)
+		}
+		buf, err = encoding.DecompressZSTD(buf[:0], compressedBuf)
+		if err != nil {
+			return seriesCount, fmt.Errorf("cannot decompress block from %q: %w", path, err)
+		}
+		src := buf
+		for len(src) > 0 {
+			var ds detachedSeries
+			tail, err := ds.TSID.Unmarshal(src)
+			if err != nil {
+				return seriesCount, fmt.Errorf("cannot unmarshal TSID: %w", err)
+			}
+			if len(tail) < 16 {
+				return seriesCount, fmt.Errorf("cannot unmarshal time range for metricID=%d; got %d bytes; want at least 16 bytes", ds.TSID.MetricID, len(tail))
+			}
+			ds.MinTimestamp = encoding.UnmarshalInt64(tail)
+			ds.MaxTimestamp = encoding.UnmarshalInt64(tail[8:])
+			tail, name, err := encoding.UnmarshalBytes(tail[16:])
+			if err != nil {
+				return seriesCount, fmt.Errorf("cannot unmarshal metric name for metricID=%d: %w", ds.TSID.MetricID, err)
+			}
+			src = tail
+			if err := mn.Unmarshal(name); err != nil {
+				return seriesCount, fmt.Errorf("cannot unmarshal metric name for metricID=%d: %w", ds.TSID.MetricID, err)
+			}
+			metricName, err = is.searchMetricName(metricName[:0], ds.TSID.MetricID)
+			switch {
+			case err == io.EOF:
+				// It is OK if s already contains another TSID for the same metric name.
+				// Metric results are merged by metric name after the search.
+				if err := idb.createIndexes(&ds.TSID, mn); err != nil {
+					return seriesCount, fmt.Errorf("cannot create indexes for metricID=%d: %w", ds.TSID.MetricID, err)
+				}
+			case err != nil:
+				return seriesCount, fmt.Errorf("cannot search metric name for metricID=%d: %w", ds.TSID.MetricID, err)
+			case !bytes.Equal(metricName, name):
+				return seriesCount, fmt.Errorf("metricID=%d is already registered for another metric name; got %q; want %q", ds.TSID.MetricID, metricName, name)
+			}
+			if err := s.registerDetachedSeriesDates(is, &ds, mn, tr); err != nil {
+				return seriesCount, err
+			}
+			seriesCount++
+		}
+	}
+}
+
+func (s *Storage) registerDetachedSeriesDates(is *indexSearch, ds *detachedSeries, mn *MetricName, tr TimeRange) error {
+	minTimestamp := ds.MinTimestamp
+	if minTimestamp < tr.MinTimestamp {
+		minTimestamp = tr.MinTimestamp
+	}
+	maxTimestamp := ds.MaxTimestamp
+	if maxTimestamp > tr.MaxTimestamp {
+		maxTimestamp = tr.MaxTimestamp
+	}
+	metricID := ds.TSID.MetricID
+	for date := uint64(minTimestamp) / msecPerDay; date <= uint64(maxTimestamp)/msecPerDay; date++ {
+		if s.dateMetricIDCache.Has(date, metricID) {
+			continue
+		}
+		ok, err := is.hasDateMetricID(date, metricID)
+		if err != nil {
+			return fmt.Errorf("error when locating (date=%d, metricID=%d) in database: %w", date, metricID, err)
+		}
+		if !ok {
+			if err := is.storeDateMetricID(date, metricID, mn); err != nil {
+				return fmt.Errorf("error when storing (date=%d, metricID=%d) in database: %w", date, metricID, err)
+			}
+		}
+		s.dateMetricIDCache.Set(date, metricID)
+	}
+	return nil
+}
+
+// detachPartition detaches the partition with the given name from tb and moves it to dir.
+func (tb *table) detachPartition(name, dir string) error {
+	tb.ptwsLock.Lock()
+	var ptw *partitionWrapper
+	dst := tb.ptws[:0]
+	for _, x := range tb.ptws {
+		if ptw == nil && x.pt.name == name {
+			ptw = x
+			continue
+		}
+		dst = append(dst, x)
+	}
+	tb.ptws = dst
+	if ptw != nil {
+		ptw.detachedCh = make(chan struct{})
+		if tb.detaching == nil {
+			tb.detaching = make(map[string]bool)
+		}
+		tb.detaching[name] = true
+	}
+	tb.ptwsLock.Unlock()
+	if ptw == nil {
+		return fmt.Errorf("cannot find partition %q", name)
+	}
+	defer func() {
+		tb.ptwsLock.Lock()
+		delete(tb.detaching, name)
+		tb.ptwsLock.Unlock()
+	}()
+
+	// Wait until the pending searches and inserts for the partition are finished.
+	// The partition flushes all the in-memory data to disk when it is closed.
+	smallPartsPath := ptw.pt.smallPartsPath
+	bigPartsPath := ptw.pt.bigPartsPath
+	ptw.decRef()
+	<-ptw.detachedCh
+
+	// Wait until all the pending transaction deletions are finished before moving partition directories.
+	pendingTxnDeletionsWG.Wait()
+
+	for _, dirs := range [][2]string{{smallPartsPath, dir + "/small/" + name}, {bigPartsPath, dir + "/big/" + name}} {
+		srcDir, dstDir := dirs[0], dirs[1]
+		if err := fs.MkdirAllIfNotExist(filepath.Dir(dstDir)); err != nil {
+			return fmt.Errorf("cannot create directory for detached partition: %w", err)
+		}
+		if err := os.Rename(srcDir, dstDir); err != nil {
+			return fmt.Errorf("cannot move partition directory %q to %q: %w", srcDir, dstDir, err)
+		}
+		fs.MustSyncPath(filepath.Dir(srcDir))
+		fs.MustSyncPath(filepath.Dir(dstDir))
+	}
+	fs.MustSyncPath(filepath.Dir(dir))
+	return nil
+}
+
+// exportPartition creates a snapshot for the partition with the given name at dir.
+func (tb *table) exportPartition(name, dir string) error {
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+	for _, ptw := range ptws {
+		if ptw.pt.name != name {
+			continue
+		}
+		if err := ptw.pt.CreateSnapshotAt(dir+"/small/"+name, dir+"/big/"+name); err != nil {
+			return fmt.Errorf("cannot create snapshot for partition %q: %w", name, err)
+		}
+		fs.MustSyncPath(filepath.Dir(dir))
+		return nil
+	}
+	return fmt.Errorf("cannot find partition %q", name)
+}
+
+// attachPartition moves parts from smallPartsPath and bigPartsPath to the partition with the given name.
+//
+// The partition is created if it is missing in tb. It returns the number of attached parts.
+func (tb *table) attachPartition(name, smallPartsPath, bigPartsPath string) (int, error) {
+	var tr TimeRange
+	if err := tr.fromPartitionName(name); err != nil {
+		return 0, err
+	}
+	tb.ptwsLock.Lock()
+	if tb.detaching[name] {
+		tb.ptwsLock.Unlock()
+		return 0, fmt.Errorf("cannot attach partition %q, since it is being detached", name)
+	}
+	var ptw *partitionWrapper
+	for _, x := range tb.ptws {
+		if x.pt.name == name {
+			ptw = x
+			break
+		}
+	}
+	if ptw == nil {
+		// Create the partition at the data path containing smallPartsPath, so parts could be moved to it without copying.
+		dp := tb.dataPathForDir(smallPartsPath)
+		pt, err := createPartition(name, dp.smallPartitionsPath, dp.bigPartitionsPath, tb.getDeletedMetricIDs, tb.getRetentionFilterMetricIDs, tb.getDeletedRanges, tb.retentionMsecs)
+		if err != nil {
+			tb.ptwsLock.Unlock()
+			return 0, err
+		}
+		tb.addPartitionNolock(pt)
+		ptw = tb.ptws[len(tb.ptws)-1]
+	}
+	ptw.incRef()
+	tb.ptwsLock.Unlock()
+	defer ptw.decRef()
+
+	return ptw.pt.attachParts(smallPartsPath, bigPartsPath)
+}
+
+// attachParts moves parts from smallPartsPath and bigPartsPath to pt.
+//
+// It returns the number of attached parts.
+func (pt *partition) attachParts(smallPartsPath, bigPartsPath string) (int, error) {
+	// Prevent from concurrent snapshot creation, since it may miss the moved parts.
+	pt.snapshotLock.RLock()
+	defer pt.snapshotLock.RUnlock()
+
+	smallPws, err := pt.moveParts(smallPartsPath, pt.smallPartsPath)
+	var bigPws []*partWrapper
+	if err == nil {
+		bigPws, err = pt.moveParts(bigPartsPath, pt.bigPartsPath)
+	}
+
+	// Register the moved parts even on error, since they are already located in pt directories.
+	pt.partsLock.Lock()
+	pt.smallParts = append(pt.smallParts, smallPws...)
+	pt.bigParts = append(pt.bigParts, bigPws...)
+	pt.partsLock.Unlock()
+
+	return len(smallPws) + len(bigPws), err
+}
+
+func (pt *partition) moveParts(srcDir, dstDir string) ([]*partWrapper, error) {
+	fis, err := ioutil.ReadDir(srcDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read directory with parts: %w", err)
+	}
+	var pws []*partWrapper
+	for _, fi := range fis {
+		fn := fi.Name()
+		if !fs.IsDirOrSymlink(fi) || fn == "tmp" || fn == "txn" || fn == "snapshots" {
+			continue
+		}
+		srcPartPath := srcDir + "/" + fn
+		var ph partHeader
+		if err := ph.ParseFromPath(srcPartPath); err != nil {
+			return pws, fmt.Errorf("unexpected part directory %q: %w", srcPartPath, err)
+		}
+		dstPartPath := ph.Path(dstDir, pt.nextMergeIdx())
+		if err := os.Rename(srcPartPath, dstPartPath); err != nil {
+			return pws, fmt.Errorf("cannot move part %q to %q; make sure the partition is located on the same filesystem as the storage: %w", srcPartPath, dstPartPath, err)
+		}
+		p, err := openFilePart(dstPartPath)
+		if err != nil {
+			return pws, fmt.Errorf("cannot open part %q: %w", dstPartPath, err)
+		}
+		pws = append(pws, &partWrapper{
+			p:        p,
+			refCount: 1,
+		})
+	}
+	fs.MustSyncPath(srcDir)
+	fs.MustSyncPath(dstDir)
+	return pws, nil
+}
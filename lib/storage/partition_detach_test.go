@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStorageDetachAttachPartition(t *testing.T) {
+	const srcPath = "TestStorageDetachAttachPartition_src"
+	const dstPath = "TestStorageDetachAttachPartition_dst"
+	defer func() {
+		_ = os.RemoveAll(srcPath)
+		_ = os.RemoveAll(dstPath)
+	}()
+
+	var trOld TimeRange
+	trOld.fromPartitionTime(time.Now().AddDate(0, -2, 0))
+	ptName := timestampToPartitionName(trOld.MinTimestamp)
+	const rowsPerMetric = 100
+	now := time.Now().UnixNano() / 1e6
+	addRows := func(s *Storage, metricGroup string, minTimestamp int64) {
+		t.Helper()
+		mn := MetricName{
+			MetricGroup: []byte(metricGroup),
+		}
+		metricNameRaw := mn.marshalRaw(nil)
+		var mrs []MetricRow
+		for i := 0; i < rowsPerMetric; i++ {
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     minTimestamp + int64(i)*1000,
+				Value:         float64(i),
+			})
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("cannot add rows: %s", err)
+		}
+		s.DebugFlush()
+	}
+
+	src, err := OpenStorage(srcPath, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	addRows(src, "foo", trOld.MinTimestamp)
+	addRows(src, "bar", trOld.MinTimestamp+24*3600*1000)
+	addRows(src, "foo", now-rowsPerMetric*1000)
+
+	// Export the partition. The partition must remain available in src.
+	exportDir, err := src.ExportPartition(ptName)
+	if err != nil {
+		t.Fatalf("cannot export partition: %s", err)
+	}
+	if err := testCountStorageRows(src, trOld.MinTimestamp, trOld.MaxTimestamp, map[string]int{"foo": rowsPerMetric, "bar": rowsPerMetric}); err != nil {
+		t.Fatalf("unexpected rows after export: %s", err)
+	}
+
+	// Detach the partition. Its data must become invisible in src, while the remaining data must be left untouched.
+	detachDir, err := src.DetachPartition(ptName)
+	if err != nil {
+		t.Fatalf("cannot detach partition: %s", err)
+	}
+	if err := testCountStorageRows(src, trOld.MinTimestamp, now, map[string]int{"foo": rowsPerMetric}); err != nil {
+		t.Fatalf("unexpected rows after detach: %s", err)
+	}
+	if _, err := src.DetachPartition(ptName); err == nil {
+		t.Fatalf("expecting non-nil error when detaching missing partition")
+	}
+	if _, err := src.DetachPartition("foobar"); err == nil {
+		t.Fatalf("expecting non-nil error when detaching partition with invalid name")
+	}
+
+	// Attach the detached partition to another storage with distinct indexdb.
+	dst, err := OpenStorage(dstPath, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	addRows(dst, "foo", trOld.MinTimestamp+2*24*3600*1000)
+	if err := dst.AttachPartition(detachDir); err != nil {
+		t.Fatalf("cannot attach partition: %s", err)
+	}
+	dst.DebugFlush()
+	if err := testCountStorageRows(dst, trOld.MinTimestamp, trOld.MaxTimestamp, map[string]int{"foo": 2 * rowsPerMetric, "bar": rowsPerMetric}); err != nil {
+		t.Fatalf("unexpected rows after attach: %s", err)
+	}
+	if _, err := os.Stat(detachDir); !os.IsNotExist(err) {
+		t.Fatalf("detached partition directory must be removed after attach")
+	}
+	if err := dst.AttachPartition(detachDir); err == nil {
+		t.Fatalf("expecting non-nil error when attaching missing partition")
+	}
+
+	// The attached data must survive restart and merges.
+	dst.MustClose()
+	dst, err = OpenStorage(dstPath, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot re-open storage: %s", err)
+	}
+	if err := dst.ForceMergePartitions(ptName); err != nil {
+		t.Fatalf("cannot force merge partition: %s", err)
+	}
+	if err := testCountStorageRows(dst, trOld.MinTimestamp, trOld.MaxTimestamp, map[string]int{"foo": 2 * rowsPerMetric, "bar": rowsPerMetric}); err != nil {
+		t.Fatalf("unexpected rows after re-opening the storage: %s", err)
+	}
+	dst.MustClose()
+
+	// Attach the exported partition back to src.
+	if err := src.AttachPartition(exportDir); err != nil {
+		t.Fatalf("cannot attach exported partition: %s", err)
+	}
+	src.DebugFlush()
+	if err := testCountStorageRows(src, trOld.MinTimestamp, now, map[string]int{"foo": 2 * rowsPerMetric, "bar": rowsPerMetric}); err != nil {
+		t.Fatalf("unexpected rows after attaching exported partition: %s", err)
+	}
+	src.MustClose()
+}
@@ -168,7 +168,7 @@ func testPartitionSearchEx(t *testing.T, ptt int64, tr TimeRange, partsCount, ma
 
 	// Create partition from rowss and test search on it.
 	retentionMsecs := timestampFromTime(time.Now()) - ptr.MinTimestamp + 3600*1000
-	pt, err := createPartition(ptt, "./small-table", "./big-table", nilGetDeletedMetricIDs, retentionMsecs)
+	pt, err := createPartition(timestampToPartitionName(ptt), "./small-table", "./big-table", nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
 	if err != nil {
 		t.Fatalf("cannot create partition: %s", err)
 	}
@@ -192,7 +192,7 @@ func testPartitionSearchEx(t *testing.T, ptt int64, tr TimeRange, partsCount, ma
 	pt.MustClose()
 
 	// Open the created partition and test search on it.
-	pt, err = openPartition(smallPartsPath, bigPartsPath, nilGetDeletedMetricIDs, retentionMsecs)
+	pt, err = openPartition(smallPartsPath, bigPartsPath, nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
 	if err != nil {
 		t.Fatalf("cannot open partition: %s", err)
 	}
@@ -2,8 +2,10 @@ package storage
 
 import (
 	"math/rand"
+	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestPartitionGetMaxOutBytes(t *testing.T) {
@@ -13,6 +15,112 @@ func TestPartitionGetMaxOutBytes(t *testing.T) {
 	}
 }
 
+func TestPartitionForceMergeAllParts(t *testing.T) {
+	const path = "TestPartitionForceMergeAllParts"
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+	ptt := timestampFromTime(time.Now())
+	pt, err := createPartition(timestampToPartitionName(ptt), path+"/small", path+"/big", nilGetDeletedMetricIDs, nil, nil, maxRetentionMsecs)
+	if err != nil {
+		t.Fatalf("cannot create partition: %s", err)
+	}
+
+	// Create more parts than a single merge can process.
+	rowsCount := 0
+	for i := 0; i < 3*defaultPartsToMerge; i++ {
+		var rows []rawRow
+		for j := 0; j < 100; j++ {
+			rows = append(rows, rawRow{
+				TSID: TSID{
+					MetricID: uint64(j),
+				},
+				Timestamp:     pt.tr.MinTimestamp + int64(i*100+j),
+				Value:         float64(j),
+				PrecisionBits: 64,
+			})
+		}
+		pt.AddRows(rows)
+		pt.flushRawRows(true)
+		rowsCount += len(rows)
+	}
+
+	if err := pt.ForceMergeAllParts(); err != nil {
+		t.Fatalf("cannot force merge parts: %s", err)
+	}
+	var m partitionMetrics
+	pt.UpdateMetrics(&m)
+	if n := m.SmallPartsCount + m.BigPartsCount; n != 1 {
+		t.Fatalf("unexpected number of parts after forced merge; got %d; want 1", n)
+	}
+	if n := m.SmallRowsCount + m.BigRowsCount; n != uint64(rowsCount) {
+		t.Fatalf("unexpected number of rows after forced merge; got %d; want %d", n, rowsCount)
+	}
+	pt.MustClose()
+}
+
+func TestPartitionInmemoryParts(t *testing.T) {
+	const path = "TestPartitionInmemoryParts"
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+	defer SetInmemoryDataFlushInterval(5 * time.Second)
+	SetInmemoryDataFlushInterval(time.Hour)
+	ptt := timestampFromTime(time.Now())
+	pt, err := createPartition(timestampToPartitionName(ptt), path+"/small", path+"/big", nilGetDeletedMetricIDs, nil, nil, maxRetentionMsecs)
+	if err != nil {
+		t.Fatalf("cannot create partition: %s", err)
+	}
+
+	rowsCount := 0
+	for i := 0; i < 3*defaultPartsToMerge; i++ {
+		var rows []rawRow
+		for j := 0; j < 100; j++ {
+			rows = append(rows, rawRow{
+				TSID: TSID{
+					MetricID: uint64(j),
+				},
+				Timestamp:     pt.tr.MinTimestamp + int64(i*100+j),
+				Value:         float64(j),
+				PrecisionBits: 64,
+			})
+		}
+		pt.AddRows(rows)
+		pt.flushRawRows(true)
+		rowsCount += len(rows)
+	}
+
+	// Inmemory parts must be merged in memory instead of being written to disk.
+	var m partitionMetrics
+	pt.UpdateMetrics(&m)
+	if m.InmemoryMergesCount == 0 {
+		t.Fatalf("expecting non-zero number of inmemory merges")
+	}
+	if m.InmemoryPartsCount >= 3*defaultPartsToMerge {
+		t.Fatalf("too many inmemory parts; got %d; want less than %d", m.InmemoryPartsCount, 3*defaultPartsToMerge)
+	}
+	if m.SmallPartsCount != m.InmemoryPartsCount || m.BigPartsCount != 0 || m.SmallMergesCount != 0 {
+		t.Fatalf("unexpected file-based parts: %+v", m)
+	}
+	if m.InmemoryRowsCount != uint64(rowsCount) {
+		t.Fatalf("unexpected number of inmemory rows; got %d; want %d", m.InmemoryRowsCount, rowsCount)
+	}
+
+	// Inmemory parts must be flushed to disk on the flush.
+	if _, err := pt.flushInmemoryParts(nil, true); err != nil {
+		t.Fatalf("cannot flush inmemory parts: %s", err)
+	}
+	m = partitionMetrics{}
+	pt.UpdateMetrics(&m)
+	if m.InmemoryPartsCount != 0 || m.SmallPartsCount != 1 {
+		t.Fatalf("unexpected parts after the flush; inmemory parts: %d, small parts: %d", m.InmemoryPartsCount, m.SmallPartsCount)
+	}
+	if m.SmallRowsCount != uint64(rowsCount) {
+		t.Fatalf("unexpected number of rows after the flush; got %d; want %d", m.SmallRowsCount, rowsCount)
+	}
+	pt.MustClose()
+}
+
 func TestAppendPartsToMerge(t *testing.T) {
 	testAppendPartsToMerge(t, 2, []uint64{}, nil)
 	testAppendPartsToMerge(t, 2, []uint64{123}, nil)
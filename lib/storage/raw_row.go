@@ -19,6 +19,17 @@ type rawRow struct {
 	// Value is time series value for the given timestamp.
 	Value float64
 
+	// Nanos is the sub-millisecond part of the Timestamp in nanoseconds.
+	Nanos int32
+
+	// NanosecondTimestamp is set to true if the row must be stored with nanosecond timestamp.
+	NanosecondTimestamp bool
+
+	// Histogram is the marshaled native histogram for the row. It is empty for rows with ordinary float values.
+	//
+	// Value contains the number of observations in the histogram.
+	Histogram []byte
+
 	// PrecisionBits is the number of the significant bits in the Value
 	// to store. Possible values are [1..64].
 	// 1 means max. 50% error, 2 - 25%, 3 - 12.5%, 64 means no error, i.e.
@@ -32,6 +43,7 @@ type rawRowsMarshaler struct {
 	auxTimestamps  []int64
 	auxValues      []int64
 	auxFloatValues []float64
+	auxHistograms  [][]byte
 }
 
 func (rrm *rawRowsMarshaler) reset() {
@@ -40,6 +52,14 @@ func (rrm *rawRowsMarshaler) reset() {
 	rrm.auxTimestamps = rrm.auxTimestamps[:0]
 	rrm.auxValues = rrm.auxValues[:0]
 	rrm.auxFloatValues = rrm.auxFloatValues[:0]
+	rrm.resetHistograms()
+}
+
+func (rrm *rawRowsMarshaler) resetHistograms() {
+	for i := range rrm.auxHistograms {
+		rrm.auxHistograms[i] = nil
+	}
+	rrm.auxHistograms = rrm.auxHistograms[:0]
 }
 
 // Use sort.Interface instead of sort.Slice in order to optimize rows swap.
@@ -71,7 +91,10 @@ func (rrs *rawRowsSort) Less(i, j int) bool {
 	if ta.MetricID != tb.MetricID {
 		return ta.MetricID < tb.MetricID
 	}
-	return a.Timestamp < b.Timestamp
+	if a.Timestamp != b.Timestamp {
+		return a.Timestamp < b.Timestamp
+	}
+	return a.Nanos < b.Nanos
 }
 func (rrs *rawRowsSort) Swap(i, j int) {
 	x := *rrs
@@ -103,28 +126,44 @@ func (rrm *rawRowsMarshaler) marshalToInmemoryPart(mp *inmemoryPart, rows []rawR
 	r := &rows[0]
 	tsid := &r.TSID
 	precisionBits := r.PrecisionBits
+	nanosecondTimestamps := r.NanosecondTimestamp
+	hasHistograms := false
 	tmpBlock := getBlock()
 	defer putBlock(tmpBlock)
 	for i := range rows {
 		r = &rows[i]
-		if r.TSID.MetricID == tsid.MetricID && len(rrm.auxTimestamps) < maxRowsPerBlock {
-			rrm.auxTimestamps = append(rrm.auxTimestamps, r.Timestamp)
+		if r.TSID.MetricID == tsid.MetricID && r.NanosecondTimestamp == nanosecondTimestamps && len(rrm.auxTimestamps) < maxRowsPerBlock {
+			rrm.auxTimestamps = append(rrm.auxTimestamps, r.blockTimestamp())
 			rrm.auxFloatValues = append(rrm.auxFloatValues, r.Value)
+			rrm.auxHistograms = append(rrm.auxHistograms, r.Histogram)
+			hasHistograms = hasHistograms || len(r.Histogram) > 0
 			continue
 		}
 
 		rrm.auxValues, scale = decimal.AppendFloatToDecimal(rrm.auxValues[:0], rrm.auxFloatValues)
 		tmpBlock.Init(tsid, rrm.auxTimestamps, rrm.auxValues, scale, precisionBits)
+		tmpBlock.bh.NanosecondTimestamps = nanosecondTimestamps
+		if hasHistograms {
+			tmpBlock.setHistograms(rrm.auxHistograms)
+		}
 		rrm.bsw.WriteExternalBlock(tmpBlock, ph, &rowsMerged, false)
 
 		tsid = &r.TSID
 		precisionBits = r.PrecisionBits
-		rrm.auxTimestamps = append(rrm.auxTimestamps[:0], r.Timestamp)
+		nanosecondTimestamps = r.NanosecondTimestamp
+		rrm.auxTimestamps = append(rrm.auxTimestamps[:0], r.blockTimestamp())
 		rrm.auxFloatValues = append(rrm.auxFloatValues[:0], r.Value)
+		rrm.resetHistograms()
+		rrm.auxHistograms = append(rrm.auxHistograms, r.Histogram)
+		hasHistograms = len(r.Histogram) > 0
 	}
 
 	rrm.auxValues, scale = decimal.AppendFloatToDecimal(rrm.auxValues[:0], rrm.auxFloatValues)
 	tmpBlock.Init(tsid, rrm.auxTimestamps, rrm.auxValues, scale, precisionBits)
+	tmpBlock.bh.NanosecondTimestamps = nanosecondTimestamps
+	if hasHistograms {
+		tmpBlock.setHistograms(rrm.auxHistograms)
+	}
 	rrm.bsw.WriteExternalBlock(tmpBlock, ph, &rowsMerged, false)
 	if rowsMerged != uint64(len(rows)) {
 		logger.Panicf("BUG: unexpected rowsMerged; got %d; want %d", rowsMerged, len(rows))
@@ -132,6 +171,14 @@ func (rrm *rawRowsMarshaler) marshalToInmemoryPart(mp *inmemoryPart, rows []rawR
 	rrm.bsw.MustClose()
 }
 
+// blockTimestamp returns the timestamp for r in the precision used in the block for r.
+func (r *rawRow) blockTimestamp() int64 {
+	if !r.NanosecondTimestamp {
+		return r.Timestamp
+	}
+	return r.Timestamp*1e6 + int64(r.Nanos)
+}
+
 func getRawRowsMarshaler() *rawRowsMarshaler {
 	v := rrmPool.Get()
 	if v == nil {
@@ -0,0 +1,368 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+// retentionFilter contains retention for time series matching the given series selector.
+type retentionFilter struct {
+	// s is the original filter string. It is used in logs.
+	s string
+
+	tfs            *TagFilters
+	retentionMsecs int64
+}
+
+// retentionFilters contains filters from -retentionFilter in the order of their definition.
+var retentionFilters []*retentionFilter
+
+// SetRetentionFilters sets per-series retention filters from -retentionFilter values.
+//
+// Every value must be in the form `series_selector:retention`, for example, `{env="dev"}:7d`.
+// If a time series matches multiple filters, then the first matching filter is used.
+//
+// This function must be called before initializing the storage.
+func SetRetentionFilters(filters []string) error {
+	rfs, err := parseRetentionFilters(filters)
+	if err != nil {
+		return err
+	}
+	retentionFilters = rfs
+	return nil
+}
+
+func parseRetentionFilters(filters []string) ([]*retentionFilter, error) {
+	var rfs []*retentionFilter
+	for _, s := range filters {
+		// Search for the last ':', since series selector may contain ':' chars.
+		n := strings.LastIndexByte(s, ':')
+		if n < 0 {
+			return nil, fmt.Errorf("missing ':' in %q; expecting <series_selector>:<retention>", s)
+		}
+		tfs, err := parseRetentionFilterSelector(s[:n])
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse series selector in %q: %w", s, err)
+		}
+		retentionMsecs, err := metricsql.PositiveDurationValue(s[n+1:], 0)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse retention in %q: %w", s, err)
+		}
+		if retentionMsecs <= 0 {
+			return nil, fmt.Errorf("retention in %q must be positive", s)
+		}
+		rfs = append(rfs, &retentionFilter{
+			s:              s,
+			tfs:            tfs,
+			retentionMsecs: retentionMsecs,
+		})
+	}
+	return rfs, nil
+}
+
+func parseRetentionFilterSelector(s string) (*TagFilters, error) {
+	expr, err := metricsql.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	me, ok := expr.(*metricsql.MetricExpr)
+	if !ok {
+		return nil, fmt.Errorf("expecting series selector; got %q", expr.AppendString(nil))
+	}
+	if len(me.LabelFilters) == 0 {
+		return nil, fmt.Errorf("series selector cannot be empty")
+	}
+	tfs := NewTagFilters()
+	for _, lf := range me.LabelFilters {
+		key := []byte(lf.Label)
+		if lf.Label == "__name__" {
+			key = nil
+		}
+		if err := tfs.Add(key, []byte(lf.Value), lf.IsNegative, lf.IsRegexp); err != nil {
+			return nil, fmt.Errorf("cannot parse label filter %s: %w", lf.AppendString(nil), err)
+		}
+	}
+	return tfs, nil
+}
+
+// retentionFilterMetricIDs contains metricIDs matching retentionFilters.
+type retentionFilterMetricIDs struct {
+	// metricIDs contains matching metricIDs per each retentionFilters entry.
+	metricIDs []*uint64set.Set
+}
+
+// getRetentionDeadline returns the minimum timestamp for samples of the given metricID, which must be kept.
+//
+// retentionDeadline is returned if metricID doesn't match any retention filter.
+func (rfm *retentionFilterMetricIDs) getRetentionDeadline(metricID uint64, now, retentionDeadline int64) int64 {
+	if rfm == nil {
+		return retentionDeadline
+	}
+	for i, metricIDs := range rfm.metricIDs {
+		if !metricIDs.Has(metricID) {
+			continue
+		}
+		deadline := now - retentionFilters[i].retentionMsecs
+		if deadline > retentionDeadline {
+			// Retention filters cannot increase the retention set via -retentionPeriod.
+			return deadline
+		}
+		return retentionDeadline
+	}
+	return retentionDeadline
+}
+
+// getMaxRetentionFilterDeadline returns the maximum retention deadline across all the retention filters at the given time now.
+//
+// Parts with samples older than the returned deadline may contain samples outside retention filters.
+func getMaxRetentionFilterDeadline(now int64) int64 {
+	deadline := int64(math.MinInt64)
+	for _, rf := range retentionFilters {
+		if d := now - rf.retentionMsecs; d > deadline {
+			deadline = d
+		}
+	}
+	return deadline
+}
+
+// retentionFilterSearch is used for skipping samples outside -retentionFilter during the search,
+// since these samples are physically deleted only during background merges.
+type retentionFilterSearch struct {
+	rfm *retentionFilterMetricIDs
+
+	// now is the search start time in milliseconds.
+	now int64
+}
+
+// getDeadline returns the minimum timestamp for samples of the given metricID, which may be returned from the search.
+func (rfs *retentionFilterSearch) getDeadline(metricID uint64) int64 {
+	if rfs == nil {
+		return math.MinInt64
+	}
+	return rfs.rfm.getRetentionDeadline(metricID, rfs.now, math.MinInt64)
+}
+
+// removeRowsOutsideRetention removes samples with timestamps smaller than deadline from the unmarshaled b
+// and returns the number of removed samples.
+//
+// bh.RowsCount, bh.MinTimestamp and bh.MaxTimestamp are updated for the remaining samples.
+func (b *Block) removeRowsOutsideRetention(deadline int64) int {
+	b.assertUnmarshaled()
+	deadline = b.minTimestampFromMsecs(deadline)
+	timestamps := b.timestamps[b.nextIdx:]
+	n := 0
+	for n < len(timestamps) && timestamps[n] < deadline {
+		n++
+	}
+	if n > 0 {
+		b.timestamps = append(b.timestamps[:b.nextIdx], b.timestamps[b.nextIdx+n:]...)
+		b.values = append(b.values[:b.nextIdx], b.values[b.nextIdx+n:]...)
+		if b.bh.Histograms {
+			b.histograms = append(b.histograms[:b.nextIdx], b.histograms[b.nextIdx+n:]...)
+		}
+	}
+	b.bh.RowsCount = uint32(len(b.timestamps) - b.nextIdx)
+	if b.bh.RowsCount > 0 {
+		b.fixupTimestamps()
+	}
+	return n
+}
+
+// hasBlocksOutsideRetentionFilters returns true if p contains blocks, which are entirely outside the retention configured via -retentionFilter.
+//
+// Such blocks are guaranteed to be dropped when p is merged.
+func (p *part) hasBlocksOutsideRetentionFilters(rfm *retentionFilterMetricIDs, now, retentionDeadline int64) (bool, error) {
+	maxDeadline := getMaxRetentionFilterDeadline(now)
+	if p.ph.MinTimestamp >= maxDeadline || p.ph.MaxTimestamp < retentionDeadline {
+		// Fast path - the part either has no samples outside retention filters
+		// or it is entirely out of -retentionPeriod, so it is removed by removeStaleParts.
+		return false, nil
+	}
+	ps := &partSearch{
+		p: p,
+	}
+	for i := range p.metaindex {
+		mr := &p.metaindex[i]
+		if mr.MinTimestamp >= maxDeadline {
+			continue
+		}
+		ib, err := ps.readIndexBlock(mr)
+		if err != nil {
+			return false, fmt.Errorf("cannot read index block for part %q at offset %d: %w", &p.ph, mr.IndexBlockOffset, err)
+		}
+		for j := range ib.bhs {
+			bh := &ib.bhs[j]
+			if bh.MaxTimestamp < rfm.getRetentionDeadline(bh.TSID.MetricID, now, retentionDeadline) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// mergePartsOutsideRetentionFilters merges parts with blocks outside -retentionFilter, so these blocks are physically deleted.
+//
+// Background merges may never touch such parts in old partitions if no new data is written there.
+func (pt *partition) mergePartsOutsideRetentionFilters(rfm *retentionFilterMetricIDs, stopCh <-chan struct{}) error {
+	now := timestampFromTime(time.Now())
+	retentionDeadline := now - pt.retentionMsecs
+	if pt.tr.MinTimestamp >= getMaxRetentionFilterDeadline(now) {
+		return nil
+	}
+
+	// Inmemory parts are merged frequently, so there is no need in checking them.
+	var candidates []*partWrapper
+	pt.partsLock.Lock()
+	for _, pws := range [][]*partWrapper{pt.smallParts, pt.bigParts} {
+		for _, pw := range pws {
+			if pw.mp == nil && !pw.isInMerge {
+				pw.incRef()
+				candidates = append(candidates, pw)
+			}
+		}
+	}
+	pt.partsLock.Unlock()
+
+	m := make(map[*partWrapper]bool)
+	var err error
+	for _, pw := range candidates {
+		if err == nil {
+			var ok bool
+			ok, err = pw.p.hasBlocksOutsideRetentionFilters(rfm, now, retentionDeadline)
+			if ok {
+				m[pw] = true
+			}
+		}
+		pw.decRef()
+	}
+	if err != nil {
+		return err
+	}
+	if len(m) == 0 {
+		return nil
+	}
+
+	// The parts could be merged or removed while checking them, so mark only the remaining parts for the merge.
+	var pws []*partWrapper
+	pt.partsLock.Lock()
+	for _, src := range [][]*partWrapper{pt.smallParts, pt.bigParts} {
+		for _, pw := range src {
+			if m[pw] && !pw.isInMerge {
+				pw.isInMerge = true
+				pws = append(pws, pw)
+			}
+		}
+	}
+	pt.partsLock.Unlock()
+	if len(pws) == 0 {
+		return nil
+	}
+	logger.Infof("merging %d parts in partition %q in order to delete samples outside -retentionFilter", len(pws), pt.name)
+	if err := pt.mergePartsOptimal(pws, stopCh); err != nil {
+		return fmt.Errorf("cannot merge %d parts with samples outside -retentionFilter in partition %q: %w", len(pws), pt.name, err)
+	}
+	return nil
+}
+
+// mergePartsOutsideRetentionFilters merges parts with blocks outside -retentionFilter in all the partitions of tb.
+func (tb *table) mergePartsOutsideRetentionFilters(rfm *retentionFilterMetricIDs, stopCh <-chan struct{}) error {
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+	for _, ptw := range ptws {
+		if err := ptw.pt.mergePartsOutsideRetentionFilters(rfm, stopCh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) getRetentionFilterMetricIDs() *retentionFilterMetricIDs {
+	return s.retentionFilterMetricIDs.Load().(*retentionFilterMetricIDs)
+}
+
+func (s *Storage) startRetentionFiltersUpdater() {
+	if len(retentionFilters) == 0 {
+		return
+	}
+	s.retentionFiltersUpdaterWG.Add(1)
+	go func() {
+		s.retentionFiltersUpdater()
+		s.retentionFiltersUpdaterWG.Done()
+	}()
+}
+
+var (
+	retentionFilterMetricIDsUpdateInterval = time.Minute
+
+	// retentionFiltersMergeInterval is the interval for merging parts with samples outside -retentionFilter.
+	retentionFiltersMergeInterval = 24 * time.Hour
+)
+
+func (s *Storage) retentionFiltersUpdater() {
+	s.updateRetentionFilterMetricIDs()
+	ticker := time.NewTicker(retentionFilterMetricIDsUpdateInterval)
+	defer ticker.Stop()
+	mergeTicker := time.NewTicker(retentionFiltersMergeInterval)
+	defer mergeTicker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.updateRetentionFilterMetricIDs()
+		case <-mergeTicker.C:
+			if err := s.tb.mergePartsOutsideRetentionFilters(s.getRetentionFilterMetricIDs(), s.stop); err != nil && !errors.Is(err, errForciblyStopped) {
+				logger.Errorf("cannot delete samples outside -retentionFilter: %s", err)
+			}
+		}
+	}
+}
+
+func (s *Storage) updateRetentionFilterMetricIDs() {
+	rfm, err := s.searchRetentionFilterMetricIDs()
+	if err != nil {
+		logger.Errorf("cannot update metricIDs for -retentionFilter: %s", err)
+		return
+	}
+	s.retentionFilterMetricIDs.Store(rfm)
+}
+
+func (s *Storage) searchRetentionFilterMetricIDs() (*retentionFilterMetricIDs, error) {
+	// Search over all the time in order to cover all the stored series.
+	tr := TimeRange{
+		MinTimestamp: 0,
+		MaxTimestamp: (1 << 63) - 1,
+	}
+	idb := s.idb()
+	var rfm retentionFilterMetricIDs
+	for _, rf := range retentionFilters {
+		tfss := []*TagFilters{rf.tfs}
+		metricIDs := &uint64set.Set{}
+		is := idb.getIndexSearch(noDeadline)
+		ids, err := is.searchMetricIDs(tfss, tr, 2e9)
+		idb.putIndexSearch(is)
+		if err != nil {
+			return nil, fmt.Errorf("cannot search metricIDs for -retentionFilter=%q: %w", rf.s, err)
+		}
+		metricIDs.AddMulti(ids)
+		ok := idb.doExtDB(func(extDB *indexDB) {
+			is := extDB.getIndexSearch(noDeadline)
+			var extIDs []uint64
+			extIDs, err = is.searchMetricIDs(tfss, tr, 2e9)
+			extDB.putIndexSearch(is)
+			metricIDs.AddMulti(extIDs)
+		})
+		if ok && err != nil {
+			return nil, fmt.Errorf("cannot search metricIDs for -retentionFilter=%q in the previous indexdb: %w", rf.s, err)
+		}
+		rfm.metricIDs = append(rfm.metricIDs, metricIDs)
+	}
+	return &rfm, nil
+}
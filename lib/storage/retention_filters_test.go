@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+func TestParseRetentionFiltersSuccess(t *testing.T) {
+	f := func(filter, tfsExpected string, retentionMsecsExpected int64) {
+		t.Helper()
+		rfs, err := parseRetentionFilters([]string{filter})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(rfs) != 1 {
+			t.Fatalf("unexpected number of retention filters; got %d; want 1", len(rfs))
+		}
+		rf := rfs[0]
+		if tfs := rf.tfs.String(); tfs != tfsExpected {
+			t.Fatalf("unexpected tag filters; got %s; want %s", tfs, tfsExpected)
+		}
+		if rf.retentionMsecs != retentionMsecsExpected {
+			t.Fatalf("unexpected retention; got %d; want %d", rf.retentionMsecs, retentionMsecsExpected)
+		}
+	}
+	f(`{env="dev"}:7d`, `{env="dev"}`, 7*24*3600*1000)
+	f(`foo:bar{env=~"dev|staging"}:1h`, `{__name__="foo:bar", env=~"dev|staging"}`, 3600*1000)
+	f(`{env="dev",job!="vm"}:30m`, `{env="dev", job!="vm"}`, 30*60*1000)
+}
+
+func TestParseRetentionFiltersFailure(t *testing.T) {
+	f := func(filter string) {
+		t.Helper()
+		if _, err := parseRetentionFilters([]string{filter}); err == nil {
+			t.Fatalf("expecting non-nil error for %q", filter)
+		}
+	}
+	// missing retention
+	f(`{env="dev"}`)
+	// invalid retention
+	f(`{env="dev"}:foo`)
+	f(`{env="dev"}:0s`)
+	// invalid series selector
+	f(`{env="dev":7d`)
+	f(`{}:7d`)
+	f(`rate(foo[5m]):7d`)
+}
+
+func TestRetentionFilterMetricIDsGetRetentionDeadline(t *testing.T) {
+	defer func() {
+		retentionFilters = nil
+	}()
+	rfs, err := parseRetentionFilters([]string{`{env="dev"}:100ms`, `{env=~"dev|staging"}:200ms`, `{env="test"}:10s`})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	retentionFilters = rfs
+
+	var rfm retentionFilterMetricIDs
+	for _, metricIDs := range [][]uint64{{1}, {1, 2}, {3}} {
+		var m uint64set.Set
+		m.AddMulti(metricIDs)
+		rfm.metricIDs = append(rfm.metricIDs, &m)
+	}
+	f := func(rfm *retentionFilterMetricIDs, metricID uint64, deadlineExpected int64) {
+		t.Helper()
+		deadline := rfm.getRetentionDeadline(metricID, 1000, 0)
+		if deadline != deadlineExpected {
+			t.Fatalf("unexpected retention deadline for metricID=%d; got %d; want %d", metricID, deadline, deadlineExpected)
+		}
+	}
+	// the first matching filter must be used
+	f(&rfm, 1, 900)
+	f(&rfm, 2, 800)
+	// retention filters cannot extend -retentionPeriod
+	f(&rfm, 3, 0)
+	// metricID without matching filters
+	f(&rfm, 4, 0)
+	// nil rfm
+	f(nil, 1, 0)
+}
+
+func TestBlockRemoveRowsOutsideRetention(t *testing.T) {
+	f := func(timestamps []int64, deadline int64, timestampsExpected []int64) {
+		t.Helper()
+		var b Block
+		values := make([]int64, len(timestamps))
+		for i := range values {
+			values[i] = int64(i)
+		}
+		b.Init(&TSID{MetricID: 1}, timestamps, values, 0, 64)
+		n := b.removeRowsOutsideRetention(deadline)
+		if n != len(timestamps)-len(timestampsExpected) {
+			t.Fatalf("unexpected number of removed rows; got %d; want %d", n, len(timestamps)-len(timestampsExpected))
+		}
+		if int(b.bh.RowsCount) != len(timestampsExpected) {
+			t.Fatalf("unexpected RowsCount; got %d; want %d", b.bh.RowsCount, len(timestampsExpected))
+		}
+		if len(timestampsExpected) == 0 {
+			if len(b.timestamps) != 0 || len(b.values) != 0 {
+				t.Fatalf("expecting empty block; got timestamps=%d, values=%d", b.timestamps, b.values)
+			}
+			return
+		}
+		if !reflect.DeepEqual(b.timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps; got %d; want %d", b.timestamps, timestampsExpected)
+		}
+		for i, timestamp := range b.timestamps {
+			if timestamps[b.values[i]] != timestamp {
+				t.Fatalf("value at position %d doesn't match timestamp %d", i, timestamp)
+			}
+		}
+		if b.bh.MinTimestamp != timestampsExpected[0] || b.bh.MaxTimestamp != timestampsExpected[len(timestampsExpected)-1] {
+			t.Fatalf("unexpected block time range: [%d..%d]", b.bh.MinTimestamp, b.bh.MaxTimestamp)
+		}
+	}
+	f([]int64{10, 20, 30}, 5, []int64{10, 20, 30})
+	f([]int64{10, 20, 30}, 10, []int64{10, 20, 30})
+	f([]int64{10, 20, 30}, 15, []int64{20, 30})
+	f([]int64{10, 20, 30}, 31, nil)
+}
+
+func TestStorageRetentionFilters(t *testing.T) {
+	path := "TestStorageRetentionFilters"
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	// Add samples for the last 100 seconds with 10 seconds interval.
+	// foo samples are spread across the whole range, while baz samples are older than 50 seconds.
+	const rowsPerMetric = 10
+	now := time.Now().UnixNano() / 1e6
+	minTimestamp := now - 95*1000
+	var mrs []MetricRow
+	for _, metricGroup := range []string{"foo", "bar", "baz"} {
+		mn := MetricName{
+			MetricGroup: []byte(metricGroup),
+		}
+		metricNameRaw := mn.marshalRaw(nil)
+		for i := 0; i < rowsPerMetric; i++ {
+			if metricGroup == "baz" && i >= rowsPerMetric/2 {
+				break
+			}
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     minTimestamp + int64(i)*10*1000,
+				Value:         float64(i),
+			})
+		}
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	s.DebugFlush()
+
+	// Write samples to file parts before the retention filter is set,
+	// so the parts contain samples outside the retention filter.
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+
+	defer func() {
+		retentionFilters = nil
+	}()
+	if err := SetRetentionFilters([]string{`{__name__=~"foo|baz"}:50s`}); err != nil {
+		t.Fatalf("cannot set retention filters: %s", err)
+	}
+	s.updateRetentionFilterMetricIDs()
+
+	// Samples outside the retention filter must become invisible immediately.
+	rowsExpected := map[string]int{
+		"foo": rowsPerMetric / 2,
+		"bar": rowsPerMetric,
+	}
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows before merge: %s", err)
+	}
+
+	// The block for baz is entirely outside the retention filter, so it must be deleted by the merge.
+	if err := s.tb.mergePartsOutsideRetentionFilters(s.getRetentionFilterMetricIDs(), s.stop); err != nil {
+		t.Fatalf("cannot merge parts outside retention filters: %s", err)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	mergesCount := m.TableMetrics.SmallMergesCount + m.TableMetrics.BigMergesCount
+	if rowsDeleted := m.TableMetrics.SmallRowsDeleted + m.TableMetrics.BigRowsDeleted; rowsDeleted != rowsPerMetric/2 {
+		t.Fatalf("unexpected number of deleted rows after merge; got %d; want %d", rowsDeleted, rowsPerMetric/2)
+	}
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows after merge: %s", err)
+	}
+
+	// There is nothing to merge anymore, since the remaining blocks contain samples inside the retention.
+	if err := s.tb.mergePartsOutsideRetentionFilters(s.getRetentionFilterMetricIDs(), s.stop); err != nil {
+		t.Fatalf("cannot merge parts outside retention filters: %s", err)
+	}
+	m = Metrics{}
+	s.UpdateMetrics(&m)
+	if n := m.TableMetrics.SmallMergesCount + m.TableMetrics.BigMergesCount; n != mergesCount {
+		t.Fatalf("unexpected number of merges; got %d; want %d", n, mergesCount)
+	}
+
+	s.MustClose()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
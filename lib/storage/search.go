@@ -18,11 +18,19 @@ import (
 type BlockRef struct {
 	p  *part
 	bh blockHeader
+
+	// drs contains deleted samples, which must be removed from the block in MustReadBlock.
+	drs *deletedRanges
+
+	// rfs contains samples outside -retentionFilter, which must be removed from the block in MustReadBlock.
+	rfs *retentionFilterSearch
 }
 
 func (br *BlockRef) reset() {
 	br.p = nil
 	br.bh = blockHeader{}
+	br.drs = nil
+	br.rfs = nil
 }
 
 func (br *BlockRef) init(p *part, bh *blockHeader) {
@@ -33,6 +41,8 @@ func (br *BlockRef) init(p *part, bh *blockHeader) {
 // Init initializes br from pr and data
 func (br *BlockRef) Init(pr PartRef, data []byte) error {
 	br.p = pr.p
+	br.drs = pr.drs
+	br.rfs = pr.rfs
 	tail, err := br.bh.Unmarshal(data)
 	if err != nil {
 		return err
@@ -56,13 +66,17 @@ func (br *BlockRef) RowsCount() int {
 // PartRef returns PartRef from br.
 func (br *BlockRef) PartRef() PartRef {
 	return PartRef{
-		p: br.p,
+		p:   br.p,
+		drs: br.drs,
+		rfs: br.rfs,
 	}
 }
 
 // PartRef is Part reference.
 type PartRef struct {
-	p *part
+	p   *part
+	drs *deletedRanges
+	rfs *retentionFilterSearch
 }
 
 // MustReadBlock reads block from br to dst.
@@ -80,6 +94,19 @@ func (br *BlockRef) MustReadBlock(dst *Block, fetchData bool) {
 
 	dst.valuesData = bytesutil.Resize(dst.valuesData[:0], int(br.bh.ValuesBlockSize))
 	br.p.valuesFile.MustReadAt(dst.valuesData, int64(br.bh.ValuesBlockOffset))
+
+	hasDeletedRows := br.drs.overlapsBlock(br.bh.TSID.MetricID, br.bh.MinTimestamp, br.bh.MaxTimestamp)
+	retentionDeadline := br.rfs.getDeadline(br.bh.TSID.MetricID)
+	if hasDeletedRows || br.bh.MinTimestamp < retentionDeadline {
+		// Remove deleted samples and samples outside -retentionFilter, which weren't removed by background merge yet.
+		if err := dst.UnmarshalData(); err != nil {
+			logger.Panicf("FATAL: cannot unmarshal block from %q: %s", br.p.path, err)
+		}
+		if hasDeletedRows {
+			dst.removeDeletedRows(br.drs)
+		}
+		dst.removeRowsOutsideRetention(retentionDeadline)
+	}
 }
 
 // MetricBlockRef contains reference to time series block for a single metric.
@@ -100,6 +127,20 @@ type Search struct {
 
 	ts tableSearch
 
+	// drs contains deleted samples, which must be skipped during the search.
+	// It is nil if there are no deleted samples.
+	drs *deletedRanges
+
+	// partDrs caches deleted ranges per each searched part. See deletedRanges.forPart.
+	partDrs map[*part]*deletedRanges
+
+	// rfs is used for skipping samples outside -retentionFilter during the search.
+	// It is nil if there are no retention filters.
+	rfs *retentionFilterSearch
+
+	// tmpBlock is used for checking whether blocks with deleted samples contain the remaining samples.
+	tmpBlock Block
+
 	// tr contains time range used in the serach.
 	tr TimeRange
 
@@ -124,6 +165,10 @@ func (s *Search) reset() {
 
 	s.idb = nil
 	s.ts.reset()
+	s.drs = nil
+	s.partDrs = nil
+	s.rfs = nil
+	s.tmpBlock.Reset()
 	s.tr = TimeRange{}
 	s.tfss = nil
 	s.deadline = 0
@@ -143,6 +188,12 @@ func (s *Search) Init(storage *Storage, tfss []*TagFilters, tr TimeRange, maxMet
 		logger.Panicf("BUG: missing MustClose call before the next call to Init")
 	}
 
+	if tr.MinTimestamp < 0 {
+		// The storage doesn't contain samples with negative timestamps.
+		// Limit the time range in order to prevent from overflow when converting it to dates for per-day index search.
+		tr.MinTimestamp = 0
+	}
+
 	s.reset()
 	s.tr = tr
 	s.tfss = tfss
@@ -156,7 +207,7 @@ func (s *Search) Init(storage *Storage, tfss []*TagFilters, tr TimeRange, maxMet
 	// It is ok to call Init on error from storage.searchTSIDs.
 	// Init must be called before returning because it will fail
 	// on Seach.MustClose otherwise.
-	s.ts.Init(storage.tb, tsids, tr)
+	s.ts.Init(storage.tb, tsids, tr, deadline)
 
 	if err != nil {
 		s.err = err
@@ -164,6 +215,15 @@ func (s *Search) Init(storage *Storage, tfss []*TagFilters, tr TimeRange, maxMet
 	}
 
 	s.idb = storage.idb()
+	if drs := storage.getDeletedRanges(); len(drs.ranges) > 0 {
+		s.drs = drs
+	}
+	if rfm := storage.getRetentionFilterMetricIDs(); len(rfm.metricIDs) > 0 {
+		s.rfs = &retentionFilterSearch{
+			rfm: rfm,
+			now: int64(fasttime.UnixTimestamp()) * 1000,
+		}
+	}
 	return len(tsids)
 }
 
@@ -197,7 +257,19 @@ func (s *Search) NextMetricBlock() bool {
 			}
 		}
 		s.loops++
-		tsid := &s.ts.BlockRef.bh.TSID
+		br := s.ts.BlockRef
+		br.drs = s.getPartDeletedRanges(br.p)
+		br.rfs = s.rfs
+		retentionDeadline := s.rfs.getDeadline(br.bh.TSID.MetricID)
+		if br.bh.MaxTimestamp < retentionDeadline {
+			// Skip blocks outside -retentionFilter.
+			continue
+		}
+		if (br.bh.MinTimestamp < retentionDeadline || br.drs.overlapsBlock(br.bh.TSID.MetricID, br.bh.MinTimestamp, br.bh.MaxTimestamp)) && !s.hasNonDeletedRows(br) {
+			// Skip blocks with all the samples deleted.
+			continue
+		}
+		tsid := &br.bh.TSID
 		if tsid.MetricID != s.prevMetricID {
 			var err error
 			s.MetricBlockRef.MetricName, err = s.idb.searchMetricNameWithCache(s.MetricBlockRef.MetricName[:0], tsid.MetricID)
@@ -224,6 +296,30 @@ func (s *Search) NextMetricBlock() bool {
 	return false
 }
 
+// getPartDeletedRanges returns deleted ranges, which must be applied to samples from p.
+func (s *Search) getPartDeletedRanges(p *part) *deletedRanges {
+	if s.drs == nil {
+		return nil
+	}
+	drs, ok := s.partDrs[p]
+	if !ok {
+		drs = s.drs.forPart(p)
+		if s.partDrs == nil {
+			s.partDrs = make(map[*part]*deletedRanges)
+		}
+		s.partDrs[p] = drs
+	}
+	return drs
+}
+
+func (s *Search) hasNonDeletedRows(br *BlockRef) bool {
+	if br.drs.coversBlock(br.bh.TSID.MetricID, br.bh.MinTimestamp, br.bh.MaxTimestamp) {
+		return false
+	}
+	br.MustReadBlock(&s.tmpBlock, true)
+	return s.tmpBlock.RowsCount() > 0
+}
+
 // SearchQuery is used for sending search queries from vmselect to vmstorage.
 type SearchQuery struct {
 	MinTimestamp int64
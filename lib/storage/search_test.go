@@ -162,6 +162,62 @@ func TestSearch(t *testing.T) {
 	})
 }
 
+func TestSearchNegativeMinTimestamp(t *testing.T) {
+	path := "TestSearchNegativeMinTimestamp"
+	st, err := OpenStorage(path, 100*365*24*3600*1000, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage %q: %s", path, err)
+	}
+	defer func() {
+		st.MustClose()
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("cannot remove storage %q: %s", path, err)
+		}
+	}()
+
+	// Add rows with timestamps close to Unix epoch.
+	const rowsCount = 10
+	mrs := make([]MetricRow, rowsCount)
+	mn := MetricName{
+		MetricGroup: []byte("metric"),
+	}
+	for i := range mrs {
+		mr := &mrs[i]
+		mr.MetricNameRaw = mn.marshalRaw(nil)
+		mr.Timestamp = int64(i) * 1000
+		mr.Value = float64(i)
+	}
+	if err := st.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	st.DebugFlush()
+
+	// The search on time range starting before Unix epoch must return all the rows.
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("metric"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tr := TimeRange{
+		MinTimestamp: -5 * 60 * 1000,
+		MaxTimestamp: rowsCount * 1000,
+	}
+	var s Search
+	s.Init(st, []*TagFilters{tfs}, tr, 1e5, noDeadline)
+	rowsFound := 0
+	for s.NextMetricBlock() {
+		var b Block
+		s.MetricBlockRef.BlockRef.MustReadBlock(&b, true)
+		rowsFound += b.RowsCount()
+	}
+	if err := s.Error(); err != nil {
+		t.Fatalf("search error: %s", err)
+	}
+	s.MustClose()
+	if rowsFound != rowsCount {
+		t.Fatalf("unexpected number of rows found; got %d; want %d", rowsFound, rowsCount)
+	}
+}
+
 func testSearchInternal(st *Storage, tr TimeRange, mrs []MetricRow, accountsCount int) error {
 	var s Search
 	for i := 0; i < 10; i++ {
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bloomfilter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+)
+
+// maxSeriesLimitStatsEntries is the maximum number of metric names tracked by seriesLimiter.
+//
+// This limits memory usage when the number of unique metric names explodes.
+const maxSeriesLimitStatsEntries = 100e3
+
+// seriesLimiter limits the number of unique series during the given interval.
+//
+// It tracks the number of new series and the number of dropped rows per metric name during the current interval,
+// so the metric names, which contribute the most new series, can be determined.
+type seriesLimiter struct {
+	l        *bloomfilter.Limiter
+	interval time.Duration
+
+	rowsDropped uint64
+
+	mu sync.Mutex
+
+	// windowStart is the unix timestamp in seconds for the start of the current interval.
+	windowStart uint64
+
+	// m contains per-metric-name stats for the current interval.
+	m map[string]*seriesLimitEntry
+
+	// skippedNewSeries is the number of new series for metric names,
+	// which couldn't be tracked because of maxSeriesLimitStatsEntries limit.
+	skippedNewSeries uint64
+}
+
+type seriesLimitEntry struct {
+	newSeries   uint64
+	rowsDropped uint64
+}
+
+func newSeriesLimiter(maxSeries int, interval time.Duration) *seriesLimiter {
+	return &seriesLimiter{
+		l:           bloomfilter.NewLimiter(maxSeries, interval),
+		interval:    interval,
+		windowStart: fasttime.UnixTimestamp(),
+		m:           make(map[string]*seriesLimitEntry),
+	}
+}
+
+// MustStop stops sl.
+func (sl *seriesLimiter) MustStop() {
+	sl.l.MustStop()
+}
+
+// Add registers the series with the given metricID and metricNameRaw at sl.
+//
+// False is returned if the series cannot be added, since the limit on the number of unique series is reached.
+func (sl *seriesLimiter) Add(metricID uint64, metricNameRaw []byte) bool {
+	ok, isNew := sl.l.AddExt(metricID)
+	if ok && !isNew {
+		// Fast path - the series has been already registered during the current interval.
+		return true
+	}
+	if !ok {
+		atomic.AddUint64(&sl.rowsDropped, 1)
+	}
+	metricGroup := getMetricGroupFromRaw(metricNameRaw)
+	sl.mu.Lock()
+	sl.resetIfNeededLocked(fasttime.UnixTimestamp())
+	e := sl.m[string(metricGroup)]
+	if e == nil {
+		if len(sl.m) >= maxSeriesLimitStatsEntries {
+			if isNew {
+				sl.skippedNewSeries++
+			}
+			sl.mu.Unlock()
+			return ok
+		}
+		e = &seriesLimitEntry{}
+		sl.m[string(metricGroup)] = e
+	}
+	if isNew {
+		e.newSeries++
+	}
+	if !ok {
+		e.rowsDropped++
+	}
+	sl.mu.Unlock()
+	return ok
+}
+
+// resetIfNeededLocked resets per-metric-name stats when the current interval ends.
+//
+// The interval boundaries are aligned with the refresh interval for sl.l.
+func (sl *seriesLimiter) resetIfNeededLocked(currentTimestamp uint64) {
+	interval := uint64(sl.interval.Seconds())
+	if currentTimestamp < sl.windowStart+interval {
+		return
+	}
+	sl.windowStart += ((currentTimestamp - sl.windowStart) / interval) * interval
+	sl.m = make(map[string]*seriesLimitEntry)
+	sl.skippedNewSeries = 0
+}
+
+// SeriesLimitStatus contains the status for the limiter on the number of unique series.
+//
+// See -storage.maxHourlySeries and -storage.maxDailySeries command-line flags.
+type SeriesLimitStatus struct {
+	// MaxSeries is the maximum number of unique series during the interval.
+	MaxSeries int
+
+	// CurrentSeries is the number of unique series registered during the current interval.
+	CurrentSeries int
+
+	// RowsDropped is the total number of rows dropped because of the limit since the start.
+	RowsDropped uint64
+
+	// WindowStart is the unix timestamp in seconds for the start of the current interval.
+	WindowStart uint64
+
+	// Interval is the interval for the limit.
+	Interval time.Duration
+
+	// SkippedNewSeries is the number of new series for metric names, which couldn't be tracked because of too many unique metric names.
+	SkippedNewSeries uint64
+
+	// TopMetricNames contains metric names with the biggest number of new series during the current interval.
+	TopMetricNames []SeriesLimitMetricNameEntry
+}
+
+// SeriesLimitMetricNameEntry contains per-metric-name stats for the limiter on the number of unique series.
+type SeriesLimitMetricNameEntry struct {
+	// Name is the metric name.
+	Name string
+
+	// NewSeries is the number of new series with the given metric name during the current interval.
+	NewSeries uint64
+
+	// RowsDropped is the number of rows with the given metric name dropped during the current interval.
+	RowsDropped uint64
+}
+
+// Status returns the status for sl with up to topN metric names with the biggest number of new series.
+func (sl *seriesLimiter) Status(topN int) *SeriesLimitStatus {
+	sls := &SeriesLimitStatus{
+		MaxSeries:     sl.l.MaxItems(),
+		CurrentSeries: sl.l.CurrentItems(),
+		RowsDropped:   atomic.LoadUint64(&sl.rowsDropped),
+		Interval:      sl.interval,
+	}
+	sl.mu.Lock()
+	sl.resetIfNeededLocked(fasttime.UnixTimestamp())
+	sls.WindowStart = sl.windowStart
+	sls.SkippedNewSeries = sl.skippedNewSeries
+	entries := make([]SeriesLimitMetricNameEntry, 0, len(sl.m))
+	for name, e := range sl.m {
+		entries = append(entries, SeriesLimitMetricNameEntry{
+			Name:        name,
+			NewSeries:   e.newSeries,
+			RowsDropped: e.rowsDropped,
+		})
+	}
+	sl.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := &entries[i], &entries[j]
+		if a.NewSeries != b.NewSeries {
+			return a.NewSeries > b.NewSeries
+		}
+		if a.RowsDropped != b.RowsDropped {
+			return a.RowsDropped > b.RowsDropped
+		}
+		return a.Name < b.Name
+	})
+	if len(entries) > topN {
+		entries = entries[:topN]
+	}
+	sls.TopMetricNames = entries
+	return sls
+}
+
+// getMetricGroupFromRaw returns metric name from metricNameRaw marshaled with MarshalMetricNameRaw.
+//
+// Empty result is returned if metricNameRaw doesn't contain metric name.
+func getMetricGroupFromRaw(metricNameRaw []byte) []byte {
+	src := metricNameRaw
+	for len(src) > 0 {
+		tail, key, err := unmarshalBytesFast(src)
+		if err != nil {
+			return nil
+		}
+		tail, value, err := unmarshalBytesFast(tail)
+		if err != nil {
+			return nil
+		}
+		if len(key) == 0 {
+			return value
+		}
+		src = tail
+	}
+	return nil
+}
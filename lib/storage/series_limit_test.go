@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+func TestGetMetricGroupFromRaw(t *testing.T) {
+	f := func(labels []prompb.Label, metricGroupExpected string) {
+		t.Helper()
+		metricNameRaw := MarshalMetricNameRaw(nil, labels)
+		metricGroup := getMetricGroupFromRaw(metricNameRaw)
+		if string(metricGroup) != metricGroupExpected {
+			t.Fatalf("unexpected metric group; got %q; want %q", metricGroup, metricGroupExpected)
+		}
+	}
+	f(nil, "")
+	f([]prompb.Label{{Name: []byte("job"), Value: []byte("foo")}}, "")
+	f([]prompb.Label{{Name: []byte("__name__"), Value: []byte("foo")}}, "foo")
+	f([]prompb.Label{
+		{Name: []byte("job"), Value: []byte("x")},
+		{Name: nil, Value: []byte("bar")},
+		{Name: []byte("instance"), Value: []byte("y")},
+	}, "bar")
+
+	// Invalid metricNameRaw
+	if metricGroup := getMetricGroupFromRaw([]byte("\x00")); len(metricGroup) > 0 {
+		t.Fatalf("unexpected metric group for invalid metricNameRaw: %q", metricGroup)
+	}
+}
+
+func TestSeriesLimiter(t *testing.T) {
+	sl := newSeriesLimiter(3, time.Hour)
+	defer sl.MustStop()
+
+	metricNameRaw := func(name, instance string) []byte {
+		return MarshalMetricNameRaw(nil, []prompb.Label{
+			{Name: nil, Value: []byte(name)},
+			{Name: []byte("instance"), Value: []byte(instance)},
+		})
+	}
+	f := func(metricID uint64, name, instance string, okExpected bool) {
+		t.Helper()
+		ok := sl.Add(metricID, metricNameRaw(name, instance))
+		if ok != okExpected {
+			t.Fatalf("unexpected result for metricID=%d; got %v; want %v", metricID, ok, okExpected)
+		}
+	}
+	f(1, "foo", "a", true)
+	f(2, "foo", "b", true)
+	f(1, "foo", "a", true)
+	f(3, "bar", "a", true)
+	f(1004, "baz", "a", false)
+	f(12345, "foo", "c", false)
+	f(12345, "foo", "c", false)
+	f(2, "foo", "b", true)
+
+	sls := sl.Status(10)
+	if sls.MaxSeries != 3 {
+		t.Fatalf("unexpected MaxSeries; got %d; want 3", sls.MaxSeries)
+	}
+	if sls.CurrentSeries != 3 {
+		t.Fatalf("unexpected CurrentSeries; got %d; want 3", sls.CurrentSeries)
+	}
+	if sls.RowsDropped != 3 {
+		t.Fatalf("unexpected RowsDropped; got %d; want 3", sls.RowsDropped)
+	}
+	entriesExpected := []SeriesLimitMetricNameEntry{
+		{Name: "foo", NewSeries: 2, RowsDropped: 2},
+		{Name: "bar", NewSeries: 1},
+		{Name: "baz", RowsDropped: 1},
+	}
+	if !reflect.DeepEqual(sls.TopMetricNames, entriesExpected) {
+		t.Fatalf("unexpected TopMetricNames;\ngot\n%+v\nwant\n%+v", sls.TopMetricNames, entriesExpected)
+	}
+
+	// Verify topN limit
+	sls = sl.Status(1)
+	if !reflect.DeepEqual(sls.TopMetricNames, entriesExpected[:1]) {
+		t.Fatalf("unexpected TopMetricNames for topN=1;\ngot\n%+v\nwant\n%+v", sls.TopMetricNames, entriesExpected[:1])
+	}
+
+	// Verify that per-metric-name stats are reset at the end of the interval.
+	sl.mu.Lock()
+	windowStart := sl.windowStart
+	sl.resetIfNeededLocked(windowStart + 2*3600 + 10)
+	windowStartNew := sl.windowStart
+	sl.mu.Unlock()
+	if windowStartNew != windowStart+2*3600 {
+		t.Fatalf("unexpected windowStart after reset; got %d; want %d", windowStartNew, windowStart+2*3600)
+	}
+	sls = sl.Status(10)
+	if len(sls.TopMetricNames) != 0 {
+		t.Fatalf("expecting empty TopMetricNames after reset; got %+v", sls.TopMetricNames)
+	}
+}
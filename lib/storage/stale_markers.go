@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// StaleMarkersMode defines how Prometheus staleness markers are handled by the storage and by queries.
+type StaleMarkersMode int
+
+const (
+	// StaleMarkersKeep stores staleness markers as is. Only instant selectors without rollup functions
+	// stop returning the series after the staleness marker. This is the default mode.
+	StaleMarkersKeep StaleMarkersMode = iota
+
+	// StaleMarkersDrop drops staleness markers during data ingestion and ignores already stored staleness markers during querying.
+	// Gaps are detected according to the staleness interval for all the time series then.
+	StaleMarkersDrop
+
+	// StaleMarkersSeriesEnd stores staleness markers as explicit series end markers.
+	// All the rollup functions do not use samples before the series end marker at subsequent points then.
+	StaleMarkersSeriesEnd
+)
+
+var staleMarkersModeNames = map[string]StaleMarkersMode{
+	"keep":      StaleMarkersKeep,
+	"drop":      StaleMarkersDrop,
+	"seriesEnd": StaleMarkersSeriesEnd,
+}
+
+// SetStaleMarkersMode sets the mode for handling Prometheus staleness markers.
+//
+// Supported modes: keep, drop and seriesEnd. Staleness markers are kept by default.
+//
+// This function must be called before initializing the storage.
+func SetStaleMarkersMode(mode string) error {
+	smm, ok := staleMarkersModeNames[mode]
+	if !ok {
+		return fmt.Errorf("unsupported stale markers mode %q; supported values: keep, drop, seriesEnd", mode)
+	}
+	staleMarkersMode = smm
+	return nil
+}
+
+// GetStaleMarkersMode returns the mode set via SetStaleMarkersMode.
+func GetStaleMarkersMode() StaleMarkersMode {
+	return staleMarkersMode
+}
+
+var staleMarkersMode = StaleMarkersKeep
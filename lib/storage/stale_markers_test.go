@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+)
+
+func TestSetStaleMarkersMode(t *testing.T) {
+	defer func() {
+		staleMarkersMode = StaleMarkersKeep
+	}()
+	f := func(mode string, smmExpected StaleMarkersMode) {
+		t.Helper()
+		if err := SetStaleMarkersMode(mode); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if smm := GetStaleMarkersMode(); smm != smmExpected {
+			t.Fatalf("unexpected stale markers mode for %q; got %d; want %d", mode, smm, smmExpected)
+		}
+	}
+	f("keep", StaleMarkersKeep)
+	f("drop", StaleMarkersDrop)
+	f("seriesEnd", StaleMarkersSeriesEnd)
+
+	for _, mode := range []string{"", "foo", "seriesend"} {
+		if err := SetStaleMarkersMode(mode); err == nil {
+			t.Fatalf("expecting non-nil error for %q", mode)
+		}
+	}
+}
+
+func TestStorageAddRowsStaleMarkers(t *testing.T) {
+	path := "TestStorageAddRowsStaleMarkers"
+	mn := MetricName{
+		MetricGroup: []byte("foo"),
+	}
+	now := time.Now().UnixNano() / 1e6
+	mrs := []MetricRow{
+		{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     now - 1000,
+			Value:         123,
+		},
+		{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     now,
+			Value:         decimal.StaleNaN,
+		},
+	}
+	f := func(mode string, rowsExpected int, staleMarkersDroppedExpected uint64) {
+		t.Helper()
+		defer func() {
+			staleMarkersMode = StaleMarkersKeep
+		}()
+		if err := SetStaleMarkersMode(mode); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		s, err := OpenStorage(path, -1, 0, 0)
+		if err != nil {
+			t.Fatalf("cannot open storage: %s", err)
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("cannot add rows: %s", err)
+		}
+		s.DebugFlush()
+		if err := testCountStorageRows(s, now-2000, now, map[string]int{"foo": rowsExpected}); err != nil {
+			t.Fatalf("unexpected rows for %q mode: %s", mode, err)
+		}
+		var m Metrics
+		s.UpdateMetrics(&m)
+		if m.StaleMarkersDropped != staleMarkersDroppedExpected {
+			t.Fatalf("unexpected number of dropped stale markers for %q mode; got %d; want %d", mode, m.StaleMarkersDropped, staleMarkersDroppedExpected)
+		}
+		s.MustClose()
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("cannot remove %q: %s", path, err)
+		}
+	}
+	f("keep", 2, 0)
+	f("drop", 1, 1)
+	f("seriesEnd", 2, 0)
+}
@@ -16,10 +16,10 @@ import (
 	"time"
 	"unsafe"
 
-	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bloomfilter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -42,6 +42,7 @@ type Storage struct {
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/212 .
 	tooSmallTimestampRows uint64
 	tooBigTimestampRows   uint64
+	tieredTimestampRows   uint64
 
 	addRowsConcurrencyLimitReached uint64
 	addRowsConcurrencyLimitTimeout uint64
@@ -54,8 +55,13 @@ type Storage struct {
 	slowPerDayIndexInserts uint64
 	slowMetricNameLoads    uint64
 
-	hourlySeriesLimitRowsDropped uint64
-	dailySeriesLimitRowsDropped  uint64
+	readOnlyRowsRejected uint64
+	staleMarkersDropped  uint64
+
+	activeIndexDBCompactions uint64
+
+	// isReadOnly is set to 1 when the free disk space at path drops below the limit set via SetFreeDiskSpaceLimit.
+	isReadOnly uint32
 
 	path           string
 	cachePath      string
@@ -69,8 +75,8 @@ type Storage struct {
 	tb *table
 
 	// Series cardinality limiters.
-	hourlySeriesLimiter *bloomfilter.Limiter
-	dailySeriesLimiter  *bloomfilter.Limiter
+	hourlySeriesLimiter *seriesLimiter
+	dailySeriesLimiter  *seriesLimiter
 
 	// tsidCache is MetricName -> TSID cache.
 	tsidCache *workingsetcache.Cache
@@ -113,11 +119,41 @@ type Storage struct {
 	// prefetchedMetricIDsLock is used for serializing updates of prefetchedMetricIDs from concurrent goroutines.
 	prefetchedMetricIDsLock sync.Mutex
 
+	// retentionFilterMetricIDs contains metricIDs matching -retentionFilter.
+	// It is periodically updated by retentionFiltersUpdater.
+	retentionFilterMetricIDs atomic.Value
+
+	// deletedRanges contains *deletedRanges with samples deleted via DeleteMetricsOnTimeRange.
+	deletedRanges atomic.Value
+
+	// deletedRangesLock is used for serializing updates of deletedRanges.
+	deletedRangesLock sync.Mutex
+
+	// seriesDiskUsage contains cached on-disk sizes for time series. See getSeriesDiskUsage.
+	seriesDiskUsage seriesDiskUsage
+
+	// exemplars contains the most recent exemplars per each time series. See SetExemplarsLimits.
+	exemplars *exemplarsStorage
+
+	// metricsMetadata contains metric metadata from `# HELP`, `# TYPE` and `# UNIT` lines. See SetMetricsMetadataMaxSizeBytes.
+	metricsMetadata *metricsMetadataStorage
+
 	stop chan struct{}
 
 	currHourMetricIDsUpdaterWG sync.WaitGroup
 	nextDayMetricIDsUpdaterWG  sync.WaitGroup
 	retentionWatcherWG         sync.WaitGroup
+	retentionFiltersUpdaterWG  sync.WaitGroup
+	freeDiskSpaceWatcherWG     sync.WaitGroup
+	indexDBCompactorWG         sync.WaitGroup
+
+	// indexDBCompactionLock prevents from concurrent indexdb compactions.
+	indexDBCompactionLock sync.Mutex
+
+	// The number of deleted metricIDs and the minimum date at the last indexdb compaction.
+	// They are protected by indexDBCompactionLock.
+	indexDBCompactionDeletedMetricIDs int
+	indexDBCompactionMinDate          uint64
 
 	// The snapshotLock prevents from concurrent creation of snapshots,
 	// since this may result in snapshots without recently added data,
@@ -178,6 +214,11 @@ func OpenStorage(path string, retentionMsecs int64, maxHourlySeries, maxDailySer
 	}
 	s.flockF = flockF
 
+	// Pre-create cache directory if it is missing, since caches may be skipped from saving there.
+	if err := fs.MkdirAllIfNotExist(s.cachePath); err != nil {
+		return nil, fmt.Errorf("cannot create %q: %w", s.cachePath, err)
+	}
+
 	// Pre-create snapshots directory if it is missing.
 	snapshotsPath := path + "/snapshots"
 	if err := fs.MkdirAllIfNotExist(snapshotsPath); err != nil {
@@ -186,17 +227,17 @@ func OpenStorage(path string, retentionMsecs int64, maxHourlySeries, maxDailySer
 
 	// Initialize series cardinality limiter.
 	if maxHourlySeries > 0 {
-		s.hourlySeriesLimiter = bloomfilter.NewLimiter(maxHourlySeries, time.Hour)
+		s.hourlySeriesLimiter = newSeriesLimiter(maxHourlySeries, time.Hour)
 	}
 	if maxDailySeries > 0 {
-		s.dailySeriesLimiter = bloomfilter.NewLimiter(maxDailySeries, 24*time.Hour)
+		s.dailySeriesLimiter = newSeriesLimiter(maxDailySeries, 24*time.Hour)
 	}
 
 	// Load caches.
 	mem := memory.Allowed()
-	s.tsidCache = s.mustLoadCache("MetricName->TSID", "metricName_tsid", int(float64(mem)*0.35))
+	s.tsidCache = s.mustLoadCache("MetricName->TSID", "metricName_tsid", int(float64(mem)*tsidCachePercent/100))
 	s.metricIDCache = s.mustLoadCache("MetricID->TSID", "metricID_tsid", mem/16)
-	s.metricNameCache = s.mustLoadCache("MetricID->MetricName", "metricID_metricName", mem/10)
+	s.metricNameCache = s.mustLoadCache("MetricID->MetricName", "metricID_metricName", int(float64(mem)*metricNameCachePercent/100))
 	s.dateMetricIDCache = newDateMetricIDCache()
 
 	hour := fasttime.UnixHour()
@@ -212,6 +253,12 @@ func OpenStorage(path string, retentionMsecs int64, maxHourlySeries, maxDailySer
 	s.pendingNextDayMetricIDs = &uint64set.Set{}
 
 	s.prefetchedMetricIDs.Store(&uint64set.Set{})
+	s.retentionFilterMetricIDs.Store(&retentionFilterMetricIDs{})
+
+	s.exemplars = newExemplarsStorage(maxExemplarsSizeBytes, maxExemplarsPerSeries)
+	s.exemplars.mustLoad(s.cachePath + "/" + exemplarsFilename)
+	s.metricsMetadata = newMetricsMetadataStorage(maxMetricsMetadataSizeBytes)
+	s.metricsMetadata.mustLoad(s.cachePath + "/" + metricsMetadataFilename)
 
 	// Load metadata
 	metadataDir := path + "/metadata"
@@ -220,6 +267,7 @@ func OpenStorage(path string, retentionMsecs int64, maxHourlySeries, maxDailySer
 		return nil, fmt.Errorf("cannot create %q: %w", metadataDir, err)
 	}
 	s.minTimestampForCompositeIndex = mustGetMinTimestampForCompositeIndex(metadataDir, isEmptyDB)
+	s.deletedRanges.Store(mustLoadDeletedRanges(metadataDir + "/deletedRanges"))
 
 	// Load indexdb
 	idbPath := path + "/indexdb"
@@ -248,7 +296,7 @@ func OpenStorage(path string, retentionMsecs int64, maxHourlySeries, maxDailySer
 
 	// Load data
 	tablePath := path + "/data"
-	tb, err := openTable(tablePath, s.getDeletedMetricIDs, retentionMsecs)
+	tb, err := openTable(tablePath, s.getDeletedMetricIDs, s.getRetentionFilterMetricIDs, s.getDeletedRanges, retentionMsecs)
 	if err != nil {
 		s.idb().MustClose()
 		return nil, fmt.Errorf("cannot open table at %q: %w", tablePath, err)
@@ -258,14 +306,120 @@ func OpenStorage(path string, retentionMsecs int64, maxHourlySeries, maxDailySer
 	s.startCurrHourMetricIDsUpdater()
 	s.startNextDayMetricIDsUpdater()
 	s.startRetentionWatcher()
+	s.startRetentionFiltersUpdater()
+	s.startFreeDiskSpaceWatcher()
+	s.startIndexDBCompactor()
 
 	return s, nil
 }
 
+var (
+	tsidCachePercent       = 35.0
+	metricNameCachePercent = 10.0
+)
+
+// SetTSIDCachePercent sets the size of MetricName->TSID cache as a percent of allowed memory.
+//
+// This function must be called before opening the storage.
+func SetTSIDCachePercent(percent float64) {
+	tsidCachePercent = percent
+}
+
+// SetMetricNameCachePercent sets the size of MetricID->MetricName cache as a percent of allowed memory.
+//
+// This function must be called before opening the storage.
+func SetMetricNameCachePercent(percent float64) {
+	metricNameCachePercent = percent
+}
+
+var freeDiskSpaceLimitBytes uint64
+
+// SetFreeDiskSpaceLimit sets the minimum free disk space at the storage path.
+//
+// The storage switches to read-only mode when the free disk space drops below the given limit.
+// The limit is disabled if bytes is 0.
+//
+// This function must be called before opening the storage.
+func SetFreeDiskSpaceLimit(bytes int64) {
+	freeDiskSpaceLimitBytes = uint64(bytes)
+}
+
+var extraDataPaths []string
+
+// SetExtraDataPaths sets additional data paths for storing partitions.
+//
+// New partitions are created at the path with the most free disk space among the storage path and the given paths,
+// so the data is spread among multiple disks. The indexdb, caches and snapshots are stored at the storage path.
+//
+// This function must be called before opening the storage.
+func SetExtraDataPaths(paths []string) {
+	extraDataPaths = append([]string{}, paths...)
+}
+
+// ErrReadOnly is returned when data is added to the storage in read-only mode.
+var ErrReadOnly = fmt.Errorf("the storage is in read-only mode, since the free disk space dropped below -storage.minFreeDiskSpaceBytes; " +
+	"free up disk space or decrease -storage.minFreeDiskSpaceBytes")
+
+// IsReadOnly returns true if the storage is in read-only mode because of low free disk space.
+func (s *Storage) IsReadOnly() bool {
+	return atomic.LoadUint32(&s.isReadOnly) == 1
+}
+
+func (s *Storage) startFreeDiskSpaceWatcher() {
+	if freeDiskSpaceLimitBytes == 0 {
+		return
+	}
+	s.updateReadOnlyMode()
+	s.freeDiskSpaceWatcherWG.Add(1)
+	go func() {
+		s.freeDiskSpaceWatcher()
+		s.freeDiskSpaceWatcherWG.Done()
+	}()
+}
+
+func (s *Storage) freeDiskSpaceWatcher() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.updateReadOnlyMode()
+		}
+	}
+}
+
+func (s *Storage) updateReadOnlyMode() {
+	// Check all the data paths, since new data may be written to partitions at any of them.
+	paths := []string{s.path}
+	for _, dp := range s.tb.dataPaths[1:] {
+		paths = append(paths, dp.path)
+	}
+	for _, path := range paths {
+		freeSpaceBytes := fs.MustGetFreeSpace(path)
+		if freeSpaceBytes < freeDiskSpaceLimitBytes {
+			if atomic.CompareAndSwapUint32(&s.isReadOnly, 0, 1) {
+				logger.Warnf("switching the storage at %q to read-only mode, since %q has less than -storage.minFreeDiskSpaceBytes=%d bytes of free space: %d bytes left",
+					s.path, path, freeDiskSpaceLimitBytes, freeSpaceBytes)
+			}
+			return
+		}
+	}
+	if atomic.CompareAndSwapUint32(&s.isReadOnly, 1, 0) {
+		logger.Infof("switching the storage at %q back to read-write mode, since all its data paths have at least -storage.minFreeDiskSpaceBytes=%d bytes of free space",
+			s.path, freeDiskSpaceLimitBytes)
+	}
+}
+
 func (s *Storage) getDeletedMetricIDs() *uint64set.Set {
 	return s.deletedMetricIDs.Load().(*uint64set.Set)
 }
 
+func (s *Storage) getDeletedRanges() *deletedRanges {
+	return s.deletedRanges.Load().(*deletedRanges)
+}
+
 func (s *Storage) setDeletedMetricIDs(dmis *uint64set.Set) {
 	s.deletedMetricIDs.Store(dmis)
 }
@@ -299,22 +453,34 @@ func (s *Storage) CreateSnapshot() (string, error) {
 	if err := fs.MkdirAllFailIfExist(dstDir); err != nil {
 		return "", fmt.Errorf("cannot create dir %q: %w", dstDir, err)
 	}
+	if err := s.createSnapshot(snapshotName, dstDir); err != nil {
+		// Remove the partially created snapshot, so it isn't mistakenly used for backups.
+		s.mustDeleteSnapshot(snapshotName)
+		return "", err
+	}
+
+	logger.Infof("created Storage snapshot for %q at %q in %.3f seconds", srcDir, dstDir, time.Since(startTime).Seconds())
+	return snapshotName, nil
+}
+
+func (s *Storage) createSnapshot(snapshotName, dstDir string) error {
+	srcDir := s.path
 	dstDataDir := dstDir + "/data"
 	if err := fs.MkdirAllFailIfExist(dstDataDir); err != nil {
-		return "", fmt.Errorf("cannot create dir %q: %w", dstDataDir, err)
+		return fmt.Errorf("cannot create dir %q: %w", dstDataDir, err)
 	}
 
 	smallDir, bigDir, err := s.tb.CreateSnapshot(snapshotName)
 	if err != nil {
-		return "", fmt.Errorf("cannot create table snapshot: %w", err)
+		return fmt.Errorf("cannot create table snapshot: %w", err)
 	}
 	dstSmallDir := dstDataDir + "/small"
 	if err := fs.SymlinkRelative(smallDir, dstSmallDir); err != nil {
-		return "", fmt.Errorf("cannot create symlink from %q to %q: %w", smallDir, dstSmallDir, err)
+		return fmt.Errorf("cannot create symlink from %q to %q: %w", smallDir, dstSmallDir, err)
 	}
 	dstBigDir := dstDataDir + "/big"
 	if err := fs.SymlinkRelative(bigDir, dstBigDir); err != nil {
-		return "", fmt.Errorf("cannot create symlink from %q to %q: %w", bigDir, dstBigDir, err)
+		return fmt.Errorf("cannot create symlink from %q to %q: %w", bigDir, dstBigDir, err)
 	}
 	fs.MustSyncPath(dstDataDir)
 
@@ -322,30 +488,28 @@ func (s *Storage) CreateSnapshot() (string, error) {
 	idb := s.idb()
 	currSnapshot := idbSnapshot + "/" + idb.name
 	if err := idb.tb.CreateSnapshotAt(currSnapshot); err != nil {
-		return "", fmt.Errorf("cannot create curr indexDB snapshot: %w", err)
+		return fmt.Errorf("cannot create curr indexDB snapshot: %w", err)
 	}
 	ok := idb.doExtDB(func(extDB *indexDB) {
 		prevSnapshot := idbSnapshot + "/" + extDB.name
 		err = extDB.tb.CreateSnapshotAt(prevSnapshot)
 	})
 	if ok && err != nil {
-		return "", fmt.Errorf("cannot create prev indexDB snapshot: %w", err)
+		return fmt.Errorf("cannot create prev indexDB snapshot: %w", err)
 	}
 	dstIdbDir := dstDir + "/indexdb"
 	if err := fs.SymlinkRelative(idbSnapshot, dstIdbDir); err != nil {
-		return "", fmt.Errorf("cannot create symlink from %q to %q: %w", idbSnapshot, dstIdbDir, err)
+		return fmt.Errorf("cannot create symlink from %q to %q: %w", idbSnapshot, dstIdbDir, err)
 	}
 
 	srcMetadataDir := srcDir + "/metadata"
 	dstMetadataDir := dstDir + "/metadata"
 	if err := fs.CopyDirectory(srcMetadataDir, dstMetadataDir); err != nil {
-		return "", fmt.Errorf("cannot copy metadata: %s", err)
+		return fmt.Errorf("cannot copy metadata: %s", err)
 	}
 
 	fs.MustSyncPath(dstDir)
-
-	logger.Infof("created Storage snapshot for %q at %q in %.3f seconds", srcDir, dstDir, time.Since(startTime).Seconds())
-	return snapshotName, nil
+	return nil
 }
 
 var snapshotNameRegexp = regexp.MustCompile("^[0-9]{14}-[0-9A-Fa-f]+$")
@@ -380,20 +544,64 @@ func (s *Storage) DeleteSnapshot(snapshotName string) error {
 		return fmt.Errorf("invalid snapshotName %q", snapshotName)
 	}
 	snapshotPath := s.path + "/snapshots/" + snapshotName
+	if !fs.IsPathExist(snapshotPath) {
+		return fmt.Errorf("cannot find snapshot %q", snapshotName)
+	}
 
 	logger.Infof("deleting snapshot %q...", snapshotPath)
 	startTime := time.Now()
 
-	s.tb.MustDeleteSnapshot(snapshotName)
-	idbPath := fmt.Sprintf("%s/indexdb/snapshots/%s", s.path, snapshotName)
-	fs.MustRemoveAll(idbPath)
-	fs.MustRemoveAll(snapshotPath)
+	s.mustDeleteSnapshot(snapshotName)
 
 	logger.Infof("deleted snapshot %q in %.3f seconds", snapshotPath, time.Since(startTime).Seconds())
 
 	return nil
 }
 
+// DeleteStaleSnapshots deletes snapshots older than the given maxAge.
+//
+// The snapshot age is determined by the creation time encoded in the snapshot name.
+func (s *Storage) DeleteStaleSnapshots(maxAge time.Duration) error {
+	snapshots, err := s.ListSnapshots()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().UTC().Add(-maxAge)
+	for _, snapshotName := range snapshots {
+		t, err := getSnapshotCreationTime(snapshotName)
+		if err != nil {
+			return err
+		}
+		if !t.Before(deadline) {
+			continue
+		}
+		if err := s.DeleteSnapshot(snapshotName); err != nil {
+			return fmt.Errorf("cannot delete snapshot %q: %w", snapshotName, err)
+		}
+	}
+	return nil
+}
+
+func getSnapshotCreationTime(snapshotName string) (time.Time, error) {
+	n := strings.IndexByte(snapshotName, '-')
+	if n < 0 {
+		return time.Time{}, fmt.Errorf("cannot find creation time in snapshot name %q", snapshotName)
+	}
+	t, err := time.Parse("20060102150405", snapshotName[:n])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse creation time in snapshot name %q: %w", snapshotName, err)
+	}
+	return t, nil
+}
+
+func (s *Storage) mustDeleteSnapshot(snapshotName string) {
+	s.tb.MustDeleteSnapshot(snapshotName)
+	idbPath := fmt.Sprintf("%s/indexdb/snapshots/%s", s.path, snapshotName)
+	fs.MustRemoveAll(idbPath)
+	snapshotPath := s.path + "/snapshots/" + snapshotName
+	fs.MustRemoveAll(snapshotPath)
+}
+
 var snapshotIdx = uint64(time.Now().UnixNano())
 
 func nextSnapshotIdx() uint64 {
@@ -411,6 +619,7 @@ type Metrics struct {
 
 	TooSmallTimestampRows uint64
 	TooBigTimestampRows   uint64
+	TieredTimestampRows   uint64
 
 	AddRowsConcurrencyLimitReached uint64
 	AddRowsConcurrencyLimitTimeout uint64
@@ -429,8 +638,18 @@ type Metrics struct {
 	SlowPerDayIndexInserts uint64
 	SlowMetricNameLoads    uint64
 
-	HourlySeriesLimitRowsDropped uint64
-	DailySeriesLimitRowsDropped  uint64
+	IsReadOnly           bool
+	ReadOnlyRowsRejected uint64
+	StaleMarkersDropped  uint64
+
+	ActiveIndexDBCompactions uint64
+
+	HourlySeriesLimitRowsDropped   uint64
+	HourlySeriesLimitMaxSeries     uint64
+	HourlySeriesLimitCurrentSeries uint64
+	DailySeriesLimitRowsDropped    uint64
+	DailySeriesLimitMaxSeries      uint64
+	DailySeriesLimitCurrentSeries  uint64
 
 	TimestampsBlocksMerged uint64
 	TimestampsBytesSaved   uint64
@@ -467,6 +686,21 @@ type Metrics struct {
 	PrefetchedMetricIDsSize      uint64
 	PrefetchedMetricIDsSizeBytes uint64
 
+	ExemplarsCount         uint64
+	ExemplarsSeries        uint64
+	ExemplarsSizeBytes     uint64
+	ExemplarsMaxSizeBytes  uint64
+	ExemplarsAdded         uint64
+	ExemplarsOutOfOrder    uint64
+	ExemplarsInvalid       uint64
+	ExemplarsUnknownSeries uint64
+	ExemplarsEvictedSeries uint64
+
+	MetricsMetadataEntries        uint64
+	MetricsMetadataSizeBytes      uint64
+	MetricsMetadataMaxSizeBytes   uint64
+	MetricsMetadataEvictedEntries uint64
+
 	IndexDBMetrics IndexDBMetrics
 	TableMetrics   TableMetrics
 }
@@ -483,6 +717,7 @@ func (s *Storage) UpdateMetrics(m *Metrics) {
 
 	m.TooSmallTimestampRows += atomic.LoadUint64(&s.tooSmallTimestampRows)
 	m.TooBigTimestampRows += atomic.LoadUint64(&s.tooBigTimestampRows)
+	m.TieredTimestampRows += atomic.LoadUint64(&s.tieredTimestampRows)
 
 	m.AddRowsConcurrencyLimitReached += atomic.LoadUint64(&s.addRowsConcurrencyLimitReached)
 	m.AddRowsConcurrencyLimitTimeout += atomic.LoadUint64(&s.addRowsConcurrencyLimitTimeout)
@@ -501,8 +736,22 @@ func (s *Storage) UpdateMetrics(m *Metrics) {
 	m.SlowPerDayIndexInserts += atomic.LoadUint64(&s.slowPerDayIndexInserts)
 	m.SlowMetricNameLoads += atomic.LoadUint64(&s.slowMetricNameLoads)
 
-	m.HourlySeriesLimitRowsDropped += atomic.LoadUint64(&s.hourlySeriesLimitRowsDropped)
-	m.DailySeriesLimitRowsDropped += atomic.LoadUint64(&s.dailySeriesLimitRowsDropped)
+	m.IsReadOnly = s.IsReadOnly()
+	m.ReadOnlyRowsRejected += atomic.LoadUint64(&s.readOnlyRowsRejected)
+	m.StaleMarkersDropped += atomic.LoadUint64(&s.staleMarkersDropped)
+
+	m.ActiveIndexDBCompactions += atomic.LoadUint64(&s.activeIndexDBCompactions)
+
+	if sl := s.hourlySeriesLimiter; sl != nil {
+		m.HourlySeriesLimitRowsDropped += atomic.LoadUint64(&sl.rowsDropped)
+		m.HourlySeriesLimitMaxSeries += uint64(sl.l.MaxItems())
+		m.HourlySeriesLimitCurrentSeries += uint64(sl.l.CurrentItems())
+	}
+	if sl := s.dailySeriesLimiter; sl != nil {
+		m.DailySeriesLimitRowsDropped += atomic.LoadUint64(&sl.rowsDropped)
+		m.DailySeriesLimitMaxSeries += uint64(sl.l.MaxItems())
+		m.DailySeriesLimitCurrentSeries += uint64(sl.l.CurrentItems())
+	}
 
 	m.TimestampsBlocksMerged = atomic.LoadUint64(&timestampsBlocksMerged)
 	m.TimestampsBytesSaved = atomic.LoadUint64(&timestampsBytesSaved)
@@ -554,6 +803,9 @@ func (s *Storage) UpdateMetrics(m *Metrics) {
 	m.PrefetchedMetricIDsSize += uint64(prefetchedMetricIDs.Len())
 	m.PrefetchedMetricIDsSizeBytes += uint64(prefetchedMetricIDs.SizeBytes())
 
+	s.exemplars.updateMetrics(m)
+	s.metricsMetadata.updateMetrics(m)
+
 	s.idb().UpdateMetrics(&m.IndexDBMetrics)
 	s.tb.UpdateMetrics(&m.TableMetrics)
 }
@@ -675,6 +927,9 @@ func (s *Storage) MustClose() {
 	s.retentionWatcherWG.Wait()
 	s.currHourMetricIDsUpdaterWG.Wait()
 	s.nextDayMetricIDsUpdaterWG.Wait()
+	s.retentionFiltersUpdaterWG.Wait()
+	s.freeDiskSpaceWatcherWG.Wait()
+	s.indexDBCompactorWG.Wait()
 
 	s.tb.MustClose()
 	s.idb().MustClose()
@@ -695,6 +950,9 @@ func (s *Storage) MustClose() {
 	nextDayMetricIDs := s.nextDayMetricIDs.Load().(*byDateMetricIDEntry)
 	s.mustSaveNextDayMetricIDs(nextDayMetricIDs)
 
+	s.exemplars.mustSave(s.cachePath + "/" + exemplarsFilename)
+	s.metricsMetadata.mustSave(s.cachePath + "/" + metricsMetadataFilename)
+
 	// Release lock file.
 	if err := s.flockF.Close(); err != nil {
 		logger.Panicf("FATAL: cannot close lock file %q: %s", s.flockF.Name(), err)
@@ -925,6 +1183,13 @@ func (s *Storage) mustSaveCache(c *workingsetcache.Cache, info, name string) {
 	defer saveCacheLock.Unlock()
 
 	path := s.cachePath + "/" + name
+	if encryption.IsEnabled() {
+		// Caches contain metric names in plaintext, so they mustn't be persisted when encryption at rest is enabled.
+		// Remove the cache saved before enabling the encryption.
+		fs.MustRemoveAll(path)
+		logger.Infof("skip saving %s cache to %q, since encryption at rest is enabled", info, path)
+		return
+	}
 	logger.Infof("saving %s cache to %q...", info, path)
 	startTime := time.Now()
 	if err := c.Save(path); err != nil {
@@ -1140,6 +1405,50 @@ func (s *Storage) DeleteMetrics(tfss []*TagFilters) (int, error) {
 	return deletedCount, nil
 }
 
+// DeleteMetricsOnTimeRange deletes samples on the given tr for all the metrics matching the given tfss.
+//
+// The deleted samples become invisible to search immediately and are physically removed during background merges.
+// Returns the number of metrics with deleted samples.
+func (s *Storage) DeleteMetricsOnTimeRange(tfss []*TagFilters, tr TimeRange) (int, error) {
+	idb := s.idb()
+	is := idb.getIndexSearch(noDeadline)
+	metricIDs, err := is.searchMetricIDs(tfss, tr, 2e9)
+	idb.putIndexSearch(is)
+	if err != nil {
+		return 0, fmt.Errorf("cannot search metricIDs: %w", err)
+	}
+	m := &uint64set.Set{}
+	m.AddMulti(metricIDs)
+	ok := idb.doExtDB(func(extDB *indexDB) {
+		is := extDB.getIndexSearch(noDeadline)
+		var extMetricIDs []uint64
+		extMetricIDs, err = is.searchMetricIDs(tfss, tr, 2e9)
+		extDB.putIndexSearch(is)
+		m.AddMulti(extMetricIDs)
+	})
+	if ok && err != nil {
+		return 0, fmt.Errorf("cannot search metricIDs in the previous indexdb: %w", err)
+	}
+	if m.Len() == 0 {
+		return 0, nil
+	}
+
+	// Flush pending rows, so they get into parts affected by the deleted range.
+	s.tb.flushRawRows()
+
+	s.deletedRangesLock.Lock()
+	minTimestamp := int64(fasttime.UnixTimestamp()*1000) - s.retentionMsecs
+	createdAt := s.tb.nextDeletedRangeMergeIdx()
+	drs := s.getDeletedRanges().addRange(tr, m, minTimestamp, createdAt)
+	mustSaveDeletedRanges(s.path+"/metadata/deletedRanges", drs)
+	s.deletedRanges.Store(drs)
+	// Parts created from now on contain samples added after the deletion, so the deleted range mustn't apply to them.
+	s.tb.bumpMergeIdx(createdAt)
+	s.deletedRangesLock.Unlock()
+
+	return m.Len(), nil
+}
+
 // SearchTagKeysOnTimeRange searches for tag keys on tr.
 func (s *Storage) SearchTagKeysOnTimeRange(tr TimeRange, maxTagKeys int, deadline uint64) ([]string, error) {
 	return s.idb().SearchTagKeysOnTimeRange(tr, maxTagKeys, deadline)
@@ -1160,6 +1469,13 @@ func (s *Storage) SearchTagValues(tagKey []byte, maxTagValues int, deadline uint
 	return s.idb().SearchTagValues(tagKey, maxTagValues, deadline)
 }
 
+// SearchTagValuesWithPrefix returns up to maxTagValues tag values for the given tagKey starting with the given tagValuePrefix on the given tr.
+//
+// If tfss is non-empty, then only tag values for time series matching tfss are returned.
+func (s *Storage) SearchTagValuesWithPrefix(tr TimeRange, tagKey, tagValuePrefix []byte, tfss []*TagFilters, maxTagValues, maxMetrics int, deadline uint64) ([]string, error) {
+	return s.idb().SearchTagValuesWithPrefix(tr, tagKey, tagValuePrefix, tfss, maxTagValues, maxMetrics, deadline)
+}
+
 // SearchTagValueSuffixes returns all the tag value suffixes for the given tagKey and tagValuePrefix on the given tr.
 //
 // This allows implementing https://graphite-api.readthedocs.io/en/latest/api.html#metrics-find or similar APIs.
@@ -1344,8 +1660,21 @@ func (s *Storage) GetSeriesCount(deadline uint64) (uint64, error) {
 }
 
 // GetTSDBStatusWithFiltersForDate returns TSDB status data for /api/v1/status/tsdb with match[] filters.
-func (s *Storage) GetTSDBStatusWithFiltersForDate(tfss []*TagFilters, date uint64, topN int, deadline uint64) (*TSDBStatus, error) {
-	return s.idb().GetTSDBStatusWithFiltersForDate(tfss, date, topN, deadline)
+//
+// If focusLabel isn't empty, then the top values for the given label are returned in TSDBStatus.SeriesCountByFocusLabelValue.
+//
+// If diskUsage is set, then the approximate on-disk sizes per metric name and per label=value pair are returned
+// in TSDBStatus.BytesByMetricName and TSDBStatus.BytesByLabelValuePair.
+func (s *Storage) GetTSDBStatusWithFiltersForDate(tfss []*TagFilters, date uint64, focusLabel string, topN int, diskUsage bool, deadline uint64) (*TSDBStatus, error) {
+	var bytesByMetricID map[uint64]uint64
+	if diskUsage {
+		m, err := s.getSeriesDiskUsage(deadline)
+		if err != nil {
+			return nil, err
+		}
+		bytesByMetricID = m
+	}
+	return s.idb().GetTSDBStatusWithFiltersForDate(tfss, date, focusLabel, topN, bytesByMetricID, deadline)
 }
 
 // MetricRow is a metric to insert into storage.
@@ -1356,6 +1685,16 @@ type MetricRow struct {
 
 	Timestamp int64
 	Value     float64
+
+	// Nanos is the sub-millisecond part of the Timestamp in nanoseconds. It must be in the range [0..999999].
+	//
+	// It is stored only for time series with nanosecond timestamps. See SetNanosecondPrecisionMetricPrefixes.
+	Nanos int32
+
+	// Histogram is an optional native histogram marshaled with prompb.Histogram.MarshalCompact.
+	//
+	// Value must contain the number of observations in the histogram if Histogram is set.
+	Histogram []byte
 }
 
 // CopyFrom copies src to mr.
@@ -1363,6 +1702,8 @@ func (mr *MetricRow) CopyFrom(src *MetricRow) {
 	mr.MetricNameRaw = append(mr.MetricNameRaw[:0], src.MetricNameRaw...)
 	mr.Timestamp = src.Timestamp
 	mr.Value = src.Value
+	mr.Nanos = src.Nanos
+	mr.Histogram = append(mr.Histogram[:0], src.Histogram...)
 }
 
 // String returns string representation of the mr.
@@ -1380,6 +1721,8 @@ func (mr *MetricRow) Marshal(dst []byte) []byte {
 	dst = encoding.MarshalBytes(dst, mr.MetricNameRaw)
 	dst = encoding.MarshalUint64(dst, uint64(mr.Timestamp))
 	dst = encoding.MarshalUint64(dst, math.Float64bits(mr.Value))
+	dst = encoding.MarshalUint32(dst, uint32(mr.Nanos))
+	dst = encoding.MarshalBytes(dst, mr.Histogram)
 	return dst
 }
 
@@ -1407,6 +1750,18 @@ func (mr *MetricRow) UnmarshalX(src []byte) ([]byte, error) {
 	mr.Value = math.Float64frombits(value)
 	tail = tail[8:]
 
+	if len(tail) < 4 {
+		return tail, fmt.Errorf("cannot unmarshal Nanos: want %d bytes; have %d bytes", 4, len(tail))
+	}
+	mr.Nanos = int32(encoding.UnmarshalUint32(tail))
+	tail = tail[4:]
+
+	tail, histogram, err := encoding.UnmarshalBytes(tail)
+	if err != nil {
+		return tail, fmt.Errorf("cannot unmarshal Histogram: %w", err)
+	}
+	mr.Histogram = histogram
+
 	return tail, nil
 }
 
@@ -1424,6 +1779,10 @@ func (s *Storage) AddRows(mrs []MetricRow, precisionBits uint8) error {
 	if len(mrs) == 0 {
 		return nil
 	}
+	if s.IsReadOnly() {
+		atomic.AddUint64(&s.readOnlyRowsRejected, uint64(len(mrs)))
+		return ErrReadOnly
+	}
 
 	// Limit the number of concurrent goroutines that may add rows to the storage.
 	// This should prevent from out of memory errors and CPU trashing when too many
@@ -1520,6 +1879,9 @@ var (
 // The the MetricRow.Timestamp is used for registering the metric name starting from the given timestamp.
 // Th MetricRow.Value field is ignored.
 func (s *Storage) RegisterMetricNames(mrs []MetricRow) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
 	var (
 		tsid       TSID
 		metricName []byte
@@ -1584,6 +1946,10 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 	)
 	var pmrs *pendingMetricRows
 	minTimestamp, maxTimestamp := s.tb.getMinMaxTimestamps()
+	minTieringTimestamp := int64(math.MinInt64)
+	if partitionTier != nil {
+		minTieringTimestamp = tieringDeadline()
+	}
 	// Return only the first error, since it has no sense in returning all errors.
 	var firstWarn error
 	for i := range mrs {
@@ -1594,6 +1960,11 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 				// doesn't know how to work with them.
 				continue
 			}
+			if staleMarkersMode == StaleMarkersDrop {
+				// Skip Prometheus staleness marker according to -storage.staleMarkers=drop.
+				atomic.AddUint64(&s.staleMarkersDropped, 1)
+				continue
+			}
 		}
 		if mr.Timestamp < minTimestamp {
 			// Skip rows with too small timestamps outside the retention.
@@ -1606,6 +1977,17 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 			atomic.AddUint64(&s.tooSmallTimestampRows, 1)
 			continue
 		}
+		if mr.Timestamp < minTieringTimestamp {
+			// Skip rows for partitions, which may be offloaded to -storage.tieringDst.
+			if firstWarn == nil {
+				metricName := getUserReadableMetricName(mr.MetricNameRaw)
+				firstWarn = fmt.Errorf("cannot insert row with timestamp %d older than -storage.tieringAge, since partitions with such data "+
+					"may be offloaded to -storage.tieringDst; minimum allowed timestamp is %d; metricName: %s",
+					mr.Timestamp, minTieringTimestamp, metricName)
+			}
+			atomic.AddUint64(&s.tieredTimestampRows, 1)
+			continue
+		}
 		if mr.Timestamp > maxTimestamp {
 			// Skip rows with too big timestamps significantly exceeding the current time.
 			if firstWarn == nil {
@@ -1622,6 +2004,9 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 		r.Timestamp = mr.Timestamp
 		r.Value = mr.Value
 		r.PrecisionBits = precisionBits
+		r.NanosecondTimestamp = isNanosecondPrecisionMetric(mr.MetricNameRaw)
+		r.Nanos = mr.Nanos
+		r.Histogram = copyHistogram(mr.Histogram)
 		if string(mr.MetricNameRaw) == string(prevMetricNameRaw) {
 			// Fast path - the current mr contains the same metric name as the previous mr, so it contains the same TSID.
 			// This path should trigger on bulk imports when many rows contain the same MetricNameRaw.
@@ -1677,6 +2062,9 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 			r.Timestamp = mr.Timestamp
 			r.Value = mr.Value
 			r.PrecisionBits = precisionBits
+			r.NanosecondTimestamp = isNanosecondPrecisionMetric(mr.MetricNameRaw)
+			r.Nanos = mr.Nanos
+			r.Histogram = copyHistogram(mr.Histogram)
 			if string(mr.MetricNameRaw) == string(prevMetricNameRaw) {
 				// Fast path - the current mr contains the same metric name as the previous mr, so it contains the same TSID.
 				// This path should trigger on bulk imports when many rows contain the same MetricNameRaw.
@@ -1727,19 +2115,39 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 }
 
 func (s *Storage) isSeriesCardinalityExceeded(metricID uint64, metricNameRaw []byte) bool {
-	if sl := s.hourlySeriesLimiter; sl != nil && !sl.Add(metricID) {
-		atomic.AddUint64(&s.hourlySeriesLimitRowsDropped, 1)
-		logSkippedSeries(metricNameRaw, "-storage.maxHourlySeries", sl.MaxItems())
+	if sl := s.hourlySeriesLimiter; sl != nil && !sl.Add(metricID, metricNameRaw) {
+		logSkippedSeries(metricNameRaw, "-storage.maxHourlySeries", sl.l.MaxItems())
 		return true
 	}
-	if sl := s.dailySeriesLimiter; sl != nil && !sl.Add(metricID) {
-		atomic.AddUint64(&s.dailySeriesLimitRowsDropped, 1)
-		logSkippedSeries(metricNameRaw, "-storage.maxDailySeries", sl.MaxItems())
+	if sl := s.dailySeriesLimiter; sl != nil && !sl.Add(metricID, metricNameRaw) {
+		logSkippedSeries(metricNameRaw, "-storage.maxDailySeries", sl.l.MaxItems())
 		return true
 	}
 	return false
 }
 
+// GetHourlySeriesLimitStatus returns the status for -storage.maxHourlySeries limit with up to topN metric names,
+// which contribute the most new series during the current hour.
+//
+// nil is returned if the limit isn't set.
+func (s *Storage) GetHourlySeriesLimitStatus(topN int) *SeriesLimitStatus {
+	if s.hourlySeriesLimiter == nil {
+		return nil
+	}
+	return s.hourlySeriesLimiter.Status(topN)
+}
+
+// GetDailySeriesLimitStatus returns the status for -storage.maxDailySeries limit with up to topN metric names,
+// which contribute the most new series during the current day.
+//
+// nil is returned if the limit isn't set.
+func (s *Storage) GetDailySeriesLimitStatus(topN int) *SeriesLimitStatus {
+	if s.dailySeriesLimiter == nil {
+		return nil
+	}
+	return s.dailySeriesLimiter.Status(topN)
+}
+
 func logSkippedSeries(metricNameRaw []byte, flagName string, flagValue int) {
 	select {
 	case <-logSkippedSeriesTicker.C:
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"testing/quick"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encryption"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
 )
 
@@ -332,6 +334,12 @@ func TestMetricRowMarshalUnmarshal(t *testing.T) {
 		if mr2.MetricNameRaw == nil {
 			mr2.MetricNameRaw = []byte{}
 		}
+		if len(mr1.Histogram) == 0 {
+			mr1.Histogram = nil
+		}
+		if len(mr2.Histogram) == 0 {
+			mr2.Histogram = nil
+		}
 		if !reflect.DeepEqual(mr1, &mr2) {
 			t.Fatalf("mr1 should match mr2; got\nmr1=%s\nmr2=%s", mr1, &mr2)
 		}
@@ -383,6 +391,53 @@ func TestStorageOpenMultipleTimes(t *testing.T) {
 	}
 }
 
+func TestStorageReadOnlyMode(t *testing.T) {
+	path := "TestStorageReadOnlyMode"
+	mn := MetricName{
+		MetricGroup: []byte("foo"),
+	}
+	mrs := []MetricRow{{
+		MetricNameRaw: mn.marshalRaw(nil),
+		Timestamp:     time.Now().UnixNano() / 1e6,
+		Value:         123,
+	}}
+	f := func(freeDiskSpaceLimit int64, isReadOnlyExpected bool) {
+		t.Helper()
+		defer SetFreeDiskSpaceLimit(0)
+		SetFreeDiskSpaceLimit(freeDiskSpaceLimit)
+		s, err := OpenStorage(path, -1, 0, 0)
+		if err != nil {
+			t.Fatalf("cannot open storage: %s", err)
+		}
+		defer s.MustClose()
+		if isReadOnly := s.IsReadOnly(); isReadOnly != isReadOnlyExpected {
+			t.Fatalf("unexpected IsReadOnly(); got %v; want %v", isReadOnly, isReadOnlyExpected)
+		}
+		err = s.AddRows(mrs, defaultPrecisionBits)
+		if isReadOnlyExpected {
+			if !errors.Is(err, ErrReadOnly) {
+				t.Fatalf("expecting ErrReadOnly; got %v", err)
+			}
+			if err := s.RegisterMetricNames(mrs); !errors.Is(err, ErrReadOnly) {
+				t.Fatalf("expecting ErrReadOnly from RegisterMetricNames; got %v", err)
+			}
+			var m Metrics
+			s.UpdateMetrics(&m)
+			if !m.IsReadOnly || m.ReadOnlyRowsRejected != uint64(len(mrs)) {
+				t.Fatalf("unexpected read-only metrics: IsReadOnly=%v, ReadOnlyRowsRejected=%d", m.IsReadOnly, m.ReadOnlyRowsRejected)
+			}
+		} else if err != nil {
+			t.Fatalf("unexpected error when adding rows: %s", err)
+		}
+	}
+	f(1<<62, true)
+	f(1, false)
+	f(0, false)
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
 func TestStorageRandTimestamps(t *testing.T) {
 	path := "TestStorageRandTimestamps"
 	retentionMsecs := int64(60 * msecsPerMonth)
@@ -943,6 +998,11 @@ func testStorageAddRows(s *Storage) error {
 		return fmt.Errorf("cannot create snapshot from the storage: %w", err)
 	}
 
+	// Verify that fresh snapshot isn't deleted as stale.
+	if err := s.DeleteStaleSnapshots(time.Hour); err != nil {
+		return fmt.Errorf("cannot delete stale snapshots: %w", err)
+	}
+
 	// Verify the snapshot is visible
 	snapshots, err := s.ListSnapshots()
 	if err != nil {
@@ -996,6 +1056,11 @@ func testStorageAddRows(s *Storage) error {
 		return fmt.Errorf("snapshot %q must be deleted, but is still visible in %q", snapshotName, snapshots)
 	}
 
+	// Deleting the missing snapshot must fail.
+	if err := s.DeleteSnapshot(snapshotName); err == nil {
+		return fmt.Errorf("expecting non-nil error when deleting missing snapshot %q", snapshotName)
+	}
+
 	return nil
 }
 
@@ -1091,3 +1156,88 @@ func containsString(a []string, s string) bool {
 	}
 	return false
 }
+
+func TestGetSnapshotCreationTime(t *testing.T) {
+	f := func(snapshotName string, tExpected time.Time) {
+		t.Helper()
+		tm, err := getSnapshotCreationTime(snapshotName)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !tm.Equal(tExpected) {
+			t.Fatalf("unexpected creation time for %q; got %s; want %s", snapshotName, tm, tExpected)
+		}
+	}
+	f("20211203101520-16C1F2D5E1A9F0B2", time.Date(2021, 12, 3, 10, 15, 20, 0, time.UTC))
+
+	for _, snapshotName := range []string{"", "foo", "2021-16C1F2D5E1A9F0B2"} {
+		if _, err := getSnapshotCreationTime(snapshotName); err == nil {
+			t.Fatalf("expecting non-nil error for %q", snapshotName)
+		}
+	}
+}
+
+func TestStorageEncryption(t *testing.T) {
+	const path = "TestStorageEncryption"
+	defer func() {
+		_ = encryption.Init("", "")
+		_ = os.RemoveAll(path)
+	}()
+	if err := encryption.Init("", "echo 'k1:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE='"); err != nil {
+		t.Fatalf("cannot initialize encryption: %s", err)
+	}
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	const rowsCount = 1000
+	now := time.Now().UnixNano() / 1e6
+	minTimestamp := now - rowsCount*1000
+	mn := MetricName{
+		MetricGroup: []byte("foo"),
+	}
+	metricNameRaw := mn.marshalRaw(nil)
+	var mrs []MetricRow
+	for i := 0; i < rowsCount; i++ {
+		mrs = append(mrs, MetricRow{
+			MetricNameRaw: metricNameRaw,
+			Timestamp:     minTimestamp + int64(i)*1000,
+			Value:         float64(i),
+		})
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	s.DebugFlush()
+	rowsExpected := map[string]int{
+		"foo": rowsCount,
+	}
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows: %s", err)
+	}
+	s.MustClose()
+
+	// Re-open the storage with rotated key. The storage must remain readable.
+	if err := encryption.Init("", "printf 'k1:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=\\nk2:AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=\\n'"); err != nil {
+		t.Fatalf("cannot initialize encryption: %s", err)
+	}
+	s, err = OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot re-open storage: %s", err)
+	}
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows after re-opening the storage: %s", err)
+	}
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+	if err := testCountStorageRows(s, minTimestamp, now, rowsExpected); err != nil {
+		t.Fatalf("unexpected rows after force merge: %s", err)
+	}
+	s.MustClose()
+
+	// Caches with plaintext metric names mustn't be persisted.
+	if _, err := os.Stat(path + "/cache/metricName_tsid"); !os.IsNotExist(err) {
+		t.Fatalf("cache with metric names mustn't be persisted when encryption is enabled")
+	}
+}
@@ -17,21 +17,48 @@ import (
 
 // table represents a single table with time series data.
 type table struct {
-	path                string
-	smallPartitionsPath string
-	bigPartitionsPath   string
+	// Atomic counters must be at the top of struct for proper 8-byte alignment on 32-bit archs.
+	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/212
+
+	tieringUploads          uint64
+	tieringDownloads        uint64
+	tieringRefusedDownloads uint64
+	tieringIgnoredRows      uint64
+
+	path string
 
-	getDeletedMetricIDs func() *uint64set.Set
-	retentionMsecs      int64
+	// dataPaths contains paths for partitions. The first item is the table path itself,
+	// while the rest of items are located under extra data paths set via SetExtraDataPaths.
+	dataPaths []*tableDataPath
+
+	getDeletedMetricIDs         func() *uint64set.Set
+	getRetentionFilterMetricIDs func() *retentionFilterMetricIDs
+	getDeletedRanges            func() *deletedRanges
+	retentionMsecs              int64
 
 	ptws     []*partitionWrapper
 	ptwsLock sync.Mutex
 
-	flockF *os.File
+	// detaching contains names for partitions, which are being detached via detachPartition.
+	//
+	// It is protected by ptwsLock.
+	detaching map[string]bool
+
+	// tiered contains partitions offloaded to partitionTier.
+	//
+	// tieredLock must be obtained before ptwsLock if both locks are needed.
+	tiered       []*tieredPartition
+	tieredLoaded bool
+	tieredLock   sync.Mutex
+
+	// tieringDownloadLock serializes downloads of tiered partitions.
+	tieringDownloadLock sync.Mutex
 
 	stop chan struct{}
 
 	retentionWatcherWG sync.WaitGroup
+	tieringWorkerWG    sync.WaitGroup
+	tieringDownloadsWG sync.WaitGroup
 }
 
 // partitionWrapper provides refcounting mechanism for the partition.
@@ -45,6 +72,12 @@ type partitionWrapper struct {
 	mustDrop uint64
 
 	pt *partition
+
+	// dropPath is an optional path, which must be removed after dropping the partition.
+	dropPath string
+
+	// detachedCh is closed after the partition is closed if it is detached via table.detachPartition.
+	detachedCh chan struct{}
 }
 
 func (ptw *partitionWrapper) incRef() {
@@ -65,24 +98,36 @@ func (ptw *partitionWrapper) decRef() {
 
 	if atomic.LoadUint64(&ptw.mustDrop) == 0 {
 		ptw.pt = nil
+		if ptw.detachedCh != nil {
+			close(ptw.detachedCh)
+		}
 		return
 	}
 
 	// ptw.mustDrop > 0. Drop the partition.
 	ptw.pt.Drop()
 	ptw.pt = nil
+	if ptw.dropPath != "" {
+		fs.MustRemoveAll(ptw.dropPath)
+	}
 }
 
 func (ptw *partitionWrapper) scheduleToDrop() {
 	atomic.AddUint64(&ptw.mustDrop, 1)
 }
 
-// openTable opens a table on the given path with the given retentionMsecs.
-//
-// The table is created if it doesn't exist.
-//
-// Data older than the retentionMsecs may be dropped at any time.
-func openTable(path string, getDeletedMetricIDs func() *uint64set.Set, retentionMsecs int64) (*table, error) {
+// tableDataPath contains paths for table partitions located at a single data path.
+type tableDataPath struct {
+	path                string
+	smallPartitionsPath string
+	bigPartitionsPath   string
+
+	flockF *os.File
+}
+
+// openTableDataPath creates directories for table partitions at the given path if they don't exist yet
+// and locks the path from concurrent opens.
+func openTableDataPath(path string) (*tableDataPath, error) {
 	path = filepath.Clean(path)
 
 	// Create a directory for the table if it doesn't exist yet.
@@ -99,35 +144,103 @@ func openTable(path string, getDeletedMetricIDs func() *uint64set.Set, retention
 	// Create directories for small and big partitions if they don't exist yet.
 	smallPartitionsPath := path + "/small"
 	if err := fs.MkdirAllIfNotExist(smallPartitionsPath); err != nil {
+		fs.MustClose(flockF)
 		return nil, fmt.Errorf("cannot create directory for small partitions %q: %w", smallPartitionsPath, err)
 	}
 	smallSnapshotsPath := smallPartitionsPath + "/snapshots"
 	if err := fs.MkdirAllIfNotExist(smallSnapshotsPath); err != nil {
+		fs.MustClose(flockF)
 		return nil, fmt.Errorf("cannot create %q: %w", smallSnapshotsPath, err)
 	}
 	bigPartitionsPath := path + "/big"
 	if err := fs.MkdirAllIfNotExist(bigPartitionsPath); err != nil {
+		fs.MustClose(flockF)
 		return nil, fmt.Errorf("cannot create directory for big partitions %q: %w", bigPartitionsPath, err)
 	}
 	bigSnapshotsPath := bigPartitionsPath + "/snapshots"
 	if err := fs.MkdirAllIfNotExist(bigSnapshotsPath); err != nil {
+		fs.MustClose(flockF)
 		return nil, fmt.Errorf("cannot create %q: %w", bigSnapshotsPath, err)
 	}
 
-	// Open partitions.
-	pts, err := openPartitions(smallPartitionsPath, bigPartitionsPath, getDeletedMetricIDs, retentionMsecs)
-	if err != nil {
-		return nil, fmt.Errorf("cannot open partitions in the table %q: %w", path, err)
+	// Remove the local cache for tiered partitions, since it may contain incomplete data after unclean shutdown.
+	tieringPath := path + "/tiering"
+	if fs.IsPathExist(tieringPath) {
+		fs.MustRemoveAll(tieringPath)
 	}
 
-	tb := &table{
+	dp := &tableDataPath{
 		path:                path,
 		smallPartitionsPath: smallPartitionsPath,
 		bigPartitionsPath:   bigPartitionsPath,
-		getDeletedMetricIDs: getDeletedMetricIDs,
-		retentionMsecs:      retentionMsecs,
+		flockF:              flockF,
+	}
+	return dp, nil
+}
+
+func (dp *tableDataPath) mustClose() {
+	// Release exclusive lock on the table data path.
+	if err := dp.flockF.Close(); err != nil {
+		logger.Panicf("FATAL: cannot release lock on %q: %s", dp.flockF.Name(), err)
+	}
+}
+
+func mustCloseTableDataPaths(dps []*tableDataPath) {
+	for _, dp := range dps {
+		dp.mustClose()
+	}
+}
+
+// openTable opens a table on the given path with the given retentionMsecs.
+//
+// The table is created if it doesn't exist.
+//
+// Data older than the retentionMsecs may be dropped at any time.
+func openTable(path string, getDeletedMetricIDs func() *uint64set.Set, getRetentionFilterMetricIDs func() *retentionFilterMetricIDs, getDeletedRanges func() *deletedRanges, retentionMsecs int64) (*table, error) {
+	path = filepath.Clean(path)
+
+	paths := []string{path}
+	for _, extraPath := range extraDataPaths {
+		paths = append(paths, filepath.Clean(extraPath)+"/"+filepath.Base(path))
+	}
+	var dps []*tableDataPath
+	for _, p := range paths {
+		dp, err := openTableDataPath(p)
+		if err != nil {
+			mustCloseTableDataPaths(dps)
+			return nil, err
+		}
+		dps = append(dps, dp)
+	}
+
+	// Open partitions.
+	var pts []*partition
+	ptPaths := make(map[string]string)
+	for _, dp := range dps {
+		ptsLocal, err := openPartitions(dp.smallPartitionsPath, dp.bigPartitionsPath, getDeletedMetricIDs, getRetentionFilterMetricIDs, getDeletedRanges, retentionMsecs)
+		if err != nil {
+			mustClosePartitions(pts)
+			mustCloseTableDataPaths(dps)
+			return nil, fmt.Errorf("cannot open partitions in the table %q: %w", dp.path, err)
+		}
+		pts = append(pts, ptsLocal...)
+		for _, pt := range ptsLocal {
+			if prevPath, ok := ptPaths[pt.name]; ok {
+				mustClosePartitions(pts)
+				mustCloseTableDataPaths(dps)
+				return nil, fmt.Errorf("partition %q exists at multiple data paths: %q and %q; move its data to a single data path", pt.name, prevPath, dp.path)
+			}
+			ptPaths[pt.name] = dp.path
+		}
+	}
 
-		flockF: flockF,
+	tb := &table{
+		path:                        path,
+		dataPaths:                   dps,
+		getDeletedMetricIDs:         getDeletedMetricIDs,
+		getRetentionFilterMetricIDs: getRetentionFilterMetricIDs,
+		getDeletedRanges:            getDeletedRanges,
+		retentionMsecs:              retentionMsecs,
 
 		stop: make(chan struct{}),
 	}
@@ -135,10 +248,86 @@ func openTable(path string, getDeletedMetricIDs func() *uint64set.Set, retention
 		tb.addPartitionNolock(pt)
 	}
 	tb.startRetentionWatcher()
+	tb.startTieringWorker()
 	return tb, nil
 }
 
+// dataPathForNewPartition returns the data path for a new partition.
+//
+// The data path with the most free disk space is returned, so partitions are spread evenly
+// among data paths with similar capacity.
+func (tb *table) dataPathForNewPartition() *tableDataPath {
+	if len(tb.dataPaths) == 1 {
+		return tb.dataPaths[0]
+	}
+	return selectDataPathForNewPartition(tb.dataPaths, fs.MustGetFreeSpace)
+}
+
+// selectDataPathForNewPartition returns the data path with the most free space from dps.
+//
+// Data paths with less than -storage.minFreeDiskSpaceBytes of free space are skipped.
+// The first data path is returned if all the data paths are full, since the storage
+// switches to read-only mode in this case.
+func selectDataPathForNewPartition(dps []*tableDataPath, getFreeSpace func(path string) uint64) *tableDataPath {
+	var dpBest *tableDataPath
+	freeSpaceBest := uint64(0)
+	for _, dp := range dps {
+		freeSpace := getFreeSpace(dp.path)
+		if freeSpace < freeDiskSpaceLimitBytes {
+			continue
+		}
+		if dpBest == nil || freeSpace > freeSpaceBest {
+			dpBest = dp
+			freeSpaceBest = freeSpace
+		}
+	}
+	if dpBest == nil {
+		return dps[0]
+	}
+	return dpBest
+}
+
+// dataPathForPartition returns the data path where the given pt is located.
+func (tb *table) dataPathForPartition(pt *partition) *tableDataPath {
+	smallPartitionsPath := filepath.Dir(pt.smallPartsPath)
+	for _, dp := range tb.dataPaths {
+		if dp.smallPartitionsPath == smallPartitionsPath {
+			return dp
+		}
+	}
+	return tb.dataPaths[0]
+}
+
+// dataPathForPartitionName returns the data path for the partition with the given name.
+//
+// The first data path is returned if tb has no partition with the given name.
+func (tb *table) dataPathForPartitionName(name string) *tableDataPath {
+	tb.ptwsLock.Lock()
+	defer tb.ptwsLock.Unlock()
+	for _, ptw := range tb.ptws {
+		if ptw.pt.name == name {
+			return tb.dataPathForPartition(ptw.pt)
+		}
+	}
+	return tb.dataPaths[0]
+}
+
+// dataPathForDir returns the data path, which contains the given dir.
+//
+// The first data path is returned if dir is located outside data paths.
+func (tb *table) dataPathForDir(dir string) *tableDataPath {
+	for _, dp := range tb.dataPaths[1:] {
+		if strings.HasPrefix(dir, filepath.Dir(dp.path)+"/") {
+			return dp
+		}
+	}
+	return tb.dataPaths[0]
+}
+
 // CreateSnapshot creates tb snapshot and returns paths to small and big parts of it.
+//
+// Snapshots for partitions located at extra data paths are created at these data paths, since hard links
+// cannot be created across filesystems. Such snapshots are referred by symlinks from the returned paths.
 func (tb *table) CreateSnapshot(snapshotName string) (string, string, error) {
 	logger.Infof("creating table snapshot of %q...", tb.path)
 	startTime := time.Now()
@@ -147,26 +336,43 @@ func (tb *table) CreateSnapshot(snapshotName string) (string, string, error) {
 	defer tb.PutPartitions(ptws)
 
 	dstSmallDir := fmt.Sprintf("%s/small/snapshots/%s", tb.path, snapshotName)
-	if err := fs.MkdirAllFailIfExist(dstSmallDir); err != nil {
-		return "", "", fmt.Errorf("cannot create dir %q: %w", dstSmallDir, err)
-	}
 	dstBigDir := fmt.Sprintf("%s/big/snapshots/%s", tb.path, snapshotName)
-	if err := fs.MkdirAllFailIfExist(dstBigDir); err != nil {
-		return "", "", fmt.Errorf("cannot create dir %q: %w", dstBigDir, err)
+	for _, dp := range tb.dataPaths {
+		smallDir := fmt.Sprintf("%s/snapshots/%s", dp.smallPartitionsPath, snapshotName)
+		if err := fs.MkdirAllFailIfExist(smallDir); err != nil {
+			return "", "", fmt.Errorf("cannot create dir %q: %w", smallDir, err)
+		}
+		bigDir := fmt.Sprintf("%s/snapshots/%s", dp.bigPartitionsPath, snapshotName)
+		if err := fs.MkdirAllFailIfExist(bigDir); err != nil {
+			return "", "", fmt.Errorf("cannot create dir %q: %w", bigDir, err)
+		}
 	}
 
 	for _, ptw := range ptws {
-		smallPath := dstSmallDir + "/" + ptw.pt.name
-		bigPath := dstBigDir + "/" + ptw.pt.name
+		dp := tb.dataPathForPartition(ptw.pt)
+		smallPath := fmt.Sprintf("%s/snapshots/%s/%s", dp.smallPartitionsPath, snapshotName, ptw.pt.name)
+		bigPath := fmt.Sprintf("%s/snapshots/%s/%s", dp.bigPartitionsPath, snapshotName, ptw.pt.name)
 		if err := ptw.pt.CreateSnapshotAt(smallPath, bigPath); err != nil {
-			return "", "", fmt.Errorf("cannot create snapshot for partition %q in %q: %w", ptw.pt.name, tb.path, err)
+			return "", "", fmt.Errorf("cannot create snapshot for partition %q in %q: %w", ptw.pt.name, dp.path, err)
+		}
+		if dp == tb.dataPaths[0] {
+			continue
+		}
+		for _, paths := range [][2]string{{smallPath, dstSmallDir + "/" + ptw.pt.name}, {bigPath, dstBigDir + "/" + ptw.pt.name}} {
+			if err := fs.SymlinkRelative(paths[0], paths[1]); err != nil {
+				return "", "", fmt.Errorf("cannot create symlink from %q to %q: %w", paths[0], paths[1], err)
+			}
 		}
 	}
 
-	fs.MustSyncPath(dstSmallDir)
-	fs.MustSyncPath(dstBigDir)
-	fs.MustSyncPath(filepath.Dir(dstSmallDir))
-	fs.MustSyncPath(filepath.Dir(dstBigDir))
+	for _, dp := range tb.dataPaths {
+		smallDir := fmt.Sprintf("%s/snapshots/%s", dp.smallPartitionsPath, snapshotName)
+		bigDir := fmt.Sprintf("%s/snapshots/%s", dp.bigPartitionsPath, snapshotName)
+		fs.MustSyncPath(smallDir)
+		fs.MustSyncPath(bigDir)
+		fs.MustSyncPath(filepath.Dir(smallDir))
+		fs.MustSyncPath(filepath.Dir(bigDir))
+	}
 
 	logger.Infof("created table snapshot for %q at (%q, %q) in %.3f seconds", tb.path, dstSmallDir, dstBigDir, time.Since(startTime).Seconds())
 	return dstSmallDir, dstBigDir, nil
@@ -174,10 +380,12 @@ func (tb *table) CreateSnapshot(snapshotName string) (string, string, error) {
 
 // MustDeleteSnapshot deletes snapshot with the given snapshotName.
 func (tb *table) MustDeleteSnapshot(snapshotName string) {
-	smallDir := fmt.Sprintf("%s/small/snapshots/%s", tb.path, snapshotName)
-	fs.MustRemoveAll(smallDir)
-	bigDir := fmt.Sprintf("%s/big/snapshots/%s", tb.path, snapshotName)
-	fs.MustRemoveAll(bigDir)
+	for _, dp := range tb.dataPaths {
+		smallDir := fmt.Sprintf("%s/snapshots/%s", dp.smallPartitionsPath, snapshotName)
+		fs.MustRemoveAll(smallDir)
+		bigDir := fmt.Sprintf("%s/snapshots/%s", dp.bigPartitionsPath, snapshotName)
+		fs.MustRemoveAll(bigDir)
+	}
 }
 
 func (tb *table) addPartitionNolock(pt *partition) {
@@ -193,6 +401,9 @@ func (tb *table) addPartitionNolock(pt *partition) {
 func (tb *table) MustClose() {
 	close(tb.stop)
 	tb.retentionWatcherWG.Wait()
+	tb.tieringWorkerWG.Wait()
+	tb.tieringDownloadsWG.Wait()
+	tb.mustCloseTieredPartitions()
 
 	tb.ptwsLock.Lock()
 	ptws := tb.ptws
@@ -206,10 +417,7 @@ func (tb *table) MustClose() {
 		ptw.decRef()
 	}
 
-	// Release exclusive lock on the table.
-	if err := tb.flockF.Close(); err != nil {
-		logger.Panicf("FATAL: cannot release lock on %q: %s", tb.flockF.Name(), err)
-	}
+	mustCloseTableDataPaths(tb.dataPaths)
 }
 
 // flushRawRows flushes all the pending rows, so they become visible to search.
@@ -229,6 +437,14 @@ type TableMetrics struct {
 	partitionMetrics
 
 	PartitionsRefCount uint64
+
+	TieredPartitions        uint64
+	TieringCachedPartitions uint64
+	TieringCacheSizeBytes   uint64
+	TieringUploads          uint64
+	TieringDownloads        uint64
+	TieringRefusedDownloads uint64
+	TieringIgnoredRows      uint64
 }
 
 // UpdateMetrics updates m with metrics from tb.
@@ -239,6 +455,8 @@ func (tb *table) UpdateMetrics(m *TableMetrics) {
 		m.PartitionsRefCount += atomic.LoadUint64(&ptw.refCount)
 	}
 	tb.ptwsLock.Unlock()
+
+	tb.updateTieringMetrics(m)
 }
 
 // ForceMergePartitions force-merges partitions in tb with names starting from the given partitionNamePrefix.
@@ -263,6 +481,9 @@ func (tb *table) ForceMergePartitions(partitionNamePrefix string) error {
 
 // AddRows adds the given rows to the table tb.
 func (tb *table) AddRows(rows []rawRow) error {
+	if partitionTier != nil {
+		rows = tb.filterTieredRows(rows)
+	}
 	if len(rows) == 0 {
 		return nil
 	}
@@ -356,8 +577,14 @@ func (tb *table) AddRows(rows []rawRow) error {
 		if ptFound {
 			continue
 		}
+		ptName := tb.partitionNameForTimestamp(r.Timestamp)
+		if tb.detaching[ptName] {
+			errors = append(errors, fmt.Errorf("cannot add rows to partition %q, since it is being detached", ptName))
+			continue
+		}
 
-		pt, err := createPartition(r.Timestamp, tb.smallPartitionsPath, tb.bigPartitionsPath, tb.getDeletedMetricIDs, tb.retentionMsecs)
+		dp := tb.dataPathForNewPartition()
+		pt, err := createPartition(ptName, dp.smallPartitionsPath, dp.bigPartitionsPath, tb.getDeletedMetricIDs, tb.getRetentionFilterMetricIDs, tb.getDeletedRanges, tb.retentionMsecs)
 		if err != nil {
 			errors = append(errors, err)
 			continue
@@ -374,6 +601,17 @@ func (tb *table) AddRows(rows []rawRow) error {
 	return nil
 }
 
+// partitionNameForTimestamp returns the name for a new partition, which must contain the given timestamp.
+//
+// Per-day partitions are created if the retention is shorter than a month, so the data outside the retention
+// is dropped with one day granularity instead of keeping it on disk until the whole month goes out of the retention.
+func (tb *table) partitionNameForTimestamp(timestamp int64) string {
+	if tb.retentionMsecs < msecsPerMonth {
+		return timestampToDailyPartitionName(timestamp)
+	}
+	return timestampToPartitionName(timestamp)
+}
+
 func (tb *table) getMinMaxTimestamps() (int64, int64) {
 	now := int64(fasttime.UnixTimestamp() * 1000)
 	minTimestamp := now - tb.retentionMsecs
@@ -457,7 +695,7 @@ func (tb *table) PutPartitions(ptws []*partitionWrapper) {
 	}
 }
 
-func openPartitions(smallPartitionsPath, bigPartitionsPath string, getDeletedMetricIDs func() *uint64set.Set, retentionMsecs int64) ([]*partition, error) {
+func openPartitions(smallPartitionsPath, bigPartitionsPath string, getDeletedMetricIDs func() *uint64set.Set, getRetentionFilterMetricIDs func() *retentionFilterMetricIDs, getDeletedRanges func() *deletedRanges, retentionMsecs int64) ([]*partition, error) {
 	// Certain partition directories in either `big` or `small` dir may be missing
 	// after restoring from backup. So populate partition names from both dirs.
 	ptNames := make(map[string]bool)
@@ -471,7 +709,7 @@ func openPartitions(smallPartitionsPath, bigPartitionsPath string, getDeletedMet
 	for ptName := range ptNames {
 		smallPartsPath := smallPartitionsPath + "/" + ptName
 		bigPartsPath := bigPartitionsPath + "/" + ptName
-		pt, err := openPartition(smallPartsPath, bigPartsPath, getDeletedMetricIDs, retentionMsecs)
+		pt, err := openPartition(smallPartsPath, bigPartsPath, getDeletedMetricIDs, getRetentionFilterMetricIDs, getDeletedRanges, retentionMsecs)
 		if err != nil {
 			mustClosePartitions(pts)
 			return nil, fmt.Errorf("cannot open partition %q: %w", ptName, err)
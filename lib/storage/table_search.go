@@ -57,8 +57,10 @@ func (ts *tableSearch) reset() {
 // tsids must be sorted.
 // tsids cannot be modified after the Init call, since it is owned by ts.
 //
+// deadline is the deadline in unix timestamp seconds for downloading tiered partitions needed for the search.
+//
 // MustClose must be called then the tableSearch is done.
-func (ts *tableSearch) Init(tb *table, tsids []TSID, tr TimeRange) {
+func (ts *tableSearch) Init(tb *table, tsids []TSID, tr TimeRange, deadline uint64) {
 	if ts.needClosing {
 		logger.Panicf("BUG: missing MustClose call before the next call to Init")
 	}
@@ -81,7 +83,12 @@ func (ts *tableSearch) Init(tb *table, tsids []TSID, tr TimeRange) {
 		return
 	}
 
-	ts.ptws = tb.GetPartitions(ts.ptws[:0])
+	ptws, err := tb.getPartitionsForSearch(ts.ptws[:0], tr, deadline)
+	ts.ptws = ptws
+	if err != nil {
+		ts.err = fmt.Errorf("cannot obtain partitions for search: %w", err)
+		return
+	}
 
 	// Initialize the ptsPool.
 	if n := len(ts.ptws) - cap(ts.ptsPool); n > 0 {
@@ -48,7 +48,7 @@ func openBenchTable(b *testing.B, startTimestamp int64, rowsPerInsert, rowsCount
 		createBenchTable(b, path, startTimestamp, rowsPerInsert, rowsCount, tsidsCount)
 		createdBenchTables[path] = true
 	}
-	tb, err := openTable(path, nilGetDeletedMetricIDs, maxRetentionMsecs)
+	tb, err := openTable(path, nilGetDeletedMetricIDs, nil, nil, maxRetentionMsecs)
 	if err != nil {
 		b.Fatalf("cnanot open table %q: %s", path, err)
 	}
@@ -71,7 +71,7 @@ var createdBenchTables = make(map[string]bool)
 func createBenchTable(b *testing.B, path string, startTimestamp int64, rowsPerInsert, rowsCount, tsidsCount int) {
 	b.Helper()
 
-	tb, err := openTable(path, nilGetDeletedMetricIDs, maxRetentionMsecs)
+	tb, err := openTable(path, nilGetDeletedMetricIDs, nil, nil, maxRetentionMsecs)
 	if err != nil {
 		b.Fatalf("cannot open table %q: %s", path, err)
 	}
@@ -133,7 +133,7 @@ func benchmarkTableSearch(b *testing.B, rowsCount, tsidsCount, tsidsSearch int,
 			for i := range tsids {
 				tsids[i].MetricID = 1 + uint64(i)
 			}
-			ts.Init(tb, tsids, tr)
+			ts.Init(tb, tsids, tr, noDeadline)
 			for ts.NextBlock() {
 				ts.BlockRef.MustReadBlock(&tmpBlock, fetchData)
 			}
@@ -2,7 +2,12 @@ package storage
 
 import (
 	"os"
+	"reflect"
+	"sort"
 	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 )
 
 func TestTableOpenClose(t *testing.T) {
@@ -17,7 +22,7 @@ func TestTableOpenClose(t *testing.T) {
 	}()
 
 	// Create a new table
-	tb, err := openTable(path, nilGetDeletedMetricIDs, retentionMsecs)
+	tb, err := openTable(path, nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
 	if err != nil {
 		t.Fatalf("cannot create new table: %s", err)
 	}
@@ -27,7 +32,7 @@ func TestTableOpenClose(t *testing.T) {
 
 	// Re-open created table multiple times.
 	for i := 0; i < 10; i++ {
-		tb, err := openTable(path, nilGetDeletedMetricIDs, retentionMsecs)
+		tb, err := openTable(path, nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
 		if err != nil {
 			t.Fatalf("cannot open created table: %s", err)
 		}
@@ -43,17 +48,195 @@ func TestTableOpenMultipleTimes(t *testing.T) {
 		_ = os.RemoveAll(path)
 	}()
 
-	tb1, err := openTable(path, nilGetDeletedMetricIDs, retentionMsecs)
+	tb1, err := openTable(path, nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
 	if err != nil {
 		t.Fatalf("cannot open table the first time: %s", err)
 	}
 	defer tb1.MustClose()
 
 	for i := 0; i < 10; i++ {
-		tb2, err := openTable(path, nilGetDeletedMetricIDs, retentionMsecs)
+		tb2, err := openTable(path, nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
 		if err == nil {
 			tb2.MustClose()
 			t.Fatalf("expecting non-nil error when opening already opened table")
 		}
 	}
 }
+
+func TestTableDailyPartitions(t *testing.T) {
+	const path = "TestTableDailyPartitions"
+	const retentionMsecs = 3 * msecPerDay
+
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+
+	tb, err := openTable(path, nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
+	if err != nil {
+		t.Fatalf("cannot create new table: %s", err)
+	}
+	timestamp := int64(fasttime.UnixTimestamp() * 1000)
+	rows := []rawRow{
+		{
+			TSID:          TSID{MetricID: 1},
+			Timestamp:     timestamp,
+			PrecisionBits: defaultPrecisionBits,
+		},
+		{
+			TSID:          TSID{MetricID: 1},
+			Timestamp:     timestamp - msecPerDay,
+			PrecisionBits: defaultPrecisionBits,
+		},
+	}
+	if err := tb.AddRows(rows); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	checkPartitions := func(tb *table) {
+		t.Helper()
+		var names []string
+		for _, ptw := range tb.ptws {
+			names = append(names, ptw.pt.name)
+		}
+		sort.Strings(names)
+		namesExpected := []string{
+			timestampToDailyPartitionName(timestamp - msecPerDay),
+			timestampToDailyPartitionName(timestamp),
+		}
+		if !reflect.DeepEqual(names, namesExpected) {
+			t.Fatalf("unexpected partitions; got %q; want %q", names, namesExpected)
+		}
+	}
+	checkPartitions(tb)
+	tb.MustClose()
+
+	// Daily partitions must be opened after the restart.
+	tb, err = openTable(path, nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
+	if err != nil {
+		t.Fatalf("cannot open table: %s", err)
+	}
+	checkPartitions(tb)
+	tb.MustClose()
+}
+
+func TestSelectDataPathForNewPartition(t *testing.T) {
+	defer SetFreeDiskSpaceLimit(0)
+	SetFreeDiskSpaceLimit(100)
+
+	dps := []*tableDataPath{{path: "a"}, {path: "b"}, {path: "c"}}
+	f := func(freeSpaces map[string]uint64, pathExpected string) {
+		t.Helper()
+		dp := selectDataPathForNewPartition(dps, func(path string) uint64 {
+			return freeSpaces[path]
+		})
+		if dp.path != pathExpected {
+			t.Fatalf("unexpected data path; got %q; want %q", dp.path, pathExpected)
+		}
+	}
+	f(map[string]uint64{"a": 200, "b": 300, "c": 250}, "b")
+	f(map[string]uint64{"a": 200, "b": 50, "c": 150}, "a")
+
+	// the first path must be returned if all the paths are full
+	f(map[string]uint64{"a": 10, "b": 50, "c": 20}, "a")
+}
+
+func TestTableMultipleDataPaths(t *testing.T) {
+	const rootPath = "TestTableMultipleDataPaths"
+	const path = rootPath + "/primary/data"
+	const extraPath = rootPath + "/extra"
+	const retentionMsecs = 3 * msecPerDay
+
+	if err := os.RemoveAll(rootPath); err != nil {
+		t.Fatalf("cannot remove %q: %s", rootPath, err)
+	}
+	defer func() {
+		_ = os.RemoveAll(rootPath)
+	}()
+	SetExtraDataPaths([]string{extraPath})
+	defer SetExtraDataPaths(nil)
+
+	tb, err := openTable(path, nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
+	if err != nil {
+		t.Fatalf("cannot create new table: %s", err)
+	}
+	if len(tb.dataPaths) != 2 {
+		t.Fatalf("unexpected number of data paths; got %d; want 2", len(tb.dataPaths))
+	}
+	timestamp := int64(fasttime.UnixTimestamp() * 1000)
+	rows := []rawRow{
+		{
+			TSID:          TSID{MetricID: 1},
+			Timestamp:     timestamp,
+			PrecisionBits: defaultPrecisionBits,
+		},
+		{
+			TSID:          TSID{MetricID: 1},
+			Timestamp:     timestamp - msecPerDay,
+			PrecisionBits: defaultPrecisionBits,
+		},
+	}
+	if err := tb.AddRows(rows); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	tb.MustClose()
+	pendingTxnDeletionsWG.Wait()
+
+	// Move a partition to the extra data path.
+	ptName := timestampToDailyPartitionName(timestamp - msecPerDay)
+	for _, kind := range []string{"small", "big"} {
+		srcPath := path + "/" + kind + "/" + ptName
+		dstPath := extraPath + "/data/" + kind + "/" + ptName
+		if err := os.Rename(srcPath, dstPath); err != nil {
+			t.Fatalf("cannot move %q to %q: %s", srcPath, dstPath, err)
+		}
+	}
+
+	// Partitions from all the data paths must be opened.
+	tb, err = openTable(path, nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
+	if err != nil {
+		t.Fatalf("cannot open table: %s", err)
+	}
+	var names []string
+	for _, ptw := range tb.ptws {
+		names = append(names, ptw.pt.name)
+		dpExpected := tb.dataPaths[0]
+		if ptw.pt.name == ptName {
+			dpExpected = tb.dataPaths[1]
+		}
+		if dp := tb.dataPathForPartition(ptw.pt); dp != dpExpected {
+			t.Fatalf("unexpected data path for partition %q; got %q; want %q", ptw.pt.name, dp.path, dpExpected.path)
+		}
+	}
+	sort.Strings(names)
+	namesExpected := []string{
+		ptName,
+		timestampToDailyPartitionName(timestamp),
+	}
+	if !reflect.DeepEqual(names, namesExpected) {
+		t.Fatalf("unexpected partitions; got %q; want %q", names, namesExpected)
+	}
+
+	// The snapshot for the partition at the extra data path must be created there and must be available from the table snapshot.
+	smallDir, _, err := tb.CreateSnapshot("snapshot")
+	if err != nil {
+		t.Fatalf("cannot create snapshot: %s", err)
+	}
+	fi, err := os.Lstat(smallDir + "/" + ptName)
+	if err != nil {
+		t.Fatalf("cannot find partition snapshot: %s", err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("partition snapshot at the extra data path must be a symlink")
+	}
+	extraSnapshotPath := extraPath + "/data/small/snapshots/snapshot"
+	if !fs.IsPathExist(extraSnapshotPath + "/" + ptName) {
+		t.Fatalf("cannot find partition snapshot at %q", extraSnapshotPath)
+	}
+	tb.MustDeleteSnapshot("snapshot")
+	if fs.IsPathExist(smallDir) || fs.IsPathExist(extraSnapshotPath) {
+		t.Fatalf("snapshot must be deleted at all the data paths")
+	}
+	tb.MustClose()
+}
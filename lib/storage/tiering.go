@@ -0,0 +1,564 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// PartitionTier is a remote storage for partitions offloaded from the local disk.
+//
+// Every offloaded partition is identified by a unique id in the form <name>_<suffix>,
+// where <name> is the partition name in the form YYYY_MM or YYYY_MM_DD.
+type PartitionTier interface {
+	// UploadPartition must upload the partition from localDir under the given id.
+	//
+	// localDir contains small/<name> and big/<name> subdirectories with the partition parts.
+	// The partition must become visible to ListPartitions only after the upload is complete.
+	UploadPartition(id, localDir string) error
+
+	// DownloadPartition must download the partition with the given id to localDir.
+	DownloadPartition(id, localDir string) error
+
+	// PartitionSize must return the size in bytes for the partition with the given id.
+	PartitionSize(id string) (uint64, error)
+
+	// ListPartitions must return ids for all the completely uploaded partitions.
+	ListPartitions() ([]string, error)
+
+	// DeletePartition must delete the partition with the given id.
+	DeletePartition(id string) error
+}
+
+var (
+	partitionTier         PartitionTier
+	tieringAgeMsecs       int64
+	tieringCacheSizeBytes uint64
+)
+
+// SetPartitionTier enables offloading of partitions older than ageMsecs to the given tier.
+//
+// Offloaded partitions are downloaded on demand to the local cache, which may occupy up to cacheSizeBytes
+// of disk space. Tiering is disabled if tier is nil.
+//
+// This function must be called before initializing the storage.
+func SetPartitionTier(tier PartitionTier, ageMsecs int64, cacheSizeBytes uint64) {
+	partitionTier = tier
+	tieringAgeMsecs = ageMsecs
+	tieringCacheSizeBytes = cacheSizeBytes
+}
+
+// tieringDeadline returns the maximum timestamp for data, which may be offloaded to partitionTier.
+func tieringDeadline() int64 {
+	return int64(fasttime.UnixTimestamp()*1000) - tieringAgeMsecs
+}
+
+// tieredPartition is a partition offloaded to partitionTier.
+type tieredPartition struct {
+	id string
+	tr TimeRange
+
+	// The following fields are protected by table.tieredLock.
+
+	// ptw is the partition from the local cache. It is nil if the partition isn't cached.
+	ptw *partitionWrapper
+
+	// download is the in-progress download of the partition to the local cache.
+	download *tieredDownload
+
+	// lastAccessTime is the last time in seconds when the cached partition was used by search.
+	lastAccessTime uint64
+
+	// dropped is set when the partition is removed from table.tiered.
+	dropped bool
+}
+
+var tieringIdx = uint64(time.Now().UnixNano())
+
+func nextTieringIdx() uint64 {
+	return atomic.AddUint64(&tieringIdx, 1)
+}
+
+func newTieredPartitionID(ptName string) string {
+	return fmt.Sprintf("%s_%016X", ptName, nextTieringIdx())
+}
+
+// tieredDownload is a download of tieredPartition to the local cache.
+type tieredDownload struct {
+	// doneCh is closed when the download is finished.
+	doneCh chan struct{}
+
+	// err is the download error. It may be read only after doneCh is closed.
+	err error
+}
+
+func newTieredPartition(id string) (*tieredPartition, error) {
+	if n := strings.LastIndexByte(id, '_'); n < len("YYYY_MM") {
+		return nil, fmt.Errorf("unexpected tiered partition id %q; want <name>_<suffix>", id)
+	}
+	tp := &tieredPartition{
+		id: id,
+	}
+	if err := tp.tr.fromPartitionName(tp.name()); err != nil {
+		return nil, fmt.Errorf("cannot parse tiered partition id %q: %w", id, err)
+	}
+	return tp, nil
+}
+
+// name returns partition name for tp.
+func (tp *tieredPartition) name() string {
+	n := strings.LastIndexByte(tp.id, '_')
+	return tp.id[:n]
+}
+
+// overlapsWith returns true if tp contains data for the given tr.
+func (tp *tieredPartition) overlapsWith(tr TimeRange) bool {
+	return tp.tr.MinTimestamp <= tr.MaxTimestamp && tr.MinTimestamp <= tp.tr.MaxTimestamp
+}
+
+func (tb *table) startTieringWorker() {
+	if partitionTier == nil {
+		return
+	}
+	tb.tieringWorkerWG.Add(1)
+	go func() {
+		tb.tieringWorker()
+		tb.tieringWorkerWG.Done()
+	}()
+}
+
+func (tb *table) tieringWorker() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := tb.loadTieredPartitions(); err != nil {
+			logger.Errorf("cannot load tiered partitions for %q: %s", tb.path, err)
+		} else {
+			tb.dropStaleTieredPartitions()
+			tb.offloadOldPartitions()
+			tb.evictTieredPartitions(0)
+		}
+		select {
+		case <-tb.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadTieredPartitions loads the list of tiered partitions from partitionTier if it isn't loaded yet.
+func (tb *table) loadTieredPartitions() error {
+	tb.tieredLock.Lock()
+	loaded := tb.tieredLoaded
+	tb.tieredLock.Unlock()
+	if loaded {
+		return nil
+	}
+
+	ids, err := partitionTier.ListPartitions()
+	if err != nil {
+		return fmt.Errorf("cannot list tiered partitions: %w", err)
+	}
+	var tps []*tieredPartition
+	for _, id := range ids {
+		tp, err := newTieredPartition(id)
+		if err != nil {
+			logger.Errorf("skipping tiered partition: %s", err)
+			continue
+		}
+		tps = append(tps, tp)
+	}
+
+	tb.tieredLock.Lock()
+	if !tb.tieredLoaded {
+		tb.tiered = append(tb.tiered, tps...)
+		tb.tieredLoaded = true
+	}
+	tb.tieredLock.Unlock()
+	return nil
+}
+
+// dropStaleTieredPartitions deletes tiered partitions outside the retention.
+func (tb *table) dropStaleTieredPartitions() {
+	minTimestamp := int64(fasttime.UnixTimestamp()*1000) - tb.retentionMsecs
+	var tpsDrop []*tieredPartition
+	var ptwsDrop []*partitionWrapper
+	tb.tieredLock.Lock()
+	dst := tb.tiered[:0]
+	for _, tp := range tb.tiered {
+		if tp.tr.MaxTimestamp >= minTimestamp {
+			dst = append(dst, tp)
+			continue
+		}
+		tp.dropped = true
+		if tp.ptw != nil {
+			ptwsDrop = append(ptwsDrop, tp.ptw)
+			tp.ptw = nil
+		}
+		tpsDrop = append(tpsDrop, tp)
+	}
+	tb.tiered = dst
+	tb.tieredLock.Unlock()
+
+	for _, ptw := range ptwsDrop {
+		ptw.scheduleToDrop()
+		ptw.decRef()
+	}
+	for _, tp := range tpsDrop {
+		// Tiered partitions left after unsuccessful deletion are deleted after the next restart.
+		if err := partitionTier.DeletePartition(tp.id); err != nil {
+			logger.Errorf("cannot delete tiered partition %q outside the retention: %s", tp.id, err)
+			continue
+		}
+		logger.Infof("deleted tiered partition %q outside the retention", tp.id)
+	}
+}
+
+// offloadOldPartitions uploads local partitions older than tieringAgeMsecs to partitionTier
+// and then drops them from the local disk.
+func (tb *table) offloadOldPartitions() {
+	deadline := tieringDeadline()
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+	for _, ptw := range ptws {
+		if ptw.pt.tr.MaxTimestamp >= deadline {
+			continue
+		}
+		select {
+		case <-tb.stop:
+			return
+		default:
+		}
+		if err := tb.offloadPartition(ptw); err != nil {
+			logger.Errorf("cannot offload partition %q to tiered storage: %s", ptw.pt.name, err)
+		}
+	}
+}
+
+func (tb *table) offloadPartition(ptw *partitionWrapper) error {
+	pt := ptw.pt
+	id := newTieredPartitionID(pt.name)
+	logger.Infof("offloading partition %q to tiered storage under id %q", pt.name, id)
+	startTime := time.Now()
+
+	// The partition doesn't accept new rows, since they are older than tieringAgeMsecs.
+	// So it is safe uploading its snapshot.
+	// The snapshot is created at the data path with the partition, since hard links cannot be created across filesystems.
+	uploadDir := tb.dataPathForPartition(pt).path + "/tiering/upload/" + id
+	defer fs.MustRemoveAll(uploadDir)
+	if err := pt.CreateSnapshotAt(uploadDir+"/small/"+pt.name, uploadDir+"/big/"+pt.name); err != nil {
+		return fmt.Errorf("cannot create snapshot: %w", err)
+	}
+	if err := partitionTier.UploadPartition(id, uploadDir); err != nil {
+		return fmt.Errorf("cannot upload partition: %w", err)
+	}
+	atomic.AddUint64(&tb.tieringUploads, 1)
+
+	tp := &tieredPartition{
+		id: id,
+		tr: pt.tr,
+	}
+	tb.tieredLock.Lock()
+	tb.ptwsLock.Lock()
+	found := false
+	dst := tb.ptws[:0]
+	for _, x := range tb.ptws {
+		if x == ptw {
+			found = true
+			continue
+		}
+		dst = append(dst, x)
+	}
+	tb.ptws = dst
+	tb.ptwsLock.Unlock()
+	tb.tiered = append(tb.tiered, tp)
+	tb.tieredLock.Unlock()
+
+	if found {
+		// Remove table reference from the partition, so it will be eventually
+		// closed and dropped after all the pending searches are done.
+		ptw.scheduleToDrop()
+		ptw.decRef()
+	}
+	logger.Infof("offloaded partition %q to tiered storage under id %q in %.3f seconds", pt.name, id, time.Since(startTime).Seconds())
+	return nil
+}
+
+// evictTieredPartitions removes the least recently used tiered partitions from the local cache
+// until its size plus reserveBytes becomes smaller than tieringCacheSizeBytes.
+//
+// Partitions accessed during the last minute aren't evicted in order to avoid repeated downloads for active queries.
+// false is returned if the cache has no enough space for reserveBytes after the eviction.
+func (tb *table) evictTieredPartitions(reserveBytes uint64) bool {
+	type cachedPartition struct {
+		tp        *tieredPartition
+		sizeBytes uint64
+	}
+	var cps []cachedPartition
+	var sizeBytes uint64
+	tb.tieredLock.Lock()
+	for _, tp := range tb.tiered {
+		if tp.ptw == nil {
+			continue
+		}
+		n := tp.ptw.sizeBytes()
+		cps = append(cps, cachedPartition{
+			tp:        tp,
+			sizeBytes: n,
+		})
+		sizeBytes += n
+	}
+	sort.Slice(cps, func(i, j int) bool {
+		return cps[i].tp.lastAccessTime < cps[j].tp.lastAccessTime
+	})
+	minAccessTime := fasttime.UnixTimestamp() - 60
+	var ptwsDrop []*partitionWrapper
+	for _, cp := range cps {
+		if sizeBytes+reserveBytes <= tieringCacheSizeBytes || cp.tp.lastAccessTime > minAccessTime {
+			break
+		}
+		ptwsDrop = append(ptwsDrop, cp.tp.ptw)
+		cp.tp.ptw = nil
+		sizeBytes -= cp.sizeBytes
+	}
+	tb.tieredLock.Unlock()
+
+	for _, ptw := range ptwsDrop {
+		ptw.scheduleToDrop()
+		ptw.decRef()
+	}
+	return sizeBytes+reserveBytes <= tieringCacheSizeBytes
+}
+
+// startTieredPartitionDownload starts downloading tp to the local cache in background.
+//
+// nil is returned if tp is already cached. Otherwise the returned download may be shared with concurrent searches.
+func (tb *table) startTieredPartitionDownload(tp *tieredPartition) *tieredDownload {
+	tb.tieredLock.Lock()
+	defer tb.tieredLock.Unlock()
+	if tp.ptw != nil || tp.dropped {
+		return nil
+	}
+	if tp.download != nil {
+		return tp.download
+	}
+	d := &tieredDownload{
+		doneCh: make(chan struct{}),
+	}
+	tp.download = d
+	tb.tieringDownloadsWG.Add(1)
+	go func() {
+		defer tb.tieringDownloadsWG.Done()
+		d.err = tb.downloadTieredPartition(tp)
+		tb.tieredLock.Lock()
+		tp.download = nil
+		tb.tieredLock.Unlock()
+		close(d.doneCh)
+	}()
+	return d
+}
+
+// waitForTieredDownload waits until d is finished or the given deadline in unix timestamp seconds is exceeded.
+//
+// The download continues in background after the deadline is exceeded, so the partition becomes available to subsequent searches.
+func waitForTieredDownload(tp *tieredPartition, d *tieredDownload, deadline uint64) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.doneCh:
+			return d.err
+		case <-ticker.C:
+			if fasttime.UnixTimestamp() > deadline {
+				return fmt.Errorf("timeout when downloading tiered partition %q; the download continues in background, so try repeating the query later: %w",
+					tp.id, ErrDeadlineExceeded)
+			}
+		}
+	}
+}
+
+// downloadTieredPartition downloads tp to the local cache.
+//
+// Downloads are performed one by one in order to fit -storage.tieringCacheSize.
+// The download is refused if the cache has no enough space for tp after evicting partitions, which aren't used by queries.
+func (tb *table) downloadTieredPartition(tp *tieredPartition) error {
+	tb.tieringDownloadLock.Lock()
+	defer tb.tieringDownloadLock.Unlock()
+
+	select {
+	case <-tb.stop:
+		return fmt.Errorf("cannot download tiered partition %q, since the storage is stopped", tp.id)
+	default:
+	}
+	sizeBytes, err := partitionTier.PartitionSize(tp.id)
+	if err != nil {
+		return fmt.Errorf("cannot obtain the size of tiered partition %q: %w", tp.id, err)
+	}
+	if sizeBytes > tieringCacheSizeBytes {
+		atomic.AddUint64(&tb.tieringRefusedDownloads, 1)
+		return fmt.Errorf("cannot download tiered partition %q with size %d bytes, since it exceeds -storage.tieringCacheSize=%d bytes",
+			tp.id, sizeBytes, tieringCacheSizeBytes)
+	}
+	if !tb.evictTieredPartitions(sizeBytes) {
+		atomic.AddUint64(&tb.tieringRefusedDownloads, 1)
+		return fmt.Errorf("cannot download tiered partition %q with size %d bytes, since -storage.tieringCacheSize=%d bytes is occupied "+
+			"by partitions used by queries during the last minute; try repeating the query later", tp.id, sizeBytes, tieringCacheSizeBytes)
+	}
+
+	logger.Infof("downloading tiered partition %q", tp.id)
+	startTime := time.Now()
+	cacheDir := fmt.Sprintf("%s/tiering/cache/%s_%016X", tb.path, tp.id, nextTieringIdx())
+	if err := partitionTier.DownloadPartition(tp.id, cacheDir); err != nil {
+		fs.MustRemoveAll(cacheDir)
+		return fmt.Errorf("cannot download tiered partition %q: %w", tp.id, err)
+	}
+	name := tp.name()
+	pt, err := openPartition(cacheDir+"/small/"+name, cacheDir+"/big/"+name, tb.getDeletedMetricIDs, tb.getRetentionFilterMetricIDs, tb.getDeletedRanges, tb.retentionMsecs)
+	if err != nil {
+		fs.MustRemoveAll(cacheDir)
+		return fmt.Errorf("cannot open tiered partition %q: %w", tp.id, err)
+	}
+	atomic.AddUint64(&tb.tieringDownloads, 1)
+	ptw := &partitionWrapper{
+		pt:       pt,
+		refCount: 1,
+		dropPath: cacheDir,
+	}
+
+	tb.tieredLock.Lock()
+	dropped := tp.dropped
+	if !dropped {
+		tp.ptw = ptw
+		tp.lastAccessTime = fasttime.UnixTimestamp()
+	}
+	tb.tieredLock.Unlock()
+
+	if dropped {
+		// The partition has been dropped during the download.
+		ptw.scheduleToDrop()
+		ptw.decRef()
+		return nil
+	}
+	logger.Infof("downloaded tiered partition %q in %.3f seconds", tp.id, time.Since(startTime).Seconds())
+	return nil
+}
+
+// getPartitionsForSearch appends partitions with data for the given tr to dst and returns the result.
+//
+// Tiered partitions are downloaded to the local cache if needed. An error is returned
+// if the download isn't finished until the given deadline in unix timestamp seconds.
+// The returned partitions must be passed to PutPartitions when they no longer needed.
+func (tb *table) getPartitionsForSearch(dst []*partitionWrapper, tr TimeRange, deadline uint64) ([]*partitionWrapper, error) {
+	if partitionTier == nil {
+		return tb.GetPartitions(dst), nil
+	}
+	if err := tb.loadTieredPartitions(); err != nil {
+		return dst, err
+	}
+	for {
+		var tpsMissing []*tieredPartition
+		tb.tieredLock.Lock()
+		for _, tp := range tb.tiered {
+			if tp.ptw == nil && tp.overlapsWith(tr) {
+				tpsMissing = append(tpsMissing, tp)
+			}
+		}
+		if len(tpsMissing) == 0 {
+			accessTime := fasttime.UnixTimestamp()
+			for _, tp := range tb.tiered {
+				if tp.overlapsWith(tr) {
+					tp.ptw.incRef()
+					tp.lastAccessTime = accessTime
+					dst = append(dst, tp.ptw)
+				}
+			}
+			tb.tieredLock.Unlock()
+			return tb.GetPartitions(dst), nil
+		}
+		tb.tieredLock.Unlock()
+
+		// Download the missing partitions and try again, since the cached partitions
+		// may be evicted in the mean time.
+		ds := make([]*tieredDownload, len(tpsMissing))
+		for i, tp := range tpsMissing {
+			ds[i] = tb.startTieredPartitionDownload(tp)
+		}
+		for i, d := range ds {
+			if d == nil {
+				continue
+			}
+			if err := waitForTieredDownload(tpsMissing[i], d, deadline); err != nil {
+				return dst, err
+			}
+		}
+	}
+}
+
+// filterTieredRows removes rows, which belong to partitions offloaded to partitionTier.
+func (tb *table) filterTieredRows(rows []rawRow) []rawRow {
+	deadline := tieringDeadline()
+	n := 0
+	for i := range rows {
+		if rows[i].Timestamp < deadline {
+			n++
+		}
+	}
+	if n == 0 {
+		return rows
+	}
+	atomic.AddUint64(&tb.tieringIgnoredRows, uint64(n))
+	// Do not modify rows in place, since they are owned by the caller.
+	rowsFiltered := make([]rawRow, 0, len(rows)-n)
+	for i := range rows {
+		if rows[i].Timestamp >= deadline {
+			rowsFiltered = append(rowsFiltered, rows[i])
+		}
+	}
+	return rowsFiltered
+}
+
+// mustCloseTieredPartitions closes tiered partitions in the local cache.
+func (tb *table) mustCloseTieredPartitions() {
+	tb.tieredLock.Lock()
+	tps := tb.tiered
+	tb.tiered = nil
+	tb.tieredLock.Unlock()
+
+	for _, tp := range tps {
+		if tp.ptw != nil {
+			// The cache is cleared on the next start, so there is no need in dropping the partition.
+			tp.ptw.decRef()
+			tp.ptw = nil
+		}
+	}
+}
+
+func (tb *table) updateTieringMetrics(m *TableMetrics) {
+	m.TieringUploads += atomic.LoadUint64(&tb.tieringUploads)
+	m.TieringDownloads += atomic.LoadUint64(&tb.tieringDownloads)
+	m.TieringRefusedDownloads += atomic.LoadUint64(&tb.tieringRefusedDownloads)
+	m.TieringIgnoredRows += atomic.LoadUint64(&tb.tieringIgnoredRows)
+
+	tb.tieredLock.Lock()
+	for _, tp := range tb.tiered {
+		m.TieredPartitions++
+		if tp.ptw != nil {
+			m.TieringCachedPartitions++
+			m.TieringCacheSizeBytes += tp.ptw.sizeBytes()
+		}
+	}
+	tb.tieredLock.Unlock()
+}
+
+// sizeBytes returns the size of ptw parts on disk.
+func (ptw *partitionWrapper) sizeBytes() uint64 {
+	var m partitionMetrics
+	ptw.pt.UpdateMetrics(&m)
+	return m.SmallSizeBytes + m.BigSizeBytes
+}
@@ -0,0 +1,410 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+)
+
+func TestNewTieredPartitionSuccess(t *testing.T) {
+	f := func(id, nameExpected string) {
+		t.Helper()
+		tp, err := newTieredPartition(id)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if name := tp.name(); name != nameExpected {
+			t.Fatalf("unexpected partition name for %q; got %q; want %q", id, name, nameExpected)
+		}
+		var trExpected TimeRange
+		if err := trExpected.fromPartitionName(nameExpected); err != nil {
+			t.Fatalf("cannot parse partition name: %s", err)
+		}
+		if tp.tr != trExpected {
+			t.Fatalf("unexpected time range for %q; got %s; want %s", id, &tp.tr, &trExpected)
+		}
+	}
+	f("2021_01_16C1B3F9B6A0E2D4", "2021_01")
+	f(newTieredPartitionID("2020_12"), "2020_12")
+	f("2021_01_31_16C1B3F9B6A0E2D4", "2021_01_31")
+	f(newTieredPartitionID("2020_12_01"), "2020_12_01")
+}
+
+func TestNewTieredPartitionFailure(t *testing.T) {
+	f := func(id string) {
+		t.Helper()
+		if _, err := newTieredPartition(id); err == nil {
+			t.Fatalf("expecting non-nil error for %q", id)
+		}
+	}
+	f("")
+	f("2021_01")
+	f("2021_01-123")
+	f("2021_13_123")
+	f("2021_01_32_123")
+	f("foobar_123")
+}
+
+func TestTieredPartitionOverlapsWith(t *testing.T) {
+	tp, err := newTieredPartition("2021_02_1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(minTime, maxTime string, resultExpected bool) {
+		t.Helper()
+		tr := TimeRange{
+			MinTimestamp: testMustParseTimestamp(t, minTime),
+			MaxTimestamp: testMustParseTimestamp(t, maxTime),
+		}
+		if result := tp.overlapsWith(tr); result != resultExpected {
+			t.Fatalf("unexpected result for %s; got %v; want %v", &tr, result, resultExpected)
+		}
+	}
+	f("2021-01-01T00:00:00Z", "2021-01-31T23:59:59Z", false)
+	f("2021-01-01T00:00:00Z", "2021-02-01T00:00:00Z", true)
+	f("2021-02-10T00:00:00Z", "2021-02-11T00:00:00Z", true)
+	f("2021-01-01T00:00:00Z", "2021-04-01T00:00:00Z", true)
+	f("2021-02-28T23:59:59Z", "2021-03-10T00:00:00Z", true)
+	f("2021-03-01T00:00:00Z", "2021-03-10T00:00:00Z", false)
+}
+
+func testMustParseTimestamp(t *testing.T, s string) int64 {
+	t.Helper()
+	tm, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatalf("cannot parse %q: %s", s, err)
+	}
+	return timestampFromTime(tm)
+}
+
+func TestTableFilterTieredRows(t *testing.T) {
+	defer SetPartitionTier(nil, 0, 0)
+	SetPartitionTier(newTestPartitionTier(t), 3600*1000, 0)
+
+	var tb table
+	now := int64(time.Now().UnixNano() / 1e6)
+	rows := []rawRow{
+		{Timestamp: now - 2*3600*1000},
+		{Timestamp: now},
+		{Timestamp: now - 3*3600*1000},
+		{Timestamp: now - 1000},
+	}
+	rowsFiltered := tb.filterTieredRows(rows)
+	if len(rowsFiltered) != 2 || rowsFiltered[0].Timestamp != now || rowsFiltered[1].Timestamp != now-1000 {
+		t.Fatalf("unexpected filtered rows: %+v", rowsFiltered)
+	}
+	if rows[0].Timestamp != now-2*3600*1000 {
+		t.Fatalf("the original rows must remain unchanged")
+	}
+	if tb.tieringIgnoredRows != 2 {
+		t.Fatalf("unexpected number of ignored rows; got %d; want 2", tb.tieringIgnoredRows)
+	}
+}
+
+func TestStorageAddTieredRows(t *testing.T) {
+	const path = "TestStorageAddTieredRows"
+	defer SetPartitionTier(nil, 0, 0)
+	SetPartitionTier(newTestPartitionTier(t), 3600*1000, 1<<30)
+
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	defer func() {
+		s.MustClose()
+		_ = os.RemoveAll(path)
+	}()
+	mn := MetricName{
+		MetricGroup: []byte("foo"),
+	}
+	now := int64(time.Now().UnixNano() / 1e6)
+	mrs := []MetricRow{
+		{MetricNameRaw: mn.marshalRaw(nil), Timestamp: now - 2*3600*1000, Value: 1},
+		{MetricNameRaw: mn.marshalRaw(nil), Timestamp: now, Value: 2},
+		{MetricNameRaw: mn.marshalRaw(nil), Timestamp: now - 3*3600*1000, Value: 3},
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.TieredTimestampRows != 2 {
+		t.Fatalf("unexpected number of rows older than -storage.tieringAge; got %d; want 2", m.TieredTimestampRows)
+	}
+	if m.TableMetrics.TieringIgnoredRows != 0 {
+		t.Fatalf("rows older than -storage.tieringAge must be skipped before adding them to the table; got %d rows ignored by the table",
+			m.TableMetrics.TieringIgnoredRows)
+	}
+}
+
+func TestTableTiering(t *testing.T) {
+	const path = "TestTableTiering"
+	const retentionMsecs = 12 * msecsPerMonth
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+
+	// Fill a partition, which must be offloaded.
+	var trOld TimeRange
+	trOld.fromPartitionTime(time.Now().AddDate(0, -4, 0))
+	var rows []rawRow
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, rawRow{
+			TSID: TSID{
+				MetricID: uint64(i % 10),
+			},
+			Timestamp:     trOld.MinTimestamp + int64(i)*1000,
+			Value:         float64(i),
+			PrecisionBits: 64,
+		})
+	}
+	tb, err := openTable(path+"/table", nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
+	if err != nil {
+		t.Fatalf("cannot open table: %s", err)
+	}
+	if err := tb.AddRows(rows); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	tb.MustClose()
+
+	tier := newTestPartitionTier(t)
+	defer SetPartitionTier(nil, 0, 0)
+	SetPartitionTier(tier, 2*msecsPerMonth, 1<<30)
+
+	tb, err = openTable(path+"/table", nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
+	if err != nil {
+		t.Fatalf("cannot open table: %s", err)
+	}
+
+	// Wait until the partition is offloaded.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var m TableMetrics
+		tb.UpdateMetrics(&m)
+		if m.TieredPartitions == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for partition offloading")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ids, err := tier.ListPartitions()
+	if err != nil {
+		t.Fatalf("cannot list tiered partitions: %s", err)
+	}
+	if len(ids) != 1 || ids[0][:len("YYYY_MM")] != timestampToPartitionName(trOld.MinTimestamp) {
+		t.Fatalf("unexpected tiered partitions: %q", ids)
+	}
+	var m TableMetrics
+	tb.UpdateMetrics(&m)
+	if n := m.SmallRowsCount + m.BigRowsCount; n != 0 {
+		t.Fatalf("unexpected number of local rows after offloading; got %d; want 0", n)
+	}
+
+	// Search must download the tiered partition.
+	testTableTieringSearch(t, tb, trOld, len(rows))
+	m = TableMetrics{}
+	tb.UpdateMetrics(&m)
+	if m.TieringCachedPartitions != 1 || m.TieringDownloads != 1 || m.TieringCacheSizeBytes == 0 {
+		t.Fatalf("unexpected cache metrics after search: %+v", m)
+	}
+
+	// Recently accessed partitions mustn't be evicted.
+	if tb.evictTieredPartitions(1 << 30) {
+		t.Fatalf("expecting no space in the cache with recently accessed partitions")
+	}
+	m = TableMetrics{}
+	tb.UpdateMetrics(&m)
+	if m.TieringCachedPartitions != 1 {
+		t.Fatalf("unexpected number of cached partitions; got %d; want 1", m.TieringCachedPartitions)
+	}
+	tb.tieredLock.Lock()
+	for _, tp := range tb.tiered {
+		tp.lastAccessTime = 0
+	}
+	tb.tieredLock.Unlock()
+	if !tb.evictTieredPartitions(1 << 30) {
+		t.Fatalf("expecting free space in the cache after the eviction")
+	}
+	m = TableMetrics{}
+	tb.UpdateMetrics(&m)
+	if m.TieringCachedPartitions != 0 {
+		t.Fatalf("unexpected number of cached partitions after eviction; got %d; want 0", m.TieringCachedPartitions)
+	}
+
+	// Partitions exceeding the cache size mustn't be downloaded.
+	tieringCacheSizeBytes = 1
+	if err := testTableTieringSearchError(tb, trOld, noDeadline); err == nil || !strings.Contains(err.Error(), "exceeds -storage.tieringCacheSize") {
+		t.Fatalf("unexpected error for partition exceeding the cache size: %v", err)
+	}
+	tieringCacheSizeBytes = 1 << 30
+	m = TableMetrics{}
+	tb.UpdateMetrics(&m)
+	if m.TieringRefusedDownloads != 1 || m.TieringDownloads != 1 {
+		t.Fatalf("unexpected metrics after refused download: %+v", m)
+	}
+
+	// Search must fail on deadline, while the download continues in background.
+	tier.downloadCh = make(chan struct{})
+	err = testTableTieringSearchError(tb, trOld, fasttime.UnixTimestamp()-1)
+	if !errors.Is(err, ErrDeadlineExceeded) {
+		t.Fatalf("unexpected error on deadline; got %v; want %v", err, ErrDeadlineExceeded)
+	}
+	close(tier.downloadCh)
+	testTableTieringSearch(t, tb, trOld, len(rows))
+	tier.downloadCh = nil
+	m = TableMetrics{}
+	tb.UpdateMetrics(&m)
+	if m.TieringCachedPartitions != 1 || m.TieringDownloads != 2 {
+		t.Fatalf("unexpected metrics after the background download: %+v", m)
+	}
+
+	// Rows for tiered partitions must be ignored.
+	if err := tb.AddRows(rows[:10]); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	tb.MustClose()
+
+	// The reopened table must serve the tiered partition.
+	tb, err = openTable(path+"/table", nilGetDeletedMetricIDs, nil, nil, retentionMsecs)
+	if err != nil {
+		t.Fatalf("cannot open table: %s", err)
+	}
+	testTableTieringSearch(t, tb, trOld, len(rows))
+	m = TableMetrics{}
+	tb.UpdateMetrics(&m)
+	if m.TieringIgnoredRows != 0 || m.TieringDownloads != 1 {
+		t.Fatalf("unexpected metrics after reopening: %+v", m)
+	}
+	tb.MustClose()
+}
+
+func testTableTieringSearch(t *testing.T, tb *table, tr TimeRange, rowsCountExpected int) {
+	t.Helper()
+	var tsids []TSID
+	for i := 0; i < 10; i++ {
+		tsids = append(tsids, TSID{
+			MetricID: uint64(i),
+		})
+	}
+	var ts tableSearch
+	ts.Init(tb, tsids, tr, noDeadline)
+	rowsCount := 0
+	for ts.NextBlock() {
+		var b Block
+		ts.BlockRef.MustReadBlock(&b, true)
+		rowsCount += b.RowsCount()
+	}
+	if err := ts.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ts.MustClose()
+	if rowsCount != rowsCountExpected {
+		t.Fatalf("unexpected number of rows found; got %d; want %d", rowsCount, rowsCountExpected)
+	}
+}
+
+func testTableTieringSearchError(tb *table, tr TimeRange, deadline uint64) error {
+	var ts tableSearch
+	ts.Init(tb, []TSID{{MetricID: 0}}, tr, deadline)
+	for ts.NextBlock() {
+	}
+	err := ts.Error()
+	ts.MustClose()
+	return err
+}
+
+// testPartitionTier stores partitions in a local directory.
+type testPartitionTier struct {
+	dir string
+
+	// downloadCh blocks DownloadPartition until it is closed if set.
+	downloadCh chan struct{}
+}
+
+func newTestPartitionTier(t *testing.T) *testPartitionTier {
+	dir, err := os.MkdirTemp("", "TestPartitionTier")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return &testPartitionTier{
+		dir: dir,
+	}
+}
+
+func (tpt *testPartitionTier) UploadPartition(id, localDir string) error {
+	return testCopyDir(localDir, tpt.dir+"/"+id)
+}
+
+func (tpt *testPartitionTier) DownloadPartition(id, localDir string) error {
+	if tpt.downloadCh != nil {
+		<-tpt.downloadCh
+	}
+	return testCopyDir(tpt.dir+"/"+id, localDir)
+}
+
+func (tpt *testPartitionTier) PartitionSize(id string) (uint64, error) {
+	n := uint64(0)
+	err := filepath.Walk(tpt.dir+"/"+id, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			n += uint64(fi.Size())
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (tpt *testPartitionTier) ListPartitions() ([]string, error) {
+	des, err := os.ReadDir(tpt.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, de := range des {
+		ids = append(ids, de.Name())
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (tpt *testPartitionTier) DeletePartition(id string) error {
+	return os.RemoveAll(tpt.dir + "/" + id)
+}
+
+func testCopyDir(srcDir, dstDir string) error {
+	return filepath.Walk(srcDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dstDir, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(dstPath, 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read %q: %w", path, err)
+		}
+		return os.WriteFile(dstPath, data, 0644)
+	})
+}
@@ -31,14 +31,33 @@ func (tr *TimeRange) String() string {
 	return fmt.Sprintf("[%s - %s]", minTime, maxTime)
 }
 
-// timestampToPartitionName returns partition name for the given timestamp.
+// timestampToPartitionName returns per-month partition name for the given timestamp.
 func timestampToPartitionName(timestamp int64) string {
 	t := timestampToTime(timestamp)
 	return t.Format("2006_01")
 }
 
+// timestampToDailyPartitionName returns per-day partition name for the given timestamp.
+//
+// Per-day partitions are used for retention shorter than a month. See table.partitionNameForTimestamp.
+func timestampToDailyPartitionName(timestamp int64) string {
+	t := timestampToTime(timestamp)
+	return t.Format("2006_01_02")
+}
+
 // fromPartitionName initializes tr from the given parition name.
+//
+// The name may be in the form YYYY_MM for per-month partitions or YYYY_MM_DD for per-day partitions.
 func (tr *TimeRange) fromPartitionName(name string) error {
+	if len(name) == len("2006_01_02") {
+		t, err := time.Parse("2006_01_02", name)
+		if err != nil {
+			return fmt.Errorf("cannot parse daily partition name %q: %w", name, err)
+		}
+		tr.MinTimestamp = timestampFromTime(t)
+		tr.MaxTimestamp = tr.MinTimestamp + msecPerDay - 1
+		return nil
+	}
 	t, err := time.Parse("2006_01", name)
 	if err != nil {
 		return fmt.Errorf("cannot parse partition name %q: %w", name, err)
@@ -65,3 +84,9 @@ func (tr *TimeRange) fromPartitionTime(t time.Time) {
 const msecPerDay = 24 * 3600 * 1000
 
 const msecPerHour = 3600 * 1000
+
+// TimestampToHumanReadableFormat converts the given timestamp in milliseconds to human-readable format.
+func TimestampToHumanReadableFormat(timestamp int64) string {
+	t := timestampToTime(timestamp)
+	return t.Format("2006-01-02T15:04:05.999Z")
+}
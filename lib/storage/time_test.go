@@ -52,3 +52,48 @@ func testTimeRangeFromPartition(t *testing.T, initialTime time.Time) {
 		t.Fatalf("unexpected nextY, nextM; got %d, %d; want %d, %d+1;\nnextTime=%s\nmaxTime=%s", nextY, nextM, maxY, maxM, nextTime, maxTime)
 	}
 }
+
+func TestTimeRangeFromDailyPartitionName(t *testing.T) {
+	f := func(timestamp int64, minTimestampExpected int64) {
+		t.Helper()
+		name := timestampToDailyPartitionName(timestamp)
+		var tr TimeRange
+		if err := tr.fromPartitionName(name); err != nil {
+			t.Fatalf("cannot parse partition name %q: %s", name, err)
+		}
+		trExpected := TimeRange{
+			MinTimestamp: minTimestampExpected,
+			MaxTimestamp: minTimestampExpected + msecPerDay - 1,
+		}
+		if tr != trExpected {
+			t.Fatalf("unexpected time range for partition %q; got %s; want %s", name, &tr, &trExpected)
+		}
+		if timestamp < tr.MinTimestamp || timestamp > tr.MaxTimestamp {
+			t.Fatalf("timestamp %d is outside the time range %s for partition %q", timestamp, &tr, name)
+		}
+	}
+	// 2021-01-31T00:00:00Z
+	f(1612051200000, 1612051200000)
+	// 2021-01-31T23:59:59.999Z
+	f(1612137599999, 1612051200000)
+	// 2021-02-01T12:00:00Z
+	f(1612180800000, 1612137600000)
+
+	var tr TimeRange
+	if err := tr.fromPartitionName("2021_02_30"); err == nil {
+		t.Fatalf("expecting non-nil error for invalid daily partition name")
+	}
+}
+
+func TestTimestampToHumanReadableFormat(t *testing.T) {
+	f := func(timestamp int64, resultExpected string) {
+		t.Helper()
+		result := TimestampToHumanReadableFormat(timestamp)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for timestamp=%d; got %q; want %q", timestamp, result, resultExpected)
+		}
+	}
+	f(0, "1970-01-01T00:00:00Z")
+	f(1594370496905, "2020-07-10T08:41:36.905Z")
+	f(1594370496900, "2020-07-10T08:41:36.9Z")
+}
@@ -64,7 +64,7 @@ func Load(filePath string, maxBytes int, expireDuration time.Duration) *Cache {
 		// This may mean that the cache is split into curr and prev caches.
 		// Try loading it again with maxBytes / 2 size.
 		curr := fastcache.New(maxBytes / 2)
-		prev := fastcache.LoadFromFileOrNew(filePath, maxBytes/2)
+		prev := loadPrevCache(filePath, maxBytes/2)
 		c := newCacheInternal(curr, prev, maxBytes, split)
 		c.runWatchers(expireDuration)
 		return c
@@ -77,6 +77,32 @@ func Load(filePath string, maxBytes int, expireDuration time.Duration) *Cache {
 	return newCacheInternal(curr, prev, maxBytes, whole)
 }
 
+// loadPrevCache loads the cache from filePath for using it as prev cache with maxBytes size.
+//
+// The cache saved with another size, for example, after changing the cache size via command-line flags,
+// is loaded with its original size if its contents fit maxBytes. Then its entries are gradually moved
+// to curr cache on access until the prev cache expires, so the cache contents survive the size change.
+func loadPrevCache(filePath string, maxBytes int) *fastcache.Cache {
+	prev := fastcache.LoadFromFileOrNew(filePath, maxBytes)
+	var cs fastcache.Stats
+	prev.UpdateStats(&cs)
+	if cs.EntriesCount > 0 {
+		return prev
+	}
+	c, err := fastcache.LoadFromFile(filePath)
+	if err != nil {
+		return prev
+	}
+	cs.Reset()
+	c.UpdateStats(&cs)
+	if cs.EntriesCount == 0 || cs.BytesSize > uint64(maxBytes) {
+		c.Reset()
+		return prev
+	}
+	prev.Reset()
+	return c
+}
+
 // New creates new cache with the given maxBytes capcity and the given expireDuration
 // for inactive entries.
 //